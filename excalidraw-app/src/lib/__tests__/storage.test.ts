@@ -256,6 +256,20 @@ describe('ServerStorage', () => {
         const result = await storage.listSnapshots('room-1');
         expect(result).toEqual([]);
       });
+
+      it('should use server-rendered thumbnails', async () => {
+        fetchMock.mockResolvedValue({
+          ok: true,
+          json: async () => [
+            { id: 'snap-1', room_id: 'room-1', created_at: 1, thumbnail_url: '/api/snapshots/snap-1/thumbnail' },
+            { id: 'snap-2', room_id: 'room-1', created_at: 2, thumbnail: 'data:image/png;base64,abc', thumbnail_url: '/api/snapshots/snap-2/thumbnail' },
+          ],
+        });
+
+        const result = await storage.listSnapshots('room-1');
+        expect(result[0].thumbnail).toBe('http://localhost:3002/api/snapshots/snap-1/thumbnail');
+        expect(result[1].thumbnail).toBe('data:image/png;base64,abc');
+      });
     });
 
     describe('loadSnapshot', () => {
@@ -87,6 +87,8 @@ export interface Snapshot {
   name?: string;
   description?: string;
   thumbnail?: string;
+  // Server-rendered thumbnail, relative to the server URL
+  thumbnail_url?: string;
   created_by?: string;
   created_at: number;
   data?: string;
@@ -364,7 +366,12 @@ export class ServerStorage {
       throw new Error('Failed to list snapshots from server');
     }
 
-    return response.json();
+    const snapshots: Snapshot[] = await response.json();
+    return snapshots.map((snapshot) =>
+      !snapshot.thumbnail && snapshot.thumbnail_url
+        ? { ...snapshot, thumbnail: `${this.serverUrl}${snapshot.thumbnail_url}` }
+        : snapshot
+    );
   }
 
   async loadSnapshot(id: string): Promise<Snapshot> {
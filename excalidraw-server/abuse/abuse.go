@@ -0,0 +1,246 @@
+// Package abuse counts the violations of each client and bans clients that
+// keep misbehaving from connecting for a while. Clients are identified by
+// the keys the rate limiter uses, "user:<id>" or "ip:<address>". Every ban
+// is longer than the last one of the same client, up to a maximum, and a
+// client's record is forgotten after a day without violations.
+package abuse
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// forgetAfter is how long a client must behave before its earlier bans no
+// longer make the next one longer.
+const forgetAfter = 24 * time.Hour
+
+// Violation is a kind of misbehavior.
+type Violation string
+
+const (
+	// RateLimited is a request the rate limiter refused.
+	RateLimited Violation = "rate_limited"
+	// MalformedFrame is an event or frame that doesn't match its schema.
+	MalformedFrame Violation = "malformed_frame"
+	// OversizedPayload is an event over its size limit.
+	OversizedPayload Violation = "oversized_payload"
+)
+
+// Config says when clients are banned and for how long.
+type Config struct {
+	// Threshold is how many violations within Window earn a ban; zero
+	// turns banning off.
+	Threshold int
+	Window    time.Duration
+	// BanDuration is the length of a client's first ban; each further one
+	// doubles, up to MaxBanDuration.
+	BanDuration    time.Duration
+	MaxBanDuration time.Duration
+}
+
+// ConfigFromEnv reads ABUSE_BAN_THRESHOLD (default 20, 0 turns bans off),
+// ABUSE_WINDOW (default 1m), ABUSE_BAN_DURATION (default 1m) and
+// ABUSE_MAX_BAN_DURATION (default 1h).
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Threshold: 20, Window: time.Minute, BanDuration: time.Minute, MaxBanDuration: time.Hour}
+	if value := os.Getenv("ABUSE_BAN_THRESHOLD"); value != "" {
+		threshold, err := strconv.Atoi(value)
+		if err != nil || threshold < 0 {
+			return Config{}, fmt.Errorf("invalid ABUSE_BAN_THRESHOLD %q: must be a non-negative integer", value)
+		}
+		cfg.Threshold = threshold
+	}
+	for _, setting := range []struct {
+		name  string
+		value *time.Duration
+	}{{"ABUSE_WINDOW", &cfg.Window}, {"ABUSE_BAN_DURATION", &cfg.BanDuration}, {"ABUSE_MAX_BAN_DURATION", &cfg.MaxBanDuration}} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return Config{}, fmt.Errorf("invalid %s %q: must be a positive duration", setting.name, value)
+		}
+		*setting.value = duration
+	}
+	if cfg.MaxBanDuration < cfg.BanDuration {
+		return Config{}, fmt.Errorf("ABUSE_MAX_BAN_DURATION %s is shorter than ABUSE_BAN_DURATION %s", cfg.MaxBanDuration, cfg.BanDuration)
+	}
+	return cfg, nil
+}
+
+// Ban is a client that may not connect until Until.
+type Ban struct {
+	Key   string    `json:"key"`
+	Until time.Time `json:"until"`
+	// Bans counts the client's bans, this one included, since it last
+	// behaved for a day.
+	Bans int `json:"bans"`
+	// Violation is the one that earned the ban.
+	Violation Violation `json:"violation"`
+}
+
+type client struct {
+	// violations are the times of the violations in the current window
+	violations    []time.Time
+	bans          int
+	bannedUntil   time.Time
+	lastViolation time.Time
+	violation     Violation
+}
+
+// Tracker counts violations and bans clients.
+type Tracker struct {
+	mu        sync.Mutex
+	cfg       Config
+	clients   map[string]*client
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewTracker(cfg Config) *Tracker {
+	return &Tracker{cfg: cfg, clients: make(map[string]*client), now: time.Now}
+}
+
+// SetConfig changes the thresholds and durations at runtime; bans already
+// given keep their length.
+func (t *Tracker) SetConfig(cfg Config) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cfg = cfg
+}
+
+// Report counts a violation of the client with key and bans it when that
+// makes Threshold within Window. It returns the ban that is in effect, if
+// any. Violations of banned clients aren't counted.
+func (t *Tracker) Report(key string, violation Violation) (Ban, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.sweep(now)
+	if t.cfg.Threshold <= 0 {
+		return Ban{}, false
+	}
+
+	c, exists := t.clients[key]
+	if !exists {
+		c = &client{}
+		t.clients[key] = c
+	}
+	if now.Before(c.bannedUntil) {
+		return c.ban(key), true
+	}
+
+	if now.Sub(c.lastViolation) > forgetAfter {
+		c.bans = 0
+	}
+	c.lastViolation = now
+	recent := c.violations[:0]
+	for _, at := range c.violations {
+		if now.Sub(at) < t.cfg.Window {
+			recent = append(recent, at)
+		}
+	}
+	c.violations = append(recent, now)
+	if len(c.violations) < t.cfg.Threshold {
+		return Ban{}, false
+	}
+
+	duration := t.cfg.BanDuration
+	for i := 0; i < c.bans && duration < t.cfg.MaxBanDuration; i++ {
+		duration *= 2
+	}
+	c.bannedUntil = now.Add(min(duration, t.cfg.MaxBanDuration))
+	c.bans++
+	c.violations = nil
+	c.violation = violation
+
+	ban := c.ban(key)
+	logrus.WithFields(logrus.Fields{
+		"key":       key,
+		"violation": violation,
+		"bans":      ban.Bans,
+		"until":     ban.Until.Format(time.RFC3339),
+	}).Warn("Temporarily banned abusive client")
+	return ban, true
+}
+
+// Banned returns the ban in effect on any of keys, if any.
+func (t *Tracker) Banned(keys ...string) (Ban, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for _, key := range keys {
+		if c, exists := t.clients[key]; exists && now.Before(c.bannedUntil) {
+			return c.ban(key), true
+		}
+	}
+	return Ban{}, false
+}
+
+// Bans lists the bans in effect, the longest first.
+func (t *Tracker) Bans() []Ban {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	bans := []Ban{}
+	for key, c := range t.clients {
+		if now.Before(c.bannedUntil) {
+			bans = append(bans, c.ban(key))
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.After(bans[j].Until) })
+	return bans
+}
+
+// Lift ends a client's ban and forgets its earlier ones, and reports
+// whether it was banned.
+func (t *Tracker) Lift(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, exists := t.clients[key]
+	if !exists {
+		return false
+	}
+	delete(t.clients, key)
+	if !t.now().Before(c.bannedUntil) {
+		return false
+	}
+	logrus.WithField("key", key).Info("Lifted ban on client")
+	return true
+}
+
+func (c *client) ban(key string) Ban {
+	return Ban{Key: key, Until: c.bannedUntil, Bans: c.bans, Violation: c.violation}
+}
+
+// sweep forgets clients that have behaved for a day, or for a window when
+// they were never banned, at most once a minute.
+func (t *Tracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < time.Minute {
+		return
+	}
+	t.lastSweep = now
+
+	for key, c := range t.clients {
+		// clients never banned only matter for their current window
+		forget := forgetAfter
+		if c.bans == 0 {
+			forget = t.cfg.Window
+		}
+		if now.After(c.bannedUntil) && now.Sub(c.lastViolation) > forget {
+			delete(t.clients, key)
+		}
+	}
+}
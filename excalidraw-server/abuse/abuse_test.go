@@ -0,0 +1,84 @@
+package abuse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tracker := NewTracker(Config{Threshold: 3, Window: time.Minute, BanDuration: time.Minute, MaxBanDuration: 3 * time.Minute})
+	tracker.now = func() time.Time { return now }
+
+	// violations spread wider than the window don't add up
+	for i := 0; i < 4; i++ {
+		if _, banned := tracker.Report("ip:a", MalformedFrame); banned {
+			t.Fatalf("banned after spread out violation %d", i+1)
+		}
+		now = now.Add(40 * time.Second)
+	}
+
+	// each ban of the same client is twice as long, up to the maximum
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		var ban Ban
+		var banned bool
+		for i := 0; i < 3; i++ {
+			ban, banned = tracker.Report("ip:b", OversizedPayload)
+		}
+		if !banned || ban.Until.Sub(now) != want {
+			t.Fatalf("ban = %+v, %v, want one of %s", ban, banned, want)
+		}
+		if _, banned := tracker.Banned("user:x", "ip:b"); !banned {
+			t.Error("Banned() missed the ban")
+		}
+		now = ban.Until
+	}
+	if _, banned := tracker.Banned("ip:b"); banned {
+		t.Error("the ban outlasted its end")
+	}
+
+	// a day without violations starts over
+	now = now.Add(25 * time.Hour)
+	for i := 0; i < 3; i++ {
+		tracker.Report("ip:b", RateLimited)
+	}
+	bans := tracker.Bans()
+	if len(bans) != 1 || bans[0].Bans != 1 || bans[0].Until.Sub(now) != time.Minute || bans[0].Violation != RateLimited {
+		t.Errorf("bans = %+v", bans)
+	}
+
+	if !tracker.Lift("ip:b") || tracker.Lift("ip:b") {
+		t.Error("Lift() didn't lift the ban once")
+	}
+	if len(tracker.Bans()) != 0 {
+		t.Error("the lifted ban is still listed")
+	}
+
+	tracker.SetConfig(Config{})
+	for i := 0; i < 5; i++ {
+		if _, banned := tracker.Report("ip:c", MalformedFrame); banned {
+			t.Fatal("banned with bans turned off")
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("ABUSE_BAN_THRESHOLD", "5")
+	t.Setenv("ABUSE_WINDOW", "30s")
+	t.Setenv("ABUSE_BAN_DURATION", "")
+	t.Setenv("ABUSE_MAX_BAN_DURATION", "")
+	cfg, err := ConfigFromEnv()
+	want := Config{Threshold: 5, Window: 30 * time.Second, BanDuration: time.Minute, MaxBanDuration: time.Hour}
+	if err != nil || cfg != want {
+		t.Errorf("got %+v, %v, want %+v", cfg, err, want)
+	}
+
+	for name, value := range map[string]string{"ABUSE_BAN_THRESHOLD": "-1", "ABUSE_WINDOW": "0s", "ABUSE_MAX_BAN_DURATION": "10s"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := ConfigFromEnv(); err == nil {
+				t.Errorf("%s=%s was accepted", name, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+// Package apierror gives API errors machine-readable codes, so clients can
+// branch on failures without parsing messages. HTTP handlers answer with
+// Write, which keeps the plain text body older clients read and adds the
+// code in the X-Error-Code header; /api/v3 turns both into an Envelope.
+// Socket acks carry the code of an *Error next to its message, and GraphQL
+// errors in their extensions.
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// CodeHeader carries the code of an error response.
+const CodeHeader = "X-Error-Code"
+
+// Code identifies a kind of failure. Codes are stable; messages may change.
+type Code string
+
+// Generic codes, also used for responses that don't set one.
+const (
+	InvalidRequest   Code = "invalid_request"
+	Unauthorized     Code = "unauthorized"
+	Forbidden        Code = "forbidden"
+	NotFound         Code = "not_found"
+	MethodNotAllowed Code = "method_not_allowed"
+	Conflict         Code = "conflict"
+	TooLarge         Code = "too_large"
+	RateLimited      Code = "rate_limited"
+	NotImplemented   Code = "not_implemented"
+	Internal         Code = "internal"
+	Timeout          Code = "timeout"
+	Cancelled        Code = "cancelled"
+	UpstreamFailure  Code = "upstream_failure"
+)
+
+// Codes for specific failures.
+const (
+	InvalidToken      Code = "invalid_token"
+	ChallengeFailed   Code = "challenge_failed"
+	ChecksumMismatch  Code = "checksum_mismatch"
+	InvalidCursor     Code = "invalid_cursor"
+	InvalidPayload    Code = "invalid_payload"
+	PayloadTooLarge   Code = "payload_too_large"
+	Filtered          Code = "filtered"
+	NotAScene         Code = "not_a_scene"
+	SceneTooLarge     Code = "scene_too_large"
+	UnsupportedFormat Code = "unsupported_format"
+
+	DocumentNotFound  Code = "document_not_found"
+	FileNotFound      Code = "file_not_found"
+	FileExists        Code = "file_exists"
+	CanvasNotFound    Code = "canvas_not_found"
+	CanvasExists      Code = "canvas_exists"
+	InvalidCanvasKey  Code = "invalid_canvas_key"
+	LibraryNotFound   Code = "library_not_found"
+	TemplateNotFound  Code = "template_not_found"
+	SnapshotNotFound  Code = "snapshot_not_found"
+	OrgNotFound       Code = "org_not_found"
+	MemberNotFound    Code = "member_not_found"
+	LastAdmin         Code = "last_admin"
+	RecordingNotFound Code = "recording_not_found"
+	RecordingActive   Code = "recording_active"
+
+	RoomNotFound     Code = "room_not_found"
+	RoomInUse        Code = "room_in_use"
+	RoomEncrypted    Code = "room_encrypted"
+	NotAllowedInRoom Code = "not_allowed_in_room"
+	NotInRoom        Code = "not_in_room"
+	NotRoomOwner     Code = "not_room_owner"
+	Banned           Code = "banned"
+	Muted            Code = "muted"
+	MessageNotFound  Code = "message_not_found"
+
+	PresentationTaken Code = "presentation_taken"
+	NotPresenter      Code = "not_presenter"
+	TooManyReactions  Code = "too_many_reactions"
+)
+
+type (
+	// Error is a failure with a code, for errors that travel back to a
+	// client through socket acks.
+	Error struct {
+		Code    Code
+		Message string
+	}
+
+	// Envelope is the JSON body of /api/v3 error responses.
+	Envelope struct {
+		Error Body `json:"error"`
+	}
+
+	Body struct {
+		Status  int    `json:"status"`
+		Code    Code   `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+// New returns an error with a code.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Errorf returns an error with a code and a formatted message.
+func Errorf(code Code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions carries the code in the extensions of GraphQL errors.
+func (e *Error) Extensions() map[string]any {
+	return map[string]any{"code": e.Code}
+}
+
+// CodeOf returns the code of err, or Internal for errors without one.
+func CodeOf(err error) Code {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return Internal
+}
+
+// Write answers with an error. Requests that ran out of time or were
+// cancelled, which deadline.Status reports as 504 and 503, get the codes
+// timeout and cancelled whatever code the handler meant to send.
+func Write(w http.ResponseWriter, status int, code Code, message string) {
+	switch status {
+	case http.StatusGatewayTimeout:
+		code = Timeout
+	case http.StatusServiceUnavailable:
+		code = Cancelled
+	}
+	w.Header().Set(CodeHeader, string(code))
+	http.Error(w, message, status)
+}
+
+// ForStatus is the generic code of a status, for error responses written
+// without one.
+func ForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return InvalidRequest
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusMethodNotAllowed:
+		return MethodNotAllowed
+	case http.StatusConflict:
+		return Conflict
+	case http.StatusRequestEntityTooLarge:
+		return TooLarge
+	case http.StatusTooManyRequests:
+		return RateLimited
+	case http.StatusNotImplemented:
+		return NotImplemented
+	case http.StatusBadGateway:
+		return UpstreamFailure
+	case http.StatusServiceUnavailable:
+		return Cancelled
+	case http.StatusGatewayTimeout:
+		return Timeout
+	}
+	if status < http.StatusInternalServerError {
+		return InvalidRequest
+	}
+	return Internal
+}
@@ -0,0 +1,55 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	Write(w, http.StatusNotFound, CanvasNotFound, "Canvas not found")
+	if w.Code != http.StatusNotFound || w.Body.String() != "Canvas not found\n" {
+		t.Errorf("Write() = %d %q, want the plain text error", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(CodeHeader); got != string(CanvasNotFound) {
+		t.Errorf("%s = %q, want %q", CodeHeader, got, CanvasNotFound)
+	}
+
+	// Timeouts win over the handler's code
+	w = httptest.NewRecorder()
+	Write(w, http.StatusGatewayTimeout, CanvasNotFound, "Request timed out")
+	if got := w.Header().Get(CodeHeader); got != string(Timeout) {
+		t.Errorf("%s = %q, want %q", CodeHeader, got, Timeout)
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	err := fmt.Errorf("join: %w", New(Banned, "banned from room"))
+	if got := CodeOf(err); got != Banned {
+		t.Errorf("CodeOf(wrapped) = %q, want %q", got, Banned)
+	}
+	if got := CodeOf(errors.New("boom")); got != Internal {
+		t.Errorf("CodeOf(plain) = %q, want %q", got, Internal)
+	}
+	if got := Errorf(NotInRoom, "not in room %s", "abc").Error(); got != "not in room abc" {
+		t.Errorf("Errorf() = %q", got)
+	}
+}
+
+func TestForStatus(t *testing.T) {
+	tests := map[int]Code{
+		http.StatusNotFound:            NotFound,
+		http.StatusTooManyRequests:     RateLimited,
+		http.StatusTeapot:              InvalidRequest,
+		http.StatusInternalServerError: Internal,
+		http.StatusInsufficientStorage: Internal,
+	}
+	for status, want := range tests {
+		if got := ForStatus(status); got != want {
+			t.Errorf("ForStatus(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
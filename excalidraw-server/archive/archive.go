@@ -0,0 +1,148 @@
+// Package archive moves documents and canvases that nobody has opened for a
+// while out of the database into cheaper cold storage, a directory or an S3
+// bucket. Stores restore archived data transparently when it is read.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/backup"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultInterval = 24 * time.Hour
+	defaultPrefix   = "excalidraw-archive/"
+)
+
+// Settings configures archiving. Exactly one of Path and S3.Bucket is set.
+type Settings struct {
+	// IdleAfter is how long data goes unread and unsaved before it is
+	// archived.
+	IdleAfter time.Duration
+	// Interval is how often to look for idle data.
+	Interval time.Duration
+	Path     string
+	S3       backup.S3Config
+	// Prefix is prepended to every S3 key.
+	Prefix string
+}
+
+// SettingsFromEnv reads ARCHIVE_AFTER_DAYS, ARCHIVE_INTERVAL, ARCHIVE_PATH,
+// ARCHIVE_S3_BUCKET, ARCHIVE_S3_PREFIX, ARCHIVE_S3_REGION,
+// ARCHIVE_S3_ENDPOINT and the standard AWS credential variables. It returns
+// nil when ARCHIVE_AFTER_DAYS is unset, which disables archiving.
+func SettingsFromEnv() (*Settings, error) {
+	value := os.Getenv("ARCHIVE_AFTER_DAYS")
+	if value == "" {
+		return nil, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return nil, fmt.Errorf("invalid ARCHIVE_AFTER_DAYS %q", value)
+	}
+
+	settings := &Settings{
+		IdleAfter: time.Duration(days) * 24 * time.Hour,
+		Interval:  defaultInterval,
+		Path:      os.Getenv("ARCHIVE_PATH"),
+		S3: backup.S3Config{
+			Bucket:       os.Getenv("ARCHIVE_S3_BUCKET"),
+			Region:       os.Getenv("ARCHIVE_S3_REGION"),
+			Endpoint:     os.Getenv("ARCHIVE_S3_ENDPOINT"),
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		Prefix: defaultPrefix,
+	}
+	if value := os.Getenv("ARCHIVE_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid ARCHIVE_INTERVAL %q", value)
+		}
+		settings.Interval = interval
+	}
+	if value, ok := os.LookupEnv("ARCHIVE_S3_PREFIX"); ok {
+		settings.Prefix = value
+	}
+	if (settings.Path == "") == (settings.S3.Bucket == "") {
+		return nil, fmt.Errorf("ARCHIVE_AFTER_DAYS requires one of ARCHIVE_PATH or ARCHIVE_S3_BUCKET")
+	}
+	return settings, nil
+}
+
+// New opens the archive the settings describe.
+func New(settings Settings) (core.Archive, error) {
+	if settings.Path != "" {
+		return NewDir(settings.Path)
+	}
+	client, err := backup.NewS3Client(settings.S3)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Archive{client: client, prefix: settings.Prefix}, nil
+}
+
+// Mover periodically archives data that has been idle for a while.
+type Mover struct {
+	store     core.ArchivingStore
+	idleAfter time.Duration
+	now       func() time.Time
+}
+
+func NewMover(store core.ArchivingStore, idleAfter time.Duration) *Mover {
+	return &Mover{store: store, idleAfter: idleAfter, now: time.Now}
+}
+
+// Run archives everything idle for longer than idleAfter and returns the
+// number of archived items.
+func (m *Mover) Run(ctx context.Context) (int, error) {
+	moved, err := m.store.ArchiveIdle(ctx, m.now().Add(-m.idleAfter))
+	if err != nil {
+		return moved, err
+	}
+	logrus.WithField("archived", moved).Info("Archiving idle data finished")
+	return moved, nil
+}
+
+// Start runs the mover every interval until stop is closed.
+func (m *Mover) Start(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := m.Run(context.Background()); err != nil {
+				logrus.WithError(err).Error("Archiving idle data failed")
+			}
+		}
+	}
+}
+
+// s3Archive keeps archived data in an S3 bucket under a prefix.
+type s3Archive struct {
+	client *backup.S3Client
+	prefix string
+}
+
+func (a *s3Archive) Put(ctx context.Context, key string, data []byte) error {
+	return a.client.Put(ctx, a.prefix+key, bytes.NewReader(data), int64(len(data)))
+}
+
+func (a *s3Archive) Get(ctx context.Context, key string) ([]byte, error) {
+	return a.client.Get(ctx, a.prefix+key)
+}
+
+func (a *s3Archive) Delete(ctx context.Context, key string) error {
+	return a.client.Delete(ctx, a.prefix+key)
+}
@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSettingsFromEnv(t *testing.T) {
+	t.Setenv("ARCHIVE_AFTER_DAYS", "")
+	if settings, err := SettingsFromEnv(); settings != nil || err != nil {
+		t.Errorf("SettingsFromEnv() = %+v, %v, want archiving disabled", settings, err)
+	}
+
+	t.Setenv("ARCHIVE_AFTER_DAYS", "90")
+	t.Setenv("ARCHIVE_PATH", "/mnt/archive")
+	settings, err := SettingsFromEnv()
+	if err != nil {
+		t.Fatalf("SettingsFromEnv() failed: %v", err)
+	}
+	if settings.IdleAfter != 90*24*time.Hour || settings.Interval != defaultInterval || settings.Path != "/mnt/archive" {
+		t.Errorf("SettingsFromEnv() = %+v", settings)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"zero days":         {"ARCHIVE_AFTER_DAYS": "0"},
+		"bad interval":      {"ARCHIVE_INTERVAL": "daily"},
+		"no destination":    {"ARCHIVE_PATH": ""},
+		"both destinations": {"ARCHIVE_S3_BUCKET": "bucket"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range env {
+				t.Setenv(key, value)
+			}
+			if _, err := SettingsFromEnv(); err == nil {
+				t.Error("SettingsFromEnv() accepted invalid settings")
+			}
+		})
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := dir.Put(ctx, "documents/abc", []byte("scene")); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	data, err := dir.Get(ctx, "documents/abc")
+	if err != nil || !bytes.Equal(data, []byte("scene")) {
+		t.Errorf("Get() = %q, %v", data, err)
+	}
+	if err := dir.Delete(ctx, "documents/abc"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := dir.Get(ctx, "documents/abc"); !os.IsNotExist(err) {
+		t.Errorf("Get() after delete error = %v", err)
+	}
+	if err := dir.Delete(ctx, "documents/abc"); err != nil {
+		t.Errorf("Delete() of a missing key failed: %v", err)
+	}
+	if err := dir.Put(ctx, "../escape", nil); err == nil {
+		t.Error("Put() accepted a key outside the directory")
+	}
+}
+
+type fakeArchivingStore struct {
+	idleSince time.Time
+}
+
+func (f *fakeArchivingStore) SetArchive(core.Archive) {}
+
+func (f *fakeArchivingStore) ArchiveIdle(_ context.Context, idleSince time.Time) (int, error) {
+	f.idleSince = idleSince
+	return 3, nil
+}
+
+func TestMoverRun(t *testing.T) {
+	store := &fakeArchivingStore{}
+	mover := NewMover(store, 30*24*time.Hour)
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	mover.now = func() time.Time { return now }
+
+	moved, err := mover.Run(context.Background())
+	if err != nil || moved != 3 {
+		t.Errorf("Run() = %d, %v", moved, err)
+	}
+	if want := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC); !store.idleSince.Equal(want) {
+		t.Errorf("idleSince = %v, want %v", store.idleSince, want)
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify_RoundTrip(t *testing.T) {
+	v := NewVerifier([]byte("secret"))
+	token, err := v.Sign(&Claims{Subject: "user-1", Name: "Ada", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	claims, err := v.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.Name != "Ada" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestVerify_WrongSecret(t *testing.T) {
+	token, err := NewVerifier([]byte("secret")).Sign(&Claims{Subject: "user-1"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	_, err = NewVerifier([]byte("other")).Verify(token)
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestVerify_Expired(t *testing.T) {
+	v := NewVerifier([]byte("secret"))
+	token, err := v.Sign(&Claims{Subject: "user-1", ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	_, err = v.Verify(token)
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestVerify_NotYetValid(t *testing.T) {
+	v := NewVerifier([]byte("secret"))
+	token, err := v.Sign(&Claims{Subject: "user-1", NotBefore: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	if _, err := v.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestVerify_MissingSubject(t *testing.T) {
+	v := NewVerifier([]byte("secret"))
+	token, err := v.Sign(&Claims{Name: "anonymous"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	if _, err := v.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestVerify_RejectsOtherAlgorithms(t *testing.T) {
+	v := NewVerifier([]byte("secret"))
+	token, err := v.Sign(&Claims{Subject: "user-1"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	if _, err := v.Verify(strings.Join(parts[:2], ".") + "."); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for alg none, got %v", err)
+	}
+}
+
+func TestVerify_Malformed(t *testing.T) {
+	v := NewVerifier([]byte("secret"))
+	for _, token := range []string{"", "abc", "a.b", "a.b.c.d", "!!.!!.!!"} {
+		if _, err := v.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify(%q): expected ErrInvalidToken, got %v", token, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"excalidraw-server/apierror"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct{}
+
+// TokenFromRequest returns the bearer token of the Authorization header.
+func TokenFromRequest(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+// Middleware verifies bearer tokens and stores their claims in the request
+// context. With required set, requests without a valid token get 401;
+// otherwise they continue anonymously.
+func Middleware(verifier *Verifier, required bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token := TokenFromRequest(r); token != "" && verifier != nil {
+				claims, err := verifier.Verify(token)
+				if err == nil {
+					next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+					return
+				}
+				if required {
+					apierror.Write(w, http.StatusUnauthorized, apierror.InvalidToken, err.Error())
+					return
+				}
+			}
+
+			if required {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				apierror.Write(w, http.StatusUnauthorized, apierror.Unauthorized, "Authentication required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithClaims returns a copy of ctx carrying claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by Middleware, or nil.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(contextKey{}).(*Claims)
+	return claims
+}
+
+// RequireToken rejects requests whose bearer token is not the given static
+// token, e.g. an operator's ADMIN_TOKEN.
+func RequireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !ValidToken(TokenFromRequest(r), token) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				apierror.Write(w, http.StatusUnauthorized, apierror.Unauthorized, "Authentication required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ValidToken reports whether given is the static token, comparing in
+// constant time.
+func ValidToken(given, token string) bool {
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	verifier := NewVerifier([]byte("secret"))
+	token, err := verifier.Sign(&Claims{Subject: "42"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		required    bool
+		header      string
+		wantStatus  int
+		wantSubject string
+	}{
+		{"required with token", true, "Bearer " + token, http.StatusOK, "42"},
+		{"required without token", true, "", http.StatusUnauthorized, ""},
+		{"required with invalid token", true, "Bearer nope", http.StatusUnauthorized, ""},
+		{"optional without token", false, "", http.StatusOK, ""},
+		{"optional with invalid token", false, "Bearer nope", http.StatusOK, ""},
+		{"optional with token", false, "Bearer " + token, http.StatusOK, "42"},
+		{"other scheme", true, "Basic abc", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject string
+			handler := Middleware(verifier, tt.required)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if claims := ClaimsFromContext(r.Context()); claims != nil {
+					subject = claims.Subject
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", subject, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{"matching token", "Bearer admin-secret", http.StatusOK},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"no token", "", http.StatusUnauthorized},
+	}
+
+	handler := RequireToken("admin-secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
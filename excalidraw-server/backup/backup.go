@@ -0,0 +1,258 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPrefix    = "excalidraw/"
+	defaultRetention = 7
+	// keyPrefix starts every archive name; the UTC timestamp that follows
+	// makes keys sort oldest to newest.
+	keyPrefix  = "excalidraw-"
+	timeLayout = "20060102T150405Z"
+)
+
+// Uploader stores backup archives. S3Client is the production implementation.
+type Uploader interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Settings configures scheduled backups.
+type Settings struct {
+	Schedule *Schedule
+	S3       S3Config
+	// Prefix is prepended to every archive key.
+	Prefix string
+	// Retention is how many archives to keep; 0 keeps them all.
+	Retention int
+}
+
+// SettingsFromEnv reads BACKUP_SCHEDULE, BACKUP_S3_BUCKET, BACKUP_S3_PREFIX,
+// BACKUP_S3_REGION, BACKUP_S3_ENDPOINT, BACKUP_RETENTION and the standard
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN. It returns
+// nil when BACKUP_SCHEDULE is unset, which disables backups.
+func SettingsFromEnv() (*Settings, error) {
+	expr := os.Getenv("BACKUP_SCHEDULE")
+	if expr == "" {
+		return nil, nil
+	}
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BACKUP_SCHEDULE: %w", err)
+	}
+
+	settings := &Settings{
+		Schedule: schedule,
+		S3: S3Config{
+			Bucket:       os.Getenv("BACKUP_S3_BUCKET"),
+			Region:       os.Getenv("BACKUP_S3_REGION"),
+			Endpoint:     os.Getenv("BACKUP_S3_ENDPOINT"),
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		Prefix:    defaultPrefix,
+		Retention: defaultRetention,
+	}
+	if value, ok := os.LookupEnv("BACKUP_S3_PREFIX"); ok {
+		settings.Prefix = value
+	}
+	if value := os.Getenv("BACKUP_RETENTION"); value != "" {
+		retention, err := strconv.Atoi(value)
+		if err != nil || retention < 0 {
+			return nil, fmt.Errorf("invalid BACKUP_RETENTION %q", value)
+		}
+		settings.Retention = retention
+	}
+	if settings.S3.Bucket == "" {
+		return nil, fmt.Errorf("BACKUP_SCHEDULE requires BACKUP_S3_BUCKET")
+	}
+	return settings, nil
+}
+
+// Status reports the outcome of scheduled backups. Times are Unix
+// milliseconds and omitted until they happen.
+type Status struct {
+	Schedule    string `json:"schedule"`
+	LastAttempt int64  `json:"last_attempt,omitempty"`
+	LastSuccess int64  `json:"last_success,omitempty"`
+	LastKey     string `json:"last_key,omitempty"`
+	LastSize    int64  `json:"last_size,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	NextRun     int64  `json:"next_run,omitempty"`
+}
+
+// Scheduler writes compressed store archives to an Uploader on a cron
+// schedule and deletes archives beyond the retention count.
+type Scheduler struct {
+	store     core.BackupWriter
+	uploader  Uploader
+	schedule  *Schedule
+	prefix    string
+	retention int
+	now       func() time.Time
+
+	mu     sync.Mutex
+	status Status
+}
+
+func NewScheduler(store core.BackupWriter, uploader Uploader, settings Settings) *Scheduler {
+	return &Scheduler{
+		store:     store,
+		uploader:  uploader,
+		schedule:  settings.Schedule,
+		prefix:    settings.Prefix,
+		retention: settings.Retention,
+		now:       time.Now,
+		status:    Status{Schedule: settings.Schedule.String()},
+	}
+}
+
+// Status returns a copy of the current backup status.
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Run takes one backup, uploads it and prunes old archives. It returns the
+// uploaded key.
+func (s *Scheduler) Run(ctx context.Context) (string, error) {
+	started := s.now()
+	s.mu.Lock()
+	s.status.LastAttempt = started.UnixMilli()
+	s.mu.Unlock()
+
+	key, size, err := s.upload(ctx, started)
+	if err == nil {
+		if pruneErr := s.prune(ctx); pruneErr != nil {
+			// The new archive is safe; a failed prune is retried next run
+			logrus.WithError(pruneErr).Warn("Failed to delete old backups")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.status.LastError = err.Error()
+		return "", err
+	}
+	s.status.LastSuccess = s.now().UnixMilli()
+	s.status.LastKey = key
+	s.status.LastSize = size
+	s.status.LastError = ""
+	return key, nil
+}
+
+func (s *Scheduler) upload(ctx context.Context, started time.Time) (string, int64, error) {
+	// Stage the archive on disk: S3 needs the length up front, and the
+	// database may be larger than we'd like to hold in memory
+	file, err := os.CreateTemp("", "excalidraw-backup-*.gz")
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	compressed := gzip.NewWriter(file)
+	if err := s.store.WriteBackup(ctx, compressed); err != nil {
+		return "", 0, fmt.Errorf("write backup: %w", err)
+	}
+	if err := compressed.Close(); err != nil {
+		return "", 0, err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+
+	key := s.prefix + keyPrefix + started.UTC().Format(timeLayout) + "." + s.store.BackupFormat() + ".gz"
+	if err := s.uploader.Put(ctx, key, file, size); err != nil {
+		return "", 0, fmt.Errorf("upload %s: %w", key, err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"key":  key,
+		"size": size,
+	}).Info("Backup uploaded")
+	return key, size, nil
+}
+
+// prune deletes the oldest archives beyond the retention count. Only keys
+// this scheduler could have written are considered, so other objects under
+// the prefix are left alone.
+func (s *Scheduler) prune(ctx context.Context) error {
+	if s.retention <= 0 {
+		return nil
+	}
+	keys, err := s.uploader.List(ctx, s.prefix+keyPrefix)
+	if err != nil {
+		return err
+	}
+
+	var archives []string
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, s.prefix)
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".gz") {
+			continue
+		}
+		archives = append(archives, key)
+	}
+	if len(archives) <= s.retention {
+		return nil
+	}
+
+	sort.Strings(archives)
+	for _, key := range archives[:len(archives)-s.retention] {
+		if err := s.uploader.Delete(ctx, key); err != nil {
+			return fmt.Errorf("delete %s: %w", key, err)
+		}
+		logrus.WithField("key", key).Info("Deleted old backup")
+	}
+	return nil
+}
+
+// Start takes a backup at every scheduled time until stop is closed.
+func (s *Scheduler) Start(stop <-chan struct{}) {
+	for {
+		next := s.schedule.Next(s.now())
+		if next.IsZero() {
+			logrus.Warn("Backup schedule never fires again")
+			return
+		}
+		s.mu.Lock()
+		s.status.NextRun = next.UnixMilli()
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			if _, err := s.Run(context.Background()); err != nil {
+				logrus.WithError(err).Error("Backup failed")
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	data string
+	err  error
+}
+
+func (s *fakeStore) BackupFormat() string {
+	return "db"
+}
+
+func (s *fakeStore) WriteBackup(ctx context.Context, w io.Writer) error {
+	if s.err != nil {
+		return s.err
+	}
+	_, err := io.WriteString(w, s.data)
+	return err
+}
+
+type memoryUploader struct {
+	objects map[string][]byte
+}
+
+func (u *memoryUploader) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return errors.New("size mismatch")
+	}
+	u.objects[key] = data
+	return nil
+}
+
+func (u *memoryUploader) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for key := range u.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (u *memoryUploader) Delete(ctx context.Context, key string) error {
+	delete(u.objects, key)
+	return nil
+}
+
+func (u *memoryUploader) keys() []string {
+	keys, _ := u.List(context.Background(), "")
+	sort.Strings(keys)
+	return keys
+}
+
+func newTestScheduler(t *testing.T, store *fakeStore, uploader *memoryUploader, retention int) (*Scheduler, *time.Time) {
+	t.Helper()
+	schedule, err := ParseSchedule("@daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := NewScheduler(store, uploader, Settings{
+		Schedule:  schedule,
+		Prefix:    "backups/",
+		Retention: retention,
+	})
+	now := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	scheduler.now = func() time.Time { return now }
+	return scheduler, &now
+}
+
+func TestRunUploadsCompressedArchive(t *testing.T) {
+	uploader := &memoryUploader{objects: make(map[string][]byte)}
+	scheduler, _ := newTestScheduler(t, &fakeStore{data: "database"}, uploader, 7)
+
+	key, err := scheduler.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "backups/excalidraw-20240315T000000Z.db.gz" {
+		t.Errorf("unexpected key %q", key)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(uploader.objects[key]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "database" {
+		t.Errorf("unexpected archive contents %q", data)
+	}
+
+	status := scheduler.Status()
+	if status.LastKey != key || status.LastSuccess == 0 || status.LastError != "" || status.Schedule != "@daily" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if status.LastSize != int64(len(uploader.objects[key])) {
+		t.Errorf("expected size %d, got %d", len(uploader.objects[key]), status.LastSize)
+	}
+}
+
+func TestRunPrunesBeyondRetention(t *testing.T) {
+	uploader := &memoryUploader{objects: map[string][]byte{
+		"backups/notes.txt": []byte("unrelated"),
+	}}
+	scheduler, now := newTestScheduler(t, &fakeStore{data: "database"}, uploader, 2)
+
+	for i := 0; i < 4; i++ {
+		if _, err := scheduler.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		*now = now.Add(24 * time.Hour)
+	}
+
+	want := []string{
+		"backups/excalidraw-20240317T000000Z.db.gz",
+		"backups/excalidraw-20240318T000000Z.db.gz",
+		"backups/notes.txt",
+	}
+	if got := uploader.keys(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRunRecordsFailure(t *testing.T) {
+	uploader := &memoryUploader{objects: make(map[string][]byte)}
+	store := &fakeStore{data: "database"}
+	scheduler, _ := newTestScheduler(t, store, uploader, 7)
+
+	if _, err := scheduler.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	success := scheduler.Status().LastSuccess
+
+	store.err = errors.New("disk on fire")
+	if _, err := scheduler.Run(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	status := scheduler.Status()
+	if !strings.Contains(status.LastError, "disk on fire") {
+		t.Errorf("expected last error to be recorded, got %q", status.LastError)
+	}
+	if status.LastSuccess != success {
+		t.Errorf("expected last success to be kept, got %d", status.LastSuccess)
+	}
+}
+
+func TestSettingsFromEnv(t *testing.T) {
+	t.Setenv("BACKUP_SCHEDULE", "")
+	settings, err := SettingsFromEnv()
+	if err != nil || settings != nil {
+		t.Fatalf("expected backups to be disabled, got %+v, %v", settings, err)
+	}
+
+	t.Setenv("BACKUP_SCHEDULE", "0 3 * * *")
+	if _, err := SettingsFromEnv(); err == nil {
+		t.Error("expected an error without a bucket")
+	}
+
+	t.Setenv("BACKUP_S3_BUCKET", "bucket")
+	t.Setenv("BACKUP_RETENTION", "30")
+	settings, err = SettingsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Prefix != defaultPrefix || settings.Retention != 30 || settings.S3.Bucket != "bucket" {
+		t.Errorf("unexpected settings %+v", settings)
+	}
+
+	t.Setenv("BACKUP_RETENTION", "-1")
+	if _, err := SettingsFromEnv(); err == nil {
+		t.Error("expected an error for a negative retention")
+	}
+}
@@ -0,0 +1,74 @@
+package challenge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CaptchaHeader carries the token produced by the captcha widget.
+const CaptchaHeader = "X-Captcha-Token"
+
+// Captcha verifies tokens against a siteverify endpoint as offered by
+// hCaptcha, reCAPTCHA and Cloudflare Turnstile.
+type Captcha struct {
+	verifyURL string
+	secret    string
+	client    *http.Client
+}
+
+func NewCaptcha(verifyURL, secret string) *Captcha {
+	return &Captcha{
+		verifyURL: verifyURL,
+		secret:    secret,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *Captcha) Describe() map[string]string {
+	return map[string]string{"X-Challenge": "captcha"}
+}
+
+func (c *Captcha) Verify(r *http.Request, _ []byte) error {
+	token := r.Header.Get(CaptchaHeader)
+	if token == "" {
+		return fmt.Errorf("%w: missing %s header", ErrChallengeFailed, CaptchaHeader)
+	}
+
+	form := url.Values{
+		"secret":   {c.secret},
+		"response": {token},
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		form.Set("remoteip", host)
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, c.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: captcha verification unavailable: %v", ErrChallengeFailed, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%w: invalid captcha verification response", ErrChallengeFailed)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: captcha rejected", ErrChallengeFailed)
+	}
+	return nil
+}
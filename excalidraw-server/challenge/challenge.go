@@ -0,0 +1,109 @@
+package challenge
+
+import (
+	"bytes"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrChallengeFailed = errors.New("challenge failed")
+
+// Challenge decides whether an anonymous request has proven it is not an
+// automated bulk upload.
+type Challenge interface {
+	Verify(r *http.Request, body []byte) error
+	// Describe returns response headers that tell clients how to solve it.
+	Describe() map[string]string
+}
+
+// GetChallenge builds the challenge configured by POST_CHALLENGE (off, pow or
+// captcha), returning nil when disabled.
+func GetChallenge() (Challenge, error) {
+	kind := os.Getenv("POST_CHALLENGE")
+	switch kind {
+	case "", "off":
+		return nil, nil
+	case "pow":
+		difficulty := defaultDifficulty
+		if value := os.Getenv("POW_DIFFICULTY"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > 64 {
+				return nil, fmt.Errorf("invalid POW_DIFFICULTY %q", value)
+			}
+			difficulty = parsed
+		}
+		logrus.WithField("difficulty", difficulty).Info("Use proof-of-work challenge")
+		return NewProofOfWork(difficulty, 5*time.Minute), nil
+	case "captcha":
+		verifyURL := os.Getenv("CAPTCHA_VERIFY_URL")
+		secret := os.Getenv("CAPTCHA_SECRET")
+		if verifyURL == "" || secret == "" {
+			return nil, fmt.Errorf("captcha challenge requires CAPTCHA_VERIFY_URL and CAPTCHA_SECRET")
+		}
+		logrus.WithField("verifyURL", verifyURL).Info("Use captcha challenge")
+		return NewCaptcha(verifyURL, secret), nil
+	default:
+		return nil, fmt.Errorf("unknown POST_CHALLENGE %q", kind)
+	}
+}
+
+// Middleware rejects anonymous requests that fail the challenge with 403
+// Forbidden. Requests with a bearer token accepted by verifier skip it. The
+// body is buffered so the challenge can bind to it and handlers can still
+// read it; bodies over maxSize bytes are refused with 413 before it is
+// checked.
+func Middleware(ch Challenge, verifier *auth.Verifier, maxSize int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isAuthenticated(r, verifier) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.TooLarge, "Body too large")
+					return
+				}
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Failed to read body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			if err := ch.Verify(r, body); err != nil {
+				logrus.WithError(err).WithField("path", r.URL.Path).Warn("Rejected request failing challenge")
+				for key, value := range ch.Describe() {
+					w.Header().Set(key, value)
+				}
+				apierror.Write(w, http.StatusForbidden, apierror.ChallengeFailed, err.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isAuthenticated(r *http.Request, verifier *auth.Verifier) bool {
+	if verifier == nil {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return false
+	}
+	_, err := verifier.Verify(token)
+	return err == nil
+}
@@ -0,0 +1,222 @@
+package challenge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"excalidraw-server/auth"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// solve brute-forces a stamp for body at the given difficulty.
+func solve(body []byte, issued time.Time, difficulty int) string {
+	bodyHash := sha256.Sum256(body)
+	prefix := hex.EncodeToString(bodyHash[:]) + ":" + strconv.FormatInt(issued.Unix(), 10) + ":"
+	for counter := 0; ; counter++ {
+		if leadingZeroBits(sha256.Sum256([]byte(prefix+strconv.Itoa(counter)))) >= difficulty {
+			return fmt.Sprintf("%d:%d", issued.Unix(), counter)
+		}
+	}
+}
+
+func newTestProofOfWork(difficulty int) (*ProofOfWork, time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	pow := NewProofOfWork(difficulty, 5*time.Minute)
+	pow.now = func() time.Time { return now }
+	return pow, now
+}
+
+func powRequest(stamp string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/post/", http.NoBody)
+	if stamp != "" {
+		req.Header.Set(ProofHeader, stamp)
+	}
+	return req
+}
+
+func TestProofOfWork_Verify(t *testing.T) {
+	pow, now := newTestProofOfWork(8)
+	body := []byte(`{"elements":[]}`)
+	stamp := solve(body, now, 8)
+
+	if err := pow.Verify(powRequest(stamp), body); err != nil {
+		t.Fatalf("Verify() with valid stamp failed: %v", err)
+	}
+	if err := pow.Verify(powRequest(stamp), body); err == nil {
+		t.Error("expected reused stamp to be rejected")
+	}
+}
+
+func TestProofOfWork_Rejects(t *testing.T) {
+	pow, now := newTestProofOfWork(8)
+	body := []byte(`{"elements":[]}`)
+
+	tests := []struct {
+		name  string
+		stamp string
+		body  []byte
+	}{
+		{"missing header", "", body},
+		{"malformed header", "nonsense", body},
+		{"invalid timestamp", "abc:1", body},
+		{"expired stamp", solve(body, now.Add(-time.Hour), 8), body},
+		{"stamp for other body", solve([]byte("other"), now, 8), body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pow.Verify(powRequest(tt.stamp), tt.body); err == nil {
+				t.Error("expected Verify() to fail")
+			}
+		})
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	var hash [sha256.Size]byte
+	if got := leadingZeroBits(hash); got != 256 {
+		t.Errorf("all-zero hash = %d, want 256", got)
+	}
+	hash[1] = 0x10
+	if got := leadingZeroBits(hash); got != 11 {
+		t.Errorf("leadingZeroBits = %d, want 11", got)
+	}
+}
+
+func TestCaptcha_Verify(t *testing.T) {
+	siteverify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() failed: %v", err)
+		}
+		if r.PostForm.Get("secret") != "shh" {
+			t.Errorf("unexpected secret %q", r.PostForm.Get("secret"))
+		}
+		success := r.PostForm.Get("response") == "good"
+		fmt.Fprintf(w, `{"success":%t}`, success)
+	}))
+	defer siteverify.Close()
+
+	captcha := NewCaptcha(siteverify.URL, "shh")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid token", "good", false},
+		{"rejected token", "bad", true},
+		{"missing token", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/post/", http.NoBody)
+			if tt.token != "" {
+				req.Header.Set(CaptchaHeader, tt.token)
+			}
+			err := captcha.Verify(req, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	pow, now := newTestProofOfWork(8)
+	verifier := auth.NewVerifier([]byte("secret"))
+	token, err := verifier.Sign(&auth.Claims{Subject: "42"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	var received string
+	handler := Middleware(pow, verifier, 64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	body := `{"elements":[]}`
+	tests := []struct {
+		name           string
+		header         string
+		value          string
+		wantStatus     int
+		wantDifficulty string
+	}{
+		{"missing proof", "", "", http.StatusForbidden, "8"},
+		{"valid proof", ProofHeader, solve([]byte(body), now, 8), http.StatusOK, ""},
+		{"authenticated", "Authorization", "Bearer " + token, http.StatusOK, ""},
+		{"invalid token", "Authorization", "Bearer nope", http.StatusForbidden, "8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = ""
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/post/", strings.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get(DifficultyHeader); got != tt.wantDifficulty {
+				t.Errorf("%s = %q, want %q", DifficultyHeader, got, tt.wantDifficulty)
+			}
+			if tt.wantStatus == http.StatusOK && received != body {
+				t.Errorf("handler received body %q, want %q", received, body)
+			}
+		})
+	}
+
+	// Anonymous bodies over the limit are refused before the challenge
+	large := strings.Repeat("x", 65)
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/post/", strings.NewReader(large))
+	req.Header.Set(ProofHeader, solve([]byte(large), now, 8))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body status = %d, want 413", rec.Code)
+	}
+}
+
+func TestGetChallenge(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantNil bool
+		wantErr bool
+	}{
+		{"disabled", map[string]string{"POST_CHALLENGE": ""}, true, false},
+		{"pow", map[string]string{"POST_CHALLENGE": "pow", "POW_DIFFICULTY": "16"}, false, false},
+		{"pow invalid difficulty", map[string]string{"POST_CHALLENGE": "pow", "POW_DIFFICULTY": "0"}, true, true},
+		{"captcha", map[string]string{"POST_CHALLENGE": "captcha", "CAPTCHA_VERIFY_URL": "http://example.com", "CAPTCHA_SECRET": "s"}, false, false},
+		{"captcha missing secret", map[string]string{"POST_CHALLENGE": "captcha", "CAPTCHA_VERIFY_URL": "http://example.com", "CAPTCHA_SECRET": ""}, true, true},
+		{"unknown", map[string]string{"POST_CHALLENGE": "riddle"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			ch, err := GetChallenge()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetChallenge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (ch == nil) != tt.wantNil {
+				t.Errorf("GetChallenge() = %v, wantNil %v", ch, tt.wantNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package challenge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDifficulty = 20
+	// ProofHeader carries the solved stamp: "<unix seconds>:<counter>".
+	ProofHeader = "X-Proof-Of-Work"
+	// DifficultyHeader advertises the required number of leading zero bits.
+	DifficultyHeader = "X-Proof-Of-Work-Difficulty"
+)
+
+// ProofOfWork is a hashcash-style challenge. Clients search for a counter
+// such that SHA-256("<hex sha256 of body>:<unix seconds>:<counter>") starts
+// with difficulty zero bits. Stamps expire after window and can't be reused.
+type ProofOfWork struct {
+	difficulty int
+	window     time.Duration
+	now        func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewProofOfWork(difficulty int, window time.Duration) *ProofOfWork {
+	return &ProofOfWork{
+		difficulty: difficulty,
+		window:     window,
+		now:        time.Now,
+		seen:       make(map[string]time.Time),
+	}
+}
+
+func (p *ProofOfWork) Describe() map[string]string {
+	return map[string]string{DifficultyHeader: strconv.Itoa(p.difficulty)}
+}
+
+func (p *ProofOfWork) Verify(r *http.Request, body []byte) error {
+	stamp := r.Header.Get(ProofHeader)
+	timestamp, counter, found := strings.Cut(stamp, ":")
+	if !found || counter == "" {
+		return fmt.Errorf("%w: missing or malformed %s header", ErrChallengeFailed, ProofHeader)
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp", ErrChallengeFailed)
+	}
+	now := p.now()
+	issued := time.Unix(seconds, 0)
+	if issued.Before(now.Add(-p.window)) || issued.After(now.Add(p.window)) {
+		return fmt.Errorf("%w: stamp expired", ErrChallengeFailed)
+	}
+
+	bodyHash := sha256.Sum256(body)
+	input := hex.EncodeToString(bodyHash[:]) + ":" + timestamp + ":" + counter
+	if leadingZeroBits(sha256.Sum256([]byte(input))) < p.difficulty {
+		return fmt.Errorf("%w: insufficient work", ErrChallengeFailed)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, expiry := range p.seen {
+		if now.After(expiry) {
+			delete(p.seen, key)
+		}
+	}
+	if _, reused := p.seen[input]; reused {
+		return fmt.Errorf("%w: stamp already used", ErrChallengeFailed)
+	}
+	p.seen[input] = issued.Add(p.window)
+
+	return nil
+}
+
+func leadingZeroBits(hash [sha256.Size]byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
@@ -0,0 +1,47 @@
+package chatfilter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Blocklist matches chat messages against regular expressions, case
+// insensitively, and redacts the matches or rejects the message.
+type Blocklist struct {
+	pattern *regexp.Regexp
+	action  Action
+}
+
+// NewBlocklist compiles patterns into one blocklist taking action on a match.
+func NewBlocklist(patterns []string, action Action) (*Blocklist, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no patterns")
+	}
+	alternatives := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		alternatives = append(alternatives, "(?:"+pattern+")")
+	}
+	return &Blocklist{
+		pattern: regexp.MustCompile("(?i)" + strings.Join(alternatives, "|")),
+		action:  action,
+	}, nil
+}
+
+func (b *Blocklist) Check(_ context.Context, message Message) (Verdict, error) {
+	if !b.pattern.MatchString(message.Content) {
+		return Verdict{Action: Allow, Content: message.Content}, nil
+	}
+	if b.action == Reject {
+		return Verdict{Action: Reject, Reason: "blocked words"}, nil
+	}
+	redacted := b.pattern.ReplaceAllStringFunc(message.Content, func(match string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(match))
+	})
+	return Verdict{Action: Redact, Content: redacted, Reason: "blocked words"}, nil
+}
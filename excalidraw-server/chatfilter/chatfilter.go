@@ -0,0 +1,144 @@
+package chatfilter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 2 * time.Second
+
+// Action is what a filter decided to do with a chat message.
+type Action string
+
+const (
+	Allow  Action = "allow"
+	Redact Action = "redact"
+	Reject Action = "reject"
+)
+
+// Message is a chat message about to be sent to a room.
+type Message struct {
+	RoomID   string `json:"roomId"`
+	SocketID string `json:"socketId"`
+	// UserID is empty for anonymous sockets
+	UserID  string `json:"userId,omitempty"`
+	Content string `json:"content"`
+}
+
+// Verdict is a filter's decision. Content is the message to send instead
+// when the action is Redact.
+type Verdict struct {
+	Action  Action
+	Content string
+	Reason  string
+}
+
+// Filter checks chat messages before they reach a room.
+type Filter interface {
+	Check(ctx context.Context, message Message) (Verdict, error)
+}
+
+// Chain runs filters in order: each sees the content redacted by the ones
+// before it, and the first rejection wins.
+type Chain []Filter
+
+func (c Chain) Check(ctx context.Context, message Message) (Verdict, error) {
+	result := Verdict{Action: Allow, Content: message.Content}
+	for _, filter := range c {
+		verdict, err := filter.Check(ctx, message)
+		if err != nil {
+			return Verdict{}, err
+		}
+		switch verdict.Action {
+		case Reject:
+			return verdict, nil
+		case Redact:
+			message.Content = verdict.Content
+			result = verdict
+		}
+	}
+	return result, nil
+}
+
+// FromEnv builds the filters configured by CHAT_BLOCKLIST_FILE (with
+// CHAT_BLOCKLIST_ACTION) and CHAT_FILTER_URL (with CHAT_FILTER_TIMEOUT and
+// CHAT_FILTER_FAIL), returning nil when neither is set.
+func FromEnv() (Filter, error) {
+	var chain Chain
+
+	if path := os.Getenv("CHAT_BLOCKLIST_FILE"); path != "" {
+		action := Action(os.Getenv("CHAT_BLOCKLIST_ACTION"))
+		switch action {
+		case "":
+			action = Redact
+		case Redact, Reject:
+		default:
+			return nil, fmt.Errorf("invalid CHAT_BLOCKLIST_ACTION %q: must be redact or reject", action)
+		}
+		patterns, err := readPatterns(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CHAT_BLOCKLIST_FILE: %w", err)
+		}
+		blocklist, err := NewBlocklist(patterns, action)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CHAT_BLOCKLIST_FILE: %w", err)
+		}
+		chain = append(chain, blocklist)
+	}
+
+	if endpoint := os.Getenv("CHAT_FILTER_URL"); endpoint != "" {
+		timeout := defaultTimeout
+		if value := os.Getenv("CHAT_FILTER_TIMEOUT"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid CHAT_FILTER_TIMEOUT %q", value)
+			}
+			timeout = parsed
+		}
+		failClosed := false
+		switch value := os.Getenv("CHAT_FILTER_FAIL"); value {
+		case "", "open":
+		case "closed":
+			failClosed = true
+		default:
+			return nil, fmt.Errorf("invalid CHAT_FILTER_FAIL %q: must be open or closed", value)
+		}
+		remote, err := NewRemote(endpoint, timeout, failClosed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CHAT_FILTER_URL: %w", err)
+		}
+		chain = append(chain, remote)
+	}
+
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// readPatterns reads one pattern per line, skipping blank lines and lines
+// starting with #.
+func readPatterns(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
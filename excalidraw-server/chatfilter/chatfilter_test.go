@@ -0,0 +1,103 @@
+package chatfilter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type staticFilter Verdict
+
+func (f staticFilter) Check(_ context.Context, message Message) (Verdict, error) {
+	verdict := Verdict(f)
+	if verdict.Action == Allow {
+		verdict.Content = message.Content
+	}
+	return verdict, nil
+}
+
+func TestBlocklist(t *testing.T) {
+	redact, err := NewBlocklist([]string{`\bdarn\b`, `héck+`}, Redact)
+	if err != nil {
+		t.Fatalf("NewBlocklist() error = %v", err)
+	}
+	ctx := context.Background()
+
+	verdict, _ := redact.Check(ctx, Message{Content: "Darn, what the HÉCKK"})
+	if verdict.Action != Redact || verdict.Content != "****, what the *****" {
+		t.Errorf("Check() = %+v, want both matches redacted", verdict)
+	}
+	verdict, _ = redact.Check(ctx, Message{Content: "darning socks"})
+	if verdict.Action != Allow || verdict.Content != "darning socks" {
+		t.Errorf("Check() of a clean message = %+v", verdict)
+	}
+
+	reject, _ := NewBlocklist([]string{"darn"}, Reject)
+	if verdict, _ := reject.Check(ctx, Message{Content: "darn"}); verdict.Action != Reject {
+		t.Errorf("Check() with reject = %+v", verdict)
+	}
+
+	if _, err := NewBlocklist([]string{"(unclosed"}, Redact); err == nil {
+		t.Error("NewBlocklist() accepted an invalid pattern")
+	}
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	redact, _ := NewBlocklist([]string{"darn"}, Redact)
+
+	verdict, err := Chain{redact, staticFilter{Action: Allow}}.Check(ctx, Message{Content: "darn it"})
+	if err != nil || verdict.Action != Redact || verdict.Content != "**** it" {
+		t.Errorf("Chain.Check() = %+v, %v, want the redaction kept", verdict, err)
+	}
+
+	verdict, _ = Chain{redact, staticFilter{Action: Reject, Reason: "spam"}}.Check(ctx, Message{Content: "darn it"})
+	if verdict.Action != Reject || verdict.Reason != "spam" {
+		t.Errorf("Chain.Check() = %+v, want the rejection", verdict)
+	}
+
+	verdict, _ = Chain{}.Check(ctx, Message{Content: "hello"})
+	if verdict.Action != Allow || verdict.Content != "hello" {
+		t.Errorf("empty Chain.Check() = %+v", verdict)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# words\n\ndarn\n  heck  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CHAT_BLOCKLIST_FILE", "")
+	t.Setenv("CHAT_FILTER_URL", "")
+	if filter, err := FromEnv(); err != nil || filter != nil {
+		t.Errorf("FromEnv() without settings = %v, %v, want nil", filter, err)
+	}
+
+	t.Setenv("CHAT_BLOCKLIST_FILE", path)
+	t.Setenv("CHAT_BLOCKLIST_ACTION", "reject")
+	filter, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv() error = %v", err)
+	}
+	if verdict, _ := filter.Check(context.Background(), Message{Content: "oh heck"}); verdict.Action != Reject {
+		t.Errorf("Check() = %+v, want the trimmed pattern to match", verdict)
+	}
+
+	for key, value := range map[string]string{
+		"CHAT_BLOCKLIST_ACTION": "delete",
+		"CHAT_BLOCKLIST_FILE":   filepath.Join(t.TempDir(), "missing.txt"),
+		"CHAT_FILTER_URL":       "ftp://moderation.example",
+		"CHAT_FILTER_TIMEOUT":   "soon",
+		"CHAT_FILTER_FAIL":      "maybe",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv("CHAT_FILTER_URL", "http://moderation.example")
+			t.Setenv(key, value)
+			if _, err := FromEnv(); err == nil {
+				t.Errorf("FromEnv() accepted %s=%q", key, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package chatfilter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxResponseSize bounds what is read of a moderation endpoint's response.
+const maxResponseSize = 1 << 20
+
+// Remote asks an external moderation endpoint about every message: it POSTs
+// the Message as JSON and expects { action, content?, reason? } back, with
+// action allow, redact (sending content instead) or reject.
+type Remote struct {
+	endpoint   string
+	client     *http.Client
+	failClosed bool
+}
+
+// NewRemote returns a filter calling endpoint. When the endpoint fails or
+// doesn't answer within timeout, messages are let through, or rejected with
+// failClosed.
+func NewRemote(endpoint string, timeout time.Duration, failClosed bool) (*Remote, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("%q is not an http or https URL", endpoint)
+	}
+	return &Remote{
+		endpoint:   endpoint,
+		client:     &http.Client{Timeout: timeout},
+		failClosed: failClosed,
+	}, nil
+}
+
+func (r *Remote) Check(ctx context.Context, message Message) (Verdict, error) {
+	verdict, err := r.ask(ctx, message)
+	if err == nil {
+		return verdict, nil
+	}
+	logrus.WithError(err).WithField("room_id", message.RoomID).Warn("Chat filter endpoint failed")
+	if r.failClosed {
+		return Verdict{Action: Reject, Reason: "content filter unavailable"}, nil
+	}
+	return Verdict{Action: Allow, Content: message.Content}, nil
+}
+
+func (r *Remote) ask(ctx context.Context, message Message) (Verdict, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return Verdict{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var result struct {
+		Action  Action  `json:"action"`
+		Content *string `json:"content"`
+		Reason  string  `json:"reason"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		return Verdict{}, fmt.Errorf("invalid response: %w", err)
+	}
+	switch result.Action {
+	case Allow:
+		return Verdict{Action: Allow, Content: message.Content}, nil
+	case Reject:
+		return Verdict{Action: Reject, Reason: result.Reason}, nil
+	case Redact:
+		if result.Content == nil {
+			return Verdict{}, fmt.Errorf("invalid response: redact without content")
+		}
+		return Verdict{Action: Redact, Content: *result.Content, Reason: result.Reason}, nil
+	default:
+		return Verdict{}, fmt.Errorf("invalid response: unknown action %q", result.Action)
+	}
+}
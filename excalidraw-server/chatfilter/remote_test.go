@@ -0,0 +1,70 @@
+package chatfilter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRemote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message Message
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch message.Content {
+		case "spam":
+			_, _ = w.Write([]byte(`{"action":"reject","reason":"spam"}`))
+		case "rude":
+			_, _ = w.Write([]byte(`{"action":"redact","content":"[removed]","reason":"toxicity"}`))
+		case "broken":
+			_, _ = w.Write([]byte(`{"action":"explode"}`))
+		default:
+			_, _ = w.Write([]byte(`{"action":"allow"}`))
+		}
+	}))
+	defer server.Close()
+
+	remote, err := NewRemote(server.URL, time.Second, false)
+	if err != nil {
+		t.Fatalf("NewRemote() error = %v", err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		content string
+		want    Verdict
+	}{
+		{"hello", Verdict{Action: Allow, Content: "hello"}},
+		{"spam", Verdict{Action: Reject, Reason: "spam"}},
+		{"rude", Verdict{Action: Redact, Content: "[removed]", Reason: "toxicity"}},
+		{"broken", Verdict{Action: Allow, Content: "broken"}},
+	}
+	for _, tt := range tests {
+		verdict, err := remote.Check(ctx, Message{RoomID: "room", Content: tt.content})
+		if err != nil || verdict != tt.want {
+			t.Errorf("Check(%q) = %+v, %v, want %+v", tt.content, verdict, err, tt.want)
+		}
+	}
+
+	closed, _ := NewRemote(server.URL, time.Second, true)
+	if verdict, _ := closed.Check(ctx, Message{Content: "broken"}); verdict.Action != Reject {
+		t.Errorf("Check() failing closed = %+v, want a rejection", verdict)
+	}
+}
+
+func TestRemoteUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	open, _ := NewRemote(server.URL, time.Second, false)
+	if verdict, _ := open.Check(context.Background(), Message{Content: "hi"}); verdict.Action != Allow || verdict.Content != "hi" {
+		t.Errorf("Check() failing open = %+v", verdict)
+	}
+}
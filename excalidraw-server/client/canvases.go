@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type (
+	// Canvas is an entry of the caller's canvases, the per-user saves that
+	// need JWT_SECRET on the server and a token on the client.
+	Canvas struct {
+		Key          string   `json:"key"`
+		Name         string   `json:"name,omitempty"`
+		Tags         []string `json:"tags,omitempty"`
+		Size         int      `json:"size"`
+		CreatedAt    int64    `json:"created_at"`
+		UpdatedAt    int64    `json:"updated_at"`
+		Views        int64    `json:"views"`
+		LastAccessed int64    `json:"last_accessed,omitempty"`
+	}
+
+	// CanvasList picks the order of ListCanvases: Sort is "updatedAt", the
+	// default, or "name".
+	CanvasList struct {
+		Sort      string
+		Ascending bool
+	}
+)
+
+// ListCanvases lists all of the caller's canvases, newest first unless
+// opts says otherwise.
+func (c *Client) ListCanvases(ctx context.Context, opts CanvasList) ([]Canvas, error) {
+	query := url.Values{"limit": {"1000"}}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Ascending {
+		query.Set("order", "asc")
+	}
+
+	canvases := []Canvas{}
+	for {
+		var page struct {
+			Items      []Canvas `json:"items"`
+			NextCursor string   `json:"next_cursor"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/api/v3/kv/?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		canvases = append(canvases, page.Items...)
+		if page.NextCursor == "" {
+			return canvases, nil
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+}
+
+// GetCanvas returns a canvas's data as it was saved.
+func (c *Client) GetCanvas(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/v3/kv/"+url.PathEscape(key), c.token, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// PutCanvas creates or replaces a canvas; its name and tags stay as they
+// are.
+func (c *Client) PutCanvas(ctx context.Context, key string, data []byte) error {
+	resp, err := c.send(ctx, http.MethodPut, "/api/v3/kv/"+url.PathEscape(key), c.token, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
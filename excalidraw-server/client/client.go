@@ -0,0 +1,199 @@
+// Package client talks to an excalidraw-server from Go, for bots and scripts
+// such as agents that document a room or lint its diagrams. A Client calls
+// the REST API and joins rooms over the native WebSocket endpoint, where a
+// Room sends and receives scene broadcasts alongside the browser clients.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client is a connection to one server. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	adminToken string
+	http       *http.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithToken authenticates requests and room connections with a JWT, as
+// the server's JWT_SECRET signs them.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithAdminToken authenticates the admin endpoints, such as RoomStats,
+// with the server's ADMIN_TOKEN.
+func WithAdminToken(token string) Option {
+	return func(c *Client) { c.adminToken = token }
+}
+
+// WithHTTPClient sends REST requests through hc instead of
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// New returns a client of the server at serverURL, e.g.
+// https://draw.example.com or http://localhost:3002/excalidraw behind a
+// BASE_PATH.
+func New(serverURL string, opts ...Option) (*Client, error) {
+	baseURL, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server URL %q: must be http or https", serverURL)
+	}
+	baseURL.Path = strings.TrimSuffix(baseURL.Path, "/")
+
+	c := &Client{baseURL: baseURL, http: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Error is a failed request or room action, with the server's code.
+// StatusCode is zero for errors answered over a room connection.
+type Error struct {
+	StatusCode int
+	Code       apierror.Code
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s (%s)", e.Message, e.Code)
+	}
+	return fmt.Sprintf("%d %s (%s)", e.StatusCode, e.Message, e.Code)
+}
+
+type (
+	// NewSnapshot is a named version of a room's scene to save.
+	NewSnapshot struct {
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+		CreatedBy   string `json:"created_by,omitempty"`
+		// Data is the scene, as Excalidraw exports it.
+		Data json.RawMessage `json:"data"`
+	}
+
+	// Snapshot is a saved version of a room's scene. Lists leave Data out.
+	Snapshot struct {
+		ID           string `json:"id"`
+		RoomID       string `json:"room_id"`
+		Name         string `json:"name"`
+		Description  string `json:"description"`
+		CreatedBy    string `json:"created_by"`
+		CreatedAt    int64  `json:"created_at"`
+		ThumbnailURL string `json:"thumbnail_url"`
+		Data         []byte `json:"data,omitempty"`
+	}
+)
+
+// CreateSnapshot saves a snapshot of a room and returns its id. Encrypted
+// rooms can't have snapshots.
+func (c *Client) CreateSnapshot(ctx context.Context, roomID string, snapshot NewSnapshot) (string, error) {
+	// The API takes the scene as a string
+	body := struct {
+		NewSnapshot
+		Data string `json:"data"`
+	}{NewSnapshot: snapshot, Data: string(snapshot.Data)}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/rooms/"+url.PathEscape(roomID)+"/snapshots", body, &created)
+	return created.ID, err
+}
+
+// ListSnapshots lists a room's snapshots, newest first.
+func (c *Client) ListSnapshots(ctx context.Context, roomID string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := c.do(ctx, http.MethodGet, "/api/rooms/"+url.PathEscape(roomID)+"/snapshots", nil, &snapshots)
+	return snapshots, err
+}
+
+// GetSnapshot returns a snapshot with its scene.
+func (c *Client) GetSnapshot(ctx context.Context, snapshotID string) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := c.do(ctx, http.MethodGet, "/api/snapshots/"+url.PathEscape(snapshotID), nil, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// do sends a JSON request to path and decodes the response into out.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body, contentType = bytes.NewReader(encoded), "application/json"
+	}
+	resp, err := c.send(ctx, method, path, c.token, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send sends a request to path, authenticated with token when it is set,
+// and returns the response unless it is an error.
+func (c *Client) send(ctx context.Context, method, path, token, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// responseError reads an error response: a v3 envelope, or plain text with
+// the code in the X-Error-Code header.
+func responseError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var envelope apierror.Envelope
+	if json.Unmarshal(message, &envelope) == nil && envelope.Error.Code != "" {
+		return &Error{StatusCode: resp.StatusCode, Code: envelope.Error.Code, Message: envelope.Error.Message}
+	}
+
+	code := apierror.Code(resp.Header.Get(apierror.CodeHeader))
+	if code == "" {
+		code = apierror.ForStatus(resp.StatusCode)
+	}
+	return &Error{StatusCode: resp.StatusCode, Code: code, Message: strings.TrimSpace(string(message))}
+}
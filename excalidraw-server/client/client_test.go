@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/handlers/api/snapshots"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/stores/sqlite"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func newTestServer(t *testing.T) *Client {
+	t.Helper()
+	store := sqlite.NewDocumentStore(filepath.Join(t.TempDir(), "test.db")).(snapshots.SnapshotStore)
+	r := chi.NewRouter()
+	r.Post("/api/rooms/{roomId}/snapshots", snapshots.HandleCreateSnapshot(store))
+	r.Get("/api/rooms/{roomId}/snapshots", snapshots.HandleListSnapshots(store))
+	r.Get("/api/snapshots/{snapshotId}", snapshots.HandleGetSnapshot(store))
+	r.Get("/ws/rooms/{roomId}", websocket.HandleRawSocket(socketio.NewServer(nil, nil), websocket.AuthOptions{Mode: websocket.AuthOff}))
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	c, err := New(server.URL + "/")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	return c
+}
+
+// nextEvent waits for an event of the given type.
+func nextEvent(t *testing.T, room *Room, eventType string) Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-room.Events():
+			if !ok {
+				t.Fatalf("events closed waiting for %s", eventType)
+			}
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", eventType)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	id, err := c.CreateSnapshot(ctx, "room-1", NewSnapshot{Name: "v1", CreatedBy: "bot", Data: json.RawMessage(`{"elements":[]}`)})
+	if err != nil || id == "" {
+		t.Fatalf("CreateSnapshot() = %q, %v", id, err)
+	}
+	list, err := c.ListSnapshots(ctx, "room-1")
+	if err != nil || len(list) != 1 || list[0].ID != id || list[0].Name != "v1" || list[0].Data != nil {
+		t.Fatalf("ListSnapshots() = %+v, %v", list, err)
+	}
+	snapshot, err := c.GetSnapshot(ctx, id)
+	if err != nil || string(snapshot.Data) != `{"elements":[]}` || snapshot.CreatedBy != "bot" {
+		t.Fatalf("GetSnapshot() = %+v, %v", snapshot, err)
+	}
+
+	_, err = c.GetSnapshot(ctx, "missing")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 || apiErr.Code != apierror.SnapshotNotFound {
+		t.Errorf("GetSnapshot() of a missing snapshot = %v, want a 404 snapshot_not_found", err)
+	}
+}
+
+func TestRoom(t *testing.T) {
+	c := newTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	linter, err := c.Join(ctx, "sdk-room")
+	if err != nil {
+		t.Fatalf("Join() failed: %v", err)
+	}
+	defer linter.Close()
+	if linter.SocketID == "" || linter.Mode != "plain" {
+		t.Errorf("joined as %q in a %q room", linter.SocketID, linter.Mode)
+	}
+	writer, err := c.Join(ctx, "sdk-room")
+	if err != nil {
+		t.Fatalf("Join() failed: %v", err)
+	}
+
+	seq, err := writer.SendElements(ctx, []map[string]any{{"id": "rect", "type": "rectangle", "version": 1}})
+	if err != nil || seq != 1 {
+		t.Fatalf("SendElements() = %d, %v, want seq 1", seq, err)
+	}
+	broadcast, err := nextEvent(t, linter, "client-broadcast").Broadcast()
+	if err != nil || broadcast.Seq != 1 {
+		t.Fatalf("Broadcast() = %+v, %v", broadcast, err)
+	}
+	elements, err := broadcast.Elements()
+	if err != nil || len(elements) != 1 || elements[0]["id"] != "rect" {
+		t.Errorf("Elements() = %v, %v", elements, err)
+	}
+
+	_, err = writer.Broadcast(ctx, map[string]any{}, "not metadata")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != apierror.InvalidPayload {
+		t.Errorf("Broadcast() with bad metadata = %v, want invalid_payload", err)
+	}
+
+	_ = writer.Close()
+	for range writer.Events() {
+	}
+	if _, err := writer.Broadcast(ctx, map[string]any{}, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Broadcast() after Close() = %v, want ErrClosed", err)
+	}
+}
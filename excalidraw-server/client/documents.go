@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Share is a document saved for sharing.
+type Share struct {
+	ID string `json:"id"`
+	// URL is the link preview page of the document, which unfurls into a
+	// thumbnail in chat apps.
+	URL string `json:"url"`
+}
+
+// ShareDocument saves a scene as a shared document. Servers with
+// POST_CHALLENGE set refuse it.
+func (c *Client) ShareDocument(ctx context.Context, scene json.RawMessage) (*Share, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v3/post/", scene, &created); err != nil {
+		return nil, err
+	}
+	return &Share{ID: created.ID, URL: c.baseURL.String() + "/share/" + url.PathEscape(created.ID)}, nil
+}
+
+// GetDocument returns a shared document as it was saved.
+func (c *Client) GetDocument(ctx context.Context, id string) ([]byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/v3/"+url.PathEscape(id)+"/", c.token, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
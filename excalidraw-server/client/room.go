@@ -0,0 +1,282 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventBuffer is how many events a Room holds for a slow reader before it
+// stops reading from the server.
+const eventBuffer = 256
+
+// ErrClosed is returned by the methods of a Room whose connection ended.
+var ErrClosed = errors.New("room connection closed")
+
+// Event is a frame the server sent to a room connection: room-presence,
+// client-broadcast, scene-init, chat-history, client-chat-message and the
+// other events of the native WebSocket protocol.
+type Event struct {
+	Type string
+	Data json.RawMessage
+}
+
+// Broadcast is the data of client-broadcast and scene-init events.
+// Payloads of encrypted rooms are base64 ciphertext.
+type Broadcast struct {
+	Payload  json.RawMessage `json:"payload"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	Seq      int64           `json:"seq,omitempty"`
+}
+
+// Broadcast decodes the data of a client-broadcast or scene-init event.
+func (e Event) Broadcast() (Broadcast, error) {
+	var broadcast Broadcast
+	if e.Type != "client-broadcast" && e.Type != "scene-init" {
+		return broadcast, fmt.Errorf("%s is not a broadcast", e.Type)
+	}
+	err := json.Unmarshal(e.Data, &broadcast)
+	return broadcast, err
+}
+
+// Elements returns the elements of an Excalidraw SCENE_INIT or
+// SCENE_UPDATE payload.
+func (b Broadcast) Elements() ([]map[string]any, error) {
+	var scene struct {
+		Type    string `json:"type"`
+		Payload struct {
+			Elements []map[string]any `json:"elements"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(b.Payload, &scene); err != nil {
+		return nil, fmt.Errorf("payload is not a scene: %w", err)
+	}
+	if scene.Type != "SCENE_INIT" && scene.Type != "SCENE_UPDATE" {
+		return nil, fmt.Errorf("payload is a %q, not a scene", scene.Type)
+	}
+	return scene.Payload.Elements, nil
+}
+
+type frame struct {
+	Type string          `json:"type"`
+	ID   int64           `json:"id,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type ack struct {
+	Status  string        `json:"status"`
+	Code    apierror.Code `json:"code"`
+	Message string        `json:"error"`
+	Seq     int64         `json:"seq"`
+}
+
+// Room is a connection to a room on the native WebSocket endpoint. The
+// server counts it as a member until Close.
+type Room struct {
+	// ID is the room's id, SocketID the id the room's presence lists the
+	// connection under, and Mode "plain" or "encrypted".
+	ID       string
+	SocketID string
+	Mode     string
+
+	conn    *websocket.Conn
+	events  chan Event
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan ack
+	err     error
+	done    chan struct{}
+}
+
+// Join connects to a room and waits until the server has joined it.
+// Events must be read, or the server disconnects the room once it falls
+// too far behind.
+func (c *Client) Join(ctx context.Context, roomID string) (*Room, error) {
+	endpoint := *c.baseURL
+	endpoint.Scheme = strings.Replace(endpoint.Scheme, "http", "ws", 1)
+	endpoint.Path += "/ws/rooms/" + url.PathEscape(roomID)
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, endpoint.String(), header)
+	if err != nil {
+		if resp != nil && resp.StatusCode >= http.StatusBadRequest {
+			return nil, responseError(resp)
+		}
+		return nil, err
+	}
+
+	var joined struct {
+		Type string `json:"type"`
+		Data struct {
+			SocketID string `json:"socketId"`
+			Mode     string `json:"mode"`
+		} `json:"data"`
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(deadline)
+	}
+	if err := conn.ReadJSON(&joined); err != nil || joined.Type != "joined" {
+		_ = conn.Close()
+		if err == nil {
+			err = fmt.Errorf("expected joined, got %s", joined.Type)
+		}
+		return nil, fmt.Errorf("failed to join room: %w", err)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	room := &Room{
+		ID:       roomID,
+		SocketID: joined.Data.SocketID,
+		Mode:     joined.Data.Mode,
+		conn:     conn,
+		events:   make(chan Event, eventBuffer),
+		pending:  make(map[int64]chan ack),
+		done:     make(chan struct{}),
+	}
+	go room.readLoop()
+	return room, nil
+}
+
+// Events returns the room's events, which is closed when the connection
+// ends.
+func (r *Room) Events() <-chan Event {
+	return r.events
+}
+
+// Broadcast sends a scene update to the rest of the room and returns the
+// sequence number the room gave it. Broadcasts with metadata {full: true},
+// or SCENE_INIT payloads, become the scene later joiners start from.
+func (r *Room) Broadcast(ctx context.Context, payload, metadata any) (int64, error) {
+	id, answer, err := r.expectAck()
+	if err != nil {
+		return 0, err
+	}
+	defer r.forgetAck(id)
+	if err := r.send("server-broadcast", id, payload, metadata); err != nil {
+		return 0, err
+	}
+
+	select {
+	case result := <-answer:
+		if result.Status != "ok" {
+			return 0, &Error{Code: result.Code, Message: result.Message}
+		}
+		return result.Seq, nil
+	case <-r.done:
+		return 0, r.closeErr()
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// BroadcastVolatile sends an update, such as a pointer position, that may
+// be dropped on the way. It doesn't wait for the server.
+func (r *Room) BroadcastVolatile(payload, metadata any) error {
+	return r.send("server-volatile-broadcast", 0, payload, metadata)
+}
+
+// SendElements broadcasts elements as an Excalidraw SCENE_UPDATE, which
+// browser clients merge into their scene by element version.
+func (r *Room) SendElements(ctx context.Context, elements []map[string]any) (int64, error) {
+	return r.Broadcast(ctx, map[string]any{
+		"type":    "SCENE_UPDATE",
+		"payload": map[string]any{"elements": elements},
+	}, nil)
+}
+
+// Close leaves the room.
+func (r *Room) Close() error {
+	r.writeMu.Lock()
+	_ = r.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	r.writeMu.Unlock()
+	return r.conn.Close()
+}
+
+func (r *Room) send(frameType string, id int64, payload, metadata any) error {
+	data, err := json.Marshal(map[string]any{"payload": payload, "metadata": metadata})
+	if err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if err := r.conn.WriteJSON(frame{Type: frameType, ID: id, Data: data}); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+// expectAck reserves a frame id and the channel its broadcast-ack arrives
+// on.
+func (r *Room) expectAck() (int64, chan ack, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return 0, nil, r.err
+	}
+	r.nextID++
+	answer := make(chan ack, 1)
+	r.pending[r.nextID] = answer
+	return r.nextID, answer, nil
+}
+
+func (r *Room) forgetAck(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, id)
+}
+
+func (r *Room) closeErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// readLoop hands acks to the broadcasts waiting for them and everything
+// else to Events, until the connection ends.
+func (r *Room) readLoop() {
+	var err error
+	defer func() {
+		r.mu.Lock()
+		r.err = ErrClosed
+		if err != nil && !errors.Is(err, net.ErrClosed) && !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			r.err = fmt.Errorf("%w: %v", ErrClosed, err)
+		}
+		r.mu.Unlock()
+		close(r.done)
+		close(r.events)
+	}()
+
+	for {
+		var received frame
+		if err = r.conn.ReadJSON(&received); err != nil {
+			return
+		}
+		if received.Type == "broadcast-ack" && received.ID != 0 {
+			var result ack
+			_ = json.Unmarshal(received.Data, &result)
+			r.mu.Lock()
+			answer := r.pending[received.ID]
+			r.mu.Unlock()
+			if answer != nil {
+				answer <- result
+			}
+			continue
+		}
+		r.events <- Event{Type: received.Type, Data: received.Data}
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+type (
+	// RoomStats describes the members and traffic of a live room.
+	RoomStats struct {
+		RoomID         string       `json:"room_id"`
+		Mode           string       `json:"mode"`
+		Users          []RoomMember `json:"users"`
+		Messages       int64        `json:"messages"`
+		BytesBroadcast int64        `json:"bytes_broadcast"`
+		// MessagesPerMinute counts broadcasts over the last minute.
+		MessagesPerMinute int64 `json:"messages_per_minute"`
+	}
+
+	// RoomMember is a connection in a room, with the identity of its token.
+	RoomMember struct {
+		SocketID string `json:"socket_id"`
+		User     *User  `json:"user,omitempty"`
+		JoinedAt int64  `json:"joined_at"`
+	}
+
+	// User is the identity a room member connected with.
+	User struct {
+		ID        string `json:"id"`
+		Name      string `json:"name,omitempty"`
+		AvatarURL string `json:"avatarUrl,omitempty"`
+	}
+)
+
+// ListRooms returns the member count of each listed live room; private and
+// link-only rooms are left out.
+func (c *Client) ListRooms(ctx context.Context) (map[string]int, error) {
+	rooms := map[string]int{}
+	err := c.do(ctx, http.MethodGet, "/api/rooms", nil, &rooms)
+	return rooms, err
+}
+
+// RoomStats returns the members and traffic of a live room. It needs
+// WithAdminToken.
+func (c *Client) RoomStats(ctx context.Context, roomID string) (*RoomStats, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/rooms/"+url.PathEscape(roomID), c.adminToken, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var stats RoomStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
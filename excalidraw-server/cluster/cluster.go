@@ -0,0 +1,72 @@
+// Package cluster connects server instances through an event bus, so rooms
+// can span instances and external services can follow room activity.
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	defaultNATSURL = "nats://127.0.0.1:4222"
+	defaultPrefix  = "excalidraw"
+)
+
+// Transport publishes and receives messages on subjects of an event bus.
+type Transport interface {
+	Publish(subject string, data []byte) error
+	// Subscribe calls handler for every message published on subject,
+	// including by this instance, in the order they arrive.
+	Subscribe(subject string, handler func(data []byte)) error
+	Close() error
+}
+
+// Settings selects the cluster transport.
+type Settings struct {
+	// Transport is "nats"; empty disables cluster mode.
+	Transport string
+	NATSURL   string
+	// Prefix starts every subject, so clusters can share a bus.
+	Prefix string
+}
+
+// SettingsFromEnv reads CLUSTER_TRANSPORT, NATS_URL and
+// CLUSTER_SUBJECT_PREFIX.
+func SettingsFromEnv() (Settings, error) {
+	settings := Settings{
+		Transport: strings.ToLower(strings.TrimSpace(os.Getenv("CLUSTER_TRANSPORT"))),
+		NATSURL:   defaultNATSURL,
+		Prefix:    defaultPrefix,
+	}
+	if value := os.Getenv("NATS_URL"); value != "" {
+		settings.NATSURL = value
+	}
+	if value := os.Getenv("CLUSTER_SUBJECT_PREFIX"); value != "" {
+		if strings.ContainsAny(value, " \t\r\n*>") {
+			return Settings{}, fmt.Errorf("invalid CLUSTER_SUBJECT_PREFIX %q: must not contain whitespace or wildcards", value)
+		}
+		settings.Prefix = value
+	}
+
+	switch settings.Transport {
+	case "", "off", "none":
+		settings.Transport = ""
+	case "nats":
+	case "kafka":
+		return Settings{}, fmt.Errorf("CLUSTER_TRANSPORT=kafka is not supported yet, use nats")
+	default:
+		return Settings{}, fmt.Errorf("unknown CLUSTER_TRANSPORT %q", settings.Transport)
+	}
+	return settings, nil
+}
+
+// Dial connects to the transport settings select.
+func Dial(settings Settings) (Transport, error) {
+	switch settings.Transport {
+	case "nats":
+		return DialNATS(settings.NATSURL)
+	default:
+		return nil, fmt.Errorf("unknown cluster transport %q", settings.Transport)
+	}
+}
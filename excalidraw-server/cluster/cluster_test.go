@@ -0,0 +1,33 @@
+package cluster
+
+import "testing"
+
+func TestSettingsFromEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport string
+		prefix    string
+		want      Settings
+		wantErr   bool
+	}{
+		{name: "off", want: Settings{NATSURL: defaultNATSURL, Prefix: defaultPrefix}},
+		{name: "nats", transport: "NATS", prefix: "draw", want: Settings{Transport: "nats", NATSURL: defaultNATSURL, Prefix: "draw"}},
+		{name: "kafka", transport: "kafka", wantErr: true},
+		{name: "unknown", transport: "carrier-pigeon", wantErr: true},
+		{name: "wildcard prefix", transport: "nats", prefix: "draw.>", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CLUSTER_TRANSPORT", tt.transport)
+			t.Setenv("CLUSTER_SUBJECT_PREFIX", tt.prefix)
+			t.Setenv("NATS_URL", "")
+			got, err := SettingsFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SettingsFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("SettingsFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	natsDialTimeout = 5 * time.Second
+	// natsMaxBackoff caps the wait between reconnection attempts.
+	natsMaxBackoff = 30 * time.Second
+)
+
+// ErrNotConnected is returned by Publish while the NATS connection is down.
+var ErrNotConnected = errors.New("not connected to NATS")
+
+// NATS is a Transport over a NATS server. It reconnects with backoff when the
+// connection drops and subscribes again.
+type NATS struct {
+	conn *nats.Conn
+}
+
+// DialNATS connects to the NATS server at rawURL, nats://[user:pass@]host[:port]
+// or nats://token@host[:port].
+func DialNATS(rawURL string) (*NATS, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "nats" {
+		return nil, fmt.Errorf("unsupported NATS URL scheme %q", parsed.Scheme)
+	}
+
+	conn, err := nats.Connect(rawURL,
+		nats.Name("excalidraw-server"),
+		nats.Timeout(natsDialTimeout),
+		nats.MaxReconnects(-1),
+		nats.CustomReconnectDelay(natsBackoff),
+		// Nothing is buffered while NATS is unreachable
+		nats.ReconnectBufSize(-1),
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			if !conn.IsClosed() {
+				logrus.WithError(err).Warn("Lost the NATS connection, reconnecting")
+			}
+		}),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			logrus.Info("Reconnected to NATS")
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			logrus.WithError(err).Warn("NATS reported an error")
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &NATS{conn: conn}, nil
+}
+
+// natsBackoff doubles the wait after every failed reconnection attempt.
+func natsBackoff(attempts int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < attempts && backoff < natsMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > natsMaxBackoff {
+		backoff = natsMaxBackoff
+	}
+	return backoff
+}
+
+func (n *NATS) Publish(subject string, data []byte) error {
+	if !n.conn.IsConnected() {
+		return ErrNotConnected
+	}
+	err := n.conn.Publish(subject, data)
+	if errors.Is(err, nats.ErrReconnectBufExceeded) {
+		return ErrNotConnected
+	}
+	return err
+}
+
+func (n *NATS) Subscribe(subject string, handler func(data []byte)) error {
+	_, err := n.conn.Subscribe(subject, func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+	return err
+}
+
+func (n *NATS) Close() error {
+	n.conn.Close()
+	return nil
+}
@@ -0,0 +1,194 @@
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNATS is a NATS server for one subject space, enough of the protocol
+// for the client.
+type fakeNATS struct {
+	listener   net.Listener
+	maxPayload int
+
+	mu    sync.Mutex
+	conns []net.Conn
+	// subs maps a subject to the connections and sids subscribed to it
+	subs map[string]map[net.Conn]string
+}
+
+func newFakeNATS(t *testing.T, maxPayload int) *fakeNATS {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeNATS{listener: listener, maxPayload: maxPayload, subs: make(map[string]map[net.Conn]string)}
+	t.Cleanup(func() { _ = listener.Close() })
+	go server.accept()
+	return server
+}
+
+func (s *fakeNATS) url() string {
+	return "nats://token@" + s.listener.Addr().String()
+}
+
+func (s *fakeNATS) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+// dropConnections closes every client connection, as a restart would.
+func (s *fakeNATS) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+	s.subs = make(map[string]map[net.Conn]string)
+}
+
+func (s *fakeNATS) subscribers(subject string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs[subject])
+}
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "INFO {\"max_payload\":%d}\r\n", s.maxPayload)
+	for {
+		line, err := readNATSLine(reader)
+		if err != nil {
+			return
+		}
+		verb, args, _ := strings.Cut(line, " ")
+		switch verb {
+		case "CONNECT":
+			if !strings.Contains(args, `"auth_token":"token"`) {
+				fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "SUB":
+			fields := strings.Fields(args)
+			s.mu.Lock()
+			if s.subs[fields[0]] == nil {
+				s.subs[fields[0]] = make(map[net.Conn]string)
+			}
+			s.subs[fields[0]][conn] = fields[1]
+			s.mu.Unlock()
+		case "PUB":
+			fields := strings.Fields(args)
+			size, _ := strconv.Atoi(fields[1])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return
+			}
+			s.mu.Lock()
+			for subscriber, sid := range s.subs[fields[0]] {
+				fmt.Fprintf(subscriber, "MSG %s %s %d\r\n%s", fields[0], sid, size, data)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func readNATSLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func receive(t *testing.T, messages <-chan string) string {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+		return ""
+	}
+}
+
+func TestNATSPublishSubscribe(t *testing.T) {
+	server := newFakeNATS(t, 1024)
+	client, err := DialNATS(server.url())
+	if err != nil {
+		t.Fatalf("DialNATS() failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	messages := make(chan string, 10)
+	if err := client.Subscribe("rooms.broadcast", func(data []byte) { messages <- string(data) }); err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+	if err := client.Publish("rooms.broadcast", []byte("hello\r\nworld")); err != nil {
+		t.Fatalf("Publish() failed: %v", err)
+	}
+	if got := receive(t, messages); got != "hello\r\nworld" {
+		t.Errorf("received %q, want %q", got, "hello\r\nworld")
+	}
+
+	if err := client.Publish("rooms.broadcast", make([]byte, 1025)); err == nil {
+		t.Error("Publish() of a message over max_payload succeeded")
+	}
+}
+
+func TestNATSReconnect(t *testing.T) {
+	server := newFakeNATS(t, 1024)
+	client, err := DialNATS(server.url())
+	if err != nil {
+		t.Fatalf("DialNATS() failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	messages := make(chan string, 10)
+	_ = client.Subscribe("rooms.broadcast", func(data []byte) { messages <- string(data) })
+	for deadline := time.Now().Add(2 * time.Second); server.subscribers("rooms.broadcast") == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	server.dropConnections()
+	// The client subscribes again once it is back
+	for deadline := time.Now().Add(5 * time.Second); server.subscribers("rooms.broadcast") == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not reconnect")
+		}
+	}
+	if err := client.Publish("rooms.broadcast", []byte("back")); err != nil {
+		t.Fatalf("Publish() after reconnecting failed: %v", err)
+	}
+	if got := receive(t, messages); got != "back" {
+		t.Errorf("received %q, want %q", got, "back")
+	}
+}
+
+func TestDialNATSErrors(t *testing.T) {
+	server := newFakeNATS(t, 1024)
+	if _, err := DialNATS("nats://wrong@" + server.listener.Addr().String()); err == nil {
+		t.Error("DialNATS() with a wrong token succeeded")
+	}
+	if _, err := DialNATS("tls://" + server.listener.Addr().String()); err == nil {
+		t.Error("DialNATS() with a tls:// URL succeeded")
+	}
+}
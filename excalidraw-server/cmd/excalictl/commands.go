@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/client"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// exportExtension is the extension of exported scenes, which the
+// Excalidraw editor opens.
+const exportExtension = ".excalidraw"
+
+func runCanvasList(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("canvas list", flag.ContinueOnError)
+	sortBy := flags.String("sort", "", "updatedAt or name")
+	ascending := flags.Bool("asc", false, "Sort in ascending order")
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	canvases, err := api.ListCanvases(ctx, client.CanvasList{Sort: *sortBy, Ascending: *ascending})
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "KEY\tNAME\tSIZE\tUPDATED")
+	for _, canvas := range canvases {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\n", canvas.Key, canvas.Name, canvas.Size, formatMillis(canvas.UpdatedAt))
+	}
+	return table.Flush()
+}
+
+func runCanvasGet(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("canvas get", flag.ContinueOnError)
+	output := flags.String("o", "", "File to write the canvas to instead of stdout")
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	data, err := api.GetCanvas(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	return c.writeOutput(*output, data)
+}
+
+func runCanvasPut(ctx context.Context, c *cli, args []string) error {
+	rest, err := parseFlags(flag.NewFlagSet("canvas put", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+	data, err := c.readInput(rest[1])
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	return api.PutCanvas(ctx, rest[0], data)
+}
+
+// runCanvasExport writes every canvas to DIR/<key>.excalidraw.
+func runCanvasExport(ctx context.Context, c *cli, args []string) error {
+	rest, err := parseFlags(flag.NewFlagSet("canvas export", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	dir := rest[0]
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	canvases, err := api.ListCanvases(ctx, client.CanvasList{})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, canvas := range canvases {
+		data, err := api.GetCanvas(ctx, canvas.Key)
+		if err != nil {
+			return fmt.Errorf("failed to get canvas %s: %w", canvas.Key, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, canvas.Key+exportExtension), data, 0o644); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.stderr, "Exported %d canvases to %s\n", len(canvases), dir)
+	return nil
+}
+
+func runDocumentShare(ctx context.Context, c *cli, args []string) error {
+	rest, err := parseFlags(flag.NewFlagSet("document share", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	data, err := c.readInput(rest[0])
+	if err != nil {
+		return err
+	}
+	if !json.Valid(data) {
+		return fmt.Errorf("%s is not a JSON scene", rest[0])
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	share, err := api.ShareDocument(ctx, data)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, share.URL)
+	return nil
+}
+
+func runDocumentGet(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("document get", flag.ContinueOnError)
+	output := flags.String("o", "", "File to write the document to instead of stdout")
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	data, err := api.GetDocument(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	return c.writeOutput(*output, data)
+}
+
+func runSnapshotList(ctx context.Context, c *cli, args []string) error {
+	rest, err := parseFlags(flag.NewFlagSet("snapshot list", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	snapshots, err := api.ListSnapshots(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tNAME\tCREATED BY\tCREATED")
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", snapshot.ID, snapshot.Name, snapshot.CreatedBy, formatMillis(snapshot.CreatedAt))
+	}
+	return table.Flush()
+}
+
+// runSnapshotExport writes a room's snapshots, or the one given with -id,
+// to DIR/<room>-<id>.excalidraw.
+func runSnapshotExport(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("snapshot export", flag.ContinueOnError)
+	dir := flags.String("dir", ".", "Directory to write the snapshots to")
+	only := flags.String("id", "", "Export only this snapshot")
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	roomID := rest[0]
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	ids := []string{*only}
+	if *only == "" {
+		snapshots, err := api.ListSnapshots(ctx, roomID)
+		if err != nil {
+			return err
+		}
+		ids = ids[:0]
+		for _, snapshot := range snapshots {
+			ids = append(ids, snapshot.ID)
+		}
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		snapshot, err := api.GetSnapshot(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get snapshot %s: %w", id, err)
+		}
+		if snapshot.RoomID != roomID {
+			return fmt.Errorf("snapshot %s belongs to room %s", id, snapshot.RoomID)
+		}
+		name := filepath.Join(*dir, roomID+"-"+id+exportExtension)
+		if err := os.WriteFile(name, snapshot.Data, 0o644); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.stderr, "Exported %d snapshots to %s\n", len(ids), *dir)
+	return nil
+}
+
+func runRoomList(ctx context.Context, c *cli, args []string) error {
+	if _, err := parseFlags(flag.NewFlagSet("room list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	rooms, err := api.ListRooms(ctx)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ROOM\tMEMBERS")
+	for _, roomID := range sortedKeys(rooms) {
+		fmt.Fprintf(table, "%s\t%d\n", roomID, rooms[roomID])
+	}
+	return table.Flush()
+}
+
+// runRoomStats prints a live room's stats as JSON; it needs
+// EXCALICTL_ADMIN_TOKEN.
+func runRoomStats(ctx context.Context, c *cli, args []string) error {
+	rest, err := parseFlags(flag.NewFlagSet("room stats", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	stats, err := api.RoomStats(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
+
+// formatMillis formats a Unix millisecond time, or - for none.
+func formatMillis(millis int64) string {
+	if millis == 0 {
+		return "-"
+	}
+	return time.UnixMilli(millis).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// configFile is what login remembers between runs.
+type configFile struct {
+	Server string `json:"server,omitempty"`
+	Token  string `json:"token,omitempty"`
+	// The device flow endpoints of the last login, reused by the next
+	DeviceURL string `json:"device_url,omitempty"`
+	TokenURL  string `json:"token_url,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+}
+
+// configPath is EXCALICTL_CONFIG, or excalictl/config.json in the user
+// config directory.
+func configPath() (string, error) {
+	if path := os.Getenv("EXCALICTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the config directory: %w", err)
+	}
+	return filepath.Join(dir, "excalictl", "config.json"), nil
+}
+
+// loadConfig reads the config file; a missing one is empty.
+func loadConfig(path string) (configFile, error) {
+	var config configFile
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// saveConfig writes the config file, readable only by the user since it
+// holds a token.
+func saveConfig(path string, config configFile) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/apiversion"
+	"excalidraw-server/handlers/api/canvases"
+	"excalidraw-server/handlers/api/documents"
+	"excalidraw-server/stores/sqlite"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newTestServer serves the canvas and document API, and an identity
+// provider whose device flow approves on the second poll.
+func newTestServer(t *testing.T) (server, provider *httptest.Server) {
+	t.Helper()
+	verifier := auth.NewVerifier([]byte("secret"))
+	store := sqlite.NewDocumentStore(filepath.Join(t.TempDir(), "test.db"))
+	r := chi.NewRouter()
+	r.Route("/api/v3", func(r chi.Router) {
+		r.Use(apiversion.Middleware(apiversion.Latest))
+		r.Post("/post/", documents.HandleCreate(store))
+		r.Get("/{id}/", documents.HandleGet(store))
+		r.Route("/kv", func(r chi.Router) {
+			canvasStore := store.(core.CanvasStore)
+			r.Use(auth.Middleware(verifier, true))
+			r.Get("/", canvases.HandleList(canvasStore))
+			r.Get("/{key}", canvases.HandleGet(canvasStore))
+			r.Put("/{key}", canvases.HandlePut(canvasStore))
+		})
+	})
+	server = httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	var polls atomic.Int32
+	idp := http.NewServeMux()
+	idp.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "excalictl" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"device_code": "device", "user_code": "ABCD-EFGH", "verification_uri": "https://idp.example.com/activate"})
+	})
+	idp.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != deviceCodeGrant || r.FormValue("device_code") != "device" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		if polls.Add(1) == 1 {
+			http.Error(w, `{"error":"authorization_pending"}`, http.StatusBadRequest)
+			return
+		}
+		token, _ := verifier.Sign(&auth.Claims{Subject: "alice", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": token, "token_type": "Bearer"})
+	})
+	provider = httptest.NewServer(idp)
+	t.Cleanup(provider.Close)
+	return server, provider
+}
+
+// excalictl runs a command line and returns its output.
+func excalictl(t *testing.T, stdin string, args ...string) (string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	status := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	if status != 0 {
+		return stderr.String(), status
+	}
+	return stdout.String(), status
+}
+
+func TestExcalictl(t *testing.T) {
+	server, provider := newTestServer(t)
+	t.Setenv("EXCALICTL_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	defaultPollInterval = 10 * time.Millisecond
+
+	if out, status := excalictl(t, "", "canvas", "list"); status != 1 || !strings.Contains(out, "no server configured") {
+		t.Errorf("canvas list before login = %d %q", status, out)
+	}
+	if _, status := excalictl(t, "", "canvas", "frobnicate"); status != 2 {
+		t.Errorf("unknown command exited with %d, want 2", status)
+	}
+
+	out, status := excalictl(t, "", "login", "-server", server.URL,
+		"-device-url", provider.URL+"/device", "-token-url", provider.URL+"/token", "-client-id", "excalictl")
+	if status != 0 {
+		t.Fatalf("login = %d %q", status, out)
+	}
+
+	scene := `{"type":"excalidraw","elements":[]}`
+	if out, status := excalictl(t, scene, "canvas", "put", "roadmap", "-"); status != 0 {
+		t.Fatalf("canvas put = %d %q", status, out)
+	}
+	if out, _ := excalictl(t, "", "canvas", "list"); !strings.Contains(out, "roadmap") {
+		t.Errorf("canvas list = %q, want roadmap", out)
+	}
+	if out, _ := excalictl(t, "", "canvas", "get", "roadmap"); out != scene {
+		t.Errorf("canvas get = %q, want %q", out, scene)
+	}
+
+	dir := t.TempDir()
+	if out, status := excalictl(t, "", "canvas", "export", dir); status != 0 {
+		t.Fatalf("canvas export = %d %q", status, out)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "roadmap.excalidraw")); err != nil || string(data) != scene {
+		t.Errorf("exported canvas = %q, %v", data, err)
+	}
+
+	out, status = excalictl(t, scene, "document", "share", "-")
+	if status != 0 || !strings.HasPrefix(out, server.URL+"/share/") {
+		t.Fatalf("document share = %d %q", status, out)
+	}
+	id := strings.TrimPrefix(strings.TrimSpace(out), server.URL+"/share/")
+	if out, _ := excalictl(t, "", "document", "get", id); out != scene {
+		t.Errorf("document get = %q, want %q", out, scene)
+	}
+
+	// A token that isn't accepted fails with the server's error
+	t.Setenv("EXCALICTL_TOKEN", "nope")
+	if out, status := excalictl(t, "", "canvas", "list"); status != 1 || !strings.Contains(out, "invalid_token") && !strings.Contains(out, "401") {
+		t.Errorf("canvas list with a bad token = %d %q", status, out)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// deviceCodeGrant is the grant type of device flow token requests, see
+// RFC 8628.
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// defaultPollInterval is how often the token endpoint is polled when the
+// identity provider doesn't say.
+var defaultPollInterval = 5 * time.Second
+
+type (
+	deviceAuthorization struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+
+	tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+)
+
+// runLogin stores the server and a token for the commands that follow.
+// Without -token or device flow endpoints it asks for a token on stdin.
+func runLogin(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	server := flags.String("server", c.config.Server, "Server URL")
+	token := flags.String("token", "", "JWT to use as is")
+	deviceURL := flags.String("device-url", c.config.DeviceURL, "Device authorization endpoint of the identity provider")
+	tokenURL := flags.String("token-url", c.config.TokenURL, "Token endpoint of the identity provider")
+	clientID := flags.String("client-id", c.config.ClientID, "OAuth client id of excalictl at the identity provider")
+	scope := flags.String("scope", "", "OAuth scopes to ask for")
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	if *server == "" {
+		return errors.New("-server is required")
+	}
+
+	config := c.config
+	config.Server = *server
+	switch {
+	case *token != "":
+		config.Token = *token
+	case *deviceURL != "" || *tokenURL != "":
+		if *deviceURL == "" || *tokenURL == "" || *clientID == "" {
+			return errors.New("the device flow needs -device-url, -token-url and -client-id")
+		}
+		obtained, err := deviceLogin(ctx, c, *deviceURL, *tokenURL, *clientID, *scope)
+		if err != nil {
+			return err
+		}
+		config.Token = obtained
+		config.DeviceURL, config.TokenURL, config.ClientID = *deviceURL, *tokenURL, *clientID
+	default:
+		fmt.Fprint(c.stderr, "Token: ")
+		line, err := bufio.NewReader(c.stdin).ReadString('\n')
+		if strings.TrimSpace(line) == "" {
+			if err == nil {
+				err = errors.New("no token given")
+			}
+			return err
+		}
+		config.Token = strings.TrimSpace(line)
+	}
+
+	if err := saveConfig(c.configPath, config); err != nil {
+		return fmt.Errorf("failed to save the login: %w", err)
+	}
+	fmt.Fprintf(c.stdout, "Logged in to %s\n", config.Server)
+	return nil
+}
+
+// deviceLogin runs the OAuth device flow: the user approves excalictl in a
+// browser while it polls for the token.
+func deviceLogin(ctx context.Context, c *cli, deviceURL, tokenURL, clientID, scope string) (string, error) {
+	form := url.Values{"client_id": {clientID}}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	var authorization deviceAuthorization
+	if err := postForm(ctx, deviceURL, form, &authorization); err != nil {
+		return "", fmt.Errorf("device authorization failed: %w", err)
+	}
+	if authorization.DeviceCode == "" {
+		return "", errors.New("device authorization failed: no device code")
+	}
+
+	if authorization.VerificationURIComplete != "" {
+		fmt.Fprintf(c.stderr, "Open %s and confirm the code %s\n", authorization.VerificationURIComplete, authorization.UserCode)
+	} else {
+		fmt.Fprintf(c.stderr, "Open %s and enter the code %s\n", authorization.VerificationURI, authorization.UserCode)
+	}
+
+	interval := defaultPollInterval
+	if authorization.Interval > 0 {
+		interval = time.Duration(authorization.Interval) * time.Second
+	}
+	if authorization.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(authorization.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	form = url.Values{
+		"grant_type":  {deviceCodeGrant},
+		"device_code": {authorization.DeviceCode},
+		"client_id":   {clientID},
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return "", errors.New("the device code expired before it was approved")
+		case <-time.After(interval):
+		}
+
+		var token tokenResponse
+		if err := postForm(ctx, tokenURL, form, &token); err != nil && token.Error == "" {
+			return "", fmt.Errorf("token request failed: %w", err)
+		}
+		switch token.Error {
+		case "":
+			if token.AccessToken == "" {
+				return "", errors.New("token request failed: no access token")
+			}
+			return token.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return "", errors.New("the login was denied")
+		case "expired_token":
+			return "", errors.New("the device code expired before it was approved")
+		default:
+			return "", fmt.Errorf("token request failed: %s %s", token.Error, token.Description)
+		}
+	}
+}
+
+// postForm posts a form and decodes the JSON answer into out, which OAuth
+// error responses fill too.
+func postForm(ctx context.Context, endpoint string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decodeErr := json.NewDecoder(resp.Body).Decode(out)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s answered %s", endpoint, resp.Status)
+	}
+	return decodeErr
+}
@@ -0,0 +1,181 @@
+// Command excalictl works with an excalidraw-server from the terminal, for
+// scripts and backups:
+//
+//	excalictl login -server https://draw.example.com
+//	excalictl canvas list
+//	excalictl canvas export ./backup
+//	excalictl snapshot export -dir ./backup room-id
+//
+// login stores the server and a JWT in the user config directory, either a
+// token given as is or one obtained with the OAuth device flow of the
+// identity provider that signs the server's tokens. EXCALICTL_SERVER and
+// EXCALICTL_TOKEN override the stored ones, and EXCALICTL_ADMIN_TOKEN is
+// the server's ADMIN_TOKEN for room stats.
+package main
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/client"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const usage = `usage: excalictl <command> [arguments]
+
+commands:
+  login [-server URL] [-token JWT | -device-url URL -token-url URL -client-id ID]
+  canvas list [-sort updatedAt|name] [-asc]
+  canvas get [-o FILE] KEY
+  canvas put KEY FILE
+  canvas export DIR
+  document share FILE
+  document get [-o FILE] ID
+  snapshot list ROOM
+  snapshot export [-dir DIR] [-id SNAPSHOT] ROOM
+  room list
+  room stats ROOM
+
+FILE may be - for stdin or stdout.
+`
+
+// errUsage reports a command line that doesn't parse; the usage is
+// printed instead of the error.
+var errUsage = errors.New("invalid arguments")
+
+// cli is what a command works with.
+type cli struct {
+	config configFile
+	// configPath is where login saves config
+	configPath string
+	adminToken string
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// commands maps "group action" and single-word commands to their handler.
+var commands = map[string]func(ctx context.Context, c *cli, args []string) error{
+	"login":           runLogin,
+	"canvas list":     runCanvasList,
+	"canvas get":      runCanvasGet,
+	"canvas put":      runCanvasPut,
+	"canvas export":   runCanvasExport,
+	"document share":  runDocumentShare,
+	"document get":    runDocumentGet,
+	"snapshot list":   runSnapshotList,
+	"snapshot export": runSnapshotExport,
+	"room list":       runRoomList,
+	"room stats":      runRoomStats,
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs a command line and returns the exit status.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	path, err := configPath()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	c := &cli{
+		config:     config,
+		configPath: path,
+		adminToken: os.Getenv("EXCALICTL_ADMIN_TOKEN"),
+		stdin:      stdin,
+		stdout:     stdout,
+		stderr:     stderr,
+	}
+	if server := os.Getenv("EXCALICTL_SERVER"); server != "" {
+		c.config.Server = server
+	}
+	if token := os.Getenv("EXCALICTL_TOKEN"); token != "" {
+		c.config.Token = token
+	}
+
+	command, rest := findCommand(args)
+	if command == nil {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if err := command(ctx, c, rest); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			fmt.Fprint(stderr, usage)
+			return 2
+		}
+		fmt.Fprintln(stderr, "excalictl:", err)
+		return 1
+	}
+	return 0
+}
+
+func findCommand(args []string) (func(context.Context, *cli, []string) error, []string) {
+	if len(args) >= 2 {
+		if command, ok := commands[args[0]+" "+args[1]]; ok {
+			return command, args[2:]
+		}
+	}
+	if len(args) >= 1 {
+		if command, ok := commands[args[0]]; ok {
+			return command, args[1:]
+		}
+	}
+	return nil, nil
+}
+
+// client returns a client of the configured server.
+func (c *cli) client() (*client.Client, error) {
+	if c.config.Server == "" {
+		return nil, errors.New("no server configured; run excalictl login -server URL")
+	}
+	return client.New(c.config.Server, client.WithToken(c.config.Token), client.WithAdminToken(c.adminToken))
+}
+
+// parseFlags parses a command's flags and checks it got want arguments.
+func parseFlags(flags *flag.FlagSet, args []string, want int) ([]string, error) {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	if flags.NArg() != want {
+		return nil, errUsage
+	}
+	return flags.Args(), nil
+}
+
+// readInput reads a file, or stdin for -.
+func (c *cli) readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(c.stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// writeOutput writes to a file, or stdout for - or no name.
+func (c *cli) writeOutput(name string, data []byte) error {
+	if name == "" || name == "-" {
+		_, err := c.stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(name, data, 0o644)
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
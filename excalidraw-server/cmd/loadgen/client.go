@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// errDisconnected is returned by client.run when the server closes the
+// socket.
+var errDisconnected = errors.New("disconnected by the server")
+
+// client is a minimal Socket.IO 5 client over the Engine.IO 4 websocket
+// transport: enough to join rooms and emit and receive JSON events. Binary
+// events are skipped.
+type client struct {
+	conn *websocket.Conn
+	// sid is the Socket.IO socket id the server assigned.
+	sid string
+
+	writeMu sync.Mutex
+}
+
+// dial connects to the Socket.IO server at serverURL (http or https) under
+// path and opens the main namespace, passing token as auth.token when set.
+func dial(ctx context.Context, serverURL, path, token string) (*client, error) {
+	endpoint, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	switch endpoint.Scheme {
+	case "http":
+		endpoint.Scheme = "ws"
+	case "https":
+		endpoint.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return nil, fmt.Errorf("invalid server URL %q: must be http(s) or ws(s)", serverURL)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + strings.Trim(path, "/") + "/"
+	endpoint.RawQuery = url.Values{"EIO": {"4"}, "transport": {"websocket"}}.Encode()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{conn: conn}
+	if err := c.handshake(token); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake waits for the Engine.IO open packet, then connects to the main
+// namespace.
+func (c *client) handshake(token string) error {
+	packet, err := c.read()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(packet, "0") {
+		return fmt.Errorf("unexpected open packet %q", packet)
+	}
+
+	connect := "40"
+	if token != "" {
+		auth, err := json.Marshal(map[string]string{"token": token})
+		if err != nil {
+			return err
+		}
+		connect += string(auth)
+	}
+	if err := c.write(connect); err != nil {
+		return err
+	}
+
+	for {
+		packet, err := c.read()
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(packet, "40"):
+			var connected struct {
+				SID string `json:"sid"`
+			}
+			if err := json.Unmarshal([]byte(packet[2:]), &connected); err != nil {
+				return fmt.Errorf("invalid connect packet %q: %w", packet, err)
+			}
+			c.sid = connected.SID
+			return nil
+		case strings.HasPrefix(packet, "44"):
+			var refused struct {
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal([]byte(packet[2:]), &refused)
+			return fmt.Errorf("connection refused: %s", refused.Message)
+		case packet == "2":
+			if err := c.write("3"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// emit sends an event with JSON arguments.
+func (c *client) emit(event string, args ...any) error {
+	data, err := json.Marshal(append([]any{event}, args...))
+	if err != nil {
+		return err
+	}
+	return c.write("42" + string(data))
+}
+
+// run reads packets until the connection ends, answering pings and passing
+// events to handle. It returns nil once close was called.
+func (c *client) run(handle func(event string, args []json.RawMessage)) error {
+	for {
+		packet, err := c.read()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		switch {
+		case packet == "":
+			// a binary attachment
+		case packet == "2":
+			if err := c.write("3"); err != nil {
+				return err
+			}
+		case packet == "1", strings.HasPrefix(packet, "41"):
+			return errDisconnected
+		case strings.HasPrefix(packet, "42"):
+			event, args, err := parseEvent(packet[2:])
+			if err != nil {
+				return err
+			}
+			handle(event, args)
+		}
+	}
+}
+
+// close disconnects from the namespace and closes the connection.
+func (c *client) close() error {
+	_ = c.write("41")
+	return c.conn.Close()
+}
+
+// read returns the next text packet, or "" for a binary one.
+func (c *client) read() (string, error) {
+	kind, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return "", err
+	}
+	if kind != websocket.TextMessage {
+		return "", nil
+	}
+	return string(data), nil
+}
+
+func (c *client) write(packet string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, []byte(packet))
+}
+
+// parseEvent splits the body of an event packet, e.g. `12["name",1]` with
+// an ack id, into the event name and its arguments.
+func parseEvent(body string) (string, []json.RawMessage, error) {
+	if strings.HasPrefix(body, "/") {
+		_, body, _ = strings.Cut(body, ",")
+	}
+	body = strings.TrimLeft(body, "0123456789")
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &parts); err != nil || len(parts) == 0 {
+		return "", nil, fmt.Errorf("invalid event packet %q", body)
+	}
+	var event string
+	if err := json.Unmarshal(parts[0], &event); err != nil {
+		return "", nil, fmt.Errorf("invalid event name %s", parts[0])
+	}
+	return event, parts[1:], nil
+}
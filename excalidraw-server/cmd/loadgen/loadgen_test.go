@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/handlers/websocket"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseEvent(t *testing.T) {
+	for _, body := range []string{`["client-broadcast",{"a":1},null]`, `12["client-broadcast",{"a":1},null]`, `/other,["client-broadcast",{"a":1},null]`} {
+		event, args, err := parseEvent(body)
+		if err != nil || event != "client-broadcast" || len(args) != 2 || string(args[0]) != `{"a":1}` {
+			t.Errorf("parseEvent(%s) = %q, %s, %v", body, event, args, err)
+		}
+	}
+	for _, body := range []string{``, `[]`, `{"a":1}`, `[1]`} {
+		if _, _, err := parseEvent(body); err == nil {
+			t.Errorf("parseEvent(%s) succeeded", body)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	var l latencies
+	if got := l.percentiles(); got != (Percentiles{}) {
+		t.Errorf("percentiles() of nothing = %+v", got)
+	}
+	for i := 100; i >= 1; i-- {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+	want := Percentiles{P50: 50, P90: 90, P99: 99, Max: 100}
+	if got := l.percentiles(); got != want {
+		t.Errorf("percentiles() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv := websocket.SetupSocketIO(websocket.AuthOptions{})
+	server := httptest.NewServer(srv.ServeHandler(nil))
+	defer server.Close()
+
+	report, err := run(context.Background(), config{
+		URL:           server.URL,
+		Path:          "/socket.io/",
+		Rooms:         2,
+		Clients:       3,
+		BroadcastRate: 20,
+		ChatRate:      10,
+		Size:          64,
+		Duration:      500 * time.Millisecond,
+		Drain:         500 * time.Millisecond,
+		JoinTimeout:   5 * time.Second,
+		RoomPrefix:    "loadgen-test",
+	}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Connected != 6 || report.ConnectFailures != 0 || report.Disconnects != 0 {
+		t.Errorf("clients = %+v, want all 6 joined", report)
+	}
+	for name, traffic := range map[string]Traffic{"broadcasts": report.Broadcasts, "chat": report.Chat} {
+		if traffic.Sent == 0 || traffic.Delivered != traffic.Expected || traffic.DropRate != 0 {
+			t.Errorf("%s = %+v, want every event delivered", name, traffic)
+		}
+		if traffic.Latency.Max <= 0 {
+			t.Errorf("%s latency = %+v, want it measured", name, traffic.Latency)
+		}
+	}
+	if report.Broadcasts.Expected != 2*report.Broadcasts.Sent || report.Chat.Expected != 3*report.Chat.Sent {
+		t.Errorf("expected deliveries = %d and %d, want every other member and every member",
+			report.Broadcasts.Expected, report.Chat.Expected)
+	}
+
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("report doesn't encode: %v", err)
+	}
+}
+
+func TestRunWithoutServer(t *testing.T) {
+	server := httptest.NewServer(nil)
+	server.Close()
+
+	_, err := run(context.Background(), config{URL: server.URL, Path: "/socket.io/", Rooms: 1, Clients: 1, JoinTimeout: time.Second}, io.Discard)
+	if err == nil {
+		t.Error("run() without a server succeeded")
+	}
+}
@@ -0,0 +1,445 @@
+// Command loadgen simulates collaboration rooms against a running server and
+// reports delivery latency and drop rates:
+//
+//	go run ./cmd/loadgen -url http://localhost:3002 -rooms 20 -clients 5 -duration 1m
+//
+// Every client joins one room and, once all of them have joined, emits
+// server-broadcast (or server-volatile-broadcast) and server-chat-message at
+// the configured rates. Payloads carry the time they were sent, so each
+// client can time the client-broadcast and client-chat-message events it
+// receives. A broadcast should reach every other member of its room and a
+// chat message every member; whatever hasn't arrived by the end of the drain
+// period counts as dropped.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// chatIDPrefix marks the chat messages loadgen sends; the id ends with the
+// send time in Unix nanoseconds.
+const chatIDPrefix = "loadgen-"
+
+// maxConcurrentDials caps how many clients connect at the same time.
+const maxConcurrentDials = 64
+
+type config struct {
+	URL   string
+	Path  string
+	Token string
+
+	Rooms   int
+	Clients int
+	// BroadcastRate and ChatRate are per client, per second; zero sends
+	// none.
+	BroadcastRate float64
+	ChatRate      float64
+	// Size pads broadcast payloads to about this many bytes.
+	Size     int
+	Volatile bool
+
+	Duration time.Duration
+	// Drain is how long to wait for deliveries after the last send.
+	Drain time.Duration
+	// JoinTimeout bounds connecting and joining a room.
+	JoinTimeout time.Duration
+	RoomPrefix  string
+}
+
+type (
+	// Traffic describes one kind of event sent during a run.
+	Traffic struct {
+		Sent       int64 `json:"sent"`
+		SendErrors int64 `json:"send_errors"`
+		// Expected counts the deliveries the sent events should have
+		// caused.
+		Expected  int64       `json:"expected"`
+		Delivered int64       `json:"delivered"`
+		DropRate  float64     `json:"drop_rate"`
+		Latency   Percentiles `json:"latency"`
+	}
+
+	// Report is the outcome of a run.
+	Report struct {
+		Clients         int         `json:"clients"`
+		Connected       int         `json:"connected"`
+		ConnectFailures int         `json:"connect_failures"`
+		Disconnects     int64       `json:"disconnects"`
+		JoinLatency     Percentiles `json:"join_latency"`
+		Seconds         float64     `json:"seconds"`
+		Broadcasts      Traffic     `json:"broadcasts"`
+		Chat            Traffic     `json:"chat"`
+	}
+)
+
+// traffic counts one kind of event while a run is going.
+type traffic struct {
+	sent, sendErrors, expected, delivered atomic.Int64
+	latencies                             latencies
+}
+
+func (t *traffic) report() Traffic {
+	report := Traffic{
+		Sent:       t.sent.Load(),
+		SendErrors: t.sendErrors.Load(),
+		Expected:   t.expected.Load(),
+		Delivered:  t.delivered.Load(),
+		Latency:    t.latencies.percentiles(),
+	}
+	if report.Expected > 0 && report.Delivered < report.Expected {
+		report.DropRate = float64(report.Expected-report.Delivered) / float64(report.Expected)
+	}
+	return report
+}
+
+type (
+	// stamp marks a broadcast as loadgen's, with the time it was sent in
+	// Unix nanoseconds.
+	stamp struct {
+		Sent int64  `json:"sent"`
+		Pad  string `json:"pad,omitempty"`
+	}
+
+	// broadcastPayload is the scene update loadgen broadcasts.
+	broadcastPayload struct {
+		Loadgen *stamp `json:"loadgen"`
+	}
+)
+
+// member is a client that joined its room.
+type member struct {
+	client *client
+	roomID string
+	done   chan error
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.URL, "url", "http://localhost:3002", "Server URL")
+	flag.StringVar(&cfg.Path, "path", "/socket.io/", "Socket.IO path, including any BASE_PATH")
+	flag.StringVar(&cfg.Token, "token", "", "JWT passed as auth.token, for servers with SOCKET_AUTH")
+	flag.IntVar(&cfg.Rooms, "rooms", 10, "Number of rooms")
+	flag.IntVar(&cfg.Clients, "clients", 5, "Clients per room")
+	flag.Float64Var(&cfg.BroadcastRate, "broadcast-rate", 2, "Scene broadcasts per client per second (0 disables)")
+	flag.Float64Var(&cfg.ChatRate, "chat-rate", 0.1, "Chat messages per client per second (0 disables)")
+	flag.IntVar(&cfg.Size, "size", 1024, "Approximate broadcast payload size in bytes")
+	flag.BoolVar(&cfg.Volatile, "volatile", false, "Send server-volatile-broadcast, like cursor updates, instead of server-broadcast")
+	flag.DurationVar(&cfg.Duration, "duration", 30*time.Second, "How long to send for")
+	flag.DurationVar(&cfg.Drain, "drain", 2*time.Second, "How long to wait for deliveries after sending stops")
+	flag.DurationVar(&cfg.JoinTimeout, "join-timeout", 10*time.Second, "How long a client may take to connect and join its room")
+	flag.StringVar(&cfg.RoomPrefix, "room-prefix", "loadgen", "Prefix of the room ids")
+	jsonOutput := flag.Bool("json", false, "Print the report as JSON")
+	maxDropRate := flag.Float64("max-drop-rate", -1, "Exit with status 1 when more than this fraction of deliveries is dropped (negative disables)")
+	flag.Parse()
+
+	if cfg.Rooms < 1 || cfg.Clients < 1 {
+		fmt.Fprintln(os.Stderr, "-rooms and -clients must be at least 1")
+		os.Exit(2)
+	}
+	if cfg.BroadcastRate < 0 || cfg.ChatRate < 0 || cfg.Size < 0 {
+		fmt.Fprintln(os.Stderr, "-broadcast-rate, -chat-rate and -size must not be negative")
+		os.Exit(2)
+	}
+
+	report, err := run(context.Background(), cfg, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	} else {
+		printReport(os.Stdout, report)
+	}
+
+	if *maxDropRate >= 0 && (report.Broadcasts.DropRate > *maxDropRate || report.Chat.DropRate > *maxDropRate) {
+		os.Exit(1)
+	}
+}
+
+// run connects cfg.Rooms × cfg.Clients clients, sends for cfg.Duration and
+// reports what arrived. Progress is written to progress.
+func run(ctx context.Context, cfg config, progress io.Writer) (Report, error) {
+	broadcasts, chat := &traffic{}, &traffic{}
+	var disconnects atomic.Int64
+	var joinLatencies latencies
+
+	// handle times the events a client receives
+	handle := func(event string, args []json.RawMessage) {
+		if len(args) == 0 {
+			return
+		}
+		switch event {
+		case "client-broadcast":
+			var payload broadcastPayload
+			if json.Unmarshal(args[0], &payload) != nil || payload.Loadgen == nil {
+				return
+			}
+			broadcasts.delivered.Add(1)
+			broadcasts.latencies.add(time.Since(time.Unix(0, payload.Loadgen.Sent)))
+		case "client-chat-message":
+			var message struct {
+				ID string `json:"id"`
+			}
+			if json.Unmarshal(args[0], &message) != nil || !strings.HasPrefix(message.ID, chatIDPrefix) {
+				return
+			}
+			sent, err := strconv.ParseInt(message.ID[strings.LastIndex(message.ID, "-")+1:], 10, 64)
+			if err != nil {
+				return
+			}
+			chat.delivered.Add(1)
+			chat.latencies.add(time.Since(time.Unix(0, sent)))
+		}
+	}
+
+	fmt.Fprintf(progress, "Connecting %d clients to %d rooms...\n", cfg.Rooms*cfg.Clients, cfg.Rooms)
+	var (
+		mu      sync.Mutex
+		members []*member
+		// roomSizes counts the members that joined each room
+		roomSizes = make(map[string]int64)
+		failures  int
+		wg        sync.WaitGroup
+		dials     = make(chan struct{}, maxConcurrentDials)
+	)
+	for room := 0; room < cfg.Rooms; room++ {
+		roomID := fmt.Sprintf("%s-%d", cfg.RoomPrefix, room)
+		for i := 0; i < cfg.Clients; i++ {
+			wg.Add(1)
+			go func(roomID string) {
+				defer wg.Done()
+				dials <- struct{}{}
+				defer func() { <-dials }()
+
+				start := time.Now()
+				m, err := join(ctx, cfg, roomID, handle)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if failures == 0 {
+						fmt.Fprintf(progress, "Failed to join %s: %v\n", roomID, err)
+					}
+					failures++
+					return
+				}
+				joinLatencies.add(time.Since(start))
+				members = append(members, m)
+				roomSizes[roomID]++
+			}(roomID)
+		}
+	}
+	wg.Wait()
+	if len(members) == 0 {
+		return Report{}, errors.New("no client could join its room")
+	}
+
+	fmt.Fprintf(progress, "%d clients joined, sending for %s...\n", len(members), cfg.Duration)
+	sendCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+	start := time.Now()
+	for clientID, m := range members {
+		wg.Add(1)
+		go func(clientID int, m *member) {
+			defer wg.Done()
+			send(sendCtx, cfg, clientID, m, roomSizes[m.roomID], broadcasts, chat)
+		}(clientID, m)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// Wait for the last deliveries, then hang up
+	select {
+	case <-time.After(cfg.Drain):
+	case <-ctx.Done():
+	}
+	for _, m := range members {
+		if err := <-m.doneOrClose(); err != nil {
+			disconnects.Add(1)
+		}
+	}
+
+	return Report{
+		Clients:         cfg.Rooms * cfg.Clients,
+		Connected:       len(members),
+		ConnectFailures: failures,
+		Disconnects:     disconnects.Load(),
+		JoinLatency:     joinLatencies.percentiles(),
+		Seconds:         elapsed.Seconds(),
+		Broadcasts:      broadcasts.report(),
+		Chat:            chat.report(),
+	}, nil
+}
+
+// join connects a client and waits until the server has put it in roomID.
+func join(ctx context.Context, cfg config, roomID string, handle func(string, []json.RawMessage)) (*member, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.JoinTimeout)
+	defer cancel()
+
+	c, err := dial(ctx, cfg.URL, cfg.Path, cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+	m := &member{client: c, roomID: roomID, done: make(chan error, 1)}
+
+	joined := make(chan struct{})
+	var once sync.Once
+	go func() {
+		m.done <- c.run(func(event string, args []json.RawMessage) {
+			if event == "room-user-change" && len(args) > 0 {
+				var users []string
+				if json.Unmarshal(args[0], &users) == nil && containsString(users, c.sid) {
+					once.Do(func() { close(joined) })
+				}
+			}
+			handle(event, args)
+		})
+	}()
+
+	if err := c.emit("join-room", roomID); err != nil {
+		_ = c.close()
+		return nil, err
+	}
+	select {
+	case <-joined:
+		return m, nil
+	case err := <-m.done:
+		_ = c.close()
+		if err == nil {
+			err = errDisconnected
+		}
+		return nil, err
+	case <-ctx.Done():
+		_ = c.close()
+		return nil, fmt.Errorf("joining %s: %w", roomID, ctx.Err())
+	}
+}
+
+// doneOrClose closes the member's connection unless the server already
+// did, and returns a channel with the error that ended it, nil when it
+// was closed here.
+func (m *member) doneOrClose() <-chan error {
+	select {
+	case err := <-m.done:
+		if err == nil {
+			err = errDisconnected
+		}
+		result := make(chan error, 1)
+		result <- err
+		return result
+	default:
+	}
+	_ = m.client.close()
+	return m.done
+}
+
+// send emits broadcasts and chat messages at the configured rates until ctx
+// is done. roomSize is how many clients joined the member's room.
+func send(ctx context.Context, cfg config, clientID int, m *member, roomSize int64, broadcasts, chat *traffic) {
+	broadcastTick := ticker(ctx, cfg.BroadcastRate)
+	chatTick := ticker(ctx, cfg.ChatRate)
+	pad := strings.Repeat("x", cfg.Size)
+	event := "server-broadcast"
+	if cfg.Volatile {
+		event = "server-volatile-broadcast"
+	}
+
+	for n := 0; ; n++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-broadcastTick:
+			payload := broadcastPayload{Loadgen: &stamp{Sent: time.Now().UnixNano(), Pad: pad}}
+			if err := m.client.emit(event, m.roomID, payload); err != nil {
+				broadcasts.sendErrors.Add(1)
+				continue
+			}
+			broadcasts.sent.Add(1)
+			broadcasts.expected.Add(roomSize - 1)
+		case <-chatTick:
+			id := fmt.Sprintf("%s%d-%d-%d", chatIDPrefix, clientID, n, time.Now().UnixNano())
+			message := map[string]string{"id": id, "content": "Load test message " + strconv.Itoa(n)}
+			if err := m.client.emit("server-chat-message", m.roomID, message); err != nil {
+				chat.sendErrors.Add(1)
+				continue
+			}
+			chat.sent.Add(1)
+			chat.expected.Add(roomSize)
+		}
+	}
+}
+
+// ticker ticks perSecond times a second until ctx is done, starting after
+// a random part of the first interval so that clients don't send in
+// lockstep. Zero never ticks.
+func ticker(ctx context.Context, perSecond float64) <-chan time.Time {
+	if perSecond <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / perSecond)
+	ticks := make(chan time.Time)
+	go func() {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(interval)))):
+		case <-ctx.Done():
+			return
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case now := <-t.C:
+				select {
+				case ticks <- now:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ticks
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func printReport(w io.Writer, report Report) {
+	fmt.Fprintf(w, "Clients:     %d joined, %d failed, %d disconnected (join p50 %.1fms, p99 %.1fms)\n",
+		report.Connected, report.ConnectFailures, report.Disconnects, report.JoinLatency.P50, report.JoinLatency.P99)
+	fmt.Fprintf(w, "Duration:    %.1fs\n", report.Seconds)
+	printTraffic(w, "Broadcasts:", report.Broadcasts, report.Seconds)
+	printTraffic(w, "Chat:", report.Chat, report.Seconds)
+}
+
+func printTraffic(w io.Writer, label string, t Traffic, seconds float64) {
+	if t.Sent == 0 && t.SendErrors == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%-12s %d sent (%.1f/s), %d send errors, %d/%d delivered, %.2f%% dropped\n",
+		label, t.Sent, float64(t.Sent)/seconds, t.SendErrors, t.Delivered, t.Expected, t.DropRate*100)
+	fmt.Fprintf(w, "%-12s p50 %.1fms, p90 %.1fms, p99 %.1fms, max %.1fms\n",
+		"", t.Latency.P50, t.Latency.P90, t.Latency.P99, t.Latency.Max)
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Parse reads a KEY=VALUE file in the format of .env.example. Blank lines and
+// lines starting with # are ignored; values may be wrapped in quotes.
+func Parse(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close config file")
+		}
+	}()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNumber)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+
+	return values, scanner.Err()
+}
+
+// Load parses the file and exports its values into the process environment,
+// overriding variables that are already set.
+func Load(path string) error {
+	values, err := Parse(path)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range values {
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reloader re-reads a config file and re-applies runtime-safe settings
+// without restarting the server.
+type Reloader struct {
+	path     string
+	mu       sync.Mutex
+	appliers []func() error
+	modTime  time.Time
+}
+
+func NewReloader(path string) *Reloader {
+	r := &Reloader{path: path}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// OnReload registers fn to run after every reload. Appliers read their
+// settings from the environment and return an error for invalid values.
+func (r *Reloader) OnReload(fn func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.appliers = append(r.appliers, fn)
+}
+
+// Reload loads the config file, if any, and runs every applier. Appliers
+// still run when one of them fails so unrelated settings are not held back.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.path != "" {
+		if err := Load(r.path); err != nil {
+			return err
+		}
+	}
+
+	var errs []string
+	for _, apply := range r.appliers {
+		if err := apply(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to apply config: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Watch polls the config file and reloads it whenever its modification time
+// changes, until stop is closed.
+func (r *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	if r.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(r.path)
+			if err != nil || info.ModTime().Equal(r.modTime) {
+				continue
+			}
+			r.modTime = info.ModTime()
+
+			log := logrus.WithField("path", r.path)
+			if err := r.Reload(); err != nil {
+				log.WithError(err).Error("Failed to reload config")
+				continue
+			}
+			log.Info("Config reloaded")
+		}
+	}
+}
+
+// BasePathFromEnv reads BASE_PATH, the URL prefix the server is mounted
+// under behind a path-routing reverse proxy. It returns "" for the root and
+// otherwise a path with a leading and no trailing slash, e.g. "/excalidraw".
+func BasePathFromEnv() (string, error) {
+	value := strings.Trim(strings.TrimSpace(os.Getenv("BASE_PATH")), "/")
+	if value == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(value, "{}*?#% ") || strings.Contains(value, "//") {
+		return "", fmt.Errorf("invalid BASE_PATH %q", os.Getenv("BASE_PATH"))
+	}
+	return "/" + value, nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.env")
+	writeConfig(t, path, `# comment
+STORAGE_TYPE=sqlite
+
+export LOG_LEVEL = debug
+QUOTED="with spaces"
+SINGLE='single'
+EMPTY=
+`)
+
+	values, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	want := map[string]string{
+		"STORAGE_TYPE": "sqlite",
+		"LOG_LEVEL":    "debug",
+		"QUOTED":       "with spaces",
+		"SINGLE":       "single",
+		"EMPTY":        "",
+	}
+	if len(values) != len(want) {
+		t.Errorf("got %d values, want %d: %v", len(values), len(want), values)
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s = %q, want %q", key, values[key], value)
+		}
+	}
+}
+
+func TestParse_InvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.env")
+	writeConfig(t, path, "VALID=1\nnot a setting\n")
+
+	if _, err := Parse(path); err == nil {
+		t.Error("expected error for line without '='")
+	}
+}
+
+func TestParse_MissingFile(t *testing.T) {
+	if _, err := Parse(filepath.Join(t.TempDir(), "missing.env")); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+}
+
+func TestLoad_OverridesEnvironment(t *testing.T) {
+	t.Setenv("CONFIG_TEST_KEY", "from-env")
+	path := filepath.Join(t.TempDir(), "server.env")
+	writeConfig(t, path, "CONFIG_TEST_KEY=from-file\n")
+
+	if err := Load(path); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := os.Getenv("CONFIG_TEST_KEY"); got != "from-file" {
+		t.Errorf("CONFIG_TEST_KEY = %q, want from-file", got)
+	}
+}
+
+func TestReloader_RunsAllAppliers(t *testing.T) {
+	t.Setenv("CONFIG_TEST_KEY", "")
+	path := filepath.Join(t.TempDir(), "server.env")
+	writeConfig(t, path, "CONFIG_TEST_KEY=first\n")
+
+	reloader := NewReloader(path)
+	var seen []string
+	reloader.OnReload(func() error {
+		return errors.New("broken setting")
+	})
+	reloader.OnReload(func() error {
+		seen = append(seen, os.Getenv("CONFIG_TEST_KEY"))
+		return nil
+	})
+
+	if err := reloader.Reload(); err == nil {
+		t.Error("expected applier error to be reported")
+	}
+	if len(seen) != 1 || seen[0] != "first" {
+		t.Errorf("expected second applier to run with file values, got %v", seen)
+	}
+}
+
+func TestReloader_WithoutFile(t *testing.T) {
+	reloader := NewReloader("")
+	calls := 0
+	reloader.OnReload(func() error {
+		calls++
+		return nil
+	})
+
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected applier to run once, got %d", calls)
+	}
+}
+
+func TestReloader_WatchReloadsOnChange(t *testing.T) {
+	t.Setenv("CONFIG_TEST_KEY", "")
+	path := filepath.Join(t.TempDir(), "server.env")
+	writeConfig(t, path, "CONFIG_TEST_KEY=first\n")
+
+	reloader := NewReloader(path)
+	reloaded := make(chan string, 1)
+	reloader.OnReload(func() error {
+		reloaded <- os.Getenv("CONFIG_TEST_KEY")
+		return nil
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go reloader.Watch(10*time.Millisecond, stop)
+
+	writeConfig(t, path, "CONFIG_TEST_KEY=second\n")
+	future := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("failed to touch config: %v", err)
+	}
+
+	select {
+	case value := <-reloaded:
+		if value != "second" {
+			t.Errorf("reloaded value = %q, want second", value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("config change was not picked up")
+	}
+}
+
+func TestBasePathFromEnv(t *testing.T) {
+	for value, want := range map[string]string{
+		"":               "",
+		"/":              "",
+		"excalidraw":     "/excalidraw",
+		"/excalidraw/":   "/excalidraw",
+		"/tools/draw":    "/tools/draw",
+		" /excalidraw  ": "/excalidraw",
+	} {
+		t.Setenv("BASE_PATH", value)
+		got, err := BasePathFromEnv()
+		if err != nil || got != want {
+			t.Errorf("BasePathFromEnv(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"/{room}", "/a//b", "/draw?x", "/*"} {
+		t.Setenv("BASE_PATH", value)
+		if _, err := BasePathFromEnv(); err == nil {
+			t.Errorf("BasePathFromEnv(%q) should fail", value)
+		}
+	}
+}
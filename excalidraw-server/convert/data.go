@@ -0,0 +1,256 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// DataNode is a node of a JSON graph or tree. Nodes of a tree nest
+	// their Children; an ID is only needed to link to them from edges.
+	DataNode struct {
+		ID              string     `json:"id"`
+		Label           string     `json:"label"`
+		Shape           string     `json:"shape"`
+		Rounded         bool       `json:"rounded"`
+		StrokeColor     string     `json:"stroke_color"`
+		BackgroundColor string     `json:"background_color"`
+		FontColor       string     `json:"font_color"`
+		StrokeStyle     string     `json:"stroke_style"`
+		Group           string     `json:"group"`
+		Children        []DataNode `json:"children"`
+	}
+
+	DataEdge struct {
+		From        string `json:"from"`
+		To          string `json:"to"`
+		Label       string `json:"label"`
+		StrokeColor string `json:"stroke_color"`
+		StrokeStyle string `json:"stroke_style"`
+		// Arrowhead names the end's arrowhead, arrow by default and none
+		// for a plain connector
+		Arrowhead string `json:"arrowhead"`
+	}
+
+	// dataGraph is a JSON graph; a JSON tree is a single DataNode.
+	dataGraph struct {
+		Nodes []DataNode `json:"nodes"`
+		Edges []DataEdge `json:"edges"`
+	}
+)
+
+// DiagramFromJSON reads a graph, {"nodes": [...], "edges": [...]}, or a
+// tree, {"label": ..., "children": [...]}. Edges may refer to nodes that
+// aren't listed, which are then labeled with their id.
+func DiagramFromJSON(data []byte) (Diagram, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Diagram{}, fmt.Errorf("%w: %v", ErrInvalidDiagram, err)
+	}
+	_, hasNodes := fields["nodes"]
+	_, hasEdges := fields["edges"]
+
+	g := newDataGraphBuilder()
+	if hasNodes || hasEdges {
+		var graph dataGraph
+		if err := json.Unmarshal(data, &graph); err != nil {
+			return Diagram{}, fmt.Errorf("%w: %v", ErrInvalidDiagram, err)
+		}
+		for _, node := range graph.Nodes {
+			if node.ID == "" {
+				return Diagram{}, fmt.Errorf("%w: nodes need an id", ErrInvalidDiagram)
+			}
+			if err := g.node(node); err != nil {
+				return Diagram{}, err
+			}
+		}
+		for _, edge := range graph.Edges {
+			if edge.From == "" || edge.To == "" {
+				return Diagram{}, fmt.Errorf("%w: edges need a from and a to", ErrInvalidDiagram)
+			}
+			g.edge(edge)
+		}
+		return g.diagram, nil
+	}
+
+	var root DataNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return Diagram{}, fmt.Errorf("%w: %v", ErrInvalidDiagram, err)
+	}
+	if root.Label == "" && root.ID == "" {
+		return Diagram{}, fmt.Errorf("%w: expected nodes and edges, or a tree with a label", ErrInvalidDiagram)
+	}
+	if err := g.tree(root, ""); err != nil {
+		return Diagram{}, err
+	}
+	return g.diagram, nil
+}
+
+// DiagramFromCSV reads an adjacency list with from, to and optional label
+// columns, or a tree with id, parent and optional label columns. A header
+// row names the columns; without one the columns are from, to and label.
+func DiagramFromCSV(data []byte) (Diagram, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return Diagram{}, fmt.Errorf("%w: %v", ErrInvalidDiagram, err)
+	}
+	if len(rows) == 0 {
+		return Diagram{}, fmt.Errorf("%w: empty CSV", ErrInvalidDiagram)
+	}
+
+	columns := map[string]int{"from": 0, "to": 1, "label": 2}
+	header := make(map[string]int)
+	for i, cell := range rows[0] {
+		switch name := strings.ToLower(strings.TrimSpace(cell)); name {
+		case "source":
+			header["from"] = i
+		case "target":
+			header["to"] = i
+		case "name":
+			header["id"] = i
+		case "from", "to", "id", "parent", "label":
+			header[name] = i
+		}
+	}
+	if len(header) > 0 {
+		columns = header
+		rows = rows[1:]
+	}
+	cell := func(row []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	g := newDataGraphBuilder()
+	if _, tree := columns["parent"]; tree {
+		if _, ok := columns["id"]; !ok {
+			return Diagram{}, fmt.Errorf("%w: a parent column needs an id column", ErrInvalidDiagram)
+		}
+		for line, row := range rows {
+			id := cell(row, "id")
+			if id == "" {
+				return Diagram{}, fmt.Errorf("%w: row %d has no id", ErrInvalidDiagram, line+1)
+			}
+			if err := g.node(DataNode{ID: id, Label: cell(row, "label")}); err != nil {
+				return Diagram{}, err
+			}
+			if parent := cell(row, "parent"); parent != "" {
+				g.edge(DataEdge{From: parent, To: id})
+			}
+		}
+		return g.diagram, nil
+	}
+
+	if _, ok := columns["from"]; !ok {
+		return Diagram{}, fmt.Errorf("%w: expected from and to, or id and parent columns", ErrInvalidDiagram)
+	}
+	for line, row := range rows {
+		from, to := cell(row, "from"), cell(row, "to")
+		if from == "" {
+			return Diagram{}, fmt.Errorf("%w: row %d has no from", ErrInvalidDiagram, line+1)
+		}
+		// A row without a to declares a node on its own
+		g.ensure(from)
+		if to != "" {
+			g.edge(DataEdge{From: from, To: to, Label: cell(row, "label")})
+		}
+	}
+	return g.diagram, nil
+}
+
+type dataGraphBuilder struct {
+	diagram Diagram
+	index   map[string]int
+	// declared marks nodes listed on their own, as opposed to those made
+	// up for edges
+	declared map[string]bool
+}
+
+func newDataGraphBuilder() *dataGraphBuilder {
+	return &dataGraphBuilder{index: make(map[string]int), declared: make(map[string]bool)}
+}
+
+func (g *dataGraphBuilder) node(node DataNode) error {
+	if g.declared[node.ID] {
+		return fmt.Errorf("%w: duplicate node %q", ErrInvalidDiagram, node.ID)
+	}
+	g.declared[node.ID] = true
+	n := DiagramNode{
+		ID:              node.ID,
+		Label:           firstNonEmpty(node.Label, node.ID),
+		Shape:           node.Shape,
+		Rounded:         node.Rounded,
+		StrokeColor:     node.StrokeColor,
+		BackgroundColor: node.BackgroundColor,
+		FontColor:       node.FontColor,
+		StrokeStyle:     node.StrokeStyle,
+	}
+	if node.Group != "" {
+		n.Groups = []string{node.Group}
+	}
+	if i, ok := g.index[node.ID]; ok {
+		// An earlier edge made it up
+		g.diagram.Nodes[i] = n
+		return nil
+	}
+	g.index[node.ID] = len(g.diagram.Nodes)
+	g.diagram.Nodes = append(g.diagram.Nodes, n)
+	return nil
+}
+
+// ensure makes up a node for an id that isn't listed.
+func (g *dataGraphBuilder) ensure(id string) {
+	if _, ok := g.index[id]; !ok {
+		g.index[id] = len(g.diagram.Nodes)
+		g.diagram.Nodes = append(g.diagram.Nodes, DiagramNode{ID: id, Label: id})
+	}
+}
+
+func (g *dataGraphBuilder) edge(edge DataEdge) {
+	g.ensure(edge.From)
+	g.ensure(edge.To)
+	arrowhead := edge.Arrowhead
+	switch arrowhead {
+	case "":
+		arrowhead = "arrow"
+	case "none":
+		arrowhead = ""
+	}
+	g.diagram.Edges = append(g.diagram.Edges, DiagramEdge{
+		From:         edge.From,
+		To:           edge.To,
+		Label:        edge.Label,
+		EndArrowhead: arrowhead,
+		StrokeColor:  edge.StrokeColor,
+		StrokeStyle:  edge.StrokeStyle,
+	})
+}
+
+// tree adds node and its descendants, each with an edge from its parent;
+// nodes without an id are numbered.
+func (g *dataGraphBuilder) tree(node DataNode, parent string) error {
+	if node.ID == "" {
+		node.ID = "node-" + strconv.Itoa(len(g.diagram.Nodes)+1)
+	}
+	if err := g.node(node); err != nil {
+		return err
+	}
+	if parent != "" {
+		g.edge(DataEdge{From: parent, To: node.ID})
+	}
+	for _, child := range node.Children {
+		if err := g.tree(child, node.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
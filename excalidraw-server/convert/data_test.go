@@ -0,0 +1,78 @@
+package convert
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiagramFromJSON(t *testing.T) {
+	d, err := DiagramFromJSON([]byte(`{
+		"nodes": [{"id": "start", "shape": "ellipse"}, {"id": "ok", "label": "OK?", "shape": "diamond", "group": "checks"}],
+		"edges": [{"from": "start", "to": "ok"}, {"from": "ok", "to": "done", "label": "yes", "arrowhead": "none"}]
+	}`))
+	if err != nil {
+		t.Fatalf("DiagramFromJSON() failed: %v", err)
+	}
+	if len(d.Nodes) != 3 || d.Nodes[0].Label != "start" || d.Nodes[1].Label != "OK?" || d.Nodes[2].ID != "done" {
+		t.Errorf("nodes = %+v", d.Nodes)
+	}
+	if len(d.Edges) != 2 || d.Edges[0].EndArrowhead != "arrow" || d.Edges[1].EndArrowhead != "" || d.Edges[1].Label != "yes" {
+		t.Errorf("edges = %+v", d.Edges)
+	}
+
+	tree, err := DiagramFromJSON([]byte(`{"label": "CEO", "children": [{"label": "CTO", "children": [{"label": "Dev"}]}, {"label": "CFO"}]}`))
+	if err != nil {
+		t.Fatalf("DiagramFromJSON() of a tree failed: %v", err)
+	}
+	if len(tree.Nodes) != 4 || len(tree.Edges) != 3 || tree.Edges[0].From != tree.Nodes[0].ID {
+		t.Errorf("tree = %+v", tree)
+	}
+
+	for name, data := range map[string]string{
+		"not json":        `digraph {}`,
+		"no tree label":   `{"children": []}`,
+		"node without id": `{"nodes": [{"label": "A"}]}`,
+		"duplicate":       `{"nodes": [{"id": "a"}, {"id": "a"}]}`,
+		"edge without to": `{"edges": [{"from": "a"}]}`,
+	} {
+		if _, err := DiagramFromJSON([]byte(data)); !errors.Is(err, ErrInvalidDiagram) {
+			t.Errorf("%s: err = %v, want ErrInvalidDiagram", name, err)
+		}
+	}
+}
+
+func TestDiagramFromCSV(t *testing.T) {
+	d, err := DiagramFromCSV([]byte("a,b,calls\nb,c\nd\n"))
+	if err != nil {
+		t.Fatalf("DiagramFromCSV() failed: %v", err)
+	}
+	if len(d.Nodes) != 4 || len(d.Edges) != 2 || d.Edges[0].Label != "calls" {
+		t.Errorf("adjacency list = %+v", d)
+	}
+
+	tree, err := DiagramFromCSV([]byte("name,parent,label\nceo,,Jane\ncto,ceo,Bob\n"))
+	if err != nil {
+		t.Fatalf("DiagramFromCSV() of a tree failed: %v", err)
+	}
+	if len(tree.Nodes) != 2 || tree.Nodes[0].Label != "Jane" || len(tree.Edges) != 1 || tree.Edges[0].From != "ceo" {
+		t.Errorf("tree = %+v", tree)
+	}
+
+	if _, err := DiagramFromCSV([]byte("parent,label\na,b\n")); !errors.Is(err, ErrInvalidDiagram) {
+		t.Errorf("parent without id: err = %v, want ErrInvalidDiagram", err)
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	d := Diagram{
+		Nodes: []DiagramNode{{ID: "a"}, {ID: "b", Shape: "ellipse", StrokeColor: "red"}},
+		Edges: []DiagramEdge{{From: "a", To: "b"}},
+	}
+	d.SetDefaults(DiagramNode{Shape: "diamond", StrokeColor: "blue"}, DiagramEdge{StrokeStyle: "dashed"})
+	if d.Nodes[0].Shape != "diamond" || d.Nodes[0].StrokeColor != "blue" || d.Nodes[1].Shape != "ellipse" || d.Nodes[1].StrokeColor != "red" {
+		t.Errorf("nodes = %+v", d.Nodes)
+	}
+	if d.Edges[0].StrokeStyle != "dashed" {
+		t.Errorf("edges = %+v", d.Edges)
+	}
+}
@@ -0,0 +1,239 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// MaxDiagramNodes and MaxDiagramEdges bound what Diagram lays out.
+	MaxDiagramNodes = 1000
+	MaxDiagramEdges = 5000
+
+	nodePadding = 20
+)
+
+// ErrInvalidDiagram is returned for diagrams that can't be laid out.
+var ErrInvalidDiagram = errors.New("invalid diagram")
+
+type (
+	// Diagram is a graph of labeled nodes and edges, laid out automatically
+	// when it's turned into a scene.
+	Diagram struct {
+		// Direction is where edges point: TB (down, the default), BT, LR or
+		// RL
+		Direction string
+		Nodes     []DiagramNode
+		Edges     []DiagramEdge
+		// Roughness, FontFamily and FontSize apply to every element; zero
+		// values use the clean style, FontFamilyHand and DefaultFontSize.
+		// Edge labels are a size smaller.
+		Roughness  int
+		FontFamily int
+		FontSize   float64
+		// NodeSpacing and RankSpacing are the gaps between nodes in a rank
+		// and between ranks; zero uses 40 and 80.
+		NodeSpacing float64
+		RankSpacing float64
+	}
+
+	DiagramNode struct {
+		ID    string
+		Label string
+		// Shape is rectangle (the default), ellipse, diamond, text for a
+		// label without a shape, or point for a small dot
+		Shape   string
+		Rounded bool
+		// Empty colors and styles use the defaults: a dark outline, no fill
+		// and text in the outline's color
+		StrokeColor     string
+		BackgroundColor string
+		FontColor       string
+		StrokeStyle     string
+		// Groups are the ids of the groups the node is in, innermost first
+		Groups []string
+	}
+
+	DiagramEdge struct {
+		From  string
+		To    string
+		Label string
+		// StartArrowhead and EndArrowhead name Excalidraw arrowheads such as
+		// arrow or triangle; empty for none
+		StartArrowhead string
+		EndArrowhead   string
+		StrokeColor    string
+		StrokeStyle    string
+	}
+)
+
+// Scene lays the diagram out and draws it: nodes as shapes with their
+// label bound inside, edges as arrows bound to the nodes they connect.
+func (d Diagram) Scene() (Scene, error) {
+	if len(d.Nodes) > MaxDiagramNodes || len(d.Edges) > MaxDiagramEdges {
+		return Scene{}, fmt.Errorf("%w: more than %d nodes or %d edges", ErrInvalidDiagram, MaxDiagramNodes, MaxDiagramEdges)
+	}
+	index := make(map[string]int, len(d.Nodes))
+	for i, node := range d.Nodes {
+		if _, dup := index[node.ID]; dup {
+			return Scene{}, fmt.Errorf("%w: duplicate node %q", ErrInvalidDiagram, node.ID)
+		}
+		index[node.ID] = i
+	}
+	edges := make([][2]int, len(d.Edges))
+	for i, edge := range d.Edges {
+		from, okFrom := index[edge.From]
+		to, okTo := index[edge.To]
+		if !okFrom || !okTo {
+			return Scene{}, fmt.Errorf("%w: edge from %q to %q has an unknown end", ErrInvalidDiagram, edge.From, edge.To)
+		}
+		edges[i] = [2]int{from, to}
+	}
+
+	b := NewBuilder()
+	b.Roughness = d.Roughness
+	if d.FontFamily != 0 {
+		b.FontFamily = d.FontFamily
+	}
+	fontSize := d.FontSize
+	if fontSize <= 0 {
+		fontSize = DefaultFontSize
+	}
+
+	sizes := make([]Point, len(d.Nodes))
+	for i, node := range d.Nodes {
+		sizes[i] = d.nodeSize(b, node, fontSize)
+	}
+	l := layout{direction: d.Direction, nodeGap: d.NodeSpacing, rankGap: d.RankSpacing}
+	if l.nodeGap <= 0 {
+		l.nodeGap = 40
+	}
+	if l.rankGap <= 0 {
+		l.rankGap = 80
+	}
+	placed := l.place(sizes, edges)
+
+	// Groups get fresh ids, so pasting a diagram twice doesn't merge them
+	groupIDs := make(map[string]string)
+	elements := make([]*Element, len(d.Nodes))
+	for i, node := range d.Nodes {
+		center, size := placed.centers[i], sizes[i]
+		x, y := center[0]-size[0]/2, center[1]-size[1]/2
+		groups := make([]string, len(node.Groups))
+		for j, group := range node.Groups {
+			if groupIDs[group] == "" {
+				groupIDs[group] = newID()
+			}
+			groups[j] = groupIDs[group]
+		}
+
+		var el *Element
+		switch node.Shape {
+		case "text":
+			el = b.Text(x, y, node.Label, fontSize)
+			el.TextAlign = "center"
+			el.StrokeColor = firstNonEmpty(node.FontColor, node.StrokeColor, el.StrokeColor)
+			el.GroupIDs = groups
+			elements[i] = el
+			continue
+		case "point":
+			el = b.Shape("ellipse", x, y, size[0], size[1])
+			el.BackgroundColor = firstNonEmpty(node.StrokeColor, el.StrokeColor)
+		case "ellipse", "diamond":
+			el = b.Shape(node.Shape, x, y, size[0], size[1])
+		default:
+			el = b.Shape("rectangle", x, y, size[0], size[1])
+			if node.Rounded {
+				el.Roundness = &Roundness{Type: 3}
+			}
+		}
+		el.StrokeColor = firstNonEmpty(node.StrokeColor, el.StrokeColor)
+		el.BackgroundColor = firstNonEmpty(node.BackgroundColor, el.BackgroundColor)
+		el.StrokeStyle = firstNonEmpty(node.StrokeStyle, el.StrokeStyle)
+		el.GroupIDs = groups
+		elements[i] = el
+		if node.Label != "" && node.Shape != "point" {
+			text := b.BoundText(el, node.Label, fontSize)
+			text.StrokeColor = firstNonEmpty(node.FontColor, el.StrokeColor)
+		}
+	}
+
+	for i, edge := range d.Edges {
+		from, to := elements[edges[i][0]], elements[edges[i][1]]
+		var points []Point
+		if from == to {
+			// A small loop off the node's right side
+			right, cy, h := from.X+from.Width, from.Y+from.Height/2, from.Height/4
+			points = []Point{{right, cy - h}, {right + 30, cy - h}, {right + 30, cy + h}, {right, cy + h}}
+		} else {
+			bends := placed.bends[i]
+			first, last := Center(to), Center(from)
+			if len(bends) > 0 {
+				first, last = bends[0], bends[len(bends)-1]
+			}
+			points = append(points, Clip(from, Center(from), first))
+			points = append(points, bends...)
+			points = append(points, Clip(to, Center(to), last))
+		}
+
+		el := b.Arrow(points, from, to)
+		if len(points) == 2 {
+			el.Roundness = nil
+		}
+		el.StartArrowhead, el.EndArrowhead = nil, nil
+		if edge.StartArrowhead != "" {
+			el.StartArrowhead = Arrowhead(edge.StartArrowhead)
+		}
+		if edge.EndArrowhead != "" {
+			el.EndArrowhead = Arrowhead(edge.EndArrowhead)
+		}
+		el.StrokeColor = firstNonEmpty(edge.StrokeColor, el.StrokeColor)
+		el.StrokeStyle = firstNonEmpty(edge.StrokeStyle, el.StrokeStyle)
+		if edge.Label != "" {
+			b.BoundText(el, edge.Label, math.Round(fontSize*0.8))
+		}
+	}
+	return NewScene(b.Elements()), nil
+}
+
+// SetDefaults styles the nodes and edges that don't set a shape, color or
+// stroke style of their own like node and edge.
+func (d *Diagram) SetDefaults(node DiagramNode, edge DiagramEdge) {
+	for i := range d.Nodes {
+		n := &d.Nodes[i]
+		if n.Shape == "" {
+			n.Shape = node.Shape
+			n.Rounded = n.Rounded || node.Rounded
+		}
+		n.StrokeColor = firstNonEmpty(n.StrokeColor, node.StrokeColor)
+		n.BackgroundColor = firstNonEmpty(n.BackgroundColor, node.BackgroundColor)
+		n.FontColor = firstNonEmpty(n.FontColor, node.FontColor)
+		n.StrokeStyle = firstNonEmpty(n.StrokeStyle, node.StrokeStyle)
+	}
+	for i := range d.Edges {
+		e := &d.Edges[i]
+		e.StrokeColor = firstNonEmpty(e.StrokeColor, edge.StrokeColor)
+		e.StrokeStyle = firstNonEmpty(e.StrokeStyle, edge.StrokeStyle)
+	}
+}
+
+// nodeSize fits a node's shape around its label.
+func (d Diagram) nodeSize(b *Builder, node DiagramNode, fontSize float64) Point {
+	if node.Shape == "point" {
+		return Point{12, 12}
+	}
+	w, h := b.measure(node.Label, fontSize)
+	if node.Shape == "text" {
+		return Point{w, h}
+	}
+	w, h = math.Max(w+2*nodePadding, 120), math.Max(h+2*nodePadding, 60)
+	switch node.Shape {
+	case "ellipse":
+		// The inscribed rectangle of an ellipse is 1/√2 of its size
+		w, h = w*math.Sqrt2, h*math.Sqrt2
+	case "diamond":
+		w, h = w*1.6, h*1.6
+	}
+	return Point{math.Round(w), math.Round(h)}
+}
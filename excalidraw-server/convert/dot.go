@@ -0,0 +1,639 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidDOT is returned for text that isn't a Graphviz DOT graph.
+var ErrInvalidDOT = errors.New("invalid DOT graph")
+
+// FromDOT lays out a Graphviz DOT graph and draws it with nodes as shapes
+// and edges as arrows, both with bound text labels. Node shapes, colors,
+// fill and line styles, arrow directions and rankdir carry over;
+// subgraphs named cluster* become groups. Invisible nodes and edges are
+// left out, and ports and positions are ignored since the graph is laid
+// out from scratch.
+func FromDOT(data []byte) (Scene, error) {
+	diagram, err := parseDOT(string(data))
+	if err != nil {
+		return Scene{}, err
+	}
+	return diagram.Scene()
+}
+
+// dotToken kinds: an ID, an edge operator, or the punctuation itself.
+const (
+	dotID   = 'i'
+	dotEdge = '-'
+	dotEOF  = 0
+)
+
+type dotToken struct {
+	kind byte
+	text string
+	// html marks <...> IDs, whose text is an HTML label
+	html bool
+	line int
+}
+
+type dotParser struct {
+	tokens   []dotToken
+	pos      int
+	directed bool
+	name     string
+
+	diagram Diagram
+	// nodes indexes diagram.Nodes, attrs and edges hold the attributes
+	// of each node and edge, and mentions lists node ids as they're used
+	nodes    map[string]int
+	attrs    []map[string]string
+	edges    []map[string]string
+	mentions []string
+}
+
+// dotScope holds the defaults set by node and edge statements, which last
+// until the end of the enclosing subgraph.
+type dotScope struct {
+	node   map[string]string
+	edge   map[string]string
+	groups []string
+}
+
+func (s dotScope) child(groups []string) dotScope {
+	return dotScope{node: copyAttrs(s.node), edge: copyAttrs(s.edge), groups: groups}
+}
+
+func copyAttrs(attrs map[string]string) map[string]string {
+	c := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		c[k] = v
+	}
+	return c
+}
+
+func parseDOT(src string) (Diagram, error) {
+	tokens, err := lexDOT(src)
+	if err != nil {
+		return Diagram{}, err
+	}
+	p := &dotParser{tokens: tokens, nodes: make(map[string]int)}
+	if err := p.graph(); err != nil {
+		return Diagram{}, err
+	}
+	p.finish()
+	return p.diagram, nil
+}
+
+func (p *dotParser) peek() dotToken {
+	return p.tokens[p.pos]
+}
+
+func (p *dotParser) next() dotToken {
+	t := p.tokens[p.pos]
+	if t.kind != dotEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *dotParser) keyword(t dotToken, word string) bool {
+	return t.kind == dotID && !t.html && strings.EqualFold(t.text, word)
+}
+
+func (p *dotParser) errorf(t dotToken, format string, args ...any) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidDOT, t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *dotParser) expect(kind byte) error {
+	if t := p.next(); t.kind != kind {
+		return p.errorf(t, "expected %q", kind)
+	}
+	return nil
+}
+
+func (p *dotParser) graph() error {
+	if p.keyword(p.peek(), "strict") {
+		p.next()
+	}
+	switch t := p.next(); {
+	case p.keyword(t, "digraph"):
+		p.directed = true
+	case p.keyword(t, "graph"):
+	default:
+		return p.errorf(t, "expected graph or digraph")
+	}
+	if p.peek().kind == dotID {
+		p.name = p.next().text
+	}
+	if err := p.expect('{'); err != nil {
+		return err
+	}
+	scope := dotScope{node: map[string]string{}, edge: map[string]string{}}
+	if err := p.statements(scope); err != nil {
+		return err
+	}
+	return p.expect('}')
+}
+
+func (p *dotParser) statements(scope dotScope) error {
+	for {
+		t := p.peek()
+		if t.kind == '}' || t.kind == dotEOF {
+			return nil
+		}
+		if err := p.statement(&scope); err != nil {
+			return err
+		}
+		if k := p.peek().kind; k == ';' || k == ',' {
+			p.next()
+		}
+	}
+}
+
+func (p *dotParser) statement(scope *dotScope) error {
+	t := p.peek()
+	switch {
+	case p.keyword(t, "graph") || p.keyword(t, "node") || p.keyword(t, "edge"):
+		p.next()
+		attrs, err := p.attrList()
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(t.text) {
+		case "graph":
+			p.graphAttrs(attrs)
+		case "node":
+			for k, v := range attrs {
+				scope.node[k] = v
+			}
+		case "edge":
+			for k, v := range attrs {
+				scope.edge[k] = v
+			}
+		}
+		return nil
+	case t.kind == dotID && p.tokens[p.pos+1].kind == '=':
+		p.next()
+		p.next()
+		value := p.next()
+		if value.kind != dotID {
+			return p.errorf(value, "expected a value for %s", t.text)
+		}
+		p.graphAttrs(map[string]string{t.text: value.text})
+		return nil
+	}
+
+	operand, err := p.operand(scope)
+	if err != nil {
+		return err
+	}
+	if p.peek().kind != dotEdge {
+		// A node statement, or a subgraph on its own
+		if t.kind == dotID && !p.keyword(t, "subgraph") {
+			attrs, err := p.attrList()
+			if err != nil {
+				return err
+			}
+			for k, v := range attrs {
+				p.attrs[p.nodes[operand[0]]][k] = v
+			}
+		}
+		return nil
+	}
+
+	operands := [][]string{operand}
+	for p.peek().kind == dotEdge {
+		p.next()
+		operand, err := p.operand(scope)
+		if err != nil {
+			return err
+		}
+		operands = append(operands, operand)
+	}
+	attrs, err := p.attrList()
+	if err != nil {
+		return err
+	}
+	edgeAttrs := copyAttrs(scope.edge)
+	for k, v := range attrs {
+		edgeAttrs[k] = v
+	}
+	for i := 1; i < len(operands); i++ {
+		for _, from := range operands[i-1] {
+			for _, to := range operands[i] {
+				p.diagram.Edges = append(p.diagram.Edges, DiagramEdge{From: from, To: to})
+				p.edges = append(p.edges, edgeAttrs)
+			}
+		}
+	}
+	return nil
+}
+
+// operand reads a node id, with an optional port, or a subgraph and
+// returns the nodes it stands for.
+func (p *dotParser) operand(scope *dotScope) ([]string, error) {
+	t := p.peek()
+	if t.kind == '{' || p.keyword(t, "subgraph") {
+		return p.subgraph(scope)
+	}
+	if t.kind != dotID {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	p.next()
+	// Ports place edges on the node's outline, which the layout decides
+	for p.peek().kind == ':' {
+		p.next()
+		if p.next().kind != dotID {
+			return nil, p.errorf(t, "expected a port after %s:", t.text)
+		}
+	}
+	p.node(t.text, scope)
+	return []string{t.text}, nil
+}
+
+func (p *dotParser) subgraph(scope *dotScope) ([]string, error) {
+	groups := scope.groups
+	if p.keyword(p.peek(), "subgraph") {
+		p.next()
+		if t := p.peek(); t.kind == dotID {
+			p.next()
+			if strings.HasPrefix(t.text, "cluster") {
+				groups = append([]string{t.text}, groups...)
+			}
+		}
+	}
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	first := len(p.mentions)
+	if err := p.statements(scope.child(groups)); err != nil {
+		return nil, err
+	}
+	if err := p.expect('}'); err != nil {
+		return nil, err
+	}
+
+	// Edges to a subgraph connect to every node it mentions
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range p.mentions[first:] {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// node declares a node the first time it's mentioned, with the defaults in
+// scope.
+func (p *dotParser) node(id string, scope *dotScope) {
+	p.mentions = append(p.mentions, id)
+	i, ok := p.nodes[id]
+	if !ok {
+		p.nodes[id] = len(p.diagram.Nodes)
+		p.diagram.Nodes = append(p.diagram.Nodes, DiagramNode{ID: id, Groups: scope.groups})
+		p.attrs = append(p.attrs, copyAttrs(scope.node))
+		return
+	}
+	// A node mentioned in a cluster belongs to it
+	if len(scope.groups) > len(p.diagram.Nodes[i].Groups) {
+		p.diagram.Nodes[i].Groups = scope.groups
+	}
+}
+
+// attrList reads any number of [name=value, ...] lists.
+func (p *dotParser) attrList() (map[string]string, error) {
+	attrs := make(map[string]string)
+	for p.peek().kind == '[' {
+		p.next()
+		for p.peek().kind != ']' {
+			name := p.next()
+			if name.kind != dotID {
+				return nil, p.errorf(name, "expected an attribute name")
+			}
+			value := "true"
+			if p.peek().kind == '=' {
+				p.next()
+				v := p.next()
+				if v.kind != dotID {
+					return nil, p.errorf(v, "expected a value for %s", name.text)
+				}
+				value = v.text
+				if v.html {
+					value = htmlText(value)
+				}
+			}
+			attrs[name.text] = value
+			if k := p.peek().kind; k == ',' || k == ';' {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	return attrs, nil
+}
+
+func (p *dotParser) graphAttrs(attrs map[string]string) {
+	if rankdir := strings.ToUpper(attrs["rankdir"]); rankdir != "" {
+		p.diagram.Direction = rankdir
+	}
+}
+
+// finish turns the nodes' and edges' attributes into their style, and
+// leaves out invisible ones.
+func (p *dotParser) finish() {
+	switch p.diagram.Direction {
+	case "TB", "BT", "LR", "RL":
+	default:
+		p.diagram.Direction = "TB"
+	}
+
+	invisible := make(map[string]bool)
+	nodes := p.diagram.Nodes[:0]
+	for i, node := range p.diagram.Nodes {
+		attrs := p.attrs[i]
+		style := dotStyle(attrs["style"])
+		if style["invis"] {
+			invisible[node.ID] = true
+			continue
+		}
+		node.Label = dotLabel(attrs, node.ID, p.name)
+		node.StrokeColor = dotColor(attrs["color"])
+		node.FontColor = dotColor(attrs["fontcolor"])
+		if style["filled"] {
+			node.BackgroundColor = firstNonEmpty(dotColor(attrs["fillcolor"]), node.StrokeColor, "#d3d3d3")
+		}
+		node.StrokeStyle = dotStrokeStyle(style)
+		node.Rounded = style["rounded"]
+
+		switch shape := strings.ToLower(attrs["shape"]); shape {
+		case "", "ellipse", "oval", "circle", "doublecircle", "egg":
+			node.Shape = "ellipse"
+		case "diamond", "mdiamond":
+			node.Shape = "diamond"
+		case "plaintext", "plain", "none", "underline":
+			node.Shape = "text"
+		case "point":
+			node.Shape = "point"
+		case "record", "mrecord":
+			node.Shape = "rectangle"
+			node.Rounded = node.Rounded || shape == "mrecord"
+			node.Label = recordLabel(node.Label)
+		default:
+			node.Shape = "rectangle"
+		}
+		nodes = append(nodes, node)
+	}
+	p.diagram.Nodes = nodes
+
+	edges := p.diagram.Edges[:0]
+	for i, edge := range p.diagram.Edges {
+		attrs := p.edges[i]
+		style := dotStyle(attrs["style"])
+		if style["invis"] || invisible[edge.From] || invisible[edge.To] {
+			continue
+		}
+		edge.Label = dotLabel(map[string]string{"label": firstNonEmpty(attrs["label"], attrs["xlabel"])}, "", p.name)
+		edge.StrokeColor = dotColor(attrs["color"])
+		edge.StrokeStyle = dotStrokeStyle(style)
+
+		dir := strings.ToLower(attrs["dir"])
+		if dir == "" {
+			dir = "none"
+			if p.directed {
+				dir = "forward"
+			}
+		}
+		if dir == "forward" || dir == "both" {
+			edge.EndArrowhead = dotArrowhead(attrs["arrowhead"])
+		}
+		if dir == "back" || dir == "both" {
+			edge.StartArrowhead = dotArrowhead(attrs["arrowtail"])
+		}
+		edges = append(edges, edge)
+	}
+	p.diagram.Edges = edges
+}
+
+func dotStyle(style string) map[string]bool {
+	styles := make(map[string]bool)
+	for _, s := range strings.Split(style, ",") {
+		styles[strings.ToLower(strings.TrimSpace(s))] = true
+	}
+	return styles
+}
+
+func dotStrokeStyle(style map[string]bool) string {
+	switch {
+	case style["dashed"]:
+		return "dashed"
+	case style["dotted"]:
+		return "dotted"
+	}
+	return ""
+}
+
+var dotEscape = regexp.MustCompile(`\\[nlrNGE]`)
+
+// dotLabel returns a label with its escapes expanded; nodes without one
+// show their id.
+func dotLabel(attrs map[string]string, id, graph string) string {
+	label, ok := attrs["label"]
+	if !ok {
+		label = `\N`
+	}
+	label = dotEscape.ReplaceAllStringFunc(label, func(escape string) string {
+		switch escape[1] {
+		case 'N':
+			return id
+		case 'G':
+			return graph
+		case 'E':
+			return ""
+		}
+		return "\n"
+	})
+	return strings.TrimRight(label, "\n")
+}
+
+// recordLabel draws a record's fields as lines.
+func recordLabel(label string) string {
+	fields := strings.FieldsFunc(label, func(r rune) bool { return r == '|' || r == '{' || r == '}' })
+	lines := make([]string, 0, len(fields))
+	for _, field := range fields {
+		// <port> names aren't shown
+		if strings.HasPrefix(strings.TrimSpace(field), "<") {
+			if _, rest, ok := strings.Cut(field, ">"); ok {
+				field = rest
+			}
+		}
+		if field = strings.TrimSpace(field); field != "" {
+			lines = append(lines, field)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var colorName = regexp.MustCompile(`^[a-zA-Z]+$`)
+
+// dotColor returns a Graphviz color as a CSS color: hex colors and X11
+// names, which CSS mostly shares. HSV colors and schemes other than X11
+// give "".
+func dotColor(color string) string {
+	// Color lists draw several colors; the first stands for them
+	color, _, _ = strings.Cut(color, ":")
+	color, _, _ = strings.Cut(color, ";")
+	color = strings.TrimPrefix(strings.TrimSpace(color), "/x11/")
+	switch {
+	case strings.HasPrefix(color, "#") && (len(color) == 7 || len(color) == 9):
+		return strings.ToLower(color[:7])
+	case colorName.MatchString(color):
+		return strings.ToLower(color)
+	}
+	return ""
+}
+
+// dotArrowhead maps a Graphviz arrow shape to an Excalidraw arrowhead.
+func dotArrowhead(shape string) string {
+	switch strings.ToLower(shape) {
+	case "none":
+		return ""
+	case "empty", "onormal":
+		return "triangle_outline"
+	case "dot":
+		return "dot"
+	case "odot":
+		return "circle_outline"
+	case "diamond":
+		return "diamond"
+	case "odiamond", "ediamond":
+		return "diamond_outline"
+	case "tee":
+		return "bar"
+	case "crow":
+		return "crowfoot_many"
+	}
+	return "arrow"
+}
+
+// lexDOT splits DOT source into tokens, dropping comments.
+func lexDOT(src string) ([]dotToken, error) {
+	var tokens []dotToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: line %d: unterminated comment", ErrInvalidDOT, line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case strings.HasPrefix(src[i:], "->") || strings.HasPrefix(src[i:], "--"):
+			tokens = append(tokens, dotToken{kind: dotEdge, text: src[i : i+2], line: line})
+			i += 2
+		case strings.IndexByte("{}[];,=:", c) >= 0:
+			tokens = append(tokens, dotToken{kind: c, text: string(c), line: line})
+			i++
+		case c == '"':
+			// Quoted strings, joined by +
+			var text strings.Builder
+			start := line
+			for {
+				j := i + 1
+				for ; j < len(src) && src[j] != '"'; j++ {
+					if src[j] == '\\' && j+1 < len(src) {
+						if src[j+1] == '"' {
+							text.WriteByte('"')
+							j++
+							continue
+						}
+						if src[j+1] == '\n' {
+							// A backslash continues the line
+							line++
+							j++
+							continue
+						}
+					}
+					if src[j] == '\n' {
+						line++
+					}
+					text.WriteByte(src[j])
+				}
+				if j >= len(src) {
+					return nil, fmt.Errorf("%w: line %d: unterminated string", ErrInvalidDOT, start)
+				}
+				i = j + 1
+				k := i
+				for k < len(src) && strings.IndexByte(" \t\r\n", src[k]) >= 0 {
+					k++
+				}
+				if k < len(src) && src[k] == '+' {
+					k++
+					for k < len(src) && strings.IndexByte(" \t\r\n", src[k]) >= 0 {
+						k++
+					}
+					if k < len(src) && src[k] == '"' {
+						line += strings.Count(src[i:k], "\n")
+						i = k
+						continue
+					}
+				}
+				break
+			}
+			tokens = append(tokens, dotToken{kind: dotID, text: text.String(), line: start})
+		case c == '<':
+			depth, j := 0, i
+			for ; j < len(src); j++ {
+				if src[j] == '<' {
+					depth++
+				} else if src[j] == '>' {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("%w: line %d: unterminated HTML string", ErrInvalidDOT, line)
+			}
+			tokens = append(tokens, dotToken{kind: dotID, text: src[i+1 : j], html: true, line: line})
+			line += strings.Count(src[i:j], "\n")
+			i = j + 1
+		default:
+			r, size := utf8.DecodeRuneInString(src[i:])
+			isID := func(r rune) bool {
+				return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) || r >= utf8.RuneSelf
+			}
+			if !isID(r) && r != '-' {
+				return nil, fmt.Errorf("%w: line %d: unexpected %q", ErrInvalidDOT, line, r)
+			}
+			j := i + size
+			for j < len(src) {
+				r, size := utf8.DecodeRuneInString(src[j:])
+				if !isID(r) {
+					break
+				}
+				j += size
+			}
+			tokens = append(tokens, dotToken{kind: dotID, text: src[i:j], line: line})
+			i = j
+		}
+	}
+	return append(tokens, dotToken{kind: dotEOF, line: line}), nil
+}
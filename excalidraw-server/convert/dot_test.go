@@ -0,0 +1,107 @@
+package convert
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFromDOT(t *testing.T) {
+	src := `digraph deploy {
+		// Services
+		rankdir=LR
+		node [shape=box style="rounded,filled" fillcolor="#e7f5ff"]
+		web [label="Web\napp" color=blue]
+		subgraph cluster_data {
+			db [shape=cylinder]
+			cache [shape=ellipse style=dashed]
+		}
+		web -> { db cache } [label="reads"]
+		db -> web [dir=back arrowtail=dot]
+		hidden [style=invis]
+		web -> hidden
+		web -> web
+	}`
+	scene, err := FromDOT([]byte(src))
+	if err != nil {
+		t.Fatalf("FromDOT() failed: %v", err)
+	}
+	types := byType(scene.Elements)
+	if len(types["rectangle"]) != 2 || len(types["ellipse"]) != 1 || len(types["arrow"]) != 4 {
+		t.Fatalf("elements = %v, want 2 rectangles, an ellipse and 4 arrows", types)
+	}
+
+	byLabel := make(map[string]*Element)
+	for _, text := range types["text"] {
+		if text.ContainerID != nil {
+			for _, el := range scene.Elements {
+				if el.ID == *text.ContainerID && el.Type != "arrow" {
+					byLabel[text.Text] = el
+				}
+			}
+		}
+	}
+	web, db, cache := byLabel["Web\napp"], byLabel["db"], byLabel["cache"]
+	if web == nil || db == nil || cache == nil {
+		t.Fatalf("nodes by label = %v", byLabel)
+	}
+	if web.StrokeColor != "blue" || web.BackgroundColor != "#e7f5ff" || web.Roundness == nil {
+		t.Errorf("web style = %s, %s, %v", web.StrokeColor, web.BackgroundColor, web.Roundness)
+	}
+	if cache.StrokeStyle != "dashed" || cache.BackgroundColor != "transparent" {
+		t.Errorf("cache style = %s, %s, want its own style to replace the defaults", cache.StrokeStyle, cache.BackgroundColor)
+	}
+	if len(db.GroupIDs) != 1 || len(cache.GroupIDs) != 1 || db.GroupIDs[0] != cache.GroupIDs[0] || len(web.GroupIDs) != 0 {
+		t.Errorf("groups = %v, %v, %v, want the cluster grouped", web.GroupIDs, db.GroupIDs, cache.GroupIDs)
+	}
+	// Left to right: web's rank comes before the data stores
+	if web.X+web.Width >= db.X || web.X+web.Width >= cache.X {
+		t.Errorf("web at x %v, db at %v and cache at %v, want web left of both", web.X, db.X, cache.X)
+	}
+
+	arrows := 0
+	for _, arrow := range types["arrow"] {
+		if arrow.StartBinding == nil || arrow.EndBinding == nil {
+			t.Errorf("arrow %+v isn't bound at both ends", arrow.LinearFields)
+		}
+		if arrow.StartBinding.ElementID == db.ID && arrow.EndBinding.ElementID == web.ID {
+			arrows++
+			if arrow.StartArrowhead == nil || *arrow.StartArrowhead != "dot" || arrow.EndArrowhead != nil {
+				t.Errorf("dir=back arrowheads = %v, %v", arrow.StartArrowhead, arrow.EndArrowhead)
+			}
+		}
+	}
+	if arrows != 1 {
+		t.Errorf("found %d arrows from db to web, want 1", arrows)
+	}
+}
+
+func TestLayoutRanks(t *testing.T) {
+	// A chain with a shortcut and a cycle back to the start
+	sizes := []Point{{100, 50}, {100, 50}, {100, 50}}
+	edges := [][2]int{{0, 1}, {1, 2}, {0, 2}, {2, 0}}
+	placed := layout{direction: "TB", nodeGap: 40, rankGap: 80}.place(sizes, edges)
+
+	if !(placed.centers[0][1] < placed.centers[1][1] && placed.centers[1][1] < placed.centers[2][1]) {
+		t.Errorf("centers = %v, want one rank each from top to bottom", placed.centers)
+	}
+	// The shortcut bends around the middle rank; the cycle is drawn upwards
+	if len(placed.bends[2]) != 1 || placed.bends[2][0][0] == placed.centers[1][0] {
+		t.Errorf("shortcut bends = %v, want one beside the middle node at %v", placed.bends[2], placed.centers[1])
+	}
+	if len(placed.bends[3]) != 1 {
+		t.Errorf("back edge bends = %v, want one", placed.bends[3])
+	}
+}
+
+func TestFromDOTInvalid(t *testing.T) {
+	for name, src := range map[string]string{
+		"not dot":     `{"nodes": []}`,
+		"unclosed":    `digraph { a -> b`,
+		"bad string":  `digraph { a [label="oops] }`,
+		"bad comment": `graph { /* a -- b }`,
+	} {
+		if _, err := FromDOT([]byte(src)); !errors.Is(err, ErrInvalidDOT) {
+			t.Errorf("%s: err = %v, want ErrInvalidDOT", name, err)
+		}
+	}
+}
@@ -0,0 +1,515 @@
+package convert
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDrawioSize bounds a compressed draw.io page once inflated.
+const maxDrawioSize = 50 << 20
+
+// ErrInvalidDrawio is returned for files that aren't draw.io diagrams.
+var ErrInvalidDrawio = errors.New("not a draw.io diagram")
+
+type (
+	drawioFile struct {
+		XMLName  xml.Name
+		Diagrams []drawioDiagram `xml:"diagram"`
+		// Root is set when the file is a bare mxGraphModel
+		Root *drawioRoot `xml:"root"`
+	}
+
+	drawioDiagram struct {
+		Name    string       `xml:"name,attr"`
+		Model   *drawioModel `xml:"mxGraphModel"`
+		Content string       `xml:",chardata"`
+	}
+
+	drawioModel struct {
+		Root drawioRoot `xml:"root"`
+	}
+
+	// drawioRoot holds mxCells, some wrapped in UserObject or object
+	// elements that carry their label
+	drawioRoot struct {
+		Items []drawioItem `xml:",any"`
+	}
+
+	drawioItem struct {
+		XMLName xml.Name
+		drawioCell
+		Label string      `xml:"label,attr"`
+		Cell  *drawioCell `xml:"mxCell"`
+	}
+
+	drawioCell struct {
+		ID       string          `xml:"id,attr"`
+		Value    string          `xml:"value,attr"`
+		Style    string          `xml:"style,attr"`
+		Vertex   string          `xml:"vertex,attr"`
+		Edge     string          `xml:"edge,attr"`
+		Parent   string          `xml:"parent,attr"`
+		Source   string          `xml:"source,attr"`
+		Target   string          `xml:"target,attr"`
+		Geometry *drawioGeometry `xml:"mxGeometry"`
+	}
+
+	drawioGeometry struct {
+		X        float64       `xml:"x,attr"`
+		Y        float64       `xml:"y,attr"`
+		Width    float64       `xml:"width,attr"`
+		Height   float64       `xml:"height,attr"`
+		Relative string        `xml:"relative,attr"`
+		Ends     []drawioPoint `xml:"mxPoint"`
+		Points   []drawioPoint `xml:"Array>mxPoint"`
+	}
+
+	drawioPoint struct {
+		X  float64 `xml:"x,attr"`
+		Y  float64 `xml:"y,attr"`
+		As string  `xml:"as,attr"`
+	}
+)
+
+// FromDrawio converts a page of a .drawio file, counting from 0, to a
+// scene. Shapes become rectangles, ellipses or diamonds with their label as
+// bound text, edges become arrows bound to the shapes they connect, and
+// draw.io groups become Excalidraw groups. Images and stencils become
+// rectangles.
+func FromDrawio(data []byte, page int) (Scene, error) {
+	var file drawioFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return Scene{}, fmt.Errorf("%w: %v", ErrInvalidDrawio, err)
+	}
+
+	var root *drawioRoot
+	switch {
+	case file.XMLName.Local == "mxGraphModel" && file.Root != nil:
+		root = file.Root
+	case file.XMLName.Local == "mxfile":
+		if page < 0 || page >= len(file.Diagrams) {
+			return Scene{}, fmt.Errorf("%w: the file has no page %d", ErrInvalidDrawio, page)
+		}
+		diagram := file.Diagrams[page]
+		if diagram.Model == nil {
+			model, err := inflateDiagram(diagram.Content)
+			if err != nil {
+				return Scene{}, err
+			}
+			diagram.Model = model
+		}
+		root = &diagram.Model.Root
+	default:
+		return Scene{}, ErrInvalidDrawio
+	}
+
+	return NewScene(newDrawioConverter(root).convert()), nil
+}
+
+// inflateDiagram decodes a compressed page: the URL-encoded model, deflated
+// and then base64 encoded.
+func inflateDiagram(content string) (*drawioModel, error) {
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid compressed page", ErrInvalidDrawio)
+	}
+	inflated, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(compressed)), maxDrawioSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid compressed page", ErrInvalidDrawio)
+	}
+	if len(inflated) > maxDrawioSize {
+		return nil, fmt.Errorf("%w: page too large", ErrInvalidDrawio)
+	}
+	text := string(inflated)
+	if unescaped, err := url.PathUnescape(text); err == nil {
+		text = unescaped
+	}
+
+	var model drawioModel
+	if err := xml.Unmarshal([]byte(text), &model); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDrawio, err)
+	}
+	return &model, nil
+}
+
+type drawioConverter struct {
+	b     *Builder
+	cells []*drawioCell
+	byID  map[string]*drawioCell
+	// elements by cell id, for binding edges and their labels
+	elements map[string]*Element
+	// origins caches the absolute position of cells' coordinate systems
+	origins map[string]Point
+}
+
+func newDrawioConverter(root *drawioRoot) *drawioConverter {
+	c := &drawioConverter{
+		b:        NewBuilder(),
+		byID:     make(map[string]*drawioCell),
+		elements: make(map[string]*Element),
+		origins:  make(map[string]Point),
+	}
+	c.b.FontFamily = FontFamilySans
+	for i := range root.Items {
+		item := &root.Items[i]
+		cell := &item.drawioCell
+		if item.XMLName.Local != "mxCell" {
+			if item.Cell == nil {
+				continue
+			}
+			// UserObject and object wrap the cell and carry its id and label
+			cell = item.Cell
+			cell.ID = item.ID
+			cell.Value = item.Label
+		}
+		c.cells = append(c.cells, cell)
+		c.byID[cell.ID] = cell
+	}
+	return c
+}
+
+func (c *drawioConverter) convert() []*Element {
+	// Shapes first, so edges can bind to shapes that come later in the file
+	for _, cell := range c.cells {
+		if cell.Vertex == "1" && cell.Geometry != nil && !c.isEdgeLabel(cell) {
+			c.vertex(cell)
+		}
+	}
+	for _, cell := range c.cells {
+		if cell.Edge == "1" {
+			c.edge(cell)
+		}
+	}
+	for _, cell := range c.cells {
+		if cell.Vertex == "1" && c.isEdgeLabel(cell) {
+			c.edgeLabel(cell)
+		}
+	}
+	return c.b.Elements()
+}
+
+// isEdgeLabel reports whether a vertex is a label placed on an edge.
+func (c *drawioConverter) isEdgeLabel(cell *drawioCell) bool {
+	parent := c.byID[cell.Parent]
+	return parent != nil && parent.Edge == "1"
+}
+
+// origin returns the absolute position that a cell's children are
+// relative to; layers and the root are at 0, 0.
+func (c *drawioConverter) origin(id string) Point {
+	if pt, ok := c.origins[id]; ok {
+		return pt
+	}
+	c.origins[id] = Point{} // guards against cycles
+	cell := c.byID[id]
+	var pt Point
+	if cell != nil && cell.Vertex == "1" && cell.Geometry != nil && cell.Geometry.Relative != "1" {
+		parent := c.origin(cell.Parent)
+		pt = Point{parent[0] + cell.Geometry.X, parent[1] + cell.Geometry.Y}
+	}
+	c.origins[id] = pt
+	return pt
+}
+
+// groups returns the ids of the draw.io groups a cell is in, innermost
+// first.
+func (c *drawioConverter) groups(cell *drawioCell) []string {
+	groups := []string{}
+	seen := map[string]bool{}
+	for parent := c.byID[cell.Parent]; parent != nil && !seen[parent.ID]; parent = c.byID[parent.Parent] {
+		seen[parent.ID] = true
+		if _, isGroup := parseStyle(parent.Style)["group"]; isGroup {
+			groups = append(groups, "drawio-"+parent.ID)
+		}
+	}
+	return groups
+}
+
+func (c *drawioConverter) vertex(cell *drawioCell) {
+	style := parseStyle(cell.Style)
+	if _, isGroup := style["group"]; isGroup {
+		return
+	}
+	origin := c.origin(cell.Parent)
+	g := cell.Geometry
+	x, y := origin[0]+g.X, origin[1]+g.Y
+	label := drawioLabel(cell.Value, style)
+	fontSize := styleNumber(style, "fontSize", 12)
+
+	_, isText := style["text"]
+	if isText || style["strokeColor"] == "none" && style["fillColor"] == "none" && label != "" {
+		if label == "" {
+			return
+		}
+		el := c.b.Text(0, 0, label, fontSize)
+		el.GroupIDs = c.groups(cell)
+		el.StrokeColor = color(style["fontColor"], "#1e1e1e")
+		el.Opacity = styleNumber(style, "opacity", 100)
+		switch align := style["align"]; align {
+		case "left", "right":
+			el.TextAlign = align
+		default:
+			el.TextAlign = "center"
+		}
+		switch el.TextAlign {
+		case "left":
+			el.X = x
+		case "right":
+			el.X = x + g.Width - el.Width
+		default:
+			el.X = x + (g.Width-el.Width)/2
+		}
+		el.Y = y + (g.Height-el.Height)/2
+		c.elements[cell.ID] = el
+		return
+	}
+
+	kind := "rectangle"
+	switch shape := firstNonEmpty(style["shape"], styleName(cell.Style)); shape {
+	case "ellipse", "doubleEllipse":
+		kind = "ellipse"
+	case "rhombus":
+		kind = "diamond"
+	}
+	el := c.b.Shape(kind, x, y, g.Width, g.Height)
+	el.GroupIDs = c.groups(cell)
+	applyDrawioStyle(el, style)
+	if kind == "rectangle" && style["rounded"] == "1" {
+		el.Roundness = &Roundness{Type: 3}
+	}
+	c.elements[cell.ID] = el
+
+	if label != "" {
+		text := c.b.BoundText(el, label, fontSize)
+		text.StrokeColor = color(style["fontColor"], "#1e1e1e")
+	}
+}
+
+func (c *drawioConverter) edge(cell *drawioCell) {
+	style := parseStyle(cell.Style)
+	source, target := c.elements[cell.Source], c.elements[cell.Target]
+	// Edges only bind to shapes, not to text
+	if source != nil && source.Type == "text" {
+		source = nil
+	}
+	if target != nil && target.Type == "text" {
+		target = nil
+	}
+
+	origin := c.origin(cell.Parent)
+	var waypoints []Point
+	var sourcePoint, targetPoint *Point
+	if g := cell.Geometry; g != nil {
+		for _, pt := range g.Points {
+			waypoints = append(waypoints, Point{origin[0] + pt.X, origin[1] + pt.Y})
+		}
+		for _, pt := range g.Ends {
+			abs := Point{origin[0] + pt.X, origin[1] + pt.Y}
+			switch pt.As {
+			case "sourcePoint":
+				sourcePoint = &abs
+			case "targetPoint":
+				targetPoint = &abs
+			}
+		}
+	}
+
+	// Ends attached to shapes start on their outline, aimed at the next
+	// point along the edge
+	var start, end Point
+	switch {
+	case source != nil:
+		start = Center(source)
+	case sourcePoint != nil:
+		start = *sourcePoint
+	default:
+		return
+	}
+	switch {
+	case target != nil:
+		end = Center(target)
+	case targetPoint != nil:
+		end = *targetPoint
+	default:
+		return
+	}
+	if source != nil {
+		next := end
+		if len(waypoints) > 0 {
+			next = waypoints[0]
+		}
+		start = Clip(source, start, next)
+	}
+	if target != nil {
+		previous := start
+		if len(waypoints) > 0 {
+			previous = waypoints[len(waypoints)-1]
+		}
+		end = Clip(target, end, previous)
+	}
+	points := append(append([]Point{start}, waypoints...), end)
+
+	el := c.b.Arrow(points, source, target)
+	el.GroupIDs = c.groups(cell)
+	applyDrawioStyle(el, style)
+	el.BackgroundColor = "transparent"
+	el.StartArrowhead = drawioArrowhead(style["startArrow"], "none")
+	el.EndArrowhead = drawioArrowhead(style["endArrow"], "classic")
+	if style["curved"] != "1" {
+		el.Roundness = nil
+	}
+	if el.StartArrowhead == nil && el.EndArrowhead == nil {
+		el.Type = "line"
+	}
+	c.elements[cell.ID] = el
+
+	if label := drawioLabel(cell.Value, style); label != "" && el.Type == "arrow" {
+		text := c.b.BoundText(el, label, styleNumber(style, "fontSize", 11))
+		text.StrokeColor = color(style["fontColor"], "#1e1e1e")
+	}
+}
+
+// edgeLabel adds a label cell of an edge as the arrow's text, when the
+// arrow has none of its own.
+func (c *drawioConverter) edgeLabel(cell *drawioCell) {
+	arrow := c.elements[cell.Parent]
+	style := parseStyle(cell.Style)
+	label := drawioLabel(cell.Value, style)
+	if arrow == nil || arrow.Type != "arrow" || label == "" {
+		return
+	}
+	for _, bound := range arrow.BoundElements {
+		if bound.Type == "text" {
+			return
+		}
+	}
+	text := c.b.BoundText(arrow, label, styleNumber(style, "fontSize", 11))
+	text.StrokeColor = color(style["fontColor"], "#1e1e1e")
+}
+
+func applyDrawioStyle(el *Element, style map[string]string) {
+	el.StrokeColor = color(style["strokeColor"], "#1e1e1e")
+	el.BackgroundColor = color(style["fillColor"], "transparent")
+	if style["strokeColor"] == "none" {
+		el.StrokeColor = "transparent"
+	}
+	el.StrokeWidth = styleNumber(style, "strokeWidth", 1)
+	if style["dashed"] == "1" {
+		el.StrokeStyle = "dashed"
+		if pattern := style["dashPattern"]; strings.HasPrefix(pattern, "1 ") {
+			el.StrokeStyle = "dotted"
+		}
+	}
+	el.Opacity = styleNumber(style, "opacity", 100)
+}
+
+// drawioArrowhead maps a draw.io marker to an Excalidraw arrowhead, nil for
+// none.
+func drawioArrowhead(marker, def string) *string {
+	if marker == "" {
+		marker = def
+	}
+	switch marker {
+	case "none":
+		return nil
+	case "oval", "dash":
+		return Arrowhead("dot")
+	case "diamond", "diamondThin":
+		return Arrowhead("diamond")
+	case "block", "blockThin":
+		return Arrowhead("triangle")
+	case "ERmany", "ERoneToMany", "ERzeroToMany":
+		return Arrowhead("crowfoot_many")
+	case "ERone", "ERmandOne":
+		return Arrowhead("crowfoot_one")
+	case "ERzeroToOne":
+		return Arrowhead("crowfoot_one_or_many")
+	}
+	return Arrowhead("arrow")
+}
+
+// parseStyle splits a draw.io style into its key=value pairs; bare names
+// such as "ellipse" map to "".
+func parseStyle(style string) map[string]string {
+	values := make(map[string]string)
+	for _, part := range strings.Split(style, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if key != "" {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// styleName returns the bare name a style starts with, such as "ellipse".
+func styleName(style string) string {
+	first, _, _ := strings.Cut(style, ";")
+	if strings.Contains(first, "=") {
+		return ""
+	}
+	return strings.TrimSpace(first)
+}
+
+func styleNumber(style map[string]string, key string, def float64) float64 {
+	if value, err := strconv.ParseFloat(style[key], 64); err == nil && value >= 0 {
+		return value
+	}
+	return def
+}
+
+// color returns a draw.io color, or def for none and unset ones.
+func color(value, def string) string {
+	switch {
+	case value == "" || value == "none" || value == "default":
+		return def
+	case strings.HasPrefix(value, "#"):
+		return value
+	}
+	return def
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+var (
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(div|p|li)>`)
+	htmlTag   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// drawioLabel returns a label as plain text; labels with html=1 in their
+// style are HTML.
+func drawioLabel(value string, style map[string]string) string {
+	if style["html"] == "1" {
+		value = htmlText(value)
+	}
+	lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// htmlText returns the text of an HTML label, with line breaks and block
+// ends as newlines.
+func htmlText(value string) string {
+	value = htmlBreak.ReplaceAllString(value, "\n")
+	value = htmlTag.ReplaceAllString(value, "")
+	value = html.UnescapeString(value)
+	return strings.ReplaceAll(value, "\u00a0", " ")
+}
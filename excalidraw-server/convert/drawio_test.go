@@ -0,0 +1,115 @@
+package convert
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+const drawioModelXML = `<mxGraphModel><root>
+	<mxCell id="0"/>
+	<mxCell id="1" parent="0"/>
+	<mxCell id="e" style="endArrow=classic;html=1;" edge="1" parent="1" source="a" target="b" value="calls">
+		<mxGeometry relative="1" as="geometry"/>
+	</mxCell>
+	<mxCell id="a" value="Web&lt;br&gt;app" style="rounded=1;html=1;fillColor=#dae8fc;strokeColor=#6c8ebf;" vertex="1" parent="1">
+		<mxGeometry x="40" y="40" width="120" height="60" as="geometry"/>
+	</mxCell>
+	<UserObject id="b" label="DB">
+		<mxCell style="ellipse;whiteSpace=wrap;" vertex="1" parent="g">
+			<mxGeometry x="200" y="0" width="80" height="80" as="geometry"/>
+		</mxCell>
+	</UserObject>
+	<mxCell id="g" style="group" vertex="1" parent="1">
+		<mxGeometry x="100" y="20" width="300" height="100" as="geometry"/>
+	</mxCell>
+	<mxCell id="t" value="Note" style="text;html=1;" vertex="1" parent="1">
+		<mxGeometry x="0" y="200" width="100" height="20" as="geometry"/>
+	</mxCell>
+</root></mxGraphModel>`
+
+func byType(elements []*Element) map[string][]*Element {
+	types := make(map[string][]*Element)
+	for _, el := range elements {
+		types[el.Type] = append(types[el.Type], el)
+	}
+	return types
+}
+
+func TestFromDrawio(t *testing.T) {
+	scene, err := FromDrawio([]byte(drawioModelXML), 0)
+	if err != nil {
+		t.Fatalf("FromDrawio() failed: %v", err)
+	}
+	types := byType(scene.Elements)
+	if len(types["rectangle"]) != 1 || len(types["ellipse"]) != 1 || len(types["arrow"]) != 1 || len(types["text"]) != 4 {
+		t.Fatalf("elements = %v, want a rectangle, an ellipse, an arrow and 4 texts", types)
+	}
+
+	rect, ellipse, arrow := types["rectangle"][0], types["ellipse"][0], types["arrow"][0]
+	if rect.BackgroundColor != "#dae8fc" || rect.StrokeColor != "#6c8ebf" || rect.Roundness == nil {
+		t.Errorf("rectangle style = %s, %s, %v", rect.BackgroundColor, rect.StrokeColor, rect.Roundness)
+	}
+	// The ellipse is positioned relative to its group
+	if ellipse.X != 300 || ellipse.Y != 20 || len(ellipse.GroupIDs) != 1 {
+		t.Errorf("ellipse at %v, %v in %v, want 300, 20 in one group", ellipse.X, ellipse.Y, ellipse.GroupIDs)
+	}
+	if arrow.StartBinding == nil || arrow.StartBinding.ElementID != rect.ID || arrow.EndBinding == nil || arrow.EndBinding.ElementID != ellipse.ID {
+		t.Errorf("arrow bindings = %+v, %+v", arrow.StartBinding, arrow.EndBinding)
+	}
+	if arrow.X != 160 {
+		t.Errorf("arrow starts at x %v, want the rectangle's right side at 160", arrow.X)
+	}
+
+	labels := make(map[string]*Element)
+	for _, text := range types["text"] {
+		labels[text.Text] = text
+	}
+	for label, container := range map[string]*Element{"Web\napp": rect, "DB": ellipse, "calls": arrow} {
+		text := labels[label]
+		if text == nil || text.ContainerID == nil || *text.ContainerID != container.ID {
+			t.Errorf("label %q = %+v, want text bound to %s", label, text, container.Type)
+		}
+	}
+	if note := labels["Note"]; note == nil || note.ContainerID != nil {
+		t.Errorf("free text = %+v", note)
+	}
+}
+
+func TestFromDrawioCompressed(t *testing.T) {
+	var deflated bytes.Buffer
+	w, _ := flate.NewWriter(&deflated, flate.BestCompression)
+	w.Write([]byte(url.PathEscape(drawioModelXML)))
+	w.Close()
+	file := `<mxfile><diagram name="Empty"><mxGraphModel><root/></mxGraphModel></diagram>` +
+		`<diagram name="Page-2">` + base64.StdEncoding.EncodeToString(deflated.Bytes()) + `</diagram></mxfile>`
+
+	scene, err := FromDrawio([]byte(file), 1)
+	if err != nil {
+		t.Fatalf("FromDrawio() failed: %v", err)
+	}
+	if len(scene.Elements) != 7 {
+		t.Errorf("got %d elements, want 7", len(scene.Elements))
+	}
+	if scene, err := FromDrawio([]byte(file), 0); err != nil || len(scene.Elements) != 0 {
+		t.Errorf("first page = %d elements, %v, want an empty scene", len(scene.Elements), err)
+	}
+
+	for name, data := range map[string]string{
+		"no such page": file,
+		"not xml":      "{}",
+		"svg":          `<svg xmlns="http://www.w3.org/2000/svg"/>`,
+		"bad content":  `<mxfile><diagram>not base64!</diagram></mxfile>`,
+	} {
+		page := 0
+		if name == "no such page" {
+			page = 2
+		}
+		if _, err := FromDrawio([]byte(data), page); !errors.Is(err, ErrInvalidDrawio) {
+			t.Errorf("%s: err = %v, want ErrInvalidDrawio", name, err)
+		}
+	}
+}
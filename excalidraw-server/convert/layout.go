@@ -0,0 +1,362 @@
+package convert
+
+import (
+	"math"
+	"sort"
+)
+
+// layout places a directed graph in layers, the way Graphviz's dot does:
+// cycles are broken by reversing edges, nodes are ranked by longest path,
+// edges spanning several ranks get a bend point in each rank they cross,
+// and the order within ranks is swept with the barycenter heuristic to
+// reduce crossings.
+type layout struct {
+	// direction is TB, BT, LR or RL
+	direction string
+	nodeGap   float64
+	rankGap   float64
+}
+
+type layoutResult struct {
+	// centers are the nodes' centers
+	centers []Point
+	// bends are the points each edge passes through between its ends, in
+	// the edge's direction; self loops have none
+	bends [][]Point
+}
+
+// layoutNode is a node of the layered graph; virtual nodes carry edges
+// through ranks and have no size.
+type layoutNode struct {
+	rank    int
+	breadth float64 // size along the rank
+	depth   float64 // size across ranks
+	virtual bool
+	up      []int
+	down    []int
+	// x is the position along the rank
+	x float64
+}
+
+func (l layout) place(sizes []Point, edges [][2]int) layoutResult {
+	horizontal := l.direction == "LR" || l.direction == "RL"
+	nodes := make([]*layoutNode, len(sizes))
+	for i, size := range sizes {
+		nodes[i] = &layoutNode{breadth: size[0], depth: size[1]}
+		if horizontal {
+			nodes[i].breadth, nodes[i].depth = size[1], size[0]
+		}
+	}
+
+	dag, reversed := acyclic(len(sizes), edges)
+	rank(nodes, dag)
+
+	// Chain long edges through virtual nodes, one per rank crossed
+	chains := make([][]int, len(edges))
+	for e, edge := range dag {
+		if edge[0] == edge[1] {
+			continue
+		}
+		prev := edge[0]
+		for r := nodes[edge[0]].rank + 1; r < nodes[edge[1]].rank; r++ {
+			nodes = append(nodes, &layoutNode{rank: r, virtual: true})
+			v := len(nodes) - 1
+			link(nodes, prev, v)
+			chains[e] = append(chains[e], v)
+			prev = v
+		}
+		link(nodes, prev, edge[1])
+	}
+
+	layers := order(nodes)
+	l.position(nodes, layers)
+
+	// Ranks are as deep as their deepest node
+	rankCenters := make([]float64, len(layers))
+	var offset float64
+	for r, layer := range layers {
+		var depth float64
+		for _, v := range layer {
+			depth = math.Max(depth, nodes[v].depth)
+		}
+		rankCenters[r] = offset + depth/2
+		offset += depth + l.rankGap
+	}
+	total := offset - l.rankGap
+
+	point := func(v int) Point {
+		x, y := nodes[v].x, rankCenters[nodes[v].rank]
+		if l.direction == "BT" || l.direction == "RL" {
+			y = total - y
+		}
+		if horizontal {
+			return Point{y, x}
+		}
+		return Point{x, y}
+	}
+
+	result := layoutResult{centers: make([]Point, len(sizes)), bends: make([][]Point, len(edges))}
+	for i := range sizes {
+		result.centers[i] = point(i)
+	}
+	for e, chain := range chains {
+		for _, v := range chain {
+			result.bends[e] = append(result.bends[e], point(v))
+		}
+		if reversed[e] {
+			for i, j := 0, len(result.bends[e])-1; i < j; i, j = i+1, j-1 {
+				result.bends[e][i], result.bends[e][j] = result.bends[e][j], result.bends[e][i]
+			}
+		}
+	}
+	return result
+}
+
+func link(nodes []*layoutNode, from, to int) {
+	nodes[from].down = append(nodes[from].down, to)
+	nodes[to].up = append(nodes[to].up, from)
+}
+
+// acyclic returns the edges with those closing a cycle reversed, found by
+// a depth-first search in input order.
+func acyclic(n int, edges [][2]int) (dag [][2]int, reversed []bool) {
+	out := make([][]int, n)
+	for e, edge := range edges {
+		out[edge[0]] = append(out[edge[0]], e)
+	}
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := make([]int, n)
+	reversed = make([]bool, len(edges))
+	var visit func(v int)
+	visit = func(v int) {
+		state[v] = onStack
+		for _, e := range out[v] {
+			to := edges[e][1]
+			switch state[to] {
+			case unvisited:
+				visit(to)
+			case onStack:
+				reversed[e] = to != v
+			}
+		}
+		state[v] = done
+	}
+	for v := 0; v < n; v++ {
+		if state[v] == unvisited {
+			visit(v)
+		}
+	}
+
+	dag = make([][2]int, len(edges))
+	for e, edge := range edges {
+		dag[e] = edge
+		if reversed[e] {
+			dag[e] = [2]int{edge[1], edge[0]}
+		}
+	}
+	return dag, reversed
+}
+
+// rank puts every node a rank below all of its predecessors, then moves
+// sources down next to their highest successor so they don't dangle from
+// the top.
+func rank(nodes []*layoutNode, dag [][2]int) {
+	n := len(nodes)
+	out := make([][]int, n)
+	indegree := make([]int, n)
+	for _, edge := range dag {
+		if edge[0] != edge[1] {
+			out[edge[0]] = append(out[edge[0]], edge[1])
+			indegree[edge[1]]++
+		}
+	}
+	sources := make([]bool, n)
+	queue := []int{}
+	for v := 0; v < n; v++ {
+		if indegree[v] == 0 {
+			queue = append(queue, v)
+			sources[v] = true
+		}
+	}
+	topo := make([]int, 0, n)
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		topo = append(topo, v)
+		for _, w := range out[v] {
+			nodes[w].rank = max(nodes[w].rank, nodes[v].rank+1)
+			if indegree[w]--; indegree[w] == 0 {
+				queue = append(queue, w)
+			}
+		}
+	}
+	for i := len(topo) - 1; i >= 0; i-- {
+		v := topo[i]
+		if !sources[v] || len(out[v]) == 0 {
+			continue
+		}
+		lowest := math.MaxInt
+		for _, w := range out[v] {
+			lowest = min(lowest, nodes[w].rank)
+		}
+		nodes[v].rank = lowest - 1
+	}
+}
+
+// order groups nodes into ranks and orders each rank to reduce edge
+// crossings, keeping the best of several up and down sweeps.
+func order(nodes []*layoutNode) [][]int {
+	ranks := 0
+	for _, node := range nodes {
+		ranks = max(ranks, node.rank+1)
+	}
+	layers := make([][]int, ranks)
+	for v, node := range nodes {
+		layers[node.rank] = append(layers[node.rank], v)
+	}
+
+	pos := make([]float64, len(nodes))
+	index := func() {
+		for _, layer := range layers {
+			for i, v := range layer {
+				pos[v] = float64(i)
+			}
+		}
+	}
+	index()
+	best := copyLayers(layers)
+	bestCrossings := crossings(nodes, layers, pos)
+
+	for iter := 0; iter < 16 && bestCrossings > 0; iter++ {
+		down := iter%2 == 0
+		for i := 1; i < ranks; i++ {
+			r := i
+			if !down {
+				r = ranks - 1 - i
+			}
+			layer := layers[r]
+			bary := make(map[int]float64, len(layer))
+			for _, v := range layer {
+				neighbors := nodes[v].up
+				if !down {
+					neighbors = nodes[v].down
+				}
+				if len(neighbors) == 0 {
+					bary[v] = pos[v]
+					continue
+				}
+				var sum float64
+				for _, w := range neighbors {
+					sum += pos[w]
+				}
+				bary[v] = sum / float64(len(neighbors))
+			}
+			sort.SliceStable(layer, func(a, b int) bool { return bary[layer[a]] < bary[layer[b]] })
+			for i, v := range layer {
+				pos[v] = float64(i)
+			}
+		}
+		if c := crossings(nodes, layers, pos); c < bestCrossings {
+			best, bestCrossings = copyLayers(layers), c
+		}
+	}
+	return best
+}
+
+func copyLayers(layers [][]int) [][]int {
+	c := make([][]int, len(layers))
+	for i, layer := range layers {
+		c[i] = append([]int{}, layer...)
+	}
+	return c
+}
+
+// crossings counts the pairs of edges that cross between adjacent ranks.
+func crossings(nodes []*layoutNode, layers [][]int, pos []float64) int {
+	count := 0
+	for _, layer := range layers {
+		var segments [][2]float64
+		for _, v := range layer {
+			for _, w := range nodes[v].down {
+				segments = append(segments, [2]float64{pos[v], pos[w]})
+			}
+		}
+		for i := range segments {
+			for j := i + 1; j < len(segments); j++ {
+				a, b := segments[i], segments[j]
+				if (a[0]-b[0])*(a[1]-b[1]) < 0 {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// position spreads each rank out along its axis, then pulls nodes towards
+// the average position of their neighbors in alternating sweeps.
+func (l layout) position(nodes []*layoutNode, layers [][]int) {
+	gap := func(a, b int) float64 {
+		g := l.nodeGap
+		if nodes[a].virtual || nodes[b].virtual {
+			g /= 2
+		}
+		return (nodes[a].breadth+nodes[b].breadth)/2 + g
+	}
+	for _, layer := range layers {
+		for i := 1; i < len(layer); i++ {
+			nodes[layer[i]].x = nodes[layer[i-1]].x + gap(layer[i-1], layer[i])
+		}
+	}
+
+	for iter := 0; iter < 8; iter++ {
+		down := iter%2 == 0
+		for i := range layers {
+			r := i
+			if !down {
+				r = len(layers) - 1 - i
+			}
+			layer := layers[r]
+			desired := make([]float64, len(layer))
+			for i, v := range layer {
+				neighbors := nodes[v].up
+				if !down {
+					neighbors = nodes[v].down
+				}
+				desired[i] = nodes[v].x
+				if len(neighbors) > 0 {
+					var sum float64
+					for _, w := range neighbors {
+						sum += nodes[w].x
+					}
+					desired[i] = sum / float64(len(neighbors))
+				}
+			}
+			// Packing towards the left and towards the right both keep the
+			// gaps; their average stays closest to where nodes want to be
+			left := append([]float64{}, desired...)
+			for i := 1; i < len(layer); i++ {
+				left[i] = math.Max(left[i], left[i-1]+gap(layer[i-1], layer[i]))
+			}
+			right := append([]float64{}, desired...)
+			for i := len(layer) - 2; i >= 0; i-- {
+				right[i] = math.Min(right[i], right[i+1]-gap(layer[i], layer[i+1]))
+			}
+			for i, v := range layer {
+				nodes[v].x = (left[i] + right[i]) / 2
+			}
+		}
+	}
+
+	minX := math.Inf(1)
+	for _, node := range nodes {
+		minX = math.Min(minX, node.x-node.breadth/2)
+	}
+	for _, node := range nodes {
+		node.x -= minX
+	}
+}
@@ -0,0 +1,365 @@
+// Package convert turns diagrams from other tools into Excalidraw scenes.
+package convert
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"excalidraw-server/export"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// FontFamilyHand, FontFamilySans and FontFamilyMono are Excalidraw's
+	// Virgil, Helvetica and Cascadia fonts, which every version can show.
+	FontFamilyHand = 1
+	FontFamilySans = 2
+	FontFamilyMono = 3
+
+	DefaultFontSize = 20
+	lineHeight      = 1.25
+	// boundTextPadding keeps bound text off its container's outline, as the
+	// editor does
+	boundTextPadding = 5
+)
+
+type (
+	// Element is an Excalidraw element. Text and linear elements carry the
+	// fields of their embedded struct as well.
+	Element struct {
+		ID              string         `json:"id"`
+		Type            string         `json:"type"`
+		X               float64        `json:"x"`
+		Y               float64        `json:"y"`
+		Width           float64        `json:"width"`
+		Height          float64        `json:"height"`
+		Angle           float64        `json:"angle"`
+		StrokeColor     string         `json:"strokeColor"`
+		BackgroundColor string         `json:"backgroundColor"`
+		FillStyle       string         `json:"fillStyle"`
+		StrokeWidth     float64        `json:"strokeWidth"`
+		StrokeStyle     string         `json:"strokeStyle"`
+		Roughness       int            `json:"roughness"`
+		Opacity         float64        `json:"opacity"`
+		GroupIDs        []string       `json:"groupIds"`
+		FrameID         *string        `json:"frameId"`
+		Roundness       *Roundness     `json:"roundness"`
+		Seed            int64          `json:"seed"`
+		Version         int            `json:"version"`
+		VersionNonce    int64          `json:"versionNonce"`
+		IsDeleted       bool           `json:"isDeleted"`
+		BoundElements   []BoundElement `json:"boundElements"`
+		Updated         int64          `json:"updated"`
+		Link            *string        `json:"link"`
+		Locked          bool           `json:"locked"`
+		*TextFields
+		*LinearFields
+	}
+
+	TextFields struct {
+		Text          string  `json:"text"`
+		OriginalText  string  `json:"originalText"`
+		FontSize      float64 `json:"fontSize"`
+		FontFamily    int     `json:"fontFamily"`
+		TextAlign     string  `json:"textAlign"`
+		VerticalAlign string  `json:"verticalAlign"`
+		ContainerID   *string `json:"containerId"`
+		LineHeight    float64 `json:"lineHeight"`
+		AutoResize    bool    `json:"autoResize"`
+	}
+
+	// LinearFields are the fields of lines and arrows; Points are relative
+	// to the element's X and Y.
+	LinearFields struct {
+		Points             []Point  `json:"points"`
+		LastCommittedPoint *Point   `json:"lastCommittedPoint"`
+		StartBinding       *Binding `json:"startBinding"`
+		EndBinding         *Binding `json:"endBinding"`
+		StartArrowhead     *string  `json:"startArrowhead"`
+		EndArrowhead       *string  `json:"endArrowhead"`
+		// Polygon marks a closed line, which the editor fills
+		Polygon bool `json:"polygon,omitempty"`
+	}
+
+	Roundness struct {
+		Type int `json:"type"`
+	}
+
+	BoundElement struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+
+	// Binding attaches an end of an arrow to an element.
+	Binding struct {
+		ElementID string  `json:"elementId"`
+		Focus     float64 `json:"focus"`
+		Gap       float64 `json:"gap"`
+	}
+
+	// Point is an [x, y] pair.
+	Point [2]float64
+
+	// Scene is an .excalidraw file.
+	Scene struct {
+		Type     string         `json:"type"`
+		Version  int            `json:"version"`
+		Source   string         `json:"source"`
+		Elements []*Element     `json:"elements"`
+		AppState AppState       `json:"appState"`
+		Files    map[string]any `json:"files"`
+	}
+
+	AppState struct {
+		ViewBackgroundColor string `json:"viewBackgroundColor"`
+	}
+)
+
+// NewScene wraps elements in an .excalidraw file.
+func NewScene(elements []*Element) Scene {
+	if elements == nil {
+		elements = []*Element{}
+	}
+	return Scene{
+		Type:     "excalidraw",
+		Version:  2,
+		Source:   "excalidraw-server",
+		Elements: elements,
+		AppState: AppState{ViewBackgroundColor: "#ffffff"},
+		Files:    map[string]any{},
+	}
+}
+
+// JSON encodes the scene.
+func (s Scene) JSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Arrowhead returns a pointer to an arrowhead name, for LinearFields.
+func Arrowhead(name string) *string {
+	return &name
+}
+
+// Builder creates elements with Excalidraw's defaults and keeps them in the
+// order they were made.
+type Builder struct {
+	// Roughness and FontFamily apply to elements made afterwards;
+	// NewBuilder starts with the clean "architect" style and
+	// FontFamilyHand.
+	Roughness  int
+	FontFamily int
+
+	elements []*Element
+	now      int64
+}
+
+func NewBuilder() *Builder {
+	return &Builder{FontFamily: FontFamilyHand, now: time.Now().UnixMilli()}
+}
+
+// Elements returns the elements made so far.
+func (b *Builder) Elements() []*Element {
+	return b.elements
+}
+
+func (b *Builder) add(kind string, x, y, width, height float64) *Element {
+	el := &Element{
+		ID:              newID(),
+		Type:            kind,
+		X:               x,
+		Y:               y,
+		Width:           width,
+		Height:          height,
+		StrokeColor:     "#1e1e1e",
+		BackgroundColor: "transparent",
+		FillStyle:       "solid",
+		StrokeWidth:     2,
+		StrokeStyle:     "solid",
+		Roughness:       b.Roughness,
+		Opacity:         100,
+		GroupIDs:        []string{},
+		Seed:            randomInt(),
+		Version:         1,
+		VersionNonce:    randomInt(),
+		BoundElements:   []BoundElement{},
+		Updated:         b.now,
+	}
+	b.elements = append(b.elements, el)
+	return el
+}
+
+// Shape adds a rectangle, diamond or ellipse.
+func (b *Builder) Shape(kind string, x, y, width, height float64) *Element {
+	return b.add(kind, x, y, width, height)
+}
+
+// Text adds a free-standing text element with its top left corner at x, y.
+func (b *Builder) Text(x, y float64, text string, fontSize float64) *Element {
+	if fontSize <= 0 {
+		fontSize = DefaultFontSize
+	}
+	width, height := b.measure(text, fontSize)
+	el := b.add("text", x, y, width, height)
+	el.TextFields = &TextFields{
+		Text:          text,
+		OriginalText:  text,
+		FontSize:      fontSize,
+		FontFamily:    b.FontFamily,
+		TextAlign:     "left",
+		VerticalAlign: "top",
+		LineHeight:    lineHeight,
+		AutoResize:    true,
+	}
+	return el
+}
+
+// BoundText adds text centered in container, which the editor then keeps
+// inside it.
+func (b *Builder) BoundText(container *Element, text string, fontSize float64) *Element {
+	el := b.Text(0, 0, text, fontSize)
+	el.X = container.X + (container.Width-el.Width)/2
+	el.Y = container.Y + (container.Height-el.Height)/2
+	el.TextAlign = "center"
+	el.VerticalAlign = "middle"
+	el.ContainerID = &container.ID
+	el.StrokeColor = container.StrokeColor
+	el.GroupIDs = append([]string{}, container.GroupIDs...)
+	if maxWidth := container.Width - 2*boundTextPadding; maxWidth > 0 && el.Width > maxWidth {
+		el.AutoResize = false
+	}
+	container.BoundElements = append(container.BoundElements, BoundElement{ID: el.ID, Type: "text"})
+	return el
+}
+
+// Arrow adds an arrow through points, in scene coordinates, bound to start
+// and end when they aren't nil.
+func (b *Builder) Arrow(points []Point, start, end *Element) *Element {
+	el := b.linear("arrow", points)
+	el.EndArrowhead = Arrowhead("arrow")
+	if start != nil {
+		el.StartBinding = bind(el, start, points[0])
+	}
+	if end != nil {
+		el.EndBinding = bind(el, end, points[len(points)-1])
+	}
+	return el
+}
+
+// Line adds a line through points, in scene coordinates.
+func (b *Builder) Line(points []Point) *Element {
+	return b.linear("line", points)
+}
+
+func (b *Builder) linear(kind string, points []Point) *Element {
+	origin := points[0]
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	relative := make([]Point, len(points))
+	for i, pt := range points {
+		relative[i] = Point{pt[0] - origin[0], pt[1] - origin[1]}
+		minX, minY = math.Min(minX, pt[0]), math.Min(minY, pt[1])
+		maxX, maxY = math.Max(maxX, pt[0]), math.Max(maxY, pt[1])
+	}
+	el := b.add(kind, origin[0], origin[1], maxX-minX, maxY-minY)
+	el.Roundness = &Roundness{Type: 2}
+	el.LinearFields = &LinearFields{Points: relative}
+	return el
+}
+
+// bind attaches an end of arrow at pt to target.
+func bind(arrow, target *Element, pt Point) *Binding {
+	target.BoundElements = append(target.BoundElements, BoundElement{ID: arrow.ID, Type: "arrow"})
+	gap := Distance(pt, Clip(target, Point{target.X + target.Width/2, target.Y + target.Height/2}, pt))
+	return &Binding{ElementID: target.ID, Gap: math.Max(1, math.Round(gap))}
+}
+
+// Center returns the center of an element.
+func Center(el *Element) Point {
+	return Point{el.X + el.Width/2, el.Y + el.Height/2}
+}
+
+// Clip returns where the segment from inside, a point within el, towards
+// toward leaves el's outline. Diamonds and ellipses are clipped to their
+// shape, anything else to its bounding box.
+func Clip(el *Element, inside, toward Point) Point {
+	dx, dy := toward[0]-inside[0], toward[1]-inside[1]
+	if dx == 0 && dy == 0 {
+		return inside
+	}
+	center := Center(el)
+	rx, ry := el.Width/2, el.Height/2
+	if rx <= 0 || ry <= 0 {
+		return inside
+	}
+	// Solve for the largest t along inside + t*(dx, dy) still in the shape,
+	// in coordinates where the shape is centered and unit sized
+	px, py := (inside[0]-center[0])/rx, (inside[1]-center[1])/ry
+	vx, vy := dx/rx, dy/ry
+	var t float64
+	switch el.Type {
+	case "ellipse":
+		a := vx*vx + vy*vy
+		bq := 2 * (px*vx + py*vy)
+		c := px*px + py*py - 1
+		t = (-bq + math.Sqrt(math.Max(0, bq*bq-4*a*c))) / (2 * a)
+	case "diamond":
+		// |x| + |y| = 1 on each of the four sides
+		t = math.Inf(1)
+		for _, s := range [][2]float64{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}} {
+			if d := s[0]*vx + s[1]*vy; d > 0 {
+				t = math.Min(t, (1-s[0]*px-s[1]*py)/d)
+			}
+		}
+	default:
+		t = math.Inf(1)
+		if vx != 0 {
+			t = math.Min(t, (math.Copysign(1, vx)-px)/vx)
+		}
+		if vy != 0 {
+			t = math.Min(t, (math.Copysign(1, vy)-py)/vy)
+		}
+	}
+	if math.IsInf(t, 0) || math.IsNaN(t) || t < 0 {
+		return inside
+	}
+	return Point{inside[0] + t*dx, inside[1] + t*dy}
+}
+
+// Distance returns the distance between two points.
+func Distance(a, b Point) float64 {
+	return math.Hypot(a[0]-b[0], a[1]-b[1])
+}
+
+// measure estimates the size of text the way the editor lays it out.
+func (b *Builder) measure(text string, fontSize float64) (width, height float64) {
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		width = math.Max(width, export.TextWidth(line, b.FontFamily, fontSize))
+	}
+	if width == 0 && utf8.RuneCountInString(text) > 0 {
+		width = fontSize / 2
+	}
+	return math.Ceil(width), math.Ceil(float64(len(lines)) * fontSize * lineHeight)
+}
+
+// idAlphabet is the alphabet of the editor's nanoid element ids.
+const idAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz_-"
+
+func newID() string {
+	b := make([]byte, 21)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = idAlphabet[b[i]&63]
+	}
+	return string(b)
+}
+
+// randomInt returns a random seed or nonce in the editor's range.
+func randomInt() int64 {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return int64(binary.LittleEndian.Uint32(b[:]) >> 1)
+}
@@ -0,0 +1,817 @@
+package convert
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxSVGElements bounds the elements one SVG can turn into.
+const maxSVGElements = 20000
+
+// ErrInvalidSVG is returned for files that aren't SVG images or are too
+// complex to convert.
+var ErrInvalidSVG = errors.New("not an SVG image")
+
+type (
+	svgNode struct {
+		XMLName xml.Name
+		Attrs   []xml.Attr `xml:",any,attr"`
+		Nodes   []svgNode  `xml:",any"`
+		Text    string     `xml:",chardata"`
+	}
+
+	// svgStyle is the presentation an element inherits from its ancestors.
+	svgStyle struct {
+		fill        string
+		stroke      string
+		strokeWidth float64
+		dashed      bool
+		// opacity multiplies down the tree, from 0 to 1
+		opacity    float64
+		fontSize   float64
+		fontFamily string
+		textAnchor string
+		hidden     bool
+	}
+
+	// matrix is an SVG transform: a, b, c, d, e, f.
+	matrix [6]float64
+)
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// FromSVG converts the shapes of an SVG into editable elements: rectangles,
+// circles and ellipses become Excalidraw shapes when they aren't rotated or
+// skewed, and lines, polygons and paths become lines, with curves
+// flattened and closed shapes filled. Text keeps its position, size and
+// color. Each <g> becomes a group. Gradients are approximated by their
+// first color; images, <use> references, clip paths, masks and CSS style
+// sheets are left out.
+func FromSVG(data []byte) (Scene, error) {
+	var root svgNode
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return Scene{}, fmt.Errorf("%w: %v", ErrInvalidSVG, err)
+	}
+	if root.XMLName.Local != "svg" {
+		return Scene{}, ErrInvalidSVG
+	}
+
+	c := &svgConverter{b: NewBuilder(), gradients: make(map[string]string)}
+	c.b.FontFamily = FontFamilySans
+	c.collectGradients(root)
+	c.walk(root, identity, svgStyle{
+		fill:        "#000000",
+		stroke:      "none",
+		strokeWidth: 1,
+		opacity:     1,
+		fontSize:    16,
+	}, nil)
+	if c.err != nil {
+		return Scene{}, c.err
+	}
+	return NewScene(c.b.Elements()), nil
+}
+
+type svgConverter struct {
+	b *Builder
+	// gradients maps gradient ids to the color they're drawn as
+	gradients map[string]string
+	err       error
+}
+
+// collectGradients records the first stop color of each gradient, following
+// href references to gradients that only inherit stops.
+func (c *svgConverter) collectGradients(root svgNode) {
+	stops := make(map[string]string)
+	hrefs := make(map[string]string)
+	var visit func(n svgNode)
+	visit = func(n svgNode) {
+		if n.XMLName.Local == "linearGradient" || n.XMLName.Local == "radialGradient" {
+			props := properties(n)
+			id := props["id"]
+			for _, child := range n.Nodes {
+				if child.XMLName.Local == "stop" {
+					stops[id] = properties(child)["stop-color"]
+					break
+				}
+			}
+			hrefs[id] = strings.TrimPrefix(firstNonEmpty(props["href"], props["xlink:href"]), "#")
+			return
+		}
+		for _, child := range n.Nodes {
+			visit(child)
+		}
+	}
+	visit(root)
+
+	for id := range hrefs {
+		ref := id
+		for i := 0; i < 8 && stops[ref] == ""; i++ {
+			ref = hrefs[ref]
+		}
+		if color := stops[ref]; color != "" {
+			c.gradients[id] = color
+		}
+	}
+}
+
+func (c *svgConverter) walk(n svgNode, m matrix, style svgStyle, groups []string) {
+	if c.err != nil {
+		return
+	}
+	switch n.XMLName.Local {
+	case "defs", "symbol", "clipPath", "mask", "pattern", "marker", "style", "script",
+		"title", "desc", "metadata", "linearGradient", "radialGradient", "filter", "foreignObject":
+		return
+	}
+	props := properties(n)
+	if props["display"] == "none" {
+		return
+	}
+	style = style.inherit(props)
+	if transform := props["transform"]; transform != "" {
+		m = m.mul(parseTransform(transform))
+	}
+
+	switch n.XMLName.Local {
+	case "svg":
+		m = m.mul(viewport(props))
+		c.children(n, m, style, groups)
+	case "g", "a", "switch":
+		if len(n.Nodes) > 1 {
+			groups = append([]string{newID()}, groups...)
+		}
+		c.children(n, m, style, groups)
+	case "rect":
+		x, y := length(props["x"]), length(props["y"])
+		w, h := length(props["width"]), length(props["height"])
+		if w <= 0 || h <= 0 {
+			return
+		}
+		if m.axisAligned() {
+			pt := m.apply(Point{x, y})
+			el := c.shape("rectangle", pt[0], pt[1], w*m[0], h*m[3], style, m, groups)
+			if el != nil && (length(props["rx"]) > 0 || length(props["ry"]) > 0) {
+				el.Roundness = &Roundness{Type: 3}
+			}
+			return
+		}
+		c.poly([]Point{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}, true, style, m, groups)
+	case "circle", "ellipse":
+		cx, cy := length(props["cx"]), length(props["cy"])
+		rx, ry := length(props["rx"]), length(props["ry"])
+		if n.XMLName.Local == "circle" {
+			rx = length(props["r"])
+			ry = rx
+		}
+		if rx <= 0 || ry <= 0 {
+			return
+		}
+		if m.axisAligned() {
+			pt := m.apply(Point{cx - rx, cy - ry})
+			c.shape("ellipse", pt[0], pt[1], 2*rx*m[0], 2*ry*m[3], style, m, groups)
+			return
+		}
+		points := make([]Point, 36)
+		for i := range points {
+			t := 2 * math.Pi * float64(i) / float64(len(points))
+			points[i] = Point{cx + rx*math.Cos(t), cy + ry*math.Sin(t)}
+		}
+		c.poly(points, true, style, m, groups)
+	case "line":
+		points := []Point{{length(props["x1"]), length(props["y1"])}, {length(props["x2"]), length(props["y2"])}}
+		c.poly(points, false, style, m, groups)
+	case "polyline", "polygon":
+		numbers := parseNumbers(props["points"])
+		points := make([]Point, 0, len(numbers)/2)
+		for i := 0; i+1 < len(numbers); i += 2 {
+			points = append(points, Point{numbers[i], numbers[i+1]})
+		}
+		c.poly(points, n.XMLName.Local == "polygon", style, m, groups)
+	case "path":
+		subpaths := parsePath(props["d"])
+		if len(subpaths) > 1 {
+			groups = append([]string{newID()}, groups...)
+		}
+		for _, sub := range subpaths {
+			c.poly(sub.points, sub.closed, style, m, groups)
+		}
+	case "text":
+		c.text(n, props, style, m, groups)
+	}
+}
+
+func (c *svgConverter) children(n svgNode, m matrix, style svgStyle, groups []string) {
+	for _, child := range n.Nodes {
+		c.walk(child, m, style, groups)
+	}
+}
+
+// add checks the element budget before adding one more.
+func (c *svgConverter) add() bool {
+	if len(c.b.elements) >= maxSVGElements {
+		c.err = fmt.Errorf("%w: more than %d elements", ErrInvalidSVG, maxSVGElements)
+		return false
+	}
+	return true
+}
+
+func (c *svgConverter) shape(kind string, x, y, w, h float64, style svgStyle, m matrix, groups []string) *Element {
+	if style.hidden || !style.visible(true) || !c.add() {
+		return nil
+	}
+	el := c.b.Shape(kind, x, y, w, h)
+	c.apply(el, style, m, groups, true)
+	return el
+}
+
+// poly adds a line through points, in the coordinates of m; closed lines
+// are filled.
+func (c *svgConverter) poly(points []Point, closed bool, style svgStyle, m matrix, groups []string) {
+	if len(points) < 2 || style.hidden || !style.visible(closed) || !c.add() {
+		return
+	}
+	abs := make([]Point, 0, len(points)+1)
+	for _, pt := range points {
+		abs = append(abs, m.apply(pt))
+	}
+	if closed && abs[0] != abs[len(abs)-1] {
+		abs = append(abs, abs[0])
+	}
+	el := c.b.Line(abs)
+	el.Roundness = nil
+	el.Polygon = closed
+	c.apply(el, style, m, groups, closed)
+}
+
+func (c *svgConverter) text(n svgNode, props map[string]string, style svgStyle, m matrix, groups []string) {
+	content := svgText(n)
+	if content == "" || style.hidden || !c.add() {
+		return
+	}
+	xs, ys := parseNumbers(props["x"]), parseNumbers(props["y"])
+	var pt Point
+	if len(xs) > 0 {
+		pt[0] = xs[0]
+	}
+	if len(ys) > 0 {
+		pt[1] = ys[0]
+	}
+	pt = m.apply(pt)
+	fontSize := style.fontSize * m.scale()
+
+	c.b.FontFamily = fontFamily(style.fontFamily)
+	// y is the baseline of the first line
+	el := c.b.Text(pt[0], pt[1]-fontSize, content, fontSize)
+	c.b.FontFamily = FontFamilySans
+	switch style.textAnchor {
+	case "middle":
+		el.X -= el.Width / 2
+		el.TextAlign = "center"
+	case "end":
+		el.X -= el.Width
+		el.TextAlign = "right"
+	}
+	c.apply(el, style, m, groups, false)
+	el.StrokeColor = c.paint(style.fill)
+	if el.StrokeColor == "transparent" {
+		el.StrokeColor = c.paint(style.stroke)
+	}
+}
+
+func (c *svgConverter) apply(el *Element, style svgStyle, m matrix, groups []string, filled bool) {
+	el.StrokeColor = c.paint(style.stroke)
+	el.BackgroundColor = "transparent"
+	if filled {
+		el.BackgroundColor = c.paint(style.fill)
+	}
+	el.StrokeWidth = math.Round(style.strokeWidth*m.scale()*100) / 100
+	if style.dashed {
+		el.StrokeStyle = "dashed"
+	}
+	el.Opacity = math.Round(style.opacity * 100)
+	el.GroupIDs = append([]string{}, groups...)
+}
+
+// paint returns the color a fill or stroke is drawn with.
+func (c *svgConverter) paint(value string) string {
+	switch value {
+	case "", "none", "transparent":
+		return "transparent"
+	case "currentColor":
+		return "#1e1e1e"
+	}
+	if strings.HasPrefix(value, "url(") {
+		// url(#id) with an optional fallback color
+		ref, fallback, _ := strings.Cut(strings.TrimPrefix(value, "url("), ")")
+		if color, ok := c.gradients[strings.Trim(strings.TrimSpace(ref), `#"'`)]; ok {
+			return color
+		}
+		return c.paint(strings.TrimSpace(fallback))
+	}
+	return value
+}
+
+func (s svgStyle) inherit(props map[string]string) svgStyle {
+	if v, ok := props["fill"]; ok && v != "inherit" {
+		s.fill = v
+	}
+	if v, ok := props["stroke"]; ok && v != "inherit" {
+		s.stroke = v
+	}
+	if v, ok := props["stroke-width"]; ok {
+		if width, ok := parseLength(v); ok && width >= 0 {
+			s.strokeWidth = width
+		}
+	}
+	if v, ok := props["stroke-dasharray"]; ok {
+		s.dashed = v != "none" && v != ""
+	}
+	if v, err := strconv.ParseFloat(props["opacity"], 64); err == nil {
+		s.opacity *= math.Max(0, math.Min(1, v))
+	}
+	if props["fill-opacity"] == "0" {
+		s.fill = "none"
+	}
+	if props["stroke-opacity"] == "0" {
+		s.stroke = "none"
+	}
+	if v, ok := parseLength(props["font-size"]); ok && v > 0 {
+		s.fontSize = v
+	}
+	if v := props["font-family"]; v != "" {
+		s.fontFamily = v
+	}
+	if v := props["text-anchor"]; v != "" {
+		s.textAnchor = v
+	}
+	switch props["visibility"] {
+	case "hidden", "collapse":
+		s.hidden = true
+	case "visible":
+		s.hidden = false
+	}
+	return s
+}
+
+// visible reports whether a shape draws anything.
+func (s svgStyle) visible(filled bool) bool {
+	return s.opacity > 0 && (s.stroke != "none" || filled && s.fill != "none")
+}
+
+// properties returns an element's attributes with its inline style applied
+// on top.
+func properties(n svgNode) map[string]string {
+	props := make(map[string]string, len(n.Attrs))
+	for _, attr := range n.Attrs {
+		name := attr.Name.Local
+		if attr.Name.Space == "xlink" || strings.HasSuffix(attr.Name.Space, "/xlink") {
+			name = "xlink:" + name
+		}
+		props[name] = strings.TrimSpace(attr.Value)
+	}
+	for _, decl := range strings.Split(props["style"], ";") {
+		key, value, ok := strings.Cut(decl, ":")
+		if ok {
+			props[strings.TrimSpace(key)] = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+		}
+	}
+	return props
+}
+
+// svgText returns a text element's content, starting a new line for each
+// <tspan> positioned with x or dy.
+func svgText(n svgNode) string {
+	lines := []string{strings.Join(strings.Fields(n.Text), " ")}
+	for _, child := range n.Nodes {
+		if child.XMLName.Local != "tspan" {
+			continue
+		}
+		props := properties(child)
+		text := strings.Join(strings.Fields(svgText(child)), " ")
+		last := len(lines) - 1
+		switch {
+		case (props["x"] != "" || props["dy"] != "") && lines[last] != "":
+			lines = append(lines, text)
+		case lines[last] == "":
+			lines[last] = text
+		default:
+			lines[last] += " " + text
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func fontFamily(family string) int {
+	family = strings.ToLower(family)
+	switch {
+	case strings.Contains(family, "mono"), strings.Contains(family, "courier"), strings.Contains(family, "cascadia"):
+		return FontFamilyMono
+	case strings.Contains(family, "virgil"), strings.Contains(family, "excalifont"), strings.Contains(family, "cursive"):
+		return FontFamilyHand
+	}
+	return FontFamilySans
+}
+
+// viewport maps an <svg> element's viewBox to its position and size,
+// centering it as the default preserveAspectRatio does.
+func viewport(props map[string]string) matrix {
+	m := matrix{1, 0, 0, 1, length(props["x"]), length(props["y"])}
+	box := parseNumbers(props["viewBox"])
+	if len(box) != 4 || box[2] <= 0 || box[3] <= 0 {
+		return m
+	}
+	width, okW := parseLength(props["width"])
+	height, okH := parseLength(props["height"])
+	if !okW || !okH || width <= 0 || height <= 0 {
+		return m.mul(matrix{1, 0, 0, 1, -box[0], -box[1]})
+	}
+	sx, sy := width/box[2], height/box[3]
+	var dx, dy float64
+	if props["preserveAspectRatio"] != "none" {
+		s := math.Min(sx, sy)
+		dx, dy = (width-box[2]*s)/2, (height-box[3]*s)/2
+		sx, sy = s, s
+	}
+	return m.mul(matrix{sx, 0, 0, sy, dx - box[0]*sx, dy - box[1]*sy})
+}
+
+// mul returns the transform that applies n and then m.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m matrix) apply(pt Point) Point {
+	return Point{m[0]*pt[0] + m[2]*pt[1] + m[4], m[1]*pt[0] + m[3]*pt[1] + m[5]}
+}
+
+// axisAligned reports whether m only moves and scales, so rectangles stay
+// rectangles.
+func (m matrix) axisAligned() bool {
+	return m[1] == 0 && m[2] == 0 && m[0] > 0 && m[3] > 0
+}
+
+// scale returns how much m scales lengths on average.
+func (m matrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+var transformPattern = regexp.MustCompile(`(\w+)\s*\(([^)]*)\)`)
+
+func parseTransform(s string) matrix {
+	m := identity
+	for _, match := range transformPattern.FindAllStringSubmatch(s, -1) {
+		args := parseNumbers(match[2])
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+		var t matrix
+		switch match[1] {
+		case "matrix":
+			if len(args) != 6 {
+				continue
+			}
+			copy(t[:], args)
+		case "translate":
+			t = matrix{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			sx := arg(0, 1)
+			t = matrix{sx, 0, 0, arg(1, sx), 0, 0}
+		case "rotate":
+			a := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			cos, sin := math.Cos(a), math.Sin(a)
+			t = matrix{cos, sin, -sin, cos, cx - cos*cx + sin*cy, cy - sin*cx - cos*cy}
+		case "skewX":
+			t = matrix{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			t = matrix{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			continue
+		}
+		m = m.mul(t)
+	}
+	return m
+}
+
+var (
+	numberPattern = regexp.MustCompile(`[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+	numberPrefix  = regexp.MustCompile(`^` + numberPattern.String())
+)
+
+func parseNumbers(s string) []float64 {
+	var numbers []float64
+	for _, match := range numberPattern.FindAllString(s, -1) {
+		if v, err := strconv.ParseFloat(match, 64); err == nil {
+			numbers = append(numbers, v)
+		}
+	}
+	return numbers
+}
+
+// units converts absolute CSS units to pixels.
+var units = map[string]float64{"": 1, "px": 1, "pt": 4.0 / 3, "pc": 16, "mm": 96 / 25.4, "cm": 96 / 2.54, "in": 96, "em": 16}
+
+// parseLength parses an SVG length; percentages aren't supported.
+func parseLength(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	number := numberPrefix.FindString(s)
+	if number == "" {
+		return 0, false
+	}
+	scale, ok := units[strings.TrimSpace(s[len(number):])]
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	return v * scale, err == nil
+}
+
+func length(s string) float64 {
+	v, _ := parseLength(s)
+	return v
+}
+
+type subpath struct {
+	points []Point
+	closed bool
+}
+
+// parsePath flattens path data into polylines, one per subpath. Parsing
+// stops at the first error, drawing what came before as browsers do.
+func parsePath(d string) []subpath {
+	l := pathLexer{s: d}
+	var (
+		paths    []subpath
+		cur      *subpath
+		pos      Point
+		start    Point
+		control  Point // the last control point, for S and T
+		previous byte
+		cmd      byte
+	)
+	lineTo := func(pts ...Point) {
+		if cur == nil {
+			paths = append(paths, subpath{points: []Point{pos}})
+			cur = &paths[len(paths)-1]
+		}
+		cur.points = append(cur.points, pts...)
+	}
+
+	for {
+		if next, ok := l.command(); ok {
+			cmd = next
+		} else if cmd == 0 || l.done() {
+			break
+		}
+		relative := cmd >= 'a'
+		offset := func(pt Point) Point {
+			if relative {
+				return Point{pos[0] + pt[0], pos[1] + pt[1]}
+			}
+			return pt
+		}
+
+		upper := cmd &^ 0x20
+		switch upper {
+		case 'M':
+			args, ok := l.numbers(2)
+			if !ok {
+				return paths
+			}
+			pos = offset(Point{args[0], args[1]})
+			start = pos
+			paths = append(paths, subpath{points: []Point{pos}})
+			cur = &paths[len(paths)-1]
+			// Further pairs are line segments
+			cmd = 'L' | cmd&0x20
+		case 'L':
+			args, ok := l.numbers(2)
+			if !ok {
+				return paths
+			}
+			pos = offset(Point{args[0], args[1]})
+			lineTo(pos)
+		case 'H', 'V':
+			args, ok := l.numbers(1)
+			if !ok {
+				return paths
+			}
+			next := pos
+			axis := 0
+			if upper == 'V' {
+				axis = 1
+			}
+			next[axis] = args[0]
+			if relative {
+				next[axis] += pos[axis]
+			}
+			pos = next
+			lineTo(pos)
+		case 'C', 'S':
+			n := 6
+			if upper == 'S' {
+				n = 4
+			}
+			args, ok := l.numbers(n)
+			if !ok {
+				return paths
+			}
+			c1 := pos
+			if upper == 'C' {
+				c1 = offset(Point{args[0], args[1]})
+				args = args[2:]
+			} else if previous == 'C' || previous == 'S' {
+				c1 = Point{2*pos[0] - control[0], 2*pos[1] - control[1]}
+			}
+			c2 := offset(Point{args[0], args[1]})
+			end := offset(Point{args[2], args[3]})
+			lineTo(cubic(pos, c1, c2, end)...)
+			pos, control = end, c2
+		case 'Q', 'T':
+			n := 4
+			if upper == 'T' {
+				n = 2
+			}
+			args, ok := l.numbers(n)
+			if !ok {
+				return paths
+			}
+			c := pos
+			if upper == 'Q' {
+				c = offset(Point{args[0], args[1]})
+				args = args[2:]
+			} else if previous == 'Q' || previous == 'T' {
+				c = Point{2*pos[0] - control[0], 2*pos[1] - control[1]}
+			}
+			end := offset(Point{args[0], args[1]})
+			// A quadratic curve is a cubic with these control points
+			c1 := Point{pos[0] + 2*(c[0]-pos[0])/3, pos[1] + 2*(c[1]-pos[1])/3}
+			c2 := Point{end[0] + 2*(c[0]-end[0])/3, end[1] + 2*(c[1]-end[1])/3}
+			lineTo(cubic(pos, c1, c2, end)...)
+			pos, control = end, c
+		case 'A':
+			rx, okRx := l.number()
+			ry, okRy := l.number()
+			rotation, okRot := l.number()
+			large, okLarge := l.flag()
+			sweep, okSweep := l.flag()
+			end, okEnd := l.numbers(2)
+			if !okRx || !okRy || !okRot || !okLarge || !okSweep || !okEnd {
+				return paths
+			}
+			next := offset(Point{end[0], end[1]})
+			lineTo(arc(pos, rx, ry, rotation, large, sweep, next)...)
+			pos = next
+		case 'Z':
+			if cur != nil {
+				cur.closed = true
+			}
+			pos = start
+			// Drawing on without a moveto starts a new subpath here
+			cur = nil
+			cmd = 0
+		default:
+			return paths
+		}
+		previous = upper
+	}
+	return paths
+}
+
+// cubic flattens a cubic Bézier curve from p0, leaving p0 out.
+func cubic(p0, p1, p2, p3 Point) []Point {
+	n := int((Distance(p0, p1) + Distance(p1, p2) + Distance(p2, p3)) / 8)
+	n = max(4, min(n, 24))
+	points := make([]Point, n)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		points[i-1] = Point{
+			a*p0[0] + b*p1[0] + c*p2[0] + d*p3[0],
+			a*p0[1] + b*p1[1] + c*p2[1] + d*p3[1],
+		}
+	}
+	return points
+}
+
+// arc flattens an elliptical arc from p0 to p1, leaving p0 out, following
+// the SVG spec's conversion from endpoint to center parameters.
+func arc(p0 Point, rx, ry, rotation float64, large, sweep bool, p1 Point) []Point {
+	if p0 == p1 {
+		return nil
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return []Point{p1}
+	}
+	phi := rotation * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	dx, dy := (p0[0]-p1[0])/2, (p0[1]-p1[1])/2
+	x1, y1 := cos*dx+sin*dy, -sin*dx+cos*dy
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		rx, ry = rx*math.Sqrt(lambda), ry*math.Sqrt(lambda)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cx1, cy1 := coef*rx*y1/ry, -coef*ry*x1/rx
+	cx := cos*cx1 - sin*cy1 + (p0[0]+p1[0])/2
+	cy := sin*cx1 + cos*cy1 + (p0[1]+p1[1])/2
+
+	theta := math.Atan2((y1-cy1)/ry, (x1-cx1)/rx)
+	delta := math.Atan2((-y1-cy1)/ry, (-x1-cx1)/rx) - theta
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+
+	n := max(2, min(int(math.Abs(delta)/(math.Pi/12))+1, 48))
+	points := make([]Point, n)
+	for i := 1; i <= n; i++ {
+		t := theta + delta*float64(i)/float64(n)
+		x, y := rx*math.Cos(t), ry*math.Sin(t)
+		points[i-1] = Point{cx + x*cos - y*sin, cy + x*sin + y*cos}
+	}
+	points[n-1] = p1
+	return points
+}
+
+// pathLexer reads the commands and numbers of path data.
+type pathLexer struct {
+	s string
+	i int
+}
+
+func (l *pathLexer) skip() {
+	for l.i < len(l.s) && strings.IndexByte(" \t\r\n,", l.s[l.i]) >= 0 {
+		l.i++
+	}
+}
+
+func (l *pathLexer) done() bool {
+	l.skip()
+	return l.i >= len(l.s)
+}
+
+func (l *pathLexer) command() (byte, bool) {
+	l.skip()
+	if l.i < len(l.s) && strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", l.s[l.i]) >= 0 {
+		l.i++
+		return l.s[l.i-1], true
+	}
+	return 0, false
+}
+
+func (l *pathLexer) number() (float64, bool) {
+	l.skip()
+	loc := numberPrefix.FindStringIndex(l.s[l.i:])
+	if loc == nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(l.s[l.i:l.i+loc[1]], 64)
+	l.i += loc[1]
+	return v, err == nil
+}
+
+func (l *pathLexer) numbers(n int) ([]float64, bool) {
+	args := make([]float64, n)
+	for i := range args {
+		v, ok := l.number()
+		if !ok {
+			return nil, false
+		}
+		args[i] = v
+	}
+	return args, true
+}
+
+// flag reads an arc flag, which may be written without a separator.
+func (l *pathLexer) flag() (bool, bool) {
+	l.skip()
+	if l.i < len(l.s) && (l.s[l.i] == '0' || l.s[l.i] == '1') {
+		l.i++
+		return l.s[l.i-1] == '1', true
+	}
+	return false, false
+}
@@ -0,0 +1,106 @@
+package convert
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFromSVG(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="200" height="100" viewBox="0 0 400 200">
+		<defs><linearGradient id="sky"><stop offset="0" stop-color="#a5d8ff"/></linearGradient></defs>
+		<rect x="10" y="20" width="100" height="40" rx="4" fill="url(#sky)" stroke="#1971c2" stroke-width="4"/>
+		<g transform="translate(200 0)" fill="none" stroke="red">
+			<circle cx="50" cy="50" r="20"/>
+			<path d="M0 100 h50 v50 z M60 100 c10 0 20 10 20 20" style="stroke-dasharray: 4 2"/>
+		</g>
+		<ellipse cx="0" cy="0" rx="10" ry="5" transform="rotate(45)"/>
+		<text x="10" y="190" font-size="20" text-anchor="start">Hello<tspan x="10" dy="24">world</tspan></text>
+		<rect width="10" height="10" display="none"/>
+		<rect width="10" height="10" fill="none"/>
+	</svg>`
+	scene, err := FromSVG([]byte(svg))
+	if err != nil {
+		t.Fatalf("FromSVG() failed: %v", err)
+	}
+	types := byType(scene.Elements)
+	if len(types["rectangle"]) != 1 || len(types["ellipse"]) != 1 || len(types["line"]) != 3 || len(types["text"]) != 1 {
+		t.Fatalf("elements = %v, want a rectangle, an ellipse, 3 lines and a text", types)
+	}
+
+	// The viewBox halves everything
+	rect := types["rectangle"][0]
+	if rect.X != 5 || rect.Y != 10 || rect.Width != 50 || rect.Height != 20 || rect.StrokeWidth != 2 {
+		t.Errorf("rectangle = %v, %v %vx%v stroke %v, want 5, 10 50x20 stroke 2", rect.X, rect.Y, rect.Width, rect.Height, rect.StrokeWidth)
+	}
+	if rect.BackgroundColor != "#a5d8ff" || rect.StrokeColor != "#1971c2" || rect.Roundness == nil {
+		t.Errorf("rectangle style = %s, %s, %v", rect.BackgroundColor, rect.StrokeColor, rect.Roundness)
+	}
+	circle := types["ellipse"][0]
+	if circle.X != 115 || circle.Y != 15 || circle.Width != 20 || circle.BackgroundColor != "transparent" || circle.StrokeColor != "red" {
+		t.Errorf("circle = %+v", circle)
+	}
+
+	var triangle, curve, rotated *Element
+	for _, line := range types["line"] {
+		switch {
+		case line.Polygon && len(line.Points) == 4:
+			triangle = line
+		case !line.Polygon && line.StrokeStyle == "dashed":
+			curve = line
+		case line.Polygon:
+			rotated = line
+		}
+	}
+	if triangle == nil || triangle.X != 100 || triangle.Y != 50 || triangle.StrokeStyle != "dashed" {
+		t.Errorf("closed path = %+v", triangle)
+	}
+	if curve == nil || len(curve.Points) < 5 || curve.Points[len(curve.Points)-1] != (Point{10, 10}) {
+		t.Errorf("curve = %+v", curve)
+	}
+	if triangle != nil && curve != nil && (len(triangle.GroupIDs) != 2 || triangle.GroupIDs[0] != curve.GroupIDs[0] || circle.GroupIDs[0] != triangle.GroupIDs[1]) {
+		t.Errorf("groups = %v, %v, %v, want the path's subpaths grouped inside the <g>", circle.GroupIDs, triangle.GroupIDs, curve.GroupIDs)
+	}
+	if rotated == nil || rotated.BackgroundColor != "#000000" {
+		t.Errorf("rotated ellipse = %+v, want a filled polygon", rotated)
+	}
+
+	text := types["text"][0]
+	if text.Text != "Hello\nworld" || text.FontSize != 10 || text.X != 5 || text.Y != 85 {
+		t.Errorf("text = %q size %v at %v, %v", text.Text, text.FontSize, text.X, text.Y)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	// Arc flags without separators, relative commands and implicit lineto
+	paths := parsePath("m10 10 20 0a10 10 0 0110 10L50 50Z")
+	if len(paths) != 1 || !paths[0].closed {
+		t.Fatalf("paths = %+v", paths)
+	}
+	points := paths[0].points
+	if points[0] != (Point{10, 10}) || points[1] != (Point{30, 10}) || points[len(points)-1] != (Point{50, 50}) {
+		t.Errorf("points = %v", points)
+	}
+	for _, pt := range points[2 : len(points)-2] {
+		if d := Distance(pt, Point{30, 20}); math.Abs(d-10) > 1e-9 {
+			t.Errorf("arc point %v is %v from the center, want 10", pt, d)
+		}
+	}
+
+	if paths := parsePath("M0 0 L10 oops 20"); len(paths) != 1 || len(paths[0].points) != 1 {
+		t.Errorf("malformed path = %+v, want what came before the error", paths)
+	}
+}
+
+func TestFromSVGInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"not xml":   "hello",
+		"not svg":   `<mxfile/>`,
+		"too large": `<svg>` + strings.Repeat(`<line x2="1" stroke="red"/>`, maxSVGElements+1) + `</svg>`,
+	} {
+		if _, err := FromSVG([]byte(data)); !errors.Is(err, ErrInvalidSVG) {
+			t.Errorf("%s: err = %v, want ErrInvalidSVG", name, err)
+		}
+	}
+}
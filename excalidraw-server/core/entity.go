@@ -3,6 +3,73 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrFileNotFound is returned by FileStore implementations for unknown ids.
+	ErrFileNotFound = errors.New("file not found")
+	// ErrLibraryNotFound is returned by LibraryStore implementations for unknown ids.
+	ErrLibraryNotFound = errors.New("library not found")
+	// ErrDocumentNotFound is returned by DocumentStatsStore implementations
+	// for unknown ids.
+	ErrDocumentNotFound = errors.New("document not found")
+	// ErrCanvasNotFound is returned by CanvasStore implementations for unknown keys.
+	ErrCanvasNotFound = errors.New("canvas not found")
+	// ErrOrgNotFound is returned by OrgStore implementations for unknown ids.
+	ErrOrgNotFound = errors.New("organization not found")
+	// ErrNotMember is returned by OrgStore implementations for users outside
+	// an organization.
+	ErrNotMember = errors.New("not a member of the organization")
+	// ErrRecordingNotFound is returned by RecordingStore implementations for unknown ids.
+	ErrRecordingNotFound = errors.New("recording not found")
+	// ErrRecordingActive is returned when a room is already being recorded.
+	ErrRecordingActive = errors.New("room is already being recorded")
+	// ErrNotRecording is returned when a room has no recording in progress.
+	ErrNotRecording = errors.New("room is not being recorded")
+	// ErrRoomStateNotFound is returned by RoomStateStore implementations for
+	// rooms without saved state.
+	ErrRoomStateNotFound = errors.New("room state not found")
+	// ErrUserNotFound is returned by UserStore implementations for unknown
+	// users.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrThumbnailNotFound is returned by ThumbnailStore implementations for
+	// scenes without a cached thumbnail.
+	ErrThumbnailNotFound = errors.New("thumbnail not found")
+	// ErrTemplateNotFound is returned by TemplateStore implementations for
+	// unknown ids.
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrInvalidCursor is returned for canvas page cursors that weren't
+	// issued for the requested sort.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+const (
+	OrgRoleAdmin  OrgRole = "admin"
+	OrgRoleMember OrgRole = "member"
+
+	// NotificationMention tells a user they were @mentioned in a room's chat.
+	NotificationMention NotificationKind = "mention"
+
+	// RoomPublic rooms can be joined by anyone and are listed in /api/rooms.
+	RoomPublic RoomVisibility = "public"
+	// RoomLinkOnly rooms can be joined by anyone with the room id but are
+	// not listed.
+	RoomLinkOnly RoomVisibility = "link-only"
+	// RoomPrivate rooms can only be joined by their owner and allowlist.
+	RoomPrivate RoomVisibility = "private"
+
+	// CanvasSortUpdated orders canvases by when they were last saved.
+	CanvasSortUpdated CanvasSort = "updatedAt"
+	// CanvasSortName orders canvases by name, or key when they have none.
+	CanvasSortName CanvasSort = "name"
 )
 
 type (
@@ -14,4 +81,570 @@ type (
 		FindID(ctx context.Context, id string) (*Document, error)
 		Create(ctx context.Context, document *Document) (string, error)
 	}
+
+	// AccessStats counts how often a shared document or canvas is opened.
+	// LastAccessed is in Unix milliseconds and zero until the first view.
+	AccessStats struct {
+		Views        int64 `json:"views"`
+		LastAccessed int64 `json:"last_accessed,omitempty"`
+	}
+
+	DocumentStatsStore interface {
+		// RecordDocumentView counts a view of the document now.
+		RecordDocumentView(ctx context.Context, id string) error
+		GetDocumentStats(ctx context.Context, id string) (*AccessStats, error)
+	}
+
+	// File is a binary asset (usually an image) referenced by fileId from
+	// scene elements.
+	File struct {
+		ID        string
+		Data      []byte
+		CreatedAt time.Time
+	}
+
+	FileStore interface {
+		PutFile(ctx context.Context, file *File) error
+		GetFile(ctx context.Context, id string) (*File, error)
+		// ListFiles returns every stored file without its data.
+		ListFiles(ctx context.Context) ([]File, error)
+		DeleteFile(ctx context.Context, id string) error
+	}
+
+	// Library is a shared .excalidrawlib file owned by a user.
+	Library struct {
+		ID          string          `json:"id"`
+		OwnerID     string          `json:"owner_id"`
+		OwnerName   string          `json:"owner_name,omitempty"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Public      bool            `json:"public"`
+		CreatedAt   int64           `json:"created_at"`
+		UpdatedAt   int64           `json:"updated_at"`
+		Data        json.RawMessage `json:"library,omitempty"`
+	}
+
+	// LibraryFilter selects libraries by owner, public visibility or both.
+	LibraryFilter struct {
+		OwnerID    string
+		PublicOnly bool
+	}
+
+	LibraryStore interface {
+		CreateLibrary(ctx context.Context, library *Library) (string, error)
+		GetLibrary(ctx context.Context, id string) (*Library, error)
+		UpdateLibrary(ctx context.Context, library *Library) error
+		DeleteLibrary(ctx context.Context, id string) error
+		// ListLibraries returns matching libraries without their data,
+		// most recently updated first.
+		ListLibraries(ctx context.Context, filter LibraryFilter) ([]Library, error)
+	}
+
+	// Template is a scene admins publish as a starting point for new
+	// canvases and rooms.
+	Template struct {
+		ID          string          `json:"id"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Category    string          `json:"category"`
+		CreatedAt   int64           `json:"created_at"`
+		UpdatedAt   int64           `json:"updated_at"`
+		Data        json.RawMessage `json:"scene,omitempty"`
+	}
+
+	TemplateStore interface {
+		CreateTemplate(ctx context.Context, template *Template) (string, error)
+		GetTemplate(ctx context.Context, id string) (*Template, error)
+		UpdateTemplate(ctx context.Context, template *Template) error
+		DeleteTemplate(ctx context.Context, id string) error
+		// ListTemplates returns the templates in category, or all of them
+		// when it is empty, without their data, ordered by category and
+		// name.
+		ListTemplates(ctx context.Context, category string) ([]Template, error)
+	}
+
+	// Canvas is a scene a user saved under a key in their private cloud
+	// storage. Data is stored as sent, so clients may encrypt it.
+	Canvas struct {
+		Key       string `json:"key"`
+		OwnerID   string `json:"-"`
+		Size      int    `json:"size"`
+		CreatedAt int64  `json:"created_at"`
+		UpdatedAt int64  `json:"updated_at"`
+		Data      []byte `json:"-"`
+		// CanvasMetadata and AccessStats survive replacing the canvas.
+		CanvasMetadata
+		AccessStats
+	}
+
+	// CanvasMetadata describes a canvas apart from its data.
+	CanvasMetadata struct {
+		// Name is shown instead of the key when set.
+		Name string   `json:"name,omitempty"`
+		Tags []string `json:"tags,omitempty"`
+	}
+
+	// CanvasMetadataUpdate changes the metadata fields that are set.
+	CanvasMetadataUpdate struct {
+		Name *string
+		Tags *[]string
+	}
+
+	CanvasStore interface {
+		// PutCanvas creates or replaces the owner's canvas under its key.
+		PutCanvas(ctx context.Context, canvas *Canvas) error
+		GetCanvas(ctx context.Context, ownerID, key string) (*Canvas, error)
+		DeleteCanvas(ctx context.Context, ownerID, key string) error
+		// ListCanvases returns the owner's canvases without their data,
+		// most recently updated first.
+		ListCanvases(ctx context.Context, ownerID string) ([]Canvas, error)
+		// ListCanvasPage returns a page of the owner's canvases without
+		// their data.
+		ListCanvasPage(ctx context.Context, ownerID string, opts CanvasListOptions) (CanvasPage, error)
+		// UpdateCanvasMetadata changes a canvas's metadata without
+		// touching its data or update time, and returns the canvas without
+		// its data.
+		UpdateCanvasMetadata(ctx context.Context, ownerID, key string, update CanvasMetadataUpdate) (*Canvas, error)
+		// RecordCanvasView counts a view of the canvas now.
+		RecordCanvasView(ctx context.Context, ownerID, key string) error
+	}
+
+	// CanvasSort is what a canvas listing is ordered by.
+	CanvasSort string
+
+	// CanvasListOptions select a page of canvases. Ties are broken by key in
+	// the same direction, so pages are stable.
+	CanvasListOptions struct {
+		// Sort defaults to CanvasSortUpdated.
+		Sort CanvasSort
+		// Ascending lists the oldest or A first; the default is newest
+		// first and A first when sorting by name.
+		Ascending *bool
+		// Limit caps the page; zero lists every remaining canvas.
+		Limit int
+		// Cursor is CanvasPage.NextCursor of the previous page.
+		Cursor string
+	}
+
+	CanvasPage struct {
+		Canvases []Canvas
+		// NextCursor continues the listing; empty on the last page.
+		NextCursor string
+	}
+
+	// OrgRole is a member's role in an organization.
+	OrgRole string
+
+	// Org is an organization that shares canvases between its members.
+	Org struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		CreatedAt int64  `json:"created_at"`
+		// Role is the caller's role when listing their organizations.
+		Role OrgRole `json:"role,omitempty"`
+	}
+
+	OrgMember struct {
+		UserID   string  `json:"user_id"`
+		Role     OrgRole `json:"role"`
+		JoinedAt int64   `json:"joined_at"`
+	}
+
+	OrgStore interface {
+		// CreateOrg stores a new organization with ownerID as its first admin.
+		CreateOrg(ctx context.Context, name, ownerID string) (*Org, error)
+		GetOrg(ctx context.Context, id string) (*Org, error)
+		// DeleteOrg deletes an organization and its memberships.
+		DeleteOrg(ctx context.Context, id string) error
+		// ListOrgs returns the organizations userID belongs to, with their
+		// role, ordered by name.
+		ListOrgs(ctx context.Context, userID string) ([]Org, error)
+		// ListOrgMembers returns an organization's members, oldest first.
+		ListOrgMembers(ctx context.Context, orgID string) ([]OrgMember, error)
+		GetOrgMember(ctx context.Context, orgID, userID string) (*OrgMember, error)
+		// PutOrgMember adds a member or changes their role, keeping the
+		// original join time.
+		PutOrgMember(ctx context.Context, orgID string, member OrgMember) error
+		RemoveOrgMember(ctx context.Context, orgID, userID string) error
+	}
+
+	// Recording is a captured stream of scene broadcasts in a room.
+	Recording struct {
+		ID         string `json:"id"`
+		RoomID     string `json:"room_id"`
+		StartedAt  int64  `json:"started_at"`
+		EndedAt    int64  `json:"ended_at,omitempty"`
+		EventCount int    `json:"event_count"`
+	}
+
+	// RecordingEvent is one broadcast, Offset milliseconds after the start.
+	RecordingEvent struct {
+		Offset   int64           `json:"offset"`
+		SocketID string          `json:"socket_id"`
+		Payload  json.RawMessage `json:"payload"`
+	}
+
+	RecordingStore interface {
+		CreateRecording(ctx context.Context, roomID string, startedAt time.Time) (string, error)
+		AppendRecordingEvent(ctx context.Context, id string, event RecordingEvent) error
+		FinishRecording(ctx context.Context, id string, endedAt time.Time) error
+		GetRecording(ctx context.Context, id string) (*Recording, error)
+		// ListRecordings returns a room's recordings, newest first.
+		ListRecordings(ctx context.Context, roomID string) ([]Recording, error)
+		GetRecordingEvents(ctx context.Context, id string) ([]RecordingEvent, error)
+	}
+
+	// CRDTStore is implemented by stores that persist the update log of
+	// rooms edited through the CRDT sync provider. Updates are opaque Yjs
+	// updates; applying them in any order gives the same document.
+	CRDTStore interface {
+		// AppendCRDTUpdate adds an update to the end of a room's log.
+		AppendCRDTUpdate(ctx context.Context, roomID string, update []byte) error
+		// GetCRDTUpdates returns a room's log, oldest first.
+		GetCRDTUpdates(ctx context.Context, roomID string) ([][]byte, error)
+		// CompactCRDTUpdates replaces the first n updates of a room's log
+		// with merged, which must contain all of them.
+		CompactCRDTUpdates(ctx context.Context, roomID string, n int, merged []byte) error
+	}
+
+	// RoomStateStore is implemented by stores that can hold the in-memory
+	// state of hibernated collaboration rooms until they are used again.
+	// The state is opaque to the store.
+	RoomStateStore interface {
+		PutRoomState(ctx context.Context, roomID string, state []byte) error
+		GetRoomState(ctx context.Context, roomID string) ([]byte, error)
+		DeleteRoomState(ctx context.Context, roomID string) error
+		// ListRoomStates returns the ids of the rooms with saved state.
+		ListRoomStates(ctx context.Context) ([]string, error)
+	}
+
+	// ThumbnailStore is implemented by stores that can cache rendered scene
+	// thumbnails. Thumbnails are keyed by the hex SHA-256 of the scene they
+	// show, so identical canvases, snapshots and documents share one.
+	ThumbnailStore interface {
+		PutThumbnail(ctx context.Context, hash string, png []byte) error
+		GetThumbnail(ctx context.Context, hash string) ([]byte, error)
+		DeleteThumbnail(ctx context.Context, hash string) error
+		// ListThumbnails returns the hashes of every cached thumbnail.
+		ListThumbnails(ctx context.Context) ([]string, error)
+	}
+
+	// RoomVisibility controls who may join a collaboration room.
+	RoomVisibility string
+
+	// RoomPermissions controls access to a collaboration room. Users are
+	// identified by their JWT subject.
+	RoomPermissions struct {
+		RoomID       string         `json:"room_id"`
+		Owner        string         `json:"owner"`
+		Visibility   RoomVisibility `json:"visibility"`
+		AllowedUsers []string       `json:"allowed_users"`
+	}
+
+	RoomPermissionStore interface {
+		// GetRoomPermissions returns a room's permissions, or public ones
+		// without an owner when none are stored.
+		GetRoomPermissions(ctx context.Context, roomID string) (*RoomPermissions, error)
+		PutRoomPermissions(ctx context.Context, permissions *RoomPermissions) error
+	}
+
+	// RoomMessageLimits are a room's own limits, in bytes, on the payloads
+	// of each kind of event; zero fields keep the deployment's.
+	RoomMessageLimits struct {
+		Broadcast int `json:"broadcast"`
+		Chat      int `json:"chat"`
+		Presence  int `json:"presence"`
+	}
+
+	// RoomLimitsStore is implemented by stores that keep the message limits
+	// of rooms with their settings.
+	RoomLimitsStore interface {
+		// GetRoomMessageLimits returns a room's limits, or zero ones when
+		// none are stored.
+		GetRoomMessageLimits(ctx context.Context, roomID string) (RoomMessageLimits, error)
+		PutRoomMessageLimits(ctx context.Context, roomID string, limits RoomMessageLimits) error
+	}
+
+	// RoomModeStore is implemented by stores that remember, with the
+	// settings of rooms, which rooms relayed end-to-end encrypted frames.
+	RoomModeStore interface {
+		IsRoomEncrypted(ctx context.Context, roomID string) (bool, error)
+		MarkRoomEncrypted(ctx context.Context, roomID string) error
+	}
+
+	// RoomPermissionLister is implemented by permission stores that can find
+	// the rooms a user owns or is allowed in.
+	RoomPermissionLister interface {
+		ListUserRoomPermissions(ctx context.Context, userID string) ([]RoomPermissions, error)
+	}
+
+	// SceneScanner is implemented by stores that can enumerate every
+	// persisted scene (documents, snapshots, canvases and templates) for
+	// reference scanning.
+	SceneScanner interface {
+		ScanScenes(ctx context.Context, fn func(data []byte) error) error
+	}
+
+	// BackupWriter is implemented by stores that can write a consistent copy
+	// of everything they persist, for scheduled backups.
+	BackupWriter interface {
+		// BackupFormat names the archive format and is used as the backup's
+		// file extension, e.g. "db" or "tar".
+		BackupFormat() string
+		WriteBackup(ctx context.Context, w io.Writer) error
+	}
+
+	// User is someone who signed in with a JWT, as last seen. Users are
+	// identified by their JWT subject.
+	User struct {
+		ID        string `json:"id"`
+		Login     string `json:"login,omitempty"`
+		Name      string `json:"name,omitempty"`
+		Email     string `json:"email,omitempty"`
+		AvatarURL string `json:"avatar_url,omitempty"`
+		SeenAt    int64  `json:"seen_at"`
+	}
+
+	// UserStore is implemented by stores that keep a directory of the users
+	// seen with a JWT, so they can be found by login.
+	UserStore interface {
+		// PutUser saves a user, replacing what was known about them.
+		PutUser(ctx context.Context, user User) error
+		GetUser(ctx context.Context, id string) (*User, error)
+		// FindUserByLogin returns the user with a login, compared case
+		// insensitively, or the one seen last if several had it.
+		FindUserByLogin(ctx context.Context, login string) (*User, error)
+		// DeleteUser forgets a user; unknown users are not an error.
+		DeleteUser(ctx context.Context, id string) error
+	}
+
+	// UserBatchStore is implemented by user stores that can look up many
+	// users at once.
+	UserBatchStore interface {
+		// GetUsers returns the known users among ids by id; unknown ids are
+		// left out.
+		GetUsers(ctx context.Context, ids []string) (map[string]User, error)
+	}
+
+	// UserLister is implemented by user stores that can list their users.
+	UserLister interface {
+		// ListUsers returns up to limit users, most recently seen first.
+		ListUsers(ctx context.Context, limit int) ([]User, error)
+	}
+
+	// StorageUsage is how much a store holds.
+	StorageUsage struct {
+		// Bytes is the size of the stored data, or of the database file for
+		// stores that have one
+		Bytes     int64 `json:"bytes"`
+		Documents int   `json:"documents"`
+		Canvases  int   `json:"canvases"`
+		Snapshots int   `json:"snapshots"`
+		Files     int   `json:"files"`
+		Users     int   `json:"users"`
+	}
+
+	// StorageUsageStore is implemented by stores that can tell how much they
+	// hold.
+	StorageUsageStore interface {
+		StorageUsage(ctx context.Context) (*StorageUsage, error)
+	}
+
+	// NotificationKind says what a notification is about.
+	NotificationKind string
+
+	// Notification is something a user should know about, unread until
+	// ReadAt is set.
+	Notification struct {
+		ID        string           `json:"id"`
+		UserID    string           `json:"user_id"`
+		Kind      NotificationKind `json:"kind"`
+		RoomID    string           `json:"room_id,omitempty"`
+		MessageID string           `json:"message_id,omitempty"`
+		// From is the name of whoever caused the notification
+		From      string `json:"from,omitempty"`
+		Text      string `json:"text"`
+		CreatedAt int64  `json:"created_at"`
+		ReadAt    int64  `json:"read_at,omitempty"`
+	}
+
+	NotificationStore interface {
+		// AddNotification stores a notification, filling in its ID and
+		// CreatedAt.
+		AddNotification(ctx context.Context, notification Notification) (*Notification, error)
+		// ListNotifications returns up to limit of a user's notifications,
+		// or only the unread ones, newest first.
+		ListNotifications(ctx context.Context, userID string, unreadOnly bool, limit int) ([]Notification, error)
+		CountUnreadNotifications(ctx context.Context, userID string) (int, error)
+		// MarkNotificationsRead marks the given notifications of a user, or
+		// all of them when ids is empty, as read and returns how many were
+		// unread.
+		MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error)
+		// DeleteNotifications deletes all of a user's notifications and
+		// returns how many there were.
+		DeleteNotifications(ctx context.Context, userID string) (int, error)
+	}
+
+	// Archive is cold storage for data a store moved out of its database,
+	// addressed by keys the store chooses.
+	Archive interface {
+		Put(ctx context.Context, key string, data []byte) error
+		Get(ctx context.Context, key string) ([]byte, error)
+		Delete(ctx context.Context, key string) error
+	}
+
+	// ArchivingStore is implemented by stores that can move idle data into
+	// an Archive and restore it transparently when it is read again.
+	ArchivingStore interface {
+		SetArchive(archive Archive)
+		// ArchiveIdle moves data last used before idleSince into the
+		// archive and returns how many items were moved.
+		ArchiveIdle(ctx context.Context, idleSince time.Time) (int, error)
+	}
+
+	// RoomActivity summarizes the data a store keeps for a collaboration
+	// room.
+	RoomActivity struct {
+		RoomID string `json:"room_id"`
+		// LastActive is when the room last saved a snapshot or hibernated,
+		// in Unix milliseconds
+		LastActive int64 `json:"last_active"`
+		Snapshots  int   `json:"snapshots"`
+		Autosaves  int   `json:"autosaves"`
+		// HasState is set when the room hibernated; its saved state holds
+		// the chat history
+		HasState bool `json:"has_state"`
+	}
+
+	// RoomRetentionStore is implemented by stores that can find and delete
+	// the data of rooms nobody used for a while.
+	RoomRetentionStore interface {
+		// ListInactiveRooms returns the rooms whose stored data didn't
+		// change since before, least recently active first.
+		ListInactiveRooms(ctx context.Context, before time.Time) ([]RoomActivity, error)
+		// DeleteRoomSnapshots deletes a room's autosaves, or its other
+		// snapshots, and returns how many it deleted.
+		DeleteRoomSnapshots(ctx context.Context, roomID string, autosaves bool) (int, error)
+		// ReplaceRoomState replaces the saved state of a hibernated room
+		// without counting as activity.
+		ReplaceRoomState(ctx context.Context, roomID string, state []byte) error
+	}
 )
+
+// AutosaveDescription is the description of the snapshots the app saves
+// automatically, which tells them apart from snapshots users saved.
+const AutosaveDescription = "Automatic snapshot"
+
+// Allows reports whether userID may join the room; anonymous users have an
+// empty id and can only join rooms that aren't private.
+func (p *RoomPermissions) Allows(userID string) bool {
+	if p.Visibility != RoomPrivate {
+		return true
+	}
+	if userID == "" {
+		return false
+	}
+	if userID == p.Owner {
+		return true
+	}
+	for _, allowed := range p.AllowedUsers {
+		if allowed == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Descending reports whether the page lists the newest or Z first.
+func (o CanvasListOptions) Descending() bool {
+	if o.Ascending != nil {
+		return !*o.Ascending
+	}
+	return o.Sort != CanvasSortName
+}
+
+// SortName is what CanvasSortName orders the canvas by.
+func (c Canvas) SortName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Key
+}
+
+// Apply changes metadata as update says.
+func (m *CanvasMetadata) Apply(update CanvasMetadataUpdate) {
+	if update.Name != nil {
+		m.Name = *update.Name
+	}
+	if update.Tags != nil {
+		m.Tags = append([]string(nil), *update.Tags...)
+	}
+}
+
+// CursorAfter returns the cursor of a page that ends with canvas.
+func (o CanvasListOptions) CursorAfter(canvas Canvas) string {
+	value := "n" + canvas.SortName() + "\x00" + canvas.Key
+	if o.Sort != CanvasSortName {
+		value = "u" + strconv.FormatInt(canvas.UpdatedAt, 10) + ":" + canvas.Key
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// After decodes Cursor into the last canvas of the previous page, of which
+// only the key and either the name or UpdatedAt are set. ok is false
+// without a cursor.
+func (o CanvasListOptions) After() (last Canvas, ok bool, err error) {
+	if o.Cursor == "" {
+		return Canvas{}, false, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil || len(raw) == 0 {
+		return Canvas{}, false, ErrInvalidCursor
+	}
+	value := string(raw)
+	if o.Sort == CanvasSortName {
+		name, key, found := strings.Cut(value[1:], "\x00")
+		if value[0] != 'n' || !found || name == "" {
+			return Canvas{}, false, ErrInvalidCursor
+		}
+		return Canvas{Key: key, CanvasMetadata: CanvasMetadata{Name: name}}, true, nil
+	}
+	updated, key, found := strings.Cut(value[1:], ":")
+	updatedAt, err := strconv.ParseInt(updated, 10, 64)
+	if value[0] != 'u' || !found || err != nil {
+		return Canvas{}, false, ErrInvalidCursor
+	}
+	return Canvas{Key: key, UpdatedAt: updatedAt}, true, nil
+}
+
+// PageCanvases sorts canvases and cuts the page opts selects out of them,
+// for stores that list every canvas anyway.
+func PageCanvases(canvases []Canvas, opts CanvasListOptions) (CanvasPage, error) {
+	last, hasCursor, err := opts.After()
+	if err != nil {
+		return CanvasPage{}, err
+	}
+	desc := opts.Descending()
+	// before reports whether a is listed before b
+	before := func(a, b Canvas) bool {
+		if opts.Sort != CanvasSortName && a.UpdatedAt != b.UpdatedAt {
+			return (a.UpdatedAt > b.UpdatedAt) == desc
+		}
+		if opts.Sort == CanvasSortName && a.SortName() != b.SortName() {
+			return (a.SortName() > b.SortName()) == desc
+		}
+		return (a.Key > b.Key) == desc && a.Key != b.Key
+	}
+	sort.Slice(canvases, func(i, j int) bool { return before(canvases[i], canvases[j]) })
+
+	start := 0
+	if hasCursor {
+		start = sort.Search(len(canvases), func(i int) bool { return before(last, canvases[i]) })
+	}
+	page := CanvasPage{Canvases: canvases[start:]}
+	if opts.Limit > 0 && len(page.Canvases) > opts.Limit {
+		page.Canvases = page.Canvases[:opts.Limit]
+		page.NextCursor = opts.CursorAfter(page.Canvases[opts.Limit-1])
+	}
+	return page, nil
+}
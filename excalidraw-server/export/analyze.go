@@ -0,0 +1,150 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// Analysis summarizes what a scene is made of
+	Analysis struct {
+		// Elements counts the elements that aren't deleted; Types breaks
+		// them down by type
+		Elements int            `json:"elements"`
+		Deleted  int            `json:"deleted"`
+		Types    map[string]int `json:"types"`
+		Text     TextStats      `json:"text"`
+		// Bounds is the area the elements cover in scene coordinates, nil
+		// for an empty scene
+		Bounds *Bounds      `json:"bounds"`
+		Images []ImageStats `json:"images"`
+		// MissingFiles are referenced by image elements but not embedded
+		MissingFiles []string `json:"missing_files"`
+		// Components are the top-level groups. The editor inserts library
+		// items as a group; scenes don't record which library one came from.
+		Components []Component `json:"components"`
+	}
+
+	TextStats struct {
+		Elements   int `json:"elements"`
+		Words      int `json:"words"`
+		Characters int `json:"characters"`
+	}
+
+	Bounds struct {
+		X      float64 `json:"x"`
+		Y      float64 `json:"y"`
+		Width  float64 `json:"width"`
+		Height float64 `json:"height"`
+	}
+
+	// ImageStats describes a file embedded in the scene
+	ImageStats struct {
+		FileID   string `json:"file_id"`
+		MimeType string `json:"mime_type"`
+		// Bytes is the decoded size of the file
+		Bytes int `json:"bytes"`
+		// Width and Height are in pixels, 0 for formats that aren't decoded
+		// such as SVG
+		Width  int `json:"width"`
+		Height int `json:"height"`
+		// Uses counts the image elements showing the file
+		Uses int `json:"uses"`
+	}
+
+	Component struct {
+		GroupID  string         `json:"group_id"`
+		Elements int            `json:"elements"`
+		Types    map[string]int `json:"types"`
+	}
+)
+
+// Analyze counts the elements, text, images and groups of scene
+func Analyze(scene *Scene) Analysis {
+	a := Analysis{
+		Types:        make(map[string]int),
+		Images:       []ImageStats{},
+		MissingFiles: []string{},
+		Components:   []Component{},
+	}
+	uses := make(map[string]int)
+	components := make(map[string]int)
+	b := &builder{files: scene.Files, outlineOnly: true}
+
+	for i := range scene.Elements {
+		el := &scene.Elements[i]
+		if el.IsDeleted {
+			a.Deleted++
+			continue
+		}
+		a.Elements++
+		a.Types[el.Type]++
+		b.element(el)
+
+		switch el.Type {
+		case "text":
+			a.Text.Elements++
+			a.Text.Words += len(strings.Fields(el.Text))
+			a.Text.Characters += utf8.RuneCountInString(el.Text)
+		case "image":
+			if el.FileID != "" {
+				uses[el.FileID]++
+			}
+		}
+
+		if len(el.GroupIDs) > 0 {
+			outer := el.GroupIDs[len(el.GroupIDs)-1]
+			index, seen := components[outer]
+			if !seen {
+				index = len(a.Components)
+				components[outer] = index
+				a.Components = append(a.Components, Component{GroupID: outer, Types: make(map[string]int)})
+			}
+			a.Components[index].Elements++
+			a.Components[index].Types[el.Type]++
+		}
+	}
+
+	if minX, minY, maxX, maxY, ok := bounds(b.items); ok {
+		a.Bounds = &Bounds{X: round2(minX), Y: round2(minY), Width: round2(maxX - minX), Height: round2(maxY - minY)}
+	}
+
+	for id, file := range scene.Files {
+		a.Images = append(a.Images, imageStats(id, file, uses[id]))
+	}
+	sort.Slice(a.Images, func(i, j int) bool { return a.Images[i].FileID < a.Images[j].FileID })
+	for id := range uses {
+		if _, ok := scene.Files[id]; !ok {
+			a.MissingFiles = append(a.MissingFiles, id)
+		}
+	}
+	sort.Strings(a.MissingFiles)
+	return a
+}
+
+func imageStats(id string, file File, uses int) ImageStats {
+	stats := ImageStats{FileID: id, MimeType: file.MimeType, Uses: uses}
+	header, payload, ok := strings.Cut(file.DataURL, ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return stats
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return stats
+	}
+	stats.Bytes = len(data)
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		stats.Width, stats.Height = cfg.Width, cfg.Height
+	}
+	return stats
+}
+
+// round2 rounds to two decimals, which is plenty for scene coordinates
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(img.Bytes())
+
+	scene := parse(t, `{
+		"elements": [
+			{"id": "r", "type": "rectangle", "x": 10, "y": 20, "width": 100, "height": 50, "strokeWidth": 2, "groupIds": ["inner", "card"]},
+			{"id": "t", "type": "text", "x": 20, "y": 30, "width": 60, "height": 20, "text": "Two words", "fontSize": 16, "groupIds": ["card"]},
+			{"id": "i", "type": "image", "x": 30, "y": 40, "width": 30, "height": 20, "fileId": "f1"},
+			{"id": "i2", "type": "image", "x": 30, "y": 40, "width": 30, "height": 20, "fileId": "f1"},
+			{"id": "m", "type": "image", "x": 30, "y": 40, "width": 30, "height": 20, "fileId": "gone"},
+			{"id": "d", "type": "ellipse", "x": 5000, "y": 5000, "width": 10, "height": 10, "isDeleted": true}
+		],
+		"files": {"f1": {"mimeType": "image/png", "dataURL": "`+dataURL+`"}}
+	}`)
+
+	a := Analyze(scene)
+	if a.Elements != 5 || a.Deleted != 1 {
+		t.Errorf("Elements, Deleted = %d, %d, want 5, 1", a.Elements, a.Deleted)
+	}
+	if want := map[string]int{"rectangle": 1, "text": 1, "image": 3}; !reflect.DeepEqual(a.Types, want) {
+		t.Errorf("Types = %v, want %v", a.Types, want)
+	}
+	if want := (TextStats{Elements: 1, Words: 2, Characters: 9}); a.Text != want {
+		t.Errorf("Text = %+v, want %+v", a.Text, want)
+	}
+	// The rectangle's stroke reaches a pixel past its outline
+	if want := (Bounds{X: 9, Y: 19, Width: 102, Height: 52}); a.Bounds == nil || *a.Bounds != want {
+		t.Errorf("Bounds = %+v, want %+v", a.Bounds, want)
+	}
+	want := []ImageStats{{FileID: "f1", MimeType: "image/png", Bytes: img.Len(), Width: 3, Height: 2, Uses: 2}}
+	if !reflect.DeepEqual(a.Images, want) {
+		t.Errorf("Images = %+v, want %+v", a.Images, want)
+	}
+	if !reflect.DeepEqual(a.MissingFiles, []string{"gone"}) {
+		t.Errorf("MissingFiles = %v, want [gone]", a.MissingFiles)
+	}
+	wantComponents := []Component{{GroupID: "card", Elements: 2, Types: map[string]int{"rectangle": 1, "text": 1}}}
+	if !reflect.DeepEqual(a.Components, wantComponents) {
+		t.Errorf("Components = %+v, want %+v", a.Components, wantComponents)
+	}
+
+	if empty := Analyze(parse(t, `{"elements": []}`)); empty.Bounds != nil || empty.Elements != 0 {
+		t.Errorf("Analyze() of an empty scene = %+v", empty)
+	}
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// rgba is a straight (not premultiplied) color with components in [0, 1]
+type rgba struct {
+	R, G, B, A float64
+}
+
+var (
+	black       = rgba{0, 0, 0, 1}
+	white       = rgba{1, 1, 1, 1}
+	transparent = rgba{}
+)
+
+var namedColors = map[string]rgba{
+	"black":       black,
+	"white":       white,
+	"transparent": transparent,
+	"red":         {1, 0, 0, 1},
+	"green":       {0, 0.5, 0, 1},
+	"blue":        {0, 0, 1, 1},
+	"yellow":      {1, 1, 0, 1},
+	"orange":      {1, 0.647, 0, 1},
+	"gray":        {0.5, 0.5, 0.5, 1},
+	"grey":        {0.5, 0.5, 0.5, 1},
+}
+
+// parseColor parses the CSS colors Excalidraw writes: #rgb, #rgba, #rrggbb,
+// #rrggbbaa and a few names. Anything else falls back to def.
+func parseColor(s string, def rgba) rgba {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c
+	}
+	if !strings.HasPrefix(s, "#") {
+		return def
+	}
+	hex := s[1:]
+	if len(hex) == 3 || len(hex) == 4 {
+		var long strings.Builder
+		for _, ch := range hex {
+			long.WriteRune(ch)
+			long.WriteRune(ch)
+		}
+		hex = long.String()
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return def
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return def
+	}
+	return rgba{
+		R: float64(v>>24&0xff) / 255,
+		G: float64(v>>16&0xff) / 255,
+		B: float64(v>>8&0xff) / 255,
+		A: float64(v&0xff) / 255,
+	}
+}
+
+func (c rgba) visible() bool {
+	return c.A > 0
+}
+
+func (c rgba) withAlpha(a float64) rgba {
+	c.A *= a
+	return c
+}
+
+// dark applies the filter Excalidraw uses for its dark theme,
+// invert(93%) hue-rotate(180deg).
+func (c rgba) dark() rgba {
+	const amount = 0.93
+	r := c.R*(1-amount) + (1-c.R)*amount
+	g := c.G*(1-amount) + (1-c.G)*amount
+	b := c.B*(1-amount) + (1-c.B)*amount
+	return rgba{
+		R: clamp01(-0.574*r + 1.430*g + 0.144*b),
+		G: clamp01(0.426*r + 0.430*g + 0.144*b),
+		B: clamp01(0.426*r + 1.430*g - 0.856*b),
+		A: c.A,
+	}
+}
+
+// hex formats the color as #rrggbb, ignoring alpha
+func (c rgba) hex() string {
+	return "#" + hexByte(c.R) + hexByte(c.G) + hexByte(c.B)
+}
+
+func hexByte(v float64) string {
+	s := strconv.FormatUint(uint64(math.Round(clamp01(v)*255)), 16)
+	if len(s) == 1 {
+		return "0" + s
+	}
+	return s
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,489 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	_ "image/gif"  // decode embedded GIFs
+	_ "image/jpeg" // decode embedded JPEGs
+	_ "image/png"  // decode embedded PNGs
+	"math"
+	"strings"
+)
+
+const (
+	// padding surrounds the exported scene, as in Excalidraw's own export
+	padding           = 10
+	defaultFontSize   = 20
+	defaultLineHeight = 1.25
+	// capHeight is a fraction of the font size, used to place baselines the
+	// same way in every format
+	capHeight = 0.7
+)
+
+const (
+	fontHand = iota
+	fontSans
+	fontMono
+)
+
+type (
+	style struct {
+		fill   rgba
+		stroke rgba
+		width  float64
+		dash   []float64
+	}
+
+	shape struct {
+		path      path
+		style     style
+		transform matrix
+	}
+
+	textLine struct {
+		text string
+		// x is where the line is anchored according to align, and baseline
+		// its baseline, in the element's coordinates
+		x, baseline float64
+	}
+
+	text struct {
+		lines     []textLine
+		align     string
+		fontSize  float64
+		font      int
+		color     rgba
+		transform matrix
+	}
+
+	picture struct {
+		img image.Image
+		// dataURL is passed through to SVG, which also handles SVG images
+		dataURL   string
+		mimeType  string
+		width     float64
+		height    float64
+		opacity   float64
+		transform matrix
+	}
+
+	// drawing is a scene laid out for export: width × height units with the
+	// items' transforms already moved into that box
+	drawing struct {
+		width, height float64
+		background    rgba
+		items         []any
+	}
+)
+
+type builder struct {
+	dark  bool
+	files map[string]File
+	items []any
+	// outlineOnly skips decoding images, for callers that only need bounds
+	outlineOnly bool
+}
+
+func (b *builder) color(s string, def rgba) rgba {
+	c := parseColor(s, def)
+	if b.dark {
+		c = c.dark()
+	}
+	return c
+}
+
+// layout turns the scene into a drawing
+func layout(scene *Scene, dark bool) *drawing {
+	b := &builder{dark: dark, files: scene.Files}
+	for i := range scene.Elements {
+		el := &scene.Elements[i]
+		if el.IsDeleted {
+			continue
+		}
+		b.element(el)
+	}
+
+	minX, minY, maxX, maxY, ok := bounds(b.items)
+	if !ok {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+
+	d := &drawing{
+		width:      math.Ceil(maxX-minX) + 2*padding,
+		height:     math.Ceil(maxY-minY) + 2*padding,
+		background: b.color(scene.AppState.ViewBackgroundColor, white),
+		items:      b.items,
+	}
+	shift := translate(padding-minX, padding-minY)
+	for _, item := range d.items {
+		switch item := item.(type) {
+		case *shape:
+			item.transform = item.transform.then(shift)
+		case *text:
+			item.transform = item.transform.then(shift)
+		case *picture:
+			item.transform = item.transform.then(shift)
+		}
+	}
+	return d
+}
+
+// bounds returns the bounding box of items, or false when none has any
+// extent
+func bounds(items []any) (minX, minY, maxX, maxY float64, ok bool) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, item := range items {
+		for _, pt := range itemBounds(item) {
+			minX, minY = math.Min(minX, pt.X), math.Min(minY, pt.Y)
+			maxX, maxY = math.Max(maxX, pt.X), math.Max(maxY, pt.Y)
+		}
+	}
+	return minX, minY, maxX, maxY, !math.IsInf(minX, 1)
+}
+
+// itemBounds returns points whose bounding box covers the item
+func itemBounds(item any) []point {
+	var pts []point
+	switch item := item.(type) {
+	case *shape:
+		grow := 0.0
+		if item.style.stroke.visible() {
+			grow = item.style.width / 2
+		}
+		for _, line := range item.path.flatten(item.transform, 4) {
+			for _, pt := range line.pts {
+				pts = append(pts, pt.add(point{-grow, -grow}), pt.add(point{grow, grow}))
+			}
+		}
+	case *text:
+		for _, line := range item.lines {
+			width := textWidth(line.text, item.font, item.fontSize)
+			left := line.x
+			switch item.align {
+			case "center":
+				left -= width / 2
+			case "right":
+				left -= width
+			}
+			top := line.baseline - item.fontSize
+			bottom := line.baseline + item.fontSize*0.3
+			for _, pt := range []point{{left, top}, {left + width, top}, {left, bottom}, {left + width, bottom}} {
+				pts = append(pts, item.transform.apply(pt))
+			}
+		}
+	case *picture:
+		for _, pt := range []point{{0, 0}, {item.width, 0}, {0, item.height}, {item.width, item.height}} {
+			pts = append(pts, item.transform.apply(pt))
+		}
+	}
+	return pts
+}
+
+func (b *builder) element(el *Element) {
+	opacity := 1.0
+	if el.Opacity != nil {
+		opacity = clamp01(*el.Opacity / 100)
+	}
+	strokeWidth := el.StrokeWidth
+	if strokeWidth <= 0 {
+		strokeWidth = 1
+	}
+	stroke := style{
+		stroke: b.color(el.StrokeColor, black).withAlpha(opacity),
+		width:  strokeWidth,
+	}
+	switch el.StrokeStyle {
+	case "dashed":
+		stroke.dash = []float64{8, 8 + strokeWidth}
+	case "dotted":
+		stroke.dash = []float64{1.5, 6 + strokeWidth}
+	}
+	background := b.color(el.BackgroundColor, transparent).withAlpha(opacity)
+
+	// Elements rotate around their center
+	transform := rotateAbout(el.Angle, point{el.X + el.Width/2, el.Y + el.Height/2})
+
+	switch el.Type {
+	case "rectangle", "frame", "magicframe", "embeddable", "iframe":
+		b.filled(rectanglePath(el), background, el, stroke, transform)
+	case "diamond":
+		w, h := el.Width, el.Height
+		outline := polygonPath(
+			point{el.X + w/2, el.Y}, point{el.X + w, el.Y + h/2},
+			point{el.X + w/2, el.Y + h}, point{el.X, el.Y + h/2})
+		b.filled(outline, background, el, stroke, transform)
+	case "ellipse":
+		outline := ellipsePath(point{el.X + el.Width/2, el.Y + el.Height/2}, el.Width/2, el.Height/2)
+		b.filled(outline, background, el, stroke, transform)
+	case "line", "arrow":
+		b.linear(el, background, stroke, transform)
+	case "freedraw":
+		b.freedraw(el, stroke, transform)
+	case "text":
+		b.text(el, opacity, transform)
+	case "image":
+		b.image(el, opacity, transform)
+	}
+}
+
+func rectanglePath(el *Element) path {
+	x, y, w, h := el.X, el.Y, el.Width, el.Height
+	if el.Roundness == nil {
+		return polygonPath(point{x, y}, point{x + w, y}, point{x + w, y + h}, point{x, y + h})
+	}
+
+	// Excalidraw's adaptive radius is a quarter of the shorter side, capped
+	// at 32; the older proportional type isn't capped
+	r := math.Min(math.Abs(w), math.Abs(h)) / 4
+	if el.Roundness.Type == 3 {
+		r = math.Min(r, 32)
+	}
+	var p path
+	p.moveTo(point{x + r, y})
+	p.lineTo(point{x + w - r, y})
+	p.quadTo(point{x + w, y}, point{x + w, y + r})
+	p.lineTo(point{x + w, y + h - r})
+	p.quadTo(point{x + w, y + h}, point{x + w - r, y + h})
+	p.lineTo(point{x + r, y + h})
+	p.quadTo(point{x, y + h}, point{x, y + h - r})
+	p.lineTo(point{x, y + r})
+	p.quadTo(point{x, y}, point{x + r, y})
+	p.close()
+	return p
+}
+
+// filled adds a closed shape with its fill and outline
+func (b *builder) filled(outline path, background rgba, el *Element, stroke style, transform matrix) {
+	if background.visible() {
+		switch el.FillStyle {
+		case "solid":
+			b.items = append(b.items, &shape{path: outline, style: style{fill: background}, transform: transform})
+		default:
+			// hachure, cross-hatch and zigzag
+			polygons := outline.flatten(identity, 2)
+			gap := math.Max(4, stroke.width*4)
+			lines := style{stroke: background, width: math.Max(0.5, stroke.width/2)}
+			angle := -41 * math.Pi / 180
+			b.items = append(b.items, &shape{path: hatch(polygons, angle, gap), style: lines, transform: transform})
+			if el.FillStyle == "cross-hatch" {
+				b.items = append(b.items, &shape{path: hatch(polygons, angle+math.Pi/2, gap), style: lines, transform: transform})
+			}
+		}
+	}
+	b.items = append(b.items, &shape{path: outline, style: stroke, transform: transform})
+}
+
+func elementPoints(el *Element) []point {
+	pts := make([]point, 0, len(el.Points))
+	for _, p := range el.Points {
+		if len(p) >= 2 {
+			pts = append(pts, point{el.X + p[0], el.Y + p[1]})
+		}
+	}
+	return pts
+}
+
+// smoothPath runs a Catmull-Rom spline through pts, as Excalidraw draws
+// rounded lines and arrows
+func smoothPath(pts []point) path {
+	var p path
+	p.moveTo(pts[0])
+	for i := 0; i+1 < len(pts); i++ {
+		prev, next := pts[max(i-1, 0)], pts[min(i+2, len(pts)-1)]
+		c1 := pts[i].add(pts[i+1].sub(prev).mul(1.0 / 6))
+		c2 := pts[i+1].sub(next.sub(pts[i]).mul(1.0 / 6))
+		p.cubicTo(c1, c2, pts[i+1])
+	}
+	return p
+}
+
+func (b *builder) linear(el *Element, background rgba, stroke style, transform matrix) {
+	pts := elementPoints(el)
+	if len(pts) < 2 {
+		return
+	}
+	var line path
+	if el.Roundness != nil && len(pts) > 2 {
+		line = smoothPath(pts)
+	} else {
+		line = polygonPath(pts...)
+		line = line[:len(line)-1]
+	}
+
+	closed := len(pts) > 2 && pts[0].sub(pts[len(pts)-1]).length() < 1e-6
+	if el.Type == "line" && closed {
+		b.filled(line, background, el, stroke, transform)
+	} else {
+		b.items = append(b.items, &shape{path: line, style: stroke, transform: transform})
+	}
+
+	if el.Type != "arrow" {
+		return
+	}
+	solid := stroke
+	solid.dash = nil
+	n := len(pts)
+	if el.EndArrowhead != "" {
+		b.arrowhead(el.EndArrowhead, pts[n-1], pts[n-2], solid, transform)
+	}
+	if el.StartArrowhead != "" {
+		b.arrowhead(el.StartArrowhead, pts[0], pts[1], solid, transform)
+	}
+}
+
+// arrowhead draws head at tip, pointing away from from
+func (b *builder) arrowhead(head string, tip, from point, stroke style, transform matrix) {
+	segment := tip.sub(from)
+	length := segment.length()
+	if length == 0 {
+		return
+	}
+	dir := segment.mul(1 / length)
+
+	size, angle := 15.0, 25.0
+	switch head {
+	case "arrow":
+		size, angle = 25, 20
+	case "diamond", "diamond_outline":
+		size = 12
+	}
+	lengthFactor := 0.5
+	if strings.HasPrefix(head, "diamond") {
+		lengthFactor = 0.25
+	}
+	size = math.Min(size, length*lengthFactor)
+	back := dir.mul(-size)
+	side := func(deg float64) point { return tip.add(back.rotate(deg * math.Pi / 180)) }
+
+	filled := stroke
+	filled.fill = stroke.stroke
+	var p path
+	switch head {
+	case "arrow":
+		p.moveTo(side(angle))
+		p.lineTo(tip)
+		p.lineTo(side(-angle))
+		b.items = append(b.items, &shape{path: p, style: stroke, transform: transform})
+	case "bar":
+		p.moveTo(tip.add(back.rotate(math.Pi / 2).mul(0.5)))
+		p.lineTo(tip.add(back.rotate(-math.Pi / 2).mul(0.5)))
+		b.items = append(b.items, &shape{path: p, style: stroke, transform: transform})
+	case "triangle", "triangle_outline":
+		p = polygonPath(tip, side(angle), side(-angle))
+	case "dot", "circle", "circle_outline":
+		p = ellipsePath(tip.add(back.mul(0.5)), size/2, size/2)
+	case "diamond", "diamond_outline":
+		mid := tip.add(back)
+		half := back.rotate(math.Pi / 2).mul(0.5)
+		p = polygonPath(tip, mid.add(half), tip.add(back.mul(2)), mid.sub(half))
+	default:
+		return
+	}
+	if strings.HasSuffix(head, "_outline") {
+		b.items = append(b.items, &shape{path: p, style: stroke, transform: transform})
+	} else if head != "arrow" && head != "bar" {
+		b.items = append(b.items, &shape{path: p, style: filled, transform: transform})
+	}
+}
+
+// freedraw approximates Excalidraw's pressure-sensitive strokes with a round
+// line of constant width
+func (b *builder) freedraw(el *Element, stroke style, transform matrix) {
+	pts := elementPoints(el)
+	if len(pts) == 0 {
+		return
+	}
+	width := stroke.width * 2.25
+	if len(pts) == 1 {
+		dot := style{fill: stroke.stroke}
+		b.items = append(b.items, &shape{path: ellipsePath(pts[0], width/2, width/2), style: dot, transform: transform})
+		return
+	}
+	line := polygonPath(pts...)
+	b.items = append(b.items, &shape{
+		path:      line[:len(line)-1],
+		style:     style{stroke: stroke.stroke, width: width},
+		transform: transform,
+	})
+}
+
+func (b *builder) text(el *Element, opacity float64, transform matrix) {
+	if el.Text == "" {
+		return
+	}
+	fontSize := el.FontSize
+	if fontSize <= 0 {
+		fontSize = defaultFontSize
+	}
+	lineHeight := el.LineHeight
+	if lineHeight <= 0 {
+		lineHeight = defaultLineHeight
+	}
+	font := fontFor(el.FontFamily)
+
+	x := el.X
+	switch el.TextAlign {
+	case "center":
+		x += el.Width / 2
+	case "right":
+		x += el.Width
+	}
+	t := &text{
+		align:     el.TextAlign,
+		fontSize:  fontSize,
+		font:      font,
+		color:     b.color(el.StrokeColor, black).withAlpha(opacity),
+		transform: transform,
+	}
+	// Center the cap height in each line box
+	for i, line := range strings.Split(strings.ReplaceAll(el.Text, "\r\n", "\n"), "\n") {
+		top := el.Y + float64(i)*fontSize*lineHeight
+		t.lines = append(t.lines, textLine{
+			text:     strings.Map(printable, line),
+			x:        x,
+			baseline: top + (fontSize*lineHeight+fontSize*capHeight)/2,
+		})
+	}
+	b.items = append(b.items, t)
+}
+
+// printable turns tabs into spaces and drops other control characters,
+// which XML and the fonts can't carry
+func printable(r rune) rune {
+	switch {
+	case r == '\t':
+		return ' '
+	case r < ' ' || r == 0x7f:
+		return -1
+	}
+	return r
+}
+
+func (b *builder) image(el *Element, opacity float64, transform matrix) {
+	file, ok := b.files[el.FileID]
+	if !ok {
+		return
+	}
+	header, payload, ok := strings.Cut(file.DataURL, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return
+	}
+	pic := &picture{
+		dataURL:   file.DataURL,
+		mimeType:  strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64"),
+		width:     el.Width,
+		height:    el.Height,
+		opacity:   opacity,
+		transform: translate(el.X, el.Y).then(transform),
+	}
+	if b.outlineOnly {
+		b.items = append(b.items, pic)
+		return
+	}
+	if data, err := base64.StdEncoding.DecodeString(payload); err == nil {
+		pic.img, _, _ = image.Decode(bytes.NewReader(data))
+	}
+	b.items = append(b.items, pic)
+}
@@ -0,0 +1,98 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	PNG Format = "png"
+	SVG Format = "svg"
+	PDF Format = "pdf"
+)
+
+const (
+	// MaxScale limits the scale factor
+	MaxScale = 4
+	// maxPixels limits PNG size, about 64 MB of pixels in memory
+	maxPixels = 16 << 20
+)
+
+// ErrTooLarge is returned when a scene is too big to render as a PNG at the
+// requested scale
+var ErrTooLarge = errors.New("image too large")
+
+// ContentType returns the MIME type of f, or "" for an unknown format
+func (f Format) ContentType() string {
+	switch f {
+	case PNG:
+		return "image/png"
+	case SVG:
+		return "image/svg+xml"
+	case PDF:
+		return "application/pdf"
+	}
+	return ""
+}
+
+type Options struct {
+	// Scale multiplies the output size; values outside (0, MaxScale] mean 1
+	Scale float64
+	// Dark renders with Excalidraw's dark theme colors
+	Dark bool
+}
+
+// Render draws scene to w in the given format
+func Render(w io.Writer, scene *Scene, format Format, opts Options) error {
+	scale := opts.Scale
+	if scale <= 0 || scale > MaxScale || math.IsNaN(scale) {
+		scale = 1
+	}
+	d := layout(scene, opts.Dark)
+
+	switch format {
+	case SVG:
+		return writeSVG(w, d, scale)
+	case PDF:
+		return writePDF(w, d, scale)
+	case PNG:
+		if math.Ceil(d.width*scale)*math.Ceil(d.height*scale) > maxPixels {
+			return ErrTooLarge
+		}
+		return writePNG(w, d, scale)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// Size returns the size of scene's export at scale 1
+func Size(scene *Scene) (width, height float64) {
+	d := layout(scene, false)
+	return d.width, d.height
+}
+
+// maxThumbnailScale stops small scenes from being blown up into a few huge
+// shapes
+const maxThumbnailScale = 2
+
+// Thumbnail draws scene as a width × height PNG, scaled to fit and centered
+// on the scene's background, for link previews.
+func Thumbnail(w io.Writer, scene *Scene, width, height int, dark bool) error {
+	if width <= 0 || height <= 0 || width*height > maxPixels {
+		return ErrTooLarge
+	}
+	d := layout(scene, dark)
+	scale := math.Min(float64(width)/d.width, float64(height)/d.height)
+	scale = math.Min(scale, maxThumbnailScale)
+	center := translate((float64(width)-d.width*scale)/2, (float64(height)-d.height*scale)/2)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	paint(dst, d, scaling(scale).then(center))
+	return png.Encode(w, dst)
+}
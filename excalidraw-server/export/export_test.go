@@ -0,0 +1,188 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testScene has a solid red square at the origin, a hatched ellipse, an
+// arrow and a line of text
+const testScene = `{
+	"type": "excalidraw",
+	"elements": [
+		{"id": "r", "type": "rectangle", "x": 0, "y": 0, "width": 100, "height": 100,
+		 "strokeColor": "#1e1e1e", "backgroundColor": "#ff0000", "fillStyle": "solid", "strokeWidth": 2},
+		{"id": "e", "type": "ellipse", "x": 150, "y": 0, "width": 80, "height": 60,
+		 "strokeColor": "#1971c2", "backgroundColor": "#a5d8ff", "fillStyle": "hachure", "strokeWidth": 1, "strokeStyle": "dashed"},
+		{"id": "a", "type": "arrow", "x": 0, "y": 150, "width": 200, "height": 0,
+		 "points": [[0, 0], [200, 0]], "endArrowhead": "triangle", "strokeColor": "#000000"},
+		{"id": "t", "type": "text", "x": 0, "y": 200, "width": 120, "height": 25,
+		 "text": "Hello <b>", "fontSize": 20, "fontFamily": 1, "textAlign": "left"},
+		{"id": "gone", "type": "rectangle", "x": 5000, "y": 5000, "width": 10, "height": 10, "isDeleted": true}
+	],
+	"appState": {"viewBackgroundColor": "#ffffff"},
+	"files": {}
+}`
+
+func parse(t *testing.T, data string) *Scene {
+	t.Helper()
+	scene, err := ParseScene([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseScene() failed: %v", err)
+	}
+	return scene
+}
+
+func TestParseSceneRejectsOtherDocuments(t *testing.T) {
+	for _, data := range []string{"", "\x00\x01encrypted", `{"type":"excalidrawlib","libraryItems":[]}`} {
+		if _, err := ParseScene([]byte(data)); !errors.Is(err, ErrNotScene) {
+			t.Errorf("ParseScene(%q) error = %v, want ErrNotScene", data, err)
+		}
+	}
+	if _, err := ParseScene([]byte(`{"elements":[]}`)); err != nil {
+		t.Errorf("empty scene error = %v", err)
+	}
+}
+
+func TestLayoutBounds(t *testing.T) {
+	d := layout(parse(t, testScene), false)
+	// The deleted element is ignored; the rest spans about 230 × 225
+	if d.width < 230 || d.width > 260 || d.height < 225 || d.height > 260 {
+		t.Errorf("drawing size = %v × %v", d.width, d.height)
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, parse(t, testScene), SVG, Options{Scale: 2}); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	svg := buf.String()
+
+	for _, want := range []string{`fill="#ff0000"`, `stroke-dasharray="8 9"`, "Hello &lt;b&gt;", `fill="#ffffff"`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG is missing %s", want)
+		}
+	}
+	size := regexp.MustCompile(`width="([\d.]+)" height="([\d.]+)" viewBox="0 0 ([\d.]+) ([\d.]+)"`).FindStringSubmatch(svg)
+	if size == nil {
+		t.Fatalf("SVG has no size: %s", svg[:100])
+	}
+	width, _ := strconv.ParseFloat(size[1], 64)
+	viewWidth, _ := strconv.ParseFloat(size[3], 64)
+	if width != 2*viewWidth {
+		t.Errorf("width = %v with a view box %v wide, want scale 2", width, viewWidth)
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, parse(t, testScene), PNG, Options{Scale: 2}); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+
+	d := layout(parse(t, testScene), false)
+	if img.Bounds().Dx() != int(d.width*2) || img.Bounds().Dy() != int(d.height*2) {
+		t.Errorf("PNG size = %v, drawing is %v × %v", img.Bounds().Size(), d.width, d.height)
+	}
+
+	// The middle of the square is red and the corner is background
+	r, g, b, _ := img.At(int(2*(padding+50+1)), int(2*(padding+50+1))).RGBA()
+	if r>>8 != 0xff || g>>8 != 0 || b>>8 != 0 {
+		t.Errorf("square center = %x %x %x, want red", r>>8, g>>8, b>>8)
+	}
+	if r, g, b, _ := img.At(1, 1).RGBA(); r>>8 != 0xff || g>>8 != 0xff || b>>8 != 0xff {
+		t.Errorf("corner = %x %x %x, want white", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestRenderDark(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, parse(t, testScene), SVG, Options{Dark: true}); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	// White becomes Excalidraw's dark background
+	if !strings.Contains(buf.String(), `fill="#121212"`) {
+		t.Error("dark SVG background isn't #121212")
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, parse(t, testScene), PDF, Options{}); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	pdf := buf.Bytes()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("not a PDF")
+	}
+
+	// Every cross-reference entry points at its object
+	xref := bytes.LastIndex(pdf, []byte("\nxref\n")) + 1
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(pdf[xref:], -1)
+	if len(entries) == 0 {
+		t.Fatal("empty xref table")
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj"; !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[offset:offset+10])
+		}
+	}
+	if !bytes.Contains(pdf, []byte("/BaseFont /Helvetica")) {
+		t.Error("PDF doesn't use Helvetica for text")
+	}
+}
+
+func TestRenderTooLarge(t *testing.T) {
+	scene := parse(t, `{"elements":[{"type":"rectangle","x":0,"y":0,"width":100000,"height":100000}]}`)
+	if err := Render(&bytes.Buffer{}, scene, PNG, Options{}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Render() error = %v, want ErrTooLarge", err)
+	}
+	// Vector formats have no pixel limit
+	if err := Render(&bytes.Buffer{}, scene, SVG, Options{}); err != nil {
+		t.Errorf("Render() SVG error = %v", err)
+	}
+}
+
+func TestDashes(t *testing.T) {
+	line := polyline{pts: []point{{0, 0}, {10, 0}, {10, 10}}}
+	got := dashes(line, []float64{4, 2})
+	// 20 units of line in 6-unit periods: on 0-4, 6-10, 12-16, 18-20
+	if len(got) != 4 {
+		t.Fatalf("dashes = %v, want 4", got)
+	}
+	if end := got[1][len(got[1])-1]; end != (point{10, 0}) {
+		t.Errorf("second dash ends at %v, want the corner", end)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Thumbnail(&buf, parse(t, testScene), 300, 100, false); err != nil {
+		t.Fatalf("Thumbnail() failed: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != 300 || size.Y != 100 {
+		t.Errorf("thumbnail size = %v, want 300 × 100", size)
+	}
+	// The roughly square scene fits the 3:1 box by height, so it's centered
+	// with background on both sides
+	for _, x := range []int{0, 299} {
+		if r, g, b, _ := img.At(x, 50).RGBA(); r>>8 != 0xff || g>>8 != 0xff || b>>8 != 0xff {
+			t.Errorf("pixel at x=%d = %x %x %x, want background", x, r>>8, g>>8, b>>8)
+		}
+	}
+}
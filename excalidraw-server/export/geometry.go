@@ -0,0 +1,260 @@
+package export
+
+import (
+	"math"
+	"sort"
+)
+
+type point struct {
+	X, Y float64
+}
+
+func (p point) add(q point) point   { return point{p.X + q.X, p.Y + q.Y} }
+func (p point) sub(q point) point   { return point{p.X - q.X, p.Y - q.Y} }
+func (p point) mul(k float64) point { return point{p.X * k, p.Y * k} }
+func (p point) length() float64     { return math.Hypot(p.X, p.Y) }
+func (p point) rotate(angle float64) point {
+	sin, cos := math.Sincos(angle)
+	return point{p.X*cos - p.Y*sin, p.X*sin + p.Y*cos}
+}
+
+// matrix is an affine transform [a b c d e f] mapping (x, y) to
+// (a*x + c*y + e, b*x + d*y + f), as in SVG and PDF.
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func translate(x, y float64) matrix {
+	return matrix{1, 0, 0, 1, x, y}
+}
+
+func scaling(k float64) matrix {
+	return matrix{k, 0, 0, k, 0, 0}
+}
+
+// rotateAbout rotates by angle radians around center
+func rotateAbout(angle float64, center point) matrix {
+	sin, cos := math.Sincos(angle)
+	return matrix{cos, sin, -sin, cos,
+		center.X - cos*center.X + sin*center.Y,
+		center.Y - sin*center.X - cos*center.Y}
+}
+
+func (m matrix) apply(p point) point {
+	return point{m[0]*p.X + m[2]*p.Y + m[4], m[1]*p.X + m[3]*p.Y + m[5]}
+}
+
+// then returns the transform that applies m followed by n
+func (m matrix) then(n matrix) matrix {
+	return matrix{
+		n[0]*m[0] + n[2]*m[1],
+		n[1]*m[0] + n[3]*m[1],
+		n[0]*m[2] + n[2]*m[3],
+		n[1]*m[2] + n[3]*m[3],
+		n[0]*m[4] + n[2]*m[5] + n[4],
+		n[1]*m[4] + n[3]*m[5] + n[5],
+	}
+}
+
+// scale is the factor by which m scales lengths
+func (m matrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+func (m matrix) invert() matrix {
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 {
+		return identity
+	}
+	return matrix{
+		m[3] / det, -m[1] / det, -m[2] / det, m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det,
+		(m[1]*m[4] - m[0]*m[5]) / det,
+	}
+}
+
+type segmentKind int
+
+const (
+	moveTo segmentKind = iota
+	lineTo
+	cubicTo
+	closePath
+)
+
+// segment is one path command; cubicTo uses all three points, moveTo and
+// lineTo only the first.
+type segment struct {
+	kind segmentKind
+	pts  [3]point
+}
+
+type path []segment
+
+func (p *path) moveTo(pt point) { *p = append(*p, segment{kind: moveTo, pts: [3]point{pt}}) }
+func (p *path) lineTo(pt point) { *p = append(*p, segment{kind: lineTo, pts: [3]point{pt}}) }
+func (p *path) close()          { *p = append(*p, segment{kind: closePath}) }
+func (p *path) cubicTo(c1, c2, end point) {
+	*p = append(*p, segment{kind: cubicTo, pts: [3]point{c1, c2, end}})
+}
+
+// quadTo adds a quadratic curve as the equivalent cubic
+func (p *path) quadTo(ctrl, end point) {
+	start := p.current()
+	p.cubicTo(start.add(ctrl.sub(start).mul(2.0/3)), end.add(ctrl.sub(end).mul(2.0/3)), end)
+}
+
+func (p path) current() point {
+	for i := len(p) - 1; i >= 0; i-- {
+		switch p[i].kind {
+		case moveTo, lineTo:
+			return p[i].pts[0]
+		case cubicTo:
+			return p[i].pts[2]
+		}
+	}
+	return point{}
+}
+
+func polygonPath(pts ...point) path {
+	var p path
+	for i, pt := range pts {
+		if i == 0 {
+			p.moveTo(pt)
+		} else {
+			p.lineTo(pt)
+		}
+	}
+	p.close()
+	return p
+}
+
+// ellipsePath approximates an ellipse with four cubic curves
+func ellipsePath(center point, rx, ry float64) path {
+	const k = 0.5522847498
+	cx, cy := center.X, center.Y
+	var p path
+	p.moveTo(point{cx + rx, cy})
+	p.cubicTo(point{cx + rx, cy + k*ry}, point{cx + k*rx, cy + ry}, point{cx, cy + ry})
+	p.cubicTo(point{cx - k*rx, cy + ry}, point{cx - rx, cy + k*ry}, point{cx - rx, cy})
+	p.cubicTo(point{cx - rx, cy - k*ry}, point{cx - k*rx, cy - ry}, point{cx, cy - ry})
+	p.cubicTo(point{cx + k*rx, cy - ry}, point{cx + rx, cy - k*ry}, point{cx + rx, cy})
+	p.close()
+	return p
+}
+
+// polyline is a flattened subpath
+type polyline struct {
+	pts    []point
+	closed bool
+}
+
+// flatten transforms p by m and approximates its curves with line segments
+// no longer than about tolerance.
+func (p path) flatten(m matrix, tolerance float64) []polyline {
+	var lines []polyline
+	for _, seg := range p {
+		if seg.kind == moveTo {
+			lines = append(lines, polyline{pts: []point{m.apply(seg.pts[0])}})
+			continue
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		cur := &lines[len(lines)-1]
+		switch seg.kind {
+		case lineTo:
+			cur.pts = append(cur.pts, m.apply(seg.pts[0]))
+		case cubicTo:
+			p0 := cur.pts[len(cur.pts)-1]
+			p1, p2, p3 := m.apply(seg.pts[0]), m.apply(seg.pts[1]), m.apply(seg.pts[2])
+			hull := p1.sub(p0).length() + p2.sub(p1).length() + p3.sub(p2).length()
+			n := int(math.Ceil(hull / tolerance))
+			n = max(1, min(n, 100))
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				u := 1 - t
+				cur.pts = append(cur.pts, p0.mul(u*u*u).add(p1.mul(3*u*u*t)).add(p2.mul(3*u*t*t)).add(p3.mul(t*t*t)))
+			}
+		case closePath:
+			cur.closed = true
+		}
+	}
+	return lines
+}
+
+// hatch returns the lines filling the polygons at angle radians, gap apart,
+// like rough.js's hachure fill.
+func hatch(polygons []polyline, angle, gap float64) path {
+	var rotated [][]point
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, poly := range polygons {
+		pts := make([]point, len(poly.pts))
+		for i, pt := range poly.pts {
+			pts[i] = pt.rotate(-angle)
+			minY, maxY = math.Min(minY, pts[i].Y), math.Max(maxY, pts[i].Y)
+		}
+		rotated = append(rotated, pts)
+	}
+
+	var p path
+	for y := minY + gap/2; y < maxY; y += gap {
+		var xs []float64
+		for _, pts := range rotated {
+			for i := range pts {
+				a, b := pts[i], pts[(i+1)%len(pts)]
+				if (a.Y <= y) == (b.Y <= y) {
+					continue
+				}
+				xs = append(xs, a.X+(y-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			p.moveTo(point{xs[i], y}.rotate(angle))
+			p.lineTo(point{xs[i+1], y}.rotate(angle))
+		}
+	}
+	return p
+}
+
+// dashes splits a polyline into the dashes of pattern, which alternates on
+// and off lengths.
+func dashes(line polyline, pattern []float64) [][]point {
+	pts := line.pts
+	if line.closed && len(pts) > 0 {
+		pts = append(pts[:len(pts):len(pts)], pts[0])
+	}
+	if len(pts) == 0 {
+		return nil
+	}
+	var out [][]point
+	cur := []point{pts[0]}
+	idx, left, on := 0, pattern[0], true
+	for i := 1; i < len(pts); i++ {
+		a, b := pts[i-1], pts[i]
+		segLen := b.sub(a).length()
+		pos := 0.0
+		for segLen-pos > left {
+			pos += left
+			at := a.add(b.sub(a).mul(pos / segLen))
+			if on {
+				out = append(out, append(cur, at))
+				cur = nil
+			} else {
+				cur = []point{at}
+			}
+			on = !on
+			idx = (idx + 1) % len(pattern)
+			left = pattern[idx]
+		}
+		left -= segLen - pos
+		if on {
+			cur = append(cur, b)
+		}
+	}
+	if on && len(cur) > 1 {
+		out = append(out, cur)
+	}
+	return out
+}
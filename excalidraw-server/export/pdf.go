@@ -0,0 +1,251 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+)
+
+// pdfFonts are standard fonts every PDF viewer has, so nothing is embedded
+var pdfFonts = map[int]string{
+	fontHand: "Helvetica",
+	fontSans: "Helvetica",
+	fontMono: "Courier",
+}
+
+// pdfWriter collects numbered objects for a single-page PDF
+type pdfWriter struct {
+	objects [][]byte
+}
+
+// add stores an object and returns its number
+func (p *pdfWriter) add(body string) int {
+	p.objects = append(p.objects, []byte(body))
+	return len(p.objects)
+}
+
+// addStream stores a Flate-compressed stream with extra dictionary entries
+func (p *pdfWriter) addStream(dict string, data []byte) int {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	return p.add(fmt.Sprintf("<< %s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+		dict, compressed.Len(), compressed.Bytes()))
+}
+
+// reserve allocates an object number to fill in later with set
+func (p *pdfWriter) reserve() int {
+	return p.add("")
+}
+
+func (p *pdfWriter) set(n int, body string) {
+	p.objects[n-1] = []byte(body)
+}
+
+func (p *pdfWriter) writeTo(w io.Writer, root int) error {
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(p.objects))
+	for i, obj := range p.objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(obj)
+		out.WriteString("\nendobj\n")
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(p.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.objects)+1, root, xref)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func pdfPath(sb *strings.Builder, p path) {
+	for _, seg := range p {
+		switch seg.kind {
+		case moveTo:
+			fmt.Fprintf(sb, "%s %s m\n", num(seg.pts[0].X), num(seg.pts[0].Y))
+		case lineTo:
+			fmt.Fprintf(sb, "%s %s l\n", num(seg.pts[0].X), num(seg.pts[0].Y))
+		case cubicTo:
+			fmt.Fprintf(sb, "%s %s %s %s %s %s c\n",
+				num(seg.pts[0].X), num(seg.pts[0].Y),
+				num(seg.pts[1].X), num(seg.pts[1].Y),
+				num(seg.pts[2].X), num(seg.pts[2].Y))
+		case closePath:
+			sb.WriteString("h\n")
+		}
+	}
+}
+
+func pdfMatrix(m matrix) string {
+	parts := make([]string, len(m))
+	for i, v := range m {
+		parts[i] = formatFloat(v, 4)
+	}
+	return strings.Join(parts, " ") + " cm"
+}
+
+func pdfColor(c rgba) string {
+	return fmt.Sprintf("%s %s %s", formatFloat(c.R, 3), formatFloat(c.G, 3), formatFloat(c.B, 3))
+}
+
+// pdfString encodes s as a literal string in WinAnsiEncoding, which the
+// standard fonts use; characters outside Latin-1 become "?"
+func pdfString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r >= ' ' && r <= '~':
+			sb.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&sb, "\\%03o", r)
+		default:
+			sb.WriteByte('?')
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// pdfImage stores img as an RGB image with its alpha as a soft mask
+func (p *pdfWriter) pdfImage(img image.Image) int {
+	bounds := img.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a > 0 {
+				// Un-premultiply
+				r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+			}
+			rgb = append(rgb, byte(r>>8), byte(g>>8), byte(b>>8))
+			alpha = append(alpha, byte(a>>8))
+			opaque = opaque && a == 0xffff
+		}
+	}
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8", bounds.Dx(), bounds.Dy())
+	if !opaque {
+		mask := p.addStream(dict+" /ColorSpace /DeviceGray", alpha)
+		dict += fmt.Sprintf(" /SMask %d 0 R", mask)
+	}
+	return p.addStream(dict+" /ColorSpace /DeviceRGB", rgb)
+}
+
+func writePDF(w io.Writer, d *drawing, scale float64) error {
+	p := &pdfWriter{}
+	catalog := p.reserve()
+	pages := p.reserve()
+
+	fonts := map[string]int{}
+	var fontNames []string
+	alphas := map[string]string{}
+	var alphaNames []string
+	images := map[*picture]string{}
+	var imageRefs []string
+
+	// gs selects a graphics state with the given fill and stroke opacity
+	gs := func(sb *strings.Builder, fill, stroke float64) {
+		if fill >= 1 && stroke >= 1 {
+			return
+		}
+		key := formatFloat(fill, 3) + " " + formatFloat(stroke, 3)
+		name, ok := alphas[key]
+		if !ok {
+			name = fmt.Sprintf("GS%d", len(alphas))
+			alphas[key] = name
+			obj := p.add(fmt.Sprintf("<< /Type /ExtGState /ca %s /CA %s >>", formatFloat(fill, 3), formatFloat(stroke, 3)))
+			alphaNames = append(alphaNames, fmt.Sprintf("/%s %d 0 R", name, obj))
+		}
+		fmt.Fprintf(sb, "/%s gs\n", name)
+	}
+
+	var sb strings.Builder
+	// PDF's origin is the bottom left; flip to Excalidraw's top left
+	fmt.Fprintf(&sb, "1 0 0 -1 0 %s cm\n%s\n", num(d.height*scale), pdfMatrix(scaling(scale)))
+	if d.background.visible() {
+		sb.WriteString("q\n")
+		gs(&sb, d.background.A, 1)
+		fmt.Fprintf(&sb, "%s rg\n0 0 %s %s re f\nQ\n", pdfColor(d.background), num(d.width), num(d.height))
+	}
+
+	for _, item := range d.items {
+		switch item := item.(type) {
+		case *shape:
+			if item.style.fill.visible() {
+				sb.WriteString("q\n" + pdfMatrix(item.transform) + "\n")
+				gs(&sb, item.style.fill.A, 1)
+				sb.WriteString(pdfColor(item.style.fill) + " rg\n")
+				pdfPath(&sb, item.path)
+				sb.WriteString("f\nQ\n")
+			}
+			if item.style.stroke.visible() {
+				sb.WriteString("q\n" + pdfMatrix(item.transform) + "\n")
+				gs(&sb, 1, item.style.stroke.A)
+				fmt.Fprintf(&sb, "%s RG\n%s w\n1 J\n1 j\n", pdfColor(item.style.stroke), num(item.style.width))
+				if len(item.style.dash) > 0 {
+					fmt.Fprintf(&sb, "[%s %s] 0 d\n", num(item.style.dash[0]), num(item.style.dash[1]))
+				}
+				pdfPath(&sb, item.path)
+				sb.WriteString("S\nQ\n")
+			}
+		case *text:
+			fontName := pdfFonts[item.font]
+			if _, ok := fonts[fontName]; !ok {
+				fonts[fontName] = p.add(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fontName))
+				fontNames = append(fontNames, fmt.Sprintf("/%s %d 0 R", fontName, fonts[fontName]))
+			}
+			sb.WriteString("q\n" + pdfMatrix(item.transform) + "\n")
+			gs(&sb, item.color.A, 1)
+			fmt.Fprintf(&sb, "%s rg\nBT\n/%s %s Tf\n", pdfColor(item.color), fontName, num(item.fontSize))
+			for _, line := range item.lines {
+				x := line.x
+				width := textWidth(line.text, item.font, item.fontSize)
+				switch item.align {
+				case "center":
+					x -= width / 2
+				case "right":
+					x -= width
+				}
+				// Flip the text matrix back so glyphs are upright
+				fmt.Fprintf(&sb, "1 0 0 -1 %s %s Tm\n%s Tj\n", num(x), num(line.baseline), pdfString(line.text))
+			}
+			sb.WriteString("ET\nQ\n")
+		case *picture:
+			if item.img == nil {
+				continue
+			}
+			name, ok := images[item]
+			if !ok {
+				name = fmt.Sprintf("Im%d", len(images))
+				images[item] = name
+				imageRefs = append(imageRefs, fmt.Sprintf("/%s %d 0 R", name, p.pdfImage(item.img)))
+			}
+			sb.WriteString("q\n" + pdfMatrix(item.transform) + "\n")
+			gs(&sb, item.opacity, 1)
+			// Images fill the unit square with their first row at the top
+			fmt.Fprintf(&sb, "%s 0 0 %s 0 %s cm\n/%s Do\nQ\n", num(item.width), num(-item.height), num(item.height), name)
+		}
+	}
+
+	content := p.addStream("", []byte(sb.String()))
+	resources := fmt.Sprintf("<< /Font << %s >> /ExtGState << %s >> /XObject << %s >> >>",
+		strings.Join(fontNames, " "), strings.Join(alphaNames, " "), strings.Join(imageRefs, " "))
+	page := p.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Contents %d 0 R /Resources %s >>",
+		pages, num(d.width*scale), num(d.height*scale), content, resources))
+	p.set(pages, fmt.Sprintf("<< /Type /Pages /Kids [%d 0 R] /Count 1 >>", page))
+	p.set(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	return p.writeTo(w, catalog)
+}
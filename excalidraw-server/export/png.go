@@ -0,0 +1,345 @@
+package export
+
+import (
+	"image"
+	"image/png"
+	"io"
+	"math"
+)
+
+// rasterizer accumulates the signed area covered by polygon edges over a
+// window of the image, the technique font-rs uses; the running sum along a
+// row is then the coverage of each pixel.
+type rasterizer struct {
+	x0, y0, w, h int
+	acc          []float32
+}
+
+func newRasterizer(bounds image.Rectangle) *rasterizer {
+	// An extra column takes the area right of the last pixel
+	return &rasterizer{
+		x0: bounds.Min.X, y0: bounds.Min.Y, w: bounds.Dx(), h: bounds.Dy(),
+		acc: make([]float32, (bounds.Dx()+2)*bounds.Dy()),
+	}
+}
+
+// line adds the edge from p0 to p1, in image coordinates
+func (r *rasterizer) line(p0, p1 point) {
+	p0 = point{p0.X - float64(r.x0), p0.Y - float64(r.y0)}
+	p1 = point{p1.X - float64(r.x0), p1.Y - float64(r.y0)}
+	if p0.Y == p1.Y {
+		return
+	}
+	dir := float32(1)
+	if p0.Y > p1.Y {
+		dir, p0, p1 = -1, p1, p0
+	}
+	if p1.Y <= 0 || p0.Y >= float64(r.h) {
+		return
+	}
+
+	dxdy := (p1.X - p0.X) / (p1.Y - p0.Y)
+	x := p0.X
+	if p0.Y < 0 {
+		x -= p0.Y * dxdy
+	}
+	stride := r.w + 2
+	// Area left of the window still counts, and area right of it is dropped
+	clampX := func(v float64) float64 { return math.Max(0, math.Min(float64(r.w), v)) }
+
+	for y := int(math.Max(0, p0.Y)); y < r.h && float64(y) < p1.Y; y++ {
+		dy := math.Min(float64(y+1), p1.Y) - math.Max(float64(y), p0.Y)
+		xnext := x + dxdy*dy
+		d := float32(dy) * dir
+		xa, xb := clampX(x), clampX(xnext)
+		if xa > xb {
+			xa, xb = xb, xa
+		}
+		row := r.acc[y*stride : (y+1)*stride]
+		x0floor := math.Floor(xa)
+		x0i := int(x0floor)
+		x1ceil := math.Ceil(xb)
+		x1i := int(x1ceil)
+		if x1i <= x0i+1 {
+			xmf := float32(0.5*(xa+xb) - x0floor)
+			row[x0i] += d - d*xmf
+			row[x0i+1] += d * xmf
+		} else {
+			s := float32(1 / (xb - xa))
+			x0f := float32(xa - x0floor)
+			a0 := 0.5 * s * (1 - x0f) * (1 - x0f)
+			x1f := float32(xb - x1ceil + 1)
+			am := 0.5 * s * x1f * x1f
+			row[x0i] += d * a0
+			if x1i == x0i+2 {
+				row[x0i+1] += d * (1 - a0 - am)
+			} else {
+				a1 := s * (1.5 - x0f)
+				row[x0i+1] += d * (a1 - a0)
+				for xi := x0i + 2; xi < x1i-1; xi++ {
+					row[xi] += d * s
+				}
+				a2 := a1 + float32(x1i-x0i-3)*s
+				row[x1i-1] += d * (1 - a2 - am)
+			}
+			row[x1i] += d * am
+		}
+		x = xnext
+	}
+}
+
+// polygon adds a closed polygon's edges
+func (r *rasterizer) polygon(pts []point) {
+	for i := range pts {
+		r.line(pts[i], pts[(i+1)%len(pts)])
+	}
+}
+
+// positive adds a polygon wound so that overlapping pieces of a stroke
+// merge instead of cancelling out
+func (r *rasterizer) positive(pts []point) {
+	area := 0.0
+	for i := range pts {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	if area < 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	r.polygon(pts)
+}
+
+// composite paints c over dst wherever the accumulated coverage is nonzero
+func (r *rasterizer) composite(dst *image.RGBA, c rgba) {
+	stride := r.w + 2
+	for y := 0; y < r.h; y++ {
+		var sum float32
+		row := r.acc[y*stride : (y+1)*stride]
+		for x := 0; x < r.w; x++ {
+			sum += row[x]
+			coverage := math.Min(1, math.Abs(float64(sum)))
+			if coverage < 1.0/512 {
+				continue
+			}
+			blend(dst, r.x0+x, r.y0+y, c, c.A*coverage)
+		}
+	}
+}
+
+// blend paints c with opacity alpha over the pixel at x, y
+func blend(dst *image.RGBA, x, y int, c rgba, alpha float64) {
+	i := dst.PixOffset(x, y)
+	px := dst.Pix[i : i+4 : i+4]
+	keep := 1 - alpha
+	px[0] = uint8(math.Round(c.R*255*alpha + float64(px[0])*keep))
+	px[1] = uint8(math.Round(c.G*255*alpha + float64(px[1])*keep))
+	px[2] = uint8(math.Round(c.B*255*alpha + float64(px[2])*keep))
+	px[3] = uint8(math.Round(255*alpha + float64(px[3])*keep))
+}
+
+// window returns the pixels covered by pts, grown by margin and clipped to
+// the image
+func window(pts []point, margin float64, clip image.Rectangle) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, pt := range pts {
+		minX, minY = math.Min(minX, pt.X), math.Min(minY, pt.Y)
+		maxX, maxY = math.Max(maxX, pt.X), math.Max(maxY, pt.Y)
+	}
+	if math.IsInf(minX, 1) {
+		return image.Rectangle{}
+	}
+	rect := image.Rect(
+		int(math.Floor(minX-margin)), int(math.Floor(minY-margin)),
+		int(math.Ceil(maxX+margin)), int(math.Ceil(maxY+margin)))
+	return rect.Intersect(clip)
+}
+
+// circle approximates a circle with a polygon
+func circle(center point, radius float64) []point {
+	n := int(math.Ceil(2 * math.Pi * radius / 2))
+	n = max(8, min(n, 64))
+	pts := make([]point, n)
+	for i := range pts {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+		pts[i] = point{center.X + radius*cos, center.Y + radius*sin}
+	}
+	return pts
+}
+
+// strokePolyline adds a round-capped, round-joined stroke of pts as a union
+// of segment quads and vertex discs
+func (r *rasterizer) strokePolyline(pts []point, halfWidth float64) {
+	for i, pt := range pts {
+		r.positive(circle(pt, halfWidth))
+		if i == 0 {
+			continue
+		}
+		prev := pts[i-1]
+		seg := pt.sub(prev)
+		length := seg.length()
+		if length == 0 {
+			continue
+		}
+		n := point{-seg.Y, seg.X}.mul(halfWidth / length)
+		r.positive([]point{prev.add(n), pt.add(n), pt.sub(n), prev.sub(n)})
+	}
+}
+
+func drawShape(dst *image.RGBA, s *shape, m matrix) {
+	transform := s.transform.then(m)
+	lines := s.path.flatten(transform, 0.5)
+	var all []point
+	for _, line := range lines {
+		all = append(all, line.pts...)
+	}
+
+	if s.style.fill.visible() {
+		if rect := window(all, 1, dst.Bounds()); !rect.Empty() {
+			r := newRasterizer(rect)
+			for _, line := range lines {
+				r.polygon(line.pts)
+			}
+			r.composite(dst, s.style.fill)
+		}
+	}
+
+	if s.style.stroke.visible() {
+		k := transform.scale()
+		halfWidth := math.Max(0.5, s.style.width*k) / 2
+		rect := window(all, halfWidth+1, dst.Bounds())
+		if rect.Empty() {
+			return
+		}
+		r := newRasterizer(rect)
+		for _, line := range lines {
+			if len(s.style.dash) > 0 {
+				pattern := []float64{s.style.dash[0] * k, s.style.dash[1] * k}
+				for _, dash := range dashes(line, pattern) {
+					r.strokePolyline(dash, halfWidth)
+				}
+				continue
+			}
+			pts := line.pts
+			if line.closed && len(pts) > 0 {
+				pts = append(pts[:len(pts):len(pts)], pts[0])
+			}
+			r.strokePolyline(pts, halfWidth)
+		}
+		r.composite(dst, s.style.stroke)
+	}
+}
+
+// drawText draws t with the built-in bitmap font
+func drawText(dst *image.RGBA, t *text, m matrix) {
+	transform := t.transform.then(m)
+	var squares [][]point
+	var all []point
+	for _, line := range t.lines {
+		px := t.fontSize / 10
+		runes := []rune(line.text)
+		width := float64(len(runes)*glyphAdvance-1) * px
+		left := line.x
+		switch t.align {
+		case "center":
+			left -= width / 2
+		case "right":
+			left -= width
+		}
+		top := line.baseline - glyphAscent*px
+		for i, ch := range runes {
+			for row, bits := range glyph(ch) {
+				for col, bit := range bits {
+					if bit != '#' {
+						continue
+					}
+					x := left + float64(i*glyphAdvance+col)*px
+					y := top + float64(row)*px
+					square := []point{
+						transform.apply(point{x, y}), transform.apply(point{x + px, y}),
+						transform.apply(point{x + px, y + px}), transform.apply(point{x, y + px}),
+					}
+					squares = append(squares, square)
+					all = append(all, square...)
+				}
+			}
+		}
+	}
+	rect := window(all, 1, dst.Bounds())
+	if rect.Empty() {
+		return
+	}
+	r := newRasterizer(rect)
+	for _, square := range squares {
+		r.positive(square)
+	}
+	r.composite(dst, t.color)
+}
+
+// drawPicture maps each covered pixel back into the image, sampling the
+// nearest source pixel
+func drawPicture(dst *image.RGBA, p *picture, m matrix) {
+	if p.img == nil || p.width == 0 || p.height == 0 {
+		return
+	}
+	transform := p.transform.then(m)
+	corners := []point{
+		transform.apply(point{0, 0}), transform.apply(point{p.width, 0}),
+		transform.apply(point{0, p.height}), transform.apply(point{p.width, p.height}),
+	}
+	rect := window(corners, 0, dst.Bounds())
+	inverse := transform.invert()
+	src := p.img.Bounds()
+	kx, ky := float64(src.Dx())/p.width, float64(src.Dy())/p.height
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			local := inverse.apply(point{float64(x) + 0.5, float64(y) + 0.5})
+			if local.X < 0 || local.Y < 0 || local.X >= p.width || local.Y >= p.height {
+				continue
+			}
+			sx := src.Min.X + int(local.X*kx)
+			sy := src.Min.Y + int(local.Y*ky)
+			r, g, b, a := p.img.At(sx, sy).RGBA()
+			if a == 0 {
+				continue
+			}
+			c := rgba{R: float64(r) / float64(a), G: float64(g) / float64(a), B: float64(b) / float64(a)}
+			blend(dst, x, y, c, float64(a)/0xffff*p.opacity)
+		}
+	}
+}
+
+func rasterize(d *drawing, scale float64) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(d.width*scale)), int(math.Ceil(d.height*scale))))
+	paint(dst, d, scaling(scale))
+	return dst
+}
+
+// paint fills dst with the background and draws the items transformed by m
+func paint(dst *image.RGBA, d *drawing, m matrix) {
+	if d.background.visible() {
+		bounds := dst.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				blend(dst, x, y, d.background, d.background.A)
+			}
+		}
+	}
+
+	for _, item := range d.items {
+		switch item := item.(type) {
+		case *shape:
+			drawShape(dst, item, m)
+		case *text:
+			drawText(dst, item, m)
+		case *picture:
+			drawPicture(dst, item, m)
+		}
+	}
+}
+
+func writePNG(w io.Writer, d *drawing, scale float64) error {
+	return png.Encode(w, rasterize(d, scale))
+}
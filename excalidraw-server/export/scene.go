@@ -0,0 +1,83 @@
+// Package export renders stored Excalidraw scenes to SVG, PDF and PNG on the
+// server, so diagrams can be embedded with a plain image URL.
+//
+// Shapes are drawn with clean geometry rather than Excalidraw's hand-drawn
+// strokes; fills, dashes, arrowheads, text and embedded images are kept.
+package export
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrNotScene is returned for documents that aren't a plain Excalidraw scene,
+// such as the end-to-end encrypted payloads of share links.
+var ErrNotScene = errors.New("not an excalidraw scene")
+
+type (
+	// Scene is the part of an .excalidraw file needed to draw it
+	Scene struct {
+		Elements []Element       `json:"elements"`
+		AppState AppState        `json:"appState"`
+		Files    map[string]File `json:"files"`
+	}
+
+	AppState struct {
+		// Name is the drawing's title, when it has one
+		Name                string `json:"name"`
+		ViewBackgroundColor string `json:"viewBackgroundColor"`
+	}
+
+	// File is an image embedded in the scene
+	File struct {
+		MimeType string `json:"mimeType"`
+		DataURL  string `json:"dataURL"`
+	}
+
+	Roundness struct {
+		Type int `json:"type"`
+	}
+
+	Element struct {
+		ID              string      `json:"id"`
+		Type            string      `json:"type"`
+		X               float64     `json:"x"`
+		Y               float64     `json:"y"`
+		Width           float64     `json:"width"`
+		Height          float64     `json:"height"`
+		Angle           float64     `json:"angle"`
+		StrokeColor     string      `json:"strokeColor"`
+		BackgroundColor string      `json:"backgroundColor"`
+		FillStyle       string      `json:"fillStyle"`
+		StrokeWidth     float64     `json:"strokeWidth"`
+		StrokeStyle     string      `json:"strokeStyle"`
+		Opacity         *float64    `json:"opacity"`
+		Roundness       *Roundness  `json:"roundness"`
+		IsDeleted       bool        `json:"isDeleted"`
+		Points          [][]float64 `json:"points"`
+		StartArrowhead  string      `json:"startArrowhead"`
+		EndArrowhead    string      `json:"endArrowhead"`
+		Text            string      `json:"text"`
+		FontSize        float64     `json:"fontSize"`
+		FontFamily      int         `json:"fontFamily"`
+		TextAlign       string      `json:"textAlign"`
+		LineHeight      float64     `json:"lineHeight"`
+		FileID          string      `json:"fileId"`
+		// GroupIDs lists the groups the element is in, innermost first
+		GroupIDs []string `json:"groupIds"`
+	}
+)
+
+// ParseScene decodes an .excalidraw JSON document
+func ParseScene(data []byte) (*Scene, error) {
+	var raw struct {
+		Scene
+		Elements *[]Element `json:"elements"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil || raw.Elements == nil {
+		return nil, ErrNotScene
+	}
+	scene := raw.Scene
+	scene.Elements = *raw.Elements
+	return &scene, nil
+}
@@ -0,0 +1,136 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Font stacks for SVG text; viewers without Excalidraw's fonts fall back to
+// a similar generic family
+var svgFonts = map[int]string{
+	fontHand: "Excalifont, Virgil, 'Comic Sans MS', cursive",
+	fontSans: "Helvetica, Arial, sans-serif",
+	fontMono: "Cascadia, Consolas, monospace",
+}
+
+// formatFloat formats v with at most prec decimals
+func formatFloat(v float64, prec int) string {
+	s := strconv.FormatFloat(v, 'f', prec, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// num formats a coordinate; two decimals are plenty for vector output
+func num(v float64) string {
+	return formatFloat(v, 2)
+}
+
+func svgMatrix(m matrix) string {
+	parts := make([]string, len(m))
+	for i, v := range m {
+		parts[i] = formatFloat(v, 4)
+	}
+	return "matrix(" + strings.Join(parts, " ") + ")"
+}
+
+func svgPath(p path) string {
+	var sb strings.Builder
+	for _, seg := range p {
+		switch seg.kind {
+		case moveTo:
+			fmt.Fprintf(&sb, "M%s %s", num(seg.pts[0].X), num(seg.pts[0].Y))
+		case lineTo:
+			fmt.Fprintf(&sb, "L%s %s", num(seg.pts[0].X), num(seg.pts[0].Y))
+		case cubicTo:
+			fmt.Fprintf(&sb, "C%s %s %s %s %s %s",
+				num(seg.pts[0].X), num(seg.pts[0].Y),
+				num(seg.pts[1].X), num(seg.pts[1].Y),
+				num(seg.pts[2].X), num(seg.pts[2].Y))
+		case closePath:
+			sb.WriteString("Z")
+		}
+	}
+	return sb.String()
+}
+
+// svgPaint returns the attribute values for a fill or stroke color
+func svgPaint(c rgba) (paint, opacity string) {
+	if !c.visible() {
+		return "none", ""
+	}
+	if c.A < 1 {
+		opacity = num(c.A)
+	}
+	return c.hex(), opacity
+}
+
+func writeSVG(w io.Writer, d *drawing, scale float64) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`,
+		num(d.width*scale), num(d.height*scale), num(d.width), num(d.height))
+	sb.WriteString("\n")
+	if d.background.visible() {
+		fill, opacity := svgPaint(d.background)
+		fmt.Fprintf(&sb, `<rect width="100%%" height="100%%" fill="%s"%s/>`+"\n", fill, optional("fill-opacity", opacity))
+	}
+
+	for _, item := range d.items {
+		switch item := item.(type) {
+		case *shape:
+			fill, fillOpacity := svgPaint(item.style.fill)
+			stroke, strokeOpacity := svgPaint(item.style.stroke)
+			fmt.Fprintf(&sb, `<path transform="%s" d="%s" fill="%s"%s stroke="%s"%s`,
+				svgMatrix(item.transform), svgPath(item.path), fill, optional("fill-opacity", fillOpacity),
+				stroke, optional("stroke-opacity", strokeOpacity))
+			if item.style.stroke.visible() {
+				fmt.Fprintf(&sb, ` stroke-width="%s" stroke-linecap="round" stroke-linejoin="round"`, num(item.style.width))
+				if len(item.style.dash) > 0 {
+					fmt.Fprintf(&sb, ` stroke-dasharray="%s %s"`, num(item.style.dash[0]), num(item.style.dash[1]))
+				}
+			}
+			sb.WriteString("/>\n")
+		case *text:
+			fill, opacity := svgPaint(item.color)
+			anchor := "start"
+			switch item.align {
+			case "center":
+				anchor = "middle"
+			case "right":
+				anchor = "end"
+			}
+			fmt.Fprintf(&sb, `<g transform="%s" font-family="%s" font-size="%s" fill="%s"%s text-anchor="%s" style="white-space: pre">`+"\n",
+				svgMatrix(item.transform), svgFonts[item.font], num(item.fontSize), fill, optional("fill-opacity", opacity), anchor)
+			for _, line := range item.lines {
+				fmt.Fprintf(&sb, `<text x="%s" y="%s">%s</text>`+"\n", num(line.x), num(line.baseline), html.EscapeString(line.text))
+			}
+			sb.WriteString("</g>\n")
+		case *picture:
+			opacity := ""
+			if item.opacity < 1 {
+				opacity = num(item.opacity)
+			}
+			fmt.Fprintf(&sb, `<image transform="%s" width="%s" height="%s" preserveAspectRatio="none"%s href="%s"/>`+"\n",
+				svgMatrix(item.transform), num(item.width), num(item.height), optional("opacity", opacity), html.EscapeString(item.dataURL))
+		}
+	}
+	sb.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// optional formats an attribute, or nothing when value is empty
+func optional(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf(` %s="%s"`, name, value)
+}
@@ -0,0 +1,236 @@
+package filegc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const defaultGrace = 24 * time.Hour
+
+// Collector deletes uploaded files that no persisted scene references.
+// Files younger than the grace period are kept, since they usually belong to
+// a live room that hasn't been saved yet. A pass that finds a scene it can't
+// read, such as an end-to-end encrypted one, deletes nothing, since the
+// files that scene references can't be known.
+type Collector struct {
+	files  core.FileStore
+	scenes core.SceneScanner
+	// libraries and rooms are also scanned when the scene store holds them
+	libraries core.LibraryStore
+	rooms     core.RoomStateStore
+	grace     time.Duration
+	now       func() time.Time
+}
+
+func NewCollector(files core.FileStore, scenes core.SceneScanner, grace time.Duration) *Collector {
+	libraries, _ := scenes.(core.LibraryStore)
+	rooms, _ := scenes.(core.RoomStateStore)
+	return &Collector{
+		files:     files,
+		scenes:    scenes,
+		libraries: libraries,
+		rooms:     rooms,
+		grace:     grace,
+		now:       time.Now,
+	}
+}
+
+// SettingsFromEnv reads FILE_GC_INTERVAL (0 disables collection) and
+// FILE_GC_GRACE.
+func SettingsFromEnv() (interval, grace time.Duration, err error) {
+	grace = defaultGrace
+	if value := os.Getenv("FILE_GC_INTERVAL"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil {
+			return 0, 0, fmt.Errorf("invalid FILE_GC_INTERVAL: %w", err)
+		}
+	}
+	if value := os.Getenv("FILE_GC_GRACE"); value != "" {
+		if grace, err = time.ParseDuration(value); err != nil {
+			return 0, 0, fmt.Errorf("invalid FILE_GC_GRACE: %w", err)
+		}
+	}
+	return interval, grace, nil
+}
+
+// Run performs one collection pass and returns the number of deleted files.
+func (c *Collector) Run(ctx context.Context) (int, error) {
+	referenced := make(map[string]struct{})
+	unreadable := 0
+	err := c.scenes.ScanScenes(ctx, func(data []byte) error {
+		if !collectFileIDs(data, referenced) {
+			unreadable++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("scan scenes: %w", err)
+	}
+	libraries, err := c.scanLibraries(ctx, referenced)
+	if err != nil {
+		return 0, fmt.Errorf("scan libraries: %w", err)
+	}
+	rooms, err := c.scanRoomStates(ctx, referenced)
+	if err != nil {
+		return 0, fmt.Errorf("scan room states: %w", err)
+	}
+	unreadable += libraries + rooms
+	if unreadable > 0 {
+		logrus.WithField("unreadable", unreadable).Warn("Skipped file garbage collection: some scenes are not JSON (encrypted?)")
+		return 0, nil
+	}
+
+	files, err := c.files.ListFiles(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list files: %w", err)
+	}
+
+	cutoff := c.now().Add(-c.grace)
+	deleted := 0
+	for _, file := range files {
+		if _, ok := referenced[file.ID]; ok || file.CreatedAt.After(cutoff) {
+			continue
+		}
+		if err := c.files.DeleteFile(ctx, file.ID); err != nil {
+			logrus.WithField("file_id", file.ID).WithError(err).Warn("Failed to delete orphaned file")
+			continue
+		}
+		deleted++
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"files":      len(files),
+		"referenced": len(referenced),
+		"deleted":    deleted,
+	}).Info("File garbage collection finished")
+	return deleted, nil
+}
+
+// Start runs the collector every interval until stop is closed.
+func (c *Collector) Start(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := c.Run(context.Background()); err != nil {
+				logrus.WithError(err).Error("File garbage collection failed")
+			}
+		}
+	}
+}
+
+// scanLibraries adds the files referenced by library items to ids and
+// returns how many libraries couldn't be read.
+func (c *Collector) scanLibraries(ctx context.Context, ids map[string]struct{}) (int, error) {
+	if c.libraries == nil {
+		return 0, nil
+	}
+	libraries, err := c.libraries.ListLibraries(ctx, core.LibraryFilter{})
+	if err != nil {
+		return 0, err
+	}
+	unreadable := 0
+	for _, listed := range libraries {
+		library, err := c.libraries.GetLibrary(ctx, listed.ID)
+		if errors.Is(err, core.ErrLibraryNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !collectFileIDs(library.Data, ids) {
+			unreadable++
+		}
+	}
+	return unreadable, nil
+}
+
+// scanRoomStates adds the files referenced by hibernated rooms to ids and
+// returns how many rooms couldn't be read. Room state is MessagePack, and
+// the scene of an encrypted room is an opaque binary payload.
+func (c *Collector) scanRoomStates(ctx context.Context, ids map[string]struct{}) (int, error) {
+	if c.rooms == nil {
+		return 0, nil
+	}
+	roomIDs, err := c.rooms.ListRoomStates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	unreadable := 0
+	for _, roomID := range roomIDs {
+		data, err := c.rooms.GetRoomState(ctx, roomID)
+		if errors.Is(err, core.ErrRoomStateNotFound) {
+			// the room woke up during the scan
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		var state any
+		if msgpack.Unmarshal(data, &state) != nil || !walk(state, ids) {
+			unreadable++
+		}
+	}
+	return unreadable, nil
+}
+
+// collectFileIDs adds every fileId referenced by a scene to ids. Scenes are
+// walked generically so documents, snapshots (which wrap the scene in a JSON
+// string) and library items are all covered. It reports false for data that
+// isn't JSON, or that embeds a scene that isn't.
+func collectFileIDs(data []byte, ids map[string]struct{}) bool {
+	var scene any
+	if err := json.Unmarshal(data, &scene); err != nil {
+		return false
+	}
+	return walk(scene, ids)
+}
+
+// walk adds the fileIds under value to ids and reports false when it finds
+// binary data that isn't a JSON scene.
+func walk(value any, ids map[string]struct{}) bool {
+	readable := true
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			switch key {
+			case "fileId":
+				if id, ok := child.(string); ok && id != "" {
+					ids[id] = struct{}{}
+				}
+			case "files":
+				if files, ok := child.(map[string]any); ok {
+					for id := range files {
+						ids[id] = struct{}{}
+					}
+				}
+			}
+			readable = walk(child, ids) && readable
+		}
+	case []any:
+		for _, child := range v {
+			readable = walk(child, ids) && readable
+		}
+	case string:
+		// Some clients embed the scene as a JSON encoded string.
+		if len(v) > 1 && (v[0] == '{' || v[0] == '[') {
+			collectFileIDs([]byte(v), ids)
+		}
+	case []byte:
+		// binary payloads, as room state keeps them, are scenes or
+		// ciphertext
+		return len(v) == 0 || collectFileIDs(v, ids)
+	}
+	return readable
+}
@@ -0,0 +1,173 @@
+package filegc
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCollectFileIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		want   []string
+		wantOK bool
+	}{
+		{"image elements", `{"elements":[{"type":"image","fileId":"a"},{"type":"rectangle"}]}`, []string{"a"}, true},
+		{"files map", `{"elements":[],"files":{"b":{"mimeType":"image/png"}}}`, []string{"b"}, true},
+		{"scene as string", `{"data":"{\"elements\":[{\"fileId\":\"c\"}]}"}`, []string{"c"}, true},
+		{"not json", "\x00encrypted", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := make(map[string]struct{})
+			if ok := collectFileIDs([]byte(tt.data), ids); ok != tt.wantOK {
+				t.Errorf("collectFileIDs() = %v, want %v", ok, tt.wantOK)
+			}
+			var got []string
+			for id := range ids {
+				got = append(got, id)
+			}
+			sort.Strings(got)
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollector_Run(t *testing.T) {
+	store := memory.NewDocumentStore()
+	files := store.(core.FileStore)
+	ctx := context.Background()
+	now := time.Now()
+
+	doc := &core.Document{}
+	doc.Data.WriteString(`{"elements":[{"type":"image","fileId":"used"}]}`)
+	if _, err := store.Create(ctx, doc); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	for _, file := range []core.File{
+		{ID: "used", CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "orphan", CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "fresh", CreatedAt: now.Add(-time.Hour)},
+	} {
+		file.Data = []byte("data")
+		if err := files.PutFile(ctx, &file); err != nil {
+			t.Fatalf("PutFile() failed: %v", err)
+		}
+	}
+
+	collector := NewCollector(files, store.(core.SceneScanner), 24*time.Hour)
+	collector.now = func() time.Time { return now }
+
+	deleted, err := collector.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want 1", deleted)
+	}
+
+	for id, wantKept := range map[string]bool{"used": true, "fresh": true, "orphan": false} {
+		_, err := files.GetFile(ctx, id)
+		if kept := err == nil; kept != wantKept {
+			t.Errorf("file %q kept = %v, want %v", id, kept, wantKept)
+		}
+	}
+}
+
+func TestSettingsFromEnv(t *testing.T) {
+	t.Setenv("FILE_GC_INTERVAL", "")
+	t.Setenv("FILE_GC_GRACE", "")
+	interval, grace, err := SettingsFromEnv()
+	if err != nil || interval != 0 || grace != defaultGrace {
+		t.Errorf("defaults = (%v, %v, %v)", interval, grace, err)
+	}
+
+	t.Setenv("FILE_GC_INTERVAL", "1h")
+	t.Setenv("FILE_GC_GRACE", "2h")
+	interval, grace, err = SettingsFromEnv()
+	if err != nil || interval != time.Hour || grace != 2*time.Hour {
+		t.Errorf("configured = (%v, %v, %v)", interval, grace, err)
+	}
+
+	t.Setenv("FILE_GC_GRACE", "soon")
+	if _, _, err := SettingsFromEnv(); err == nil {
+		t.Error("expected error for invalid grace")
+	}
+}
+
+func TestCollector_RunSkipsWhenScenesAreUnreadable(t *testing.T) {
+	store := memory.NewDocumentStore()
+	files := store.(core.FileStore)
+	ctx := context.Background()
+	now := time.Now()
+
+	doc := &core.Document{}
+	doc.Data.WriteString("\x00encrypted")
+	if _, err := store.Create(ctx, doc); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if err := files.PutFile(ctx, &core.File{ID: "encrypted-image", Data: []byte("data"), CreatedAt: now.Add(-48 * time.Hour)}); err != nil {
+		t.Fatalf("PutFile() failed: %v", err)
+	}
+
+	collector := NewCollector(files, store.(core.SceneScanner), 24*time.Hour)
+	collector.now = func() time.Time { return now }
+	if deleted, err := collector.Run(ctx); err != nil || deleted != 0 {
+		t.Fatalf("Run() = %d, %v, want nothing deleted", deleted, err)
+	}
+	if _, err := files.GetFile(ctx, "encrypted-image"); err != nil {
+		t.Errorf("file of an unreadable scene was deleted: %v", err)
+	}
+}
+
+func TestCollector_RunKeepsLibraryAndRoomFiles(t *testing.T) {
+	store := memory.NewDocumentStore()
+	files := store.(core.FileStore)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := store.(core.LibraryStore).CreateLibrary(ctx, &core.Library{
+		OwnerID: "user",
+		Name:    "Icons",
+		Data:    []byte(`{"libraryItems":[{"elements":[{"type":"image","fileId":"library"}]}]}`),
+	}); err != nil {
+		t.Fatalf("CreateLibrary() failed: %v", err)
+	}
+	state, err := msgpack.Marshal(map[string]any{
+		"elements": []map[string]any{{"type": "image", "fileId": "room"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.(core.RoomStateStore).PutRoomState(ctx, "room-1", state); err != nil {
+		t.Fatalf("PutRoomState() failed: %v", err)
+	}
+
+	for _, id := range []string{"library", "room", "orphan"} {
+		if err := files.PutFile(ctx, &core.File{ID: id, Data: []byte("data"), CreatedAt: now.Add(-48 * time.Hour)}); err != nil {
+			t.Fatalf("PutFile() failed: %v", err)
+		}
+	}
+
+	collector := NewCollector(files, store.(core.SceneScanner), 24*time.Hour)
+	collector.now = func() time.Time { return now }
+	if deleted, err := collector.Run(ctx); err != nil || deleted != 1 {
+		t.Fatalf("Run() = %d, %v, want 1 deleted", deleted, err)
+	}
+	for id, wantKept := range map[string]bool{"library": true, "room": true, "orphan": false} {
+		_, err := files.GetFile(ctx, id)
+		if kept := err == nil; kept != wantKept {
+			t.Errorf("file %q kept = %v, want %v", id, kept, wantKept)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/grpcapi/pb"
+	"excalidraw-server/handlers/api/canvases"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// maxCanvasPage matches the largest page of GET /api/v2/kv.
+const maxCanvasPage = 1000
+
+// canvasService serves the canvases of the caller, whose claims the guard
+// stored in the context.
+type canvasService struct {
+	pb.UnimplementedCanvasServiceServer
+	store core.CanvasStore
+}
+
+func owner(ctx context.Context) string {
+	return auth.ClaimsFromContext(ctx).Subject
+}
+
+func checkKey(key string) error {
+	if !canvases.ValidKey(key) {
+		return errorStatus(codes.InvalidArgument, apierror.InvalidCanvasKey, "invalid canvas key")
+	}
+	return nil
+}
+
+// canvasError reports unknown canvases as NotFound and logs other errors.
+func canvasError(err error, message string) error {
+	if !errors.Is(err, core.ErrCanvasNotFound) {
+		logrus.WithField("error", err).Error(message)
+	}
+	return storeError(err, codes.NotFound, apierror.CanvasNotFound, "canvas not found")
+}
+
+func (s *canvasService) ListCanvases(ctx context.Context, req *pb.ListCanvasesRequest) (*pb.ListCanvasesResponse, error) {
+	opts := core.CanvasListOptions{Cursor: req.GetCursor()}
+	switch sort := core.CanvasSort(req.GetSort()); sort {
+	case "", core.CanvasSortUpdated, core.CanvasSortName:
+		opts.Sort = sort
+	default:
+		return nil, errorStatus(codes.InvalidArgument, apierror.InvalidRequest, "sort must be updatedAt or name")
+	}
+	switch order := req.GetOrder(); order {
+	case "":
+	case "asc", "desc":
+		ascending := order == "asc"
+		opts.Ascending = &ascending
+	default:
+		return nil, errorStatus(codes.InvalidArgument, apierror.InvalidRequest, "order must be asc or desc")
+	}
+	opts.Limit = int(req.GetLimit())
+	if opts.Limit < 0 || opts.Limit > maxCanvasPage {
+		return nil, errorStatus(codes.InvalidArgument, apierror.InvalidRequest, fmt.Sprintf("limit must be between 0 and %d", maxCanvasPage))
+	}
+
+	page, err := s.store.ListCanvasPage(ctx, owner(ctx), opts)
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidCursor) {
+			return nil, errorStatus(codes.InvalidArgument, apierror.InvalidCursor, "invalid cursor")
+		}
+		logrus.WithField("error", err).Error("Failed to list canvases")
+		return nil, storeError(err, codes.Internal, apierror.Internal, "failed to list canvases")
+	}
+	resp := &pb.ListCanvasesResponse{NextCursor: page.NextCursor}
+	for i := range page.Canvases {
+		resp.Canvases = append(resp.Canvases, canvasMessage(&page.Canvases[i]))
+	}
+	return resp, nil
+}
+
+// GetCanvas returns one of the caller's canvases with its data and counts
+// the view
+func (s *canvasService) GetCanvas(ctx context.Context, req *pb.GetCanvasRequest) (*pb.Canvas, error) {
+	if err := checkKey(req.GetKey()); err != nil {
+		return nil, err
+	}
+	canvas, err := s.store.GetCanvas(ctx, owner(ctx), req.GetKey())
+	if err != nil {
+		return nil, canvasError(err, "Failed to get canvas")
+	}
+	if err := s.store.RecordCanvasView(ctx, owner(ctx), req.GetKey()); err != nil {
+		logrus.WithField("canvas_key", req.GetKey()).WithField("error", err).Warn("Failed to record canvas view")
+	}
+	message := canvasMessage(canvas)
+	message.Data = canvas.Data
+	return message, nil
+}
+
+// PutCanvas saves a canvas, replacing any canvas already stored under the
+// key
+func (s *canvasService) PutCanvas(ctx context.Context, req *pb.PutCanvasRequest) (*emptypb.Empty, error) {
+	if err := checkKey(req.GetKey()); err != nil {
+		return nil, err
+	}
+	if len(req.GetData()) == 0 {
+		return nil, errorStatus(codes.InvalidArgument, apierror.InvalidRequest, "empty canvas")
+	}
+	if len(req.GetData()) > canvases.MaxCanvasSize {
+		return nil, errorStatus(codes.InvalidArgument, apierror.TooLarge, "canvas too large")
+	}
+
+	err := s.store.PutCanvas(ctx, &core.Canvas{
+		Key:     req.GetKey(),
+		OwnerID: owner(ctx),
+		Data:    req.GetData(),
+	})
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to save canvas")
+		return nil, storeError(err, codes.Internal, apierror.Internal, "failed to save canvas")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// UpdateCanvasMetadata renames or re-tags a canvas without uploading it
+// again
+func (s *canvasService) UpdateCanvasMetadata(ctx context.Context, req *pb.UpdateCanvasMetadataRequest) (*pb.Canvas, error) {
+	if err := checkKey(req.GetKey()); err != nil {
+		return nil, err
+	}
+	var metadata canvases.MetadataRequest
+	metadata.Name = req.Name
+	if req.GetTags() != nil {
+		tags := append([]string{}, req.GetTags().GetValues()...)
+		metadata.Tags = &tags
+	}
+	update, err := canvases.ValidateMetadata(metadata)
+	if err != nil {
+		return nil, errorStatus(codes.InvalidArgument, apierror.InvalidRequest, err.Error())
+	}
+
+	canvas, err := s.store.UpdateCanvasMetadata(ctx, owner(ctx), req.GetKey(), update)
+	if err != nil {
+		return nil, canvasError(err, "Failed to update canvas metadata")
+	}
+	return canvasMessage(canvas), nil
+}
+
+func (s *canvasService) DeleteCanvas(ctx context.Context, req *pb.DeleteCanvasRequest) (*emptypb.Empty, error) {
+	if err := checkKey(req.GetKey()); err != nil {
+		return nil, err
+	}
+	if err := s.store.DeleteCanvas(ctx, owner(ctx), req.GetKey()); err != nil {
+		return nil, canvasError(err, "Failed to delete canvas")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// canvasMessage converts a canvas without its data.
+func canvasMessage(canvas *core.Canvas) *pb.Canvas {
+	return &pb.Canvas{
+		Key:          canvas.Key,
+		Size:         int64(canvas.Size),
+		CreatedAt:    canvas.CreatedAt,
+		UpdatedAt:    canvas.UpdatedAt,
+		Name:         canvas.Name,
+		Tags:         canvas.Tags,
+		Views:        canvas.Views,
+		LastAccessed: canvas.LastAccessed,
+	}
+}
@@ -0,0 +1,40 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"excalidraw-server/grpcapi/pb"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+)
+
+type documentService struct {
+	pb.UnimplementedDocumentServiceServer
+	store core.DocumentStore
+}
+
+func (s *documentService) CreateDocument(ctx context.Context, req *pb.CreateDocumentRequest) (*pb.CreateDocumentResponse, error) {
+	id, err := s.store.Create(ctx, &core.Document{Data: *bytes.NewBuffer(req.GetData())})
+	if err != nil {
+		return nil, storeError(err, codes.Internal, apierror.Internal, "failed to save")
+	}
+	return &pb.CreateDocumentResponse{Id: id}, nil
+}
+
+// GetDocument returns a shared document, counting the view when the store
+// keeps access stats
+func (s *documentService) GetDocument(ctx context.Context, req *pb.GetDocumentRequest) (*pb.Document, error) {
+	document, err := s.store.FindID(ctx, req.GetId())
+	if err != nil {
+		return nil, storeError(err, codes.NotFound, apierror.DocumentNotFound, "not found")
+	}
+	if statsStore, ok := s.store.(core.DocumentStatsStore); ok {
+		if err := statsStore.RecordDocumentView(ctx, req.GetId()); err != nil {
+			logrus.WithField("document_id", req.GetId()).WithField("error", err).Warn("Failed to record document view")
+		}
+	}
+	return &pb.Document{Id: req.GetId(), Data: document.Data.Bytes()}, nil
+}
@@ -0,0 +1,113 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/grpcapi/pb"
+	"excalidraw-server/ratelimit"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// gatewayClientKey carries the rate limit key of the HTTP client
+	// behind a gateway call. It is only trusted on gateway connections.
+	gatewayClientKey = "x-gateway-client"
+	// gatewayNetwork is the network of the in-process gateway connection.
+	gatewayNetwork = "bufconn"
+)
+
+// gatewayServices registers the gateway routes of each service.
+var gatewayServices = map[string]func(context.Context, *runtime.ServeMux, *grpc.ClientConn) error{
+	pb.DocumentService_ServiceDesc.ServiceName:  pb.RegisterDocumentServiceHandler,
+	pb.CanvasService_ServiceDesc.ServiceName:    pb.RegisterCanvasServiceHandler,
+	pb.SnapshotService_ServiceDesc.ServiceName:  pb.RegisterSnapshotServiceHandler,
+	pb.RoomAdminService_ServiceDesc.ServiceName: pb.RegisterRoomAdminServiceHandler,
+}
+
+// Gateway serves the services registered on srv as JSON over HTTP. Each
+// method is a POST to /excalidraw.v1.<Service>/<Method> whose body and
+// response are its messages in proto JSON with snake_case names; WatchRoom
+// streams newline-delimited results. Calls go to srv through an in-process
+// connection, so they are authenticated and rate limited like gRPC ones,
+// and the gateway works without a gRPC listener.
+func Gateway(srv *grpc.Server) (http.Handler, error) {
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			logrus.WithError(err).Error("gRPC gateway connection closed")
+		}
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///gateway",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize), grpc.MaxCallSendMsgSize(maxMessageSize)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect the gateway: %w", err)
+	}
+
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
+			return metadata.Pairs(gatewayClientKey, ratelimit.KeyByIP(r))
+		}),
+		runtime.WithErrorHandler(handleGatewayError),
+	)
+	for name := range srv.GetServiceInfo() {
+		if register, ok := gatewayServices[name]; ok {
+			if err := register(context.Background(), mux, conn); err != nil {
+				return nil, fmt.Errorf("failed to register %s: %w", name, err)
+			}
+		}
+	}
+	return mux, nil
+}
+
+// handleGatewayError sets X-Error-Code, like REST errors, before writing
+// the status as JSON.
+func handleGatewayError(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if st, ok := status.FromError(err); ok {
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.ErrorInfo); ok {
+				w.Header().Set(apierror.CodeHeader, info.GetReason())
+			}
+		}
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
+
+// gatewayClient returns the rate limit key the gateway passed for its
+// HTTP client, or "" for calls that didn't come through the gateway.
+func gatewayClient(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil || p.Addr.Network() != gatewayNetwork {
+		return ""
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	// HTTP clients can add metadata too; the gateway's comes last
+	values := md.Get(gatewayClientKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
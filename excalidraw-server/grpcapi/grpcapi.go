@@ -0,0 +1,205 @@
+// Package grpcapi serves documents, canvases, snapshots and room
+// administration over gRPC, for backend integrations and the desktop app.
+// The services are defined in proto/excalidraw/v1/excalidraw.proto and call
+// the same stores as the REST handlers; Gateway maps them back to JSON over
+// HTTP.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/grpcapi/pb"
+	"excalidraw-server/handlers/api/canvases"
+	"excalidraw-server/handlers/api/snapshots"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/ratelimit"
+	"net"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// maxMessageSize fits the largest canvas with room for the rest of the
+// message.
+const maxMessageSize = canvases.MaxCanvasSize + 1<<20
+
+type (
+	// Options select the services to serve. Services whose stores or
+	// credentials are missing aren't registered.
+	Options struct {
+		Documents core.DocumentStore
+		// Canvases also needs Verifier.
+		Canvases  core.CanvasStore
+		Snapshots snapshots.SnapshotStore
+		Verifier  *auth.Verifier
+		// AdminToken enables RoomAdminService.
+		AdminToken string
+		Rooms      Rooms
+		// Limiter, when set, rate limits calls that create data by user or
+		// client address.
+		Limiter ratelimit.Limiter
+	}
+
+	// Rooms gives RoomAdminService access to the live rooms.
+	Rooms struct {
+		List        func() map[string]int
+		Stats       func(roomID string) (websocket.RoomStats, bool)
+		Disconnect  func(roomID string) int
+		IsEncrypted func(roomID string) bool
+	}
+)
+
+// limitedMethods create data, like the REST endpoints behind the rate limit.
+var limitedMethods = map[string]bool{
+	pb.DocumentService_CreateDocument_FullMethodName: true,
+	pb.CanvasService_PutCanvas_FullMethodName:        true,
+	pb.SnapshotService_CreateSnapshot_FullMethodName: true,
+}
+
+// NewServer returns a gRPC server with the services opts allow.
+func NewServer(opts Options, serverOpts ...grpc.ServerOption) *grpc.Server {
+	guard := &guard{verifier: opts.Verifier, adminToken: opts.AdminToken, limiter: opts.Limiter}
+	serverOpts = append(serverOpts,
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.MaxSendMsgSize(maxMessageSize),
+		grpc.ChainUnaryInterceptor(guard.unary),
+		grpc.ChainStreamInterceptor(guard.stream),
+	)
+	srv := grpc.NewServer(serverOpts...)
+
+	if opts.Documents != nil {
+		pb.RegisterDocumentServiceServer(srv, &documentService{store: opts.Documents})
+	}
+	if opts.Canvases != nil && opts.Verifier != nil {
+		pb.RegisterCanvasServiceServer(srv, &canvasService{store: opts.Canvases})
+	}
+	if opts.Snapshots != nil {
+		pb.RegisterSnapshotServiceServer(srv, &snapshotService{store: opts.Snapshots, isEncrypted: opts.Rooms.IsEncrypted})
+	}
+	if opts.AdminToken != "" && opts.Rooms.Stats != nil {
+		pb.RegisterRoomAdminServiceServer(srv, &roomAdminService{rooms: opts.Rooms})
+	}
+	return srv
+}
+
+// guard authenticates and rate limits calls by service.
+type guard struct {
+	verifier   *auth.Verifier
+	adminToken string
+	limiter    ratelimit.Limiter
+}
+
+func (g *guard) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := g.check(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (g *guard) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, err := g.check(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// check returns ctx with the caller's claims for CanvasService, and rejects
+// calls without the credentials their service needs or over the rate limit.
+func (g *guard) check(ctx context.Context, method string) (context.Context, error) {
+	token := bearerToken(ctx)
+	switch {
+	case strings.HasPrefix(method, "/"+pb.CanvasService_ServiceDesc.ServiceName+"/"):
+		if token == "" {
+			return ctx, errorStatus(codes.Unauthenticated, apierror.Unauthorized, "authentication required")
+		}
+		claims, err := g.verifier.Verify(token)
+		if err != nil {
+			return ctx, errorStatus(codes.Unauthenticated, apierror.InvalidToken, err.Error())
+		}
+		ctx = auth.WithClaims(ctx, claims)
+	case strings.HasPrefix(method, "/"+pb.RoomAdminService_ServiceDesc.ServiceName+"/"):
+		if !auth.ValidToken(token, g.adminToken) {
+			return ctx, errorStatus(codes.Unauthenticated, apierror.Unauthorized, "authentication required")
+		}
+	}
+
+	if g.limiter != nil && limitedMethods[method] {
+		allowed, _, err := g.limiter.Allow(ctx, g.limitKey(ctx, token))
+		if err != nil {
+			logrus.WithError(err).Warn("Rate limiter failed, letting call through")
+		} else if !allowed {
+			return ctx, errorStatus(codes.ResourceExhausted, apierror.RateLimited, "too many requests")
+		}
+	}
+	return ctx, nil
+}
+
+// limitKey matches ratelimit.KeyByUserOrIP, so REST and gRPC calls share
+// a budget.
+func (g *guard) limitKey(ctx context.Context, token string) string {
+	if claims := auth.ClaimsFromContext(ctx); claims != nil {
+		return "user:" + claims.Subject
+	}
+	if token != "" && g.verifier != nil {
+		if claims, err := g.verifier.Verify(token); err == nil {
+			return "user:" + claims.Subject
+		}
+	}
+	if key := gatewayClient(ctx); key != "" {
+		return key
+	}
+	host := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host = p.Addr.String()
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	return "ip:" + host
+}
+
+// bearerToken returns the bearer token of the authorization metadata.
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if strings.HasPrefix(value, "Bearer ") {
+			return strings.TrimSpace(strings.TrimPrefix(value, "Bearer "))
+		}
+	}
+	return ""
+}
+
+// errorStatus is a gRPC error carrying the apierror code as the reason of
+// an ErrorInfo detail, so clients can branch on the same codes as over
+// REST.
+func errorStatus(c codes.Code, code apierror.Code, message string) error {
+	st := status.New(c, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: string(code), Domain: "excalidraw"}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// storeError is the error for a failed store call: DeadlineExceeded or
+// Canceled when the call ran out of time or was cancelled, fallback
+// otherwise.
+func storeError(err error, fallback codes.Code, code apierror.Code, message string) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorStatus(codes.DeadlineExceeded, apierror.Timeout, message)
+	case errors.Is(err, context.Canceled):
+		return errorStatus(codes.Canceled, apierror.Cancelled, message)
+	default:
+		return errorStatus(fallback, code, message)
+	}
+}
@@ -0,0 +1,243 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/grpcapi/pb"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/stores/memory"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testAdminToken = "admin-secret"
+
+// limitAll allows the first call of each key and no more.
+type limitAll struct {
+	seen map[string]bool
+}
+
+func (l *limitAll) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	if l.seen[key] {
+		return false, time.Minute, nil
+	}
+	l.seen[key] = true
+	return true, 0, nil
+}
+
+func (l *limitAll) SetRate(perMinute, burst int) {}
+
+func testOptions() Options {
+	store := memory.NewDocumentStore()
+	canvasStore, _ := store.(core.CanvasStore)
+	return Options{
+		Documents:  store,
+		Canvases:   canvasStore,
+		Verifier:   auth.NewVerifier([]byte("secret")),
+		AdminToken: testAdminToken,
+		Rooms: Rooms{
+			List: func() map[string]int { return map[string]int{"room": 2} },
+			Stats: func(roomID string) (websocket.RoomStats, bool) {
+				return websocket.RoomStats{RoomID: roomID, Users: []websocket.RoomMember{{SocketID: "a"}}}, roomID == "room"
+			},
+			Disconnect: func(roomID string) int { return 2 },
+		},
+	}
+}
+
+// dial serves opts in memory and returns a client connection to it.
+func dial(t *testing.T, opts Options) *grpc.ClientConn {
+	t.Helper()
+	srv := NewServer(opts)
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///test",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// assertCode checks the gRPC code and apierror code of err.
+func assertCode(t *testing.T, err error, want codes.Code, reason apierror.Code) {
+	t.Helper()
+	st := status.Convert(err)
+	if st.Code() != want {
+		t.Fatalf("code = %v (%v), want %v", st.Code(), err, want)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() == string(reason) {
+			return
+		}
+	}
+	t.Errorf("details = %v, want reason %s", st.Details(), reason)
+}
+
+func TestDocuments(t *testing.T) {
+	client := pb.NewDocumentServiceClient(dial(t, testOptions()))
+	ctx := context.Background()
+
+	created, err := client.CreateDocument(ctx, &pb.CreateDocumentRequest{Data: []byte(`{"elements":[]}`)})
+	if err != nil {
+		t.Fatalf("CreateDocument() failed: %v", err)
+	}
+	document, err := client.GetDocument(ctx, &pb.GetDocumentRequest{Id: created.GetId()})
+	if err != nil || string(document.GetData()) != `{"elements":[]}` {
+		t.Errorf("GetDocument() = %v, %v", document, err)
+	}
+
+	_, err = client.GetDocument(ctx, &pb.GetDocumentRequest{Id: "missing"})
+	assertCode(t, err, codes.NotFound, apierror.DocumentNotFound)
+}
+
+func TestCanvases(t *testing.T) {
+	opts := testOptions()
+	client := pb.NewCanvasServiceClient(dial(t, opts))
+
+	_, err := client.ListCanvases(context.Background(), &pb.ListCanvasesRequest{})
+	assertCode(t, err, codes.Unauthenticated, apierror.Unauthorized)
+	_, err = client.ListCanvases(withToken("nope"), &pb.ListCanvasesRequest{})
+	assertCode(t, err, codes.Unauthenticated, apierror.InvalidToken)
+
+	token, err := opts.Verifier.Sign(&auth.Claims{Subject: "alice"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	ctx := withToken(token)
+	if _, err := client.PutCanvas(ctx, &pb.PutCanvasRequest{Key: "plan", Data: []byte("scene")}); err != nil {
+		t.Fatalf("PutCanvas() failed: %v", err)
+	}
+	_, err = client.PutCanvas(ctx, &pb.PutCanvasRequest{Key: "../plan", Data: []byte("scene")})
+	assertCode(t, err, codes.InvalidArgument, apierror.InvalidCanvasKey)
+
+	name := "Floor plan"
+	canvas, err := client.UpdateCanvasMetadata(ctx, &pb.UpdateCanvasMetadataRequest{Key: "plan", Name: &name, Tags: &pb.Tags{Values: []string{"home", "home"}}})
+	if err != nil || canvas.GetName() != name || len(canvas.GetTags()) != 1 {
+		t.Errorf("UpdateCanvasMetadata() = %v, %v", canvas, err)
+	}
+
+	list, err := client.ListCanvases(ctx, &pb.ListCanvasesRequest{})
+	if err != nil || len(list.GetCanvases()) != 1 || list.GetCanvases()[0].GetData() != nil {
+		t.Errorf("ListCanvases() = %v, %v", list, err)
+	}
+	canvas, err = client.GetCanvas(ctx, &pb.GetCanvasRequest{Key: "plan"})
+	if err != nil || string(canvas.GetData()) != "scene" {
+		t.Errorf("GetCanvas() = %v, %v", canvas, err)
+	}
+
+	if _, err := client.DeleteCanvas(ctx, &pb.DeleteCanvasRequest{Key: "plan"}); err != nil {
+		t.Fatalf("DeleteCanvas() failed: %v", err)
+	}
+	_, err = client.GetCanvas(ctx, &pb.GetCanvasRequest{Key: "plan"})
+	assertCode(t, err, codes.NotFound, apierror.CanvasNotFound)
+}
+
+func TestRoomAdmin(t *testing.T) {
+	client := pb.NewRoomAdminServiceClient(dial(t, testOptions()))
+
+	_, err := client.ListRooms(withToken("wrong"), &pb.ListRoomsRequest{})
+	assertCode(t, err, codes.Unauthenticated, apierror.Unauthorized)
+
+	ctx := withToken(testAdminToken)
+	rooms, err := client.ListRooms(ctx, &pb.ListRoomsRequest{})
+	if err != nil || rooms.GetUsers()["room"] != 2 {
+		t.Errorf("ListRooms() = %v, %v", rooms, err)
+	}
+	_, err = client.GetRoom(ctx, &pb.GetRoomRequest{RoomId: "gone"})
+	assertCode(t, err, codes.NotFound, apierror.RoomNotFound)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.WatchRoom(ctx, &pb.WatchRoomRequest{RoomId: "room", IntervalMs: 1})
+	if err != nil {
+		t.Fatalf("WatchRoom() failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		stats, err := stream.Recv()
+		if err != nil || stats.GetRoomId() != "room" || len(stats.GetMembers()) != 1 {
+			t.Fatalf("Recv() = %v, %v", stats, err)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	opts := testOptions()
+	opts.Limiter = &limitAll{seen: map[string]bool{}}
+	client := pb.NewDocumentServiceClient(dial(t, opts))
+
+	if _, err := client.CreateDocument(context.Background(), &pb.CreateDocumentRequest{Data: []byte("{}")}); err != nil {
+		t.Fatalf("first CreateDocument() failed: %v", err)
+	}
+	_, err := client.CreateDocument(context.Background(), &pb.CreateDocumentRequest{Data: []byte("{}")})
+	assertCode(t, err, codes.ResourceExhausted, apierror.RateLimited)
+}
+
+func TestGateway(t *testing.T) {
+	opts := testOptions()
+	opts.Limiter = &limitAll{seen: map[string]bool{}}
+	srv := NewServer(opts)
+	t.Cleanup(srv.Stop)
+	gateway, err := Gateway(srv)
+	if err != nil {
+		t.Fatalf("Gateway() failed: %v", err)
+	}
+
+	post := func(path, body, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.RemoteAddr = remoteAddr
+		for key, values := range header {
+			r.Header[key] = values
+		}
+		w := httptest.NewRecorder()
+		gateway.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post("/excalidraw.v1.DocumentService/CreateDocument", `{"data":"e30="}`, "192.0.2.1:1234", nil)
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); w.Code != http.StatusOK || err != nil || created.ID == "" {
+		t.Fatalf("CreateDocument = %d %s", w.Code, w.Body.String())
+	}
+	// Clients are limited by their own address, which they can't spoof
+	spoofed := http.Header{"Grpc-Metadata-" + gatewayClientKey: {"ip:192.0.2.2"}}
+	if w := post("/excalidraw.v1.DocumentService/CreateDocument", `{}`, "192.0.2.1:1234", spoofed); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second CreateDocument = %d %s, want 429", w.Code, w.Body.String())
+	}
+	if w := post("/excalidraw.v1.DocumentService/CreateDocument", `{}`, "192.0.2.2:1234", nil); w.Code != http.StatusOK {
+		t.Errorf("CreateDocument from another client = %d %s", w.Code, w.Body.String())
+	}
+
+	w = post("/excalidraw.v1.RoomAdminService/GetRoom", `{"room_id":"gone"}`, "192.0.2.1:1234", http.Header{"Authorization": {"Bearer " + testAdminToken}})
+	if w.Code != http.StatusNotFound || w.Header().Get(apierror.CodeHeader) != string(apierror.RoomNotFound) {
+		t.Errorf("GetRoom = %d %s, %s", w.Code, w.Header().Get(apierror.CodeHeader), w.Body.String())
+	}
+}
@@ -0,0 +1,101 @@
+package grpcapi
+
+import (
+	"context"
+	"excalidraw-server/apierror"
+	"excalidraw-server/grpcapi/pb"
+	"excalidraw-server/handlers/websocket"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	defaultWatchInterval = time.Second
+	minWatchInterval     = 100 * time.Millisecond
+)
+
+type roomAdminService struct {
+	pb.UnimplementedRoomAdminServiceServer
+	rooms Rooms
+}
+
+func (s *roomAdminService) ListRooms(ctx context.Context, req *pb.ListRoomsRequest) (*pb.ListRoomsResponse, error) {
+	resp := &pb.ListRoomsResponse{Users: map[string]int32{}}
+	if s.rooms.List != nil {
+		for roomID, users := range s.rooms.List() {
+			resp.Users[roomID] = int32(users)
+		}
+	}
+	return resp, nil
+}
+
+func (s *roomAdminService) GetRoom(ctx context.Context, req *pb.GetRoomRequest) (*pb.RoomStats, error) {
+	stats, ok := s.rooms.Stats(req.GetRoomId())
+	if !ok {
+		return nil, errorStatus(codes.NotFound, apierror.RoomNotFound, "room not found")
+	}
+	return roomStatsMessage(stats), nil
+}
+
+// DisconnectRoom force-disconnects every socket in a room
+func (s *roomAdminService) DisconnectRoom(ctx context.Context, req *pb.DisconnectRoomRequest) (*pb.DisconnectRoomResponse, error) {
+	if s.rooms.Disconnect == nil {
+		return nil, errorStatus(codes.Unimplemented, apierror.NotImplemented, "disconnecting rooms is not available")
+	}
+	count := s.rooms.Disconnect(req.GetRoomId())
+	logrus.WithFields(logrus.Fields{
+		"room_id":      req.GetRoomId(),
+		"disconnected": count,
+	}).Info("Room connections closed")
+	return &pb.DisconnectRoomResponse{Disconnected: int32(count)}, nil
+}
+
+// WatchRoom sends the room's stats right away and then every interval. The
+// stream ends once the room has closed.
+func (s *roomAdminService) WatchRoom(req *pb.WatchRoomRequest, stream pb.RoomAdminService_WatchRoomServer) error {
+	interval := defaultWatchInterval
+	if req.GetIntervalMs() > 0 {
+		interval = max(time.Duration(req.GetIntervalMs())*time.Millisecond, minWatchInterval)
+	}
+
+	stats, ok := s.rooms.Stats(req.GetRoomId())
+	if !ok {
+		return errorStatus(codes.NotFound, apierror.RoomNotFound, "room not found")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := stream.Send(roomStatsMessage(stats)); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+		if stats, ok = s.rooms.Stats(req.GetRoomId()); !ok {
+			return nil
+		}
+	}
+}
+
+func roomStatsMessage(stats websocket.RoomStats) *pb.RoomStats {
+	message := &pb.RoomStats{
+		RoomId:            stats.RoomID,
+		Mode:              string(stats.Mode),
+		Messages:          stats.Messages,
+		BytesBroadcast:    stats.BytesBroadcast,
+		MessagesPerMinute: stats.MessagesPerMinute,
+	}
+	for _, user := range stats.Users {
+		member := &pb.RoomMember{SocketId: user.SocketID, JoinedAt: user.JoinedAt}
+		if user.User != nil {
+			member.UserId = user.User.ID
+			member.UserName = user.User.Name
+		}
+		message.Members = append(message.Members, member)
+	}
+	return message
+}
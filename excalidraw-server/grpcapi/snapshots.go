@@ -0,0 +1,81 @@
+package grpcapi
+
+import (
+	"context"
+	"excalidraw-server/apierror"
+	"excalidraw-server/grpcapi/pb"
+	"excalidraw-server/handlers/api/snapshots"
+	"excalidraw-server/stores/sqlite"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type snapshotService struct {
+	pb.UnimplementedSnapshotServiceServer
+	store snapshots.SnapshotStore
+	// isEncrypted, when set, reports rooms whose scenes must not be saved
+	isEncrypted func(roomID string) bool
+}
+
+func (s *snapshotService) CreateSnapshot(ctx context.Context, req *pb.CreateSnapshotRequest) (*pb.CreateSnapshotResponse, error) {
+	if req.GetRoomId() == "" {
+		return nil, errorStatus(codes.InvalidArgument, apierror.InvalidRequest, "room_id is required")
+	}
+	if s.isEncrypted != nil && s.isEncrypted(req.GetRoomId()) {
+		return nil, errorStatus(codes.FailedPrecondition, apierror.RoomEncrypted, "snapshots are disabled for encrypted rooms")
+	}
+
+	id, err := s.store.CreateSnapshot(ctx, req.GetRoomId(), req.GetName(), req.GetDescription(), "", req.GetCreatedBy(), req.GetData())
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to create snapshot")
+		return nil, storeError(err, codes.Internal, apierror.Internal, "failed to create snapshot")
+	}
+	return &pb.CreateSnapshotResponse{Id: id}, nil
+}
+
+// ListSnapshots lists a room's snapshots without their data
+func (s *snapshotService) ListSnapshots(ctx context.Context, req *pb.ListSnapshotsRequest) (*pb.ListSnapshotsResponse, error) {
+	list, err := s.store.ListSnapshots(ctx, req.GetRoomId())
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list snapshots")
+		return nil, storeError(err, codes.Internal, apierror.Internal, "failed to list snapshots")
+	}
+	resp := &pb.ListSnapshotsResponse{}
+	for i := range list {
+		message := snapshotMessage(&list[i])
+		message.Data = nil
+		resp.Snapshots = append(resp.Snapshots, message)
+	}
+	return resp, nil
+}
+
+func (s *snapshotService) GetSnapshot(ctx context.Context, req *pb.GetSnapshotRequest) (*pb.Snapshot, error) {
+	snapshot, err := s.store.GetSnapshot(ctx, req.GetId())
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to get snapshot")
+		return nil, storeError(err, codes.NotFound, apierror.SnapshotNotFound, "snapshot not found")
+	}
+	return snapshotMessage(snapshot), nil
+}
+
+func (s *snapshotService) DeleteSnapshot(ctx context.Context, req *pb.DeleteSnapshotRequest) (*emptypb.Empty, error) {
+	if err := s.store.DeleteSnapshot(ctx, req.GetId()); err != nil {
+		logrus.WithField("error", err).Error("Failed to delete snapshot")
+		return nil, storeError(err, codes.NotFound, apierror.SnapshotNotFound, "snapshot not found")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func snapshotMessage(snapshot *sqlite.Snapshot) *pb.Snapshot {
+	return &pb.Snapshot{
+		Id:          snapshot.ID,
+		RoomId:      snapshot.RoomID,
+		Name:        snapshot.Name,
+		Description: snapshot.Description,
+		CreatedBy:   snapshot.CreatedBy,
+		CreatedAt:   snapshot.CreatedAt,
+		Data:        snapshot.Data,
+	}
+}
@@ -0,0 +1,412 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/api/orgs"
+	"excalidraw-server/handlers/websocket"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// exportedNotifications bounds the notifications in an export.
+const exportedNotifications = 100000
+
+type (
+	// RoomStore finds and updates the rooms a user owns or is allowed in.
+	RoomStore interface {
+		core.RoomPermissionStore
+		core.RoomPermissionLister
+	}
+
+	// Stores are where data about users is kept; nil ones are skipped.
+	Stores struct {
+		Canvases      core.CanvasStore
+		Libraries     core.LibraryStore
+		Orgs          core.OrgStore
+		Rooms         RoomStore
+		Users         core.UserStore
+		Notifications core.NotificationStore
+		// Chat returns the chat messages a user sent.
+		Chat func(ctx context.Context, userID string) ([]websocket.ChatMessage, error)
+		// AnonymizeChat removes a user from the chat messages they sent
+		// and returns how many there were.
+		AnonymizeChat func(ctx context.Context, userID string) (int, error)
+	}
+
+	// DeleteResponse counts what deleting an account removed or anonymized.
+	DeleteResponse struct {
+		Canvases  int `json:"canvases"`
+		Libraries int `json:"libraries"`
+		// Orgs are the organizations the user left; the ones nobody else
+		// belonged to are deleted.
+		Orgs          int `json:"orgs"`
+		Rooms         int `json:"rooms"`
+		Notifications int `json:"notifications"`
+		ChatMessages  int `json:"chat_messages"`
+	}
+)
+
+// HandleExport serves a zip of everything stored about the caller: their
+// profile, canvases, libraries, organization memberships, room permissions,
+// notifications and the chat messages they sent
+func HandleExport(stores Stores) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := auth.ClaimsFromContext(r.Context())
+
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		if err := writeExport(r.Context(), zw, stores, claims); err != nil {
+			logrus.WithField("user_id", claims.Subject).WithField("error", err).Error("Failed to export user data")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to export data")
+			return
+		}
+		if err := zw.Close(); err != nil {
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to export data")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="excalidraw-export.zip"`)
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.Header().Set("Cache-Control", "private, no-store")
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// exportWriter adds files to an export.
+type exportWriter struct {
+	zw  *zip.Writer
+	now time.Time
+}
+
+func (e exportWriter) file(name string, data []byte) error {
+	f, err := e.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: e.now})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func (e exportWriter) json(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return e.file(name, data)
+}
+
+func writeExport(ctx context.Context, zw *zip.Writer, stores Stores, claims *auth.Claims) error {
+	userID := claims.Subject
+	export := exportWriter{zw: zw, now: time.Now()}
+
+	profile := core.User{ID: userID, Login: claims.Login, Name: claims.Name, Email: claims.Email, AvatarURL: claims.AvatarURL}
+	if stores.Users != nil {
+		user, err := stores.Users.GetUser(ctx, userID)
+		if err != nil && !errors.Is(err, core.ErrUserNotFound) {
+			return err
+		}
+		if user != nil {
+			profile = *user
+		}
+	}
+	if err := export.json("profile.json", profile); err != nil {
+		return err
+	}
+
+	if stores.Canvases != nil {
+		list, err := stores.Canvases.ListCanvases(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, listed := range list {
+			canvas, err := stores.Canvases.GetCanvas(ctx, userID, listed.Key)
+			if errors.Is(err, core.ErrCanvasNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			// canvases are stored as sent, and clients may encrypt them
+			name := "canvases/" + canvas.Key + ".bin"
+			if json.Valid(canvas.Data) {
+				name = "canvases/" + canvas.Key + ".excalidraw"
+			}
+			if err := export.file(name, canvas.Data); err != nil {
+				return err
+			}
+		}
+		if list == nil {
+			list = []core.Canvas{}
+		}
+		if err := export.json("canvases.json", list); err != nil {
+			return err
+		}
+	}
+
+	if stores.Libraries != nil {
+		list, err := stores.Libraries.ListLibraries(ctx, core.LibraryFilter{OwnerID: userID})
+		if err != nil {
+			return err
+		}
+		for _, listed := range list {
+			library, err := stores.Libraries.GetLibrary(ctx, listed.ID)
+			if errors.Is(err, core.ErrLibraryNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := export.file("libraries/"+library.ID+".excalidrawlib", library.Data); err != nil {
+				return err
+			}
+		}
+		if list == nil {
+			list = []core.Library{}
+		}
+		if err := export.json("libraries.json", list); err != nil {
+			return err
+		}
+	}
+
+	if stores.Orgs != nil {
+		list, err := stores.Orgs.ListOrgs(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if list == nil {
+			list = []core.Org{}
+		}
+		if err := export.json("orgs.json", list); err != nil {
+			return err
+		}
+	}
+
+	if stores.Rooms != nil {
+		list, err := stores.Rooms.ListUserRoomPermissions(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := export.json("rooms.json", list); err != nil {
+			return err
+		}
+	}
+
+	if stores.Notifications != nil {
+		list, err := stores.Notifications.ListNotifications(ctx, userID, false, exportedNotifications)
+		if err != nil {
+			return err
+		}
+		if err := export.json("notifications.json", list); err != nil {
+			return err
+		}
+	}
+
+	if stores.Chat != nil {
+		messages, err := stores.Chat(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := export.json("chat.json", messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleDelete deletes the caller's canvases, libraries, notifications and
+// profile, takes them out of organizations and rooms and anonymizes the chat
+// messages they sent. Deleting again finishes an interrupted deletion.
+func HandleDelete(stores Stores) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := auth.ClaimsFromContext(r.Context()).Subject
+		log := logrus.WithField("user_id", userID)
+
+		deleted, err := deleteUser(r.Context(), stores, userID)
+		if err != nil {
+			log.WithField("error", err).Error("Failed to delete user data")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to delete data")
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"canvases":      deleted.Canvases,
+			"libraries":     deleted.Libraries,
+			"orgs":          deleted.Orgs,
+			"rooms":         deleted.Rooms,
+			"notifications": deleted.Notifications,
+			"chat_messages": deleted.ChatMessages,
+		}).Info("User data deleted")
+		render.JSON(w, r, deleted)
+	}
+}
+
+func deleteUser(ctx context.Context, stores Stores, userID string) (DeleteResponse, error) {
+	var deleted DeleteResponse
+
+	if stores.Canvases != nil {
+		list, err := stores.Canvases.ListCanvases(ctx, userID)
+		if err != nil {
+			return deleted, err
+		}
+		for _, canvas := range list {
+			err := stores.Canvases.DeleteCanvas(ctx, userID, canvas.Key)
+			if err != nil && !errors.Is(err, core.ErrCanvasNotFound) {
+				return deleted, err
+			}
+			deleted.Canvases++
+		}
+	}
+
+	if stores.Libraries != nil {
+		list, err := stores.Libraries.ListLibraries(ctx, core.LibraryFilter{OwnerID: userID})
+		if err != nil {
+			return deleted, err
+		}
+		for _, library := range list {
+			err := stores.Libraries.DeleteLibrary(ctx, library.ID)
+			if err != nil && !errors.Is(err, core.ErrLibraryNotFound) {
+				return deleted, err
+			}
+			deleted.Libraries++
+		}
+	}
+
+	if stores.Orgs != nil {
+		list, err := stores.Orgs.ListOrgs(ctx, userID)
+		if err != nil {
+			return deleted, err
+		}
+		for _, org := range list {
+			if err := leaveOrg(ctx, stores, org.ID, userID); err != nil {
+				return deleted, err
+			}
+			deleted.Orgs++
+		}
+	}
+
+	if stores.Rooms != nil {
+		list, err := stores.Rooms.ListUserRoomPermissions(ctx, userID)
+		if err != nil {
+			return deleted, err
+		}
+		for _, permissions := range list {
+			if err := stores.Rooms.PutRoomPermissions(ctx, withoutUser(permissions, userID)); err != nil {
+				return deleted, err
+			}
+			deleted.Rooms++
+		}
+	}
+
+	if stores.Notifications != nil {
+		count, err := stores.Notifications.DeleteNotifications(ctx, userID)
+		if err != nil {
+			return deleted, err
+		}
+		deleted.Notifications = count
+	}
+
+	if stores.AnonymizeChat != nil {
+		count, err := stores.AnonymizeChat(ctx, userID)
+		if err != nil {
+			return deleted, err
+		}
+		deleted.ChatMessages = count
+	}
+
+	// last, so mentions keep finding the user until everything else is gone
+	if stores.Users != nil {
+		if err := stores.Users.DeleteUser(ctx, userID); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// leaveOrg removes userID from an organization. Organizations nobody else
+// belongs to are deleted with their canvases, and ones the user was the
+// last admin of get their longest-standing member as admin.
+func leaveOrg(ctx context.Context, stores Stores, orgID, userID string) error {
+	members, err := stores.Orgs.ListOrgMembers(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	var others []core.OrgMember
+	admins := 0
+	for _, member := range members {
+		if member.UserID == userID {
+			continue
+		}
+		others = append(others, member)
+		if member.Role == core.OrgRoleAdmin {
+			admins++
+		}
+	}
+
+	if len(others) == 0 {
+		if stores.Canvases != nil {
+			shared, err := stores.Canvases.ListCanvases(ctx, orgs.CanvasOwner(orgID))
+			if err != nil {
+				return err
+			}
+			for _, canvas := range shared {
+				err := stores.Canvases.DeleteCanvas(ctx, orgs.CanvasOwner(orgID), canvas.Key)
+				if err != nil && !errors.Is(err, core.ErrCanvasNotFound) {
+					return err
+				}
+			}
+		}
+		if err := stores.Orgs.DeleteOrg(ctx, orgID); err != nil && !errors.Is(err, core.ErrOrgNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	if admins == 0 {
+		successor := others[0]
+		successor.Role = core.OrgRoleAdmin
+		if err := stores.Orgs.PutOrgMember(ctx, orgID, successor); err != nil {
+			return err
+		}
+	}
+	if err := stores.Orgs.RemoveOrgMember(ctx, orgID, userID); err != nil && !errors.Is(err, core.ErrNotMember) {
+		return err
+	}
+	return nil
+}
+
+// withoutUser takes userID off a room's allowlist and hands rooms they own
+// to the first user left on it. Rooms without one get an owner nobody can
+// sign in as, so private rooms stay closed rather than up for grabs.
+func withoutUser(permissions core.RoomPermissions, userID string) *core.RoomPermissions {
+	allowed := make([]string, 0, len(permissions.AllowedUsers))
+	for _, allowedID := range permissions.AllowedUsers {
+		if allowedID != userID {
+			allowed = append(allowed, allowedID)
+		}
+	}
+	permissions.AllowedUsers = allowed
+
+	if permissions.Owner == userID {
+		if len(allowed) > 0 {
+			permissions.Owner = allowed[0]
+		} else {
+			permissions.Owner = "deleted:" + ulid.Make().String()
+		}
+	}
+	return &permissions
+}
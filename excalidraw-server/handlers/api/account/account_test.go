@@ -0,0 +1,296 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/orgs"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/stores/memory"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// roomMap is a RoomStore for tests.
+type roomMap map[string]core.RoomPermissions
+
+func (m roomMap) GetRoomPermissions(_ context.Context, roomID string) (*core.RoomPermissions, error) {
+	permissions := m[roomID]
+	return &permissions, nil
+}
+
+func (m roomMap) PutRoomPermissions(_ context.Context, permissions *core.RoomPermissions) error {
+	m[permissions.RoomID] = *permissions
+	return nil
+}
+
+func (m roomMap) ListUserRoomPermissions(_ context.Context, userID string) ([]core.RoomPermissions, error) {
+	var list []core.RoomPermissions
+	for _, permissions := range m {
+		if permissions.Owner == userID {
+			list = append(list, permissions)
+			continue
+		}
+		for _, allowed := range permissions.AllowedUsers {
+			if allowed == userID {
+				list = append(list, permissions)
+				break
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].RoomID < list[j].RoomID })
+	return list, nil
+}
+
+type fixture struct {
+	stores Stores
+	// organizations alice is the only member of, the only admin of and one
+	// of two admins of
+	soloOrg, sharedOrg, adminOrg string
+	anonymized                   []string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.NewDocumentStore()
+	f := &fixture{stores: Stores{
+		Canvases:      store.(core.CanvasStore),
+		Libraries:     store.(core.LibraryStore),
+		Orgs:          store.(core.OrgStore),
+		Users:         store.(core.UserStore),
+		Notifications: store.(core.NotificationStore),
+		Rooms: roomMap{
+			"owned":   {RoomID: "owned", Owner: "alice", Visibility: core.RoomPrivate, AllowedUsers: []string{"bob"}},
+			"alone":   {RoomID: "alone", Owner: "alice", Visibility: core.RoomPrivate, AllowedUsers: []string{}},
+			"invited": {RoomID: "invited", Owner: "bob", Visibility: core.RoomPrivate, AllowedUsers: []string{"alice", "carol"}},
+			"other":   {RoomID: "other", Owner: "bob", Visibility: core.RoomPublic, AllowedUsers: []string{}},
+		},
+		Chat: func(_ context.Context, userID string) ([]websocket.ChatMessage, error) {
+			return []websocket.ChatMessage{{ID: "m1", RoomID: "owned", Content: "hello", User: &websocket.UserInfo{ID: userID}}}, nil
+		},
+	}}
+	f.stores.AnonymizeChat = func(_ context.Context, userID string) (int, error) {
+		f.anonymized = append(f.anonymized, userID)
+		return 1, nil
+	}
+
+	for _, canvas := range []core.Canvas{
+		{OwnerID: "alice", Key: "plan", Data: []byte(`{"type":"excalidraw"}`)},
+		{OwnerID: "alice", Key: "secret", Data: []byte{0x01, 0x02}},
+		{OwnerID: "bob", Key: "plan", Data: []byte(`{}`)},
+	} {
+		canvas := canvas
+		if err := f.stores.Canvases.PutCanvas(ctx, &canvas); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.stores.Libraries.CreateLibrary(ctx, &core.Library{OwnerID: "alice", Name: "shapes", Data: json.RawMessage(`{"type":"excalidrawlib"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.stores.Libraries.CreateLibrary(ctx, &core.Library{OwnerID: "bob", Name: "bob's", Data: json.RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	solo, err := f.stores.Orgs.CreateOrg(ctx, "solo", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := f.stores.Orgs.CreateOrg(ctx, "shared", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := f.stores.Orgs.CreateOrg(ctx, "admin", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.soloOrg, f.sharedOrg, f.adminOrg = solo.ID, shared.ID, admin.ID
+	for _, add := range []struct {
+		org    string
+		member core.OrgMember
+	}{
+		{shared.ID, core.OrgMember{UserID: "bob", Role: core.OrgRoleMember}},
+		{shared.ID, core.OrgMember{UserID: "carol", Role: core.OrgRoleMember}},
+		{admin.ID, core.OrgMember{UserID: "carol", Role: core.OrgRoleAdmin}},
+		{admin.ID, core.OrgMember{UserID: "bob", Role: core.OrgRoleMember}},
+	} {
+		if err := f.stores.Orgs.PutOrgMember(ctx, add.org, add.member); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.stores.Canvases.PutCanvas(ctx, &core.Canvas{OwnerID: orgs.CanvasOwner(solo.ID), Key: "team", Data: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.stores.Users.PutUser(ctx, core.User{ID: "alice", Login: "alice", Email: "alice@example.com", SeenAt: 5}); err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []string{"alice", "alice", "bob"} {
+		if _, err := f.stores.Notifications.AddNotification(ctx, core.Notification{UserID: userID, Kind: core.NotificationMention, Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func request(method string, handler http.HandlerFunc, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/v2/me", nil)
+	req = req.WithContext(auth.WithClaims(req.Context(), &auth.Claims{Subject: userID, Name: "Alice"}))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestHandleExport(t *testing.T) {
+	f := newFixture(t)
+
+	w := request(http.MethodGet, HandleExport(f.stores), "alice")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("export isn't a zip: %v", err)
+	}
+	files := make(map[string]string)
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	for _, name := range []string{"profile.json", "canvases.json", "libraries.json", "orgs.json", "rooms.json", "notifications.json", "chat.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("export is missing %s", name)
+		}
+	}
+	if files["canvases/plan.excalidraw"] != `{"type":"excalidraw"}` || files["canvases/secret.bin"] != "\x01\x02" {
+		t.Errorf("exported canvases = %q and %q", files["canvases/plan.excalidraw"], files["canvases/secret.bin"])
+	}
+	libraries := 0
+	for name, data := range files {
+		if strings.HasPrefix(name, "libraries/") {
+			libraries++
+			if data != `{"type":"excalidrawlib"}` {
+				t.Errorf("exported library %s = %q", name, data)
+			}
+		}
+	}
+	if libraries != 1 {
+		t.Errorf("exported %d libraries, want alice's one", libraries)
+	}
+	if !strings.Contains(files["profile.json"], "alice@example.com") {
+		t.Errorf("profile.json = %s, want the stored user", files["profile.json"])
+	}
+
+	var notifications []core.Notification
+	if err := json.Unmarshal([]byte(files["notifications.json"]), &notifications); err != nil || len(notifications) != 2 {
+		t.Errorf("notifications.json = %s, %v, want alice's two", files["notifications.json"], err)
+	}
+	var rooms []core.RoomPermissions
+	if err := json.Unmarshal([]byte(files["rooms.json"]), &rooms); err != nil || len(rooms) != 3 {
+		t.Errorf("rooms.json = %s, %v, want three", files["rooms.json"], err)
+	}
+	var orgList []core.Org
+	if err := json.Unmarshal([]byte(files["orgs.json"]), &orgList); err != nil || len(orgList) != 3 {
+		t.Errorf("orgs.json = %s, %v, want three", files["orgs.json"], err)
+	}
+}
+
+func TestHandleExportWithoutStores(t *testing.T) {
+	w := request(http.MethodGet, HandleExport(Stores{}), "alice")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil || len(zr.File) != 1 || zr.File[0].Name != "profile.json" {
+		t.Errorf("export without stores = %v, %v, want only the profile from the token", zr, err)
+	}
+}
+
+func TestHandleDelete(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+
+	w := request(http.MethodDelete, HandleDelete(f.stores), "alice")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var got DeleteResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := DeleteResponse{Canvases: 2, Libraries: 1, Orgs: 3, Rooms: 3, Notifications: 2, ChatMessages: 1}
+	if got != want {
+		t.Errorf("HandleDelete() = %+v, want %+v", got, want)
+	}
+
+	if list, _ := f.stores.Canvases.ListCanvases(ctx, "alice"); len(list) != 0 {
+		t.Errorf("alice still has canvases %v", list)
+	}
+	if list, _ := f.stores.Canvases.ListCanvases(ctx, "bob"); len(list) != 1 {
+		t.Errorf("bob's canvases = %v, want untouched", list)
+	}
+	if list, _ := f.stores.Libraries.ListLibraries(ctx, core.LibraryFilter{}); len(list) != 1 || list[0].OwnerID != "bob" {
+		t.Errorf("libraries left = %v, want bob's", list)
+	}
+	if _, err := f.stores.Users.GetUser(ctx, "alice"); err == nil {
+		t.Error("alice is still in the user directory")
+	}
+	if list, _ := f.stores.Notifications.ListNotifications(ctx, "bob", false, 10); len(list) != 1 {
+		t.Errorf("bob's notifications = %v, want untouched", list)
+	}
+	if len(f.anonymized) != 1 || f.anonymized[0] != "alice" {
+		t.Errorf("anonymized chat of %v, want alice", f.anonymized)
+	}
+
+	if list, _ := f.stores.Orgs.ListOrgs(ctx, "alice"); len(list) != 0 {
+		t.Errorf("alice is still in organizations %v", list)
+	}
+	if _, err := f.stores.Orgs.GetOrg(ctx, f.soloOrg); err == nil {
+		t.Error("the organization only alice belonged to still exists")
+	}
+	if list, _ := f.stores.Canvases.ListCanvases(ctx, orgs.CanvasOwner(f.soloOrg)); len(list) != 0 {
+		t.Errorf("the deleted organization still has canvases %v", list)
+	}
+	if member, err := f.stores.Orgs.GetOrgMember(ctx, f.sharedOrg, "bob"); err != nil || member.Role != core.OrgRoleAdmin {
+		t.Errorf("bob in the shared organization = %+v, %v, want promoted to admin", member, err)
+	}
+	if member, err := f.stores.Orgs.GetOrgMember(ctx, f.adminOrg, "bob"); err != nil || member.Role != core.OrgRoleMember {
+		t.Errorf("bob in the organization with another admin = %+v, %v, want still a member", member, err)
+	}
+
+	rooms := f.stores.Rooms.(roomMap)
+	if owned := rooms["owned"]; owned.Owner != "bob" || len(owned.AllowedUsers) != 1 {
+		t.Errorf("owned room = %+v, want handed to bob", owned)
+	}
+	if alone := rooms["alone"]; alone.Owner == "alice" || !strings.HasPrefix(alone.Owner, "deleted:") || alone.Visibility != core.RoomPrivate {
+		t.Errorf("room without other users = %+v, want a placeholder owner", alone)
+	}
+	if invited := rooms["invited"]; len(invited.AllowedUsers) != 1 || invited.AllowedUsers[0] != "carol" {
+		t.Errorf("room alice was invited to = %+v, want only carol allowed", invited)
+	}
+
+	w = request(http.MethodDelete, HandleDelete(f.stores), "alice")
+	if w.Code != http.StatusOK {
+		t.Fatalf("second delete: expected 200, got %d", w.Code)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Canvases != 0 || got.Orgs != 0 || got.Rooms != 0 {
+		t.Errorf("second delete = %+v, %v, want nothing left", got, err)
+	}
+}
@@ -0,0 +1,144 @@
+// Package adminpanel serves a small admin web UI at /admin, separate from the
+// drawing app, and the overview it shows. The page itself holds nothing
+// secret: it asks for ADMIN_TOKEN and sends it to the admin API, which
+// checks it.
+package adminpanel
+
+import (
+	"context"
+	_ "embed"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/websocket"
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+// maxUsers is how many of the most recently seen users the overview lists.
+const maxUsers = 100
+
+// pagePolicy keeps the page to its own inline script and style and to the
+// API of the server it came from.
+const pagePolicy = "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'; frame-ancestors 'none'"
+
+//go:embed panel.html
+var page []byte
+
+type (
+	// Sources are what the overview reads; optional ones are nil when the
+	// store can't provide them.
+	Sources struct {
+		// Rooms returns the user count of every live room.
+		Rooms func() map[string]int
+		Stats func(roomID string) (websocket.RoomStats, bool)
+		// ProbeStore makes a cheap store call to check it answers.
+		ProbeStore  func(ctx context.Context) error
+		Usage       core.StorageUsageStore
+		Users       core.UserLister
+		StorageType string
+		StartedAt   time.Time
+	}
+
+	// Health is how the server is doing.
+	Health struct {
+		// Status is "ok", or "degraded" when the store didn't answer.
+		Status        string `json:"status"`
+		StartedAt     int64  `json:"started_at"`
+		UptimeSeconds int64  `json:"uptime_seconds"`
+		// StoreLatency is how long the store probe took, in milliseconds.
+		StoreLatency float64 `json:"store_latency_ms"`
+		StoreError   string  `json:"store_error,omitempty"`
+		Goroutines   int     `json:"goroutines"`
+		HeapBytes    uint64  `json:"heap_bytes"`
+	}
+
+	// Storage is what the store holds; Usage is nil when the store can't
+	// tell.
+	Storage struct {
+		Type  string             `json:"type"`
+		Usage *core.StorageUsage `json:"usage,omitempty"`
+	}
+
+	// Overview is everything the admin panel shows at once.
+	Overview struct {
+		Health  Health                `json:"health"`
+		Storage Storage               `json:"storage"`
+		Rooms   []websocket.RoomStats `json:"rooms"`
+		// Users are the most recently seen users, or null when the store
+		// keeps no directory of them.
+		Users []core.User `json:"users"`
+	}
+)
+
+// HandlePage serves the admin panel.
+func HandlePage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", pagePolicy)
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(page)
+	}
+}
+
+// HandleOverview reports the server's health, storage usage, live rooms
+// and known users
+func HandleOverview(sources Sources) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		overview := Overview{
+			Health: Health{
+				Status:        "ok",
+				StartedAt:     sources.StartedAt.UnixMilli(),
+				UptimeSeconds: int64(now.Sub(sources.StartedAt).Seconds()),
+				Goroutines:    runtime.NumGoroutine(),
+			},
+			Storage: Storage{Type: sources.StorageType},
+			Rooms:   []websocket.RoomStats{},
+		}
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		overview.Health.HeapBytes = mem.HeapAlloc
+
+		if sources.ProbeStore != nil {
+			start := time.Now()
+			if err := sources.ProbeStore(r.Context()); err != nil {
+				overview.Health.Status = "degraded"
+				overview.Health.StoreError = err.Error()
+			}
+			overview.Health.StoreLatency = float64(time.Since(start).Microseconds()) / 1000
+		}
+
+		for roomID := range sources.Rooms() {
+			if stats, ok := sources.Stats(roomID); ok {
+				overview.Rooms = append(overview.Rooms, stats)
+			}
+		}
+		sort.Slice(overview.Rooms, func(i, j int) bool {
+			return overview.Rooms[i].RoomID < overview.Rooms[j].RoomID
+		})
+
+		var err error
+		if sources.Usage != nil {
+			if overview.Storage.Usage, err = sources.Usage.StorageUsage(r.Context()); err != nil {
+				logrus.WithError(err).Warn("Failed to measure storage usage")
+				apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to measure storage usage")
+				return
+			}
+		}
+		if sources.Users != nil {
+			if overview.Users, err = sources.Users.ListUsers(r.Context(), maxUsers); err != nil {
+				logrus.WithError(err).Warn("Failed to list users")
+				apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list users")
+				return
+			}
+		}
+
+		render.JSON(w, r, overview)
+	}
+}
@@ -0,0 +1,85 @@
+package adminpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/stores/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlePage(t *testing.T) {
+	w := httptest.NewRecorder()
+	HandlePage()(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Header().Get("Content-Security-Policy"), "connect-src 'self'") {
+		t.Errorf("unexpected policy %q", w.Header().Get("Content-Security-Policy"))
+	}
+	if !strings.Contains(w.Body.String(), `"api/admin/overview"`) {
+		t.Error("the page doesn't load the overview")
+	}
+}
+
+func TestHandleOverview(t *testing.T) {
+	store := memory.NewDocumentStore()
+	users := store.(core.UserStore)
+	for _, user := range []core.User{{ID: "alice", SeenAt: 1}, {ID: "bob", SeenAt: 2}} {
+		if err := users.PutUser(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sources := Sources{
+		Rooms: func() map[string]int { return map[string]int{"b-room": 1, "a-room": 2, "gone": 1} },
+		Stats: func(roomID string) (websocket.RoomStats, bool) {
+			return websocket.RoomStats{RoomID: roomID, Mode: websocket.RoomModePlain}, roomID != "gone"
+		},
+		ProbeStore:  func(context.Context) error { return errors.New("database is locked") },
+		Usage:       store.(core.StorageUsageStore),
+		Users:       store.(core.UserLister),
+		StorageType: "memory",
+		StartedAt:   time.Now().Add(-time.Hour),
+	}
+
+	w := httptest.NewRecorder()
+	HandleOverview(sources)(w, httptest.NewRequest(http.MethodGet, "/api/admin/overview", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var got Overview
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Health.Status != "degraded" || got.Health.StoreError != "database is locked" || got.Health.UptimeSeconds < 3600 {
+		t.Errorf("unexpected health %+v", got.Health)
+	}
+	if got.Storage.Type != "memory" || got.Storage.Usage == nil || got.Storage.Usage.Users != 2 {
+		t.Errorf("unexpected storage %+v", got.Storage)
+	}
+	if len(got.Rooms) != 2 || got.Rooms[0].RoomID != "a-room" || got.Rooms[1].RoomID != "b-room" {
+		t.Errorf("unexpected rooms %+v", got.Rooms)
+	}
+	if len(got.Users) != 2 || got.Users[0].ID != "bob" {
+		t.Errorf("unexpected users %+v", got.Users)
+	}
+
+	// stores without usage or a user directory leave them out
+	sources.Usage, sources.Users, sources.ProbeStore = nil, nil, nil
+	w = httptest.NewRecorder()
+	HandleOverview(sources)(w, httptest.NewRequest(http.MethodGet, "/api/admin/overview", nil))
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["users"]) != "null" || strings.Contains(string(fields["storage"]), `"usage"`) || !strings.Contains(string(fields["health"]), `"status":"ok"`) {
+		t.Errorf("unexpected overview %s", w.Body)
+	}
+}
@@ -0,0 +1,235 @@
+// Package apiversion serves one set of REST handlers as several API
+// versions. /api/v3 answers errors with JSON envelopes, wraps lists in pages
+// and uses 201 for creates; /api/v2 keeps its old responses and announces
+// its deprecation in headers.
+package apiversion
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+const (
+	// Legacy is the version of requests outside a versioned route.
+	Legacy = 2
+	// Latest is the current version.
+	Latest = 3
+
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// V2Deprecated is when /api/v2 was deprecated in favor of /api/v3.
+var V2Deprecated = time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+
+type (
+	// Page is a v3 list response. NextCursor, passed back as ?cursor=,
+	// gets the next page; it is empty on the last one.
+	Page struct {
+		Items      any    `json:"items"`
+		NextCursor string `json:"next_cursor,omitempty"`
+	}
+)
+
+type versionKey struct{}
+
+// Middleware serves requests as API version; from v3 on, plain text errors
+// are answered as an apierror.Envelope.
+func Middleware(version int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(context.WithValue(r.Context(), versionKey{}, version))
+			if version < 3 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ew := &envelopeWriter{ResponseWriter: w}
+			next.ServeHTTP(ew, r)
+			ew.finish()
+		})
+	}
+}
+
+// Version returns the API version of a request.
+func Version(r *http.Request) int {
+	if version, ok := r.Context().Value(versionKey{}).(int); ok {
+		return version
+	}
+	return Legacy
+}
+
+// Deprecate marks responses as deprecated since deprecated, linking to the
+// same path with prefix replaced by successor. A non-zero sunset is when
+// the version goes away.
+func Deprecate(prefix, successor string, deprecated, sunset time.Time) func(http.Handler) http.Handler {
+	// RFC 9745 dates are unix seconds
+	deprecation := "@" + strconv.FormatInt(deprecated.Unix(), 10)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", deprecation)
+			h.Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, strings.Replace(r.URL.Path, prefix, successor, 1)))
+			if !sunset.IsZero() {
+				h.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SunsetFromEnv reads API_V2_SUNSET, a date like 2027-06-30; zero when
+// unset.
+func SunsetFromEnv() (time.Time, error) {
+	value := os.Getenv("API_V2_SUNSET")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	sunset, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid API_V2_SUNSET %q: must be a date like 2027-06-30", value)
+	}
+	return sunset, nil
+}
+
+// CreatedStatus is the status of a successful create. v2 answered some
+// creates with 200, having set the status after writing the body.
+func CreatedStatus(r *http.Request) int {
+	if Version(r) < 3 {
+		return http.StatusOK
+	}
+	return http.StatusCreated
+}
+
+// RenderPage writes one page of a list: a bare JSON array with the next
+// cursor in X-Next-Cursor for v2, a Page for v3.
+func RenderPage(w http.ResponseWriter, r *http.Request, items any, nextCursor string) {
+	if nextCursor != "" {
+		w.Header().Set("X-Next-Cursor", nextCursor)
+	}
+	if Version(r) < 3 {
+		render.JSON(w, r, items)
+		return
+	}
+	render.JSON(w, r, Page{Items: items, NextCursor: nextCursor})
+}
+
+// RenderList writes a whole list for v2. v3 pages it by ?limit (100 by
+// default, at most 1000) and ?cursor.
+func RenderList[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	if items == nil {
+		items = []T{}
+	}
+	if Version(r) < 3 {
+		render.JSON(w, r, items)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultPageSize
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+			return
+		}
+		limit = parsed
+	}
+	start := 0
+	if cursor := query.Get("cursor"); cursor != "" {
+		offset, ok := decodeCursor(cursor)
+		if !ok || offset > len(items) {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidCursor, "Invalid cursor")
+			return
+		}
+		start = offset
+	}
+
+	end := min(start+limit, len(items))
+	next := ""
+	if end < len(items) {
+		next = encodeCursor(end)
+	}
+	RenderPage(w, r, items[start:end], next)
+}
+
+// List cursors are offsets, opaque to clients so they don't build them.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(data) < 2 || data[0] != 'o' {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(string(data[1:]))
+	return offset, err == nil && offset >= 0
+}
+
+// envelopeWriter holds back plain text error bodies, as written by
+// apierror.Write, and writes them as an apierror.Envelope when the handler
+// is done. Errors written without a code get the status's generic one.
+type envelopeWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	// status and message are set for errors being held back
+	status  int
+	message *bytes.Buffer
+}
+
+func (w *envelopeWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		w.status, w.message = status, new(bytes.Buffer)
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *envelopeWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.message != nil {
+		return w.message.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *envelopeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *envelopeWriter) finish() {
+	if w.message == nil {
+		return
+	}
+	h := w.Header()
+	code := apierror.Code(h.Get(apierror.CodeHeader))
+	if code == "" {
+		code = apierror.ForStatus(w.status)
+	}
+	h.Set("Content-Type", "application/json")
+	h.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	_ = json.NewEncoder(w.ResponseWriter).Encode(apierror.Envelope{Error: apierror.Body{
+		Status:  w.status,
+		Code:    code,
+		Message: strings.TrimSpace(w.message.String()),
+	}})
+}
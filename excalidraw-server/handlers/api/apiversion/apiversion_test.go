@@ -0,0 +1,147 @@
+package apiversion
+
+import (
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(version int, handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	Middleware(version)(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	fail := func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, http.StatusNotFound, apierror.CanvasNotFound, "Canvas not found")
+	}
+
+	w := serve(Legacy, fail, "/")
+	if w.Code != http.StatusNotFound || w.Body.String() != "Canvas not found\n" {
+		t.Errorf("v2 = %d %q, want the plain text error", w.Code, w.Body.String())
+	}
+
+	w = serve(Latest, fail, "/")
+	var resp apierror.Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("v3 body %q isn't JSON: %v", w.Body.String(), err)
+	}
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("v3 = %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if resp.Error.Status != http.StatusNotFound || resp.Error.Code != apierror.CanvasNotFound || resp.Error.Message != "Canvas not found" {
+		t.Errorf("v3 error = %+v", resp.Error)
+	}
+
+	// Errors without a code get the status's
+	w = serve(Latest, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}, "/")
+	if !strings.Contains(w.Body.String(), `"code":"not_found"`) {
+		t.Errorf("v3 body = %s, want the generic not_found code", w.Body.String())
+	}
+
+	// Other responses pass through untouched
+	w = serve(Latest, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte("png"))
+	}, "/")
+	if w.Code != http.StatusUnprocessableEntity || w.Body.String() != "png" {
+		t.Errorf("v3 passthrough = %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCreatedStatus(t *testing.T) {
+	create := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(CreatedStatus(r))
+	}
+	if w := serve(Legacy, create, "/"); w.Code != http.StatusOK {
+		t.Errorf("v2 status = %d, want 200", w.Code)
+	}
+	if w := serve(Latest, create, "/"); w.Code != http.StatusCreated {
+		t.Errorf("v3 status = %d, want 201", w.Code)
+	}
+}
+
+func TestRenderList(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	list := func(w http.ResponseWriter, r *http.Request) {
+		RenderList(w, r, items)
+	}
+
+	w := serve(Legacy, list, "/?limit=2")
+	if w.Body.String() != "[1,2,3,4,5]\n" {
+		t.Errorf("v2 = %q, want the whole array", w.Body.String())
+	}
+
+	var got []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("too many pages, got %v", got)
+		}
+		w = serve(Latest, list, "/?limit=2&cursor="+cursor)
+		var page struct {
+			Items      []int  `json:"items"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("v3 body %q: %v", w.Body.String(), err)
+		}
+		got = append(got, page.Items...)
+		if w.Header().Get("X-Next-Cursor") != page.NextCursor {
+			t.Errorf("X-Next-Cursor = %q, want %q", w.Header().Get("X-Next-Cursor"), page.NextCursor)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if len(got) != len(items) {
+		t.Errorf("paged items = %v, want %v", got, items)
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=1001", "?cursor=nope", "?cursor=" + encodeCursor(6)} {
+		if w := serve(Latest, list, "/"+query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestDeprecate(t *testing.T) {
+	deprecated := time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+	handler := Deprecate("/api/v2", "/api/v3", deprecated, sunset)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/excalidraw/api/v2/kv/plan", nil))
+	if got := w.Header().Get("Deprecation"); got != "@1792108800" {
+		t.Errorf("Deprecation = %q", got)
+	}
+	if got := w.Header().Get("Link"); got != `</excalidraw/api/v3/kv/plan>; rel="successor-version"` {
+		t.Errorf("Link = %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Wed, 30 Jun 2027 00:00:00 GMT" {
+		t.Errorf("Sunset = %q", got)
+	}
+}
+
+func TestSunsetFromEnv(t *testing.T) {
+	t.Setenv("API_V2_SUNSET", "")
+	if sunset, err := SunsetFromEnv(); err != nil || !sunset.IsZero() {
+		t.Errorf("unset = %v, %v", sunset, err)
+	}
+	t.Setenv("API_V2_SUNSET", "2027-06-30")
+	if sunset, err := SunsetFromEnv(); err != nil || sunset.Month() != time.June {
+		t.Errorf("2027-06-30 = %v, %v", sunset, err)
+	}
+	t.Setenv("API_V2_SUNSET", "soon")
+	if _, err := SunsetFromEnv(); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
@@ -0,0 +1,15 @@
+package backups
+
+import (
+	"excalidraw-server/backup"
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+// HandleStatus reports when the last backup ran and whether it succeeded
+func HandleStatus(status func() backup.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, status())
+	}
+}
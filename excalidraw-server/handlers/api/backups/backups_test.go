@@ -0,0 +1,36 @@
+package backups
+
+import (
+	"encoding/json"
+	"excalidraw-server/backup"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleStatus(t *testing.T) {
+	handler := HandleStatus(func() backup.Status {
+		return backup.Status{
+			Schedule:    "@daily",
+			LastSuccess: 1000,
+			LastKey:     "excalidraw/excalidraw-19700101T000001Z.db.gz",
+		}
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/backups/status", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["last_success"] != float64(1000) || got["schedule"] != "@daily" {
+		t.Errorf("unexpected status %v", got)
+	}
+	if _, ok := got["last_error"]; ok {
+		t.Errorf("expected no last_error, got %v", got["last_error"])
+	}
+}
@@ -0,0 +1,45 @@
+package bans
+
+import (
+	"excalidraw-server/abuse"
+	"excalidraw-server/apierror"
+	"excalidraw-server/handlers/api/openapi"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// HandleList lists the clients banned for abuse and until when
+func HandleList(tracker *abuse.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, tracker.Bans())
+	}
+}
+
+// HandleLift ends the ban on a client, given by its key such as
+// ip:203.0.113.7, and forgets its earlier ones
+func HandleLift(tracker *abuse.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tracker.Lift(chi.URLParam(r, "key")) {
+			apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Client is not banned")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Operations documents the ban routes relative to /api/abuse.
+var Operations = []openapi.Operation{
+	{
+		Method: http.MethodGet, Path: "/bans", Tag: "rooms", Auth: openapi.AuthAdmin,
+		Summary:  "List the clients banned for abuse",
+		Response: []abuse.Ban{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/bans/{key}", Tag: "rooms", Auth: openapi.AuthAdmin,
+		Summary: "Lift a client's ban",
+		Status:  http.StatusNoContent,
+		Errors:  []int{http.StatusNotFound},
+	},
+}
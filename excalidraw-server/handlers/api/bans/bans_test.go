@@ -0,0 +1,39 @@
+package bans
+
+import (
+	"encoding/json"
+	"excalidraw-server/abuse"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestBans(t *testing.T) {
+	tracker := abuse.NewTracker(abuse.Config{Threshold: 1, Window: time.Minute, BanDuration: time.Minute, MaxBanDuration: time.Hour})
+	tracker.Report("ip:203.0.113.7", abuse.MalformedFrame)
+
+	r := chi.NewRouter()
+	r.Get("/api/abuse/bans", HandleList(tracker))
+	r.Delete("/api/abuse/bans/{key}", HandleLift(tracker))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/abuse/bans", nil))
+	var bans []abuse.Ban
+	if err := json.Unmarshal(w.Body.Bytes(), &bans); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	if len(bans) != 1 || bans[0].Key != "ip:203.0.113.7" || bans[0].Violation != abuse.MalformedFrame {
+		t.Errorf("bans = %+v", bans)
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/abuse/bans/ip:203.0.113.7", nil))
+		if w.Code != want {
+			t.Errorf("DELETE = %d, want %d", w.Code, want)
+		}
+	}
+}
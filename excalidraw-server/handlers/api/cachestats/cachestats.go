@@ -0,0 +1,15 @@
+package cachestats
+
+import (
+	"excalidraw-server/stores/cache"
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+// HandleStats reports the store cache's hit rate and memory use
+func HandleStats(stats func() cache.Stats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, stats())
+	}
+}
@@ -0,0 +1,29 @@
+package cachestats
+
+import (
+	"encoding/json"
+	"excalidraw-server/stores/cache"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleStats(t *testing.T) {
+	handler := HandleStats(func() cache.Stats {
+		return cache.Stats{Hits: 3, Misses: 1, HitRate: 0.75, Entries: 1, Bytes: 200, MaxBytes: 1 << 20}
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/cache/stats", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["hit_rate"] != 0.75 || got["max_bytes"] != float64(1<<20) {
+		t.Errorf("unexpected stats %v", got)
+	}
+}
@@ -0,0 +1,373 @@
+package canvases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/export"
+	"excalidraw-server/handlers/api/apiversion"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/api/openapi"
+	"excalidraw-server/thumbnails"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxCanvasSize limits the size of a saved canvas.
+	MaxCanvasSize = 50 << 20
+
+	maxPageSize = 1000
+
+	maxNameLength = 200
+	maxTags       = 32
+	maxTagLength  = 64
+)
+
+// MetadataRequest is the body of PATCH /api/v2/kv/{key}; fields that are
+// left out stay as they are.
+type MetadataRequest struct {
+	Name *string   `json:"name"`
+	Tags *[]string `json:"tags"`
+}
+
+// validKey keeps keys safe to use as file names and storage keys.
+var validKey = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// ValidKey reports whether key may name a canvas.
+func ValidKey(key string) bool {
+	return validKey.MatchString(key)
+}
+
+// canvasKey returns the key in the URL, or writes 400 if it is invalid.
+func canvasKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key := chi.URLParam(r, "key")
+	if !ValidKey(key) {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidCanvasKey, "Invalid canvas key")
+		return "", false
+	}
+	return key, true
+}
+
+type ownerKey struct{}
+
+// WithOwner returns a copy of ctx whose canvas requests use ownerID's
+// canvases instead of the caller's, e.g. an organization's shared ones.
+func WithOwner(ctx context.Context, ownerID string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, ownerID)
+}
+
+// owner returns the owner set by WithOwner, or the caller's subject.
+func owner(r *http.Request) string {
+	if ownerID, ok := r.Context().Value(ownerKey{}).(string); ok {
+		return ownerID
+	}
+	return auth.ClaimsFromContext(r.Context()).Subject
+}
+
+// HandleList lists the caller's canvases, newest first by default.
+// ?sort=updatedAt|name and ?order=asc|desc choose the order; with ?limit=
+// (at most 1000) the X-Next-Cursor header, passed back as ?cursor=, gets
+// the next page.
+func HandleList(store core.CanvasStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		opts := core.CanvasListOptions{Cursor: query.Get("cursor")}
+
+		switch sort := core.CanvasSort(query.Get("sort")); sort {
+		case "", core.CanvasSortUpdated, core.CanvasSortName:
+			opts.Sort = sort
+		default:
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "sort must be updatedAt or name")
+			return
+		}
+		switch order := query.Get("order"); order {
+		case "":
+		case "asc", "desc":
+			ascending := order == "asc"
+			opts.Ascending = &ascending
+		default:
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "order must be asc or desc")
+			return
+		}
+		if value := query.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxPageSize {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "limit must be between 1 and 1000")
+				return
+			}
+			opts.Limit = limit
+		}
+
+		page, err := store.ListCanvasPage(r.Context(), owner(r), opts)
+		if err != nil {
+			if errors.Is(err, core.ErrInvalidCursor) {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidCursor, "Invalid cursor")
+				return
+			}
+			logrus.WithField("error", err).Error("Failed to list canvases")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list canvases")
+			return
+		}
+		canvases := page.Canvases
+		if canvases == nil {
+			canvases = []core.Canvas{}
+		}
+		apiversion.RenderPage(w, r, canvases, page.NextCursor)
+	}
+}
+
+// HandleGet serves one of the caller's canvases as it was saved and counts
+// the view
+func HandleGet(store core.CanvasStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := canvasKey(w, r)
+		if !ok {
+			return
+		}
+		canvas, err := store.GetCanvas(r.Context(), owner(r), key)
+		if err != nil {
+			if !errors.Is(err, core.ErrCanvasNotFound) {
+				logrus.WithField("error", err).Error("Failed to get canvas")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.CanvasNotFound, "Canvas not found")
+			return
+		}
+		if err := store.RecordCanvasView(r.Context(), owner(r), key); err != nil {
+			logrus.WithField("canvas_key", key).WithField("error", err).Warn("Failed to record canvas view")
+		}
+
+		contentType := "application/octet-stream"
+		if json.Valid(canvas.Data) {
+			contentType = "application/json"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "private, no-store")
+		_, _ = w.Write(canvas.Data)
+	}
+}
+
+// HandleThumbnail serves a small PNG preview of one of the caller's
+// canvases, rendered on first use and cached until the canvas changes
+func HandleThumbnail(store core.CanvasStore, service *thumbnails.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := canvasKey(w, r)
+		if !ok {
+			return
+		}
+		canvas, err := store.GetCanvas(r.Context(), owner(r), key)
+		if err != nil {
+			if !errors.Is(err, core.ErrCanvasNotFound) {
+				logrus.WithField("error", err).Error("Failed to get canvas")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.CanvasNotFound, "Canvas not found")
+			return
+		}
+
+		png, hash, err := service.Get(r.Context(), canvas.Data)
+		if err != nil {
+			if errors.Is(err, export.ErrNotScene) {
+				apierror.Write(w, http.StatusUnprocessableEntity, apierror.NotAScene, "Canvas is not a plain Excalidraw scene; encrypted canvases have no thumbnail")
+				return
+			}
+			logrus.WithField("canvas_key", key).WithField("error", err).Error("Failed to render canvas thumbnail")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to render thumbnail")
+			return
+		}
+
+		// Canvases change, so clients revalidate with the ETag
+		w.Header().Set("Cache-Control", "private, no-cache")
+		thumbnails.Serve(w, r, png, hash)
+	}
+}
+
+// HandlePut saves the request body as the caller's canvas, replacing any
+// canvas already stored under the key
+func HandlePut(store core.CanvasStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := canvasKey(w, r)
+		if !ok {
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxCanvasSize))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.TooLarge, "Canvas too large")
+				return
+			}
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Failed to read body")
+			return
+		}
+		if len(data) == 0 {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Empty canvas")
+			return
+		}
+
+		err = store.PutCanvas(r.Context(), &core.Canvas{
+			Key:     key,
+			OwnerID: owner(r),
+			Data:    data,
+		})
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to save canvas")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save canvas")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandlePatch renames or re-tags one of the caller's canvases without
+// uploading it again, and responds with its listing entry
+func HandlePatch(store core.CanvasStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := canvasKey(w, r)
+		if !ok {
+			return
+		}
+
+		var req MetadataRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+			return
+		}
+		update, err := ValidateMetadata(req)
+		if err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, err.Error())
+			return
+		}
+
+		canvas, err := store.UpdateCanvasMetadata(r.Context(), owner(r), key, update)
+		if err != nil {
+			if !errors.Is(err, core.ErrCanvasNotFound) {
+				logrus.WithField("error", err).Error("Failed to update canvas metadata")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.CanvasNotFound, "Canvas not found")
+			return
+		}
+		render.JSON(w, r, canvas)
+	}
+}
+
+// ValidateMetadata trims the name and tags of req and drops duplicate tags.
+func ValidateMetadata(req MetadataRequest) (core.CanvasMetadataUpdate, error) {
+	if req.Name == nil && req.Tags == nil {
+		return core.CanvasMetadataUpdate{}, errors.New("nothing to update: set name or tags")
+	}
+
+	var update core.CanvasMetadataUpdate
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if utf8.RuneCountInString(name) > maxNameLength || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			return update, fmt.Errorf("name must be at most %d characters without control characters", maxNameLength)
+		}
+		update.Name = &name
+	}
+	if req.Tags != nil {
+		tags := []string{}
+		seen := make(map[string]bool)
+		for _, tag := range *req.Tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || strings.IndexFunc(tag, unicode.IsControl) >= 0 {
+				return update, fmt.Errorf("tags must be 1 to %d characters without control characters", maxTagLength)
+			}
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) > maxTags {
+			return update, fmt.Errorf("at most %d tags are allowed", maxTags)
+		}
+		update.Tags = &tags
+	}
+	return update, nil
+}
+
+// HandleDelete deletes one of the caller's canvases
+func HandleDelete(store core.CanvasStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := canvasKey(w, r)
+		if !ok {
+			return
+		}
+		if err := store.DeleteCanvas(r.Context(), owner(r), key); err != nil {
+			if !errors.Is(err, core.ErrCanvasNotFound) {
+				logrus.WithField("error", err).Error("Failed to delete canvas")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.CanvasNotFound, "Canvas not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Operations documents the canvas routes, relative to where they are
+// mounted, except the thumbnail which needs a thumbnail store.
+var Operations = []openapi.Operation{
+	{
+		Method: http.MethodGet, Path: "/", Tag: "canvases", Auth: openapi.AuthUser,
+		Summary: "List canvases; with a limit, the X-Next-Cursor header is the cursor of the next page",
+		Query: []openapi.Param{
+			{Name: "sort", Description: "updatedAt (the default) or name"},
+			{Name: "order", Description: "asc or desc"},
+			{Name: "limit", Type: "integer", Description: "Page size, at most 1000"},
+			{Name: "cursor", Description: "X-Next-Cursor of the previous page"},
+		},
+		Response: []core.Canvas{},
+		Errors:   []int{http.StatusBadRequest},
+	},
+	{
+		Method: http.MethodGet, Path: "/{key}", Tag: "canvases", Auth: openapi.AuthUser,
+		Summary:      "Get a canvas as it was saved",
+		ResponseType: "application/json, application/octet-stream",
+		Errors:       []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPut, Path: "/{key}", Tag: "canvases", Auth: openapi.AuthUser,
+		Summary:     "Save a canvas, replacing any under the key; the body is stored as sent",
+		RequestType: "application/json, application/octet-stream",
+		Status:      http.StatusNoContent,
+		Errors:      []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge},
+	},
+	{
+		Method: http.MethodPatch, Path: "/{key}", Tag: "canvases", Auth: openapi.AuthUser,
+		Summary:  "Rename or re-tag a canvas",
+		Request:  MetadataRequest{},
+		Response: core.Canvas{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		Method: http.MethodDelete, Path: "/{key}", Tag: "canvases", Auth: openapi.AuthUser,
+		Summary: "Delete a canvas",
+		Status:  http.StatusNoContent,
+		Errors:  []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+}
+
+// ThumbnailOperation documents HandleThumbnail, relative to where the
+// canvas routes are mounted.
+var ThumbnailOperation = openapi.Operation{
+	Method: http.MethodGet, Path: "/{key}/thumbnail", Tag: "canvases", Auth: openapi.AuthUser,
+	Summary:      "Render a small preview of a canvas",
+	ResponseType: "image/png",
+	Errors:       []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity},
+}
@@ -0,0 +1,273 @@
+package canvases
+
+import (
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"excalidraw-server/thumbnails"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type fixture struct {
+	router *chi.Mux
+	tokens map[string]string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	verifier := auth.NewVerifier([]byte("secret"))
+	documentStore := memory.NewDocumentStore()
+	store := documentStore.(core.CanvasStore)
+	service := thumbnails.NewService(documentStore.(core.ThumbnailStore))
+
+	r := chi.NewRouter()
+	r.Route("/kv", func(r chi.Router) {
+		r.Use(auth.Middleware(verifier, true))
+		r.Get("/", HandleList(store))
+		r.Get("/{key}", HandleGet(store))
+		r.Get("/{key}/thumbnail", HandleThumbnail(store, service))
+		r.Put("/{key}", HandlePut(store))
+		r.Patch("/{key}", HandlePatch(store))
+		r.Delete("/{key}", HandleDelete(store))
+	})
+
+	f := &fixture{router: r, tokens: make(map[string]string)}
+	for _, user := range []string{"alice", "bob"} {
+		token, err := verifier.Sign(&auth.Claims{Subject: user})
+		if err != nil {
+			t.Fatalf("Sign() failed: %v", err)
+		}
+		f.tokens[user] = token
+	}
+	return f
+}
+
+func (f *fixture) do(method, path, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+f.tokens[user])
+	}
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPutAndGet(t *testing.T) {
+	f := newFixture(t)
+
+	if rec := f.do(http.MethodPut, "/kv/drawing-1", "alice", `{"elements":[]}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("put status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := f.do(http.MethodGet, "/kv/drawing-1", "alice", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get status = %d", rec.Code)
+	}
+	if rec.Body.String() != `{"elements":[]}` {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	// Replacing keeps one canvas under the key
+	f.do(http.MethodPut, "/kv/drawing-1", "alice", "encrypted\x00bytes")
+	rec = f.do(http.MethodGet, "/kv/drawing-1", "alice", "")
+	if rec.Body.String() != "encrypted\x00bytes" {
+		t.Errorf("unexpected body after replace %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/octet-stream" {
+		t.Errorf("Content-Type = %q, want application/octet-stream", got)
+	}
+}
+
+func TestCanvasesArePrivate(t *testing.T) {
+	f := newFixture(t)
+	f.do(http.MethodPut, "/kv/drawing-1", "alice", `{}`)
+
+	if rec := f.do(http.MethodGet, "/kv/drawing-1", "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("other user get status = %d, want 404", rec.Code)
+	}
+	if rec := f.do(http.MethodDelete, "/kv/drawing-1", "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("other user delete status = %d, want 404", rec.Code)
+	}
+	if rec := f.do(http.MethodGet, "/kv/drawing-1", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous get status = %d, want 401", rec.Code)
+	}
+
+	rec := f.do(http.MethodGet, "/kv/", "bob", "")
+	if strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("expected bob's list to be empty, got %s", rec.Body.String())
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	f := newFixture(t)
+	f.do(http.MethodPut, "/kv/a", "alice", `{"a":1}`)
+	f.do(http.MethodPut, "/kv/b", "alice", `{}`)
+
+	rec := f.do(http.MethodGet, "/kv/", "alice", "")
+	var canvases []map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&canvases); err != nil {
+		t.Fatal(err)
+	}
+	if len(canvases) != 2 {
+		t.Fatalf("expected 2 canvases, got %v", canvases)
+	}
+	for _, canvas := range canvases {
+		if _, ok := canvas["data"]; ok {
+			t.Errorf("list should not include data: %v", canvas)
+		}
+		if canvas["key"] == "a" && canvas["size"] != float64(7) {
+			t.Errorf("expected size 7, got %v", canvas["size"])
+		}
+	}
+
+	if rec := f.do(http.MethodDelete, "/kv/a", "alice", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete status = %d", rec.Code)
+	}
+	if rec := f.do(http.MethodGet, "/kv/a", "alice", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", rec.Code)
+	}
+}
+
+func TestListPages(t *testing.T) {
+	f := newFixture(t)
+	for _, key := range []string{"c", "a", "b"} {
+		f.do(http.MethodPut, "/kv/"+key, "alice", `{}`)
+	}
+
+	var keys []string
+	path := "/kv/?sort=name&limit=2"
+	for path != "" {
+		rec := f.do(http.MethodGet, path, "alice", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list status = %d, want 200", rec.Code)
+		}
+		var canvases []map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&canvases); err != nil {
+			t.Fatal(err)
+		}
+		for _, canvas := range canvases {
+			keys = append(keys, canvas["key"].(string))
+		}
+		path = ""
+		if cursor := rec.Header().Get("X-Next-Cursor"); cursor != "" {
+			path = "/kv/?sort=name&limit=2&cursor=" + cursor
+		}
+	}
+	if strings.Join(keys, ",") != "a,b,c" {
+		t.Errorf("paged keys = %v, want a,b,c", keys)
+	}
+
+	for _, query := range []string{"sort=size", "order=up", "limit=0", "limit=1001", "cursor=bogus!"} {
+		if rec := f.do(http.MethodGet, "/kv/?"+query, "alice", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("list with %s status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+	f := newFixture(t)
+	f.do(http.MethodPut, "/kv/a", "alice", `{"a":1}`)
+
+	rec := f.do(http.MethodPatch, "/kv/a", "alice", `{"name":"  Roadmap ","tags":["q3","planning","q3"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var canvas map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&canvas); err != nil {
+		t.Fatal(err)
+	}
+	if canvas["name"] != "Roadmap" || fmt.Sprint(canvas["tags"]) != "[q3 planning]" || canvas["size"] != float64(7) {
+		t.Errorf("patched canvas = %v", canvas)
+	}
+
+	// Tags stay when only the name changes, and the data is untouched
+	f.do(http.MethodPatch, "/kv/a", "alice", `{"name":""}`)
+	rec = f.do(http.MethodGet, "/kv/", "alice", "")
+	var canvases []map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&canvases); err != nil {
+		t.Fatal(err)
+	}
+	if len(canvases) != 1 || canvases[0]["name"] != nil || fmt.Sprint(canvases[0]["tags"]) != "[q3 planning]" {
+		t.Errorf("listed canvases = %v", canvases)
+	}
+	if rec := f.do(http.MethodGet, "/kv/a", "alice", ""); rec.Body.String() != `{"a":1}` {
+		t.Errorf("data after patch = %s", rec.Body)
+	}
+
+	for _, body := range []string{`{}`, `not json`, `{"name":"a\u0000b"}`, `{"tags":[""]}`, `{"name":"` + strings.Repeat("x", 201) + `"}`} {
+		if rec := f.do(http.MethodPatch, "/kv/a", "alice", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("patch with %.20s status = %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := f.do(http.MethodPatch, "/kv/a", "bob", `{"name":"mine"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("patch of another user's canvas status = %d, want 404", rec.Code)
+	}
+}
+
+func TestPutValidates(t *testing.T) {
+	f := newFixture(t)
+
+	if rec := f.do(http.MethodPut, "/kv/bad.key", "alice", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid key status = %d, want 400", rec.Code)
+	}
+	if rec := f.do(http.MethodPut, "/kv/empty", "alice", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty body status = %d, want 400", rec.Code)
+	}
+	big := strings.Repeat("x", MaxCanvasSize+1)
+	if rec := f.do(http.MethodPut, "/kv/big", "alice", big); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body status = %d, want 413", rec.Code)
+	}
+}
+
+func TestListReportsViews(t *testing.T) {
+	f := newFixture(t)
+	f.do(http.MethodPut, "/kv/a", "alice", `{}`)
+	f.do(http.MethodGet, "/kv/a", "alice", "")
+	f.do(http.MethodGet, "/kv/a", "alice", "")
+
+	rec := f.do(http.MethodGet, "/kv/", "alice", "")
+	var canvases []map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&canvases); err != nil {
+		t.Fatal(err)
+	}
+	if len(canvases) != 1 || canvases[0]["views"] != float64(2) || canvases[0]["last_accessed"] == nil {
+		t.Errorf("expected 2 views with a last access time, got %v", canvases)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	f := newFixture(t)
+
+	scene := `{"elements":[{"id":"a","type":"ellipse","x":0,"y":0,"width":80,"height":80}]}`
+	if rec := f.do(http.MethodPut, "/kv/drawing", "alice", scene); rec.Code != http.StatusNoContent {
+		t.Fatalf("put status = %d", rec.Code)
+	}
+	rec := f.do(http.MethodGet, "/kv/drawing/thumbnail", "alice", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("thumbnail = %d %s, want a PNG", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if etag := rec.Header().Get("ETag"); etag != `"`+thumbnails.Hash([]byte(scene))+`"` {
+		t.Errorf("ETag = %s, want the scene's hash", etag)
+	}
+
+	if rec := f.do(http.MethodGet, "/kv/drawing/thumbnail", "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("another user's thumbnail status = %d, want 404", rec.Code)
+	}
+
+	if rec := f.do(http.MethodPut, "/kv/secret", "alice", "\x00encrypted"); rec.Code != http.StatusNoContent {
+		t.Fatalf("put status = %d", rec.Code)
+	}
+	if rec := f.do(http.MethodGet, "/kv/secret/thumbnail", "alice", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("encrypted canvas thumbnail status = %d, want 422", rec.Code)
+	}
+}
@@ -0,0 +1,24 @@
+package cleanup
+
+import (
+	"context"
+	"excalidraw-server/apierror"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/retention"
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+// HandleReport lists the inactive rooms the cleanup policies would delete
+// data of if they ran now, without deleting anything
+func HandleReport(report func(ctx context.Context) (retention.Report, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, err := report(r.Context())
+		if err != nil {
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to build cleanup report")
+			return
+		}
+		render.JSON(w, r, got)
+	}
+}
@@ -0,0 +1,59 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/core"
+	"excalidraw-server/retention"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleReport(t *testing.T) {
+	handler := HandleReport(func(context.Context) (retention.Report, error) {
+		return retention.Report{
+			GeneratedAt: 1000,
+			Policies:    map[retention.Kind]int{retention.Snapshots: 90},
+			Rooms: []retention.RoomPlan{{
+				RoomActivity: core.RoomActivity{RoomID: "old-room", LastActive: 10, Snapshots: 3},
+				Delete:       []retention.Kind{retention.Snapshots},
+			}},
+		}, nil
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/retention/report", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var got struct {
+		Policies map[string]int `json:"policies"`
+		Rooms    []struct {
+			RoomID    string   `json:"room_id"`
+			Snapshots int      `json:"snapshots"`
+			Delete    []string `json:"delete"`
+		} `json:"rooms"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Policies["snapshots"] != 90 || len(got.Rooms) != 1 || got.Rooms[0].RoomID != "old-room" || got.Rooms[0].Delete[0] != "snapshots" {
+		t.Errorf("unexpected report %+v", got)
+	}
+}
+
+func TestHandleReportError(t *testing.T) {
+	handler := HandleReport(func(context.Context) (retention.Report, error) {
+		return retention.Report{}, errors.New("database is locked")
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/retention/report", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+}
@@ -0,0 +1,53 @@
+// Package compression negotiates brotli or gzip encoding for API responses.
+package compression
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// DefaultLevel is used when COMPRESSION_LEVEL is unset. It is a good trade
+// between ratio and CPU for both gzip (1-9) and brotli (0-11).
+const DefaultLevel = 5
+
+// contentTypes lists the responses worth compressing. Images, client-encrypted
+// scenes and the playback stream are left alone.
+var contentTypes = []string{
+	"application/json",
+	"text/plain",
+	"text/html",
+	"text/css",
+	"text/javascript",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+// LevelFromEnv reads COMPRESSION_LEVEL (1-9); 0 disables compression.
+func LevelFromEnv() (int, error) {
+	value := os.Getenv("COMPRESSION_LEVEL")
+	if value == "" {
+		return DefaultLevel, nil
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 || level > 9 {
+		return 0, fmt.Errorf("invalid COMPRESSION_LEVEL %q: must be 0-9", value)
+	}
+	return level, nil
+}
+
+// Middleware compresses responses with brotli when the client accepts it,
+// falling back to gzip and deflate. Responses carry Vary: Accept-Encoding.
+func Middleware(level int) func(http.Handler) http.Handler {
+	compressor := middleware.NewCompressor(level, contentTypes...)
+	// Encoders set later take precedence over the built-in gzip and deflate
+	compressor.SetEncoder("br", func(w io.Writer, level int) io.Writer {
+		return brotli.NewWriterLevel(w, level)
+	})
+	return compressor.Handler
+}
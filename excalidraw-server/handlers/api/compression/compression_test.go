@@ -0,0 +1,100 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+var body = strings.Repeat(`{"type":"rectangle","x":10,"y":20},`, 200)
+
+func serve(contentType, acceptEncoding string) *httptest.ResponseRecorder {
+	handler := Middleware(DefaultLevel)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_, _ = io.WriteString(w, body)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/rooms", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewarePrefersBrotli(t *testing.T) {
+	rec := serve("application/json", "gzip, deflate, br")
+
+	if got := rec.Header().Get("Content-Encoding"); got != "br" {
+		t.Fatalf("Content-Encoding = %q, want br", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", got)
+	}
+	data, err := io.ReadAll(brotli.NewReader(rec.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != body {
+		t.Error("decompressed body does not match")
+	}
+}
+
+func TestMiddlewareGzip(t *testing.T) {
+	rec := serve("application/json", "gzip")
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	reader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != body {
+		t.Error("decompressed body does not match")
+	}
+}
+
+func TestMiddlewareSkips(t *testing.T) {
+	for _, tt := range []struct {
+		name, contentType, acceptEncoding string
+	}{
+		{"no Accept-Encoding", "application/json", ""},
+		{"binary content", "application/octet-stream", "br, gzip"},
+		{"images", "image/png", "br, gzip"},
+	} {
+		rec := serve(tt.contentType, tt.acceptEncoding)
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%s: Content-Encoding = %q, want none", tt.name, got)
+		}
+		if rec.Body.String() != body {
+			t.Errorf("%s: body was modified", tt.name)
+		}
+	}
+}
+
+func TestLevelFromEnv(t *testing.T) {
+	t.Setenv("COMPRESSION_LEVEL", "")
+	if level, err := LevelFromEnv(); err != nil || level != DefaultLevel {
+		t.Errorf("LevelFromEnv() = %d, %v, want default", level, err)
+	}
+	t.Setenv("COMPRESSION_LEVEL", "0")
+	if level, err := LevelFromEnv(); err != nil || level != 0 {
+		t.Errorf("LevelFromEnv() = %d, %v, want 0", level, err)
+	}
+	for _, value := range []string{"10", "-1", "fast"} {
+		t.Setenv("COMPRESSION_LEVEL", value)
+		if _, err := LevelFromEnv(); err == nil {
+			t.Errorf("LevelFromEnv(%q) should fail", value)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Package deadline bounds how long API requests may wait on a store and maps
+// the resulting context errors to HTTP status codes.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultTimeout is used when REQUEST_TIMEOUT is unset.
+const DefaultTimeout = 30 * time.Second
+
+// TimeoutFromEnv reads REQUEST_TIMEOUT; zero disables the timeout.
+func TimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv("REQUEST_TIMEOUT")
+	if value == "" {
+		return DefaultTimeout, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// Middleware cancels the request context after timeout so store calls give
+// up instead of piling up behind a slow backend.
+func Middleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Status returns 504 for store calls that ran out of time, 503 for ones that
+// were cancelled, and fallback for any other error.
+func Status(err error, fallback int) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,57 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var deadlineSet bool
+	handler := Middleware(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, deadlineSet = r.Context().Deadline()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !deadlineSet {
+		t.Error("expected the request context to carry a deadline")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{context.Canceled, http.StatusServiceUnavailable},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := Status(tt.err, http.StatusInternalServerError); got != tt.want {
+			t.Errorf("Status(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestTimeoutFromEnv(t *testing.T) {
+	t.Setenv("REQUEST_TIMEOUT", "")
+	if got, err := TimeoutFromEnv(); err != nil || got != DefaultTimeout {
+		t.Errorf("TimeoutFromEnv() = %v, %v, want default", got, err)
+	}
+
+	t.Setenv("REQUEST_TIMEOUT", "5s")
+	if got, err := TimeoutFromEnv(); err != nil || got != 5*time.Second {
+		t.Errorf("TimeoutFromEnv() = %v, %v, want 5s", got, err)
+	}
+
+	t.Setenv("REQUEST_TIMEOUT", "soon")
+	if _, err := TimeoutFromEnv(); err == nil {
+		t.Error("expected an error for an invalid duration")
+	}
+}
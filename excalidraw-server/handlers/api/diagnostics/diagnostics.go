@@ -0,0 +1,159 @@
+package diagnostics
+
+import (
+	"bytes"
+	"excalidraw-server/apierror"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// defaultGoroutineGroups is how many stacks a summary lists unless asked
+// for more
+const defaultGoroutineGroups = 20
+
+type (
+	// GoroutineGroup counts the goroutines parked in the same function and
+	// started from the same place.
+	GoroutineGroup struct {
+		Count int `json:"count"`
+		// Function is the innermost frame outside the runtime.
+		Function  string `json:"function"`
+		CreatedBy string `json:"created_by,omitempty"`
+		// States counts the group's goroutines by wait reason, e.g.
+		// "chan receive" or "select".
+		States map[string]int `json:"states"`
+		// Stack is an example of the group's call stacks, innermost first.
+		Stack []string `json:"stack"`
+	}
+
+	// GoroutineSummary groups the running goroutines by where they are, so
+	// a leak shows up as one group that keeps growing.
+	GoroutineSummary struct {
+		Total  int              `json:"total"`
+		States map[string]int   `json:"states"`
+		Groups []GoroutineGroup `json:"groups"`
+	}
+)
+
+// HandleGoroutines summarizes the running goroutines, largest groups first.
+// ?limit= caps how many groups are listed; 0 lists all of them.
+func HandleGoroutines() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultGoroutineGroups
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid limit")
+				return
+			}
+			limit = parsed
+		}
+
+		summary := Summarize(allStacks())
+		if limit > 0 && len(summary.Groups) > limit {
+			summary.Groups = summary.Groups[:limit]
+		}
+		render.JSON(w, r, summary)
+	}
+}
+
+// allStacks dumps the stacks of every goroutine, in the format of a
+// goroutine profile with debug=2.
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// Summarize groups a dump of goroutine stacks by function and creator.
+func Summarize(dump []byte) GoroutineSummary {
+	summary := GoroutineSummary{States: make(map[string]int), Groups: []GoroutineGroup{}}
+	groups := make(map[string]int)
+
+	for _, block := range bytes.Split(bytes.TrimSpace(dump), []byte("\n\n")) {
+		lines := strings.Split(string(block), "\n")
+		state, ok := goroutineState(lines[0])
+		if !ok {
+			continue
+		}
+
+		var stack []string
+		var createdBy string
+		for _, line := range lines[1:] {
+			if strings.HasPrefix(line, "\t") {
+				continue
+			}
+			if after, found := strings.CutPrefix(line, "created by "); found {
+				createdBy, _, _ = strings.Cut(after, " in goroutine ")
+				continue
+			}
+			stack = append(stack, functionName(line))
+		}
+		function := ""
+		for _, frame := range stack {
+			if !strings.HasPrefix(frame, "runtime.") {
+				function = frame
+				break
+			}
+		}
+		if function == "" && len(stack) > 0 {
+			function = stack[0]
+		}
+
+		summary.Total++
+		summary.States[state]++
+		key := function + "\x00" + createdBy
+		i, seen := groups[key]
+		if !seen {
+			i = len(summary.Groups)
+			groups[key] = i
+			summary.Groups = append(summary.Groups, GoroutineGroup{
+				Function:  function,
+				CreatedBy: createdBy,
+				States:    make(map[string]int),
+				Stack:     stack,
+			})
+		}
+		summary.Groups[i].Count++
+		summary.Groups[i].States[state]++
+	}
+
+	sort.SliceStable(summary.Groups, func(i, j int) bool {
+		return summary.Groups[i].Count > summary.Groups[j].Count
+	})
+	return summary
+}
+
+// goroutineState reads the wait reason from a header like
+// "goroutine 7 [chan receive, 3 minutes]:".
+func goroutineState(header string) (string, bool) {
+	if !strings.HasPrefix(header, "goroutine ") {
+		return "", false
+	}
+	start := strings.Index(header, "[")
+	end := strings.LastIndex(header, "]")
+	if start < 0 || end < start {
+		return "", false
+	}
+	state, _, _ := strings.Cut(header[start+1:end], ",")
+	return state, true
+}
+
+// functionName strips the arguments from a frame like
+// "main.serve(0xc000010000, 0x1)".
+func functionName(frame string) string {
+	if i := strings.LastIndex(frame, "("); i > 0 && strings.HasSuffix(frame, ")") {
+		return frame[:i]
+	}
+	return frame
+}
@@ -0,0 +1,72 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const dump = `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x1d
+
+goroutine 7 [chan receive, 3 minutes]:
+runtime.gopark(0x0?, 0x0?, 0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/proc.go:424 +0xce
+runtime.chanrecv1(0xc000010000?, 0x0?)
+	/usr/local/go/src/runtime/chan.go:489 +0x12
+excalidraw-server/handlers/websocket.(*Admin).Start(0xc000020000, 0xc000030000)
+	/app/handlers/websocket/admin.go:150 +0x9a
+created by main.main in goroutine 1
+	/app/main.go:12 +0x2b
+
+goroutine 8 [select]:
+excalidraw-server/handlers/websocket.(*Admin).Start(0xc000020000, 0xc000030000)
+	/app/handlers/websocket/admin.go:152 +0x9a
+created by main.main in goroutine 1
+	/app/main.go:12 +0x2b
+`
+
+func TestSummarize(t *testing.T) {
+	summary := Summarize([]byte(dump))
+
+	if summary.Total != 3 || summary.States["running"] != 1 || summary.States["chan receive"] != 1 {
+		t.Fatalf("summary = %+v, want 3 goroutines by state", summary)
+	}
+	if len(summary.Groups) != 2 {
+		t.Fatalf("groups = %+v, want 2", summary.Groups)
+	}
+	group := summary.Groups[0]
+	if group.Count != 2 || group.Function != "excalidraw-server/handlers/websocket.(*Admin).Start" || group.CreatedBy != "main.main" {
+		t.Errorf("largest group = %+v, want the two admin loops", group)
+	}
+	if group.States["chan receive"] != 1 || group.States["select"] != 1 {
+		t.Errorf("group states = %v", group.States)
+	}
+	if len(group.Stack) != 3 || group.Stack[0] != "runtime.gopark" {
+		t.Errorf("group stack = %v, want the first goroutine's frames", group.Stack)
+	}
+}
+
+func TestHandleGoroutines(t *testing.T) {
+	w := httptest.NewRecorder()
+	HandleGoroutines()(w, httptest.NewRequest(http.MethodGet, "/debug/goroutines?limit=1", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var got GoroutineSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Total == 0 || len(got.Groups) != 1 {
+		t.Errorf("summary = %+v, want goroutines and one group", got)
+	}
+
+	w = httptest.NewRecorder()
+	HandleGoroutines()(w, httptest.NewRequest(http.MethodGet, "/debug/goroutines?limit=-1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a negative limit, got %d", w.Code)
+	}
+}
@@ -0,0 +1,201 @@
+package diagrams
+
+import (
+	"bytes"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/convert"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// MaxSourceSize caps the text of a graph to convert.
+const MaxSourceSize = 1 << 20
+
+// HandleDOT lays out a Graphviz DOT graph and returns it as an Excalidraw
+// scene, for generating architecture diagrams from code and editing them
+// by hand afterwards. Nothing is stored.
+func HandleDOT() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, ok := readSource(w, r)
+		if !ok {
+			return
+		}
+		scene, err := convert.FromDOT(data)
+		if err != nil {
+			apierror.Write(w, http.StatusUnprocessableEntity, apierror.InvalidRequest, err.Error())
+			return
+		}
+		render.JSON(w, r, scene)
+	}
+}
+
+// HandleData lays out structured data as a flowchart or tree and returns
+// it as an Excalidraw scene, for generating diagrams in CI pipelines. The
+// body is JSON (a graph of nodes and edges, or a nested tree) or CSV (an
+// adjacency list, or id and parent columns), picked by the format query
+// parameter or the Content-Type. Other query parameters style the diagram;
+// see diagramStyle.
+func HandleData() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format := query.Get("format")
+		if format == "" {
+			format = "json"
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+				format = "csv"
+			}
+		}
+		parse := convert.DiagramFromJSON
+		switch format {
+		case "json":
+		case "csv":
+			parse = convert.DiagramFromCSV
+		default:
+			apierror.Write(w, http.StatusBadRequest, apierror.UnsupportedFormat, "Format must be json or csv")
+			return
+		}
+		diagram, err := diagramStyle(query)
+		if err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, err.Error())
+			return
+		}
+
+		data, ok := readSource(w, r)
+		if !ok {
+			return
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Empty body")
+			return
+		}
+		parsed, err := parse(data)
+		if err != nil {
+			apierror.Write(w, http.StatusUnprocessableEntity, apierror.InvalidRequest, err.Error())
+			return
+		}
+		parsed.SetDefaults(diagram.Nodes[0], diagram.Edges[0])
+		parsed.Direction = diagram.Direction
+		parsed.Roughness = diagram.Roughness
+		parsed.FontFamily = diagram.FontFamily
+		parsed.FontSize = diagram.FontSize
+		parsed.NodeSpacing = diagram.NodeSpacing
+		parsed.RankSpacing = diagram.RankSpacing
+
+		scene, err := parsed.Scene()
+		if err != nil {
+			apierror.Write(w, http.StatusUnprocessableEntity, apierror.InvalidRequest, err.Error())
+			return
+		}
+		render.JSON(w, r, scene)
+	}
+}
+
+var cssColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// diagramStyle reads the styling query parameters into a diagram with one
+// node and one edge carrying the defaults for every node and edge:
+//
+//   - direction: TB (default), BT, LR or RL
+//   - shape: rectangle (default), ellipse, diamond or text; rounded=true
+//     rounds rectangles
+//   - stroke_color, background_color, font_color and edge_color: hex or
+//     named colors
+//   - edge_style: solid, dashed or dotted
+//   - roughness: 0 (default) to 2, from clean to sketchy
+//   - font: hand (default), sans or mono; font_size from 8 to 96
+//   - node_spacing and rank_spacing: gaps in pixels, from 1 to 1000
+func diagramStyle(query url.Values) (convert.Diagram, error) {
+	d := convert.Diagram{Direction: "TB", Nodes: make([]convert.DiagramNode, 1), Edges: make([]convert.DiagramEdge, 1)}
+	node, edge := &d.Nodes[0], &d.Edges[0]
+
+	if v := query.Get("direction"); v != "" {
+		d.Direction = strings.ToUpper(v)
+		switch d.Direction {
+		case "TB", "BT", "LR", "RL":
+		default:
+			return d, errors.New("direction must be TB, BT, LR or RL")
+		}
+	}
+	switch node.Shape = query.Get("shape"); node.Shape {
+	case "", "rectangle", "ellipse", "diamond", "text":
+	default:
+		return d, errors.New("shape must be rectangle, ellipse, diamond or text")
+	}
+	node.Rounded = query.Get("rounded") == "true"
+	for name, color := range map[string]*string{
+		"stroke_color":     &node.StrokeColor,
+		"background_color": &node.BackgroundColor,
+		"font_color":       &node.FontColor,
+		"edge_color":       &edge.StrokeColor,
+	} {
+		if v := query.Get(name); v != "" {
+			if !cssColor.MatchString(v) {
+				return d, fmt.Errorf("%s must be a hex or named color", name)
+			}
+			*color = v
+		}
+	}
+	switch edge.StrokeStyle = query.Get("edge_style"); edge.StrokeStyle {
+	case "", "solid", "dashed", "dotted":
+	default:
+		return d, errors.New("edge style must be solid, dashed or dotted")
+	}
+
+	if v := query.Get("roughness"); v != "" {
+		roughness, err := strconv.Atoi(v)
+		if err != nil || roughness < 0 || roughness > 2 {
+			return d, errors.New("roughness must be 0, 1 or 2")
+		}
+		d.Roughness = roughness
+	}
+	switch query.Get("font") {
+	case "", "hand":
+		d.FontFamily = convert.FontFamilyHand
+	case "sans":
+		d.FontFamily = convert.FontFamilySans
+	case "mono":
+		d.FontFamily = convert.FontFamilyMono
+	default:
+		return d, errors.New("font must be hand, sans or mono")
+	}
+	for name, limits := range map[string]struct {
+		value    *float64
+		min, max float64
+	}{
+		"font_size":    {&d.FontSize, 8, 96},
+		"node_spacing": {&d.NodeSpacing, 1, 1000},
+		"rank_spacing": {&d.RankSpacing, 1, 1000},
+	} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || n < limits.min || n > limits.max {
+				return d, fmt.Errorf("%s must be a number from %v to %v", name, limits.min, limits.max)
+			}
+			*limits.value = n
+		}
+	}
+	return d, nil
+}
+
+func readSource(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxSourceSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.TooLarge, "Graph too large")
+			return nil, false
+		}
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Failed to read graph")
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,80 @@
+package diagrams
+
+import (
+	"encoding/json"
+	"excalidraw-server/convert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleDOT(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		body     string
+		want     int
+		elements int
+	}{
+		{"graph", `digraph { a -> b [label="calls"] }`, http.StatusOK, 6},
+		{"not dot", `<svg/>`, http.StatusUnprocessableEntity, 0},
+		{"too large", "graph {" + strings.Repeat(" ", MaxSourceSize) + "}", http.StatusRequestEntityTooLarge, 0},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/convert/dot", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		HandleDOT()(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		var scene convert.Scene
+		if err := json.NewDecoder(rec.Body).Decode(&scene); err != nil || scene.Type != "excalidraw" || len(scene.Elements) != tt.elements {
+			t.Errorf("%s: scene = %+v, %v, want %d elements", tt.name, scene, err, tt.elements)
+		}
+	}
+}
+
+func TestHandleData(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"json graph", "?direction=LR&shape=ellipse&stroke_color=%231971c2&font=sans", "application/json", `{"edges":[{"from":"a","to":"b"}]}`, http.StatusOK},
+		{"json tree", "", "application/json", `{"label":"CEO","children":[{"label":"CTO"}]}`, http.StatusOK},
+		{"csv", "", "text/csv; charset=utf-8", "from,to\na,b\n", http.StatusOK},
+		{"csv by format", "?format=csv", "", "a,b\n", http.StatusOK},
+		{"bad direction", "?direction=up", "", `{"edges":[]}`, http.StatusBadRequest},
+		{"bad color", "?edge_color=url(x)", "", `{"edges":[]}`, http.StatusBadRequest},
+		{"bad font size", "?font_size=200", "", `{"edges":[]}`, http.StatusBadRequest},
+		{"bad format", "?format=xml", "", `<a/>`, http.StatusBadRequest},
+		{"empty", "", "", " ", http.StatusBadRequest},
+		{"invalid", "", "", `{"nodes":[{"label":"no id"}]}`, http.StatusUnprocessableEntity},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/convert/data"+tt.query, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		HandleData()(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/convert/data?shape=diamond&edge_style=dashed&roughness=1", strings.NewReader(`{"edges":[{"from":"a","to":"b"}]}`))
+	rec := httptest.NewRecorder()
+	HandleData()(rec, req)
+	var scene convert.Scene
+	if err := json.NewDecoder(rec.Body).Decode(&scene); err != nil {
+		t.Fatal(err)
+	}
+	for _, el := range scene.Elements {
+		if el.Type == "rectangle" || el.Roughness != 1 || el.Type == "arrow" && el.StrokeStyle != "dashed" {
+			t.Errorf("element %s isn't styled: %+v", el.Type, el)
+		}
+	}
+}
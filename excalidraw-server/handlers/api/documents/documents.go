@@ -2,12 +2,19 @@ package documents
 
 import (
 	"bytes"
+	"errors"
+	"excalidraw-server/apierror"
 	"excalidraw-server/core"
+	"excalidraw-server/export"
+	"excalidraw-server/handlers/api/apiversion"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/api/openapi"
 	"io"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
 )
 
 type (
@@ -21,30 +28,101 @@ func HandleCreate(documentStore core.DocumentStore) http.HandlerFunc {
 		data := new(bytes.Buffer)
 		_, err := io.Copy(data, r.Body)
 		if err != nil {
-			http.Error(w, "Failed to copy", http.StatusInternalServerError)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to copy")
 			return
 		}
 		id, err := documentStore.Create(r.Context(), &core.Document{Data: *data})
 		if err != nil {
-			http.Error(w, "Failed to save", http.StatusInternalServerError)
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save")
 			return
 		}
 
+		render.Status(r, apiversion.CreatedStatus(r))
 		render.JSON(w, r, DocumentCreateResponse{ID: id})
-		render.Status(r, http.StatusOK)
 	}
 }
 
+// HandleGet serves a shared document, counting the view when the store
+// keeps access stats
 func HandleGet(documentStore core.DocumentStore) http.HandlerFunc {
+	statsStore, _ := documentStore.(core.DocumentStatsStore)
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 		document, err := documentStore.FindID(r.Context(), id)
 		if err != nil {
-			http.Error(w, "not found", http.StatusNotFound)
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.DocumentNotFound, "not found")
 			return
 		}
+		if statsStore != nil {
+			if err := statsStore.RecordDocumentView(r.Context(), id); err != nil {
+				logrus.WithField("document_id", id).WithField("error", err).Warn("Failed to record document view")
+			}
+		}
 		if _, err := w.Write(document.Data.Bytes()); err != nil {
-			http.Error(w, "failed to write response", http.StatusInternalServerError)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "failed to write response")
+		}
+	}
+}
+
+// HandleStats returns how often a shared document has been opened
+func HandleStats(statsStore core.DocumentStatsStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := statsStore.GetDocumentStats(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			if !errors.Is(err, core.ErrDocumentNotFound) {
+				logrus.WithField("error", err).Error("Failed to get document stats")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.DocumentNotFound, "not found")
+			return
 		}
+		render.JSON(w, r, stats)
 	}
 }
+
+// Operations documents the routes mounted under /api/v2, except the stats
+// which only some stores keep.
+var Operations = []openapi.Operation{
+	{
+		Method: http.MethodPost, Path: "/post/", Tag: "documents",
+		Summary:     "Share a document; the body is stored as sent, usually encrypted",
+		RequestType: "application/octet-stream",
+		Response:    DocumentCreateResponse{},
+	},
+	{
+		Method: http.MethodGet, Path: "/{id}/", Tag: "documents",
+		Summary:      "Get a shared document as it was stored",
+		ResponseType: "application/octet-stream",
+		Errors:       []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodGet, Path: "/{id}/export.{format}", Tag: "documents",
+		Summary: "Render a shared document as png, svg or pdf",
+		Query: []openapi.Param{
+			{Name: "scale", Type: "number", Description: "Above 0 and at most 4, 1 by default"},
+			{Name: "theme", Description: "light or dark"},
+		},
+		ResponseType: "image/png, image/svg+xml, application/pdf",
+		Errors:       []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		Method: http.MethodGet, Path: "/{id}/og.png", Tag: "documents",
+		Summary:      "Render a shared document as a link preview image",
+		Query:        []openapi.Param{{Name: "theme", Description: "light or dark"}},
+		ResponseType: "image/png",
+		Errors:       []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		Method: http.MethodGet, Path: "/{id}/analyze", Tag: "documents",
+		Summary:  "Describe the elements, text and images of a shared document",
+		Response: export.Analysis{},
+		Errors:   []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+}
+
+// StatsOperation documents HandleStats, mounted under /api/v2.
+var StatsOperation = openapi.Operation{
+	Method: http.MethodGet, Path: "/{id}/stats", Tag: "documents",
+	Summary:  "Count how often a shared document has been opened",
+	Response: core.AccessStats{},
+	Errors:   []int{http.StatusNotFound},
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
 	"fmt"
 	"io"
 	"net/http"
@@ -177,6 +178,21 @@ func TestHandleCreate_StoreError(t *testing.T) {
 	}
 }
 
+func TestHandleCreate_StoreTimeout(t *testing.T) {
+	store := newMockStore()
+	store.createErr = fmt.Errorf("insert: %w", context.DeadlineExceeded)
+	handler := HandleCreate(store)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/post/", strings.NewReader("test"))
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("Status code mismatch: got %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+}
+
 func TestHandleCreate_ContentTypes(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -556,3 +572,41 @@ func TestResponseFormat(t *testing.T) {
 		t.Errorf("Response is not valid JSON: %v", err)
 	}
 }
+
+func TestHandleStats(t *testing.T) {
+	documentStore := memory.NewDocumentStore()
+	statsStore := documentStore.(core.DocumentStatsStore)
+	id, err := documentStore.Create(context.Background(), &core.Document{Data: *bytes.NewBufferString("scene")})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/api/v2/{id}/", HandleGet(documentStore))
+	r.Get("/api/v2/{id}/stats", HandleStats(statsStore))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/"+id+"/", http.NoBody))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("get status = %d", rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/"+id+"/stats", http.NoBody))
+	var stats core.AccessStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.Views != 2 || stats.LastAccessed == 0 {
+		t.Errorf("stats = %+v, want 2 views", stats)
+	}
+
+	// Stats are only kept for stored documents
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/missing/stats", http.NoBody))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("stats for an unknown id status = %d, want 404", rec.Code)
+	}
+}
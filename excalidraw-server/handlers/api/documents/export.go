@@ -0,0 +1,138 @@
+package documents
+
+import (
+	"bytes"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"excalidraw-server/export"
+	"excalidraw-server/handlers/api/deadline"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+// loadScene reads a document as a scene, writing the error response when it
+// is missing or isn't a plain scene
+func loadScene(w http.ResponseWriter, r *http.Request, documentStore core.DocumentStore, id string) (*export.Scene, bool) {
+	document, err := documentStore.FindID(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.DocumentNotFound, "not found")
+		return nil, false
+	}
+	scene, err := export.ParseScene(document.Data.Bytes())
+	if err != nil {
+		apierror.Write(w, http.StatusUnprocessableEntity, apierror.NotAScene, "Document is not a plain Excalidraw scene; encrypted share links can't be read by the server")
+		return nil, false
+	}
+	return scene, true
+}
+
+// HandleExport renders a shared document as PNG, SVG or PDF, for embedding
+// diagrams with a plain image URL. The format comes from the URL and the
+// scale and theme query parameters size and color the output.
+func HandleExport(documentStore core.DocumentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := export.Format(chi.URLParam(r, "format"))
+		if format.ContentType() == "" {
+			apierror.Write(w, http.StatusNotFound, apierror.UnsupportedFormat, "Format must be png, svg or pdf")
+			return
+		}
+
+		opts := export.Options{Scale: 1}
+		if s := r.URL.Query().Get("scale"); s != "" {
+			scale, err := strconv.ParseFloat(s, 64)
+			if err != nil || scale <= 0 || scale > export.MaxScale {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Scale must be a number above 0 and at most 4")
+				return
+			}
+			opts.Scale = scale
+		}
+		switch r.URL.Query().Get("theme") {
+		case "", "light":
+		case "dark":
+			opts.Dark = true
+		default:
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Theme must be light or dark")
+			return
+		}
+
+		id := chi.URLParam(r, "id")
+		scene, ok := loadScene(w, r, documentStore, id)
+		if !ok {
+			return
+		}
+
+		// Render fully before writing so failures still get an error status
+		var out bytes.Buffer
+		if err := export.Render(&out, scene, format, opts); err != nil {
+			if errors.Is(err, export.ErrTooLarge) {
+				apierror.Write(w, http.StatusUnprocessableEntity, apierror.SceneTooLarge, "Scene is too large to export at this scale")
+				return
+			}
+			logrus.WithField("document_id", id).WithField("error", err).Error("Failed to export document")
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to export document")
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		// Documents never change once shared
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if format == export.SVG {
+			w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:")
+		}
+		if _, err := w.Write(out.Bytes()); err != nil {
+			logrus.WithField("document_id", id).WithField("error", err).Warn("Failed to write export")
+		}
+	}
+}
+
+// OpenGraph preview images are 1200 × 630, the size Slack, Teams and social
+// networks crop link cards to
+const (
+	OGImageWidth  = 1200
+	OGImageHeight = 630
+)
+
+// HandleOGImage renders a shared document as a link preview image
+func HandleOGImage(documentStore core.DocumentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		scene, ok := loadScene(w, r, documentStore, id)
+		if !ok {
+			return
+		}
+
+		var out bytes.Buffer
+		dark := r.URL.Query().Get("theme") == "dark"
+		if err := export.Thumbnail(&out, scene, OGImageWidth, OGImageHeight, dark); err != nil {
+			logrus.WithField("document_id", id).WithField("error", err).Error("Failed to render preview image")
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to render preview image")
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		if _, err := w.Write(out.Bytes()); err != nil {
+			logrus.WithField("document_id", id).WithField("error", err).Warn("Failed to write preview image")
+		}
+	}
+}
+
+// HandleAnalyze reports what a shared document is made of: elements by type,
+// text, bounds, embedded images and top-level groups
+func HandleAnalyze(documentStore core.DocumentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scene, ok := loadScene(w, r, documentStore, chi.URLParam(r, "id"))
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		render.JSON(w, r, export.Analyze(scene))
+	}
+}
@@ -0,0 +1,142 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"excalidraw-server/core"
+	"excalidraw-server/export"
+	"excalidraw-server/stores/memory"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandleExport(t *testing.T) {
+	documentStore := memory.NewDocumentStore()
+	create := func(data string) string {
+		id, err := documentStore.Create(context.Background(), &core.Document{Data: *bytes.NewBufferString(data)})
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		return id
+	}
+	scene := create(`{"elements":[{"type":"rectangle","x":0,"y":0,"width":50,"height":30,"backgroundColor":"#ff0000","fillStyle":"solid"}]}`)
+	encrypted := create("\x00\x01\x02 encrypted")
+
+	r := chi.NewRouter()
+	r.Get("/api/v2/{id}/export.{format}", HandleExport(documentStore))
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		return rec
+	}
+
+	for format, contentType := range map[string]string{"png": "image/png", "svg": "image/svg+xml", "pdf": "application/pdf"} {
+		rec := get("/api/v2/" + scene + "/export." + format)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s export status = %d: %s", format, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Type"); got != contentType {
+			t.Errorf("%s Content-Type = %q, want %q", format, got, contentType)
+		}
+	}
+
+	// The scale multiplies the image size. Padding and the outline make the
+	// scene 71 × 51
+	rec := get("/api/v2/" + scene + "/export.png?scale=2&theme=dark")
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != 142 || size.Y != 102 {
+		t.Errorf("PNG size = %v, want 142 × 102", size)
+	}
+
+	if rec := get("/api/v2/" + scene + "/export.svg?theme=dark"); !strings.Contains(rec.Body.String(), "#121212") {
+		t.Error("dark SVG doesn't use the dark background")
+	}
+
+	for path, want := range map[string]int{
+		"/api/v2/" + scene + "/export.gif":           http.StatusNotFound,
+		"/api/v2/" + scene + "/export.png?scale=0":   http.StatusBadRequest,
+		"/api/v2/" + scene + "/export.png?scale=10":  http.StatusBadRequest,
+		"/api/v2/" + scene + "/export.png?theme=sun": http.StatusBadRequest,
+		"/api/v2/missing/export.png":                 http.StatusNotFound,
+		"/api/v2/" + encrypted + "/export.svg":       http.StatusUnprocessableEntity,
+	} {
+		if rec := get(path); rec.Code != want {
+			t.Errorf("GET %s status = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
+func TestHandleOGImage(t *testing.T) {
+	documentStore := memory.NewDocumentStore()
+	id, err := documentStore.Create(context.Background(), &core.Document{
+		Data: *bytes.NewBufferString(`{"elements":[{"type":"ellipse","x":0,"y":0,"width":40,"height":40}]}`),
+	})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/api/v2/{id}/og.png", HandleOGImage(documentStore))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/"+id+"/og.png", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != OGImageWidth || size.Y != OGImageHeight {
+		t.Errorf("preview size = %v, want %d × %d", size, OGImageWidth, OGImageHeight)
+	}
+}
+
+func TestHandleAnalyze(t *testing.T) {
+	documentStore := memory.NewDocumentStore()
+	id, err := documentStore.Create(context.Background(), &core.Document{
+		Data: *bytes.NewBufferString(`{"elements":[{"type":"ellipse","x":0,"y":0,"width":40,"height":40},{"type":"text","text":"hi there","x":0,"y":50}]}`),
+	})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	encrypted, err := documentStore.Create(context.Background(), &core.Document{Data: *bytes.NewBufferString("\x00\x01 encrypted")})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/api/v2/{id}/analyze", HandleAnalyze(documentStore))
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		return rec
+	}
+
+	rec := get("/api/v2/" + id + "/analyze")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var analysis export.Analysis
+	if err := json.NewDecoder(rec.Body).Decode(&analysis); err != nil {
+		t.Fatal(err)
+	}
+	if analysis.Elements != 2 || analysis.Types["ellipse"] != 1 || analysis.Text.Words != 2 || analysis.Bounds == nil {
+		t.Errorf("analysis = %+v", analysis)
+	}
+
+	if rec := get("/api/v2/" + encrypted + "/analyze"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("encrypted document status = %d, want 422", rec.Code)
+	}
+	if rec := get("/api/v2/missing/analyze"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing document status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,151 @@
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/deadline"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxSize matches the 4 MiB image limit of the Excalidraw frontend.
+	DefaultMaxSize = 4 << 20
+	// ChecksumHeader optionally carries the hex SHA-256 of the uploaded body.
+	ChecksumHeader = "X-Content-SHA256"
+)
+
+// validFileID keeps ids safe to use as file names and storage keys.
+var validFileID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// MaxSizeFromEnv reads FILE_MAX_SIZE in bytes, falling back to DefaultMaxSize.
+func MaxSizeFromEnv() int64 {
+	if value := os.Getenv("FILE_MAX_SIZE"); value != "" {
+		if size, err := strconv.ParseInt(value, 10, 64); err == nil && size > 0 {
+			return size
+		}
+		logrus.WithField("FILE_MAX_SIZE", value).Warn("Invalid FILE_MAX_SIZE, using default")
+	}
+	return DefaultMaxSize
+}
+
+// ContentType sniffs the type of an asset. Only images are served with their
+// own type; anything else, including encrypted payloads, is served as
+// application/octet-stream so uploads can't be rendered as HTML.
+func ContentType(data []byte) string {
+	contentType := http.DetectContentType(data)
+	if strings.HasPrefix(contentType, "image/") {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// HandlePut stores the request body under fileId. Files are immutable: a
+// repeated upload with the same content succeeds, different content is a
+// conflict.
+func HandlePut(store core.FileStore, maxSize int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fileID := chi.URLParam(r, "fileId")
+		if !validFileID.MatchString(fileID) {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid file id")
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.TooLarge, "File too large")
+				return
+			}
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Failed to read body")
+			return
+		}
+		if len(data) == 0 {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Empty file")
+			return
+		}
+
+		if checksum := r.Header.Get(ChecksumHeader); checksum != "" {
+			sum := sha256.Sum256(data)
+			if !strings.EqualFold(checksum, hex.EncodeToString(sum[:])) {
+				apierror.Write(w, http.StatusBadRequest, apierror.ChecksumMismatch, "Checksum mismatch")
+				return
+			}
+		}
+
+		log := logrus.WithFields(logrus.Fields{
+			"file_id":      fileID,
+			"data_length":  len(data),
+			"content_type": ContentType(data),
+		})
+
+		existing, err := store.GetFile(r.Context(), fileID)
+		switch {
+		case err == nil:
+			if !bytes.Equal(existing.Data, data) {
+				apierror.Write(w, http.StatusConflict, apierror.FileExists, "File already exists with different content")
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		case !errors.Is(err, core.ErrFileNotFound):
+			log.WithField("error", err).Error("Failed to look up file")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save")
+			return
+		}
+
+		if err := store.PutFile(r.Context(), &core.File{ID: fileID, Data: data, CreatedAt: time.Now()}); err != nil {
+			log.WithField("error", err).Error("Failed to store file")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save")
+			return
+		}
+
+		log.Info("File uploaded")
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// HandleGet serves a stored file with its sniffed content type.
+func HandleGet(store core.FileStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fileID := chi.URLParam(r, "fileId")
+		if !validFileID.MatchString(fileID) {
+			apierror.Write(w, http.StatusNotFound, apierror.FileNotFound, "not found")
+			return
+		}
+
+		file, err := store.GetFile(r.Context(), fileID)
+		if errors.Is(err, core.ErrFileNotFound) {
+			apierror.Write(w, http.StatusNotFound, apierror.FileNotFound, "not found")
+			return
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"file_id": fileID, "error": err}).Error("Failed to load file")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to load")
+			return
+		}
+
+		sum := sha256.Sum256(file.Data)
+		w.Header().Set("Content-Type", ContentType(file.Data))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set(ChecksumHeader, hex.EncodeToString(sum[:]))
+		// Files never change once uploaded.
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		if _, err := w.Write(file.Data); err != nil {
+			logrus.WithField("error", err).Warn("Failed to write file response")
+		}
+	}
+}
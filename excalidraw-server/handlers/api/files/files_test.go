@@ -0,0 +1,201 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"excalidraw-server/core"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type mockFileStore struct {
+	mu     sync.Mutex
+	files  map[string]core.File
+	getErr error
+}
+
+func newMockStore() *mockFileStore {
+	return &mockFileStore{files: make(map[string]core.File)}
+}
+
+func (m *mockFileStore) PutFile(ctx context.Context, file *core.File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[file.ID] = *file
+	return nil
+}
+
+func (m *mockFileStore) GetFile(ctx context.Context, id string) (*core.File, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, ok := m.files[id]
+	if !ok {
+		return nil, fmt.Errorf("file with id %s: %w", id, core.ErrFileNotFound)
+	}
+	return &file, nil
+}
+
+func (m *mockFileStore) ListFiles(ctx context.Context) ([]core.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make([]core.File, 0, len(m.files))
+	for _, file := range m.files {
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (m *mockFileStore) DeleteFile(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, id)
+	return nil
+}
+
+var pngData = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+
+func newRouter(store core.FileStore, maxSize int64) *chi.Mux {
+	r := chi.NewRouter()
+	r.Put("/api/v2/files/{fileId}", HandlePut(store, maxSize))
+	r.Get("/api/v2/files/{fileId}", HandleGet(store))
+	return r
+}
+
+func put(r http.Handler, id string, data []byte, checksum string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/api/v2/files/"+id, bytes.NewReader(data))
+	if checksum != "" {
+		req.Header.Set(ChecksumHeader, checksum)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandlePut(t *testing.T) {
+	sum := sha256.Sum256(pngData)
+	checksum := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name       string
+		id         string
+		data       []byte
+		checksum   string
+		wantStatus int
+	}{
+		{"created", "file1", pngData, "", http.StatusCreated},
+		{"created with checksum", "file2", pngData, checksum, http.StatusCreated},
+		{"checksum mismatch", "file3", pngData, "deadbeef", http.StatusBadRequest},
+		{"too large", "file4", make([]byte, 101), "", http.StatusRequestEntityTooLarge},
+		{"empty", "file5", nil, "", http.StatusBadRequest},
+		{"invalid id", "..", pngData, "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := put(newRouter(newMockStore(), 100), tt.id, tt.data, tt.checksum)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandlePut_Immutable(t *testing.T) {
+	router := newRouter(newMockStore(), DefaultMaxSize)
+
+	if rec := put(router, "file1", pngData, ""); rec.Code != http.StatusCreated {
+		t.Fatalf("first upload status = %d", rec.Code)
+	}
+	if rec := put(router, "file1", pngData, ""); rec.Code != http.StatusOK {
+		t.Errorf("identical re-upload status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := put(router, "file1", []byte("other"), ""); rec.Code != http.StatusConflict {
+		t.Errorf("conflicting upload status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestHandlePut_StoreError(t *testing.T) {
+	store := newMockStore()
+	store.getErr = errors.New("disk on fire")
+
+	if rec := put(newRouter(store, DefaultMaxSize), "file1", pngData, ""); rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestHandleGet(t *testing.T) {
+	store := newMockStore()
+	router := newRouter(store, DefaultMaxSize)
+	put(router, "image", pngData, "")
+	put(router, "page", []byte("<html><script>alert(1)</script></html>"), "")
+
+	tests := []struct {
+		name            string
+		id              string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"image", "image", http.StatusOK, "image/png"},
+		{"html is not served as html", "page", http.StatusOK, "application/octet-stream"},
+		{"missing", "missing", http.StatusNotFound, "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/files/"+tt.id, http.NoBody))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/files/image", http.NoBody))
+	if !bytes.Equal(rec.Body.Bytes(), pngData) {
+		t.Error("response body does not match uploaded data")
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("expected nosniff header")
+	}
+}
+
+func TestMaxSizeFromEnv(t *testing.T) {
+	t.Setenv("FILE_MAX_SIZE", "")
+	if got := MaxSizeFromEnv(); got != DefaultMaxSize {
+		t.Errorf("default = %d", got)
+	}
+	t.Setenv("FILE_MAX_SIZE", "1024")
+	if got := MaxSizeFromEnv(); got != 1024 {
+		t.Errorf("configured = %d", got)
+	}
+	t.Setenv("FILE_MAX_SIZE", "big")
+	if got := MaxSizeFromEnv(); got != DefaultMaxSize {
+		t.Errorf("invalid value = %d, want default", got)
+	}
+}
+
+func TestHandleGet_StoreError(t *testing.T) {
+	store := newMockStore()
+	store.getErr = errors.New("disk on fire")
+
+	rec := httptest.NewRecorder()
+	newRouter(store, DefaultMaxSize).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/files/image", http.NoBody))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
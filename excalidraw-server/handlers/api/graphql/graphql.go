@@ -0,0 +1,114 @@
+// Package graphql serves users, canvases, snapshots and live rooms as one
+// GraphQL schema, so dashboards can fetch what they show in a single
+// request. Resolvers call the same stores as the REST handlers, and users
+// and room snapshots are loaded in batches per request.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/openapi"
+	"excalidraw-server/handlers/api/snapshots"
+	"excalidraw-server/handlers/websocket"
+	"net/http"
+
+	"github.com/go-chi/render"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+const (
+	maxRequestSize  = 64 << 10
+	maxDepth        = 10
+	maxParallelism  = 32
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+type (
+	// Options are the stores behind the schema; any may be nil.
+	Options struct {
+		Users     core.UserStore
+		Canvases  core.CanvasStore
+		Snapshots snapshots.SnapshotStore
+		Rooms     Rooms
+	}
+
+	// Rooms gives the schema access to the live rooms.
+	Rooms struct {
+		// List counts the users of the rooms anyone may see.
+		List  func() map[string]int
+		Stats func(roomID string) (websocket.RoomStats, bool)
+		// CanJoin decides who may look rooms up by id; nil lets everyone.
+		CanJoin func(ctx context.Context, roomID, userID string) (bool, error)
+	}
+
+	// Request is a GraphQL query, as the body of a POST or the parameters
+	// of a GET.
+	Request struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName,omitempty"`
+		Variables     map[string]any `json:"variables,omitempty"`
+	}
+)
+
+// Handle answers GraphQL queries, posted as JSON or sent as the query,
+// operationName and variables parameters of a GET. Errors in a query that
+// could be run come back in its errors list with their code under
+// extensions, next to whatever data resolved.
+func Handle(opts Options) http.HandlerFunc {
+	schema := gql.MustParseSchema(schema, &resolver{opts: opts},
+		gql.MaxDepth(maxDepth),
+		gql.MaxParallelism(maxParallelism),
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		switch r.Method {
+		case http.MethodGet:
+			query := r.URL.Query()
+			req.Query = query.Get("query")
+			req.OperationName = query.Get("operationName")
+			if variables := query.Get("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+					apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "variables must be a JSON object")
+					return
+				}
+			}
+		default:
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+				return
+			}
+		}
+		if req.Query == "" {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "query is required")
+			return
+		}
+
+		ctx := withLoaders(r.Context(), opts)
+		render.JSON(w, r, schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+	}
+}
+
+// Operations document the endpoint for the OpenAPI spec.
+var Operations = []openapi.Operation{
+	{
+		Method: http.MethodPost, Path: "/", Tag: "graphql", Auth: openapi.AuthOptional,
+		Summary:  "Run a GraphQL query over users, canvases, snapshots and rooms",
+		Request:  Request{},
+		Response: map[string]any{},
+		Errors:   []int{http.StatusBadRequest},
+	},
+	{
+		Method: http.MethodGet, Path: "/", Tag: "graphql", Auth: openapi.AuthOptional,
+		Summary: "Run a GraphQL query given as parameters",
+		Query: []openapi.Param{
+			{Name: "query", Required: true},
+			{Name: "operationName"},
+			{Name: "variables", Description: "JSON object of the query's variables"},
+		},
+		Response: map[string]any{},
+		Errors:   []int{http.StatusBadRequest},
+	},
+}
@@ -0,0 +1,229 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/snapshots"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/stores/sqlite"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingUsers counts the batches users are loaded in.
+type countingUsers struct {
+	core.UserStore
+	batches atomic.Int32
+}
+
+func (c *countingUsers) GetUsers(ctx context.Context, ids []string) (map[string]core.User, error) {
+	c.batches.Add(1)
+	return c.UserStore.(core.UserBatchStore).GetUsers(ctx, ids)
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func testOptions(t *testing.T) (Options, *countingUsers) {
+	t.Helper()
+	store := sqlite.NewDocumentStore(filepath.Join(t.TempDir(), "test.db"))
+	users := &countingUsers{UserStore: store.(core.UserStore)}
+	stats := map[string]websocket.RoomStats{
+		"public": {RoomID: "public", Users: []websocket.RoomMember{
+			{SocketID: "a", User: &websocket.UserInfo{ID: "alice"}, JoinedAt: 1},
+			{SocketID: "b", User: &websocket.UserInfo{ID: "bob", Name: "Bob"}, JoinedAt: 2},
+			{SocketID: "c", JoinedAt: 3},
+		}},
+		"private": {RoomID: "private", Users: []websocket.RoomMember{{SocketID: "d"}}},
+	}
+	return Options{
+		Users:     users,
+		Canvases:  store.(core.CanvasStore),
+		Snapshots: store.(snapshots.SnapshotStore),
+		Rooms: Rooms{
+			List: func() map[string]int { return map[string]int{"public": 3} },
+			Stats: func(roomID string) (websocket.RoomStats, bool) {
+				room, ok := stats[roomID]
+				return room, ok
+			},
+			CanJoin: func(ctx context.Context, roomID, userID string) (bool, error) {
+				return roomID != "private", nil
+			},
+		},
+	}, users
+}
+
+// query posts a query, as userID when it is set.
+func query(t *testing.T, handler http.Handler, userID, query string, variables map[string]any) response {
+	t.Helper()
+	body, _ := json.Marshal(Request{Query: query, Variables: variables})
+	r := httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(string(body)))
+	if userID != "" {
+		r = r.WithContext(auth.WithClaims(r.Context(), &auth.Claims{Subject: userID, Name: "Claimed"}))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST %q = %d %s", query, w.Code, w.Body.String())
+	}
+	var resp response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+func TestUsersAndRooms(t *testing.T) {
+	opts, users := testOptions(t)
+	ctx := context.Background()
+	for _, user := range []core.User{{ID: "alice", Login: "alice", Name: "Alice", SeenAt: 1000}, {ID: "bob", Login: "bob"}} {
+		if err := users.PutUser(ctx, user); err != nil {
+			t.Fatalf("PutUser() failed: %v", err)
+		}
+	}
+	if _, err := opts.Snapshots.CreateSnapshot(ctx, "public", "v1", "", "", "alice", []byte("{}")); err != nil {
+		t.Fatalf("CreateSnapshot() failed: %v", err)
+	}
+	handler := Handle(opts)
+
+	resp := query(t, handler, "", `{
+		me { id }
+		rooms { id userCount members { socketId user { id login name } } snapshots { name createdBy room { id } } }
+		private: room(id: "private") { id }
+	}`, nil)
+	want := `{"me":null,"rooms":[{"id":"public","userCount":3,"members":[` +
+		`{"socketId":"a","user":{"id":"alice","login":"alice","name":"Alice"}},` +
+		`{"socketId":"b","user":{"id":"bob","login":"bob","name":null}},` +
+		`{"socketId":"c","user":null}],` +
+		`"snapshots":[{"name":"v1","createdBy":"alice","room":{"id":"public"}}]}],"private":null}`
+	if len(resp.Errors) != 0 || string(resp.Data) != want {
+		t.Errorf("data = %s, errors = %+v, want %s", resp.Data, resp.Errors, want)
+	}
+	if batches := users.batches.Load(); batches != 1 {
+		t.Errorf("members loaded in %d batches, want 1", batches)
+	}
+
+	resp = query(t, handler, "carol", `{ me { id name seenAt } user(login: "ALICE") { id seenAt } }`, nil)
+	want = `{"me":{"id":"carol","name":"Claimed","seenAt":null},"user":{"id":"alice","seenAt":"1970-01-01T00:00:01Z"}}`
+	if len(resp.Errors) != 0 || string(resp.Data) != want {
+		t.Errorf("data = %s, errors = %+v, want %s", resp.Data, resp.Errors, want)
+	}
+}
+
+func TestCanvases(t *testing.T) {
+	opts, _ := testOptions(t)
+	ctx := context.Background()
+	for _, canvas := range []core.Canvas{
+		{Key: "plan", OwnerID: "alice", Data: []byte("1"), CanvasMetadata: core.CanvasMetadata{Name: "Floor plan", Tags: []string{"home"}}},
+		{Key: "garden", OwnerID: "alice", Data: []byte("2"), CanvasMetadata: core.CanvasMetadata{Tags: []string{"home"}}},
+		{Key: "sprint", OwnerID: "alice", Data: []byte("3")},
+		{Key: "other", OwnerID: "bob", Data: []byte("4"), CanvasMetadata: core.CanvasMetadata{Tags: []string{"home"}}},
+	} {
+		metadata := canvas.CanvasMetadata
+		if err := opts.Canvases.PutCanvas(ctx, &canvas); err != nil {
+			t.Fatalf("PutCanvas() failed: %v", err)
+		}
+		update := core.CanvasMetadataUpdate{Name: &metadata.Name, Tags: &metadata.Tags}
+		if _, err := opts.Canvases.UpdateCanvasMetadata(ctx, canvas.OwnerID, canvas.Key, update); err != nil {
+			t.Fatalf("UpdateCanvasMetadata() failed: %v", err)
+		}
+	}
+	handler := Handle(opts)
+
+	resp := query(t, handler, "", `{ canvases { totalCount } }`, nil)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "unauthorized" {
+		t.Errorf("errors without a token = %+v, want unauthorized", resp.Errors)
+	}
+
+	const page = `query ($after: String) {
+		canvases(filter: {tag: "home"}, sort: NAME, first: 1, after: $after) {
+			nodes { key name tags }
+			pageInfo { endCursor hasNextPage }
+			totalCount
+		}
+	}`
+	type connection struct {
+		Canvases struct {
+			Nodes []struct {
+				Key string `json:"key"`
+			} `json:"nodes"`
+			PageInfo struct {
+				EndCursor   string `json:"endCursor"`
+				HasNextPage bool   `json:"hasNextPage"`
+			} `json:"pageInfo"`
+			TotalCount int `json:"totalCount"`
+		} `json:"canvases"`
+	}
+	var keys []string
+	variables := map[string]any{}
+	for i := 0; i < 3; i++ {
+		resp := query(t, handler, "alice", page, variables)
+		var data connection
+		if err := json.Unmarshal(resp.Data, &data); err != nil || len(resp.Errors) != 0 {
+			t.Fatalf("page %d = %s, %+v", i, resp.Data, resp.Errors)
+		}
+		if data.Canvases.TotalCount != 2 {
+			t.Errorf("totalCount = %d, want 2", data.Canvases.TotalCount)
+		}
+		for _, node := range data.Canvases.Nodes {
+			keys = append(keys, node.Key)
+		}
+		if !data.Canvases.PageInfo.HasNextPage {
+			break
+		}
+		variables["after"] = data.Canvases.PageInfo.EndCursor
+	}
+	// garden has no name, so it sorts by key
+	if strings.Join(keys, ",") != "plan,garden" {
+		t.Errorf("paged keys = %v, want plan,garden", keys)
+	}
+
+	resp = query(t, handler, "alice", `{ canvases(filter: {search: "SPR"}) { nodes { key } } canvas(key: "other") { key } }`, nil)
+	if want := `{"canvases":{"nodes":[{"key":"sprint"}]},"canvas":null}`; len(resp.Errors) != 0 || string(resp.Data) != want {
+		t.Errorf("data = %s, errors = %+v, want %s", resp.Data, resp.Errors, want)
+	}
+
+	resp = query(t, handler, "alice", `{ canvases(after: "nope") { totalCount } }`, nil)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "invalid_cursor" {
+		t.Errorf("errors for a bad cursor = %+v, want invalid_cursor", resp.Errors)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	opts, _ := testOptions(t)
+	handler := Handle(opts)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/graphql?query="+url.QueryEscape(`query Rooms { rooms { id } }`)+"&operationName=Rooms", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != `{"data":{"rooms":[{"id":"public"}]}}`+"\n" {
+		t.Errorf("GET = %d %s", w.Code, w.Body.String())
+	}
+
+	for _, target := range []string{"/api/graphql", "/api/graphql?query=%7B%7D&variables=nope"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", target, w.Code)
+		}
+	}
+
+	// Queries nested deeper than maxDepth are rejected before they run
+	deep := `{ rooms { snapshots { room { snapshots { room { snapshots { room { snapshots { room { snapshots { room { id } } } } } } } } } } } }`
+	resp := query(t, handler, "", deep, nil)
+	if len(resp.Errors) == 0 || resp.Data != nil {
+		t.Errorf("deep query = %s, %+v, want an error", resp.Data, resp.Errors)
+	}
+}
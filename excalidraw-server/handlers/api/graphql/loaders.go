@@ -0,0 +1,87 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/snapshots"
+	"excalidraw-server/stores/sqlite"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+const (
+	// loaderWait is how long a loader collects keys before loading them.
+	// Sibling fields resolve concurrently, so their keys arrive together.
+	loaderWait = 2 * time.Millisecond
+	// maxBatch keeps user lookups under SQLite's parameter limit.
+	maxBatch = 500
+)
+
+// loaders load users and room snapshots for one request, each key once and
+// in batches.
+type loaders struct {
+	users     *dataloader.Loader[string, *core.User]
+	snapshots *dataloader.Loader[string, []sqlite.Snapshot]
+}
+
+type loadersKey struct{}
+
+// withLoaders returns a copy of ctx with new loaders over opts' stores.
+func withLoaders(ctx context.Context, opts Options) context.Context {
+	l := &loaders{}
+	if opts.Users != nil {
+		l.users = dataloader.NewBatchedLoader(loadUsers(opts.Users),
+			dataloader.WithWait[string, *core.User](loaderWait),
+			dataloader.WithBatchCapacity[string, *core.User](maxBatch))
+	}
+	if opts.Snapshots != nil {
+		l.snapshots = dataloader.NewBatchedLoader(loadSnapshots(opts.Snapshots),
+			dataloader.WithWait[string, []sqlite.Snapshot](loaderWait))
+	}
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// loadUsers looks users up with one query when the store can, and one by
+// one otherwise. Unknown users load as nil.
+func loadUsers(store core.UserStore) dataloader.BatchFunc[string, *core.User] {
+	return func(ctx context.Context, ids []string) []*dataloader.Result[*core.User] {
+		results := make([]*dataloader.Result[*core.User], len(ids))
+		if batch, ok := store.(core.UserBatchStore); ok {
+			users, err := batch.GetUsers(ctx, ids)
+			for i, id := range ids {
+				results[i] = &dataloader.Result[*core.User]{Error: err}
+				if user, ok := users[id]; ok {
+					results[i].Data = &user
+				}
+			}
+			return results
+		}
+
+		for i, id := range ids {
+			user, err := store.GetUser(ctx, id)
+			if errors.Is(err, core.ErrUserNotFound) {
+				err = nil
+			}
+			results[i] = &dataloader.Result[*core.User]{Data: user, Error: err}
+		}
+		return results
+	}
+}
+
+// loadSnapshots lists the snapshots of each room.
+func loadSnapshots(store snapshots.SnapshotStore) dataloader.BatchFunc[string, []sqlite.Snapshot] {
+	return func(ctx context.Context, roomIDs []string) []*dataloader.Result[[]sqlite.Snapshot] {
+		results := make([]*dataloader.Result[[]sqlite.Snapshot], len(roomIDs))
+		for i, roomID := range roomIDs {
+			list, err := store.ListSnapshots(ctx, roomID)
+			results[i] = &dataloader.Result[[]sqlite.Snapshot]{Data: list, Error: err}
+		}
+		return results
+	}
+}
@@ -0,0 +1,537 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/canvases"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/stores/sqlite"
+	"sort"
+	"strings"
+	"time"
+
+	gql "github.com/graph-gophers/graphql-go"
+	"github.com/sirupsen/logrus"
+)
+
+type resolver struct {
+	opts Options
+}
+
+// caller returns the subject of the request's token, or "" without one.
+func caller(ctx context.Context) string {
+	if claims := auth.ClaimsFromContext(ctx); claims != nil {
+		return claims.Subject
+	}
+	return ""
+}
+
+// requireUser returns the caller's subject, or an error without a token.
+func requireUser(ctx context.Context) (string, error) {
+	userID := caller(ctx)
+	if userID == "" {
+		return "", apierror.New(apierror.Unauthorized, "authentication required")
+	}
+	return userID, nil
+}
+
+// storeError is the error for a failed store call: timeout or cancelled
+// when the request ran out of time or was cancelled, code otherwise.
+func storeError(err error, code apierror.Code, message string) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return apierror.New(apierror.Timeout, message)
+	case errors.Is(err, context.Canceled):
+		return apierror.New(apierror.Cancelled, message)
+	default:
+		return apierror.New(code, message)
+	}
+}
+
+func notImplemented(what string) error {
+	return apierror.Errorf(apierror.NotImplemented, "%s not available", what)
+}
+
+func millis(ms int64) gql.Time {
+	return gql.Time{Time: time.UnixMilli(ms)}
+}
+
+func optionalMillis(ms int64) *gql.Time {
+	if ms == 0 {
+		return nil
+	}
+	t := millis(ms)
+	return &t
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// loadUser returns the user with id from the store, or nil if it has
+// none.
+func loadUser(ctx context.Context, id string) (*core.User, error) {
+	users := loadersFrom(ctx).users
+	if users == nil {
+		return nil, nil
+	}
+	user, err := users.Load(ctx, id)()
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to get user")
+		return nil, storeError(err, apierror.Internal, "failed to get user")
+	}
+	return user, nil
+}
+
+// Me falls back to the token's claims for users the store doesn't know.
+func (r *resolver) Me(ctx context.Context) (*userResolver, error) {
+	claims := auth.ClaimsFromContext(ctx)
+	if claims == nil {
+		return nil, nil
+	}
+	user, err := loadUser(ctx, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		user = &core.User{ID: claims.Subject, Login: claims.Login, Name: claims.Name, AvatarURL: claims.AvatarURL}
+	}
+	return &userResolver{user: user}, nil
+}
+
+type userArgs struct {
+	ID    *gql.ID
+	Login *string
+}
+
+func (r *resolver) User(ctx context.Context, args userArgs) (*userResolver, error) {
+	if _, err := requireUser(ctx); err != nil {
+		return nil, err
+	}
+	if r.opts.Users == nil {
+		return nil, notImplemented("users")
+	}
+	if (args.ID == nil) == (args.Login == nil) {
+		return nil, apierror.New(apierror.InvalidRequest, "either id or login is required")
+	}
+
+	if args.ID != nil {
+		user, err := loadUser(ctx, string(*args.ID))
+		if err != nil || user == nil {
+			return nil, err
+		}
+		return &userResolver{user: user}, nil
+	}
+	user, err := r.opts.Users.FindUserByLogin(ctx, *args.Login)
+	if errors.Is(err, core.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to find user")
+		return nil, storeError(err, apierror.Internal, "failed to find user")
+	}
+	return &userResolver{user: user}, nil
+}
+
+type (
+	canvasesArgs struct {
+		Filter *canvasFilter
+		Sort   *string
+		Order  *string
+		First  *int32
+		After  *string
+	}
+
+	canvasFilter struct {
+		Tag    *string
+		Search *string
+	}
+)
+
+// matches reports whether canvas passes the filter.
+func (f *canvasFilter) matches(canvas core.Canvas) bool {
+	if f == nil {
+		return true
+	}
+	if f.Tag != nil {
+		tagged := false
+		for _, tag := range canvas.Tags {
+			tagged = tagged || tag == *f.Tag
+		}
+		if !tagged {
+			return false
+		}
+	}
+	if f.Search != nil {
+		search := strings.ToLower(*f.Search)
+		return strings.Contains(strings.ToLower(canvas.Name), search) || strings.Contains(strings.ToLower(canvas.Key), search)
+	}
+	return true
+}
+
+// Canvases pages through the caller's canvases with the same cursors as
+// GET /api/v3/kv.
+func (r *resolver) Canvases(ctx context.Context, args canvasesArgs) (*canvasConnectionResolver, error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r.opts.Canvases == nil {
+		return nil, notImplemented("canvases")
+	}
+
+	opts := core.CanvasListOptions{Limit: defaultPageSize}
+	if args.Sort != nil && *args.Sort == "NAME" {
+		opts.Sort = core.CanvasSortName
+	}
+	if args.Order != nil {
+		ascending := *args.Order == "ASC"
+		opts.Ascending = &ascending
+	}
+	if args.First != nil {
+		if *args.First < 1 || *args.First > maxPageSize {
+			return nil, apierror.Errorf(apierror.InvalidRequest, "first must be between 1 and %d", maxPageSize)
+		}
+		opts.Limit = int(*args.First)
+	}
+	if args.After != nil {
+		opts.Cursor = *args.After
+	}
+
+	list, err := r.opts.Canvases.ListCanvases(ctx, userID)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list canvases")
+		return nil, storeError(err, apierror.Internal, "failed to list canvases")
+	}
+	matching := list[:0]
+	for _, canvas := range list {
+		if args.Filter.matches(canvas) {
+			matching = append(matching, canvas)
+		}
+	}
+	total := len(matching)
+	page, err := core.PageCanvases(matching, opts)
+	if err != nil {
+		return nil, apierror.New(apierror.InvalidCursor, "invalid cursor")
+	}
+	return &canvasConnectionResolver{page: page, opts: opts, total: total}, nil
+}
+
+type canvasArgs struct {
+	Key string
+}
+
+func (r *resolver) Canvas(ctx context.Context, args canvasArgs) (*canvasResolver, error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r.opts.Canvases == nil {
+		return nil, notImplemented("canvases")
+	}
+	if !canvases.ValidKey(args.Key) {
+		return nil, apierror.New(apierror.InvalidCanvasKey, "invalid canvas key")
+	}
+
+	canvas, err := r.opts.Canvases.GetCanvas(ctx, userID, args.Key)
+	if errors.Is(err, core.ErrCanvasNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to get canvas")
+		return nil, storeError(err, apierror.Internal, "failed to get canvas")
+	}
+	return &canvasResolver{canvas: *canvas}, nil
+}
+
+func (r *resolver) Rooms(ctx context.Context) []*roomResolver {
+	if r.opts.Rooms.List == nil {
+		return []*roomResolver{}
+	}
+	rooms := []*roomResolver{}
+	for roomID, count := range r.opts.Rooms.List() {
+		rooms = append(rooms, r.room(roomID, count))
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].count != rooms[j].count {
+			return rooms[i].count > rooms[j].count
+		}
+		return rooms[i].stats.RoomID < rooms[j].stats.RoomID
+	})
+	return rooms
+}
+
+type roomArgs struct {
+	ID gql.ID
+}
+
+func (r *resolver) Room(ctx context.Context, args roomArgs) (*roomResolver, error) {
+	return r.liveRoom(ctx, string(args.ID))
+}
+
+type snapshotArgs struct {
+	ID gql.ID
+}
+
+func (r *resolver) Snapshot(ctx context.Context, args snapshotArgs) (*snapshotResolver, error) {
+	if r.opts.Snapshots == nil {
+		return nil, notImplemented("snapshots")
+	}
+	snapshot, err := r.opts.Snapshots.GetSnapshot(ctx, string(args.ID))
+	if err != nil {
+		// Like GET /api/snapshots/{id}, failures other than running out
+		// of time count as not found
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, storeError(err, apierror.Internal, "failed to get snapshot")
+		}
+		return nil, nil
+	}
+	snapshot.Data = nil
+	return &snapshotResolver{root: r, snapshot: *snapshot}, nil
+}
+
+// room returns the resolver of a room with count users, with its members
+// if it is still live.
+func (r *resolver) room(roomID string, count int) *roomResolver {
+	room := &roomResolver{root: r, stats: websocket.RoomStats{RoomID: roomID}, count: count}
+	if r.opts.Rooms.Stats != nil {
+		if stats, ok := r.opts.Rooms.Stats(roomID); ok {
+			room.stats = stats
+			room.count = len(stats.Users)
+		}
+	}
+	return room
+}
+
+// liveRoom returns roomID if it is live and the caller may join it, or
+// nil.
+func (r *resolver) liveRoom(ctx context.Context, roomID string) (*roomResolver, error) {
+	if r.opts.Rooms.Stats == nil {
+		return nil, nil
+	}
+	stats, ok := r.opts.Rooms.Stats(roomID)
+	if !ok {
+		return nil, nil
+	}
+	if r.opts.Rooms.CanJoin != nil {
+		allowed, err := r.opts.Rooms.CanJoin(ctx, roomID, caller(ctx))
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to check room permissions")
+			return nil, storeError(err, apierror.Internal, "failed to check room permissions")
+		}
+		if !allowed {
+			return nil, nil
+		}
+	}
+	return &roomResolver{root: r, stats: stats, count: len(stats.Users)}, nil
+}
+
+type userResolver struct {
+	user *core.User
+}
+
+func (u *userResolver) ID() gql.ID {
+	return gql.ID(u.user.ID)
+}
+
+func (u *userResolver) Login() *string {
+	return optionalString(u.user.Login)
+}
+
+func (u *userResolver) Name() *string {
+	return optionalString(u.user.Name)
+}
+
+func (u *userResolver) AvatarURL() *string {
+	return optionalString(u.user.AvatarURL)
+}
+
+func (u *userResolver) SeenAt() *gql.Time {
+	return optionalMillis(u.user.SeenAt)
+}
+
+type canvasConnectionResolver struct {
+	page  core.CanvasPage
+	opts  core.CanvasListOptions
+	total int
+}
+
+func (c *canvasConnectionResolver) Nodes() []*canvasResolver {
+	nodes := make([]*canvasResolver, len(c.page.Canvases))
+	for i, canvas := range c.page.Canvases {
+		nodes[i] = &canvasResolver{canvas: canvas}
+	}
+	return nodes
+}
+
+func (c *canvasConnectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNextPage: c.page.NextCursor != ""}
+	if n := len(c.page.Canvases); n > 0 {
+		cursor := c.opts.CursorAfter(c.page.Canvases[n-1])
+		info.endCursor = &cursor
+	}
+	return info
+}
+
+func (c *canvasConnectionResolver) TotalCount() int32 {
+	return int32(c.total)
+}
+
+type pageInfoResolver struct {
+	endCursor   *string
+	hasNextPage bool
+}
+
+func (p *pageInfoResolver) EndCursor() *string {
+	return p.endCursor
+}
+
+func (p *pageInfoResolver) HasNextPage() bool {
+	return p.hasNextPage
+}
+
+type canvasResolver struct {
+	canvas core.Canvas
+}
+
+func (c *canvasResolver) Key() string {
+	return c.canvas.Key
+}
+
+func (c *canvasResolver) Name() *string {
+	return optionalString(c.canvas.Name)
+}
+
+func (c *canvasResolver) Tags() []string {
+	if c.canvas.Tags == nil {
+		return []string{}
+	}
+	return c.canvas.Tags
+}
+
+func (c *canvasResolver) Size() int32 {
+	return int32(c.canvas.Size)
+}
+
+func (c *canvasResolver) CreatedAt() gql.Time {
+	return millis(c.canvas.CreatedAt)
+}
+
+func (c *canvasResolver) UpdatedAt() gql.Time {
+	return millis(c.canvas.UpdatedAt)
+}
+
+func (c *canvasResolver) Views() int32 {
+	return int32(c.canvas.Views)
+}
+
+func (c *canvasResolver) LastAccessed() *gql.Time {
+	return optionalMillis(c.canvas.LastAccessed)
+}
+
+type roomResolver struct {
+	root  *resolver
+	stats websocket.RoomStats
+	count int
+}
+
+func (r *roomResolver) ID() gql.ID {
+	return gql.ID(r.stats.RoomID)
+}
+
+func (r *roomResolver) UserCount() int32 {
+	return int32(r.count)
+}
+
+func (r *roomResolver) Members() []*memberResolver {
+	members := make([]*memberResolver, len(r.stats.Users))
+	for i, member := range r.stats.Users {
+		members[i] = &memberResolver{member: member}
+	}
+	return members
+}
+
+func (r *roomResolver) Snapshots(ctx context.Context) ([]*snapshotResolver, error) {
+	loader := loadersFrom(ctx).snapshots
+	if loader == nil {
+		return nil, notImplemented("snapshots")
+	}
+	list, err := loader.Load(ctx, r.stats.RoomID)()
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list snapshots")
+		return nil, storeError(err, apierror.Internal, "failed to list snapshots")
+	}
+	resolvers := make([]*snapshotResolver, len(list))
+	for i, snapshot := range list {
+		resolvers[i] = &snapshotResolver{root: r.root, snapshot: snapshot}
+	}
+	return resolvers, nil
+}
+
+type memberResolver struct {
+	member websocket.RoomMember
+}
+
+func (m *memberResolver) SocketID() gql.ID {
+	return gql.ID(m.member.SocketID)
+}
+
+func (m *memberResolver) JoinedAt() gql.Time {
+	return millis(m.member.JoinedAt)
+}
+
+// User is the member's directory entry, or what their socket told about
+// them if the store doesn't know them.
+func (m *memberResolver) User(ctx context.Context) (*userResolver, error) {
+	info := m.member.User
+	if info == nil {
+		return nil, nil
+	}
+	user, err := loadUser(ctx, info.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		user = &core.User{ID: info.ID, Name: info.Name, AvatarURL: info.AvatarURL}
+	}
+	return &userResolver{user: user}, nil
+}
+
+type snapshotResolver struct {
+	root     *resolver
+	snapshot sqlite.Snapshot
+}
+
+func (s *snapshotResolver) ID() gql.ID {
+	return gql.ID(s.snapshot.ID)
+}
+
+func (s *snapshotResolver) RoomID() gql.ID {
+	return gql.ID(s.snapshot.RoomID)
+}
+
+func (s *snapshotResolver) Name() string {
+	return s.snapshot.Name
+}
+
+func (s *snapshotResolver) Description() string {
+	return s.snapshot.Description
+}
+
+func (s *snapshotResolver) CreatedBy() string {
+	return s.snapshot.CreatedBy
+}
+
+func (s *snapshotResolver) CreatedAt() gql.Time {
+	return millis(s.snapshot.CreatedAt)
+}
+
+func (s *snapshotResolver) Room(ctx context.Context) (*roomResolver, error) {
+	return s.root.liveRoom(ctx, s.snapshot.RoomID)
+}
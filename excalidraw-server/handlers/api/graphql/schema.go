@@ -0,0 +1,106 @@
+package graphql
+
+// schema is served on /api/graphql. Fields backed by a store the server
+// doesn't have fail with the not_implemented code.
+const schema = `
+schema {
+	query: Query
+}
+
+"Time is an RFC 3339 timestamp."
+scalar Time
+
+type Query {
+	"The signed-in user; null without a token."
+	me: User
+	"A user seen with a JWT, by id or login. Needs a token."
+	user(id: ID, login: String): User
+	"The caller's canvases. Needs a token."
+	canvases(filter: CanvasFilter, sort: CanvasSort, order: SortOrder, first: Int, after: String): CanvasConnection!
+	"One of the caller's canvases. Needs a token."
+	canvas(key: String!): Canvas
+	"The listed live rooms, busiest first."
+	rooms: [Room!]!
+	"A live room the caller may join."
+	room(id: ID!): Room
+	snapshot(id: ID!): Snapshot
+}
+
+type User {
+	id: ID!
+	login: String
+	name: String
+	avatarUrl: String
+	"When the user last signed in; null for users only known from their token."
+	seenAt: Time
+}
+
+input CanvasFilter {
+	"Only canvases with this tag."
+	tag: String
+	"Only canvases whose name or key contains this, ignoring case."
+	search: String
+}
+
+"What canvases are ordered by; UPDATED_AT by default."
+enum CanvasSort {
+	UPDATED_AT
+	NAME
+}
+
+"The default is newest first, or A first when sorting by name."
+enum SortOrder {
+	ASC
+	DESC
+}
+
+type CanvasConnection {
+	nodes: [Canvas!]!
+	pageInfo: PageInfo!
+	"How many canvases match the filter, over all pages."
+	totalCount: Int!
+}
+
+type PageInfo {
+	"Pass as after to get the next page."
+	endCursor: String
+	hasNextPage: Boolean!
+}
+
+type Canvas {
+	key: String!
+	name: String
+	tags: [String!]!
+	size: Int!
+	createdAt: Time!
+	updatedAt: Time!
+	views: Int!
+	lastAccessed: Time
+}
+
+type Room {
+	id: ID!
+	userCount: Int!
+	members: [RoomMember!]!
+	"The room's snapshots, without their data."
+	snapshots: [Snapshot!]!
+}
+
+type RoomMember {
+	socketId: ID!
+	joinedAt: Time!
+	"Null for anonymous sockets."
+	user: User
+}
+
+type Snapshot {
+	id: ID!
+	roomId: ID!
+	name: String!
+	description: String!
+	createdBy: String!
+	createdAt: Time!
+	"The room while it is live, if the caller may join it."
+	room: Room
+}
+`
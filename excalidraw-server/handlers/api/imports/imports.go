@@ -0,0 +1,157 @@
+package imports
+
+import (
+	"bytes"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/convert"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/canvases"
+	"excalidraw-server/handlers/api/deadline"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxImportSize caps uploaded diagrams.
+const MaxImportSize = 10 << 20
+
+type (
+	// Options says where imported diagrams are saved.
+	Options struct {
+		Documents core.DocumentStore
+		// Canvases saves imports to the caller's canvases when a canvas key
+		// is given; nil when the store or JWT_SECRET is missing.
+		Canvases core.CanvasStore
+	}
+
+	// ImportResponse returns the new document's id or the new canvas' key,
+	// with the converted elements so clients can insert them directly.
+	ImportResponse struct {
+		ID       string             `json:"id,omitempty"`
+		Key      string             `json:"key,omitempty"`
+		Elements []*convert.Element `json:"elements"`
+	}
+)
+
+// HandleDrawio converts an uploaded .drawio file into an Excalidraw scene.
+// The page query parameter picks a page counting from 0. The scene is
+// saved as a new shared document, or as the caller's canvas when the
+// canvas query parameter names a key.
+func HandleDrawio(opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := 0
+		if p := r.URL.Query().Get("page"); p != "" {
+			var err error
+			if page, err = strconv.Atoi(p); err != nil || page < 0 {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Page must be a number from 0")
+				return
+			}
+		}
+		data, ok := readUpload(w, r)
+		if !ok {
+			return
+		}
+		scene, err := convert.FromDrawio(data, page)
+		if err != nil {
+			apierror.Write(w, http.StatusUnprocessableEntity, apierror.InvalidRequest, err.Error())
+			return
+		}
+		save(w, r, opts, scene, "drawio")
+	}
+}
+
+// HandleSVG converts the shapes and text of an uploaded SVG into editable
+// elements and saves them like HandleDrawio.
+func HandleSVG(opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, ok := readUpload(w, r)
+		if !ok {
+			return
+		}
+		scene, err := convert.FromSVG(data)
+		if err != nil {
+			apierror.Write(w, http.StatusUnprocessableEntity, apierror.InvalidRequest, err.Error())
+			return
+		}
+		save(w, r, opts, scene, "svg")
+	}
+}
+
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxImportSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.TooLarge, "File too large")
+			return nil, false
+		}
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Failed to read file")
+		return nil, false
+	}
+	if len(data) == 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Empty file")
+		return nil, false
+	}
+	return data, true
+}
+
+// save stores a converted scene as a document, or as a canvas when the
+// request names one.
+func save(w http.ResponseWriter, r *http.Request, opts Options, scene convert.Scene, format string) {
+	data, err := scene.JSON()
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to encode scene")
+		return
+	}
+	log := logrus.WithField("format", format)
+	resp := ImportResponse{Elements: scene.Elements}
+
+	if key := r.URL.Query().Get("canvas"); key != "" {
+		if opts.Canvases == nil {
+			apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Canvases are not available")
+			return
+		}
+		claims := auth.ClaimsFromContext(r.Context())
+		if claims == nil {
+			apierror.Write(w, http.StatusUnauthorized, apierror.Unauthorized, "Authentication required")
+			return
+		}
+		if !canvases.ValidKey(key) {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidCanvasKey, "Invalid canvas key")
+			return
+		}
+		_, err := opts.Canvases.GetCanvas(r.Context(), claims.Subject, key)
+		if err == nil {
+			apierror.Write(w, http.StatusConflict, apierror.CanvasExists, "Canvas already exists")
+			return
+		}
+		if !errors.Is(err, core.ErrCanvasNotFound) {
+			log.WithField("error", err).Error("Failed to get canvas")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save")
+			return
+		}
+		err = opts.Canvases.PutCanvas(r.Context(), &core.Canvas{Key: key, OwnerID: claims.Subject, Data: data})
+		if err != nil {
+			log.WithField("error", err).Error("Failed to save canvas")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save")
+			return
+		}
+		resp.Key = key
+	} else {
+		id, err := opts.Documents.Create(r.Context(), &core.Document{Data: *bytes.NewBuffer(data)})
+		if err != nil {
+			log.WithField("error", err).Error("Failed to save document")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save")
+			return
+		}
+		resp.ID = id
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, resp)
+}
@@ -0,0 +1,124 @@
+package imports
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const drawioFile = `<mxfile><diagram name="Page-1"><mxGraphModel><root>
+	<mxCell id="0"/>
+	<mxCell id="1" parent="0"/>
+	<mxCell id="a" value="Start" style="rounded=1;" vertex="1" parent="1">
+		<mxGeometry x="0" y="0" width="120" height="60" as="geometry"/>
+	</mxCell>
+</root></mxGraphModel></diagram></mxfile>`
+
+type fixture struct {
+	router *chi.Mux
+	store  core.DocumentStore
+	token  string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	verifier := auth.NewVerifier([]byte("secret"))
+	token, err := verifier.Sign(&auth.Claims{Subject: "alice"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	f := &fixture{store: memory.NewDocumentStore(), token: token}
+	opts := Options{Documents: f.store, Canvases: f.store.(core.CanvasStore)}
+
+	f.router = chi.NewRouter()
+	f.router.Route("/import", func(r chi.Router) {
+		r.Use(auth.Middleware(verifier, false))
+		r.Post("/drawio", HandleDrawio(opts))
+		r.Post("/svg", HandleSVG(opts))
+	})
+	return f
+}
+
+func (f *fixture) do(path string, signedIn bool, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if signedIn {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandleDrawio(t *testing.T) {
+	f := newFixture(t)
+
+	rec := f.do("/import/drawio", false, drawioFile)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp ImportResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.ID == "" || len(resp.Elements) != 2 {
+		t.Fatalf("response = %+v, %v", resp, err)
+	}
+	document, err := f.store.FindID(context.Background(), resp.ID)
+	if err != nil || !strings.Contains(document.Data.String(), `"Start"`) {
+		t.Errorf("saved document = %v, %v", document, err)
+	}
+
+	for name, tt := range map[string]struct {
+		path string
+		body string
+		want int
+	}{
+		"not draw.io":  {"/import/drawio", `{"type":"excalidraw"}`, http.StatusUnprocessableEntity},
+		"empty":        {"/import/drawio", "", http.StatusBadRequest},
+		"no such page": {"/import/drawio?page=1", drawioFile, http.StatusUnprocessableEntity},
+		"bad page":     {"/import/drawio?page=-1", drawioFile, http.StatusBadRequest},
+		"anonymous":    {"/import/drawio?canvas=flow", drawioFile, http.StatusUnauthorized},
+	} {
+		if rec := f.do(tt.path, false, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestHandleDrawioCanvas(t *testing.T) {
+	f := newFixture(t)
+
+	rec := f.do("/import/drawio?canvas=flow", true, drawioFile)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	canvas, err := f.store.(core.CanvasStore).GetCanvas(context.Background(), "alice", "flow")
+	if err != nil || !strings.Contains(string(canvas.Data), `"rectangle"`) {
+		t.Errorf("saved canvas = %+v, %v", canvas, err)
+	}
+
+	if rec := f.do("/import/drawio?canvas=flow", true, drawioFile); rec.Code != http.StatusConflict {
+		t.Errorf("existing key status = %d, want 409", rec.Code)
+	}
+	if rec := f.do("/import/drawio?canvas=../flow", true, drawioFile); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid key status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandleSVG(t *testing.T) {
+	f := newFixture(t)
+
+	rec := f.do("/import/svg", false, `<svg><rect width="10" height="10"/><text y="20">Hi</text></svg>`)
+	var resp ImportResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusCreated || len(resp.Elements) != 2 {
+		t.Fatalf("status = %d, %+v, %v", rec.Code, resp, err)
+	}
+	if rec := f.do("/import/svg", false, drawioFile); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("draw.io file status = %d, want 422", rec.Code)
+	}
+}
@@ -0,0 +1,109 @@
+// Package instance tells the frontend what this server is called and which
+// optional features and limits it has.
+package instance
+
+import (
+	"encoding/json"
+	"excalidraw-server/handlers/api/openapi"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/render"
+)
+
+const defaultName = "Excalidraw"
+
+type (
+	// Features reports which optional APIs are available.
+	Features struct {
+		Collaboration bool `json:"collaboration"`
+		// SocketAuth is the collab handshake mode: off, optional or required.
+		SocketAuth string `json:"socket_auth"`
+		// Accounts is set when per-user APIs accept JWT bearer tokens.
+		Accounts  bool `json:"accounts"`
+		Files     bool `json:"files"`
+		Libraries bool `json:"libraries"`
+		Canvases  bool `json:"canvases"`
+		Orgs      bool `json:"orgs"`
+		Snapshots bool `json:"snapshots"`
+		Recording bool `json:"recording"`
+		// CRDTSync is set when Yjs clients can sync rooms through the
+		// y-websocket provider on /yjs.
+		CRDTSync bool `json:"crdt_sync"`
+		// RoomPermissions is set when room owners can restrict who joins;
+		// joins are only checked when SocketAuth isn't off.
+		RoomPermissions bool `json:"room_permissions"`
+		// Notifications is set when @mentions in room chat notify users
+		// through /api/v2/notifications.
+		Notifications bool `json:"notifications"`
+		// Invitations is set when users can email room invitations.
+		Invitations bool `json:"invitations"`
+		// Thumbnails is set when canvases and snapshots have PNG previews
+		// rendered by the server.
+		Thumbnails bool `json:"thumbnails"`
+		// Templates is set when /api/v2/templates serves a gallery of
+		// starting scenes.
+		Templates bool `json:"templates"`
+		// EncryptedOnly is set when every room is relayed end-to-end
+		// encrypted.
+		EncryptedOnly bool `json:"encrypted_only"`
+		// PostChallenge is the challenge for anonymous uploads: off, pow or
+		// captcha.
+		PostChallenge string `json:"post_challenge"`
+	}
+
+	// Limits are maximum sizes in bytes.
+	Limits struct {
+		FileMaxSize     int64 `json:"file_max_size"`
+		LibraryMaxSize  int   `json:"library_max_size"`
+		CanvasMaxSize   int   `json:"canvas_max_size"`
+		TemplateMaxSize int   `json:"template_max_size"`
+		ImportMaxSize   int   `json:"import_max_size"`
+		MessageMaxSize  int   `json:"message_max_size"`
+	}
+
+	Config struct {
+		Name     string   `json:"name"`
+		LogoURL  string   `json:"logo_url,omitempty"`
+		Features Features `json:"features"`
+		Limits   Limits   `json:"limits"`
+	}
+)
+
+// BrandingFromEnv returns a Config with the name and logo from INSTANCE_NAME
+// and INSTANCE_LOGO_URL; the caller fills in features and limits.
+func BrandingFromEnv() Config {
+	cfg := Config{
+		Name:    os.Getenv("INSTANCE_NAME"),
+		LogoURL: os.Getenv("INSTANCE_LOGO_URL"),
+	}
+	if cfg.Name == "" {
+		cfg.Name = defaultName
+	}
+	return cfg
+}
+
+// Handle returns the instance configuration
+func Handle(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, cfg)
+	}
+}
+
+// Operation documents Handle, mounted under /api/v2.
+var Operation = openapi.Operation{
+	Method: http.MethodGet, Path: "/config", Tag: "instance",
+	Summary:  "Get the server's name and which features and limits it has",
+	Response: Config{},
+}
+
+// Script returns a script tag that exposes cfg to the frontend as
+// window.EXCALIDRAW_SERVER_CONFIG, for injecting into index.html.
+func Script(cfg Config) []byte {
+	// json.Marshal escapes <, > and &, so the config can't close the tag
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil
+	}
+	return []byte("<script>window.EXCALIDRAW_SERVER_CONFIG=" + string(data) + ";</script>")
+}
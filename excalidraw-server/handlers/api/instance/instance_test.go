@@ -0,0 +1,66 @@
+package instance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandle(t *testing.T) {
+	cfg := Config{
+		Name:     "Team Whiteboard",
+		Features: Features{Collaboration: true, SocketAuth: "optional", Files: true},
+		Limits:   Limits{FileMaxSize: 4 << 20},
+	}
+
+	rec := httptest.NewRecorder()
+	Handle(cfg)(rec, httptest.NewRequest(http.MethodGet, "/api/v2/config", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var got map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["name"] != "Team Whiteboard" {
+		t.Errorf("name = %v", got["name"])
+	}
+	if _, ok := got["logo_url"]; ok {
+		t.Error("logo_url should be omitted when unset")
+	}
+	features := got["features"].(map[string]any)
+	if features["socket_auth"] != "optional" || features["files"] != true || features["canvases"] != false {
+		t.Errorf("features = %v", features)
+	}
+	if limits := got["limits"].(map[string]any); limits["file_max_size"] != float64(4<<20) {
+		t.Errorf("limits = %v", limits)
+	}
+}
+
+func TestBrandingFromEnv(t *testing.T) {
+	t.Setenv("INSTANCE_NAME", "")
+	t.Setenv("INSTANCE_LOGO_URL", "")
+	if cfg := BrandingFromEnv(); cfg.Name != defaultName || cfg.LogoURL != "" {
+		t.Errorf("BrandingFromEnv() = %+v, want defaults", cfg)
+	}
+
+	t.Setenv("INSTANCE_NAME", "Acme Draw")
+	t.Setenv("INSTANCE_LOGO_URL", "https://acme.example/logo.svg")
+	if cfg := BrandingFromEnv(); cfg.Name != "Acme Draw" || cfg.LogoURL != "https://acme.example/logo.svg" {
+		t.Errorf("BrandingFromEnv() = %+v", cfg)
+	}
+}
+
+func TestScriptEscapesHTML(t *testing.T) {
+	script := string(Script(Config{Name: "</script><script>alert(1)</script>"}))
+
+	if !strings.HasPrefix(script, "<script>window.EXCALIDRAW_SERVER_CONFIG={") || !strings.HasSuffix(script, "};</script>") {
+		t.Errorf("unexpected script %q", script)
+	}
+	if strings.Count(script, "</script>") != 1 {
+		t.Errorf("config must not be able to close the script tag: %q", script)
+	}
+}
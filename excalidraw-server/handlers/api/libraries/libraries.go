@@ -0,0 +1,219 @@
+package libraries
+
+import (
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/apiversion"
+	"excalidraw-server/handlers/api/deadline"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxLibrarySize limits the size of a library request body.
+const MaxLibrarySize = 10 << 20
+
+type (
+	LibraryRequest struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Public      bool            `json:"public"`
+		Library     json.RawMessage `json:"library"`
+	}
+
+	CreateLibraryResponse struct {
+		ID string `json:"id"`
+	}
+)
+
+// decodeRequest reads a LibraryRequest and checks that it carries an
+// .excalidrawlib file.
+func decodeRequest(w http.ResponseWriter, r *http.Request) (*LibraryRequest, bool) {
+	var req LibraryRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxLibrarySize)).Decode(&req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+		return nil, false
+	}
+	if req.Name == "" {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Library name is required")
+		return nil, false
+	}
+
+	var file struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(req.Library, &file); err != nil || file.Type != "excalidrawlib" {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "library must be an .excalidrawlib file")
+		return nil, false
+	}
+	return &req, true
+}
+
+// loadOwned fetches the library in the URL and checks that the caller owns it.
+func loadOwned(store core.LibraryStore, w http.ResponseWriter, r *http.Request) (*core.Library, bool) {
+	library, err := store.GetLibrary(r.Context(), chi.URLParam(r, "libraryId"))
+	if err != nil {
+		apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.LibraryNotFound, "Library not found")
+		return nil, false
+	}
+	claims := auth.ClaimsFromContext(r.Context())
+	if claims == nil || claims.Subject != library.OwnerID {
+		apierror.Write(w, http.StatusNotFound, apierror.LibraryNotFound, "Library not found")
+		return nil, false
+	}
+	return library, true
+}
+
+// loadVisible fetches the library in the URL if it is public or owned by the
+// caller. Private libraries of other users look like missing ones.
+func loadVisible(store core.LibraryStore, w http.ResponseWriter, r *http.Request) (*core.Library, bool) {
+	library, err := store.GetLibrary(r.Context(), chi.URLParam(r, "libraryId"))
+	if err != nil {
+		if !errors.Is(err, core.ErrLibraryNotFound) {
+			logrus.WithField("error", err).Error("Failed to get library")
+		}
+		apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.LibraryNotFound, "Library not found")
+		return nil, false
+	}
+	if !library.Public {
+		claims := auth.ClaimsFromContext(r.Context())
+		if claims == nil || claims.Subject != library.OwnerID {
+			apierror.Write(w, http.StatusNotFound, apierror.LibraryNotFound, "Library not found")
+			return nil, false
+		}
+	}
+	return library, true
+}
+
+// HandleCreate stores a new library owned by the caller
+func HandleCreate(store core.LibraryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeRequest(w, r)
+		if !ok {
+			return
+		}
+		claims := auth.ClaimsFromContext(r.Context())
+		ownerName := claims.Name
+		if ownerName == "" {
+			ownerName = claims.Login
+		}
+
+		id, err := store.CreateLibrary(r.Context(), &core.Library{
+			OwnerID:     claims.Subject,
+			OwnerName:   ownerName,
+			Name:        req.Name,
+			Description: req.Description,
+			Public:      req.Public,
+			Data:        req.Library,
+		})
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to create library")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to create library")
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, CreateLibraryResponse{ID: id})
+	}
+}
+
+// HandleListOwn lists the caller's libraries
+func HandleListOwn(store core.LibraryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := auth.ClaimsFromContext(r.Context())
+		libraries, err := store.ListLibraries(r.Context(), core.LibraryFilter{OwnerID: claims.Subject})
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to list libraries")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list libraries")
+			return
+		}
+		apiversion.RenderList(w, r, libraries)
+	}
+}
+
+// HandleListPublic lists the public gallery
+func HandleListPublic(store core.LibraryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		libraries, err := store.ListLibraries(r.Context(), core.LibraryFilter{PublicOnly: true})
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to list public libraries")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list libraries")
+			return
+		}
+		apiversion.RenderList(w, r, libraries)
+	}
+}
+
+// HandleGet returns a library's metadata and data
+func HandleGet(store core.LibraryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		library, ok := loadVisible(store, w, r)
+		if !ok {
+			return
+		}
+		render.JSON(w, r, library)
+	}
+}
+
+// HandleDownload serves the raw .excalidrawlib file, suitable for the
+// frontend's #addLibrary= links
+func HandleDownload(store core.LibraryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		library, ok := loadVisible(store, w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.excalidrawlib+json")
+		if _, err := w.Write(library.Data); err != nil {
+			logrus.WithField("error", err).Warn("Failed to write library")
+		}
+	}
+}
+
+// HandleUpdate replaces a library owned by the caller
+func HandleUpdate(store core.LibraryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		library, ok := loadOwned(store, w, r)
+		if !ok {
+			return
+		}
+		req, ok := decodeRequest(w, r)
+		if !ok {
+			return
+		}
+
+		library.Name = req.Name
+		library.Description = req.Description
+		library.Public = req.Public
+		library.Data = req.Library
+		if err := store.UpdateLibrary(r.Context(), library); err != nil {
+			logrus.WithField("error", err).Error("Failed to update library")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to update library")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleDelete deletes a library owned by the caller
+func HandleDelete(store core.LibraryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		library, ok := loadOwned(store, w, r)
+		if !ok {
+			return
+		}
+		if err := store.DeleteLibrary(r.Context(), library.ID); err != nil {
+			logrus.WithField("error", err).Error("Failed to delete library")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to delete library")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
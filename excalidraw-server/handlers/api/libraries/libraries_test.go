@@ -0,0 +1,192 @@
+package libraries
+
+import (
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const libraryBody = `{"name":"Stencils","public":%s,"library":{"type":"excalidrawlib","version":2,"libraryItems":[]}}`
+
+func newRouter(store core.LibraryStore, verifier *auth.Verifier) *chi.Mux {
+	r := chi.NewRouter()
+	requireUser := auth.Middleware(verifier, true)
+	r.Route("/libraries", func(r chi.Router) {
+		r.Use(auth.Middleware(verifier, false))
+		r.Get("/public", HandleListPublic(store))
+		r.With(requireUser).Get("/", HandleListOwn(store))
+		r.With(requireUser).Post("/", HandleCreate(store))
+		r.Route("/{libraryId}", func(r chi.Router) {
+			r.Get("/", HandleGet(store))
+			r.Get("/excalidrawlib", HandleDownload(store))
+			r.With(requireUser).Put("/", HandleUpdate(store))
+			r.With(requireUser).Delete("/", HandleDelete(store))
+		})
+	})
+	return r
+}
+
+type fixture struct {
+	router *chi.Mux
+	tokens map[string]string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	verifier := auth.NewVerifier([]byte("secret"))
+	f := &fixture{
+		router: newRouter(memory.NewDocumentStore().(core.LibraryStore), verifier),
+		tokens: make(map[string]string),
+	}
+	for _, user := range []string{"alice", "bob"} {
+		token, err := verifier.Sign(&auth.Claims{Subject: user, Name: strings.ToUpper(user)})
+		if err != nil {
+			t.Fatalf("Sign() failed: %v", err)
+		}
+		f.tokens[user] = token
+	}
+	return f
+}
+
+func (f *fixture) do(method, path, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+f.tokens[user])
+	}
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (f *fixture) create(t *testing.T, user string, public bool) string {
+	t.Helper()
+	visibility := "false"
+	if public {
+		visibility = "true"
+	}
+	rec := f.do(http.MethodPost, "/libraries/", user, fmt.Sprintf(libraryBody, visibility))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp CreateLibraryResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp.ID
+}
+
+func TestHandleCreate_Validation(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		name       string
+		user       string
+		body       string
+		wantStatus int
+	}{
+		{"anonymous", "", fmt.Sprintf(libraryBody, "true"), http.StatusUnauthorized},
+		{"invalid json", "alice", "{", http.StatusBadRequest},
+		{"missing name", "alice", `{"library":{"type":"excalidrawlib"}}`, http.StatusBadRequest},
+		{"not a library", "alice", `{"name":"x","library":{"type":"excalidraw"}}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := f.do(http.MethodPost, "/libraries/", tt.user, tt.body); rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	f := newFixture(t)
+	public := f.create(t, "alice", true)
+	private := f.create(t, "alice", false)
+
+	tests := []struct {
+		name       string
+		path       string
+		user       string
+		wantStatus int
+	}{
+		{"public anonymous", "/libraries/" + public, "", http.StatusOK},
+		{"public download", "/libraries/" + public + "/excalidrawlib", "", http.StatusOK},
+		{"private owner", "/libraries/" + private, "alice", http.StatusOK},
+		{"private other user", "/libraries/" + private, "bob", http.StatusNotFound},
+		{"private anonymous", "/libraries/" + private + "/excalidrawlib", "", http.StatusNotFound},
+		{"missing", "/libraries/missing", "alice", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := f.do(http.MethodGet, tt.path, tt.user, ""); rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestListings(t *testing.T) {
+	f := newFixture(t)
+	f.create(t, "alice", true)
+	f.create(t, "alice", false)
+	f.create(t, "bob", false)
+
+	count := func(rec *httptest.ResponseRecorder) int {
+		var libraries []core.Library
+		if err := json.NewDecoder(rec.Body).Decode(&libraries); err != nil {
+			t.Fatalf("failed to decode listing: %v", err)
+		}
+		for _, library := range libraries {
+			if library.Data != nil {
+				t.Error("listings should not include library data")
+			}
+		}
+		return len(libraries)
+	}
+
+	if got := count(f.do(http.MethodGet, "/libraries/public", "", "")); got != 1 {
+		t.Errorf("public listing has %d libraries, want 1", got)
+	}
+	if got := count(f.do(http.MethodGet, "/libraries/", "alice", "")); got != 2 {
+		t.Errorf("alice's listing has %d libraries, want 2", got)
+	}
+	if got := count(f.do(http.MethodGet, "/libraries/", "bob", "")); got != 1 {
+		t.Errorf("bob's listing has %d libraries, want 1", got)
+	}
+}
+
+func TestUpdateAndDelete_OwnerOnly(t *testing.T) {
+	f := newFixture(t)
+	id := f.create(t, "alice", false)
+	update := fmt.Sprintf(libraryBody, "true")
+
+	if rec := f.do(http.MethodPut, "/libraries/"+id, "bob", update); rec.Code != http.StatusNotFound {
+		t.Errorf("update by other user status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := f.do(http.MethodPut, "/libraries/"+id, "alice", update); rec.Code != http.StatusNoContent {
+		t.Errorf("update by owner status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := f.do(http.MethodGet, "/libraries/"+id, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("library should be public after update, got %d", rec.Code)
+	}
+
+	if rec := f.do(http.MethodDelete, "/libraries/"+id, "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("delete by other user status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := f.do(http.MethodDelete, "/libraries/"+id, "alice", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete by owner status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := f.do(http.MethodGet, "/libraries/"+id, "alice", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted library status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
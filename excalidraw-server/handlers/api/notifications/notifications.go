@@ -0,0 +1,106 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/deadline"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+	// maxReadIDs bounds the notifications one read request marks by id.
+	maxReadIDs = 100
+	// maxRequestSize limits read request bodies.
+	maxRequestSize = 8 << 10
+)
+
+type (
+	// List is a page of the caller's notifications and how many of all of
+	// them are unread.
+	List struct {
+		Notifications []core.Notification `json:"notifications"`
+		Unread        int                 `json:"unread"`
+	}
+
+	// ReadRequest names the notifications to mark as read; no ids marks
+	// them all.
+	ReadRequest struct {
+		IDs []string `json:"ids"`
+	}
+
+	ReadResponse struct {
+		Marked int `json:"marked"`
+	}
+)
+
+// HandleList returns the caller's newest limit notifications (default 50, at
+// most 100), or only unread ones with unread=true.
+func HandleList(store core.NotificationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		limit := defaultPageSize
+		if value := query.Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxPageSize {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = parsed
+		}
+		unreadOnly := query.Get("unread") == "true"
+
+		claims := auth.ClaimsFromContext(r.Context())
+		notifications, err := store.ListNotifications(r.Context(), claims.Subject, unreadOnly, limit)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to list notifications")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list notifications")
+			return
+		}
+		unread, err := store.CountUnreadNotifications(r.Context(), claims.Subject)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to count unread notifications")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list notifications")
+			return
+		}
+		if notifications == nil {
+			notifications = []core.Notification{}
+		}
+		render.JSON(w, r, List{Notifications: notifications, Unread: unread})
+	}
+}
+
+// HandleMarkRead marks the caller's notifications in the request as read, or
+// all of them for an empty body or no ids, and returns how many were unread.
+func HandleMarkRead(store core.NotificationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ReadRequest
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+			return
+		}
+		if len(req.IDs) > maxReadIDs {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "At most 100 ids can be marked at once")
+			return
+		}
+
+		claims := auth.ClaimsFromContext(r.Context())
+		marked, err := store.MarkNotificationsRead(r.Context(), claims.Subject, req.IDs)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to mark notifications read")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to mark notifications read")
+			return
+		}
+		render.JSON(w, r, ReadResponse{Marked: marked})
+	}
+}
@@ -0,0 +1,132 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type fixture struct {
+	router *chi.Mux
+	store  core.NotificationStore
+	tokens map[string]string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	verifier := auth.NewVerifier([]byte("secret"))
+	store := memory.NewDocumentStore().(core.NotificationStore)
+
+	r := chi.NewRouter()
+	r.Route("/notifications", func(r chi.Router) {
+		r.Use(auth.Middleware(verifier, true))
+		r.Get("/", HandleList(store))
+		r.Post("/read", HandleMarkRead(store))
+	})
+
+	f := &fixture{router: r, store: store, tokens: make(map[string]string)}
+	for _, user := range []string{"alice", "bob"} {
+		token, err := verifier.Sign(&auth.Claims{Subject: user})
+		if err != nil {
+			t.Fatalf("Sign() failed: %v", err)
+		}
+		f.tokens[user] = token
+	}
+	return f
+}
+
+func (f *fixture) do(method, path, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+f.tokens[user])
+	}
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (f *fixture) add(t *testing.T, userID, text string) string {
+	t.Helper()
+	notification, err := f.store.AddNotification(context.Background(), core.Notification{
+		UserID: userID,
+		Kind:   core.NotificationMention,
+		RoomID: "room",
+		Text:   text,
+	})
+	if err != nil {
+		t.Fatalf("AddNotification() error = %v", err)
+	}
+	return notification.ID
+}
+
+func (f *fixture) list(t *testing.T, path, user string) List {
+	t.Helper()
+	rec := f.do(http.MethodGet, path, user, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d: %s", path, rec.Code, rec.Body.String())
+	}
+	var list List
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return list
+}
+
+func TestListAndMarkRead(t *testing.T) {
+	f := newFixture(t)
+	first := f.add(t, "alice", "@alice one")
+	f.add(t, "alice", "@alice two")
+	f.add(t, "bob", "@bob")
+
+	list := f.list(t, "/notifications/", "alice")
+	if len(list.Notifications) != 2 || list.Unread != 2 {
+		t.Fatalf("list = %+v, want 2 notifications, 2 unread", list)
+	}
+
+	rec := f.do(http.MethodPost, "/notifications/read", "alice", `{"ids":["`+first+`"]}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"marked":1`) {
+		t.Fatalf("mark one: %d %s", rec.Code, rec.Body.String())
+	}
+	list = f.list(t, "/notifications/?unread=true", "alice")
+	if len(list.Notifications) != 1 || list.Unread != 1 || list.Notifications[0].Text != "@alice two" {
+		t.Fatalf("unread list = %+v", list)
+	}
+
+	rec = f.do(http.MethodPost, "/notifications/read", "alice", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"marked":1`) {
+		t.Fatalf("mark all: %d %s", rec.Code, rec.Body.String())
+	}
+	if list := f.list(t, "/notifications/", "alice"); list.Unread != 0 || len(list.Notifications) != 2 {
+		t.Errorf("after marking all read = %+v", list)
+	}
+	if list := f.list(t, "/notifications/", "bob"); list.Unread != 1 {
+		t.Errorf("bob's unread = %d, want 1", list.Unread)
+	}
+}
+
+func TestListValidation(t *testing.T) {
+	f := newFixture(t)
+	if rec := f.do(http.MethodGet, "/notifications/", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous status = %d, want 401", rec.Code)
+	}
+	for _, path := range []string{"/notifications/?limit=0", "/notifications/?limit=101", "/notifications/?limit=x"} {
+		if rec := f.do(http.MethodGet, path, "alice", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s status = %d, want 400", path, rec.Code)
+		}
+	}
+	if rec := f.do(http.MethodPost, "/notifications/read", "alice", "{"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid body status = %d, want 400", rec.Code)
+	}
+	ids := strings.TrimSuffix(strings.Repeat(`"x",`, maxReadIDs+1), ",")
+	if rec := f.do(http.MethodPost, "/notifications/read", "alice", `{"ids":[`+ids+`]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("too many ids status = %d, want 400", rec.Code)
+	}
+}
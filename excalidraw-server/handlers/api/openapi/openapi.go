@@ -0,0 +1,331 @@
+// Package openapi describes the HTTP API as an OpenAPI 3 document, built
+// from the operations each handler package lists and the Go types of their
+// request and response bodies, and serves it with a Swagger UI page.
+package openapi
+
+import (
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Auth is how an operation authenticates its caller.
+type Auth int
+
+const (
+	// AuthNone is for public operations.
+	AuthNone Auth = iota
+	// AuthOptional operations accept a JWT bearer token and do more with
+	// one, such as saving to the caller's canvases.
+	AuthOptional
+	// AuthUser operations need a JWT bearer token.
+	AuthUser
+	// AuthAdmin operations need the ADMIN_TOKEN as a bearer token.
+	AuthAdmin
+)
+
+type (
+	// Operation describes one endpoint.
+	Operation struct {
+		Method string
+		// Path is relative to where the routes are mounted, with parameters
+		// in braces as in chi patterns
+		Path    string
+		Summary string
+		// Tag groups operations in the docs
+		Tag   string
+		Auth  Auth
+		Query []Param
+		// Request is a value of the JSON request body's type; RequestType
+		// names the media types of other bodies instead, separated by
+		// commas
+		Request     any
+		RequestType string
+		// Response is a value of the JSON response's type; ResponseType
+		// names the media types of other responses instead. Neither is set
+		// for empty responses.
+		Response     any
+		ResponseType string
+		// Status is the success status, 200 when zero
+		Status int
+		// Errors are the error statuses the operation responds with
+		Errors []int
+	}
+
+	// Param is a query parameter.
+	Param struct {
+		Name        string
+		Description string
+		// Type is a JSON schema type, string when empty
+		Type     string
+		Required bool
+	}
+
+	// Spec collects operations into an OpenAPI document.
+	Spec struct {
+		title   string
+		version string
+		// basePath prefixes every path, for servers behind a proxy
+		basePath string
+		mu       sync.Mutex
+		ops      []mounted
+	}
+
+	mounted struct {
+		path string
+		Operation
+	}
+)
+
+// NewSpec returns an empty spec for an API called title, served under
+// basePath, which may be empty.
+func NewSpec(title, version, basePath string) *Spec {
+	return &Spec{title: title, version: version, basePath: basePath}
+}
+
+// Add documents ops, mounted under prefix.
+func (s *Spec) Add(prefix string, ops ...Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, op := range ops {
+		path := prefix + op.Path
+		if op.Path == "/" {
+			path = prefix
+		}
+		s.ops = append(s.ops, mounted{path: path, Operation: op})
+	}
+}
+
+// pathParam matches chi's {name} and {name:regexp} parameters.
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Document returns the OpenAPI document.
+func (s *Spec) Document() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schemas := newSchemaSet()
+	paths := make(map[string]map[string]any)
+	tags := make(map[string]bool)
+	for _, op := range s.ops {
+		path := pathParam.ReplaceAllString(op.path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+
+		var params []map[string]any
+		for _, match := range pathParam.FindAllStringSubmatch(op.path, -1) {
+			params = append(params, map[string]any{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+		for _, param := range op.Query {
+			typ := param.Type
+			if typ == "" {
+				typ = "string"
+			}
+			p := map[string]any{
+				"name":   param.Name,
+				"in":     "query",
+				"schema": map[string]any{"type": typ},
+			}
+			if param.Description != "" {
+				p["description"] = param.Description
+			}
+			if param.Required {
+				p["required"] = true
+			}
+			params = append(params, p)
+		}
+
+		operation := map[string]any{
+			"summary":     op.Summary,
+			"operationId": operationID(op.Method, path),
+		}
+		if op.Tag != "" {
+			operation["tags"] = []string{op.Tag}
+			tags[op.Tag] = true
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if body := content(schemas, op.Request, op.RequestType); body != nil {
+			operation["requestBody"] = map[string]any{"required": true, "content": body}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		if body := content(schemas, op.Response, op.ResponseType); body != nil {
+			success["content"] = body
+		}
+		responses := map[string]any{strconv.Itoa(status): success}
+		errors := op.Errors
+		switch op.Auth {
+		case AuthUser, AuthAdmin:
+			errors = append([]int{http.StatusUnauthorized}, errors...)
+		}
+		for _, code := range errors {
+			responses[strconv.Itoa(code)] = map[string]any{
+				"description": http.StatusText(code),
+				"content":     map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}},
+			}
+		}
+		operation["responses"] = responses
+
+		switch op.Auth {
+		case AuthOptional:
+			operation["security"] = []map[string][]string{{}, {"bearerAuth": {}}}
+		case AuthUser:
+			operation["security"] = []map[string][]string{{"bearerAuth": {}}}
+		case AuthAdmin:
+			operation["security"] = []map[string][]string{{"adminToken": {}}}
+		}
+		paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	tagList := make([]map[string]string, 0, len(tags))
+	for tag := range tags {
+		tagList = append(tagList, map[string]string{"name": tag})
+	}
+	sort.Slice(tagList, func(i, j int) bool { return tagList[i]["name"] < tagList[j]["name"] })
+
+	server := s.basePath
+	if server == "" {
+		server = "/"
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": s.title, "version": s.version},
+		"servers": []map[string]string{{"url": server}},
+		"tags":    tagList,
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]string{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+					"description":  "A JWT signed with JWT_SECRET; its subject identifies the user",
+				},
+				"adminToken": map[string]string{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "The server's ADMIN_TOKEN",
+				},
+			},
+		},
+	}
+}
+
+// content returns the media types of a request or response body, or nil
+// for none.
+func content(schemas *schemaSet, value any, mediaType string) map[string]any {
+	switch {
+	case mediaType != "":
+		types := make(map[string]any)
+		for _, t := range strings.Split(mediaType, ",") {
+			t = strings.TrimSpace(t)
+			schema := map[string]any{"type": "string", "format": "binary"}
+			if strings.HasPrefix(t, "text/") {
+				schema = map[string]any{"type": "string"}
+			}
+			types[t] = map[string]any{"schema": schema}
+		}
+		return types
+	case value != nil:
+		return map[string]any{"application/json": map[string]any{"schema": schemas.of(value)}}
+	}
+	return nil
+}
+
+// operationID makes an id like getApiV2KvKey from a method and path, for
+// naming methods in generated clients.
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	upper := true
+	for _, r := range path {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			if upper {
+				b.WriteString(strings.ToUpper(string(r)))
+			} else {
+				b.WriteRune(r)
+			}
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	return b.String()
+}
+
+// Handle serves the spec as JSON. It is built on the first request, after
+// every route has been added.
+func Handle(spec *Spec) http.HandlerFunc {
+	var (
+		once sync.Once
+		data []byte
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			data, err = json.Marshal(spec.Document())
+		})
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to build OpenAPI document")
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to build OpenAPI document")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// uiPage loads Swagger UI from a CDN and points it at the spec.
+const uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>API docs</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.onload = function () {
+  window.ui = SwaggerUIBundle({url: %s, dom_id: "#swagger-ui"});
+};
+</script>
+</body>
+</html>
+`
+
+// HandleUI serves a Swagger UI page for the spec at specURL, which may be
+// relative to the page.
+func HandleUI(specURL string) http.HandlerFunc {
+	// json.Marshal escapes <, > and &, so the URL can't close the script
+	quoted, _ := json.Marshal(specURL)
+	page := []byte(fmt.Sprintf(uiPage, quoted))
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(page)
+	}
+}
@@ -0,0 +1,116 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type (
+	testMeta struct {
+		Name string   `json:"name,omitempty"`
+		Tags []string `json:"tags"`
+	}
+
+	testItem struct {
+		Key    string    `json:"key"`
+		Secret string    `json:"-"`
+		Parent *testItem `json:"parent"`
+		Data   []byte    `json:"data"`
+		Counts map[string]int64
+		Nested []testItem `json:"nested"`
+		testMeta
+	}
+)
+
+func TestDocument(t *testing.T) {
+	spec := NewSpec("Test API", "2", "")
+	spec.Add("/api/v2/kv",
+		Operation{Method: http.MethodGet, Path: "/", Summary: "List", Tag: "items", Auth: AuthUser, Response: []testItem{}},
+		Operation{
+			Method: http.MethodPut, Path: "/{key:[a-z]+}", Summary: "Save", Tag: "items",
+			Query:       []Param{{Name: "limit", Type: "integer", Required: true}},
+			RequestType: "application/json, application/octet-stream",
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusBadRequest},
+		},
+	)
+	spec.Add("/api/admin", Operation{Method: http.MethodPatch, Path: "/{key}", Auth: AuthAdmin, Request: testMeta{}})
+
+	data, err := json.Marshal(spec.Document())
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	var doc struct {
+		OpenAPI    string
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	if _, ok := doc.Paths["/api/v2/kv"]["get"]; !ok {
+		t.Errorf("paths = %v, want the root route at the prefix", doc.Paths)
+	}
+	put := string(doc.Paths["/api/v2/kv/{key}"]["put"])
+	for _, want := range []string{`"in":"path"`, `"name":"key"`, `"name":"limit"`, `"204"`, `"400"`, `"application/octet-stream"`, `"operationId":"putApiV2KvKey"`} {
+		if !strings.Contains(put, want) {
+			t.Errorf("put = %s, want %s", put, want)
+		}
+	}
+	if patch := string(doc.Paths["/api/admin/{key}"]["patch"]); !strings.Contains(patch, `"adminToken"`) || !strings.Contains(patch, `"401"`) {
+		t.Errorf("patch = %s, want the admin token required", patch)
+	}
+
+	item, ok := doc.Components.Schemas["testItem"]
+	if !ok {
+		t.Fatalf("schemas = %v, want testItem", doc.Components.Schemas)
+	}
+	for _, name := range []string{"key", "parent", "data", "Counts", "nested", "name", "tags"} {
+		if _, ok := item.Properties[name]; !ok {
+			t.Errorf("testItem properties = %v, want %s", item.Properties, name)
+		}
+	}
+	for _, name := range []string{"Secret", "-", "testMeta"} {
+		if _, ok := item.Properties[name]; ok {
+			t.Errorf("testItem properties = %v, want no %s", item.Properties, name)
+		}
+	}
+	if item.Properties["data"]["format"] != "byte" || item.Properties["Counts"]["type"] != "object" {
+		t.Errorf("data = %v, Counts = %v", item.Properties["data"], item.Properties["Counts"])
+	}
+	if items, _ := item.Properties["nested"]["items"].(map[string]any); items["$ref"] != "#/components/schemas/testItem" {
+		t.Errorf("nested = %v, want a reference to testItem", item.Properties["nested"])
+	}
+}
+
+func TestHandle(t *testing.T) {
+	spec := NewSpec("Test API", "2", "")
+	handler := Handle(spec)
+	// Operations added after the handler is made are still served
+	spec.Add("/api", Operation{Method: http.MethodGet, Path: "/rooms", Summary: "List rooms"})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status = %d, content type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `"/api/rooms"`) {
+		t.Errorf("body = %s, want the rooms route", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	HandleUI("/api/openapi.json")(w, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	if !strings.Contains(w.Body.String(), `url: "/api/openapi.json"`) {
+		t.Errorf("page = %s, want the spec URL", w.Body.String())
+	}
+}
@@ -0,0 +1,310 @@
+package orgs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/apiversion"
+	"excalidraw-server/handlers/api/canvases"
+	"excalidraw-server/handlers/api/deadline"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxNameLength limits organization names, in characters.
+	maxNameLength = 100
+	// maxRequestSize limits organization and membership request bodies.
+	maxRequestSize = 4 << 10
+)
+
+type (
+	OrgRequest struct {
+		Name string `json:"name"`
+	}
+
+	MemberRequest struct {
+		Role core.OrgRole `json:"role"`
+	}
+)
+
+// CanvasOwner is the canvas owner id under which an organization's shared
+// canvases are stored, kept apart from its members' own canvases.
+func CanvasOwner(orgID string) string {
+	return "org:" + orgID
+}
+
+type memberKey struct{}
+
+// memberFromContext returns the caller's membership stored by RequireMember.
+func memberFromContext(ctx context.Context) *core.OrgMember {
+	member, _ := ctx.Value(memberKey{}).(*core.OrgMember)
+	return member
+}
+
+// RequireMember loads the caller's membership of the organization in the
+// URL. Non-members get 404 so organization ids don't leak. Canvas handlers
+// behind it use the organization's shared canvases.
+func RequireMember(store core.OrgStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			orgID := chi.URLParam(r, "orgId")
+			claims := auth.ClaimsFromContext(r.Context())
+
+			member, err := store.GetOrgMember(r.Context(), orgID, claims.Subject)
+			if err != nil {
+				if !errors.Is(err, core.ErrNotMember) {
+					logrus.WithField("error", err).Error("Failed to get organization member")
+				}
+				apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.OrgNotFound, "Organization not found")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), memberKey{}, member)
+			ctx = canvases.WithOwner(ctx, CanvasOwner(orgID))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requireAdmin writes 403 unless the caller administers the organization.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if member := memberFromContext(r.Context()); member == nil || member.Role != core.OrgRoleAdmin {
+		apierror.Write(w, http.StatusForbidden, apierror.Forbidden, "Organization admin required")
+		return false
+	}
+	return true
+}
+
+// targetUser returns the user id in the URL; subjects from some identity
+// providers contain characters that arrive percent-encoded.
+func targetUser(r *http.Request) string {
+	userID := chi.URLParam(r, "userId")
+	if unescaped, err := url.PathUnescape(userID); err == nil {
+		return unescaped
+	}
+	return userID
+}
+
+// HandleCreate creates an organization with the caller as its admin
+func HandleCreate(store core.OrgStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req OrgRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Name must be 1-100 characters")
+			return
+		}
+
+		claims := auth.ClaimsFromContext(r.Context())
+		org, err := store.CreateOrg(r.Context(), name, claims.Subject)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to create organization")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to create organization")
+			return
+		}
+		org.Role = core.OrgRoleAdmin
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, org)
+	}
+}
+
+// HandleList lists the organizations the caller belongs to
+func HandleList(store core.OrgStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := auth.ClaimsFromContext(r.Context())
+		orgs, err := store.ListOrgs(r.Context(), claims.Subject)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to list organizations")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list organizations")
+			return
+		}
+		apiversion.RenderList(w, r, orgs)
+	}
+}
+
+// HandleGet returns an organization with the caller's role
+func HandleGet(store core.OrgStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, err := store.GetOrg(r.Context(), chi.URLParam(r, "orgId"))
+		if err != nil {
+			if !errors.Is(err, core.ErrOrgNotFound) {
+				logrus.WithField("error", err).Error("Failed to get organization")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.OrgNotFound, "Organization not found")
+			return
+		}
+		org.Role = memberFromContext(r.Context()).Role
+		render.JSON(w, r, org)
+	}
+}
+
+// HandleDelete deletes an organization, its memberships and, when
+// canvasStore is set, its shared canvases. Admins only.
+func HandleDelete(store core.OrgStore, canvasStore core.CanvasStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		orgID := chi.URLParam(r, "orgId")
+
+		if canvasStore != nil {
+			shared, err := canvasStore.ListCanvases(r.Context(), CanvasOwner(orgID))
+			if err != nil {
+				logrus.WithField("error", err).Error("Failed to list organization canvases")
+				apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to delete organization")
+				return
+			}
+			for _, canvas := range shared {
+				err := canvasStore.DeleteCanvas(r.Context(), CanvasOwner(orgID), canvas.Key)
+				if err != nil && !errors.Is(err, core.ErrCanvasNotFound) {
+					logrus.WithField("error", err).Error("Failed to delete organization canvas")
+					apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to delete organization")
+					return
+				}
+			}
+		}
+
+		if err := store.DeleteOrg(r.Context(), orgID); err != nil {
+			if !errors.Is(err, core.ErrOrgNotFound) {
+				logrus.WithField("error", err).Error("Failed to delete organization")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.OrgNotFound, "Organization not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleListMembers lists an organization's members
+func HandleListMembers(store core.OrgStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		members, err := store.ListOrgMembers(r.Context(), chi.URLParam(r, "orgId"))
+		if err != nil {
+			if !errors.Is(err, core.ErrOrgNotFound) {
+				logrus.WithField("error", err).Error("Failed to list organization members")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.OrgNotFound, "Organization not found")
+			return
+		}
+		apiversion.RenderList(w, r, members)
+	}
+}
+
+// isLastAdmin reports whether userID is the organization's only admin.
+func isLastAdmin(ctx context.Context, store core.OrgStore, orgID, userID string) (bool, error) {
+	members, err := store.ListOrgMembers(ctx, orgID)
+	if err != nil {
+		return false, err
+	}
+	admins, isAdmin := 0, false
+	for _, member := range members {
+		if member.Role == core.OrgRoleAdmin {
+			admins++
+			isAdmin = isAdmin || member.UserID == userID
+		}
+	}
+	return isAdmin && admins == 1, nil
+}
+
+// HandlePutMember adds a user to the organization or changes their role.
+// Admins only; the last admin can't be demoted.
+func HandlePutMember(store core.OrgStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		orgID := chi.URLParam(r, "orgId")
+		userID := targetUser(r)
+		if userID == "" {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid user id")
+			return
+		}
+
+		var req MemberRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+			return
+		}
+		if req.Role != core.OrgRoleAdmin && req.Role != core.OrgRoleMember {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Role must be admin or member")
+			return
+		}
+
+		if req.Role != core.OrgRoleAdmin {
+			last, err := isLastAdmin(r.Context(), store, orgID, userID)
+			if err != nil {
+				logrus.WithField("error", err).Error("Failed to list organization members")
+				apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save member")
+				return
+			}
+			if last {
+				apierror.Write(w, http.StatusConflict, apierror.LastAdmin, "Organization needs at least one admin")
+				return
+			}
+		}
+
+		err := store.PutOrgMember(r.Context(), orgID, core.OrgMember{UserID: userID, Role: req.Role})
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to save organization member")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save member")
+			return
+		}
+
+		member, err := store.GetOrgMember(r.Context(), orgID, userID)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to get organization member")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to save member")
+			return
+		}
+		render.JSON(w, r, member)
+	}
+}
+
+// HandleRemoveMember removes a user from the organization. Admins can
+// remove anyone and members can leave; the last admin can't.
+func HandleRemoveMember(store core.OrgStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID := chi.URLParam(r, "orgId")
+		userID := targetUser(r)
+		if userID != memberFromContext(r.Context()).UserID && !requireAdmin(w, r) {
+			return
+		}
+
+		last, err := isLastAdmin(r.Context(), store, orgID, userID)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to list organization members")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to remove member")
+			return
+		}
+		if last {
+			apierror.Write(w, http.StatusConflict, apierror.LastAdmin, "Organization needs at least one admin")
+			return
+		}
+
+		if err := store.RemoveOrgMember(r.Context(), orgID, userID); err != nil {
+			if !errors.Is(err, core.ErrNotMember) {
+				logrus.WithField("error", err).Error("Failed to remove organization member")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.MemberNotFound, "Member not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
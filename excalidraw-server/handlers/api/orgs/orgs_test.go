@@ -0,0 +1,205 @@
+package orgs
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/canvases"
+	"excalidraw-server/stores/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type fixture struct {
+	router   *chi.Mux
+	canvases core.CanvasStore
+	tokens   map[string]string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	verifier := auth.NewVerifier([]byte("secret"))
+	documentStore := memory.NewDocumentStore()
+	store := documentStore.(core.OrgStore)
+	canvasStore := documentStore.(core.CanvasStore)
+
+	r := chi.NewRouter()
+	r.Route("/orgs", func(r chi.Router) {
+		r.Use(auth.Middleware(verifier, true))
+		r.Get("/", HandleList(store))
+		r.Post("/", HandleCreate(store))
+		r.Route("/{orgId}", func(r chi.Router) {
+			r.Use(RequireMember(store))
+			r.Get("/", HandleGet(store))
+			r.Delete("/", HandleDelete(store, canvasStore))
+			r.Get("/members", HandleListMembers(store))
+			r.Put("/members/{userId}", HandlePutMember(store))
+			r.Delete("/members/{userId}", HandleRemoveMember(store))
+			r.Get("/kv/{key}", canvases.HandleGet(canvasStore))
+			r.Put("/kv/{key}", canvases.HandlePut(canvasStore))
+		})
+	})
+
+	f := &fixture{router: r, canvases: canvasStore, tokens: make(map[string]string)}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		token, err := verifier.Sign(&auth.Claims{Subject: user})
+		if err != nil {
+			t.Fatalf("Sign() failed: %v", err)
+		}
+		f.tokens[user] = token
+	}
+	return f
+}
+
+func (f *fixture) do(method, path, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+f.tokens[user])
+	}
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// createOrg creates an organization administered by alice with bob as a
+// member and returns its id.
+func (f *fixture) createOrg(t *testing.T) string {
+	t.Helper()
+	rec := f.do(http.MethodPost, "/orgs/", "alice", `{"name":" Design "}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var org core.Org
+	if err := json.Unmarshal(rec.Body.Bytes(), &org); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if org.Name != "Design" || org.Role != core.OrgRoleAdmin {
+		t.Fatalf("created org = %+v", org)
+	}
+
+	if rec := f.do(http.MethodPut, "/orgs/"+org.ID+"/members/bob", "alice", `{"role":"member"}`); rec.Code != http.StatusOK {
+		t.Fatalf("add member status = %d: %s", rec.Code, rec.Body.String())
+	}
+	return org.ID
+}
+
+func TestCreateAndList(t *testing.T) {
+	f := newFixture(t)
+	orgID := f.createOrg(t)
+
+	rec := f.do(http.MethodGet, "/orgs/", "bob", "")
+	var orgs []core.Org
+	if err := json.Unmarshal(rec.Body.Bytes(), &orgs); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(orgs) != 1 || orgs[0].ID != orgID || orgs[0].Role != core.OrgRoleMember {
+		t.Errorf("bob's orgs = %+v", orgs)
+	}
+
+	if rec := f.do(http.MethodGet, "/orgs/", "carol", ""); rec.Body.String() != "[]\n" {
+		t.Errorf("carol's orgs = %q, want []", rec.Body.String())
+	}
+
+	if rec := f.do(http.MethodPost, "/orgs/", "alice", `{"name":"  "}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("blank name status = %d, want 400", rec.Code)
+	}
+	if rec := f.do(http.MethodPost, "/orgs/", "", `{"name":"x"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous create status = %d, want 401", rec.Code)
+	}
+}
+
+func TestNonMembersCannotSeeOrg(t *testing.T) {
+	f := newFixture(t)
+	orgID := f.createOrg(t)
+
+	for _, path := range []string{"/orgs/" + orgID + "/", "/orgs/" + orgID + "/members", "/orgs/" + orgID + "/kv/plan"} {
+		if rec := f.do(http.MethodGet, path, "carol", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s as a non-member status = %d, want 404", path, rec.Code)
+		}
+	}
+	if rec := f.do(http.MethodGet, "/orgs/"+orgID+"/", "bob", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET org as a member status = %d, want 200", rec.Code)
+	}
+}
+
+func TestMembership(t *testing.T) {
+	f := newFixture(t)
+	orgID := f.createOrg(t)
+
+	// Members can't manage membership
+	if rec := f.do(http.MethodPut, "/orgs/"+orgID+"/members/carol", "bob", `{"role":"member"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("member adding a user status = %d, want 403", rec.Code)
+	}
+	if rec := f.do(http.MethodPut, "/orgs/"+orgID+"/members/carol", "alice", `{"role":"owner"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown role status = %d, want 400", rec.Code)
+	}
+
+	// The last admin can't be demoted or removed
+	if rec := f.do(http.MethodPut, "/orgs/"+orgID+"/members/alice", "alice", `{"role":"member"}`); rec.Code != http.StatusConflict {
+		t.Errorf("demoting the last admin status = %d, want 409", rec.Code)
+	}
+	if rec := f.do(http.MethodDelete, "/orgs/"+orgID+"/members/alice", "alice", ""); rec.Code != http.StatusConflict {
+		t.Errorf("removing the last admin status = %d, want 409", rec.Code)
+	}
+
+	// Members can leave, but not remove others
+	if rec := f.do(http.MethodDelete, "/orgs/"+orgID+"/members/alice", "bob", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("member removing an admin status = %d, want 403", rec.Code)
+	}
+	if rec := f.do(http.MethodDelete, "/orgs/"+orgID+"/members/bob", "bob", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("leaving status = %d, want 204", rec.Code)
+	}
+	if rec := f.do(http.MethodGet, "/orgs/"+orgID+"/", "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET org after leaving status = %d, want 404", rec.Code)
+	}
+
+	// With a second admin the first can step down
+	f.do(http.MethodPut, "/orgs/"+orgID+"/members/carol", "alice", `{"role":"admin"}`)
+	if rec := f.do(http.MethodPut, "/orgs/"+orgID+"/members/alice", "alice", `{"role":"member"}`); rec.Code != http.StatusOK {
+		t.Errorf("demoting one of two admins status = %d, want 200", rec.Code)
+	}
+
+	rec := f.do(http.MethodGet, "/orgs/"+orgID+"/members", "alice", "")
+	var members []core.OrgMember
+	if err := json.Unmarshal(rec.Body.Bytes(), &members); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(members) != 2 || members[0].UserID != "alice" || members[0].Role != core.OrgRoleMember ||
+		members[1].UserID != "carol" || members[1].Role != core.OrgRoleAdmin {
+		t.Errorf("members = %+v", members)
+	}
+}
+
+func TestSharedCanvases(t *testing.T) {
+	f := newFixture(t)
+	orgID := f.createOrg(t)
+
+	if rec := f.do(http.MethodPut, "/orgs/"+orgID+"/kv/plan", "bob", `{"v":1}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("put status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := f.do(http.MethodGet, "/orgs/"+orgID+"/kv/plan", "alice", ""); rec.Body.String() != `{"v":1}` {
+		t.Errorf("alice got %q, want bob's shared canvas", rec.Body.String())
+	}
+	if own, _ := f.canvases.ListCanvases(context.Background(), "bob"); len(own) != 0 {
+		t.Errorf("shared canvas leaked into bob's own canvases: %+v", own)
+	}
+
+	// Deleting the organization deletes its canvases; admins only
+	if rec := f.do(http.MethodDelete, "/orgs/"+orgID+"/", "bob", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("member delete status = %d, want 403", rec.Code)
+	}
+	if rec := f.do(http.MethodDelete, "/orgs/"+orgID+"/", "alice", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if shared, _ := f.canvases.ListCanvases(context.Background(), CanvasOwner(orgID)); len(shared) != 0 {
+		t.Errorf("canvases left after delete: %+v", shared)
+	}
+	if rec := f.do(http.MethodGet, "/orgs/"+orgID+"/", "alice", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET deleted org status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package recordings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/deadline"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+// maxPlaybackSpeed bounds the speed query parameter of playback.
+const maxPlaybackSpeed = 100
+
+// RecordingResponse identifies a started or stopped recording.
+type RecordingResponse struct {
+	ID string `json:"id"`
+}
+
+// RoomFunc starts or stops the recording of a live room.
+type RoomFunc func(ctx context.Context, roomID string) (string, error)
+
+// HandleStart starts recording a room
+func HandleStart(start RoomFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+
+		id, err := start(r.Context(), roomID)
+		if err != nil {
+			if errors.Is(err, core.ErrRecordingActive) {
+				apierror.Write(w, http.StatusConflict, apierror.RecordingActive, "Room is already being recorded")
+				return
+			}
+			logrus.WithField("room_id", roomID).WithField("error", err).Error("Failed to start recording")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to start recording")
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, RecordingResponse{ID: id})
+	}
+}
+
+// HandleStop stops the recording in progress in a room
+func HandleStop(stop RoomFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+
+		id, err := stop(r.Context(), roomID)
+		if err != nil {
+			if errors.Is(err, core.ErrNotRecording) {
+				apierror.Write(w, http.StatusNotFound, apierror.RecordingNotFound, "Room is not being recorded")
+				return
+			}
+			logrus.WithField("room_id", roomID).WithField("error", err).Error("Failed to stop recording")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to stop recording")
+			return
+		}
+
+		render.JSON(w, r, RecordingResponse{ID: id})
+	}
+}
+
+// HandleList lists the recordings of a room, newest first
+func HandleList(store core.RecordingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+
+		recordings, err := store.ListRecordings(r.Context(), roomID)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to list recordings")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list recordings")
+			return
+		}
+		if recordings == nil {
+			recordings = []core.Recording{}
+		}
+
+		render.JSON(w, r, recordings)
+	}
+}
+
+// HandleGet returns a recording's metadata
+func HandleGet(store core.RecordingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recording, err := store.GetRecording(r.Context(), chi.URLParam(r, "recordingId"))
+		if err != nil {
+			if !errors.Is(err, core.ErrRecordingNotFound) {
+				logrus.WithField("error", err).Error("Failed to get recording")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.RecordingNotFound, "Recording not found")
+			return
+		}
+
+		render.JSON(w, r, recording)
+	}
+}
+
+// HandlePlayback streams a recording's events as newline-delimited JSON,
+// each written when its offset is reached. The speed query parameter
+// scales the original timing; speed=0 sends every event at once.
+func HandlePlayback(store core.RecordingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		speed := 1.0
+		if value := r.URL.Query().Get("speed"); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 || parsed > maxPlaybackSpeed {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid speed")
+				return
+			}
+			speed = parsed
+		}
+
+		events, err := store.GetRecordingEvents(r.Context(), chi.URLParam(r, "recordingId"))
+		if err != nil {
+			if !errors.Is(err, core.ErrRecordingNotFound) {
+				logrus.WithField("error", err).Error("Failed to get recording events")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.RecordingNotFound, "Recording not found")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Cache-Control", "no-cache")
+		flusher, _ := w.(http.Flusher)
+		encoder := json.NewEncoder(w)
+
+		start := time.Now()
+		for _, event := range events {
+			if speed > 0 {
+				due := start.Add(time.Duration(float64(event.Offset) * float64(time.Millisecond) / speed))
+				timer := time.NewTimer(time.Until(due))
+				select {
+				case <-r.Context().Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package recordings
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newRouter(store core.RecordingStore, start, stop RoomFunc) *chi.Mux {
+	r := chi.NewRouter()
+	r.Route("/api/rooms/{roomId}/recordings", func(r chi.Router) {
+		r.Get("/", HandleList(store))
+		r.Post("/", HandleStart(start))
+		r.Post("/stop", HandleStop(stop))
+	})
+	r.Route("/api/recordings/{recordingId}", func(r chi.Router) {
+		r.Get("/", HandleGet(store))
+		r.Get("/playback", HandlePlayback(store))
+	})
+	return r
+}
+
+func newRecording(t *testing.T, store core.RecordingStore, offsets ...int64) string {
+	t.Helper()
+	ctx := context.Background()
+	id, err := store.CreateRecording(ctx, "room-1", time.Now())
+	if err != nil {
+		t.Fatalf("CreateRecording() failed: %v", err)
+	}
+	for _, offset := range offsets {
+		event := core.RecordingEvent{Offset: offset, SocketID: "socket-a", Payload: json.RawMessage(`{"type":"SCENE_UPDATE"}`)}
+		if err := store.AppendRecordingEvent(ctx, id, event); err != nil {
+			t.Fatalf("AppendRecordingEvent() failed: %v", err)
+		}
+	}
+	return id
+}
+
+func TestStartStop(t *testing.T) {
+	store := memory.NewDocumentStore().(core.RecordingStore)
+	recording := ""
+	start := func(ctx context.Context, roomID string) (string, error) {
+		if recording != "" {
+			return "", core.ErrRecordingActive
+		}
+		recording = "rec-" + roomID
+		return recording, nil
+	}
+	stop := func(ctx context.Context, roomID string) (string, error) {
+		if recording == "" {
+			return "", core.ErrNotRecording
+		}
+		id := recording
+		recording = ""
+		return id, nil
+	}
+	router := newRouter(store, start, stop)
+
+	steps := []struct {
+		path string
+		want int
+	}{
+		{"/api/rooms/room-1/recordings/", http.StatusCreated},
+		{"/api/rooms/room-1/recordings/", http.StatusConflict},
+		{"/api/rooms/room-1/recordings/stop", http.StatusOK},
+		{"/api/rooms/room-1/recordings/stop", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, step.path, nil))
+		if w.Code != step.want {
+			t.Errorf("POST %s = %d, want %d", step.path, w.Code, step.want)
+		}
+	}
+}
+
+func TestHandleList(t *testing.T) {
+	store := memory.NewDocumentStore().(core.RecordingStore)
+	id := newRecording(t, store)
+	router := newRouter(store, nil, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/room-1/recordings/", nil))
+	var listed []core.Recording
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	if len(listed) != 1 || listed[0].ID != id {
+		t.Errorf("list = %+v", listed)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/room-2/recordings/", nil))
+	if w.Body.String() != "[]\n" {
+		t.Errorf("empty list = %q, want []", w.Body.String())
+	}
+}
+
+func TestHandlePlayback(t *testing.T) {
+	store := memory.NewDocumentStore().(core.RecordingStore)
+	id := newRecording(t, store, 0, 100, 200)
+	router := newRouter(store, nil, nil)
+
+	began := time.Now()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/recordings/"+id+"/playback?speed=4", nil))
+	elapsed := time.Since(began)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("playback = %d, want 200", w.Code)
+	}
+	if elapsed < 50*time.Millisecond {
+		t.Errorf("playback took %v, want at least 50ms at 4x speed", elapsed)
+	}
+	var offsets []int64
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var event core.RecordingEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		offsets = append(offsets, event.Offset)
+	}
+	if len(offsets) != 3 || offsets[2] != 200 {
+		t.Errorf("offsets = %v", offsets)
+	}
+}
+
+func TestHandlePlayback_Errors(t *testing.T) {
+	store := memory.NewDocumentStore().(core.RecordingStore)
+	id := newRecording(t, store)
+	router := newRouter(store, nil, nil)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/recordings/missing/playback", http.StatusNotFound},
+		{"/api/recordings/" + id + "/playback?speed=fast", http.StatusBadRequest},
+		{"/api/recordings/" + id + "/playback?speed=-1", http.StatusBadRequest},
+		{"/api/recordings/missing/", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, w.Code, tt.want)
+		}
+	}
+}
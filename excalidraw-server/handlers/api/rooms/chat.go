@@ -0,0 +1,156 @@
+package rooms
+
+import (
+	"context"
+	"crypto/subtle"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/websocket"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultChatPageSize = 50
+	maxChatPageSize     = 1000
+)
+
+type (
+	// ChatPage is a page of a room's chat history, oldest first.
+	ChatPage struct {
+		Messages []websocket.ChatMessage `json:"messages"`
+		// HasMore is set when older messages remain; pass the timestamp
+		// of the first message as before to get them.
+		HasMore bool `json:"has_more"`
+	}
+
+	DeleteChatResponse struct {
+		Deleted int `json:"deleted"`
+	}
+
+	// ChatAccess decides whether a request may read a room's chat or, with
+	// moderate, delete from it.
+	ChatAccess func(r *http.Request, roomID string, moderate bool) (bool, error)
+)
+
+// NewChatAccess lets requests with the admin token do anything, moderators
+// delete messages and anyone who may join a room read its chat. Users are
+// identified by the claims auth.Middleware put in the request.
+func NewChatAccess(adminToken string, canRead, isModerator func(ctx context.Context, roomID, userID string) (bool, error)) ChatAccess {
+	return func(r *http.Request, roomID string, moderate bool) (bool, error) {
+		if given := auth.TokenFromRequest(r); adminToken != "" && subtle.ConstantTimeCompare([]byte(given), []byte(adminToken)) == 1 {
+			return true, nil
+		}
+		userID := ""
+		if claims := auth.ClaimsFromContext(r.Context()); claims != nil {
+			userID = claims.Subject
+		}
+		if moderate {
+			return isModerator(r.Context(), roomID, userID)
+		}
+		return canRead(r.Context(), roomID, userID)
+	}
+}
+
+// checkChatAccess writes an error and returns false unless the request may
+// use the room's chat.
+func checkChatAccess(access ChatAccess, w http.ResponseWriter, r *http.Request, roomID string, moderate bool) bool {
+	allowed, err := access(r, roomID, moderate)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to check chat access")
+		apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to check room permissions")
+		return false
+	}
+	if allowed {
+		return true
+	}
+	if auth.ClaimsFromContext(r.Context()) == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		apierror.Write(w, http.StatusUnauthorized, apierror.Unauthorized, "Authentication required")
+		return false
+	}
+	apierror.Write(w, http.StatusForbidden, apierror.NotAllowedInRoom, "Not allowed in room")
+	return false
+}
+
+// HandleGetChat returns a page of a room's chat history: the newest limit
+// messages (default 50, at most 1000) sent before the before timestamp in
+// Unix milliseconds, or the newest ones without it.
+func HandleGetChat(history func(ctx context.Context, roomID string) ([]websocket.ChatMessage, error), access ChatAccess) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+		query := r.URL.Query()
+
+		limit := defaultChatPageSize
+		if value := query.Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxChatPageSize {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "limit must be between 1 and 1000")
+				return
+			}
+			limit = parsed
+		}
+		var before int64
+		if value := query.Get("before"); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed <= 0 {
+				apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "before must be a timestamp in milliseconds")
+				return
+			}
+			before = parsed
+		}
+
+		if !checkChatAccess(access, w, r, roomID, false) {
+			return
+		}
+		messages, err := history(r.Context(), roomID)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to get chat history")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to get chat history")
+			return
+		}
+
+		end := len(messages)
+		if before > 0 {
+			for end > 0 && messages[end-1].Timestamp >= before {
+				end--
+			}
+		}
+		start := max(end-limit, 0)
+		page := ChatPage{Messages: append([]websocket.ChatMessage{}, messages[start:end]...), HasMore: start > 0}
+		render.JSON(w, r, page)
+	}
+}
+
+// HandleDeleteChat deletes the message in the messageId URL parameter from a
+// room's chat history, or the whole history without one. Only moderators may.
+func HandleDeleteChat(deleteChat func(ctx context.Context, roomID, messageID string) (int, error), access ChatAccess) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID, messageID := chi.URLParam(r, "roomId"), chi.URLParam(r, "messageId")
+		if !checkChatAccess(access, w, r, roomID, true) {
+			return
+		}
+
+		deleted, err := deleteChat(r.Context(), roomID, messageID)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to delete chat messages")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to delete chat messages")
+			return
+		}
+		if messageID != "" && deleted == 0 {
+			apierror.Write(w, http.StatusNotFound, apierror.MessageNotFound, "Message not found")
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"room_id": roomID,
+			"deleted": deleted,
+		}).Info("Chat messages deleted")
+		render.JSON(w, r, DeleteChatResponse{Deleted: deleted})
+	}
+}
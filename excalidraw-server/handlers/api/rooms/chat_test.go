@@ -0,0 +1,150 @@
+package rooms
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/handlers/websocket"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newChatRouter(t *testing.T, verifier *auth.Verifier, messages *[]websocket.ChatMessage) *chi.Mux {
+	t.Helper()
+	history := func(_ context.Context, roomID string) ([]websocket.ChatMessage, error) {
+		if roomID != "room-1" {
+			return []websocket.ChatMessage{}, nil
+		}
+		return *messages, nil
+	}
+	deleteChat := func(_ context.Context, _ string, messageID string) (int, error) {
+		kept := []websocket.ChatMessage{}
+		for _, message := range *messages {
+			if messageID != "" && message.ID != messageID {
+				kept = append(kept, message)
+			}
+		}
+		deleted := len(*messages) - len(kept)
+		*messages = kept
+		return deleted, nil
+	}
+	canRead := func(_ context.Context, roomID, userID string) (bool, error) {
+		return roomID != "private" || userID == "alice", nil
+	}
+	isModerator := func(_ context.Context, _ string, userID string) (bool, error) {
+		return userID == "alice", nil
+	}
+	access := NewChatAccess("admin-secret", canRead, isModerator)
+
+	r := chi.NewRouter()
+	r.Route("/api/rooms/{roomId}/chat", func(r chi.Router) {
+		r.Use(auth.Middleware(verifier, false))
+		r.Get("/", HandleGetChat(history, access))
+		r.Delete("/", HandleDeleteChat(deleteChat, access))
+		r.Delete("/{messageId}", HandleDeleteChat(deleteChat, access))
+	})
+	return r
+}
+
+func TestHandleGetChat(t *testing.T) {
+	messages := make([]websocket.ChatMessage, 0, 5)
+	for i := int64(1); i <= 5; i++ {
+		messages = append(messages, websocket.ChatMessage{ID: string(rune('a' + i - 1)), Timestamp: i * 1000})
+	}
+	router := newChatRouter(t, auth.NewVerifier([]byte("secret")), &messages)
+
+	get := func(url string) (*httptest.ResponseRecorder, ChatPage) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		var page ChatPage
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+			}
+		}
+		return w, page
+	}
+	ids := func(page ChatPage) string {
+		var ids string
+		for _, message := range page.Messages {
+			ids += message.ID
+		}
+		return ids
+	}
+
+	if _, page := get("/api/rooms/room-1/chat/"); ids(page) != "abcde" || page.HasMore {
+		t.Errorf("whole history = %q, has_more %v", ids(page), page.HasMore)
+	}
+	_, page := get("/api/rooms/room-1/chat/?limit=2")
+	if ids(page) != "de" || !page.HasMore {
+		t.Errorf("newest page = %q, has_more %v", ids(page), page.HasMore)
+	}
+	_, page = get("/api/rooms/room-1/chat/?limit=2&before=4000")
+	if ids(page) != "bc" || !page.HasMore {
+		t.Errorf("older page = %q, has_more %v", ids(page), page.HasMore)
+	}
+	_, page = get("/api/rooms/room-1/chat/?limit=2&before=2000")
+	if ids(page) != "a" || page.HasMore {
+		t.Errorf("oldest page = %q, has_more %v", ids(page), page.HasMore)
+	}
+	if _, page := get("/api/rooms/empty/chat/"); page.Messages == nil || len(page.Messages) != 0 {
+		t.Errorf("empty room messages = %v, want []", page.Messages)
+	}
+
+	for _, url := range []string{"/api/rooms/room-1/chat/?limit=0", "/api/rooms/room-1/chat/?limit=1001", "/api/rooms/room-1/chat/?before=soon"} {
+		if w, _ := get(url); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", url, w.Code)
+		}
+	}
+	if w, _ := get("/api/rooms/private/chat/"); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous GET of a private room = %d, want 401", w.Code)
+	}
+}
+
+func TestHandleDeleteChat(t *testing.T) {
+	verifier := auth.NewVerifier([]byte("secret"))
+	messages := []websocket.ChatMessage{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	router := newChatRouter(t, verifier, &messages)
+
+	del := func(url, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, url, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	token := func(user string) string {
+		signed, _ := verifier.Sign(&auth.Claims{Subject: user})
+		return signed
+	}
+
+	if w := del("/api/rooms/room-1/chat/a", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous DELETE = %d, want 401", w.Code)
+	}
+	if w := del("/api/rooms/room-1/chat/a", token("bob")); w.Code != http.StatusForbidden {
+		t.Errorf("DELETE by a member = %d, want 403", w.Code)
+	}
+
+	w := del("/api/rooms/room-1/chat/a", token("alice"))
+	var response DeleteChatResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); w.Code != http.StatusOK || err != nil || response.Deleted != 1 {
+		t.Fatalf("DELETE by the moderator = %d %s", w.Code, w.Body.String())
+	}
+	if w := del("/api/rooms/room-1/chat/a", token("alice")); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE of a deleted message = %d, want 404", w.Code)
+	}
+
+	// The admin token clears the whole history
+	w = del("/api/rooms/room-1/chat/", "admin-secret")
+	if err := json.Unmarshal(w.Body.Bytes(), &response); w.Code != http.StatusOK || err != nil || response.Deleted != 2 {
+		t.Fatalf("DELETE with the admin token = %d %s", w.Code, w.Body.String())
+	}
+	if len(messages) != 0 {
+		t.Errorf("messages left = %+v", messages)
+	}
+}
@@ -0,0 +1,98 @@
+package rooms
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/api/openapi"
+	"excalidraw-server/handlers/websocket"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// eventsKeepAlive is how often an idle event stream gets a comment, so
+// proxies don't close it.
+const eventsKeepAlive = 25 * time.Second
+
+// RoomEvents gives the event stream access to a room's state and events.
+type RoomEvents struct {
+	Presence  func(roomID string) []websocket.PresenceEntry
+	History   func(ctx context.Context, roomID string) ([]websocket.ChatMessage, error)
+	Subscribe func(roomID string) (<-chan websocket.RoomEvent, func())
+}
+
+// HandleEvents streams a room's presence and chat as server-sent events,
+// for clients that can't use Socket.IO. Events are named and shaped like
+// the socket events: the stream starts with room-presence and chat-history,
+// then sends room-presence, client-chat-message, client-chat-reaction,
+// chat-deleted and chat-cleared as they happen. It ends when the client
+// falls too far behind, so reconnecting starts over from the current
+// state. Anyone who may read the room's chat may follow it.
+func HandleEvents(events RoomEvents, access ChatAccess) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+		if !checkChatAccess(access, w, r, roomID, false) {
+			return
+		}
+
+		// Subscribe first, so nothing happens between the state and the
+		// first event
+		stream, unsubscribe := events.Subscribe(roomID)
+		defer unsubscribe()
+		history, err := events.History(r.Context(), roomID)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to get chat history")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to get chat history")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		rc := http.NewResponseController(w)
+		send := func(name string, data any) bool {
+			encoded, err := json.Marshal(data)
+			if err != nil {
+				logrus.WithField("event", name).WithError(err).Error("Failed to encode room event")
+				return true
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, encoded); err != nil {
+				return false
+			}
+			return rc.Flush() == nil
+		}
+
+		if !send("room-presence", events.Presence(roomID)) || !send("chat-history", history) {
+			return
+		}
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-stream:
+				if !ok || !send(event.Name, event.Data) {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil || rc.Flush() != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// EventsOperation documents the event stream relative to /api/rooms.
+var EventsOperation = openapi.Operation{
+	Method: http.MethodGet, Path: "/{roomId}/events", Tag: "rooms", Auth: openapi.AuthOptional,
+	Summary:      "Stream a room's presence and chat as server-sent events",
+	ResponseType: "text/event-stream",
+	Errors:       []int{http.StatusUnauthorized, http.StatusForbidden},
+}
@@ -0,0 +1,82 @@
+package rooms
+
+import (
+	"bufio"
+	"context"
+	"excalidraw-server/handlers/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandleEvents(t *testing.T) {
+	stream := make(chan websocket.RoomEvent, 1)
+	unsubscribed := make(chan struct{})
+	events := RoomEvents{
+		Presence: func(roomID string) []websocket.PresenceEntry {
+			return []websocket.PresenceEntry{{SocketID: "socket-a"}}
+		},
+		History: func(_ context.Context, roomID string) ([]websocket.ChatMessage, error) {
+			return []websocket.ChatMessage{{ID: "m1", Content: "hi"}}, nil
+		},
+		Subscribe: func(roomID string) (<-chan websocket.RoomEvent, func()) {
+			return stream, func() { close(unsubscribed) }
+		},
+	}
+	canRead := func(_ context.Context, roomID, userID string) (bool, error) {
+		return roomID != "private", nil
+	}
+	r := chi.NewRouter()
+	r.Get("/api/rooms/{roomId}/events", HandleEvents(events, NewChatAccess("", canRead, canRead)))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/rooms/private/events")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("private room = %d, want 401", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/api/rooms/room-1/events")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	// next reads an event's lines up to the blank line ending it
+	next := func() string {
+		var event []string
+		for lines.Scan() && lines.Text() != "" {
+			event = append(event, lines.Text())
+		}
+		return strings.Join(event, "\n")
+	}
+	if got, want := next(), `event: room-presence`+"\n"+`data: [{"socketId":"socket-a"}]`; got != want {
+		t.Errorf("first event = %q, want %q", got, want)
+	}
+	if got := next(); !strings.HasPrefix(got, "event: chat-history\ndata: [{\"id\":\"m1\"") {
+		t.Errorf("second event = %q, want the chat history", got)
+	}
+
+	stream <- websocket.RoomEvent{Name: "client-chat-message", Data: map[string]string{"id": "m2"}}
+	if got, want := next(), "event: client-chat-message\ndata: {\"id\":\"m2\"}"; got != want {
+		t.Errorf("live event = %q, want %q", got, want)
+	}
+
+	// A closed subscription ends the stream
+	close(stream)
+	if got := next(); got != "" || lines.Scan() {
+		t.Errorf("after the subscription closed got %q, want the end of the stream", got)
+	}
+	<-unsubscribed
+}
@@ -0,0 +1,120 @@
+package rooms
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/mail"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxInvitationMessage limits the note in an invitation, in characters.
+	maxInvitationMessage = 500
+	// maxInvitationSize limits the size of an invitation request body.
+	maxInvitationSize = 8 << 10
+)
+
+type (
+	// InvitationRequest invites someone to a room by email. URL is the room
+	// link to send, with its key, and must be on the server's public URL so
+	// invitations can't point anywhere else.
+	InvitationRequest struct {
+		Email   string `json:"email"`
+		URL     string `json:"url"`
+		Message string `json:"message"`
+	}
+
+	// InvitationOptions configures HandleInvite.
+	InvitationOptions struct {
+		Mailer mail.Mailer
+		// Instance names the server in the email
+		Instance string
+		// PublicURL is the server's external URL; invitation links must
+		// start with it
+		PublicURL string
+		// CanInvite reports whether a user may invite others to a room
+		CanInvite func(ctx context.Context, roomID, userID string) (bool, error)
+	}
+)
+
+// HandleInvite emails an invitation to a room to the address in the request.
+// Anyone who may join the room may invite others.
+func HandleInvite(opts InvitationOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+
+		var req InvitationRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxInvitationSize)).Decode(&req); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+			return
+		}
+		address, err := netmail.ParseAddress(strings.TrimSpace(req.Email))
+		if err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid email address")
+			return
+		}
+		if !onPublicURL(req.URL, opts.PublicURL) {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "url must be a link on "+opts.PublicURL)
+			return
+		}
+		message := strings.TrimSpace(req.Message)
+		if utf8.RuneCountInString(message) > maxInvitationMessage {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Message must be at most 500 characters")
+			return
+		}
+
+		claims := auth.ClaimsFromContext(r.Context())
+		allowed, err := opts.CanInvite(r.Context(), roomID, claims.Subject)
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to check room permissions")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to check room permissions")
+			return
+		}
+		if !allowed {
+			apierror.Write(w, http.StatusForbidden, apierror.NotAllowedInRoom, "Not allowed in room")
+			return
+		}
+
+		from := claims.Name
+		if from == "" {
+			from = claims.Login
+		}
+		if from == "" {
+			from = "Someone"
+		}
+		email, err := mail.Render("invitation", []string{address.Address}, mail.Invitation{
+			Instance: opts.Instance,
+			From:     from,
+			RoomID:   roomID,
+			Message:  message,
+			URL:      req.URL,
+		})
+		if err == nil {
+			err = opts.Mailer.Send(r.Context(), email)
+		}
+		if err != nil {
+			logrus.WithField("room_id", roomID).WithError(err).Error("Failed to send invitation")
+			apierror.Write(w, deadline.Status(err, http.StatusBadGateway), apierror.UpstreamFailure, "Failed to send invitation")
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{"room_id": roomID, "user_id": claims.Subject}).Info("Sent room invitation")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// onPublicURL reports whether link is publicURL or a path, query or fragment
+// under it.
+func onPublicURL(link, publicURL string) bool {
+	rest, ok := strings.CutPrefix(link, publicURL)
+	return ok && publicURL != "" && (rest == "" || strings.ContainsAny(rest[:1], "/?#"))
+}
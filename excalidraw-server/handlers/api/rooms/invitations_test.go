@@ -0,0 +1,75 @@
+package rooms
+
+import (
+	"context"
+	"excalidraw-server/auth"
+	"excalidraw-server/mail"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type recordingMailer struct {
+	sent []mail.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, message mail.Message) error {
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+func TestHandleInvite(t *testing.T) {
+	verifier := auth.NewVerifier([]byte("secret"))
+	mailer := &recordingMailer{}
+	r := chi.NewRouter()
+	r.With(auth.Middleware(verifier, true)).Post("/api/rooms/{roomId}/invitations", HandleInvite(InvitationOptions{
+		Mailer:    mailer,
+		Instance:  "Draw",
+		PublicURL: "https://draw.example.com",
+		CanInvite: func(_ context.Context, roomID, userID string) (bool, error) {
+			return roomID != "private" || userID == "alice", nil
+		},
+	}))
+	token, err := verifier.Sign(&auth.Claims{Subject: "bob", Name: "Bob"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	invite := func(roomID, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/rooms/"+roomID+"/invitations", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name   string
+		roomID string
+		body   string
+		want   int
+	}{
+		{"valid", "open", `{"email":"carol@example.com","url":"https://draw.example.com/#room=open,key","message":"join us"}`, http.StatusNoContent},
+		{"invalid email", "open", `{"email":"carol","url":"https://draw.example.com/"}`, http.StatusBadRequest},
+		{"foreign url", "open", `{"email":"carol@example.com","url":"https://evil.example.com/"}`, http.StatusBadRequest},
+		{"lookalike url", "open", `{"email":"carol@example.com","url":"https://draw.example.com.evil.com/"}`, http.StatusBadRequest},
+		{"long message", "open", `{"email":"carol@example.com","url":"https://draw.example.com","message":"` + strings.Repeat("x", maxInvitationMessage+1) + `"}`, http.StatusBadRequest},
+		{"not allowed", "private", `{"email":"carol@example.com","url":"https://draw.example.com/"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := invite(tt.roomID, tt.body); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	if len(mailer.sent) != 1 {
+		t.Fatalf("sent %d invitations, want 1", len(mailer.sent))
+	}
+	sent := mailer.sent[0]
+	if sent.To[0] != "carol@example.com" || !strings.Contains(sent.Subject, "Bob") || !strings.Contains(sent.Text, "https://draw.example.com/#room=open,key") {
+		t.Errorf("invitation = %+v", sent)
+	}
+}
@@ -0,0 +1,306 @@
+package rooms
+
+import (
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/api/openapi"
+	"excalidraw-server/handlers/websocket"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxAllowedUsers limits the allowlist of a private room.
+	maxAllowedUsers = 1000
+	// maxPermissionsSize limits the size of a permissions request body.
+	maxPermissionsSize = 256 << 10
+	// maxLimitsSize limits the size of a message limits request body.
+	maxLimitsSize = 4 << 10
+)
+
+type (
+	DisconnectResponse struct {
+		Disconnected int `json:"disconnected"`
+	}
+
+	PermissionsRequest struct {
+		// Owner transfers the room when set.
+		Owner        string              `json:"owner"`
+		Visibility   core.RoomVisibility `json:"visibility"`
+		AllowedUsers []string            `json:"allowed_users"`
+	}
+)
+
+// HandleList returns the user count of every live room
+func HandleList(list func() map[string]int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, list())
+	}
+}
+
+// HandleGet returns the members and traffic of a live room
+func HandleGet(stats func(roomID string) (websocket.RoomStats, bool)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomStats, ok := stats(chi.URLParam(r, "roomId"))
+		if !ok {
+			apierror.Write(w, http.StatusNotFound, apierror.RoomNotFound, "Room not found")
+			return
+		}
+
+		render.JSON(w, r, roomStats)
+	}
+}
+
+// HandleHibernationStats reports how many rooms were hibernated and woken
+func HandleHibernationStats(stats func() websocket.HibernationStats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, stats())
+	}
+}
+
+// HandleChatStats reports how much memory chat history takes and how many
+// messages were evicted from it
+func HandleChatStats(stats func() websocket.ChatStats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, stats())
+	}
+}
+
+// HandleDisconnect force-disconnects every socket in a room
+func HandleDisconnect(disconnect func(roomID string) int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+
+		count := disconnect(roomID)
+		logrus.WithFields(logrus.Fields{
+			"room_id":      roomID,
+			"disconnected": count,
+		}).Info("Room connections closed")
+
+		render.JSON(w, r, DisconnectResponse{Disconnected: count})
+	}
+}
+
+// HandleGetLimits returns the message size limits that apply in a room.
+func HandleGetLimits(limits func(roomID string) websocket.MessageLimits) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, limits(chi.URLParam(r, "roomId")))
+	}
+}
+
+// HandlePutLimits gives a room its own message size limits. Fields left
+// zero keep the deployment's, so an empty object removes the room's.
+func HandlePutLimits(setLimits func(roomID string, limits websocket.MessageLimits) error, limits func(roomID string) websocket.MessageLimits) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+
+		var req websocket.MessageLimits
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLimitsSize)).Decode(&req); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+			return
+		}
+		if err := req.Validate(); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Limits must be between 0 and "+strconv.Itoa(websocket.MaxMessageSize)+" bytes")
+			return
+		}
+
+		if err := setLimits(roomID, req); err != nil {
+			logrus.WithField("error", err).Error("Failed to update room message limits")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to update room message limits")
+			return
+		}
+		logrus.WithFields(logrus.Fields{
+			"room_id":   roomID,
+			"broadcast": req.Broadcast,
+			"chat":      req.Chat,
+			"presence":  req.Presence,
+		}).Info("Room message limits set")
+
+		render.JSON(w, r, limits(roomID))
+	}
+}
+
+// loadPermissions returns the room's permissions if the caller owns the room
+// or it has no owner yet, and writes an error otherwise.
+func loadPermissions(store core.RoomPermissionStore, w http.ResponseWriter, r *http.Request) (*core.RoomPermissions, bool) {
+	permissions, err := store.GetRoomPermissions(r.Context(), chi.URLParam(r, "roomId"))
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to get room permissions")
+		apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to get room permissions")
+		return nil, false
+	}
+	claims := auth.ClaimsFromContext(r.Context())
+	if permissions.Owner != "" && permissions.Owner != claims.Subject {
+		apierror.Write(w, http.StatusForbidden, apierror.NotRoomOwner, "Only the room owner can manage permissions")
+		return nil, false
+	}
+	return permissions, true
+}
+
+// HandleGetPermissions returns who may join a room. Rooms without an owner
+// report the public defaults to anyone.
+func HandleGetPermissions(store core.RoomPermissionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		permissions, ok := loadPermissions(store, w, r)
+		if !ok {
+			return
+		}
+		render.JSON(w, r, permissions)
+	}
+}
+
+// HandlePutPermissions replaces a room's permissions. The first caller to
+// set permissions on a room becomes its owner, provided inRoom reports it is
+// in the room; after that only the owner can change them or hand the room
+// to someone else. Unless enforced, as join-room ignores permissions while
+// socket auth is off, rooms can only be made public.
+func HandlePutPermissions(store core.RoomPermissionStore, inRoom func(roomID, userID string) bool, enforced bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		permissions, ok := loadPermissions(store, w, r)
+		if !ok {
+			return
+		}
+		subject := auth.ClaimsFromContext(r.Context()).Subject
+		if permissions.Owner == "" && !inRoom(chi.URLParam(r, "roomId"), subject) {
+			apierror.Write(w, http.StatusForbidden, apierror.NotInRoom, "Join the room before claiming it")
+			return
+		}
+
+		var req PermissionsRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPermissionsSize)).Decode(&req); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+			return
+		}
+		switch req.Visibility {
+		case core.RoomPublic, core.RoomLinkOnly, core.RoomPrivate:
+		default:
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Visibility must be public, link-only or private")
+			return
+		}
+		if !enforced && req.Visibility != core.RoomPublic {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Rooms can only be public while socket auth is off")
+			return
+		}
+		if len(req.AllowedUsers) > maxAllowedUsers {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Too many allowed users")
+			return
+		}
+
+		permissions.Owner = subject
+		if owner := strings.TrimSpace(req.Owner); owner != "" {
+			permissions.Owner = owner
+		}
+		permissions.Visibility = req.Visibility
+		permissions.AllowedUsers = []string{}
+		seen := make(map[string]bool, len(req.AllowedUsers))
+		for _, user := range req.AllowedUsers {
+			user = strings.TrimSpace(user)
+			if user == "" || seen[user] {
+				continue
+			}
+			seen[user] = true
+			permissions.AllowedUsers = append(permissions.AllowedUsers, user)
+		}
+
+		if err := store.PutRoomPermissions(r.Context(), permissions); err != nil {
+			logrus.WithField("error", err).Error("Failed to update room permissions")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to update room permissions")
+			return
+		}
+
+		render.JSON(w, r, permissions)
+	}
+}
+
+// The operations below document the room routes relative to /api/rooms;
+// each group is only mounted when its feature is enabled.
+var (
+	ListOperation = openapi.Operation{
+		Method: http.MethodGet, Path: "/", Tag: "rooms",
+		Summary:  "Count the users of every listed live room",
+		Response: map[string]int{},
+	}
+
+	PermissionOperations = []openapi.Operation{
+		{
+			Method: http.MethodGet, Path: "/{roomId}/permissions", Tag: "rooms", Auth: openapi.AuthUser,
+			Summary:  "Get who may join a room",
+			Response: core.RoomPermissions{},
+			Errors:   []int{http.StatusForbidden},
+		},
+		{
+			Method: http.MethodPut, Path: "/{roomId}/permissions", Tag: "rooms", Auth: openapi.AuthUser,
+			Summary:  "Set who may join a room; the first member to do so owns it",
+			Request:  PermissionsRequest{},
+			Response: core.RoomPermissions{},
+			Errors:   []int{http.StatusBadRequest, http.StatusForbidden},
+		},
+	}
+
+	InvitationOperation = openapi.Operation{
+		Method: http.MethodPost, Path: "/{roomId}/invitations", Tag: "rooms", Auth: openapi.AuthUser,
+		Summary: "Email an invitation to a room",
+		Request: InvitationRequest{},
+		Status:  http.StatusNoContent,
+		Errors:  []int{http.StatusBadRequest, http.StatusForbidden, http.StatusBadGateway},
+	}
+
+	ChatOperations = []openapi.Operation{
+		{
+			Method: http.MethodGet, Path: "/{roomId}/chat", Tag: "rooms", Auth: openapi.AuthOptional,
+			Summary: "Get a page of a room's chat history, oldest first",
+			Query: []openapi.Param{
+				{Name: "limit", Type: "integer", Description: "Page size, 50 by default and at most 1000"},
+				{Name: "before", Type: "integer", Description: "Only messages sent before this timestamp in milliseconds"},
+			},
+			Response: ChatPage{},
+			Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+		},
+		{
+			Method: http.MethodDelete, Path: "/{roomId}/chat", Tag: "rooms", Auth: openapi.AuthOptional,
+			Summary:  "Delete a room's chat history; moderators only",
+			Response: DeleteChatResponse{},
+			Errors:   []int{http.StatusUnauthorized, http.StatusForbidden},
+		},
+		{
+			Method: http.MethodDelete, Path: "/{roomId}/chat/{messageId}", Tag: "rooms", Auth: openapi.AuthOptional,
+			Summary:  "Delete a chat message; moderators only",
+			Response: DeleteChatResponse{},
+			Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+		},
+	}
+
+	AdminOperations = []openapi.Operation{
+		{
+			Method: http.MethodGet, Path: "/{roomId}", Tag: "rooms", Auth: openapi.AuthAdmin,
+			Summary:  "Get the members and traffic of a live room",
+			Response: websocket.RoomStats{},
+			Errors:   []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodDelete, Path: "/{roomId}/connections", Tag: "rooms", Auth: openapi.AuthAdmin,
+			Summary:  "Disconnect every socket in a room",
+			Response: DisconnectResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/{roomId}/limits", Tag: "rooms", Auth: openapi.AuthAdmin,
+			Summary:  "Get the message size limits that apply in a room",
+			Response: websocket.MessageLimits{},
+		},
+		{
+			Method: http.MethodPut, Path: "/{roomId}/limits", Tag: "rooms", Auth: openapi.AuthAdmin,
+			Summary:  "Set a room's own message size limits; zero keeps the deployment's",
+			Request:  websocket.MessageLimits{},
+			Response: websocket.MessageLimits{},
+			Errors:   []int{http.StatusBadRequest},
+		},
+	}
+)
@@ -0,0 +1,225 @@
+package rooms
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newRouter(disconnected *string) *chi.Mux {
+	stats := func(roomID string) (websocket.RoomStats, bool) {
+		if roomID != "room-1" {
+			return websocket.RoomStats{}, false
+		}
+		return websocket.RoomStats{
+			RoomID:   roomID,
+			Users:    []websocket.RoomMember{{SocketID: "socket-a", JoinedAt: 1000}},
+			Messages: 3,
+		}, true
+	}
+	disconnect := func(roomID string) int {
+		*disconnected = roomID
+		return 2
+	}
+
+	r := chi.NewRouter()
+	r.Get("/api/rooms", HandleList(func() map[string]int { return map[string]int{"room-1": 1} }))
+	r.Get("/api/rooms/{roomId}", HandleGet(stats))
+	r.Delete("/api/rooms/{roomId}/connections", HandleDisconnect(disconnect))
+	return r
+}
+
+func TestHandleGet(t *testing.T) {
+	var disconnected string
+	router := newRouter(&disconnected)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/room-1", nil))
+	var stats websocket.RoomStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	if stats.RoomID != "room-1" || len(stats.Users) != 1 || stats.Users[0].JoinedAt != 1000 || stats.Messages != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown room = %d, want 404", w.Code)
+	}
+}
+
+func TestHandleDisconnect(t *testing.T) {
+	var disconnected string
+	router := newRouter(&disconnected)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/rooms/room-1/connections", nil))
+	if w.Code != http.StatusOK || disconnected != "room-1" {
+		t.Fatalf("DELETE = %d, disconnected %q", w.Code, disconnected)
+	}
+	var response DisconnectResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Disconnected != 2 {
+		t.Errorf("response = %q", w.Body.String())
+	}
+}
+
+// permissionMap is a RoomPermissionStore for tests; missing rooms are public.
+type permissionMap map[string]core.RoomPermissions
+
+func (m permissionMap) GetRoomPermissions(_ context.Context, roomID string) (*core.RoomPermissions, error) {
+	permissions, ok := m[roomID]
+	if !ok {
+		permissions = core.RoomPermissions{RoomID: roomID, Visibility: core.RoomPublic, AllowedUsers: []string{}}
+	}
+	return &permissions, nil
+}
+
+func (m permissionMap) PutRoomPermissions(_ context.Context, permissions *core.RoomPermissions) error {
+	m[permissions.RoomID] = *permissions
+	return nil
+}
+
+func TestHandleHibernationStats(t *testing.T) {
+	handler := HandleHibernationStats(func() websocket.HibernationStats {
+		return websocket.HibernationStats{Hibernated: 5, Woken: 3, Sleeping: 2}
+	})
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/hibernation/stats", nil))
+
+	var stats websocket.HibernationStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats != (websocket.HibernationStats{Hibernated: 5, Woken: 3, Sleeping: 2}) {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestRoomLimits(t *testing.T) {
+	stored := map[string]websocket.MessageLimits{}
+	limits := func(roomID string) websocket.MessageLimits {
+		room := stored[roomID]
+		if room.Chat == 0 {
+			room.Chat = 100
+		}
+		return room
+	}
+	r := chi.NewRouter()
+	r.Get("/api/rooms/{roomId}/limits", HandleGetLimits(limits))
+	r.Put("/api/rooms/{roomId}/limits", HandlePutLimits(func(roomID string, l websocket.MessageLimits) error { stored[roomID] = l; return nil }, limits))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/rooms/room-1/limits", strings.NewReader(`{"broadcast":1024}`)))
+	var got websocket.MessageLimits
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != (websocket.MessageLimits{Broadcast: 1024, Chat: 100}) {
+		t.Errorf("limits = %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/room-1/limits", nil))
+	if !strings.Contains(w.Body.String(), `"broadcast":1024`) {
+		t.Errorf("GET = %s", w.Body.String())
+	}
+
+	for _, body := range []string{`{"chat":-1}`, `{"presence":99999999}`, `not json`} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/rooms/room-1/limits", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestRoomPermissions(t *testing.T) {
+	verifier := auth.NewVerifier([]byte("secret"))
+	store := permissionMap{}
+	// alice is in room-1, carol only in room-2
+	inRoom := func(roomID, userID string) bool {
+		return roomID == "room-1" && userID == "alice" || roomID == "room-2" && userID == "carol"
+	}
+	r := chi.NewRouter()
+	r.Route("/api/rooms/{roomId}/permissions", func(r chi.Router) {
+		r.Use(auth.Middleware(verifier, true))
+		r.Get("/", HandleGetPermissions(store))
+		r.Put("/", HandlePutPermissions(store, inRoom, true))
+	})
+	r.With(auth.Middleware(verifier, true)).Put("/api/unenforced/{roomId}/permissions/", HandlePutPermissions(store, inRoom, false))
+
+	doAt := func(method, path, user, body string) *httptest.ResponseRecorder {
+		token, _ := verifier.Sign(&auth.Claims{Subject: user})
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	do := func(method, user, body string) *httptest.ResponseRecorder {
+		return doAt(method, "/api/rooms/room-1/permissions/", user, body)
+	}
+
+	w := do(http.MethodGet, "bob", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"visibility":"public"`) {
+		t.Fatalf("unclaimed room: %d %s", w.Code, w.Body.String())
+	}
+
+	// Only a member can claim a room, and only make it public while socket
+	// auth is off
+	if w := do(http.MethodPut, "bob", `{"visibility":"private"}`); w.Code != http.StatusForbidden {
+		t.Errorf("claim by a non-member status = %d, want 403", w.Code)
+	}
+	if w := doAt(http.MethodPut, "/api/unenforced/room-2/permissions/", "carol", `{"visibility":"private"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unenforced private status = %d, want 400", w.Code)
+	}
+	if w := doAt(http.MethodPut, "/api/unenforced/room-2/permissions/", "carol", `{"visibility":"public"}`); w.Code != http.StatusOK {
+		t.Errorf("unenforced public status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// The first member to set permissions claims the room
+	w = do(http.MethodPut, "alice", `{"visibility":"private","allowed_users":["bob"," bob ",""]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("claim status = %d: %s", w.Code, w.Body.String())
+	}
+	var permissions core.RoomPermissions
+	if err := json.Unmarshal(w.Body.Bytes(), &permissions); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if permissions.Owner != "alice" || permissions.Visibility != core.RoomPrivate ||
+		len(permissions.AllowedUsers) != 1 || permissions.AllowedUsers[0] != "bob" {
+		t.Errorf("permissions = %+v", permissions)
+	}
+
+	// Allowed users can join but not manage the room
+	if w := do(http.MethodGet, "bob", ""); w.Code != http.StatusForbidden {
+		t.Errorf("non-owner GET status = %d, want 403", w.Code)
+	}
+	if w := do(http.MethodPut, "bob", `{"visibility":"public"}`); w.Code != http.StatusForbidden {
+		t.Errorf("non-owner PUT status = %d, want 403", w.Code)
+	}
+	if w := do(http.MethodPut, "alice", `{"visibility":"hidden"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid visibility status = %d, want 400", w.Code)
+	}
+
+	// The owner can hand the room over
+	if w := do(http.MethodPut, "alice", `{"owner":"bob","visibility":"link-only"}`); w.Code != http.StatusOK {
+		t.Fatalf("transfer status = %d: %s", w.Code, w.Body.String())
+	}
+	if store["room-1"].Owner != "bob" || store["room-1"].Visibility != core.RoomLinkOnly {
+		t.Errorf("stored permissions after transfer = %+v", store["room-1"])
+	}
+	if w := do(http.MethodGet, "alice", ""); w.Code != http.StatusForbidden {
+		t.Errorf("previous owner GET status = %d, want 403", w.Code)
+	}
+}
@@ -3,9 +3,17 @@ package snapshots
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/export"
+	"excalidraw-server/handlers/api/apiversion"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/api/openapi"
 	"excalidraw-server/stores/sqlite"
+	"excalidraw-server/thumbnails"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
@@ -17,15 +25,25 @@ type (
 	CreateSnapshotRequest struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
-		Thumbnail   string `json:"thumbnail"`
-		CreatedBy   string `json:"created_by"`
-		Data        string `json:"data"`
+		// Thumbnail is ignored; the server renders thumbnails from Data.
+		Thumbnail string `json:"thumbnail"`
+		CreatedBy string `json:"created_by"`
+		Data      string `json:"data"`
 	}
 
 	CreateSnapshotResponse struct {
 		ID string `json:"id"`
 	}
 
+	// SnapshotResponse is a snapshot with the path of its rendered
+	// thumbnail, relative to the server URL. Thumbnail is only set for
+	// snapshots saved with a client-side thumbnail by older servers, and
+	// only when it is a PNG or WebP data URI within THUMBNAIL_MAX_SIZE.
+	SnapshotResponse struct {
+		sqlite.Snapshot
+		ThumbnailURL string `json:"thumbnail_url"`
+	}
+
 	UpdateSnapshotRequest struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
@@ -56,55 +74,114 @@ func HandleCreateSnapshot(store SnapshotStore) http.HandlerFunc {
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to decode request")
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
 			return
 		}
 
-		id, err := store.CreateSnapshot(r.Context(), roomID, req.Name, req.Description, req.Thumbnail, req.CreatedBy, []byte(req.Data))
+		id, err := store.CreateSnapshot(r.Context(), roomID, req.Name, req.Description, "", req.CreatedBy, []byte(req.Data))
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to create snapshot")
-			http.Error(w, "Failed to create snapshot", http.StatusInternalServerError)
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to create snapshot")
 			return
 		}
 
+		render.Status(r, apiversion.CreatedStatus(r))
 		render.JSON(w, r, CreateSnapshotResponse{ID: id})
-		render.Status(r, http.StatusCreated)
+	}
+}
+
+// RejectEncryptedRooms refuses requests for rooms relaying end-to-end encrypted
+// frames, whose scenes the server must not persist
+func RejectEncryptedRooms(isEncrypted func(roomID string) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			roomID := chi.URLParam(r, "roomId")
+			if isEncrypted(roomID) {
+				apierror.Write(w, http.StatusConflict, apierror.RoomEncrypted, "Snapshots are disabled for encrypted rooms")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
 }
 
 // HandleListSnapshots lists all snapshots for a room
 func HandleListSnapshots(store SnapshotStore) http.HandlerFunc {
+	maxThumbnail := thumbnails.MaxInlineSizeFromEnv()
 	return func(w http.ResponseWriter, r *http.Request) {
 		roomID := chi.URLParam(r, "roomId")
 
 		snapshots, err := store.ListSnapshots(r.Context(), roomID)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to list snapshots")
-			http.Error(w, "Failed to list snapshots", http.StatusInternalServerError)
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list snapshots")
 			return
 		}
 
-		if snapshots == nil {
-			snapshots = []sqlite.Snapshot{}
+		response := make([]SnapshotResponse, 0, len(snapshots))
+		for _, snapshot := range snapshots {
+			response = append(response, newSnapshotResponse(snapshot, maxThumbnail))
 		}
-
-		render.JSON(w, r, snapshots)
+		render.JSON(w, r, response)
 	}
 }
 
 // HandleGetSnapshot retrieves a specific snapshot
 func HandleGetSnapshot(store SnapshotStore) http.HandlerFunc {
+	maxThumbnail := thumbnails.MaxInlineSizeFromEnv()
 	return func(w http.ResponseWriter, r *http.Request) {
 		snapshotID := chi.URLParam(r, "snapshotId")
 
 		snapshot, err := store.GetSnapshot(r.Context(), snapshotID)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to get snapshot")
-			http.Error(w, "Snapshot not found", http.StatusNotFound)
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.SnapshotNotFound, "Snapshot not found")
 			return
 		}
 
-		render.JSON(w, r, snapshot)
+		render.JSON(w, r, newSnapshotResponse(*snapshot, maxThumbnail))
+	}
+}
+
+// newSnapshotResponse drops inline thumbnails the backfill job hasn't
+// sanitized yet when they are over maxThumbnail or not an image, so they
+// can't bloat responses
+func newSnapshotResponse(snapshot sqlite.Snapshot, maxThumbnail int) SnapshotResponse {
+	if !thumbnails.InlineOK(snapshot.Thumbnail, maxThumbnail) {
+		snapshot.Thumbnail = ""
+	}
+	return SnapshotResponse{
+		Snapshot:     snapshot,
+		ThumbnailURL: "/api/snapshots/" + url.PathEscape(snapshot.ID) + "/thumbnail",
+	}
+}
+
+// HandleGetThumbnail serves a small PNG preview of a snapshot, rendered on
+// first use and shared with every identical scene
+func HandleGetThumbnail(store SnapshotStore, service *thumbnails.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshotID := chi.URLParam(r, "snapshotId")
+
+		snapshot, err := store.GetSnapshot(r.Context(), snapshotID)
+		if err != nil {
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.SnapshotNotFound, "Snapshot not found")
+			return
+		}
+
+		png, hash, err := service.Get(r.Context(), snapshot.Data)
+		if err != nil {
+			if errors.Is(err, export.ErrNotScene) {
+				apierror.Write(w, http.StatusUnprocessableEntity, apierror.NotAScene, "Snapshot is not a plain Excalidraw scene")
+				return
+			}
+			logrus.WithField("snapshot_id", snapshotID).WithField("error", err).Error("Failed to render snapshot thumbnail")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to render thumbnail")
+			return
+		}
+
+		// A snapshot's scene never changes, only its name and description
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		thumbnails.Serve(w, r, png, hash)
 	}
 }
 
@@ -116,7 +193,7 @@ func HandleDeleteSnapshot(store SnapshotStore) http.HandlerFunc {
 		err := store.DeleteSnapshot(r.Context(), snapshotID)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to delete snapshot")
-			http.Error(w, "Failed to delete snapshot", http.StatusInternalServerError)
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to delete snapshot")
 			return
 		}
 
@@ -133,21 +210,21 @@ func HandleUpdateSnapshot(store SnapshotStore) http.HandlerFunc {
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to read request body")
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
 			return
 		}
 
 		err = json.Unmarshal(body, &req)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to decode request")
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
 			return
 		}
 
 		err = store.UpdateSnapshotMetadata(r.Context(), snapshotID, req.Name, req.Description)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to update snapshot")
-			http.Error(w, "Failed to update snapshot", http.StatusInternalServerError)
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to update snapshot")
 			return
 		}
 
@@ -163,7 +240,7 @@ func HandleGetRoomSettings(store SnapshotStore) http.HandlerFunc {
 		settings, err := store.GetRoomSettings(r.Context(), roomID)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to get room settings")
-			http.Error(w, "Failed to get room settings", http.StatusInternalServerError)
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to get room settings")
 			return
 		}
 
@@ -180,7 +257,7 @@ func HandleUpdateRoomSettings(store SnapshotStore) http.HandlerFunc {
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to decode request")
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
 			return
 		}
 
@@ -195,7 +272,7 @@ func HandleUpdateRoomSettings(store SnapshotStore) http.HandlerFunc {
 		err = store.UpdateRoomSettings(r.Context(), roomID, req.MaxSnapshots, req.AutoSaveInterval)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to update room settings")
-			http.Error(w, "Failed to update room settings", http.StatusInternalServerError)
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to update room settings")
 			return
 		}
 
@@ -227,7 +304,7 @@ func HandleGetSnapshotCount(store SnapshotStore) http.HandlerFunc {
 		snapshots, err := store.ListSnapshots(r.Context(), roomID)
 		if err != nil {
 			logrus.WithField("error", err).Error("Failed to list snapshots")
-			http.Error(w, "Failed to get snapshot count", http.StatusInternalServerError)
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to get snapshot count")
 			return
 		}
 
@@ -250,3 +327,63 @@ func ParseIntParam(r *http.Request, param string, defaultValue int) int {
 
 	return intValue
 }
+
+// Operations documents the snapshot routes, relative to /api, except the
+// thumbnail which needs a thumbnail store.
+var Operations = []openapi.Operation{
+	{
+		Method: http.MethodPost, Path: "/rooms/{roomId}/snapshots", Tag: "snapshots",
+		Summary:  "Save a snapshot of a room's scene",
+		Request:  CreateSnapshotRequest{},
+		Response: CreateSnapshotResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusConflict},
+	},
+	{
+		Method: http.MethodGet, Path: "/rooms/{roomId}/snapshots", Tag: "snapshots",
+		Summary:  "List a room's snapshots",
+		Response: []SnapshotResponse{},
+	},
+	{
+		Method: http.MethodGet, Path: "/rooms/{roomId}/snapshots/count", Tag: "snapshots",
+		Summary:  "Count a room's snapshots",
+		Response: map[string]int{},
+	},
+	{
+		Method: http.MethodGet, Path: "/snapshots/{snapshotId}", Tag: "snapshots",
+		Summary:  "Get a snapshot",
+		Response: SnapshotResponse{},
+		Errors:   []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPut, Path: "/snapshots/{snapshotId}", Tag: "snapshots",
+		Summary: "Rename a snapshot or change its description",
+		Request: UpdateSnapshotRequest{},
+		Status:  http.StatusNoContent,
+		Errors:  []int{http.StatusBadRequest},
+	},
+	{
+		Method: http.MethodDelete, Path: "/snapshots/{snapshotId}", Tag: "snapshots",
+		Summary: "Delete a snapshot",
+		Status:  http.StatusNoContent,
+	},
+	{
+		Method: http.MethodGet, Path: "/rooms/{roomId}/settings", Tag: "snapshots",
+		Summary:  "Get a room's snapshot settings",
+		Response: sqlite.RoomSettings{},
+	},
+	{
+		Method: http.MethodPut, Path: "/rooms/{roomId}/settings", Tag: "snapshots",
+		Summary: "Change a room's snapshot settings",
+		Request: UpdateSettingsRequest{},
+		Status:  http.StatusNoContent,
+		Errors:  []int{http.StatusBadRequest},
+	},
+}
+
+// ThumbnailOperation documents HandleGetThumbnail, relative to /api.
+var ThumbnailOperation = openapi.Operation{
+	Method: http.MethodGet, Path: "/snapshots/{snapshotId}/thumbnail", Tag: "snapshots",
+	Summary:      "Render a small preview of a snapshot",
+	ResponseType: "image/png",
+	Errors:       []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+}
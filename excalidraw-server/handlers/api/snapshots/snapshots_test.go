@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
 	"excalidraw-server/stores/sqlite"
+	"excalidraw-server/thumbnails"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -206,6 +209,35 @@ func TestHandleCreateSnapshot_Success(t *testing.T) {
 	}
 }
 
+func TestRejectEncryptedRooms(t *testing.T) {
+	store := newMockSnapshotStore()
+	handler := RejectEncryptedRooms(func(roomID string) bool {
+		return roomID == "secret-room"
+	})(HandleCreateSnapshot(store))
+
+	for roomID, wantStatus := range map[string]int{
+		"secret-room": http.StatusConflict,
+		"room-1":      http.StatusOK,
+	} {
+		body, _ := json.Marshal(CreateSnapshotRequest{Name: "Snapshot", Data: "{}"})
+		req := httptest.NewRequest(http.MethodPost, "/api/rooms/"+roomID+"/snapshots", bytes.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("roomId", roomID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != wantStatus {
+			t.Errorf("room %s: got status %d, want %d", roomID, rec.Code, wantStatus)
+		}
+	}
+
+	if len(store.roomSnapshots["secret-room"]) != 0 {
+		t.Error("expected no snapshot to be stored for an encrypted room")
+	}
+}
+
 func TestHandleCreateSnapshot_InvalidJSON(t *testing.T) {
 	store := newMockSnapshotStore()
 	handler := HandleCreateSnapshot(store)
@@ -343,7 +375,7 @@ func TestHandleGetSnapshot_Success(t *testing.T) {
 		t.Errorf("Status code mismatch: got %d, want %d", rec.Code, http.StatusOK)
 	}
 
-	var snapshot sqlite.Snapshot
+	var snapshot SnapshotResponse
 	err := json.NewDecoder(rec.Body).Decode(&snapshot)
 	if err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
@@ -355,6 +387,48 @@ func TestHandleGetSnapshot_Success(t *testing.T) {
 	if snapshot.Name != "Test" {
 		t.Errorf("Snapshot name mismatch: got %q, want %q", snapshot.Name, "Test")
 	}
+	if want := "/api/snapshots/" + id + "/thumbnail"; snapshot.ThumbnailURL != want {
+		t.Errorf("Thumbnail URL mismatch: got %q, want %q", snapshot.ThumbnailURL, want)
+	}
+}
+
+func TestHandleGetThumbnail(t *testing.T) {
+	store := newMockSnapshotStore()
+	handler := HandleGetThumbnail(store, thumbnails.NewService(memory.NewDocumentStore().(core.ThumbnailStore)))
+
+	scene := `{"elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":50}]}`
+	id, _ := store.CreateSnapshot(context.Background(), "room-1", "Test", "", "", "user1", []byte(scene))
+	encrypted, _ := store.CreateSnapshot(context.Background(), "room-1", "Secret", "", "", "user1", []byte("\x00ciphertext"))
+
+	get := func(id, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/snapshots/"+id+"/thumbnail", http.NoBody)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("snapshotId", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := get(id, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status code mismatch: got %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type mismatch: got %q, want image/png", ct)
+	}
+	if rec := get(id, rec.Header().Get("ETag")); rec.Code != http.StatusNotModified {
+		t.Errorf("Revalidation status mismatch: got %d, want %d", rec.Code, http.StatusNotModified)
+	}
+	if rec := get(encrypted, ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Encrypted snapshot status mismatch: got %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if rec := get("nonexistent", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Unknown snapshot status mismatch: got %d, want %d", rec.Code, http.StatusNotFound)
+	}
 }
 
 func TestHandleGetSnapshot_NotFound(t *testing.T) {
@@ -728,3 +802,35 @@ func TestConcurrentSnapshotOperations(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHandleListSnapshots_DropsOversizedThumbnails(t *testing.T) {
+	store := newMockSnapshotStore()
+	handler := HandleListSnapshots(store)
+
+	small := "data:image/png;base64,AAAA"
+	_, _ = store.CreateSnapshot(context.Background(), "room-1", "small", "", small, "user1", []byte("data"))
+	_, _ = store.CreateSnapshot(context.Background(), "room-1", "large", "", "data:image/png;base64,"+strings.Repeat("A", thumbnails.DefaultMaxInlineSize), "user1", []byte("data"))
+	_, _ = store.CreateSnapshot(context.Background(), "room-1", "html", "", "data:text/html;base64,PGgxPg==", "user1", []byte("data"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/rooms/room-1/snapshots", http.NoBody)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("roomId", "room-1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	var snapshots []SnapshotResponse
+	if err := json.NewDecoder(rec.Body).Decode(&snapshots); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for _, snapshot := range snapshots {
+		want := ""
+		if snapshot.Name == "small" {
+			want = small
+		}
+		if snapshot.Thumbnail != want {
+			t.Errorf("Thumbnail of %s mismatch: got %.40q, want %q", snapshot.Name, snapshot.Thumbnail, want)
+		}
+	}
+}
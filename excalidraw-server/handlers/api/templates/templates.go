@@ -0,0 +1,373 @@
+package templates
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/export"
+	"excalidraw-server/handlers/api/apiversion"
+	"excalidraw-server/handlers/api/canvases"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/thumbnails"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxTemplateSize limits the size of a template request body.
+	MaxTemplateSize = 10 << 20
+
+	maxNameLength        = 200
+	maxDescriptionLength = 2000
+	maxCategoryLength    = 64
+)
+
+type (
+	TemplateRequest struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Category    string          `json:"category"`
+		Scene       json.RawMessage `json:"scene"`
+	}
+
+	CreateTemplateResponse struct {
+		ID string `json:"id"`
+	}
+
+	// CanvasRequest is the body of POST /api/v2/templates/{templateId}/canvas.
+	CanvasRequest struct {
+		Key string `json:"key"`
+	}
+
+	// RoomRequest is the body of POST /api/v2/templates/{templateId}/room;
+	// an empty RoomID asks for a new room.
+	RoomRequest struct {
+		RoomID string `json:"room_id"`
+	}
+
+	RoomResponse struct {
+		RoomID string `json:"room_id"`
+	}
+
+	// RoomOptions connects the room endpoint to the collaboration server.
+	RoomOptions struct {
+		// Seed gives an unused room its starting elements.
+		Seed func(ctx context.Context, roomID string, elements []any) error
+		// CanJoin reports whether a user, or an anonymous one for "", may
+		// join a room.
+		CanJoin func(ctx context.Context, roomID, userID string) (bool, error)
+	}
+)
+
+// validRoomID matches the room ids of Excalidraw collaboration links.
+var validRoomID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// decodeRequest reads a TemplateRequest and checks that it carries an
+// Excalidraw scene.
+func decodeRequest(w http.ResponseWriter, r *http.Request) (*TemplateRequest, bool) {
+	var req TemplateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxTemplateSize)).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.TooLarge, "Template too large")
+			return nil, false
+		}
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+		return nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	req.Category = strings.TrimSpace(req.Category)
+	switch {
+	case req.Name == "":
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Template name is required")
+		return nil, false
+	case !validText(req.Name, maxNameLength):
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("name must be at most %d characters without control characters", maxNameLength))
+		return nil, false
+	case utf8.RuneCountInString(req.Description) > maxDescriptionLength:
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("description must be at most %d characters", maxDescriptionLength))
+		return nil, false
+	case !validText(req.Category, maxCategoryLength):
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("category must be at most %d characters without control characters", maxCategoryLength))
+		return nil, false
+	}
+	if _, err := export.ParseScene(req.Scene); err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "scene must be an .excalidraw file")
+		return nil, false
+	}
+	return &req, true
+}
+
+func validText(s string, maxLength int) bool {
+	return utf8.RuneCountInString(s) <= maxLength && strings.IndexFunc(s, unicode.IsControl) < 0
+}
+
+// loadTemplate fetches the template in the URL.
+func loadTemplate(store core.TemplateStore, w http.ResponseWriter, r *http.Request) (*core.Template, bool) {
+	template, err := store.GetTemplate(r.Context(), chi.URLParam(r, "templateId"))
+	if err != nil {
+		if !errors.Is(err, core.ErrTemplateNotFound) {
+			logrus.WithField("error", err).Error("Failed to get template")
+		}
+		apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.TemplateNotFound, "Template not found")
+		return nil, false
+	}
+	return template, true
+}
+
+// HandleList lists the gallery, optionally only the templates in
+// ?category=
+func HandleList(store core.TemplateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templates, err := store.ListTemplates(r.Context(), r.URL.Query().Get("category"))
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to list templates")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to list templates")
+			return
+		}
+		apiversion.RenderList(w, r, templates)
+	}
+}
+
+// HandleGet returns a template's metadata and scene
+func HandleGet(store core.TemplateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		template, ok := loadTemplate(store, w, r)
+		if !ok {
+			return
+		}
+		render.JSON(w, r, template)
+	}
+}
+
+// HandlePreview serves a PNG preview of a template for the gallery
+func HandlePreview(store core.TemplateStore, service *thumbnails.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		template, ok := loadTemplate(store, w, r)
+		if !ok {
+			return
+		}
+		png, hash, err := service.Get(r.Context(), template.Data)
+		if err != nil {
+			logrus.WithField("template_id", template.ID).WithField("error", err).Error("Failed to render template preview")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to render preview")
+			return
+		}
+
+		// Admins may edit templates, so clients revalidate with the ETag
+		w.Header().Set("Cache-Control", "public, no-cache")
+		thumbnails.Serve(w, r, png, hash)
+	}
+}
+
+// HandleCreate publishes a new template
+func HandleCreate(store core.TemplateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeRequest(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := store.CreateTemplate(r.Context(), &core.Template{
+			Name:        req.Name,
+			Description: req.Description,
+			Category:    req.Category,
+			Data:        req.Scene,
+		})
+		if err != nil {
+			logrus.WithField("error", err).Error("Failed to create template")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to create template")
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, CreateTemplateResponse{ID: id})
+	}
+}
+
+// HandleUpdate replaces a template
+func HandleUpdate(store core.TemplateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeRequest(w, r)
+		if !ok {
+			return
+		}
+
+		err := store.UpdateTemplate(r.Context(), &core.Template{
+			ID:          chi.URLParam(r, "templateId"),
+			Name:        req.Name,
+			Description: req.Description,
+			Category:    req.Category,
+			Data:        req.Scene,
+		})
+		if err != nil {
+			if !errors.Is(err, core.ErrTemplateNotFound) {
+				logrus.WithField("error", err).Error("Failed to update template")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.TemplateNotFound, "Template not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleDelete removes a template from the gallery
+func HandleDelete(store core.TemplateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := store.DeleteTemplate(r.Context(), chi.URLParam(r, "templateId")); err != nil {
+			if !errors.Is(err, core.ErrTemplateNotFound) {
+				logrus.WithField("error", err).Error("Failed to delete template")
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.TemplateNotFound, "Template not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleCreateCanvas saves a copy of a template as a new canvas of the
+// caller, named after the template. It won't replace an existing canvas.
+func HandleCreateCanvas(store core.TemplateStore, canvasStore core.CanvasStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CanvasRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+			return
+		}
+		if !canvases.ValidKey(req.Key) {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidCanvasKey, "Invalid canvas key")
+			return
+		}
+		template, ok := loadTemplate(store, w, r)
+		if !ok {
+			return
+		}
+
+		ownerID := auth.ClaimsFromContext(r.Context()).Subject
+		log := logrus.WithFields(logrus.Fields{
+			"template_id": template.ID,
+			"canvas_key":  req.Key,
+		})
+		_, err := canvasStore.GetCanvas(r.Context(), ownerID, req.Key)
+		if err == nil {
+			apierror.Write(w, http.StatusConflict, apierror.CanvasExists, "Canvas already exists")
+			return
+		}
+		if !errors.Is(err, core.ErrCanvasNotFound) {
+			log.WithField("error", err).Error("Failed to get canvas")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to create canvas")
+			return
+		}
+
+		err = canvasStore.PutCanvas(r.Context(), &core.Canvas{
+			Key:     req.Key,
+			OwnerID: ownerID,
+			Data:    template.Data,
+		})
+		if err != nil {
+			log.WithField("error", err).Error("Failed to save canvas")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to create canvas")
+			return
+		}
+		canvas, err := canvasStore.UpdateCanvasMetadata(r.Context(), ownerID, req.Key, core.CanvasMetadataUpdate{Name: &template.Name})
+		if err != nil {
+			log.WithField("error", err).Error("Failed to name canvas")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to create canvas")
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, canvas)
+	}
+}
+
+// HandleCreateRoom seeds an unused collaboration room with a template's
+// elements, a new one unless the request names it. Only plain rooms can be
+// seeded; the server can't write into end-to-end encrypted ones.
+func HandleCreateRoom(store core.TemplateStore, opts RoomOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req RoomRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid request body")
+			return
+		}
+		if req.RoomID == "" {
+			req.RoomID = newRoomID()
+		} else if !validRoomID.MatchString(req.RoomID) {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Invalid room id")
+			return
+		}
+		template, ok := loadTemplate(store, w, r)
+		if !ok {
+			return
+		}
+
+		userID := ""
+		if claims := auth.ClaimsFromContext(r.Context()); claims != nil {
+			userID = claims.Subject
+		}
+		allowed, err := opts.CanJoin(r.Context(), req.RoomID, userID)
+		if err != nil {
+			logrus.WithField("room_id", req.RoomID).WithField("error", err).Error("Failed to check room permissions")
+			apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to check room permissions")
+			return
+		}
+		if !allowed {
+			apierror.Write(w, http.StatusForbidden, apierror.NotAllowedInRoom, "Not allowed in this room")
+			return
+		}
+
+		var scene struct {
+			Elements []any `json:"elements"`
+		}
+		if err := json.Unmarshal(template.Data, &scene); err != nil {
+			logrus.WithField("template_id", template.ID).WithField("error", err).Error("Failed to decode template")
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to decode template")
+			return
+		}
+		if err := opts.Seed(r.Context(), req.RoomID, scene.Elements); err != nil {
+			switch {
+			case errors.Is(err, websocket.ErrRoomInUse):
+				apierror.Write(w, http.StatusConflict, apierror.RoomInUse, "Room already has a scene")
+			case errors.Is(err, websocket.ErrRoomEncrypted):
+				apierror.Write(w, http.StatusUnprocessableEntity, apierror.RoomEncrypted, "Rooms are end-to-end encrypted on this server")
+			default:
+				logrus.WithField("room_id", req.RoomID).WithField("error", err).Error("Failed to seed room")
+				apierror.Write(w, deadline.Status(err, http.StatusInternalServerError), apierror.Internal, "Failed to seed room")
+			}
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"template_id": template.ID,
+			"room_id":     req.RoomID,
+		}).Info("Room seeded from template")
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, RoomResponse{RoomID: req.RoomID})
+	}
+}
+
+// newRoomID returns a random room id shaped like the frontend's.
+func newRoomID() string {
+	b := make([]byte, 10)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
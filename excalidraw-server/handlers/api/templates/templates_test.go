@@ -0,0 +1,211 @@
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/stores/memory"
+	"excalidraw-server/thumbnails"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const templateBody = `{"name":"Retro","category":"meetings","scene":{"type":"excalidraw","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":50,"version":1}]}}`
+
+type fixture struct {
+	router *chi.Mux
+	store  core.DocumentStore
+	tokens map[string]string
+	// seeded rooms and their elements
+	seeded map[string][]any
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	verifier := auth.NewVerifier([]byte("secret"))
+	f := &fixture{
+		store:  memory.NewDocumentStore(),
+		tokens: map[string]string{"admin": "admin-token"},
+		seeded: make(map[string][]any),
+	}
+	token, err := verifier.Sign(&auth.Claims{Subject: "alice"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	f.tokens["alice"] = token
+
+	store := f.store.(core.TemplateStore)
+	roomOpts := RoomOptions{
+		Seed: func(_ context.Context, roomID string, elements []any) error {
+			if _, exists := f.seeded[roomID]; exists {
+				return websocket.ErrRoomInUse
+			}
+			f.seeded[roomID] = elements
+			return nil
+		},
+		CanJoin: func(_ context.Context, roomID, userID string) (bool, error) {
+			return roomID != "private" || userID == "alice", nil
+		},
+	}
+	requireAdmin := auth.RequireToken("admin-token")
+	f.router = chi.NewRouter()
+	f.router.Route("/templates", func(r chi.Router) {
+		r.Use(auth.Middleware(verifier, false))
+		r.Get("/", HandleList(store))
+		r.With(requireAdmin).Post("/", HandleCreate(store))
+		r.Route("/{templateId}", func(r chi.Router) {
+			r.Get("/", HandleGet(store))
+			r.Get("/preview", HandlePreview(store, thumbnails.NewService(f.store.(core.ThumbnailStore))))
+			r.With(requireAdmin).Put("/", HandleUpdate(store))
+			r.With(requireAdmin).Delete("/", HandleDelete(store))
+			r.With(auth.Middleware(verifier, true)).Post("/canvas", HandleCreateCanvas(store, f.store.(core.CanvasStore)))
+			r.Post("/room", HandleCreateRoom(store, roomOpts))
+		})
+	})
+	return f
+}
+
+func (f *fixture) do(method, path, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+f.tokens[user])
+	}
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (f *fixture) create(t *testing.T) string {
+	t.Helper()
+	rec := f.do(http.MethodPost, "/templates/", "admin", templateBody)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp CreateTemplateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.ID
+}
+
+func TestAdminOnly(t *testing.T) {
+	f := newFixture(t)
+	if rec := f.do(http.MethodPost, "/templates/", "alice", templateBody); rec.Code != http.StatusUnauthorized {
+		t.Errorf("create as a user status = %d, want 401", rec.Code)
+	}
+	id := f.create(t)
+	if rec := f.do(http.MethodDelete, "/templates/"+id, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous delete status = %d, want 401", rec.Code)
+	}
+
+	for name, body := range map[string]string{
+		"no name":      `{"scene":{"elements":[]}}`,
+		"no scene":     `{"name":"Retro"}`,
+		"not a scene":  `{"name":"Retro","scene":{"type":"excalidrawlib"}}`,
+		"control char": `{"name":"Re\ttro","scene":{"elements":[]}}`,
+	} {
+		if rec := f.do(http.MethodPost, "/templates/", "admin", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: create status = %d, want 400", name, rec.Code)
+		}
+	}
+}
+
+func TestGallery(t *testing.T) {
+	f := newFixture(t)
+	id := f.create(t)
+
+	rec := f.do(http.MethodGet, "/templates/?category=meetings", "", "")
+	var listed []core.Template
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil || len(listed) != 1 || listed[0].ID != id || listed[0].Data != nil {
+		t.Fatalf("list = %+v, %v", listed, err)
+	}
+	rec = f.do(http.MethodGet, "/templates/?category=planning", "", "")
+	if strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("list of an empty category = %s, want []", rec.Body.String())
+	}
+
+	rec = f.do(http.MethodGet, "/templates/"+id, "", "")
+	var template core.Template
+	if err := json.NewDecoder(rec.Body).Decode(&template); err != nil || template.Name != "Retro" || len(template.Data) == 0 {
+		t.Errorf("get = %+v, %v", template, err)
+	}
+
+	rec = f.do(http.MethodGet, "/templates/"+id+"/preview", "", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || rec.Header().Get("ETag") == "" {
+		t.Errorf("preview status = %d, headers %v", rec.Code, rec.Header())
+	}
+
+	update := strings.Replace(templateBody, `"Retro"`, `"Sprint retro"`, 1)
+	if rec := f.do(http.MethodPut, "/templates/"+id, "admin", update); rec.Code != http.StatusNoContent {
+		t.Fatalf("update status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := f.do(http.MethodPut, "/templates/missing", "admin", update); rec.Code != http.StatusNotFound {
+		t.Errorf("update of an unknown template status = %d, want 404", rec.Code)
+	}
+	if rec := f.do(http.MethodDelete, "/templates/"+id, "admin", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d", rec.Code)
+	}
+	if rec := f.do(http.MethodGet, "/templates/"+id, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", rec.Code)
+	}
+}
+
+func TestCreateCanvas(t *testing.T) {
+	f := newFixture(t)
+	id := f.create(t)
+	path := "/templates/" + id + "/canvas"
+
+	if rec := f.do(http.MethodPost, path, "", `{"key":"retro"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous status = %d, want 401", rec.Code)
+	}
+	if rec := f.do(http.MethodPost, path, "alice", `{"key":"../retro"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid key status = %d, want 400", rec.Code)
+	}
+
+	rec := f.do(http.MethodPost, path, "alice", `{"key":"retro"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	canvas, err := f.store.(core.CanvasStore).GetCanvas(context.Background(), "alice", "retro")
+	if err != nil || canvas.Name != "Retro" || !strings.Contains(string(canvas.Data), `"rectangle"`) {
+		t.Errorf("saved canvas = %+v, %v", canvas, err)
+	}
+
+	if rec := f.do(http.MethodPost, path, "alice", `{"key":"retro"}`); rec.Code != http.StatusConflict {
+		t.Errorf("existing key status = %d, want 409", rec.Code)
+	}
+}
+
+func TestCreateRoom(t *testing.T) {
+	f := newFixture(t)
+	id := f.create(t)
+	path := "/templates/" + id + "/room"
+
+	rec := f.do(http.MethodPost, path, "", `{}`)
+	var resp RoomResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusCreated || len(resp.RoomID) != 20 {
+		t.Fatalf("new room status = %d, %+v, %v", rec.Code, resp, err)
+	}
+	if elements := f.seeded[resp.RoomID]; len(elements) != 1 {
+		t.Errorf("seeded elements = %v, want the template's", elements)
+	}
+
+	if rec := f.do(http.MethodPost, path, "", `{"room_id":"`+resp.RoomID+`"}`); rec.Code != http.StatusConflict {
+		t.Errorf("seeding a used room status = %d, want 409", rec.Code)
+	}
+	if rec := f.do(http.MethodPost, path, "", `{"room_id":"private"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("anonymous seeding of a private room status = %d, want 403", rec.Code)
+	}
+	if rec := f.do(http.MethodPost, path, "alice", `{"room_id":"private"}`); rec.Code != http.StatusCreated {
+		t.Errorf("seeding an allowed private room status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := f.do(http.MethodPost, "/templates/missing/room", "", `{}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown template status = %d, want 404", rec.Code)
+	}
+}
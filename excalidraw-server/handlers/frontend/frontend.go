@@ -0,0 +1,91 @@
+// Package frontend serves a prebuilt Excalidraw web app from disk.
+package frontend
+
+import (
+	"bytes"
+	"excalidraw-server/apierror"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DirFromEnv reads FRONTEND_DIR, a directory holding a frontend build with an
+// index.html at its root. It returns "" when unset, which disables serving.
+func DirFromEnv() (string, error) {
+	dir := os.Getenv("FRONTEND_DIR")
+	if dir == "" {
+		return "", nil
+	}
+	info, err := os.Stat(filepath.Join(dir, "index.html"))
+	if err != nil || info.IsDir() {
+		return "", fmt.Errorf("FRONTEND_DIR %q has no index.html", dir)
+	}
+	return dir, nil
+}
+
+// Handler serves the files in dir and is meant to be mounted at "/*". Paths
+// that don't name a file get index.html so client-side routes such as room
+// links load the app, except under /api/ and /socket.io/ where a missing
+// route must stay a 404. A non-empty head is inserted into index.html just
+// before </head>.
+func Handler(dir string, head []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + chi.URLParam(r, "*"))
+		if strings.HasPrefix(name, "/api/") || strings.HasPrefix(name, "/socket.io/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() || name == "/index.html" {
+			serveIndex(w, r, dir, head)
+			return
+		}
+
+		if strings.HasPrefix(name, "/assets/") {
+			// Vite fingerprints everything under assets/
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		http.ServeFile(w, r, file)
+	}
+}
+
+func serveIndex(w http.ResponseWriter, r *http.Request, dir string, head []byte) {
+	file := filepath.Join(dir, "index.html")
+	info, err := os.Stat(file)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Frontend not available")
+		return
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Frontend not available")
+		return
+	}
+	if len(head) > 0 {
+		data = injectHead(data, head)
+	}
+
+	// index.html names the current asset bundle, so it must be revalidated
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "index.html", info.ModTime(), bytes.NewReader(data))
+}
+
+// injectHead inserts head before </head>, or at the start of documents
+// without one.
+func injectHead(page, head []byte) []byte {
+	end := bytes.Index(bytes.ToLower(page), []byte("</head>"))
+	if end < 0 {
+		end = 0
+	}
+	injected := make([]byte, 0, len(page)+len(head))
+	injected = append(injected, page[:end]...)
+	injected = append(injected, head...)
+	return append(injected, page[end:]...)
+}
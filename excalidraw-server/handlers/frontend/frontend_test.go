@@ -0,0 +1,122 @@
+package frontend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newBuild(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"index.html":           "<html>app</html>",
+		"manifest.json":        `{"name":"Excalidraw"}`,
+		"assets/index-abc.js":  "console.log(1)",
+		"assets/index-abc.css": "body{}",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func get(t *testing.T, dir, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := chi.NewRouter()
+	r.Get("/api/rooms", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	})
+	r.Get("/*", Handler(dir, nil))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHandlerServesFiles(t *testing.T) {
+	dir := newBuild(t)
+
+	rec := get(t, dir, "/assets/index-abc.js")
+	if rec.Code != http.StatusOK || rec.Body.String() != "console.log(1)" {
+		t.Fatalf("asset = %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+		t.Errorf("asset Cache-Control = %q", got)
+	}
+
+	rec = get(t, dir, "/manifest.json")
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "" {
+		t.Errorf("manifest = %d, Cache-Control %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestHandlerFallsBackToIndex(t *testing.T) {
+	dir := newBuild(t)
+
+	for _, path := range []string{"/", "/index.html", "/room/abc", "/assets/"} {
+		rec := get(t, dir, path)
+		if rec.Code != http.StatusOK || rec.Body.String() != "<html>app</html>" {
+			t.Errorf("%s = %d %q, want index.html", path, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "no-cache" {
+			t.Errorf("%s Cache-Control = %q, want no-cache", path, got)
+		}
+	}
+}
+
+func TestHandlerLeavesAPIAlone(t *testing.T) {
+	dir := newBuild(t)
+
+	if rec := get(t, dir, "/api/rooms"); rec.Body.String() != "{}" {
+		t.Errorf("registered API route = %q", rec.Body.String())
+	}
+	for _, path := range []string{"/api/unknown", "/socket.io/x"} {
+		if rec := get(t, dir, path); rec.Code != http.StatusNotFound {
+			t.Errorf("%s = %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+func TestDirFromEnv(t *testing.T) {
+	t.Setenv("FRONTEND_DIR", "")
+	if dir, err := DirFromEnv(); dir != "" || err != nil {
+		t.Errorf("DirFromEnv() = %q, %v, want disabled", dir, err)
+	}
+
+	t.Setenv("FRONTEND_DIR", t.TempDir())
+	if _, err := DirFromEnv(); err == nil {
+		t.Error("DirFromEnv() should fail without index.html")
+	}
+
+	build := newBuild(t)
+	t.Setenv("FRONTEND_DIR", build)
+	if dir, err := DirFromEnv(); dir != build || err != nil {
+		t.Errorf("DirFromEnv() = %q, %v", dir, err)
+	}
+}
+
+func TestHandlerInjectsHead(t *testing.T) {
+	dir := newBuild(t)
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html><HEAD><title>x</title></HEAD><body></body></html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/*", Handler(dir, []byte("<script>cfg</script>")))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/room/abc", nil))
+
+	want := "<html><HEAD><title>x</title><script>cfg</script></HEAD><body></body></html>"
+	if rec.Body.String() != want {
+		t.Errorf("index.html = %q, want %q", rec.Body.String(), want)
+	}
+}
@@ -0,0 +1,259 @@
+// Package share serves link previews for shared drawings: a page with
+// OpenGraph tags at /share/{id} and an oEmbed endpoint, so pasting a link
+// into Slack, Notion or Teams unfurls into a thumbnail of the drawing.
+package share
+
+import (
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"excalidraw-server/export"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/api/documents"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// pathPrefix starts the share page path, /share/{id}
+	pathPrefix = "/share/"
+	// maxPhotoSize bounds the oEmbed photo when the consumer sets no limit
+	maxPhotoSize = 2048
+	// cacheAge is how long consumers may cache oEmbed responses, in seconds
+	cacheAge = 86400
+)
+
+type (
+	Options struct {
+		// PublicURL is the server's external URL including any base path.
+		// When empty it is derived from each request.
+		PublicURL string
+		// TrustProxy takes the scheme and host from X-Forwarded-Proto and
+		// X-Forwarded-Host when deriving the URL
+		TrustProxy bool
+		// ProviderName names this server in previews
+		ProviderName string
+	}
+
+	// OEmbed is an oEmbed photo response
+	OEmbed struct {
+		Version         string `json:"version"`
+		Type            string `json:"type"`
+		Title           string `json:"title"`
+		ProviderName    string `json:"provider_name"`
+		ProviderURL     string `json:"provider_url"`
+		URL             string `json:"url"`
+		Width           int    `json:"width"`
+		Height          int    `json:"height"`
+		ThumbnailURL    string `json:"thumbnail_url"`
+		ThumbnailWidth  int    `json:"thumbnail_width"`
+		ThumbnailHeight int    `json:"thumbnail_height"`
+		CacheAge        int    `json:"cache_age"`
+	}
+)
+
+// PublicURLFromEnv reads PUBLIC_URL, the server's external http(s) URL. It
+// returns "" when unset.
+func PublicURLFromEnv() (string, error) {
+	value := os.Getenv("PUBLIC_URL")
+	if value == "" {
+		return "", nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("PUBLIC_URL %q must be an absolute http or https URL", value)
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+// baseURL returns the server's external URL. Without PublicURL it is taken
+// from the request, whose path minus suffix is the base path.
+func (o Options) baseURL(r *http.Request, suffix string) string {
+	if o.PublicURL != "" {
+		return o.PublicURL
+	}
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if o.TrustProxy {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme + "://" + host + strings.TrimSuffix(r.URL.Path, suffix)
+}
+
+// title is the drawing's name, or a generic one
+func title(scene *export.Scene) string {
+	if name := strings.TrimSpace(scene.AppState.Name); name != "" {
+		return name
+	}
+	return "Excalidraw drawing"
+}
+
+// loadScene reads a shared document that can be previewed
+func loadScene(r *http.Request, store core.DocumentStore, id string) (*export.Scene, error) {
+	document, err := store.FindID(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	return export.ParseScene(document.Data.Bytes())
+}
+
+var pageTemplate = template.Must(template.New("share").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} · {{.Provider}}</title>
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="{{.Provider}}">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:url" content="{{.PageURL}}">
+<meta property="og:image" content="{{.ImageURL}}">
+<meta property="og:image:width" content="{{.ImageWidth}}">
+<meta property="og:image:height" content="{{.ImageHeight}}">
+<meta name="twitter:card" content="summary_large_image">
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="{{.Title}}">
+<style>body{margin:0;display:flex;min-height:100vh;align-items:center;justify-content:center;background:#fff}img{max-width:100%;height:auto}</style>
+</head>
+<body>
+<img src="{{.SVGURL}}" alt="{{.Title}}">
+</body>
+</html>
+`))
+
+// HandlePage serves the page shared links point at, which shows the drawing
+// and carries the OpenGraph tags and oEmbed discovery link for unfurling
+func HandlePage(store core.DocumentStore, opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		scene, err := loadScene(r, store, id)
+		if err != nil {
+			if errors.Is(err, export.ErrNotScene) {
+				apierror.Write(w, http.StatusUnprocessableEntity, apierror.NotAScene, "This drawing is end-to-end encrypted and can't be previewed")
+				return
+			}
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.DocumentNotFound, "not found")
+			return
+		}
+
+		base := opts.baseURL(r, pathPrefix+id)
+		pageURL := base + pathPrefix + url.PathEscape(id)
+		documentURL := base + "/api/v2/" + url.PathEscape(id)
+		data := map[string]any{
+			"Title":       title(scene),
+			"Provider":    opts.ProviderName,
+			"PageURL":     pageURL,
+			"ImageURL":    documentURL + "/og.png",
+			"ImageWidth":  documents.OGImageWidth,
+			"ImageHeight": documents.OGImageHeight,
+			"SVGURL":      documentURL + "/export.svg",
+			"OEmbedURL":   base + "/oembed?format=json&url=" + url.QueryEscape(pageURL),
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		if err := pageTemplate.Execute(w, data); err != nil {
+			logrus.WithField("document_id", id).WithField("error", err).Warn("Failed to write share page")
+		}
+	}
+}
+
+// documentID returns the document id of a share page URL
+func documentID(shareURL string) (string, bool) {
+	parsed, err := url.Parse(shareURL)
+	if err != nil {
+		return "", false
+	}
+	i := strings.LastIndex(parsed.Path, pathPrefix)
+	if i < 0 {
+		return "", false
+	}
+	id := strings.TrimSuffix(parsed.Path[i+len(pathPrefix):], "/")
+	if id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+// dimension parses an optional positive maxwidth or maxheight parameter
+func dimension(value string) (int, bool) {
+	if value == "" {
+		return maxPhotoSize, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return min(n, maxPhotoSize), true
+}
+
+// HandleOEmbed describes a share page URL as an oEmbed photo of the drawing,
+// fitted into the consumer's maxwidth and maxheight
+func HandleOEmbed(store core.DocumentStore, opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if format := query.Get("format"); format != "" && format != "json" {
+			apierror.Write(w, http.StatusNotImplemented, apierror.UnsupportedFormat, "Only the json format is supported")
+			return
+		}
+		maxWidth, okWidth := dimension(query.Get("maxwidth"))
+		maxHeight, okHeight := dimension(query.Get("maxheight"))
+		if !okWidth || !okHeight {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "maxwidth and maxheight must be positive integers")
+			return
+		}
+
+		id, ok := documentID(query.Get("url"))
+		if !ok {
+			apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Not a shared drawing URL")
+			return
+		}
+		scene, err := loadScene(r, store, id)
+		if err != nil {
+			apierror.Write(w, deadline.Status(err, http.StatusNotFound), apierror.DocumentNotFound, "not found")
+			return
+		}
+
+		width, height := export.Size(scene)
+		scale := math.Min(1, math.Min(float64(maxWidth)/width, float64(maxHeight)/height))
+		// Round down so the rendered PNG matches the size reported here
+		scale = math.Floor(scale*1e4) / 1e4
+		base := opts.baseURL(r, "/oembed")
+		documentURL := base + "/api/v2/" + url.PathEscape(id)
+		photoURL := documentURL + "/export.png"
+		if scale < 1 {
+			photoURL += "?scale=" + strconv.FormatFloat(scale, 'f', -1, 64)
+		}
+
+		render.JSON(w, r, OEmbed{
+			Version:         "1.0",
+			Type:            "photo",
+			Title:           title(scene),
+			ProviderName:    opts.ProviderName,
+			ProviderURL:     base + "/",
+			URL:             photoURL,
+			Width:           int(math.Ceil(width * scale)),
+			Height:          int(math.Ceil(height * scale)),
+			ThumbnailURL:    documentURL + "/og.png",
+			ThumbnailWidth:  documents.OGImageWidth,
+			ThumbnailHeight: documents.OGImageHeight,
+			CacheAge:        cacheAge,
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package share
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newRouter(t *testing.T, opts Options) (*chi.Mux, string, string) {
+	t.Helper()
+	store := memory.NewDocumentStore()
+	create := func(data string) string {
+		id, err := store.Create(context.Background(), &core.Document{Data: *bytes.NewBufferString(data)})
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		return id
+	}
+	scene := create(`{"elements":[{"type":"rectangle","x":0,"y":0,"width":380,"height":180}],"appState":{"name":"Plan <v2>"}}`)
+	encrypted := create("\x00\x01 encrypted")
+
+	r := chi.NewRouter()
+	r.Get("/share/{id}", HandlePage(store, opts))
+	r.Get("/oembed", HandleOEmbed(store, opts))
+	return r, scene, encrypted
+}
+
+func get(r http.Handler, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+	return rec
+}
+
+func TestHandlePage(t *testing.T) {
+	r, scene, encrypted := newRouter(t, Options{ProviderName: "Sketches"})
+
+	rec := get(r, "http://draw.example.com/share/"+scene)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	page := rec.Body.String()
+	for _, want := range []string{
+		`<meta property="og:image" content="http://draw.example.com/api/v2/` + scene + `/og.png">`,
+		`<meta property="og:title" content="Plan &lt;v2&gt;">`,
+		`<meta property="og:site_name" content="Sketches">`,
+		`href="http://draw.example.com/oembed?format=json&amp;url=` + url.QueryEscape("http://draw.example.com/share/"+scene) + `"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page is missing %s", want)
+		}
+	}
+
+	if rec := get(r, "/share/"+encrypted); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("encrypted document status = %d, want 422", rec.Code)
+	}
+	if rec := get(r, "/share/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing document status = %d, want 404", rec.Code)
+	}
+}
+
+func TestPublicURL(t *testing.T) {
+	r, scene, _ := newRouter(t, Options{PublicURL: "https://example.com/draw", ProviderName: "Sketches"})
+	req := httptest.NewRequest(http.MethodGet, "http://internal:3002/share/"+scene, http.NoBody)
+	req.Header.Set("X-Forwarded-Host", "evil.example")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `content="https://example.com/draw/api/v2/`+scene+`/og.png"`) {
+		t.Errorf("page doesn't use PUBLIC_URL: %s", rec.Body.String())
+	}
+
+	// Forwarded headers only count behind a trusted proxy
+	r, scene, _ = newRouter(t, Options{TrustProxy: true})
+	req = httptest.NewRequest(http.MethodGet, "http://internal:3002/share/"+scene, http.NoBody)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "draw.example.com")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `content="https://draw.example.com/share/`+scene+`"`) {
+		t.Errorf("page doesn't use forwarded headers: %s", rec.Body.String())
+	}
+}
+
+func TestPublicURLFromEnv(t *testing.T) {
+	t.Setenv("PUBLIC_URL", "https://example.com/draw/")
+	if got, err := PublicURLFromEnv(); err != nil || got != "https://example.com/draw" {
+		t.Errorf("PublicURLFromEnv() = %q, %v", got, err)
+	}
+	t.Setenv("PUBLIC_URL", "example.com")
+	if _, err := PublicURLFromEnv(); err == nil {
+		t.Error("PublicURLFromEnv() accepted a URL without a scheme")
+	}
+}
+
+func TestHandleOEmbed(t *testing.T) {
+	r, scene, encrypted := newRouter(t, Options{ProviderName: "Sketches"})
+	shareURL := url.QueryEscape("http://draw.example.com/share/" + scene)
+
+	rec := get(r, "http://draw.example.com/oembed?url="+shareURL)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var embed OEmbed
+	if err := json.Unmarshal(rec.Body.Bytes(), &embed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	// The 380 × 180 rectangle plus outline and padding is 401 × 201
+	if embed.Type != "photo" || embed.Version != "1.0" || embed.Title != "Plan <v2>" || embed.ProviderName != "Sketches" {
+		t.Errorf("oEmbed = %+v", embed)
+	}
+	if embed.Width != 401 || embed.Height != 201 || embed.URL != "http://draw.example.com/api/v2/"+scene+"/export.png" {
+		t.Errorf("photo = %s %d × %d", embed.URL, embed.Width, embed.Height)
+	}
+	if embed.ThumbnailURL != "http://draw.example.com/api/v2/"+scene+"/og.png" {
+		t.Errorf("thumbnail_url = %s", embed.ThumbnailURL)
+	}
+
+	// The photo is scaled down to fit maxwidth
+	rec = get(r, "/oembed?maxwidth=200&url="+shareURL)
+	embed = OEmbed{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &embed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if embed.Width > 200 || !strings.HasSuffix(embed.URL, "/export.png?scale=0.4987") {
+		t.Errorf("fitted photo = %s %d × %d", embed.URL, embed.Width, embed.Height)
+	}
+
+	for target, want := range map[string]int{
+		"/oembed?format=xml&url=" + shareURL:                          http.StatusNotImplemented,
+		"/oembed?maxwidth=-1&url=" + shareURL:                         http.StatusBadRequest,
+		"/oembed?url=" + url.QueryEscape("http://other/page"):         http.StatusNotFound,
+		"/oembed?url=" + url.QueryEscape("http://x/share/missing"):    http.StatusNotFound,
+		"/oembed?url=" + url.QueryEscape("http://x/share/"+encrypted): http.StatusNotFound,
+	} {
+		if rec := get(r, target); rec.Code != want {
+			t.Errorf("GET %s status = %d, want %d", target, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"excalidraw-server/abuse"
+	"excalidraw-server/apierror"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+var (
+	abuseTracker      *abuse.Tracker
+	abuseTrackerMutex sync.RWMutex
+)
+
+// SetAbuseTracker makes malformed and oversized events count against their
+// client, which is disconnected and refused for a while once tracker bans
+// it. nil turns tracking off.
+func SetAbuseTracker(tracker *abuse.Tracker) {
+	abuseTrackerMutex.Lock()
+	defer abuseTrackerMutex.Unlock()
+	abuseTracker = tracker
+}
+
+func getAbuseTracker() *abuse.Tracker {
+	abuseTrackerMutex.RLock()
+	defer abuseTrackerMutex.RUnlock()
+	return abuseTracker
+}
+
+// abuseKeys returns the keys a client is tracked under, like the rate
+// limiter's: its user id when authenticated, then its remote address.
+func abuseKeys(user *UserInfo, address string) []string {
+	keys := make([]string, 0, 2)
+	if user != nil {
+		keys = append(keys, "user:"+user.ID)
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	if address != "" {
+		keys = append(keys, "ip:"+address)
+	}
+	return keys
+}
+
+// violationOf tells which violation a failed event is, if the failure is
+// the client's doing.
+func violationOf(err error) (abuse.Violation, bool) {
+	switch err.(type) {
+	case *payloadError:
+		return abuse.MalformedFrame, true
+	case *tooLargeError:
+		return abuse.OversizedPayload, true
+	}
+	return "", false
+}
+
+// reportViolation counts a violation against a client and reports whether
+// the client is banned.
+func reportViolation(user *UserInfo, address string, violation abuse.Violation) bool {
+	tracker := getAbuseTracker()
+	keys := abuseKeys(user, address)
+	if tracker == nil || len(keys) == 0 {
+		return false
+	}
+	_, banned := tracker.Report(keys[0], violation)
+	return banned
+}
+
+// reportSocketViolation is reportViolation for a collab socket, which is
+// disconnected once banned.
+func reportSocketViolation(socket *socketio.Socket, err error) {
+	violation, ok := violationOf(err)
+	if ok && reportViolation(socketUser(socket), clientAddress(socket.Handshake()), violation) {
+		// the disconnect runs the socket's leaves, which may be queued on
+		// the room worker this runs on
+		go socket.Disconnect(true)
+	}
+}
+
+// abuseBan returns the ban in effect on a client, if any.
+func abuseBan(user *UserInfo, address string) (abuse.Ban, bool) {
+	tracker := getAbuseTracker()
+	if tracker == nil {
+		return abuse.Ban{}, false
+	}
+	return tracker.Banned(abuseKeys(user, address)...)
+}
+
+// retryAfterSeconds is how many whole seconds are left of a ban.
+func retryAfterSeconds(ban abuse.Ban) int {
+	return max(1, int(math.Ceil(time.Until(ban.Until).Seconds())))
+}
+
+// banMiddleware refuses sockets of banned clients. It runs after
+// authentication, so authenticated users are recognised from any address.
+func banMiddleware(socket *socketio.Socket, next func(*socketio.ExtendedError)) {
+	if ban, banned := abuseBan(socketUser(socket), clientAddress(socket.Handshake())); banned {
+		next(socketio.NewExtendedError("temporarily banned", map[string]any{
+			"code":       apierror.Banned,
+			"retryAfter": retryAfterSeconds(ban),
+		}))
+		return
+	}
+	next(nil)
+}
@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"excalidraw-server/abuse"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	ws "github.com/gorilla/websocket"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func TestRawAbuseBan(t *testing.T) {
+	tracker := abuse.NewTracker(abuse.Config{Threshold: 2, Window: time.Minute, BanDuration: time.Minute, MaxBanDuration: time.Hour})
+	SetAbuseTracker(tracker)
+	defer SetAbuseTracker(nil)
+
+	r := chi.NewRouter()
+	r.Get("/ws/rooms/{roomId}", HandleRawSocket(socketio.NewServer(nil, nil), AuthOptions{Mode: AuthOff}))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	conn := dialRaw(t, server, "abuse-room")
+	expectRaw(t, conn, "joined")
+	if err := conn.WriteMessage(ws.TextMessage, []byte(`not json`)); err != nil {
+		t.Fatalf("WriteMessage() failed: %v", err)
+	}
+	expectRaw(t, conn, "error")
+	if err := conn.WriteMessage(ws.TextMessage, []byte(`still not json`)); err != nil {
+		t.Fatalf("WriteMessage() failed: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !ws.IsCloseError(err, ws.ClosePolicyViolation) {
+				t.Fatalf("the banned peer wasn't closed: %v", err)
+			}
+			break
+		}
+	}
+
+	bans := tracker.Bans()
+	if len(bans) != 1 || bans[0].Key != "ip:127.0.0.1" || bans[0].Violation != abuse.MalformedFrame {
+		t.Fatalf("bans = %+v", bans)
+	}
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/rooms/abuse-room"
+	_, response, err := ws.DefaultDialer.Dial(url, nil)
+	if err == nil || response == nil || response.StatusCode != http.StatusForbidden || response.Header.Get("Retry-After") == "" {
+		t.Errorf("a banned client connected: %v", err)
+	}
+}
+
+// connectSocketIO opens a socket.io connection on the default namespace
+// with header and returns the server's answer to the CONNECT packet.
+func connectSocketIO(t *testing.T, server *httptest.Server, header http.Header) string {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/socket.io/?EIO=4&transport=websocket"
+	conn, _, err := ws.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, open, err := conn.ReadMessage(); err != nil || !strings.HasPrefix(string(open), "0") {
+		t.Fatalf("engine.io open = %q, %v", open, err)
+	}
+	if err := conn.WriteMessage(ws.TextMessage, []byte("40")); err != nil {
+		t.Fatalf("WriteMessage() failed: %v", err)
+	}
+	_, answer, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() failed: %v", err)
+	}
+	return string(answer)
+}
+
+func TestSocketBansBehindProxy(t *testing.T) {
+	tracker := abuse.NewTracker(abuse.Config{Threshold: 1, Window: time.Minute, BanDuration: time.Minute, MaxBanDuration: time.Hour})
+	SetAbuseTracker(tracker)
+	defer SetAbuseTracker(nil)
+	SetTrustProxy(true)
+	defer SetTrustProxy(false)
+
+	srv := socketio.NewServer(nil, nil)
+	srv.Use(banMiddleware)
+	server := httptest.NewServer(srv.ServeHandler(nil))
+	defer server.Close()
+
+	// Both clients reach the server from the proxy's address
+	tracker.Report("ip:203.0.113.1", abuse.MalformedFrame)
+	abusive := connectSocketIO(t, server, http.Header{"X-Forwarded-For": {"203.0.113.1, 10.0.0.1"}})
+	if !strings.HasPrefix(abusive, "44") || !strings.Contains(abusive, "temporarily banned") {
+		t.Errorf("banned client's CONNECT answer = %q", abusive)
+	}
+	other := connectSocketIO(t, server, http.Header{"X-Forwarded-For": {"203.0.113.2, 10.0.0.1"}})
+	if !strings.HasPrefix(other, "40") {
+		t.Errorf("another client behind the proxy was refused: %q", other)
+	}
+
+	bans := tracker.Bans()
+	if len(bans) != 1 || bans[0].Key != "ip:203.0.113.1" {
+		t.Errorf("bans = %+v", bans)
+	}
+}
@@ -0,0 +1,252 @@
+package websocket
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const (
+	// AdminNamespace streams server stats to operators.
+	AdminNamespace = "/admin"
+
+	defaultAdminInterval = time.Second
+	// busiestRooms is how many rooms the stats describe one by one
+	busiestRooms = 10
+)
+
+// AdminOptions configures the admin namespace.
+type AdminOptions struct {
+	// Token is the bearer token admin sockets pass as auth.token.
+	Token string
+	// Interval is how often stats are sent; zero means every second.
+	Interval time.Duration
+	// Config describes the running configuration; nil sends none.
+	Config func() any
+	// ProbeStore makes a cheap store call to measure its latency; nil
+	// leaves store latency out.
+	ProbeStore func(ctx context.Context) error
+}
+
+// AdminIntervalFromEnv reads ADMIN_STATS_INTERVAL, how often the admin
+// namespace sends stats.
+func AdminIntervalFromEnv() (time.Duration, error) {
+	value := os.Getenv("ADMIN_STATS_INTERVAL")
+	if value == "" {
+		return defaultAdminInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 100*time.Millisecond {
+		return 0, fmt.Errorf("invalid ADMIN_STATS_INTERVAL %q: must be a duration of at least 100ms", value)
+	}
+	return interval, nil
+}
+
+type (
+	// RoomSummary is a room as the admin stats describe it.
+	RoomSummary struct {
+		RoomID            string `json:"room_id"`
+		Sockets           int    `json:"sockets"`
+		MessagesPerMinute int64  `json:"messages_per_minute"`
+	}
+
+	// ServerStats is a snapshot of the whole server.
+	ServerStats struct {
+		Time    int64 `json:"time"`
+		Rooms   int   `json:"rooms"`
+		Sockets int   `json:"sockets"`
+		// Broadcasts counts broadcasts since the server started.
+		Broadcasts          int64   `json:"broadcasts"`
+		BroadcastsPerSecond float64 `json:"broadcasts_per_second"`
+		BytesPerSecond      float64 `json:"bytes_per_second"`
+		// Busiest are the rooms with the most broadcasts over the last
+		// minute.
+		Busiest     []RoomSummary    `json:"busiest"`
+		Hibernation HibernationStats `json:"hibernation"`
+		Chat        ChatStats        `json:"chat"`
+		// StoreLatency is how long the last store probe took, in
+		// milliseconds.
+		StoreLatency float64 `json:"store_latency_ms,omitempty"`
+		StoreError   string  `json:"store_error,omitempty"`
+		Goroutines   int     `json:"goroutines"`
+		HeapBytes    uint64  `json:"heap_bytes"`
+	}
+)
+
+// Admin streams ServerStats and the running configuration to the sockets
+// of the admin namespace.
+type Admin struct {
+	nsp  socketio.NamespaceInterface
+	main socketio.NamespaceInterface
+	opts AdminOptions
+
+	mu sync.Mutex
+	// last is the previous sample, for rates
+	last          time.Time
+	lastMessages  int64
+	lastBytes     int64
+	storeLatency  time.Duration
+	storeError    error
+	probeInFlight bool
+}
+
+// SetupAdmin registers the admin namespace on srv. Sockets must pass the
+// token as auth.token; they get config on connect and after every reload
+// and stats every interval while connected.
+func SetupAdmin(srv *socketio.Server, opts AdminOptions) *Admin {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultAdminInterval
+	}
+	a := &Admin{
+		nsp:  srv.Of(AdminNamespace, nil),
+		main: srv.Sockets(),
+		opts: opts,
+		last: time.Now(),
+	}
+	a.nsp.Use(func(socket *socketio.Socket, next func(*socketio.ExtendedError)) {
+		token := tokenFromHandshake(socket.Handshake().Auth)
+		if opts.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(opts.Token)) != 1 {
+			logrus.WithField("socket_id", socket.Id()).Warn("Rejected admin socket with invalid token")
+			next(socketio.NewExtendedError("invalid token", nil))
+			return
+		}
+		next(nil)
+	})
+	//nolint:errcheck // Socket.IO event handlers do not return useful errors
+	a.nsp.On("connection", func(clients ...any) {
+		socket, ok := clients[0].(*socketio.Socket)
+		if !ok {
+			return
+		}
+		logrus.WithField("socket_id", socket.Id()).Info("Admin socket connected")
+		if opts.Config != nil {
+			_ = socket.Emit("config", opts.Config())
+		}
+		_ = socket.Emit("stats", a.stats(time.Now(), false))
+	})
+	return a
+}
+
+// PublishConfig sends the running configuration to every admin socket,
+// e.g. after a reload.
+func (a *Admin) PublishConfig() {
+	if a.opts.Config != nil && a.nsp.Sockets().Len() > 0 {
+		_ = a.nsp.Emit("config", a.opts.Config())
+	}
+}
+
+// Start sends stats every interval while admin sockets are connected,
+// until stop is closed.
+func (a *Admin) Start(stop <-chan struct{}) {
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if a.nsp.Sockets().Len() == 0 {
+				continue
+			}
+			a.probeStore()
+			_ = a.nsp.Emit("stats", a.stats(now, true))
+		}
+	}
+}
+
+// probeStore measures the store's latency in the background, so a slow
+// store delays its own numbers rather than the stats.
+func (a *Admin) probeStore() {
+	if a.opts.ProbeStore == nil {
+		return
+	}
+	a.mu.Lock()
+	if a.probeInFlight {
+		a.mu.Unlock()
+		return
+	}
+	a.probeInFlight = true
+	a.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		start := time.Now()
+		err := a.opts.ProbeStore(ctx)
+		latency := time.Since(start)
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.probeInFlight = false
+		a.storeLatency, a.storeError = latency, err
+	}()
+}
+
+// stats samples the server. With advance, rates are measured since the
+// previous advancing sample; otherwise they cover the same period without
+// resetting it.
+func (a *Admin) stats(now time.Time, advance bool) ServerStats {
+	rooms := GetActiveRooms()
+	stats := ServerStats{
+		Time:        now.UnixMilli(),
+		Rooms:       len(rooms),
+		Sockets:     a.main.Sockets().Len(),
+		Broadcasts:  broadcastsTotal.Load(),
+		Busiest:     busiest(rooms, now),
+		Hibernation: GetHibernationStats(),
+		Chat:        GetChatStats(),
+		Goroutines:  runtime.NumGoroutine(),
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats.HeapBytes = mem.HeapAlloc
+
+	bytes := broadcastBytesTotal.Load()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if elapsed := now.Sub(a.last).Seconds(); elapsed > 0 {
+		stats.BroadcastsPerSecond = float64(stats.Broadcasts-a.lastMessages) / elapsed
+		stats.BytesPerSecond = float64(bytes-a.lastBytes) / elapsed
+	}
+	if advance {
+		a.last, a.lastMessages, a.lastBytes = now, stats.Broadcasts, bytes
+	}
+	if a.storeLatency > 0 {
+		stats.StoreLatency = float64(a.storeLatency.Microseconds()) / 1000
+	}
+	if a.storeError != nil {
+		stats.StoreError = a.storeError.Error()
+	}
+	return stats
+}
+
+// busiest returns the rooms with the most broadcasts over the last minute.
+func busiest(rooms map[string]int, now time.Time) []RoomSummary {
+	summaries := make([]RoomSummary, 0, len(rooms))
+	for roomID, sockets := range rooms {
+		summary := RoomSummary{RoomID: roomID, Sockets: sockets}
+		if stats, ok := roomStatsAt(roomID, now); ok {
+			summary.MessagesPerMinute = stats.MessagesPerMinute
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].MessagesPerMinute != summaries[j].MessagesPerMinute {
+			return summaries[i].MessagesPerMinute > summaries[j].MessagesPerMinute
+		}
+		return summaries[i].RoomID < summaries[j].RoomID
+	})
+	if len(summaries) > busiestRooms {
+		summaries = summaries[:busiestRooms]
+	}
+	return summaries
+}
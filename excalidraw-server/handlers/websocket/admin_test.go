@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func TestAdminStats(t *testing.T) {
+	admin := SetupAdmin(socketio.NewServer(nil, nil), AdminOptions{
+		Token: "secret",
+		ProbeStore: func(context.Context) error {
+			time.Sleep(time.Millisecond)
+			return errors.New("store down")
+		},
+	})
+
+	roomsMutex.Lock()
+	activeRooms["admin-quiet"] = 1
+	activeRooms["admin-busy"] = 3
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(activeRooms, "admin-quiet")
+		delete(activeRooms, "admin-busy")
+		roomsMutex.Unlock()
+		clearRoomTraffic("admin-busy")
+	}()
+
+	start := time.Now()
+	admin.stats(start, true)
+	for i := 0; i < 10; i++ {
+		trackBroadcast("admin-busy", 100, start)
+	}
+
+	admin.probeStore()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		admin.mu.Lock()
+		done := !admin.probeInFlight
+		admin.mu.Unlock()
+		if done || time.Now().After(deadline) {
+			break
+		}
+	}
+
+	stats := admin.stats(start.Add(2*time.Second), true)
+	if stats.Rooms < 2 || stats.BroadcastsPerSecond != 5 || stats.BytesPerSecond != 500 {
+		t.Errorf("stats = %+v, want 5 broadcasts and 500 bytes a second", stats)
+	}
+	if len(stats.Busiest) < 2 || stats.Busiest[0].RoomID != "admin-busy" || stats.Busiest[0].Sockets != 3 || stats.Busiest[0].MessagesPerMinute != 10 {
+		t.Errorf("busiest rooms = %+v, want admin-busy first", stats.Busiest)
+	}
+	if stats.StoreLatency <= 0 || stats.StoreError != "store down" {
+		t.Errorf("store latency = %v, %q, want the probe's", stats.StoreLatency, stats.StoreError)
+	}
+
+	if again := admin.stats(start.Add(3*time.Second), false); again.BroadcastsPerSecond != 0 {
+		t.Errorf("rate without new broadcasts = %v, want 0", again.BroadcastsPerSecond)
+	}
+}
+
+func TestBusiestLimit(t *testing.T) {
+	rooms := make(map[string]int)
+	for i := 0; i < busiestRooms+5; i++ {
+		rooms[string(rune('a'+i))] = 1
+	}
+	got := busiest(rooms, time.Now())
+	if len(got) != busiestRooms || got[0].RoomID != "a" {
+		t.Errorf("busiest() = %+v, want the first %d rooms by id", got, busiestRooms)
+	}
+}
+
+func TestAdminIntervalFromEnv(t *testing.T) {
+	t.Setenv("ADMIN_STATS_INTERVAL", "")
+	if interval, err := AdminIntervalFromEnv(); err != nil || interval != time.Second {
+		t.Errorf("AdminIntervalFromEnv() unset = %v, %v, want 1s", interval, err)
+	}
+	t.Setenv("ADMIN_STATS_INTERVAL", "5s")
+	if interval, err := AdminIntervalFromEnv(); err != nil || interval != 5*time.Second {
+		t.Errorf("AdminIntervalFromEnv() = %v, %v, want 5s", interval, err)
+	}
+	for _, value := range []string{"fast", "10ms"} {
+		t.Setenv("ADMIN_STATS_INTERVAL", value)
+		if _, err := AdminIntervalFromEnv(); err == nil {
+			t.Errorf("AdminIntervalFromEnv() accepted %q", value)
+		}
+	}
+}
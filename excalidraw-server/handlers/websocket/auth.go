@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// AuthMode controls whether collab sockets must present a JWT in the handshake.
+type AuthMode string
+
+const (
+	// AuthOff ignores any token passed in the handshake.
+	AuthOff AuthMode = "off"
+	// AuthOptional verifies a token when present and rejects invalid ones.
+	AuthOptional AuthMode = "optional"
+	// AuthRequired rejects sockets that do not present a valid token.
+	AuthRequired AuthMode = "required"
+)
+
+// AuthOptions configures handshake authentication for collab sockets.
+type AuthOptions struct {
+	Mode     AuthMode
+	Verifier *auth.Verifier
+}
+
+// UserInfo is the identity attached to an authenticated socket.
+type UserInfo struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	AvatarURL string `json:"avatarUrl,omitempty"`
+}
+
+// PresenceEntry describes one socket in a room for the room-presence event.
+type PresenceEntry struct {
+	SocketID string    `json:"socketId"`
+	User     *UserInfo `json:"user,omitempty"`
+}
+
+// ParseAuthMode parses the SOCKET_AUTH setting, defaulting to AuthOff.
+func ParseAuthMode(value string) (AuthMode, error) {
+	switch mode := AuthMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "", AuthOff:
+		return AuthOff, nil
+	case AuthOptional, AuthRequired:
+		return mode, nil
+	default:
+		return AuthOff, fmt.Errorf("unknown socket auth mode %q", value)
+	}
+}
+
+func authMiddleware(opts AuthOptions) func(*socketio.Socket, func(*socketio.ExtendedError)) {
+	return func(socket *socketio.Socket, next func(*socketio.ExtendedError)) {
+		token := tokenFromHandshake(socket.Handshake().Auth)
+		if token == "" {
+			if opts.Mode == AuthRequired {
+				next(socketio.NewExtendedError("authentication required", nil))
+				return
+			}
+			next(nil)
+			return
+		}
+
+		claims, err := opts.Verifier.Verify(token)
+		if err != nil {
+			logrus.WithField("socket_id", socket.Id()).WithError(err).Warn("Rejected socket with invalid token")
+			next(socketio.NewExtendedError("invalid token", nil))
+			return
+		}
+
+		socket.SetData(userFromClaims(claims))
+		recordUser(claims)
+		next(nil)
+	}
+}
+
+func tokenFromHandshake(handshakeAuth any) string {
+	payload, ok := handshakeAuth.(map[string]any)
+	if !ok {
+		return ""
+	}
+
+	token, _ := payload["token"].(string)
+	return strings.TrimPrefix(token, "Bearer ")
+}
+
+func userFromClaims(claims *auth.Claims) *UserInfo {
+	name := claims.Name
+	if name == "" {
+		name = claims.Login
+	}
+	return &UserInfo{
+		ID:        claims.Subject,
+		Name:      name,
+		AvatarURL: claims.AvatarURL,
+	}
+}
+
+// authorizeRoomRequest checks a WebSocket upgrade into a room like the
+// collab handshake and join: the JWT comes from the token query parameter
+// or the Authorization header. It replies itself when the request may not
+// join.
+func authorizeRoomRequest(w http.ResponseWriter, r *http.Request, roomID string, authOpts AuthOptions) (*UserInfo, bool) {
+	var user *UserInfo
+	if authOpts.Mode != AuthOff {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			token = auth.TokenFromRequest(r)
+		}
+		if token != "" {
+			claims, err := authOpts.Verifier.Verify(token)
+			if err != nil {
+				apierror.Write(w, http.StatusUnauthorized, apierror.InvalidToken, "invalid token")
+				return nil, false
+			}
+			user = userFromClaims(claims)
+		} else if authOpts.Mode == AuthRequired {
+			apierror.Write(w, http.StatusUnauthorized, apierror.Unauthorized, "authentication required")
+			return nil, false
+		}
+
+		allowed, err := canJoin(r.Context(), roomID, user)
+		if err != nil {
+			logrus.WithField("room_id", roomID).WithError(err).Error("Failed to check room permissions")
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "failed to check room permissions")
+			return nil, false
+		}
+		if !allowed {
+			apierror.Write(w, http.StatusForbidden, apierror.NotAllowedInRoom, "not allowed in room")
+			return nil, false
+		}
+	}
+	if ban, banned := abuseBan(user, r.RemoteAddr); banned {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(ban)))
+		apierror.Write(w, http.StatusForbidden, apierror.Banned, "temporarily banned")
+		return nil, false
+	}
+	if _, banned := banExpiry(roomID, banKeys(user, r.RemoteAddr), time.Now()); banned {
+		apierror.Write(w, http.StatusForbidden, apierror.Banned, "banned from room")
+		return nil, false
+	}
+	return user, true
+}
+
+// socketUser returns the identity attached during the handshake, if any.
+func socketUser(socket interface{ Data() any }) *UserInfo {
+	user, _ := socket.Data().(*UserInfo)
+	return user
+}
+
+func buildPresence(users []*socketio.RemoteSocket) []PresenceEntry {
+	presence := make([]PresenceEntry, 0, len(users))
+	for _, user := range users {
+		presence = append(presence, PresenceEntry{
+			SocketID: string(user.Id()),
+			User:     socketUser(user),
+		})
+	}
+	return presence
+}
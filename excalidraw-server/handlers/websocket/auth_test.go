@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"excalidraw-server/auth"
+	"testing"
+)
+
+func TestParseAuthMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    AuthMode
+		wantErr bool
+	}{
+		{"", AuthOff, false},
+		{"off", AuthOff, false},
+		{"optional", AuthOptional, false},
+		{" Required ", AuthRequired, false},
+		{"sometimes", AuthOff, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAuthMode(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAuthMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseAuthMode(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTokenFromHandshake(t *testing.T) {
+	tests := []struct {
+		name string
+		auth any
+		want string
+	}{
+		{"nil auth", nil, ""},
+		{"not a map", "token", ""},
+		{"missing token", map[string]any{"other": "x"}, ""},
+		{"non-string token", map[string]any{"token": 42}, ""},
+		{"plain token", map[string]any{"token": "abc"}, "abc"},
+		{"bearer token", map[string]any{"token": "Bearer abc"}, "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenFromHandshake(tt.auth); got != tt.want {
+				t.Errorf("tokenFromHandshake() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserFromClaims(t *testing.T) {
+	user := userFromClaims(&auth.Claims{Subject: "42", Login: "ada", AvatarURL: "https://example.com/a.png"})
+	if user.ID != "42" {
+		t.Errorf("expected ID 42, got %s", user.ID)
+	}
+	if user.Name != "ada" {
+		t.Errorf("expected login to be used as name fallback, got %s", user.Name)
+	}
+
+	user = userFromClaims(&auth.Claims{Subject: "42", Name: "Ada Lovelace", Login: "ada"})
+	if user.Name != "Ada Lovelace" {
+		t.Errorf("expected name claim to win, got %s", user.Name)
+	}
+}
+
+type dataHolder struct{ data any }
+
+func (d dataHolder) Data() any { return d.data }
+
+func TestSocketUser(t *testing.T) {
+	if socketUser(dataHolder{}) != nil {
+		t.Error("expected nil user for anonymous socket")
+	}
+
+	user := &UserInfo{ID: "1"}
+	if got := socketUser(dataHolder{data: user}); got != user {
+		t.Errorf("expected attached user, got %+v", got)
+	}
+}
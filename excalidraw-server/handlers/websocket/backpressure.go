@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zishang520/engine.io-go-parser/packet"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const (
+	// volatileBacklog is how many packets a socket may have waiting to be
+	// written before volatile broadcasts to it are coalesced: while it is
+	// behind it only gets the latest of each sender's, once it catches up.
+	volatileBacklog = 32
+	// rawVolatileBacklog is the same for native WebSocket peers, in queued
+	// frames.
+	rawVolatileBacklog = rawSendBuffer / 4
+)
+
+// heldBroadcast is the latest volatile broadcast of a sender that a socket
+// behind on its writes hasn't been sent yet.
+type heldBroadcast struct {
+	roomID   string
+	scene    any
+	packed   []byte
+	metadata any
+	seq      int64
+}
+
+// socketBacklog follows how far behind a Socket.IO socket is on writing
+// what it was sent.
+type socketBacklog struct {
+	socket *socketio.Socket
+	// pending counts packets queued for the socket and not written yet.
+	pending int
+	// held are the volatile broadcasts coalesced for the socket while it
+	// was behind, by sender.
+	held map[socketio.SocketId]heldBroadcast
+}
+
+var (
+	backlogs = make(map[socketio.SocketId]*socketBacklog)
+	// laggingSockets are the sockets in backlogs currently behind, which
+	// volatile broadcasts skip.
+	laggingSockets = make(map[socketio.SocketId]*socketBacklog)
+	backlogsMutex  sync.Mutex
+)
+
+// watchBacklog starts following how many packets a socket has queued, from
+// its engine's packet and flush events.
+func watchBacklog(socket *socketio.Socket) {
+	id := socket.Id()
+	backlog := &socketBacklog{socket: socket}
+	backlogsMutex.Lock()
+	backlogs[id] = backlog
+	backlogsMutex.Unlock()
+
+	conn := socket.Conn()
+	//nolint:errcheck // engine event handlers do not return useful errors
+	conn.On("packetCreate", func(...any) {
+		backlogsMutex.Lock()
+		defer backlogsMutex.Unlock()
+		backlog.pending++
+		if backlog.pending == volatileBacklog && backlogs[id] == backlog {
+			logrus.WithField("socket_id", id).Debug("Coalescing volatile broadcasts for a socket that fell behind")
+			laggingSockets[id] = backlog
+		}
+	})
+	//nolint:errcheck // engine event handlers do not return useful errors
+	conn.On("flush", func(args ...any) {
+		if len(args) == 0 {
+			return
+		}
+		if packets, ok := args[0].([]*packet.Packet); ok {
+			backlogsMutex.Lock()
+			backlog.pending = max(backlog.pending-len(packets), 0)
+			backlogsMutex.Unlock()
+		}
+	})
+	// Held broadcasts go out once what was queued is written, not from
+	// within the flush that writes it
+	//nolint:errcheck // engine event handlers do not return useful errors
+	conn.On("drain", func(...any) {
+		releaseBacklog(id, backlog)
+	})
+}
+
+// forgetBacklog stops following a socket that went away, dropping what it
+// was held.
+func forgetBacklog(socketID socketio.SocketId) {
+	backlogsMutex.Lock()
+	defer backlogsMutex.Unlock()
+	delete(backlogs, socketID)
+	delete(laggingSockets, socketID)
+}
+
+// releaseBacklog sends a socket that caught up the broadcasts held for it.
+func releaseBacklog(socketID socketio.SocketId, backlog *socketBacklog) {
+	backlogsMutex.Lock()
+	if backlog.pending >= volatileBacklog/2 || laggingSockets[socketID] != backlog {
+		backlogsMutex.Unlock()
+		return
+	}
+	delete(laggingSockets, socketID)
+	held := backlog.held
+	backlog.held = nil
+	backlogsMutex.Unlock()
+
+	for _, broadcast := range held {
+		if !backlog.socket.Rooms().Has(socketio.Room(broadcast.roomID)) {
+			continue
+		}
+		scene := broadcast.scene
+		if !IsEncryptedRoom(broadcast.roomID) && usesMsgpack(broadcast.roomID, socketID) {
+			packed := broadcast.packed
+			if packed == nil {
+				var err error
+				if packed, err = packPayload(broadcast.scene); err != nil {
+					continue
+				}
+			}
+			scene = packed
+		}
+		_ = backlog.socket.Volatile().Emit("client-broadcast", scene, broadcast.metadata, broadcast.seq)
+	}
+}
+
+// holdVolatile keeps a volatile broadcast from sender for the members of
+// the room that are behind, replacing what the sender sent them before,
+// and returns their rooms for the broadcast to leave out.
+func holdVolatile(roomID string, sender socketio.SocketId, broadcast heldBroadcast) []socketio.Room {
+	backlogsMutex.Lock()
+	defer backlogsMutex.Unlock()
+	if len(laggingSockets) == 0 {
+		return nil
+	}
+
+	broadcast.roomID = roomID
+	var lagging []socketio.Room
+	for socketID, backlog := range laggingSockets {
+		if socketID == sender || !backlog.socket.Rooms().Has(socketio.Room(roomID)) {
+			continue
+		}
+		if backlog.held == nil {
+			backlog.held = make(map[socketio.SocketId]heldBroadcast)
+		}
+		backlog.held[sender] = broadcast
+		lagging = append(lagging, socketio.Room(socketID))
+	}
+	return lagging
+}
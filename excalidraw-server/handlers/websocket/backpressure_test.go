@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"testing"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func TestRawPeerCoalescesVolatileFrames(t *testing.T) {
+	peer := &rawPeer{
+		id:   "ws-slow",
+		out:  make(chan rawFrame, rawSendBuffer),
+		done: make(chan struct{}),
+		wake: make(chan struct{}, 1),
+	}
+	cursor := func(sender socketio.SocketId, x int) {
+		peer.sendVolatile(sender, rawFrame{Type: "client-broadcast", Data: x})
+	}
+
+	for x := 0; x < rawVolatileBacklog; x++ {
+		cursor("alice", x)
+	}
+	if len(peer.out) != rawVolatileBacklog {
+		t.Fatalf("queued %d frames, want %d", len(peer.out), rawVolatileBacklog)
+	}
+
+	// behind, only the latest of each sender's is kept
+	for x := 100; x < 200; x++ {
+		cursor("alice", x)
+		cursor("bob", x+1000)
+	}
+	if len(peer.out) != rawVolatileBacklog {
+		t.Errorf("queued %d frames while behind", len(peer.out))
+	}
+	select {
+	case <-peer.wake:
+	default:
+		t.Error("the write loop wasn't woken")
+	}
+
+	// held frames stay held until the queue is written, so they keep order
+	for len(peer.out) > 0 {
+		<-peer.out
+	}
+	cursor("alice", 300)
+	held := peer.takeHeld()
+	if len(held) != 2 || held["alice"].Data != 300 || held["bob"].Data != 1199 {
+		t.Errorf("held %+v", held)
+	}
+	if len(peer.out) != 0 {
+		t.Errorf("queued %d frames after catching up", len(peer.out))
+	}
+
+	cursor("alice", 400)
+	if len(peer.out) != 1 {
+		t.Errorf("a peer that caught up queued %d frames", len(peer.out))
+	}
+}
+
+func TestHoldVolatileWithoutLaggingSockets(t *testing.T) {
+	if lagging := holdVolatile("room", "sender", heldBroadcast{seq: 1}); lagging != nil {
+		t.Errorf("left out %v", lagging)
+	}
+}
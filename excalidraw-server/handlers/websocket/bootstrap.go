@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"context"
+	"excalidraw-server/apierror"
+	"sync"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// sceneInit is the last full scene broadcast in a room, kept so joiners
+// don't wait for another client to send theirs.
+type sceneInit struct {
+	// payload is in decoded form; packed is its MessagePack form when the
+	// sender used it
+	payload  any
+	packed   []byte
+	metadata any
+	// seeded scenes were put there by SeedRoom rather than a client
+	seeded bool
+}
+
+var (
+	// ErrRoomInUse is returned by SeedRoom for rooms that already have
+	// members or a scene.
+	ErrRoomInUse = apierror.New(apierror.RoomInUse, "room is in use")
+	// ErrRoomEncrypted is returned by SeedRoom when every room is end-to-end
+	// encrypted, so the server can't write a scene into one.
+	ErrRoomEncrypted = apierror.New(apierror.RoomEncrypted, "rooms are end-to-end encrypted on this server")
+)
+
+var (
+	sceneInits      = make(map[string]sceneInit)
+	sceneInitsMutex sync.RWMutex
+)
+
+// isFullScene reports whether a broadcast carries the whole scene: an
+// Excalidraw SCENE_INIT in a plain room, or any payload the client flags with
+// metadata { full: true }, which works for ciphertext too.
+func isFullScene(roomID string, payload, metadata any) bool {
+	if flags, ok := metadata.(map[string]any); ok && flags["full"] == true {
+		return true
+	}
+	if IsEncryptedRoom(roomID) {
+		return false
+	}
+	scene, ok := payload.(map[string]any)
+	return ok && scene["type"] == "SCENE_INIT"
+}
+
+// keepSceneInit remembers a broadcast for joiners if it carries the whole
+// scene.
+func keepSceneInit(roomID string, payload any, packed []byte, metadata any) {
+	if !isFullScene(roomID, payload, metadata) {
+		return
+	}
+	sceneInitsMutex.Lock()
+	defer sceneInitsMutex.Unlock()
+	sceneInits[roomID] = sceneInit{payload: payload, packed: packed, metadata: metadata}
+}
+
+// sendSceneInit sends the room's last full scene to a joiner as
+// scene-init(payload, metadata), in the joiner's encoding. It reports whether
+// there was one.
+func sendSceneInit(socket *socketio.Socket, roomID string) bool {
+	scene, exists := lastSceneInit(roomID)
+	if !exists {
+		return false
+	}
+
+	payload := scene.payload
+	if !IsEncryptedRoom(roomID) && socketEncoding(roomID, socket.Id()) == EncodingMsgpack {
+		packed := scene.packed
+		if packed == nil {
+			var err error
+			if packed, err = packPayload(scene.payload); err != nil {
+				return false
+			}
+		}
+		payload = packed
+	}
+	_ = socket.Emit("scene-init", payload, scene.metadata)
+	return true
+}
+
+// lastSceneInit returns the scene joiners of a room start from, if any.
+func lastSceneInit(roomID string) (sceneInit, bool) {
+	sceneInitsMutex.RLock()
+	scene, exists := sceneInits[roomID]
+	sceneInitsMutex.RUnlock()
+	if !exists {
+		return sceneInit{}, false
+	}
+	// A seeded scene is plaintext, which clients of an encrypted room can't
+	// read
+	if scene.seeded && IsEncryptedRoom(roomID) {
+		clearSceneInit(roomID)
+		return sceneInit{}, false
+	}
+	return scene, true
+}
+
+// SeedRoom gives an unused room a starting scene of elements, which its
+// first joiners receive as scene-init. Rooms with members, an earlier scene
+// or hibernated state fail with ErrRoomInUse.
+func SeedRoom(ctx context.Context, roomID string, elements []any) error {
+	if encryptedOnly.Load() {
+		return ErrRoomEncrypted
+	}
+	if isLiveRoom(roomID) {
+		return ErrRoomInUse
+	}
+	state, err := loadRoomState(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		return ErrRoomInUse
+	}
+
+	if elements == nil {
+		elements = []any{}
+	}
+	payload := map[string]any{
+		"type":    "SCENE_INIT",
+		"payload": map[string]any{"elements": elements},
+	}
+
+	roomScenesMutex.Lock()
+	_, hasScene := roomScenes[roomID]
+	roomScenesMutex.Unlock()
+	if hasScene {
+		return ErrRoomInUse
+	}
+
+	sceneInitsMutex.Lock()
+	defer sceneInitsMutex.Unlock()
+	if _, exists := sceneInits[roomID]; exists {
+		return ErrRoomInUse
+	}
+	sceneInits[roomID] = sceneInit{payload: payload, seeded: true}
+	return nil
+}
+
+func clearSceneInit(roomID string) {
+	sceneInitsMutex.Lock()
+	defer sceneInitsMutex.Unlock()
+	delete(sceneInits, roomID)
+}
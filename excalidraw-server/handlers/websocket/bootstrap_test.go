@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIsFullScene(t *testing.T) {
+	initRoomMode("secret", RoomModeEncrypted, true)
+	defer clearRoomMode("secret")
+
+	tests := []struct {
+		name     string
+		roomID   string
+		payload  any
+		metadata any
+		want     bool
+	}{
+		{"scene init", "plain", map[string]any{"type": "SCENE_INIT"}, nil, true},
+		{"scene update", "plain", map[string]any{"type": "SCENE_UPDATE"}, nil, false},
+		{"flagged update", "plain", map[string]any{"type": "SCENE_UPDATE"}, map[string]any{"full": true}, true},
+		{"ciphertext", "secret", []byte("ciphertext"), []byte("iv"), false},
+		{"flagged ciphertext", "secret", []byte("ciphertext"), map[string]any{"iv": []byte("iv"), "full": true}, true},
+		{"plain-looking ciphertext", "secret", map[string]any{"type": "SCENE_INIT"}, nil, false},
+	}
+	for _, tt := range tests {
+		if got := isFullScene(tt.roomID, tt.payload, tt.metadata); got != tt.want {
+			t.Errorf("%s: isFullScene() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKeepSceneInit(t *testing.T) {
+	defer clearSceneInit("room")
+
+	full := map[string]any{"type": "SCENE_INIT", "payload": map[string]any{"elements": []any{}}}
+	keepSceneInit("room", full, nil, nil)
+	keepSceneInit("room", map[string]any{"type": "SCENE_UPDATE"}, nil, nil)
+
+	sceneInitsMutex.RLock()
+	scene, exists := sceneInits["room"]
+	sceneInitsMutex.RUnlock()
+	if !exists || scene.payload.(map[string]any)["type"] != "SCENE_INIT" {
+		t.Fatalf("kept scene = %#v, %v, want the SCENE_INIT", scene, exists)
+	}
+
+	clearSceneInit("room")
+	sceneInitsMutex.RLock()
+	_, exists = sceneInits["room"]
+	sceneInitsMutex.RUnlock()
+	if exists {
+		t.Error("clearSceneInit() kept the scene")
+	}
+}
+
+func TestSeedRoom(t *testing.T) {
+	defer clearSceneInit("seeded")
+	elements := []any{map[string]any{"id": "a", "type": "rectangle", "version": float64(1)}}
+
+	if err := SeedRoom(context.Background(), "seeded", elements); err != nil {
+		t.Fatalf("SeedRoom() failed: %v", err)
+	}
+	sceneInitsMutex.RLock()
+	scene, exists := sceneInits["seeded"]
+	sceneInitsMutex.RUnlock()
+	if !exists || !scene.seeded || !isFullScene("seeded", scene.payload, scene.metadata) {
+		t.Fatalf("seeded scene = %#v, %v, want a SCENE_INIT", scene, exists)
+	}
+	if err := SeedRoom(context.Background(), "seeded", elements); !errors.Is(err, ErrRoomInUse) {
+		t.Errorf("second SeedRoom() error = %v, want ErrRoomInUse", err)
+	}
+
+	roomsMutex.Lock()
+	activeRooms["busy"] = 1
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(activeRooms, "busy")
+		roomsMutex.Unlock()
+	}()
+	if err := SeedRoom(context.Background(), "busy", elements); !errors.Is(err, ErrRoomInUse) {
+		t.Errorf("SeedRoom() of a room with members error = %v, want ErrRoomInUse", err)
+	}
+}
@@ -0,0 +1,378 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/chatfilter"
+	"excalidraw-server/core"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmihailenco/msgpack/v5"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const (
+	// maxReactionLength bounds one reaction, in bytes: enough for any emoji
+	// sequence or a short shortcode
+	maxReactionLength = 64
+	// maxReactionsPerMessage bounds the distinct reactions on one message
+	maxReactionsPerMessage = 50
+)
+
+var errUnknownChatMessage = apierror.New(apierror.MessageNotFound, "unknown chat message")
+
+// filteredError is a chat message the chat filter rejected. Its error acks
+// carry the code filtered and the filter's reason.
+type filteredError struct {
+	reason string
+}
+
+func (e *filteredError) Error() string {
+	return "message rejected by content filter"
+}
+
+var (
+	chatFilter      chatfilter.Filter
+	chatFilterMutex sync.RWMutex
+)
+
+// SetChatFilter makes chat messages pass filter before they reach a room, or
+// turns filtering off with nil.
+func SetChatFilter(filter chatfilter.Filter) {
+	chatFilterMutex.Lock()
+	defer chatFilterMutex.Unlock()
+	chatFilter = filter
+}
+
+func getChatFilter() chatfilter.Filter {
+	chatFilterMutex.RLock()
+	defer chatFilterMutex.RUnlock()
+	return chatFilter
+}
+
+// filterChatMessage runs a message through the chat filter and returns the
+// content to send, or a *filteredError when it rejected the message. The sender is told about
+// redactions and rejections with moderation-filtered { roomId, messageId,
+// action, reason }, and the room's owner with moderation-flagged, which also
+// carries the sender and the original content.
+func filterChatMessage(socket *socketio.Socket, srv *socketio.Server, roomID, messageID, content string) (string, error) {
+	filter := getChatFilter()
+	if filter == nil {
+		return content, nil
+	}
+
+	message := chatfilter.Message{RoomID: roomID, SocketID: string(socket.Id()), Content: content}
+	user := socketUser(socket)
+	if user != nil {
+		message.UserID = user.ID
+	}
+	verdict, err := filter.Check(context.Background(), message)
+	if err != nil {
+		logrus.WithField("room_id", roomID).WithError(err).Error("Failed to filter chat message")
+		return "", fmt.Errorf("failed to filter message")
+	}
+	if verdict.Action == chatfilter.Allow {
+		return content, nil
+	}
+
+	notice := map[string]any{
+		"roomId":    roomID,
+		"messageId": messageID,
+		"action":    verdict.Action,
+		"reason":    verdict.Reason,
+	}
+	if !deliverReliably(socket.Id(), "moderation-filtered", notice) {
+		_ = socket.Emit("moderation-filtered", notice)
+	}
+	if owner := roomOwnerSocket(roomID); owner != "" && owner != socket.Id() {
+		flag := map[string]any{
+			"roomId":    roomID,
+			"messageId": messageID,
+			"socketId":  string(socket.Id()),
+			"user":      user,
+			"action":    verdict.Action,
+			"reason":    verdict.Reason,
+			"content":   content,
+		}
+		if !deliverReliably(owner, "moderation-flagged", flag) {
+			_ = srv.To(socketio.Room(owner)).Emit("moderation-flagged", flag)
+		}
+	}
+
+	if verdict.Action == chatfilter.Reject {
+		return "", &filteredError{reason: verdict.Reason}
+	}
+	return verdict.Content, nil
+}
+
+// handleChatReaction adds a reaction to a message in the room's chat history,
+// or with remove takes it back, and tells the room with
+// client-chat-reaction { roomId, messageId, reactions }. Reacting twice with
+// the same emoji counts once.
+func handleChatReaction(socket *socketio.Socket, srv *socketio.Server, datas []any) {
+	ack, args := extractAck(datas)
+	request, err := decodeChatReaction(args)
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	roomID := request.roomID
+	wakeRoom(roomID, false)
+
+	if !socket.Rooms().Has(socketio.Room(roomID)) {
+		err := apierror.Errorf(apierror.NotInRoom, "not in room %s", roomID)
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	if isMuted(roomID, socket.Id()) {
+		err := apierror.New(apierror.Muted, "muted in room")
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+
+	reactor := string(socket.Id())
+	if user := socketUser(socket); user != nil {
+		reactor = user.ID
+	}
+	reactions, err := reactToChatMessage(roomID, request.messageID, request.emoji, reactor, request.remove)
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+
+	if err := emitChatEvent(srv, roomID, "client-chat-reaction", map[string]any{
+		"roomId":    roomID,
+		"messageId": request.messageID,
+		"reactions": reactions,
+	}); err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	respondWithAck(socket, ack, "", map[string]any{
+		"status":    "ok",
+		"messageId": request.messageID,
+		"reactions": reactions,
+	}, nil)
+}
+
+// reactToChatMessage adds reactor to, or removes it from, those who reacted
+// to a message with emoji and returns the message's reactions. The map is
+// replaced rather than changed, as copies of the history share it. Reactions
+// count against the chat budgets like the rest of the message.
+func reactToChatMessage(roomID, messageID, emoji, reactor string, remove bool) (map[string][]string, error) {
+	chatHistoryMutex.Lock()
+	defer chatHistoryMutex.Unlock()
+
+	messages := chatHistory[roomID]
+	for i := range messages {
+		if messages[i].ID != messageID {
+			continue
+		}
+		current := messages[i].Reactions
+		if _, exists := current[emoji]; !exists && !remove && len(current) >= maxReactionsPerMessage {
+			return nil, apierror.New(apierror.TooManyReactions, "message has too many reactions")
+		}
+
+		reactions := make(map[string][]string, len(current)+1)
+		for key, reactors := range current {
+			reactions[key] = reactors
+		}
+		reactors := make([]string, 0, len(current[emoji])+1)
+		for _, existing := range current[emoji] {
+			if existing != reactor {
+				reactors = append(reactors, existing)
+			}
+		}
+		if !remove {
+			reactors = append(reactors, reactor)
+		}
+		if len(reactors) == 0 {
+			delete(reactions, emoji)
+		} else {
+			reactions[emoji] = reactors
+		}
+
+		size := chatMessageSize(messages[i])
+		if len(reactions) == 0 {
+			messages[i].Reactions = nil
+			reactions = map[string][]string{}
+		} else {
+			messages[i].Reactions = reactions
+		}
+		size = chatMessageSize(messages[i]) - size
+		chatRoomBytes[roomID] += size
+		chatBytes += size
+		evictChat(roomID)
+		return reactions, nil
+	}
+	return nil, errUnknownChatMessage
+}
+
+// hasChatMessage reports whether a message is in the room's chat history.
+func hasChatMessage(roomID, messageID string) bool {
+	chatHistoryMutex.RLock()
+	defer chatHistoryMutex.RUnlock()
+	for _, message := range chatHistory[roomID] {
+		if message.ID == messageID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRoomChat returns a room's chat history, oldest first: from memory while
+// the room has members, otherwise from the state it hibernated with.
+func GetRoomChat(ctx context.Context, roomID string) ([]ChatMessage, error) {
+	if isLiveRoom(roomID) {
+		wakeRoom(roomID, false)
+		return getChatHistory(roomID), nil
+	}
+
+	state, err := loadRoomState(ctx, roomID)
+	if err != nil || state == nil {
+		return []ChatMessage{}, err
+	}
+	return state.Chat, nil
+}
+
+// DeleteRoomChat deletes a message from a room's chat history, or the whole
+// history when messageID is empty, and returns how many messages it deleted.
+// Members are told with chat-deleted { roomId, ids }, or chat-cleared
+// { roomId } for the whole history.
+func DeleteRoomChat(ctx context.Context, srv *socketio.Server, roomID, messageID string) (int, error) {
+	if isLiveRoom(roomID) {
+		wakeRoom(roomID, false)
+		chatHistoryMutex.Lock()
+		kept, ids := withoutChatMessages(chatHistory[roomID], messageID)
+		setChatHistory(roomID, kept)
+		chatHistoryMutex.Unlock()
+
+		room := socketio.Room(roomID)
+		switch {
+		case messageID == "":
+			payload := map[string]any{"roomId": roomID}
+			_ = srv.To(room).Emit("chat-cleared", payload)
+			publishRoomEvent(roomID, "chat-cleared", payload)
+		case len(ids) > 0:
+			payload := map[string]any{"roomId": roomID, "ids": ids}
+			_ = srv.To(room).Emit("chat-deleted", payload)
+			publishRoomEvent(roomID, "chat-deleted", payload)
+		}
+		return len(ids), nil
+	}
+
+	state, err := loadRoomState(ctx, roomID)
+	if err != nil || state == nil {
+		return 0, err
+	}
+	var ids []string
+	if state.Chat, ids = withoutChatMessages(state.Chat, messageID); len(ids) == 0 {
+		return 0, nil
+	}
+	data, err := msgpack.Marshal(state)
+	if err != nil {
+		return 0, err
+	}
+	if err := getRoomStateStore().PutRoomState(ctx, roomID, data); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// PurgeRoomChat deletes the chat history a hibernated room saved and returns
+// how many messages it held. The state is saved back with replace, so
+// stores can keep it from counting as activity. Live rooms are left alone.
+func PurgeRoomChat(ctx context.Context, roomID string, replace func(ctx context.Context, roomID string, state []byte) error) (int, error) {
+	if isLiveRoom(roomID) {
+		return 0, nil
+	}
+	state, err := loadRoomState(ctx, roomID)
+	if err != nil || state == nil || len(state.Chat) == 0 {
+		return 0, err
+	}
+	purged := len(state.Chat)
+	state.Chat = nil
+	data, err := msgpack.Marshal(state)
+	if err != nil {
+		return 0, err
+	}
+	if err := replace(ctx, roomID, data); err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// CanReadRoomChat reports whether userID, empty for anonymous users, may read
+// a room's chat: anyone who may join the room.
+func CanReadRoomChat(ctx context.Context, roomID, userID string) (bool, error) {
+	return CanJoinRoom(ctx, roomID, userID)
+}
+
+// IsRoomModerator reports whether userID moderates a room: its owner in the
+// stored permissions or, for rooms without one, the authenticated user who
+// owns the live room.
+func IsRoomModerator(ctx context.Context, roomID, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+	if store := getPermissionStore(); store != nil {
+		permissions, err := store.GetRoomPermissions(ctx, roomID)
+		if err != nil {
+			return false, err
+		}
+		if permissions.Owner != "" {
+			return permissions.Owner == userID, nil
+		}
+	}
+
+	moderationMutex.Lock()
+	defer moderationMutex.Unlock()
+	state, exists := moderation[roomID]
+	return exists && state.ownerUser == userID, nil
+}
+
+func isLiveRoom(roomID string) bool {
+	roomsMutex.RLock()
+	defer roomsMutex.RUnlock()
+	_, live := activeRooms[roomID]
+	return live
+}
+
+// loadRoomState reads the state a room hibernated with without waking it,
+// or nil when there is none.
+func loadRoomState(ctx context.Context, roomID string) (*roomState, error) {
+	store := getRoomStateStore()
+	if store == nil {
+		return nil, nil
+	}
+	data, err := store.GetRoomState(ctx, roomID)
+	if errors.Is(err, core.ErrRoomStateNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state roomState
+	if err := msgpack.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// withoutChatMessages returns messages without the one with messageID, or
+// without any when it is empty, and the ids it left out.
+func withoutChatMessages(messages []ChatMessage, messageID string) ([]ChatMessage, []string) {
+	kept := make([]ChatMessage, 0, len(messages))
+	ids := []string{}
+	for _, message := range messages {
+		if messageID == "" || message.ID == messageID {
+			ids = append(ids, message.ID)
+			continue
+		}
+		kept = append(kept, message)
+	}
+	return kept, ids
+}
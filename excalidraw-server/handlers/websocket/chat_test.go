@@ -0,0 +1,155 @@
+package websocket
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"testing"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func TestRoomChatOfLiveRoom(t *testing.T) {
+	ctx := context.Background()
+	defer clearChatHistory("chat-room")
+	addChatMessage("chat-room", ChatMessage{ID: "m1", Content: "one"})
+	addChatMessage("chat-room", ChatMessage{ID: "m2", Content: "two"})
+	roomsMutex.Lock()
+	activeRooms["chat-room"] = 1
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(activeRooms, "chat-room")
+		roomsMutex.Unlock()
+	}()
+
+	messages, err := GetRoomChat(ctx, "chat-room")
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("GetRoomChat() = %+v, %v", messages, err)
+	}
+
+	srv := socketio.NewServer(nil, nil)
+	if deleted, err := DeleteRoomChat(ctx, srv, "chat-room", "m1"); err != nil || deleted != 1 {
+		t.Errorf("DeleteRoomChat() of a message = %d, %v, want 1", deleted, err)
+	}
+	if deleted, _ := DeleteRoomChat(ctx, srv, "chat-room", "missing"); deleted != 0 {
+		t.Errorf("DeleteRoomChat() of a missing message = %d, want 0", deleted)
+	}
+	if messages := getChatHistory("chat-room"); len(messages) != 1 || messages[0].ID != "m2" {
+		t.Errorf("history after deleting = %+v", messages)
+	}
+	if deleted, _ := DeleteRoomChat(ctx, srv, "chat-room", ""); deleted != 1 {
+		t.Errorf("DeleteRoomChat() of everything = %d, want 1", deleted)
+	}
+}
+
+func TestRoomChatOfHibernatedRoom(t *testing.T) {
+	ctx := context.Background()
+	store := useRoomStateStore(t)
+
+	if messages, err := GetRoomChat(ctx, "saved-room"); err != nil || len(messages) != 0 {
+		t.Errorf("GetRoomChat() of an unknown room = %+v, %v", messages, err)
+	}
+
+	seedRoom("saved-room")
+	addChatMessage("saved-room", ChatMessage{ID: "m2", Content: "bye", Timestamp: 2})
+	hibernateEmptyRoom("saved-room")()
+	freeRoomState("saved-room")
+
+	messages, err := GetRoomChat(ctx, "saved-room")
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("GetRoomChat() of a hibernated room = %+v, %v", messages, err)
+	}
+	// Reading doesn't wake the room
+	if history := getChatHistory("saved-room"); len(history) != 0 {
+		t.Errorf("GetRoomChat() loaded the room: %+v", history)
+	}
+
+	if deleted, err := DeleteRoomChat(ctx, nil, "saved-room", "m1"); err != nil || deleted != 1 {
+		t.Fatalf("DeleteRoomChat() of a hibernated room = %d, %v", deleted, err)
+	}
+	if _, err := store.GetRoomState(ctx, "saved-room"); err != nil {
+		t.Fatalf("room state is gone: %v", err)
+	}
+	messages, _ = GetRoomChat(ctx, "saved-room")
+	if len(messages) != 1 || messages[0].ID != "m2" {
+		t.Errorf("hibernated history after deleting = %+v", messages)
+	}
+}
+
+func TestIsRoomModerator(t *testing.T) {
+	ctx := context.Background()
+	defer func() {
+		moderationMutex.Lock()
+		delete(moderation, "moderated-room")
+		moderationMutex.Unlock()
+	}()
+	claimOwnership("moderated-room", "socket-a", &UserInfo{ID: "alice"}, true)
+
+	if ok, _ := IsRoomModerator(ctx, "moderated-room", "alice"); !ok {
+		t.Error("owner of the live room is not a moderator")
+	}
+	for _, user := range []string{"bob", ""} {
+		if ok, _ := IsRoomModerator(ctx, "moderated-room", user); ok {
+			t.Errorf("%q is a moderator", user)
+		}
+	}
+
+	// Stored permissions win over the live room
+	SetRoomPermissionStore(staticPermissions{Owner: "bob"})
+	defer SetRoomPermissionStore(nil)
+	if ok, _ := IsRoomModerator(ctx, "moderated-room", "bob"); !ok {
+		t.Error("owner in the stored permissions is not a moderator")
+	}
+	if ok, _ := IsRoomModerator(ctx, "moderated-room", "alice"); ok {
+		t.Error("owner of the live room moderates a room owned by someone else")
+	}
+}
+
+// staticPermissions is a RoomPermissionStore giving every room the same
+// permissions.
+type staticPermissions core.RoomPermissions
+
+func (p staticPermissions) GetRoomPermissions(_ context.Context, roomID string) (*core.RoomPermissions, error) {
+	permissions := core.RoomPermissions(p)
+	permissions.RoomID = roomID
+	return &permissions, nil
+}
+
+func (p staticPermissions) PutRoomPermissions(context.Context, *core.RoomPermissions) error {
+	return nil
+}
+
+func TestReactToChatMessage(t *testing.T) {
+	defer clearChatHistory("reaction-room")
+	addChatMessage("reaction-room", ChatMessage{ID: "m1", Content: "one"})
+	before := getChatHistory("reaction-room")
+
+	reactions, err := reactToChatMessage("reaction-room", "m1", "👍", "alice", false)
+	if err != nil || len(reactions["👍"]) != 1 {
+		t.Fatalf("reactToChatMessage() = %v, %v", reactions, err)
+	}
+	_, _ = reactToChatMessage("reaction-room", "m1", "👍", "alice", false)
+	reactions, _ = reactToChatMessage("reaction-room", "m1", "👍", "bob", false)
+	if got := reactions["👍"]; len(got) != 2 || got[0] != "alice" || got[1] != "bob" {
+		t.Errorf("reactions after reacting twice = %v, want [alice bob]", got)
+	}
+	if before[0].Reactions != nil {
+		t.Errorf("earlier copy of the history changed: %v", before[0].Reactions)
+	}
+	if history := getChatHistory("reaction-room"); len(history[0].Reactions["👍"]) != 2 {
+		t.Errorf("history reactions = %v", history[0].Reactions)
+	}
+
+	_, _ = reactToChatMessage("reaction-room", "m1", "👍", "alice", true)
+	reactions, _ = reactToChatMessage("reaction-room", "m1", "👍", "bob", true)
+	if len(reactions) != 0 || getChatHistory("reaction-room")[0].Reactions != nil {
+		t.Errorf("reactions after removing all = %v", reactions)
+	}
+
+	if _, err := reactToChatMessage("reaction-room", "missing", "👍", "alice", false); err != errUnknownChatMessage {
+		t.Errorf("reactToChatMessage() of a missing message = %v, want errUnknownChatMessage", err)
+	}
+	if !hasChatMessage("reaction-room", "m1") || hasChatMessage("reaction-room", "missing") {
+		t.Error("hasChatMessage() didn't find exactly the stored message")
+	}
+}
@@ -0,0 +1,190 @@
+package websocket
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+const (
+	defaultChatRoomBudget  = 1 << 20
+	defaultChatTotalBudget = 64 << 20
+	// chatMessageOverhead approximates what a kept message takes besides
+	// its strings.
+	chatMessageOverhead = 128
+)
+
+// ChatStats tells how much memory chat history takes and what was evicted
+// to keep it within its budgets. Sizes are estimates from the messages'
+// text.
+type ChatStats struct {
+	Bytes int64 `json:"bytes"`
+	Rooms int   `json:"rooms"`
+	// RoomBudget and TotalBudget are the limits for one room and for all of
+	// them; zero means none.
+	RoomBudget  int64 `json:"room_budget"`
+	TotalBudget int64 `json:"total_budget"`
+	// EvictedMessages and EvictedBytes count messages dropped from history,
+	// oldest first, since startup.
+	EvictedMessages int64 `json:"evicted_messages"`
+	EvictedBytes    int64 `json:"evicted_bytes"`
+}
+
+var (
+	chatRoomBudget  atomic.Int64
+	chatTotalBudget atomic.Int64
+
+	// chatRoomBytes is the size of each room's chat history and chatBytes
+	// their sum. Both are guarded by chatHistoryMutex.
+	chatRoomBytes = make(map[string]int64)
+	chatBytes     int64
+
+	evictedChatMessages atomic.Int64
+	evictedChatBytes    atomic.Int64
+)
+
+func init() {
+	chatRoomBudget.Store(defaultChatRoomBudget)
+	chatTotalBudget.Store(defaultChatTotalBudget)
+}
+
+// ChatBudgetsFromEnv reads CHAT_ROOM_MAX_BYTES and CHAT_MAX_BYTES, the chat
+// history budgets of one room and of all rooms. They default to 1 MiB and
+// 64 MiB; zero removes a limit.
+func ChatBudgetsFromEnv() (room, total int64, err error) {
+	room, total = defaultChatRoomBudget, defaultChatTotalBudget
+	for _, setting := range []struct {
+		name  string
+		value *int64
+	}{{"CHAT_ROOM_MAX_BYTES", &room}, {"CHAT_MAX_BYTES", &total}} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		budget, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || budget < 0 {
+			return 0, 0, fmt.Errorf("invalid %s %q: must be a non-negative number of bytes", setting.name, value)
+		}
+		*setting.value = budget
+	}
+	return room, total, nil
+}
+
+// SetChatBudgets sets how many bytes of chat history one room and all rooms
+// together may keep before the oldest messages are evicted. Zero removes a
+// limit. History already over a new budget shrinks with its room's next
+// message.
+func SetChatBudgets(room, total int64) {
+	chatRoomBudget.Store(max(room, 0))
+	chatTotalBudget.Store(max(total, 0))
+}
+
+// GetChatStats reports the size of chat history and evictions since
+// startup.
+func GetChatStats() ChatStats {
+	chatHistoryMutex.RLock()
+	stats := ChatStats{Bytes: chatBytes, Rooms: len(chatHistory)}
+	chatHistoryMutex.RUnlock()
+
+	stats.RoomBudget = chatRoomBudget.Load()
+	stats.TotalBudget = chatTotalBudget.Load()
+	stats.EvictedMessages = evictedChatMessages.Load()
+	stats.EvictedBytes = evictedChatBytes.Load()
+	return stats
+}
+
+func chatMessageSize(message ChatMessage) int64 {
+	size := chatMessageOverhead + len(message.ID) + len(message.RoomID) + len(message.Sender) + len(message.Content) + len(message.ReplyTo)
+	if message.User != nil {
+		size += len(message.User.ID) + len(message.User.Name) + len(message.User.AvatarURL)
+	}
+	for emoji, reactors := range message.Reactions {
+		size += len(emoji)
+		for _, reactor := range reactors {
+			size += len(reactor)
+		}
+	}
+	return int64(size)
+}
+
+// setChatHistory replaces a room's chat history and recounts its size.
+// Callers hold chatHistoryMutex.
+func setChatHistory(roomID string, messages []ChatMessage) {
+	var size int64
+	for _, message := range messages {
+		size += chatMessageSize(message)
+	}
+	chatBytes += size - chatRoomBytes[roomID]
+	if len(messages) == 0 {
+		delete(chatHistory, roomID)
+		delete(chatRoomBytes, roomID)
+		return
+	}
+	chatHistory[roomID] = messages
+	chatRoomBytes[roomID] = size
+}
+
+// appendChatMessage adds a message to a room's chat history. Callers hold
+// chatHistoryMutex.
+func appendChatMessage(roomID string, message ChatMessage) {
+	size := chatMessageSize(message)
+	chatHistory[roomID] = append(chatHistory[roomID], message)
+	chatRoomBytes[roomID] += size
+	chatBytes += size
+}
+
+// evictChat drops the oldest messages of a room while its history is over
+// the room budget, then the oldest messages of any room while all history
+// is over the total budget. Callers hold chatHistoryMutex.
+func evictChat(roomID string) {
+	if budget := chatRoomBudget.Load(); budget > 0 {
+		messages, size := chatHistory[roomID], chatRoomBytes[roomID]
+		count := 0
+		for count < len(messages) && size > budget {
+			size -= chatMessageSize(messages[count])
+			count++
+		}
+		dropChatMessages(roomID, count)
+	}
+
+	budget := chatTotalBudget.Load()
+	for budget > 0 && chatBytes > budget {
+		oldest, oldestAt := "", int64(0)
+		for id, messages := range chatHistory {
+			if len(messages) > 0 && (oldest == "" || messages[0].Timestamp < oldestAt) {
+				oldest, oldestAt = id, messages[0].Timestamp
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		dropChatMessages(oldest, 1)
+	}
+}
+
+// dropChatMessages evicts the first count messages of a room's history.
+// Callers hold chatHistoryMutex.
+func dropChatMessages(roomID string, count int) {
+	if count <= 0 {
+		return
+	}
+	messages := chatHistory[roomID]
+	var size int64
+	for _, message := range messages[:count] {
+		size += chatMessageSize(message)
+	}
+	// Let go of the evicted messages, which the slice's array still holds
+	clear(messages[:count])
+	evictedChatMessages.Add(int64(count))
+	evictedChatBytes.Add(size)
+
+	chatBytes -= size
+	if count == len(messages) {
+		delete(chatHistory, roomID)
+		delete(chatRoomBytes, roomID)
+		return
+	}
+	chatHistory[roomID] = messages[count:]
+	chatRoomBytes[roomID] -= size
+}
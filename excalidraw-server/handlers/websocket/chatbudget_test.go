@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func budgetMessage(roomID string, timestamp int64, size int) ChatMessage {
+	return ChatMessage{
+		ID:        fmt.Sprintf("%s-%d", roomID, timestamp),
+		RoomID:    roomID,
+		Content:   strings.Repeat("x", size),
+		Timestamp: timestamp,
+	}
+}
+
+func TestChatBudgets(t *testing.T) {
+	for _, roomID := range []string{"budget-a", "budget-b"} {
+		clearChatHistory(roomID)
+		defer clearChatHistory(roomID)
+	}
+	before := GetChatStats()
+
+	size := chatMessageSize(budgetMessage("budget-a", 1, 1000))
+	SetChatBudgets(3*size, before.Bytes+5*size)
+	defer SetChatBudgets(defaultChatRoomBudget, defaultChatTotalBudget)
+
+	// the room budget keeps a room's three newest messages
+	for i := int64(1); i <= 4; i++ {
+		addChatMessage("budget-a", budgetMessage("budget-a", i, 1000))
+	}
+	history := getChatHistory("budget-a")
+	if len(history) != 3 || history[0].Timestamp != 2 {
+		t.Fatalf("kept %d messages from %v", len(history), history)
+	}
+
+	// the total budget evicts the oldest message of any room
+	for i := int64(5); i <= 7; i++ {
+		addChatMessage("budget-b", budgetMessage("budget-b", i, 1000))
+	}
+	if history := getChatHistory("budget-a"); len(history) != 2 || history[0].Timestamp != 3 {
+		t.Errorf("budget-a kept %v", history)
+	}
+	if history := getChatHistory("budget-b"); len(history) != 3 {
+		t.Errorf("budget-b kept %d messages", len(history))
+	}
+
+	stats := GetChatStats()
+	if stats.Bytes-before.Bytes != 5*size {
+		t.Errorf("counted %d bytes, want %d", stats.Bytes-before.Bytes, 5*size)
+	}
+	if stats.EvictedMessages-before.EvictedMessages != 2 || stats.EvictedBytes-before.EvictedBytes != 2*size {
+		t.Errorf("counted %d evictions of %d bytes", stats.EvictedMessages-before.EvictedMessages, stats.EvictedBytes-before.EvictedBytes)
+	}
+
+	clearChatHistory("budget-a")
+	clearChatHistory("budget-b")
+	if bytes := GetChatStats().Bytes; bytes != before.Bytes {
+		t.Errorf("cleared history still counts %d bytes", bytes-before.Bytes)
+	}
+}
+
+func TestChatBudgetsCountReactions(t *testing.T) {
+	clearChatHistory("budget-reactions")
+	defer clearChatHistory("budget-reactions")
+	before := GetChatStats()
+
+	size := chatMessageSize(budgetMessage("budget-reactions", 1, 100))
+	SetChatBudgets(2*size+200, defaultChatTotalBudget)
+	defer SetChatBudgets(defaultChatRoomBudget, defaultChatTotalBudget)
+
+	addChatMessage("budget-reactions", budgetMessage("budget-reactions", 1, 100))
+	addChatMessage("budget-reactions", budgetMessage("budget-reactions", 2, 100))
+	reactor := strings.Repeat("r", 50)
+	if _, err := reactToChatMessage("budget-reactions", "budget-reactions-2", "👍", reactor, false); err != nil {
+		t.Fatal(err)
+	}
+	reacted := int64(len("👍") + len(reactor))
+	if bytes := GetChatStats().Bytes - before.Bytes; bytes != 2*size+reacted {
+		t.Errorf("counted %d bytes, want %d", bytes, 2*size+reacted)
+	}
+
+	// Reconnecting reactors can't grow history past the room budget
+	for i := 0; i < 5; i++ {
+		if _, err := reactToChatMessage("budget-reactions", "budget-reactions-2", "👍", fmt.Sprintf("%s-%d", reactor, i), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history := getChatHistory("budget-reactions")
+	if len(history) != 1 || history[0].Timestamp != 2 {
+		t.Fatalf("kept %v", history)
+	}
+	if bytes := GetChatStats().Bytes - before.Bytes; bytes != chatMessageSize(history[0]) {
+		t.Errorf("counted %d bytes, want %d", bytes, chatMessageSize(history[0]))
+	}
+
+	if _, err := reactToChatMessage("budget-reactions", "budget-reactions-2", "👍", reactor, true); err != nil {
+		t.Fatal(err)
+	}
+	if bytes := GetChatStats().Bytes - before.Bytes; bytes != chatMessageSize(getChatHistory("budget-reactions")[0]) {
+		t.Errorf("removing a reaction left %d bytes counted", bytes)
+	}
+}
+
+func TestChatBudgetsFromEnv(t *testing.T) {
+	t.Setenv("CHAT_ROOM_MAX_BYTES", "")
+	t.Setenv("CHAT_MAX_BYTES", "0")
+	room, total, err := ChatBudgetsFromEnv()
+	if err != nil || room != defaultChatRoomBudget || total != 0 {
+		t.Errorf("got %d, %d, %v", room, total, err)
+	}
+	t.Setenv("CHAT_ROOM_MAX_BYTES", "-1")
+	if _, _, err := ChatBudgetsFromEnv(); err == nil {
+		t.Error("a negative budget was accepted")
+	}
+}
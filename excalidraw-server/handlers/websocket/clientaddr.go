@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// trustProxy makes socket addresses come from the proxy headers of the
+// handshake, as middleware.RealIP does for HTTP requests.
+var trustProxy atomic.Bool
+
+// SetTrustProxy makes socket.io clients be told apart by the True-Client-IP,
+// X-Real-IP or X-Forwarded-For header of their handshake rather than the
+// address of the connection, which is the proxy's behind one. Only enable it
+// behind a proxy that sets these headers (TRUST_PROXY_HEADERS).
+func SetTrustProxy(trust bool) {
+	trustProxy.Store(trust)
+}
+
+// clientAddress returns the address the client of a socket's handshake is
+// banned and tracked under.
+func clientAddress(handshake *socketio.Handshake) string {
+	if trustProxy.Load() {
+		if ip := forwardedIP(http.Header(handshake.Headers)); ip != "" {
+			return ip
+		}
+	}
+	return handshake.Address
+}
+
+// forwardedIP reads the client address from proxy headers, in the order
+// middleware.RealIP does.
+func forwardedIP(header http.Header) string {
+	var ip string
+	if value := header.Get("True-Client-IP"); value != "" {
+		ip = value
+	} else if value := header.Get("X-Real-IP"); value != "" {
+		ip = value
+	} else if value := header.Get("X-Forwarded-For"); value != "" {
+		ip, _, _ = strings.Cut(value, ",")
+	}
+	ip = strings.TrimSpace(ip)
+	if net.ParseIP(ip) == nil {
+		return ""
+	}
+	return ip
+}
@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"errors"
+	"excalidraw-server/cluster"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/vmihailenco/msgpack/v5"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// Cluster event types.
+const (
+	clusterBroadcast = "broadcast"
+	clusterJoin      = "join"
+	clusterLeave     = "leave"
+)
+
+// clusterEvent is what instances publish about their rooms, MessagePack
+// encoded. Broadcasts go to {prefix}.broadcast and are relayed by the other
+// instances; joins and leaves go to {prefix}.presence for outside consumers.
+type clusterEvent struct {
+	Type     string `msgpack:"type"`
+	Instance string `msgpack:"instance"`
+	RoomID   string `msgpack:"room_id"`
+	SocketID string `msgpack:"socket_id"`
+	// User is the authenticated user of the socket, if any
+	User string `msgpack:"user,omitempty"`
+	// Payload and Metadata are those of a broadcast; Payload is ciphertext in
+	// encrypted rooms and the decoded scene otherwise
+	Payload  any  `msgpack:"payload,omitempty"`
+	Metadata any  `msgpack:"metadata,omitempty"`
+	Volatile bool `msgpack:"volatile,omitempty"`
+	// Members is how many sockets the room has on the instance after a join
+	// or leave
+	Members int   `msgpack:"members,omitempty"`
+	Time    int64 `msgpack:"time"`
+}
+
+var (
+	clusterTransport cluster.Transport
+	clusterPrefix    string
+	clusterInstance  string
+	clusterMutex     sync.RWMutex
+)
+
+// JoinCluster shares the broadcasts of srv's rooms with the other instances
+// on transport and relays theirs to local members. Presence is published for
+// outside consumers only; each instance keeps its own room state.
+func JoinCluster(srv *socketio.Server, transport cluster.Transport, prefix string) error {
+	clusterMutex.Lock()
+	clusterTransport = transport
+	clusterPrefix = prefix
+	clusterInstance = ulid.Make().String()
+	instance := clusterInstance
+	clusterMutex.Unlock()
+
+	return transport.Subscribe(prefix+"."+clusterBroadcast, func(data []byte) {
+		var event clusterEvent
+		if err := msgpack.Unmarshal(data, &event); err != nil {
+			logrus.WithError(err).Warn("Failed to decode cluster event")
+			return
+		}
+		if event.Instance != instance {
+			runInRoom(event.RoomID, func() {
+				relayClusterBroadcast(srv, event)
+			})
+		}
+	})
+}
+
+// publishClusterEvent sends an event about a room to the other instances, if
+// the server is in a cluster.
+func publishClusterEvent(event clusterEvent) {
+	clusterMutex.RLock()
+	transport, prefix, instance := clusterTransport, clusterPrefix, clusterInstance
+	clusterMutex.RUnlock()
+	if transport == nil {
+		return
+	}
+
+	subject := prefix + ".presence"
+	if event.Type == clusterBroadcast {
+		subject = prefix + "." + clusterBroadcast
+	}
+	event.Instance = instance
+	event.Time = time.Now().UnixMilli()
+
+	log := logrus.WithFields(logrus.Fields{"room_id": event.RoomID, "subject": subject})
+	data, err := msgpack.Marshal(&event)
+	if err != nil {
+		log.WithError(err).Error("Failed to encode cluster event")
+		return
+	}
+	if err := transport.Publish(subject, data); err != nil {
+		if errors.Is(err, cluster.ErrNotConnected) {
+			log.Debug("Dropped cluster event while disconnected")
+			return
+		}
+		log.WithError(err).Warn("Failed to publish cluster event")
+	}
+}
+
+// publishPresence tells outside consumers a socket joined or left a room.
+func publishPresence(eventType, roomID string, socketID socketio.SocketId, user *UserInfo, members int) {
+	event := clusterEvent{Type: eventType, RoomID: roomID, SocketID: string(socketID), Members: members}
+	if user != nil {
+		event.User = user.ID
+	}
+	publishClusterEvent(event)
+}
+
+// relayClusterBroadcast relays another instance's broadcast to the room's
+// members here, numbered in this instance's sequence.
+func relayClusterBroadcast(srv *socketio.Server, event clusterEvent) {
+	roomID := event.RoomID
+	roomsMutex.RLock()
+	_, local := activeRooms[roomID]
+	roomsMutex.RUnlock()
+	if !local {
+		return
+	}
+	wakeRoom(roomID, false)
+
+	_, err := sequenceBroadcast(roomID, func(seq int64) error {
+		sendRawBroadcast(roomID, socketio.SocketId(event.SocketID), event.Payload, event.Metadata, seq, event.Volatile)
+		var lagging []socketio.Room
+		if event.Volatile {
+			lagging = holdVolatile(roomID, socketio.SocketId(event.SocketID), heldBroadcast{scene: event.Payload, metadata: event.Metadata, seq: seq})
+		}
+		return relayBroadcast(func() *socketio.BroadcastOperator {
+			if event.Volatile {
+				return srv.Local().Volatile().Except(lagging...)
+			}
+			return srv.Local()
+		}, roomID, event.Payload, nil, event.Metadata, seq)
+	})
+	if err != nil {
+		logrus.WithField("room_id", roomID).WithError(err).Warn("Failed to relay cluster broadcast")
+		return
+	}
+
+	trackBroadcast(roomID, payloadSize(event.Payload), time.Now())
+	if !event.Volatile {
+		recordBroadcast(roomID, event.SocketID, event.Payload)
+		keepSceneInit(roomID, event.Payload, nil, event.Metadata)
+	}
+}
@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+type busMessage struct {
+	subject string
+	data    []byte
+}
+
+// loopbackBus delivers messages to its own subscribers, as a bus shared by
+// every instance would.
+type loopbackBus struct {
+	mu        sync.Mutex
+	published []busMessage
+	handlers  map[string][]func(data []byte)
+}
+
+func (b *loopbackBus) Publish(subject string, data []byte) error {
+	b.mu.Lock()
+	b.published = append(b.published, busMessage{subject, data})
+	handlers := b.handlers[subject]
+	b.mu.Unlock()
+	for _, handler := range handlers {
+		handler(data)
+	}
+	return nil
+}
+
+func (b *loopbackBus) Subscribe(subject string, handler func(data []byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlers == nil {
+		b.handlers = make(map[string][]func(data []byte))
+	}
+	b.handlers[subject] = append(b.handlers[subject], handler)
+	return nil
+}
+
+func (b *loopbackBus) Close() error { return nil }
+
+func joinTestCluster(t *testing.T) *loopbackBus {
+	t.Helper()
+	bus := &loopbackBus{}
+	if err := JoinCluster(socketio.NewServer(nil, nil), bus, "test"); err != nil {
+		t.Fatalf("JoinCluster() failed: %v", err)
+	}
+	t.Cleanup(func() {
+		clusterMutex.Lock()
+		clusterTransport = nil
+		clusterMutex.Unlock()
+	})
+	return bus
+}
+
+func TestPublishClusterEvent(t *testing.T) {
+	bus := joinTestCluster(t)
+
+	publishPresence(clusterJoin, "room", "socket-1", &UserInfo{ID: "alice"}, 2)
+	publishClusterEvent(clusterEvent{
+		Type:     clusterBroadcast,
+		RoomID:   "room",
+		SocketID: "socket-1",
+		Payload:  []byte{1, 2, 3},
+		Metadata: map[string]any{"full": true},
+	})
+
+	if len(bus.published) != 2 {
+		t.Fatalf("published %d messages, want 2", len(bus.published))
+	}
+	if subject := bus.published[0].subject; subject != "test.presence" {
+		t.Errorf("presence subject = %q, want test.presence", subject)
+	}
+	var presence clusterEvent
+	if err := msgpack.Unmarshal(bus.published[0].data, &presence); err != nil {
+		t.Fatal(err)
+	}
+	if presence.Type != clusterJoin || presence.User != "alice" || presence.Members != 2 || presence.Instance == "" {
+		t.Errorf("presence event = %+v", presence)
+	}
+
+	if subject := bus.published[1].subject; subject != "test.broadcast" {
+		t.Errorf("broadcast subject = %q, want test.broadcast", subject)
+	}
+	var broadcast clusterEvent
+	if err := msgpack.Unmarshal(bus.published[1].data, &broadcast); err != nil {
+		t.Fatal(err)
+	}
+	// Ciphertext stays binary
+	if payload, ok := broadcast.Payload.([]byte); !ok || !bytes.Equal(payload, []byte{1, 2, 3}) {
+		t.Errorf("broadcast payload = %#v, want the ciphertext", broadcast.Payload)
+	}
+}
+
+func TestRelayClusterBroadcast(t *testing.T) {
+	bus := joinTestCluster(t)
+	defer clearBroadcastSeq("cluster-room")
+	defer clearSceneInit("cluster-room")
+
+	scene := map[string]any{"type": "SCENE_INIT", "payload": map[string]any{"elements": []any{}}}
+	remote := func(roomID string) []byte {
+		data, err := msgpack.Marshal(&clusterEvent{
+			Type:     clusterBroadcast,
+			Instance: "other-instance",
+			RoomID:   roomID,
+			SocketID: "remote-socket",
+			Payload:  scene,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	// Rooms without members here are left alone
+	_ = bus.Publish("test.broadcast", remote("cluster-room"))
+	if seq := currentBroadcastSeq("cluster-room"); seq != 0 {
+		t.Errorf("seq of a room without local members = %d, want 0", seq)
+	}
+
+	roomsMutex.Lock()
+	activeRooms["cluster-room"] = 1
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(activeRooms, "cluster-room")
+		roomsMutex.Unlock()
+	}()
+
+	_ = bus.Publish("test.broadcast", remote("cluster-room"))
+	if seq := currentBroadcastSeq("cluster-room"); seq != 1 {
+		t.Errorf("seq after a remote broadcast = %d, want 1", seq)
+	}
+	sceneInitsMutex.RLock()
+	_, kept := sceneInits["cluster-room"]
+	sceneInitsMutex.RUnlock()
+	if !kept {
+		t.Error("a remote SCENE_INIT was not kept for joiners")
+	}
+
+	// The instance's own broadcasts were already relayed locally
+	publishClusterEvent(clusterEvent{Type: clusterBroadcast, RoomID: "cluster-room", Payload: scene})
+	if seq := currentBroadcastSeq("cluster-room"); seq != 1 {
+		t.Errorf("seq after an own broadcast came back = %d, want 1", seq)
+	}
+}
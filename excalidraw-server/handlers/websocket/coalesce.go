@@ -0,0 +1,178 @@
+package websocket
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// MaxCoalesceWindow is the longest broadcast coalescing window, past which
+// strokes would visibly lag.
+const MaxCoalesceWindow = time.Second
+
+// coalesceWindow is how long a sender's scene updates are collected before
+// they go out as one broadcast; zero relays each one as it comes.
+var coalesceWindow atomic.Int64
+
+// SetCoalesceWindow sets how long each sender's SCENE_UPDATE broadcasts in
+// a plain room are merged before being relayed. Zero turns coalescing off.
+func SetCoalesceWindow(window time.Duration) {
+	coalesceWindow.Store(int64(min(max(window, 0), MaxCoalesceWindow)))
+}
+
+type coalesceKey struct {
+	roomID string
+	sender socketio.SocketId
+}
+
+// coalescedBroadcast is what a sender broadcast to a room during one window,
+// merged into a single scene update.
+type coalescedBroadcast struct {
+	// update is the sender's latest update; elements replace its own
+	update   map[string]any
+	elements []any
+	// index maps an element id to its position in elements
+	index map[string]int
+	// relay sends the merged update and returns its seq; acks answer each
+	// broadcast that went into it
+	relay func(update any) (int64, error)
+	acks  []func(seq int64, err error)
+
+	// closed broadcasts take no more updates; previous is one still being
+	// relayed when this one started, which goes out first
+	closed   bool
+	previous *coalescedBroadcast
+	once     sync.Once
+}
+
+var (
+	coalescedBroadcasts      = make(map[coalesceKey]*coalescedBroadcast)
+	coalescedBroadcastsMutex sync.Mutex
+)
+
+// sceneUpdateElements returns the elements of an Excalidraw SCENE_UPDATE
+// { type, payload: { elements } } without metadata in a plain room, the
+// only broadcasts that can be merged.
+func sceneUpdateElements(roomID string, payload, metadata any) (map[string]any, []any, bool) {
+	if metadata != nil || IsEncryptedRoom(roomID) {
+		return nil, nil, false
+	}
+	update, ok := payload.(map[string]any)
+	if !ok || update["type"] != "SCENE_UPDATE" {
+		return nil, nil, false
+	}
+	scene, ok := update["payload"].(map[string]any)
+	if !ok {
+		return nil, nil, false
+	}
+	elements, ok := scene["elements"].([]any)
+	if !ok {
+		return nil, nil, false
+	}
+	for _, element := range elements {
+		fields, ok := element.(map[string]any)
+		if !ok {
+			return nil, nil, false
+		}
+		if _, ok := fields["id"].(string); !ok {
+			return nil, nil, false
+		}
+	}
+	return update, elements, true
+}
+
+// coalesceBroadcast adds a broadcast to its sender's pending one when the
+// window is on and it is a scene update, and reports whether it did: relay
+// and ack then run when the window ends. Otherwise what the sender has
+// pending is relayed first, so the caller can relay this one after it.
+func coalesceBroadcast(roomID string, sender socketio.SocketId, payload, metadata any, relay func(update any) (int64, error), ack func(seq int64, err error)) bool {
+	key := coalesceKey{roomID, sender}
+	window := time.Duration(coalesceWindow.Load())
+	update, elements, ok := sceneUpdateElements(roomID, payload, metadata)
+
+	coalescedBroadcastsMutex.Lock()
+	pending := coalescedBroadcasts[key]
+	if window == 0 || !ok {
+		if pending != nil {
+			pending.closed = true
+		}
+		coalescedBroadcastsMutex.Unlock()
+		if pending != nil {
+			flushCoalesced(key, pending)
+		}
+		return false
+	}
+
+	if pending == nil || pending.closed {
+		pending = &coalescedBroadcast{index: make(map[string]int), previous: pending}
+		coalescedBroadcasts[key] = pending
+		next := pending
+		time.AfterFunc(window, func() {
+			// relayed on the room's worker like every other broadcast, so
+			// it is sequenced in order with joins, leaves and the rest
+			runInRoom(key.roomID, func() {
+				coalescedBroadcastsMutex.Lock()
+				next.closed = true
+				coalescedBroadcastsMutex.Unlock()
+				flushCoalesced(key, next)
+			})
+		})
+	}
+	pending.merge(update, elements)
+	pending.relay = relay
+	pending.acks = append(pending.acks, ack)
+	coalescedBroadcastsMutex.Unlock()
+	return true
+}
+
+// merge adds an update's elements, replacing those with the same id, and
+// makes it the update the merged elements go out in.
+func (b *coalescedBroadcast) merge(update map[string]any, elements []any) {
+	b.update = update
+	for _, element := range elements {
+		id := element.(map[string]any)["id"].(string)
+		if i, exists := b.index[id]; exists {
+			b.elements[i] = element
+			continue
+		}
+		b.index[id] = len(b.elements)
+		b.elements = append(b.elements, element)
+	}
+}
+
+// flushCoalesced relays a closed broadcast, once, after the one before it.
+func flushCoalesced(key coalesceKey, pending *coalescedBroadcast) {
+	pending.send()
+	coalescedBroadcastsMutex.Lock()
+	if coalescedBroadcasts[key] == pending {
+		delete(coalescedBroadcasts, key)
+	}
+	coalescedBroadcastsMutex.Unlock()
+}
+
+func (b *coalescedBroadcast) send() {
+	b.once.Do(func() {
+		if b.previous != nil {
+			b.previous.send()
+			b.previous = nil
+		}
+
+		update := make(map[string]any, len(b.update))
+		for field, value := range b.update {
+			update[field] = value
+		}
+		scene := make(map[string]any)
+		for field, value := range b.update["payload"].(map[string]any) {
+			scene[field] = value
+		}
+		scene["elements"] = b.elements
+		update["payload"] = scene
+
+		seq, err := b.relay(update)
+		for _, ack := range b.acks {
+			ack(seq, err)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package websocket
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sceneUpdate(elements ...map[string]any) map[string]any {
+	list := make([]any, len(elements))
+	for i, element := range elements {
+		list[i] = element
+	}
+	return map[string]any{"type": "SCENE_UPDATE", "payload": map[string]any{"elements": list}}
+}
+
+func TestCoalesceBroadcast(t *testing.T) {
+	SetCoalesceWindow(20 * time.Millisecond)
+	defer SetCoalesceWindow(0)
+
+	var mu sync.Mutex
+	var relayed []any
+	relay := func(update any) (int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		relayed = append(relayed, update)
+		return int64(len(relayed)), nil
+	}
+	acks := make(chan int64, 10)
+	ack := func(seq int64, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		acks <- seq
+	}
+
+	updates := []map[string]any{
+		sceneUpdate(map[string]any{"id": "a", "version": 1.0}),
+		sceneUpdate(map[string]any{"id": "b", "version": 1.0}, map[string]any{"id": "a", "version": 2.0}),
+		sceneUpdate(map[string]any{"id": "a", "version": 3.0}),
+	}
+	for _, update := range updates {
+		if !coalesceBroadcast("coalesce", "alice", update, nil, relay, ack) {
+			t.Fatal("a scene update wasn't coalesced")
+		}
+	}
+
+	for range updates {
+		select {
+		case seq := <-acks:
+			if seq != 1 {
+				t.Errorf("acknowledged with seq %d", seq)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the window didn't end")
+		}
+	}
+	want := sceneUpdate(map[string]any{"id": "a", "version": 3.0}, map[string]any{"id": "b", "version": 1.0})
+	if len(relayed) != 1 || !reflect.DeepEqual(relayed[0], want) {
+		t.Errorf("relayed %v, want %v", relayed, want)
+	}
+
+	// anything else relays what is pending first
+	if !coalesceBroadcast("coalesce", "alice", updates[0], nil, relay, ack) {
+		t.Fatal("a scene update wasn't coalesced")
+	}
+	if coalesceBroadcast("coalesce", "alice", updates[1], map[string]any{"full": true}, relay, ack) {
+		t.Error("a broadcast with metadata was coalesced")
+	}
+	if len(relayed) != 2 || <-acks != 2 {
+		t.Errorf("the pending update wasn't relayed first: %v", relayed)
+	}
+
+	SetCoalesceWindow(0)
+	if coalesceBroadcast("coalesce", "alice", updates[0], nil, relay, ack) {
+		t.Error("coalesced without a window")
+	}
+}
+
+func TestCoalesceBroadcastFlushesOnRoomWorker(t *testing.T) {
+	SetCoalesceWindow(5 * time.Millisecond)
+	defer SetCoalesceWindow(0)
+	StartRoomWorkers(1)
+	defer roomWorkers.Store(nil)
+
+	// a task holding the room's worker keeps the window's flush waiting
+	release := make(chan struct{})
+	runInRoom("coalesce-worker", func() { <-release })
+
+	var relayed sync.WaitGroup
+	relayed.Add(1)
+	relay := func(update any) (int64, error) {
+		relayed.Done()
+		return 1, nil
+	}
+	acked := make(chan struct{})
+	if !coalesceBroadcast("coalesce-worker", "alice", sceneUpdate(map[string]any{"id": "a"}), nil, relay, func(int64, error) { close(acked) }) {
+		t.Fatal("a scene update wasn't coalesced")
+	}
+
+	select {
+	case <-acked:
+		t.Fatal("the window was flushed outside the room's worker")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-acked:
+	case <-time.After(time.Second):
+		t.Fatal("the window wasn't flushed")
+	}
+	relayed.Wait()
+}
@@ -1,12 +1,15 @@
 package websocket
 
 import (
+	"context"
+	"excalidraw-server/apierror"
 	"fmt"
 	"reflect"
 	"regexp"
 	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/zishang520/engine.io/v2/types"
 	"github.com/zishang520/engine.io/v2/utils"
 	socketio "github.com/zishang520/socket.io/v2/socket"
@@ -16,11 +19,17 @@ type ackInvoker func(err error, payload map[string]any)
 
 // ChatMessage represents a single chat message in a room
 type ChatMessage struct {
-	ID        string `json:"id"`
-	RoomID    string `json:"roomId"`
-	Sender    string `json:"sender"`
-	Content   string `json:"content"`
-	Timestamp int64  `json:"timestamp"`
+	ID        string    `json:"id"`
+	RoomID    string    `json:"roomId"`
+	Sender    string    `json:"sender"`
+	Content   string    `json:"content"`
+	Timestamp int64     `json:"timestamp"`
+	User      *UserInfo `json:"user,omitempty"`
+	// ReplyTo is the id of the message this one answers, for threads
+	ReplyTo string `json:"replyTo,omitempty"`
+	// Reactions maps an emoji to who reacted with it, in order: user ids,
+	// or socket ids for anonymous members
+	Reactions map[string][]string `json:"reactions,omitempty"`
 }
 
 const maxChatMessagesPerRoom = 1000
@@ -44,9 +53,12 @@ func GetActiveRooms() map[string]int {
 	return rooms
 }
 
-func SetupSocketIO() *socketio.Server {
+// MaxMessageSize is the largest socket.io message a client may send.
+const MaxMessageSize = 5000000
+
+func SetupSocketIO(authOpts AuthOptions) *socketio.Server {
 	opts := socketio.DefaultServerOptions()
-	opts.SetMaxHttpBufferSize(5000000)
+	opts.SetMaxHttpBufferSize(MaxMessageSize)
 	opts.SetPath("/socket.io")
 	opts.SetAllowEIO3(true)
 	localhostOrigin := regexp.MustCompile(`^https?://(localhost|127\.0\.0\.1|\[::1\])(:\d+)?$`)
@@ -58,6 +70,10 @@ func SetupSocketIO() *socketio.Server {
 		Credentials: true,
 	})
 	srv := socketio.NewServer(nil, opts)
+	if authOpts.Mode != AuthOff {
+		srv.Use(authMiddleware(authOpts))
+	}
+	srv.Use(banMiddleware)
 
 	//nolint:errcheck // Socket.IO event handlers do not return useful errors
 	srv.On("connection", func(clients ...any) {
@@ -70,72 +86,19 @@ func SetupSocketIO() *socketio.Server {
 		myRoom := socketio.Room(me)
 		_ = srv.To(myRoom).Emit("init-room")
 		utils.Log().Printf("init room %v\n", myRoom)
+		openOutbox(socket)
+		watchBacklog(socket)
+		startSession(srv, socket, authOpts)
 
 		//nolint:errcheck // Socket.IO event handlers do not return useful errors
 		socket.On("join-room", func(datas ...any) {
 			ack, args := extractAck(datas)
-			if len(args) == 0 {
-				err := fmt.Errorf("room id is required")
-				respondWithAck(socket, ack, "join-room-ack", map[string]any{
-					"status": "error",
-					"error":  err.Error(),
-				}, err)
-				return
-			}
-
-			roomID, ok := args[0].(string)
-			if !ok || roomID == "" {
-				err := fmt.Errorf("invalid room id")
-				respondWithAck(socket, ack, "join-room-ack", map[string]any{
-					"status": "error",
-					"error":  err.Error(),
-				}, err)
+			request, err := decodeJoinRoom(args)
+			if err != nil {
+				respondWithAck(socket, ack, "join-room-ack", errorAckPayload(err), err)
 				return
 			}
-
-			room := socketio.Room(roomID)
-			socket.Join(room)
-			utils.Log().Printf("Socket %v has joined %v\n", me, room)
-
-			srv.In(room).FetchSockets()(func(users []*socketio.RemoteSocket, fetchErr error) {
-				if fetchErr != nil {
-					respondWithAck(socket, ack, "join-room-ack", map[string]any{
-						"status": "error",
-						"error":  fetchErr.Error(),
-					}, fetchErr)
-					return
-				}
-
-				roomsMutex.Lock()
-				activeRooms[roomID] = len(users)
-				roomsMutex.Unlock()
-
-				if len(users) <= 1 {
-					_ = srv.To(myRoom).Emit("first-in-room")
-				} else {
-					utils.Log().Printf("emit new user %v in room %v\n", me, room)
-					_ = socket.Broadcast().To(room).Emit("new-user", me)
-				}
-
-				newRoomUsers := make([]socketio.SocketId, 0, len(users))
-				for _, user := range users {
-					newRoomUsers = append(newRoomUsers, user.Id())
-				}
-				utils.Log().Printf("room %v has users %v\n", room, newRoomUsers)
-				srv.In(room).Emit("room-user-change", newRoomUsers)
-
-				// Send chat history to the newly joined user
-				chatHistoryMessages := getChatHistory(roomID)
-				if len(chatHistoryMessages) > 0 {
-					utils.Log().Printf("Sending %d chat messages to user %v in room %v\n", len(chatHistoryMessages), me, room)
-					_ = srv.To(myRoom).Emit("chat-history", chatHistoryMessages)
-				}
-
-				respondWithAck(socket, ack, "join-room-ack", map[string]any{
-					"status":     "ok",
-					"user_count": len(users),
-				}, nil)
-			})
+			joinRoom(srv, socket, authOpts, request, ack)
 		})
 
 		//nolint:errcheck // Socket.IO event handlers do not return useful errors
@@ -148,43 +111,122 @@ func SetupSocketIO() *socketio.Server {
 			handleBroadcast(socket, datas, true)
 		})
 
+		//nolint:errcheck // Socket.IO event handlers do not return useful errors
+		socket.On("server-delta", func(datas ...any) {
+			handleDelta(socket, srv, datas)
+		})
+
+		//nolint:errcheck // Socket.IO event handlers do not return useful errors
+		socket.On("request-checkpoint", func(datas ...any) {
+			handleCheckpointRequest(socket, datas)
+		})
+
+		//nolint:errcheck // Socket.IO event handlers do not return useful errors
+		socket.On("resync-request", func(datas ...any) {
+			handleResyncRequest(socket, datas)
+		})
+
 		//nolint:errcheck // Socket.IO event handlers do not return useful errors
 		socket.On("server-chat-message", func(datas ...any) {
 			handleChatMessage(socket, srv, datas)
 		})
 
+		//nolint:errcheck // Socket.IO event handlers do not return useful errors
+		socket.On("server-chat-reaction", func(datas ...any) {
+			handleChatReaction(socket, srv, datas)
+		})
+
+		//nolint:errcheck // Socket.IO event handlers do not return useful errors
+		socket.On("server-typing", func(datas ...any) {
+			handleTyping(socket, srv, datas)
+		})
+
+		//nolint:errcheck // Socket.IO event handlers do not return useful errors
+		socket.On("server-reaction", func(datas ...any) {
+			handleCanvasReaction(socket, datas)
+		})
+
+		//nolint:errcheck // Socket.IO event handlers do not return useful errors
+		socket.On("laser-pointer", func(datas ...any) {
+			handleLaserPointer(socket, datas)
+		})
+
+		//nolint:errcheck // Socket.IO event handlers do not return useful errors
+		socket.On("presentation-state", func(datas ...any) {
+			handlePresentation(socket, srv, datas)
+		})
+
+		for _, event := range []string{"moderate-kick", "moderate-ban", "moderate-mute"} {
+			moderationEvent := event
+			//nolint:errcheck // Socket.IO event handlers do not return useful errors
+			socket.On(moderationEvent, func(datas ...any) {
+				handleModeration(socket, srv, moderationEvent, datas)
+			})
+		}
+
+		for _, event := range rtcSignalEvents {
+			signalEvent := event
+			//nolint:errcheck // Socket.IO event handlers do not return useful errors
+			socket.On(signalEvent, func(datas ...any) {
+				handleRTCSignal(socket, srv, signalEvent, datas)
+			})
+		}
+
+		//nolint:errcheck // Socket.IO event handlers do not return useful errors
+		socket.On("rtc-config", func(datas ...any) {
+			handleRTCConfig(socket, datas)
+		})
+
 		socket.On("user-follow", func(datas ...any) {
 			// TODO: Implement user follow functionality
 		})
 
 		socket.On("disconnecting", func(datas ...any) {
+			endSession(me)
+			closeOutbox(me)
+			forgetBacklog(me)
+			forgetCanvasReactions(me)
 			for _, currentRoom := range socket.Rooms().Keys() {
-				roomID := string(currentRoom)
-				srv.In(currentRoom).FetchSockets()(func(users []*socketio.RemoteSocket, _ error) {
-					utils.Log().Printf("disconnecting %v from room %v\n", me, currentRoom)
-
-					otherClients := make([]socketio.SocketId, 0, len(users))
-					for _, userInRoom := range users {
-						if userInRoom.Id() != me {
-							otherClients = append(otherClients, userInRoom.Id())
+				room, roomID := currentRoom, string(currentRoom)
+				runInRoom(roomID, func() {
+					stopTyping(roomID, me, roomTypingEmitter(srv))
+					presenterLeft(roomID, me, true, roomPresentationEmitter(srv))
+					srv.In(room).FetchSockets()(func(users []*socketio.RemoteSocket, _ error) {
+						utils.Log().Printf("disconnecting %v from room %v\n", me, room)
+
+						otherClients := make([]socketio.SocketId, 0, len(users))
+						otherSockets := make([]*socketio.RemoteSocket, 0, len(users))
+						for _, userInRoom := range users {
+							if userInRoom.Id() != me {
+								otherClients = append(otherClients, userInRoom.Id())
+								otherSockets = append(otherSockets, userInRoom)
+							}
+						}
+
+						leaveModeration(roomID, me, otherClients)
+						trackLeave(roomID, me)
+						clearSocketEncoding(roomID, me)
+
+						persist := func() {}
+						roomsMutex.Lock()
+						members := roomMembers(roomID, len(otherClients))
+						if members == 0 {
+							delete(activeRooms, roomID)
+							persist = releaseRoom(roomID)
+							utils.Log().Printf("room %v is now empty, cleared chat history\n", room)
+						} else {
+							activeRooms[roomID] = members
 						}
-					}
-
-					roomsMutex.Lock()
-					if len(otherClients) == 0 {
-						delete(activeRooms, roomID)
-						// Clean up chat history when room becomes empty
-						clearChatHistory(roomID)
-						utils.Log().Printf("room %v is now empty, cleared chat history\n", currentRoom)
-					} else {
-						activeRooms[roomID] = len(otherClients)
-					}
-					roomsMutex.Unlock()
-
-					if len(otherClients) > 0 {
-						utils.Log().Printf("leaving user, room %v has users  %v\n", currentRoom, otherClients)
-						srv.In(currentRoom).Emit("room-user-change", otherClients)
-					}
+						roomsMutex.Unlock()
+						persist()
+						publishPresence(clusterLeave, roomID, me, socketUser(socket), members)
+
+						if len(otherClients) > 0 {
+							utils.Log().Printf("leaving user, room %v has users  %v\n", room, otherClients)
+							srv.In(room).Emit("room-user-change", otherClients)
+						}
+						emitPresence(srv, roomID, otherSockets)
+					})
 				})
 			}
 		})
@@ -198,81 +240,255 @@ func SetupSocketIO() *socketio.Server {
 	return srv
 }
 
+// joinRoom lets a socket into a room once bans and permissions allow it.
+func joinRoom(srv *socketio.Server, socket *socketio.Socket, authOpts AuthOptions, request joinRoomRequest, ack ackInvoker) {
+	roomID := request.roomID
+
+	if until, banned := banExpiry(roomID, banKeys(socketUser(socket), clientAddress(socket.Handshake())), time.Now()); banned {
+		err := apierror.New(apierror.Banned, "banned from room")
+		payload := errorAckPayload(err)
+		payload["until"] = until.UnixMilli()
+		respondWithAck(socket, ack, "join-room-ack", payload, err)
+		return
+	}
+
+	if authOpts.Mode != AuthOff {
+		allowed, err := canJoin(context.Background(), roomID, socketUser(socket))
+		if err != nil {
+			logrus.WithField("room_id", roomID).WithError(err).Error("Failed to check room permissions")
+			err = fmt.Errorf("failed to check room permissions")
+		} else if !allowed {
+			err = apierror.New(apierror.NotAllowedInRoom, "not allowed in room")
+		}
+		if err != nil {
+			respondWithAck(socket, ack, "join-room-ack", errorAckPayload(err), err)
+			return
+		}
+	}
+
+	runInRoom(roomID, func() {
+		enterRoom(srv, socket, request, ack)
+	})
+}
+
+// enterRoom adds a socket that may join a room to it, on the room's worker,
+// and sends it what the room has so far.
+func enterRoom(srv *socketio.Server, socket *socketio.Socket, request joinRoomRequest, ack ackInvoker) {
+	me := socket.Id()
+	myRoom := socketio.Room(me)
+	roomID := request.roomID
+	// It may have left while the join waited for the worker
+	if !socket.Connected() {
+		return
+	}
+
+	room := socketio.Room(roomID)
+	socket.Join(room)
+	setSocketEncoding(roomID, me, request.encoding)
+	trackJoin(roomID, me, socketUser(socket), time.Now())
+	utils.Log().Printf("Socket %v has joined %v\n", me, room)
+
+	srv.In(room).FetchSockets()(func(users []*socketio.RemoteSocket, fetchErr error) {
+		if fetchErr != nil {
+			respondWithAck(socket, ack, "join-room-ack", errorAckPayload(fetchErr), fetchErr)
+			return
+		}
+
+		roomsMutex.Lock()
+		members := roomMembers(roomID, len(users))
+		activeRooms[roomID] = members
+		roomsMutex.Unlock()
+		publishPresence(clusterJoin, roomID, me, socketUser(socket), members)
+
+		isOwner := claimOwnership(roomID, me, socketUser(socket), len(users) <= 1)
+		mode := initRoomMode(roomID, request.mode, members <= 1)
+
+		if len(users) <= 1 {
+			_ = srv.To(myRoom).Emit("first-in-room")
+		} else {
+			utils.Log().Printf("emit new user %v in room %v\n", me, room)
+			_ = socket.Broadcast().To(room).Emit("new-user", me)
+		}
+
+		newRoomUsers := make([]socketio.SocketId, 0, len(users))
+		for _, user := range users {
+			newRoomUsers = append(newRoomUsers, user.Id())
+		}
+		utils.Log().Printf("room %v has users %v\n", room, newRoomUsers)
+		srv.In(room).Emit("room-user-change", newRoomUsers)
+		emitPresence(srv, roomID, users)
+
+		// Bring back the chat and scene of a hibernated room
+		wakeRoom(roomID, members <= 1)
+
+		// Send chat history to the newly joined user
+		chatHistoryMessages := getChatHistory(roomID)
+		if len(chatHistoryMessages) > 0 {
+			utils.Log().Printf("Sending %d chat messages to user %v in room %v\n", len(chatHistoryMessages), me, room)
+			_ = srv.To(myRoom).Emit("chat-history", chatHistoryMessages)
+		}
+
+		// Joiners start from the last full scene instead of waiting for
+		// another client, which may be in the background, to send it
+		sceneInitSent := sendSceneInit(socket, roomID)
+
+		// Late joiners of a delta sync room start from the full scene
+		if mode != RoomModeEncrypted {
+			sendCheckpoint(socket, roomID)
+		}
+
+		// A presenter coming back takes its presentation over again; others
+		// start following it
+		if !reclaimPresentation(roomID, me, socketUser(socket), "", roomPresentationEmitter(srv)) {
+			sendPresentation(socket, roomID)
+		}
+
+		rememberSessionRoom(me, request)
+		respondWithAck(socket, ack, "join-room-ack", map[string]any{
+			"status":     "ok",
+			"user_count": len(users),
+			"is_owner":   isOwner,
+			"mode":       mode,
+			"snapshots":  mode != RoomModeEncrypted,
+			"delta":      mode != RoomModeEncrypted,
+			"protocol":   request.protocol,
+			"encoding":   request.encoding,
+			"scene_init": sceneInitSent,
+			"seq":        currentBroadcastSeq(roomID),
+		}, nil)
+	})
+}
+
 func handleBroadcast(socket *socketio.Socket, datas []any, volatile bool) {
-	roomID, payload, metadata, ack := parseBroadcastArgs(datas)
-	if roomID == "" {
-		err := fmt.Errorf("missing room id")
+	received := time.Now()
+	ack, args := extractAck(datas)
+	request, err := decodeBroadcast(args)
+	if err != nil {
+		var original any
+		if len(args) > 1 {
+			original = args[1]
+		}
+		respondWithAck(socket, ack, "broadcast-ack", makeBroadcastAckPayload(original, err), err)
+		return
+	}
+	size := payloadSize(request.payload) + payloadSize(request.metadata)
+	if err := checkMessageSize(request.roomID, broadcastKind(volatile), size); err != nil {
+		respondWithAck(socket, ack, "broadcast-ack", makeBroadcastAckPayload(request.payload, err), err)
+		return
+	}
+	runInRoom(request.roomID, func() {
+		relaySocketBroadcast(socket, ack, request, received, volatile)
+	})
+}
+
+// relaySocketBroadcast relays a socket's broadcast on its room's worker and
+// answers it.
+func relaySocketBroadcast(socket *socketio.Socket, ack ackInvoker, request broadcastRequest, received time.Time, volatile bool) {
+	roomID, metadata := request.roomID, request.metadata
+	wakeRoom(roomID, false)
+
+	payload, packed, err := decodeScenePayload(roomID, socket.Id(), request.payload)
+	if err != nil {
+		respondWithAck(socket, ack, "broadcast-ack", makeBroadcastAckPayload(nil, err), err)
+		return
+	}
+
+	if !volatile && isMuted(roomID, socket.Id()) {
+		err := apierror.New(apierror.Muted, "muted in room")
 		respondWithAck(socket, ack, "broadcast-ack", makeBroadcastAckPayload(payload, err), err)
 		return
 	}
 
 	utils.Log().Printf(" user %v sends update to room %v\n", socket.Id(), roomID)
 
-	var emitErr error
-	if volatile {
-		emitErr = socket.Volatile().Broadcast().To(socketio.Room(roomID)).Emit("client-broadcast", payload, metadata)
-	} else {
-		emitErr = socket.Broadcast().To(socketio.Room(roomID)).Emit("client-broadcast", payload, metadata)
+	relay := func(payload any, packed []byte, received time.Time) (int64, error) {
+		seq, err := sequenceBroadcast(roomID, func(seq int64) error {
+			sendRawBroadcast(roomID, socket.Id(), payload, metadata, seq, volatile)
+			var lagging []socketio.Room
+			if volatile {
+				lagging = holdVolatile(roomID, socket.Id(), heldBroadcast{scene: payload, packed: packed, metadata: metadata, seq: seq})
+			}
+			return relayBroadcast(func() *socketio.BroadcastOperator {
+				if volatile {
+					return socket.Volatile().Broadcast().Except(lagging...)
+				}
+				return socket.Broadcast()
+			}, roomID, payload, packed, metadata, seq)
+		})
+		if err != nil {
+			return seq, err
+		}
+		trackRelayLatency(roomID, time.Since(received))
+
+		size := payloadSize(payload)
+		if packed != nil {
+			size = len(packed)
+		}
+		trackBroadcast(roomID, size, time.Now())
+		if !volatile {
+			recordBroadcast(roomID, string(socket.Id()), payload)
+			keepSceneInit(roomID, payload, packed, metadata)
+		}
+
+		publishClusterEvent(clusterEvent{
+			Type:     clusterBroadcast,
+			RoomID:   roomID,
+			SocketID: string(socket.Id()),
+			Payload:  payload,
+			Metadata: metadata,
+			Volatile: volatile,
+		})
+		return seq, nil
+	}
+	respond := func(seq int64, err error) {
+		if err != nil {
+			respondWithAck(socket, ack, "broadcast-ack", makeBroadcastAckPayload(payload, err), err)
+			return
+		}
+		ackPayload := makeBroadcastAckPayload(payload, nil)
+		ackPayload["seq"] = seq
+		respondWithAck(socket, ack, "broadcast-ack", ackPayload, nil)
 	}
 
-	if emitErr != nil {
-		respondWithAck(socket, ack, "broadcast-ack", makeBroadcastAckPayload(payload, emitErr), emitErr)
+	// Scene updates may wait for the sender's next ones and go out merged
+	// with them; they are then acknowledged with the merged broadcast's seq
+	if !volatile && coalesceBroadcast(roomID, socket.Id(), payload, metadata, func(update any) (int64, error) {
+		return relay(update, nil, time.Now())
+	}, respond) {
 		return
 	}
-
-	respondWithAck(socket, ack, "broadcast-ack", makeBroadcastAckPayload(payload, nil), nil)
+	respond(relay(payload, packed, received))
 }
 
 func handleChatMessage(socket *socketio.Socket, srv *socketio.Server, datas []any) {
 	ack, args := extractAck(datas)
-
-	if len(args) < 2 {
-		err := fmt.Errorf("invalid chat message format")
-		respondWithAck(socket, ack, "", map[string]any{
-			"status": "error",
-			"error":  err.Error(),
-		}, err)
+	request, err := decodeChatMessage(args)
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
 		return
 	}
-
-	roomID, ok := args[0].(string)
-	if !ok || roomID == "" {
-		err := fmt.Errorf("missing or invalid room id")
-		respondWithAck(socket, ack, "", map[string]any{
-			"status": "error",
-			"error":  err.Error(),
-		}, err)
+	roomID, messageID, content := request.roomID, request.id, request.content
+	if err := checkMessageSize(roomID, chatPayload, len(content)); err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
 		return
 	}
+	wakeRoom(roomID, false)
 
-	messageData, ok := args[1].(map[string]any)
-	if !ok {
-		err := fmt.Errorf("invalid message data")
-		respondWithAck(socket, ack, "", map[string]any{
-			"status": "error",
-			"error":  err.Error(),
-		}, err)
+	if isMuted(roomID, socket.Id()) {
+		err := apierror.New(apierror.Muted, "muted in room")
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
 		return
 	}
 
-	// Extract message fields
-	content, _ := messageData["content"].(string)
-	if content == "" {
-		err := fmt.Errorf("message content is required")
-		respondWithAck(socket, ack, "", map[string]any{
-			"status": "error",
-			"error":  err.Error(),
-		}, err)
+	if request.replyTo != "" && !hasChatMessage(roomID, request.replyTo) {
+		err := &payloadError{"message.replyTo", "must be the id of a message in the room's history"}
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
 		return
 	}
 
-	messageID, _ := messageData["id"].(string)
-	if messageID == "" {
-		err := fmt.Errorf("message id is required")
-		respondWithAck(socket, ack, "", map[string]any{
-			"status": "error",
-			"error":  err.Error(),
-		}, err)
+	content, err = filterChatMessage(socket, srv, roomID, messageID, content)
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
 		return
 	}
 
@@ -283,19 +499,20 @@ func handleChatMessage(socket *socketio.Socket, srv *socketio.Server, datas []an
 		Sender:    string(socket.Id()),
 		Content:   content,
 		Timestamp: time.Now().UnixMilli(),
+		User:      socketUser(socket),
+		ReplyTo:   request.replyTo,
 	}
 
 	// Store message in history
 	addChatMessage(roomID, message)
+	stopTyping(roomID, socket.Id(), roomTypingEmitter(srv))
 	utils.Log().Printf("user %v sent chat message to room %v\n", socket.Id(), roomID)
+	deliverMentions(srv, message)
 
 	// Broadcast to all users in the room (including sender)
-	emitErr := srv.To(socketio.Room(roomID)).Emit("client-chat-message", message)
+	emitErr := emitChatEvent(srv, roomID, "client-chat-message", message)
 	if emitErr != nil {
-		respondWithAck(socket, ack, "", map[string]any{
-			"status": "error",
-			"error":  emitErr.Error(),
-		}, emitErr)
+		respondWithAck(socket, ack, "", errorAckPayload(emitErr), emitErr)
 		return
 	}
 
@@ -305,6 +522,22 @@ func handleChatMessage(socket *socketio.Socket, srv *socketio.Server, datas []an
 	}, nil)
 }
 
+// emitChatEvent sends a chat event to the whole room and its subscribers;
+// sockets that acknowledge events get it through their outbox.
+func emitChatEvent(srv *socketio.Server, roomID, event string, payload any) error {
+	reliable := reliableMembers(roomID)
+	except := make([]socketio.Room, 0, len(reliable))
+	for _, member := range reliable {
+		except = append(except, socketio.Room(member))
+	}
+	err := srv.To(socketio.Room(roomID)).Except(except...).Emit(event, payload)
+	for _, member := range reliable {
+		deliverReliably(member, event, payload)
+	}
+	publishRoomEvent(roomID, event, payload)
+	return err
+}
+
 func extractAck(datas []any) (ack ackInvoker, args []any) {
 	if len(datas) == 0 {
 		return nil, datas
@@ -416,6 +649,7 @@ func convertMap(source map[string]any, targetType reflect.Type) reflect.Value {
 }
 
 func respondWithAck(socket *socketio.Socket, ack ackInvoker, event string, payload map[string]any, ackErr error) {
+	reportSocketViolation(socket, ackErr)
 	if ack != nil {
 		ack(ackErr, payload)
 	}
@@ -425,26 +659,13 @@ func respondWithAck(socket *socketio.Socket, ack ackInvoker, event string, paylo
 	}
 }
 
-func parseBroadcastArgs(datas []any) (roomID string, payload, metadata any, ack ackInvoker) {
-	ack, args := extractAck(datas)
-	if len(args) < 3 {
-		return "", nil, nil, ack
-	}
-
-	roomID, _ = args[0].(string)
-	payload = args[1]
-	metadata = args[2]
-	return roomID, payload, metadata, ack
-}
-
 func makeBroadcastAckPayload(original any, ackErr error) map[string]any {
 	response := map[string]any{
 		"status": "ok",
 	}
 
 	if ackErr != nil {
-		response["status"] = "error"
-		response["error"] = ackErr.Error()
+		response = errorAckPayload(ackErr)
 	}
 
 	if messageID := extractMessageID(original); messageID != "" {
@@ -467,24 +688,36 @@ func extractMessageID(original any) string {
 	return ""
 }
 
-// addChatMessage adds a message to room's chat history, maintaining the max size limit
+// releaseRoom drops the in-memory state of a room that has become empty. It
+// runs under roomsMutex, so the store writes that hibernate the room and
+// finish its recording are left to the returned function, which the caller
+// runs on the room's worker after unlocking.
+func releaseRoom(roomID string) (persist func()) {
+	save := hibernateEmptyRoom(roomID)
+	clearChatHistory(roomID)
+	clearRoomMode(roomID)
+	forgetRoomMessageLimits(roomID)
+	clearRoomTraffic(roomID)
+	clearRoomEncodings(roomID)
+	clearRoomScene(roomID)
+	clearSceneInit(roomID)
+	clearBroadcastSeq(roomID)
+	clearPresentation(roomID)
+	return func() {
+		save()
+		finishRoomRecording(roomID)
+	}
+}
+
+// addChatMessage adds a message to room's chat history, evicting the oldest
+// past maxChatMessagesPerRoom or the chat budgets
 func addChatMessage(roomID string, message ChatMessage) {
 	chatHistoryMutex.Lock()
 	defer chatHistoryMutex.Unlock()
 
-	messages, exists := chatHistory[roomID]
-	if !exists {
-		messages = make([]ChatMessage, 0, maxChatMessagesPerRoom)
-	}
-
-	messages = append(messages, message)
-
-	// Keep only the last maxChatMessagesPerRoom messages
-	if len(messages) > maxChatMessagesPerRoom {
-		messages = messages[len(messages)-maxChatMessagesPerRoom:]
-	}
-
-	chatHistory[roomID] = messages
+	appendChatMessage(roomID, message)
+	dropChatMessages(roomID, len(chatHistory[roomID])-maxChatMessagesPerRoom)
+	evictChat(roomID)
 }
 
 // getChatHistory retrieves chat history for a room
@@ -507,5 +740,5 @@ func getChatHistory(roomID string) []ChatMessage {
 func clearChatHistory(roomID string) {
 	chatHistoryMutex.Lock()
 	defer chatHistoryMutex.Unlock()
-	delete(chatHistory, roomID)
+	setChatHistory(roomID, nil)
 }
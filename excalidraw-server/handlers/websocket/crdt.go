@@ -0,0 +1,430 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	ws "github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// y-websocket message types and sync steps, see y-protocols.
+const (
+	yMessageSync           = 0
+	yMessageAwareness      = 1
+	yMessageQueryAwareness = 3
+
+	ySyncStep1  = 0
+	ySyncStep2  = 1
+	ySyncUpdate = 2
+)
+
+// crdtWriteTimeout bounds how long a slow peer may hold up its room.
+const crdtWriteTimeout = 10 * time.Second
+
+var (
+	// yEmptyStateVector asks a peer for everything it has.
+	yEmptyStateVector = []byte{0}
+	// yEmptyUpdate is a Yjs update without structs or deletions.
+	yEmptyUpdate = []byte{0, 0}
+)
+
+// crdtRoom is a room edited through the CRDT sync provider. The server
+// doesn't decode Yjs updates: it keeps the room's update log, which clients
+// merge themselves, and compacts it with the full state of each peer that
+// syncs.
+type crdtRoom struct {
+	id    string
+	store core.CRDTStore
+
+	mu      sync.Mutex
+	updates [][]byte
+	peers   map[*crdtPeer]struct{}
+}
+
+type crdtPeer struct {
+	conn    *ws.Conn
+	writeMu sync.Mutex
+
+	// Guarded by the room's mu: awareness is the last awareness update the
+	// peer sent, clocks the clock of each awareness client it announced,
+	// and compactUpTo the log length it was sent before being asked for its
+	// state, or -1 when no answer is due.
+	awareness   []byte
+	clocks      map[uint64]uint64
+	compactUpTo int
+}
+
+var (
+	crdtRooms      = make(map[string]*crdtRoom)
+	crdtRoomsMutex sync.Mutex
+)
+
+// HandleCRDTSync serves the y-websocket protocol on /{roomId}, so clients
+// using Yjs bindings get conflict-free merging and offline edits. Updates are
+// persisted through store. Clients pass a JWT in the token query parameter,
+// which is checked like the collab handshake.
+func HandleCRDTSync(store core.CRDTStore, authOpts AuthOptions) http.HandlerFunc {
+	upgrader := ws.Upgrader{CheckOrigin: allowedWebSocketOrigin}
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+		if IsEncryptedRoom(roomID) {
+			apierror.Write(w, http.StatusConflict, apierror.RoomEncrypted, "CRDT sync isn't available in encrypted rooms")
+			return
+		}
+
+		if _, ok := authorizeRoomRequest(w, r, roomID, authOpts); !ok {
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already replied
+			return
+		}
+		conn.SetReadLimit(MaxMessageSize)
+
+		room, peer, err := joinCRDTRoom(r.Context(), store, roomID, conn)
+		if err != nil {
+			logrus.WithField("room_id", roomID).WithError(err).Error("Failed to load CRDT updates")
+			_ = conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseInternalServerErr, "failed to load room"), time.Now().Add(crdtWriteTimeout))
+			_ = conn.Close()
+			return
+		}
+		defer room.leave(peer)
+
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if messageType != ws.BinaryMessage {
+				continue
+			}
+			if err := room.handleMessage(peer, message); err != nil {
+				logrus.WithField("room_id", roomID).WithError(err).Debug("Dropped CRDT sync message")
+			}
+		}
+	}
+}
+
+// allowedWebSocketOrigin accepts the origins the socket.io server allows, the
+// server's own origin and clients that don't send one.
+func allowedWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		switch parsed.Hostname() {
+		case "localhost", "127.0.0.1", "::1":
+			return true
+		}
+		return parsed.Host == r.Host
+	case "tauri":
+		return parsed.Hostname() == "localhost"
+	}
+	return false
+}
+
+// joinCRDTRoom adds a connection to a room, loading the room's log when it
+// is the first peer, and sends it the awareness of the others.
+func joinCRDTRoom(ctx context.Context, store core.CRDTStore, roomID string, conn *ws.Conn) (*crdtRoom, *crdtPeer, error) {
+	crdtRoomsMutex.Lock()
+	room, exists := crdtRooms[roomID]
+	if !exists {
+		updates, err := store.GetCRDTUpdates(ctx, roomID)
+		if err != nil {
+			crdtRoomsMutex.Unlock()
+			return nil, nil, err
+		}
+		room = &crdtRoom{id: roomID, store: store, updates: updates, peers: make(map[*crdtPeer]struct{})}
+		crdtRooms[roomID] = room
+	}
+	peer := &crdtPeer{conn: conn, clocks: make(map[uint64]uint64), compactUpTo: -1}
+	room.mu.Lock()
+	room.peers[peer] = struct{}{}
+	crdtRoomsMutex.Unlock()
+
+	for other := range room.peers {
+		if other != peer && other.awareness != nil {
+			peer.send(yAwarenessMessage(other.awareness))
+		}
+	}
+	room.mu.Unlock()
+	return room, peer, nil
+}
+
+// leave removes a peer, tells the others its awareness clients are gone and
+// forgets the room once it is empty. The log stays in the store.
+func (room *crdtRoom) leave(peer *crdtPeer) {
+	_ = peer.conn.Close()
+
+	crdtRoomsMutex.Lock()
+	room.mu.Lock()
+	delete(room.peers, peer)
+	if len(room.peers) == 0 {
+		delete(crdtRooms, room.id)
+	}
+	crdtRoomsMutex.Unlock()
+
+	if len(peer.clocks) > 0 {
+		room.broadcast(peer, yAwarenessMessage(yAwarenessRemoval(peer.clocks)))
+	}
+	room.mu.Unlock()
+}
+
+func (room *crdtRoom) handleMessage(peer *crdtPeer, message []byte) error {
+	decoder := &yDecoder{data: message}
+	messageType, err := decoder.uint()
+	if err != nil {
+		return err
+	}
+
+	switch messageType {
+	case yMessageSync:
+		step, err := decoder.uint()
+		if err != nil {
+			return err
+		}
+		payload, err := decoder.bytes()
+		if err != nil {
+			return err
+		}
+		switch step {
+		case ySyncStep1:
+			room.sync(peer)
+		case ySyncStep2:
+			return room.receiveState(peer, payload)
+		case ySyncUpdate:
+			return room.receiveUpdate(peer, payload)
+		default:
+			return fmt.Errorf("unknown sync step %d", step)
+		}
+	case yMessageAwareness:
+		payload, err := decoder.bytes()
+		if err != nil {
+			return err
+		}
+		clocks, err := yAwarenessClocks(payload)
+		if err != nil {
+			return err
+		}
+		room.mu.Lock()
+		defer room.mu.Unlock()
+		peer.awareness = payload
+		for client, clock := range clocks {
+			peer.clocks[client] = clock
+		}
+		room.broadcast(peer, message)
+	case yMessageQueryAwareness:
+		room.mu.Lock()
+		defer room.mu.Unlock()
+		for other := range room.peers {
+			if other.awareness != nil {
+				peer.send(yAwarenessMessage(other.awareness))
+			}
+		}
+	}
+	return nil
+}
+
+// sync answers a peer's sync step 1. Without decoding its state vector the
+// peer gets the whole log, the last update as sync step 2, and is then
+// asked for its full state, which replaces the log it was sent.
+func (room *crdtRoom) sync(peer *crdtPeer) {
+	room.mu.Lock()
+	updates := room.updates
+	peer.compactUpTo = len(updates)
+	room.mu.Unlock()
+
+	if len(updates) == 0 {
+		peer.send(ySyncMessage(ySyncStep2, yEmptyUpdate))
+	}
+	for i, update := range updates {
+		step := uint64(ySyncUpdate)
+		if i == len(updates)-1 {
+			step = ySyncStep2
+		}
+		peer.send(ySyncMessage(step, update))
+	}
+	peer.send(ySyncMessage(ySyncStep1, yEmptyStateVector))
+}
+
+// receiveState handles a peer's sync step 2, which is its full state when it
+// answers the step 1 sent by sync.
+func (room *crdtRoom) receiveState(peer *crdtPeer, state []byte) error {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	n := peer.compactUpTo
+	peer.compactUpTo = -1
+	if n <= 0 || bytes.Equal(state, yEmptyUpdate) {
+		return room.appendUpdate(peer, state)
+	}
+
+	if err := room.store.CompactCRDTUpdates(context.Background(), room.id, n, state); err != nil {
+		return fmt.Errorf("failed to compact CRDT updates: %w", err)
+	}
+	compacted := make([][]byte, 0, len(room.updates)-n+1)
+	room.updates = append(append(compacted, state), room.updates[n:]...)
+	// Other states on their way were taken against the old log, so they are
+	// appended rather than compacted
+	for other := range room.peers {
+		other.compactUpTo = -1
+	}
+	// The state may hold edits the peer made offline
+	room.broadcast(peer, ySyncMessage(ySyncUpdate, state))
+	return nil
+}
+
+func (room *crdtRoom) receiveUpdate(peer *crdtPeer, update []byte) error {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return room.appendUpdate(peer, update)
+}
+
+// appendUpdate persists an update and relays it to the other peers. Callers
+// hold room.mu.
+func (room *crdtRoom) appendUpdate(from *crdtPeer, update []byte) error {
+	if bytes.Equal(update, yEmptyUpdate) {
+		return nil
+	}
+	if err := room.store.AppendCRDTUpdate(context.Background(), room.id, update); err != nil {
+		return fmt.Errorf("failed to persist CRDT update: %w", err)
+	}
+	room.updates = append(room.updates, update)
+	room.broadcast(from, ySyncMessage(ySyncUpdate, update))
+	return nil
+}
+
+// broadcast sends a message to every peer but from. Callers hold room.mu.
+func (room *crdtRoom) broadcast(from *crdtPeer, message []byte) {
+	for peer := range room.peers {
+		if peer != from {
+			peer.send(message)
+		}
+	}
+}
+
+// send writes a message to the peer, closing the connection if it fails so
+// the peer's read loop ends.
+func (peer *crdtPeer) send(message []byte) {
+	peer.writeMu.Lock()
+	defer peer.writeMu.Unlock()
+	_ = peer.conn.SetWriteDeadline(time.Now().Add(crdtWriteTimeout))
+	if err := peer.conn.WriteMessage(ws.BinaryMessage, message); err != nil {
+		_ = peer.conn.Close()
+	}
+}
+
+func ySyncMessage(step uint64, payload []byte) []byte {
+	message := yAppendUint(nil, yMessageSync)
+	message = yAppendUint(message, step)
+	return yAppendBytes(message, payload)
+}
+
+func yAwarenessMessage(update []byte) []byte {
+	return yAppendBytes(yAppendUint(nil, yMessageAwareness), update)
+}
+
+// yAwarenessClocks reads the client ids and clocks of an awareness update.
+func yAwarenessClocks(update []byte) (map[uint64]uint64, error) {
+	decoder := &yDecoder{data: update}
+	count, err := decoder.uint()
+	if err != nil {
+		return nil, err
+	}
+	clocks := make(map[uint64]uint64)
+	for i := uint64(0); i < count; i++ {
+		client, err := decoder.uint()
+		if err != nil {
+			return nil, err
+		}
+		clock, err := decoder.uint()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := decoder.bytes(); err != nil {
+			return nil, err
+		}
+		clocks[client] = clock
+	}
+	return clocks, nil
+}
+
+// yAwarenessRemoval is the awareness update a client sends when it goes
+// away: each of its states set to null with the next clock.
+func yAwarenessRemoval(clocks map[uint64]uint64) []byte {
+	update := yAppendUint(nil, uint64(len(clocks)))
+	for client, clock := range clocks {
+		update = yAppendUint(update, client)
+		update = yAppendUint(update, clock+1)
+		update = yAppendBytes(update, []byte("null"))
+	}
+	return update
+}
+
+// yAppendUint appends a lib0 variable-length unsigned integer.
+func yAppendUint(buf []byte, value uint64) []byte {
+	for value > 0x7f {
+		buf = append(buf, byte(value&0x7f)|0x80)
+		value >>= 7
+	}
+	return append(buf, byte(value))
+}
+
+// yAppendBytes appends a lib0 length-prefixed byte array.
+func yAppendBytes(buf, value []byte) []byte {
+	return append(yAppendUint(buf, uint64(len(value))), value...)
+}
+
+var errYTruncated = errors.New("truncated y-protocol message")
+
+// yDecoder reads lib0-encoded values.
+type yDecoder struct {
+	data []byte
+}
+
+func (d *yDecoder) uint() (uint64, error) {
+	var value uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if len(d.data) == 0 {
+			return 0, errYTruncated
+		}
+		b := d.data[0]
+		d.data = d.data[1:]
+		value |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return value, nil
+		}
+	}
+	return 0, errors.New("y-protocol integer overflows 64 bits")
+}
+
+func (d *yDecoder) bytes() ([]byte, error) {
+	length, err := d.uint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(d.data)) {
+		return nil, errYTruncated
+	}
+	value := d.data[:length]
+	d.data = d.data[length:]
+	return value, nil
+}
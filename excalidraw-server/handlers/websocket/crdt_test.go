@@ -0,0 +1,164 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	ws "github.com/gorilla/websocket"
+)
+
+func TestYEncoding(t *testing.T) {
+	for _, value := range []uint64{0, 1, 127, 128, 300, 1 << 32, 1<<64 - 1} {
+		decoder := &yDecoder{data: yAppendUint(nil, value)}
+		got, err := decoder.uint()
+		if err != nil || got != value || len(decoder.data) != 0 {
+			t.Errorf("uint round trip of %d = %d, %v with %d bytes left", value, got, err, len(decoder.data))
+		}
+	}
+
+	decoder := &yDecoder{data: []byte{5, 'a', 'b'}}
+	if _, err := decoder.bytes(); err == nil {
+		t.Error("bytes() of a truncated array succeeded")
+	}
+
+	removal := yAwarenessRemoval(map[uint64]uint64{42: 7})
+	clocks, err := yAwarenessClocks(removal)
+	if err != nil || len(clocks) != 1 || clocks[42] != 8 {
+		t.Errorf("yAwarenessClocks() of a removal = %v, %v, want client 42 at clock 8", clocks, err)
+	}
+}
+
+func newCRDTServer(t *testing.T, authOpts AuthOptions) (*httptest.Server, core.CRDTStore) {
+	t.Helper()
+	store := memory.NewDocumentStore().(core.CRDTStore)
+	r := chi.NewRouter()
+	r.Get("/yjs/{roomId}", HandleCRDTSync(store, authOpts))
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+func dialCRDT(t *testing.T, server *httptest.Server, roomID string) *ws.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/yjs/" + roomID
+	conn, _, err := ws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func sendY(t *testing.T, conn *ws.Conn, message []byte) {
+	t.Helper()
+	if err := conn.WriteMessage(ws.BinaryMessage, message); err != nil {
+		t.Fatalf("WriteMessage() failed: %v", err)
+	}
+}
+
+func expectY(t *testing.T, conn *ws.Conn, want []byte) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() failed: %v", err)
+	}
+	if !bytes.Equal(message, want) {
+		t.Fatalf("got message %v, want %v", message, want)
+	}
+}
+
+func waitForUpdates(t *testing.T, store core.CRDTStore, roomID string, want ...string) {
+	t.Helper()
+	var got [][]byte
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		got, _ = store.GetCRDTUpdates(context.Background(), roomID)
+		if len(got) == len(want) {
+			break
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("stored updates = %q, want %q", got, want)
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Fatalf("stored updates = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestCRDTSync(t *testing.T) {
+	server, store := newCRDTServer(t, AuthOptions{Mode: AuthOff})
+
+	alice := dialCRDT(t, server, "crdt-room")
+	sendY(t, alice, ySyncMessage(ySyncStep1, yEmptyStateVector))
+	expectY(t, alice, ySyncMessage(ySyncStep2, yEmptyUpdate))
+	expectY(t, alice, ySyncMessage(ySyncStep1, yEmptyStateVector))
+	sendY(t, alice, ySyncMessage(ySyncStep2, yEmptyUpdate))
+	sendY(t, alice, ySyncMessage(ySyncUpdate, []byte("u1")))
+	sendY(t, alice, ySyncMessage(ySyncUpdate, []byte("u2")))
+	waitForUpdates(t, store, "crdt-room", "u1", "u2")
+
+	// A new peer gets the log and is asked for its state, which replaces it
+	bob := dialCRDT(t, server, "crdt-room")
+	sendY(t, bob, ySyncMessage(ySyncStep1, yEmptyStateVector))
+	expectY(t, bob, ySyncMessage(ySyncUpdate, []byte("u1")))
+	expectY(t, bob, ySyncMessage(ySyncStep2, []byte("u2")))
+	expectY(t, bob, ySyncMessage(ySyncStep1, yEmptyStateVector))
+	sendY(t, bob, ySyncMessage(ySyncStep2, []byte("u1+u2+offline")))
+	expectY(t, alice, ySyncMessage(ySyncUpdate, []byte("u1+u2+offline")))
+	waitForUpdates(t, store, "crdt-room", "u1+u2+offline")
+
+	awareness := yAppendBytes(yAppendUint(yAppendUint(yAppendUint(nil, 1), 42), 3), []byte(`{"user":"bob"}`))
+	sendY(t, bob, yAwarenessMessage(awareness))
+	expectY(t, alice, yAwarenessMessage(awareness))
+
+	// Leaving clears the peer's awareness for the others
+	_ = bob.Close()
+	expectY(t, alice, yAwarenessMessage(yAwarenessRemoval(map[uint64]uint64{42: 3})))
+
+	// The log outlives the room
+	_ = alice.Close()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		crdtRoomsMutex.Lock()
+		_, exists := crdtRooms["crdt-room"]
+		crdtRoomsMutex.Unlock()
+		if !exists {
+			break
+		}
+	}
+	carol := dialCRDT(t, server, "crdt-room")
+	sendY(t, carol, ySyncMessage(ySyncStep1, yEmptyStateVector))
+	expectY(t, carol, ySyncMessage(ySyncStep2, []byte("u1+u2+offline")))
+}
+
+func TestCRDTSyncAuth(t *testing.T) {
+	verifier := auth.NewVerifier([]byte("secret"))
+	server, _ := newCRDTServer(t, AuthOptions{Mode: AuthRequired, Verifier: verifier})
+
+	resp, err := http.Get(server.URL + "/yjs/crdt-auth-room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status without a token = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	token, err := verifier.Sign(&auth.Claims{Subject: "alice", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := dialCRDT(t, server, "crdt-auth-room?token="+token)
+	sendY(t, conn, ySyncMessage(ySyncStep1, yEmptyStateVector))
+	expectY(t, conn, ySyncMessage(ySyncStep2, yEmptyUpdate))
+}
@@ -0,0 +1,288 @@
+package websocket
+
+import (
+	"excalidraw-server/apierror"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zishang520/engine.io/v2/utils"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const (
+	// deltaFlushInterval is how long patches are collected before the
+	// consolidated diff goes out, so an element dragged by several clients
+	// is sent once per interval.
+	deltaFlushInterval = 50 * time.Millisecond
+	// checkpointEvery is how many diffs go out between full-state
+	// checkpoints, which let clients that missed a diff converge.
+	checkpointEvery = 200
+	// maxRoomElements bounds the canonical scene kept for a room.
+	maxRoomElements = 100000
+)
+
+// roomScene is the canonical element map of a room in delta sync mode.
+type roomScene struct {
+	// elements by id; order keeps the order elements were first seen in
+	elements map[string]map[string]any
+	order    []string
+	// seq numbers the diffs sent to the room
+	seq int64
+	// pending holds accepted patches not yet sent, by id
+	pending         map[string]map[string]any
+	pendingOrder    []string
+	flushScheduled  bool
+	sinceCheckpoint int
+}
+
+var (
+	roomScenes      = make(map[string]*roomScene)
+	roomScenesMutex sync.Mutex
+)
+
+func newRoomScene() *roomScene {
+	return &roomScene{
+		elements: make(map[string]map[string]any),
+		pending:  make(map[string]map[string]any),
+	}
+}
+
+// apply merges patches into the scene the way Excalidraw reconciles
+// elements: a patch wins with a higher version, or the same version and a
+// lower versionNonce. It returns the patches that were accepted.
+func (s *roomScene) apply(patches []map[string]any) ([]map[string]any, error) {
+	accepted := make([]map[string]any, 0, len(patches))
+	for _, patch := range patches {
+		id := patch["id"].(string)
+		current, exists := s.elements[id]
+		if exists && !newerElement(patch, current) {
+			continue
+		}
+		if !exists {
+			if len(s.elements) >= maxRoomElements {
+				return accepted, apierror.Errorf(apierror.TooLarge, "room has more than %d elements", maxRoomElements)
+			}
+			s.order = append(s.order, id)
+		}
+		s.elements[id] = patch
+		if _, queued := s.pending[id]; !queued {
+			s.pendingOrder = append(s.pendingOrder, id)
+		}
+		s.pending[id] = patch
+		accepted = append(accepted, patch)
+	}
+	return accepted, nil
+}
+
+func newerElement(patch, current map[string]any) bool {
+	patchVersion, _ := number(patch["version"])
+	currentVersion, _ := number(current["version"])
+	if patchVersion != currentVersion {
+		return patchVersion > currentVersion
+	}
+	patchNonce, _ := number(patch["versionNonce"])
+	currentNonce, _ := number(current["versionNonce"])
+	return patchNonce < currentNonce
+}
+
+// flush takes the pending patches as the next diff. checkpoint is true when
+// a full-state checkpoint is due after it.
+func (s *roomScene) flush() (diff []map[string]any, seq int64, checkpoint bool) {
+	s.flushScheduled = false
+	if len(s.pendingOrder) == 0 {
+		return nil, s.seq, false
+	}
+	diff = make([]map[string]any, 0, len(s.pendingOrder))
+	for _, id := range s.pendingOrder {
+		diff = append(diff, s.pending[id])
+	}
+	s.pending = make(map[string]map[string]any)
+	s.pendingOrder = nil
+	s.seq++
+	s.sinceCheckpoint++
+	if s.sinceCheckpoint >= checkpointEvery {
+		s.sinceCheckpoint = 0
+		checkpoint = true
+	}
+	return diff, s.seq, checkpoint
+}
+
+// checkpoint returns every element, including pending ones, and the seq of
+// the last diff sent.
+func (s *roomScene) checkpoint() ([]map[string]any, int64) {
+	elements := make([]map[string]any, 0, len(s.order))
+	for _, id := range s.order {
+		elements = append(elements, s.elements[id])
+	}
+	return elements, s.seq
+}
+
+// deltaRequest is server-delta(roomId, { elements }).
+type deltaRequest struct {
+	roomID   string
+	elements []map[string]any
+}
+
+func decodeDelta(args []any) (deltaRequest, error) {
+	var request deltaRequest
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return deltaRequest{}, err
+	}
+	patch, err := objectArg(args, 1, "patch")
+	if err != nil {
+		return deltaRequest{}, err
+	}
+	elements, ok := patch["elements"].([]any)
+	if !ok {
+		return deltaRequest{}, &payloadError{"patch.elements", "must be an array"}
+	}
+	request.elements = make([]map[string]any, 0, len(elements))
+	for i, value := range elements {
+		field := fmt.Sprintf("patch.elements[%d]", i)
+		element, ok := value.(map[string]any)
+		if !ok {
+			return deltaRequest{}, &payloadError{field, fmt.Sprintf("must be an object, got %s", typeName(value))}
+		}
+		if _, err := stringField(element, "id", field+".id"); err != nil {
+			return deltaRequest{}, err
+		}
+		if _, ok := number(element["version"]); !ok {
+			return deltaRequest{}, &payloadError{field + ".version", "must be a number"}
+		}
+		if nonce, exists := element["versionNonce"]; exists && nonce != nil {
+			if _, ok := number(nonce); !ok {
+				return deltaRequest{}, &payloadError{field + ".versionNonce", "must be a number"}
+			}
+		}
+		request.elements = append(request.elements, element)
+	}
+	return request, nil
+}
+
+// handleDelta merges a client's element patches into the room's canonical
+// scene and schedules the consolidated diff.
+func handleDelta(socket *socketio.Socket, srv *socketio.Server, datas []any) {
+	ack, args := extractAck(datas)
+	request, err := decodeDelta(args)
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	roomID := request.roomID
+	wakeRoom(roomID, false)
+
+	switch {
+	case !socket.Rooms().Has(socketio.Room(roomID)):
+		err = apierror.Errorf(apierror.NotInRoom, "not in room %s", roomID)
+	case IsEncryptedRoom(roomID):
+		err = apierror.New(apierror.RoomEncrypted, "delta sync isn't available in encrypted rooms")
+	case isMuted(roomID, socket.Id()):
+		err = apierror.New(apierror.Muted, "muted in room")
+	}
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+
+	roomScenesMutex.Lock()
+	scene, exists := roomScenes[roomID]
+	if !exists {
+		scene = newRoomScene()
+		roomScenes[roomID] = scene
+	}
+	accepted, applyErr := scene.apply(request.elements)
+	if len(accepted) > 0 && !scene.flushScheduled {
+		scene.flushScheduled = true
+		time.AfterFunc(deltaFlushInterval, func() {
+			flushDelta(srv, roomID)
+		})
+	}
+	roomScenesMutex.Unlock()
+
+	if applyErr != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(applyErr), applyErr)
+		return
+	}
+	respondWithAck(socket, ack, "", map[string]any{
+		"status":   "ok",
+		"accepted": len(accepted),
+	}, nil)
+}
+
+// flushDelta sends a room's pending patches, and a checkpoint when one is due.
+func flushDelta(srv *socketio.Server, roomID string) {
+	roomScenesMutex.Lock()
+	scene, exists := roomScenes[roomID]
+	if !exists {
+		roomScenesMutex.Unlock()
+		return
+	}
+	diff, seq, checkpoint := scene.flush()
+	var elements []map[string]any
+	if checkpoint {
+		elements, _ = scene.checkpoint()
+	}
+	roomScenesMutex.Unlock()
+
+	if diff == nil {
+		return
+	}
+	room := socketio.Room(roomID)
+	payload := map[string]any{"roomId": roomID, "seq": seq, "elements": diff}
+	if err := srv.To(room).Emit("client-delta", payload); err != nil {
+		utils.Log().Printf("failed to send delta %d to room %v: %v\n", seq, roomID, err)
+		return
+	}
+	trackBroadcast(roomID, payloadSize(payload), time.Now())
+	if checkpoint {
+		_ = srv.To(room).Emit("client-checkpoint", map[string]any{"roomId": roomID, "seq": seq, "elements": elements})
+	}
+}
+
+// sendCheckpoint sends the room's full scene to one socket, if the room is
+// in delta sync mode. Diffs with a higher seq follow it.
+func sendCheckpoint(socket *socketio.Socket, roomID string) bool {
+	roomScenesMutex.Lock()
+	scene, exists := roomScenes[roomID]
+	var elements []map[string]any
+	var seq int64
+	if exists {
+		elements, seq = scene.checkpoint()
+	}
+	roomScenesMutex.Unlock()
+
+	if !exists {
+		return false
+	}
+	_ = socket.Emit("client-checkpoint", map[string]any{"roomId": roomID, "seq": seq, "elements": elements})
+	return true
+}
+
+// handleCheckpointRequest answers request-checkpoint(roomId) from a member
+// that missed a diff.
+func handleCheckpointRequest(socket *socketio.Socket, datas []any) {
+	ack, args := extractAck(datas)
+	roomID, err := stringArg(args, 0, "roomId")
+	if err == nil && !socket.Rooms().Has(socketio.Room(roomID)) {
+		err = apierror.Errorf(apierror.NotInRoom, "not in room %s", roomID)
+	}
+	if err == nil {
+		wakeRoom(roomID, false)
+	}
+	if err == nil && !sendCheckpoint(socket, roomID) {
+		err = apierror.Errorf(apierror.Conflict, "room %s has no delta sync state", roomID)
+	}
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	respondWithAck(socket, ack, "", map[string]any{"status": "ok"}, nil)
+}
+
+func clearRoomScene(roomID string) {
+	roomScenesMutex.Lock()
+	defer roomScenesMutex.Unlock()
+	delete(roomScenes, roomID)
+}
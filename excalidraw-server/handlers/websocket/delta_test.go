@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"testing"
+)
+
+func element(id string, version, nonce float64) map[string]any {
+	return map[string]any{"id": id, "version": version, "versionNonce": nonce}
+}
+
+func TestRoomSceneApply(t *testing.T) {
+	scene := newRoomScene()
+
+	accepted, err := scene.apply([]map[string]any{element("a", 1, 10), element("b", 1, 10)})
+	if err != nil || len(accepted) != 2 {
+		t.Fatalf("apply() = %v, %v", accepted, err)
+	}
+
+	tests := []struct {
+		name  string
+		patch map[string]any
+		want  bool
+	}{
+		{"older version", element("a", 0, 1), false},
+		{"same version, higher nonce", element("a", 1, 20), false},
+		{"same version, same nonce", element("a", 1, 10), false},
+		{"same version, lower nonce", element("a", 1, 5), true},
+		{"newer version", element("a", 2, 99), true},
+	}
+	for _, tt := range tests {
+		accepted, err := scene.apply([]map[string]any{tt.patch})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(accepted) == 1; got != tt.want {
+			t.Errorf("%s: accepted = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if version, _ := number(scene.elements["a"]["version"]); version != 2 {
+		t.Errorf("canonical version = %v, want 2", version)
+	}
+}
+
+func TestRoomSceneFlushConsolidates(t *testing.T) {
+	scene := newRoomScene()
+	if _, err := scene.apply([]map[string]any{element("a", 1, 1), element("b", 1, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scene.apply([]map[string]any{element("a", 2, 1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, seq, checkpoint := scene.flush()
+	if seq != 1 || checkpoint || len(diff) != 2 {
+		t.Fatalf("flush() = %v, %d, %v", diff, seq, checkpoint)
+	}
+	// One entry per element, with its latest version, in first-seen order
+	if diff[0]["id"] != "a" || diff[0]["version"] != float64(2) || diff[1]["id"] != "b" {
+		t.Errorf("diff = %v", diff)
+	}
+
+	if diff, seq, _ := scene.flush(); diff != nil || seq != 1 {
+		t.Errorf("flush() with nothing pending = %v, %d", diff, seq)
+	}
+}
+
+func TestRoomSceneCheckpoints(t *testing.T) {
+	scene := newRoomScene()
+	for i := 1; i <= checkpointEvery; i++ {
+		if _, err := scene.apply([]map[string]any{element("a", float64(i), 1)}); err != nil {
+			t.Fatal(err)
+		}
+		_, seq, checkpoint := scene.flush()
+		if checkpoint != (i == checkpointEvery) {
+			t.Fatalf("flush() #%d checkpoint = %v", seq, checkpoint)
+		}
+	}
+
+	if _, err := scene.apply([]map[string]any{element("b", 1, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	elements, seq := scene.checkpoint()
+	if seq != checkpointEvery || len(elements) != 2 || elements[0]["id"] != "a" || elements[1]["id"] != "b" {
+		t.Errorf("checkpoint() = %v, %d", elements, seq)
+	}
+}
+
+func TestDecodeDelta(t *testing.T) {
+	request, err := decodeDelta([]any{"room", map[string]any{"elements": []any{
+		map[string]any{"id": "a", "version": float64(3), "versionNonce": float64(7), "x": float64(1)},
+	}}})
+	if err != nil || request.roomID != "room" || len(request.elements) != 1 {
+		t.Fatalf("decodeDelta() = %+v, %v", request, err)
+	}
+
+	for field, args := range map[string][]any{
+		"patch.elements":                 {"room", map[string]any{}},
+		"patch.elements[0]":              {"room", map[string]any{"elements": []any{"a"}}},
+		"patch.elements[0].id":           {"room", map[string]any{"elements": []any{map[string]any{"version": float64(1)}}}},
+		"patch.elements[0].version":      {"room", map[string]any{"elements": []any{map[string]any{"id": "a"}}}},
+		"patch.elements[0].versionNonce": {"room", map[string]any{"elements": []any{map[string]any{"id": "a", "version": float64(1), "versionNonce": "x"}}}},
+	} {
+		_, err := decodeDelta(args)
+		assertPayloadError(t, err, field)
+	}
+}
@@ -0,0 +1,170 @@
+package websocket
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// Encoding is how a socket sends and receives scene broadcast payloads.
+type Encoding string
+
+const (
+	// EncodingJSON payloads are plain socket.io arguments.
+	EncodingJSON Encoding = "json"
+	// EncodingMsgpack payloads are a single MessagePack-encoded binary
+	// argument, which is smaller and cheaper to parse for large scenes.
+	EncodingMsgpack Encoding = "msgpack"
+)
+
+var (
+	// msgpackSockets holds the members of each room that asked for
+	// MessagePack payloads in join-room.
+	msgpackSockets      = make(map[string]map[socketio.SocketId]struct{})
+	msgpackSocketsMutex sync.RWMutex
+)
+
+func setSocketEncoding(roomID string, socketID socketio.SocketId, encoding Encoding) {
+	msgpackSocketsMutex.Lock()
+	defer msgpackSocketsMutex.Unlock()
+
+	if encoding != EncodingMsgpack {
+		removeMsgpackSocket(roomID, socketID)
+		return
+	}
+	members, exists := msgpackSockets[roomID]
+	if !exists {
+		members = make(map[socketio.SocketId]struct{})
+		msgpackSockets[roomID] = members
+	}
+	members[socketID] = struct{}{}
+}
+
+// clearSocketEncoding forgets the encoding of a socket leaving a room.
+func clearSocketEncoding(roomID string, socketID socketio.SocketId) {
+	msgpackSocketsMutex.Lock()
+	defer msgpackSocketsMutex.Unlock()
+	removeMsgpackSocket(roomID, socketID)
+}
+
+func removeMsgpackSocket(roomID string, socketID socketio.SocketId) {
+	delete(msgpackSockets[roomID], socketID)
+	if len(msgpackSockets[roomID]) == 0 {
+		delete(msgpackSockets, roomID)
+	}
+}
+
+func clearRoomEncodings(roomID string) {
+	msgpackSocketsMutex.Lock()
+	defer msgpackSocketsMutex.Unlock()
+	delete(msgpackSockets, roomID)
+}
+
+func socketEncoding(roomID string, socketID socketio.SocketId) Encoding {
+	msgpackSocketsMutex.RLock()
+	defer msgpackSocketsMutex.RUnlock()
+
+	if _, ok := msgpackSockets[roomID][socketID]; ok {
+		return EncodingMsgpack
+	}
+	return EncodingJSON
+}
+
+// msgpackRooms returns the personal rooms of the room's MessagePack
+// members, for addressing or excluding them in a broadcast.
+func msgpackRooms(roomID string) []socketio.Room {
+	msgpackSocketsMutex.RLock()
+	defer msgpackSocketsMutex.RUnlock()
+
+	rooms := make([]socketio.Room, 0, len(msgpackSockets[roomID]))
+	for socketID := range msgpackSockets[roomID] {
+		rooms = append(rooms, socketio.Room(socketID))
+	}
+	return rooms
+}
+
+// usesMsgpack reports whether a member of a room asked for MessagePack
+// payloads.
+func usesMsgpack(roomID string, socketID socketio.SocketId) bool {
+	msgpackSocketsMutex.RLock()
+	defer msgpackSocketsMutex.RUnlock()
+	_, exists := msgpackSockets[roomID][socketID]
+	return exists
+}
+
+// packPayload encodes a decoded JSON payload as MessagePack. Whole numbers
+// are written as integers, which is how JSON clients send coordinates.
+func packPayload(payload any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.UseCompactInts(true)
+	encoder.UseCompactFloats(true)
+	if err := encoder.Encode(payload); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unpackPayload decodes a MessagePack payload into the values a JSON payload
+// decodes to: maps with string keys, slices, strings, numbers and booleans.
+func unpackPayload(data []byte) (any, error) {
+	reader := bytes.NewReader(data)
+	decoder := msgpack.NewDecoder(reader)
+	decoder.UseLooseInterfaceDecoding(true)
+	payload, err := decoder.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	if reader.Len() > 0 {
+		return nil, fmt.Errorf("%d bytes after the payload", reader.Len())
+	}
+	return payload, nil
+}
+
+// decodeScenePayload returns the broadcast payload of a socket in its
+// decoded form, unpacking it if the socket sends MessagePack, and the
+// packed bytes, if any. Encrypted rooms relay payloads without looking at
+// them.
+func decodeScenePayload(roomID string, socketID socketio.SocketId, payload any) (scene any, packed []byte, err error) {
+	if IsEncryptedRoom(roomID) || socketEncoding(roomID, socketID) != EncodingMsgpack {
+		return payload, nil, nil
+	}
+	switch value := payload.(type) {
+	case []byte:
+		packed = value
+	case binaryArg:
+		packed = value.Bytes()
+	default:
+		return nil, nil, &payloadError{"payload", fmt.Sprintf("must be MessagePack binary, got %s", typeName(payload))}
+	}
+	if scene, err = unpackPayload(packed); err != nil {
+		return nil, nil, &payloadError{"payload", fmt.Sprintf("invalid MessagePack: %v", err)}
+	}
+	return scene, packed, nil
+}
+
+// relayBroadcast sends a scene update to the rest of the room as
+// client-broadcast(payload, metadata, seq), in each member's encoding. packed
+// is the MessagePack form of scene when the sender already had it. operator
+// picks who is sent to before the room is applied.
+func relayBroadcast(operator func() *socketio.BroadcastOperator, roomID string, scene any, packed []byte, metadata any, seq int64) error {
+	room := socketio.Room(roomID)
+	msgpackMembers := msgpackRooms(roomID)
+	if IsEncryptedRoom(roomID) || len(msgpackMembers) == 0 {
+		return operator().To(room).Emit("client-broadcast", scene, metadata, seq)
+	}
+
+	if err := operator().To(room).Except(msgpackMembers...).Emit("client-broadcast", scene, metadata, seq); err != nil {
+		return err
+	}
+	if packed == nil {
+		var err error
+		if packed, err = packPayload(scene); err != nil {
+			return err
+		}
+	}
+	return operator().To(msgpackMembers...).Emit("client-broadcast", packed, metadata, seq)
+}
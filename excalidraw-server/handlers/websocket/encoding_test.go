@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func resetEncodings() {
+	msgpackSocketsMutex.Lock()
+	msgpackSockets = make(map[string]map[socketio.SocketId]struct{})
+	msgpackSocketsMutex.Unlock()
+}
+
+func TestPackPayloadRoundTrip(t *testing.T) {
+	scene := map[string]any{
+		"type": "SCENE_UPDATE",
+		"payload": map[string]any{
+			"elements": []any{
+				map[string]any{"id": "rect", "x": float64(10), "y": 20.5, "isDeleted": false, "groupIds": []any{}},
+			},
+		},
+	}
+
+	packed, err := packPayload(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpacked, err := unpackPayload(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Whole numbers come back as integers; compare through the JSON view
+	elements := unpacked.(map[string]any)["payload"].(map[string]any)["elements"].([]any)
+	element := elements[0].(map[string]any)
+	if x, ok := number(element["x"]); !ok || x != 10 {
+		t.Errorf("x = %#v", element["x"])
+	}
+	if element["y"] != 20.5 || element["id"] != "rect" || element["isDeleted"] != false {
+		t.Errorf("element = %#v", element)
+	}
+	if !reflect.DeepEqual(element["groupIds"], []any{}) {
+		t.Errorf("groupIds = %#v", element["groupIds"])
+	}
+
+	if _, err := unpackPayload(append(packed, 0xc0)); err == nil {
+		t.Error("unpackPayload() accepted trailing data")
+	}
+}
+
+func TestPackPayloadIsSmaller(t *testing.T) {
+	elements := make([]any, 200)
+	for i := range elements {
+		elements[i] = map[string]any{"id": "element", "x": float64(i), "y": float64(i * 2), "width": float64(100), "height": float64(50)}
+	}
+	scene := map[string]any{"elements": elements}
+	packed, err := packPayload(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := payloadSize(scene); len(packed) >= size {
+		t.Errorf("MessagePack payload is %d bytes, JSON %d", len(packed), size)
+	}
+}
+
+func TestDecodeScenePayload(t *testing.T) {
+	resetEncodings()
+	resetRoomModes()
+	defer resetEncodings()
+
+	scene := map[string]any{"type": "SCENE_UPDATE"}
+	packed, err := packPayload(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// JSON sockets' payloads pass through
+	if got, gotPacked, err := decodeScenePayload("room", "json-socket", scene); err != nil || gotPacked != nil || !reflect.DeepEqual(got, scene) {
+		t.Errorf("decodeScenePayload(json) = %v, %v, %v", got, gotPacked, err)
+	}
+
+	setSocketEncoding("room", "msgpack-socket", EncodingMsgpack)
+	got, gotPacked, err := decodeScenePayload("room", "msgpack-socket", bytes.NewBuffer(packed))
+	if err != nil || !bytes.Equal(gotPacked, packed) || !reflect.DeepEqual(got, scene) {
+		t.Errorf("decodeScenePayload(msgpack) = %v, %v, %v", got, gotPacked, err)
+	}
+
+	var invalid *payloadError
+	if _, _, err := decodeScenePayload("room", "msgpack-socket", scene); !errors.As(err, &invalid) {
+		t.Errorf("decodeScenePayload() of an object from a MessagePack socket error = %v", err)
+	}
+	if _, _, err := decodeScenePayload("room", "msgpack-socket", []byte{0xc1}); !errors.As(err, &invalid) {
+		t.Errorf("decodeScenePayload() of invalid MessagePack error = %v", err)
+	}
+
+	// Encrypted rooms relay ciphertext untouched
+	initRoomMode("secret", RoomModeEncrypted, true)
+	defer clearRoomMode("secret")
+	setSocketEncoding("secret", "msgpack-socket", EncodingMsgpack)
+	if got, gotPacked, err := decodeScenePayload("secret", "msgpack-socket", []byte("ciphertext")); err != nil || gotPacked != nil || !bytes.Equal(got.([]byte), []byte("ciphertext")) {
+		t.Errorf("decodeScenePayload(encrypted) = %v, %v, %v", got, gotPacked, err)
+	}
+}
+
+func TestSocketEncodings(t *testing.T) {
+	resetEncodings()
+	defer resetEncodings()
+
+	setSocketEncoding("room", "a", EncodingMsgpack)
+	setSocketEncoding("room", "b", EncodingJSON)
+	setSocketEncoding("other", "a", EncodingJSON)
+
+	if socketEncoding("room", "a") != EncodingMsgpack || socketEncoding("room", "b") != EncodingJSON || socketEncoding("other", "a") != EncodingJSON {
+		t.Error("encodings aren't tracked per socket and room")
+	}
+	if rooms := msgpackRooms("room"); len(rooms) != 1 || rooms[0] != "a" {
+		t.Errorf("msgpackRooms() = %v", rooms)
+	}
+
+	clearSocketEncoding("room", "a")
+	if socketEncoding("room", "a") != EncodingJSON || len(msgpackSockets) != 0 {
+		t.Errorf("clearSocketEncoding() left %v", msgpackSockets)
+	}
+}
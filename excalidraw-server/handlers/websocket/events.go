@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"sync"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// roomEventBuffer is how many events a subscriber may fall behind by.
+const roomEventBuffer = 64
+
+// RoomEvent is a presence or chat event of a room, named and shaped like
+// the socket event its members get.
+type RoomEvent struct {
+	Name string
+	Data any
+}
+
+type roomSubscriber struct {
+	events chan RoomEvent
+}
+
+var (
+	// roomSubscribers follow rooms from outside Socket.IO, e.g. over
+	// server-sent events
+	roomSubscribers      = make(map[string]map[*roomSubscriber]struct{})
+	roomSubscribersMutex sync.Mutex
+)
+
+// SubscribeRoom returns a channel of the presence and chat events of a
+// room on this instance, and a function that ends the subscription.
+// Subscribers that fall too far behind have their channel closed, so they
+// can subscribe again and start over from the room's current state.
+func SubscribeRoom(roomID string) (<-chan RoomEvent, func()) {
+	sub := &roomSubscriber{events: make(chan RoomEvent, roomEventBuffer)}
+
+	roomSubscribersMutex.Lock()
+	if roomSubscribers[roomID] == nil {
+		roomSubscribers[roomID] = make(map[*roomSubscriber]struct{})
+	}
+	roomSubscribers[roomID][sub] = struct{}{}
+	roomSubscribersMutex.Unlock()
+
+	return sub.events, func() {
+		roomSubscribersMutex.Lock()
+		defer roomSubscribersMutex.Unlock()
+		unsubscribeRoom(roomID, sub)
+	}
+}
+
+// unsubscribeRoom removes sub and closes its channel, unless that already
+// happened. Callers hold roomSubscribersMutex.
+func unsubscribeRoom(roomID string, sub *roomSubscriber) {
+	if _, ok := roomSubscribers[roomID][sub]; !ok {
+		return
+	}
+	delete(roomSubscribers[roomID], sub)
+	if len(roomSubscribers[roomID]) == 0 {
+		delete(roomSubscribers, roomID)
+	}
+	close(sub.events)
+}
+
+// publishRoomEvent hands an event to the room's subscribers without
+// waiting for them.
+func publishRoomEvent(roomID, name string, data any) {
+	roomSubscribersMutex.Lock()
+	defer roomSubscribersMutex.Unlock()
+
+	for sub := range roomSubscribers[roomID] {
+		select {
+		case sub.events <- RoomEvent{Name: name, Data: data}:
+		default:
+			unsubscribeRoom(roomID, sub)
+		}
+	}
+}
+
+// emitPresence sends the room's members, its sockets followed by its
+// native WebSocket peers, to its sockets and subscribers. Rooms without
+// sockets only tell subscribers.
+func emitPresence(srv *socketio.Server, roomID string, sockets []*socketio.RemoteSocket) {
+	presence := append(buildPresence(sockets), rawPresence(roomID)...)
+	if len(sockets) > 0 {
+		srv.In(socketio.Room(roomID)).Emit("room-presence", presence)
+	}
+	publishRoomEvent(roomID, "room-presence", presence)
+}
+
+// GetRoomPresence lists the members of a live room on this instance in the
+// order they joined, like the room-presence event.
+func GetRoomPresence(roomID string) []PresenceEntry {
+	stats, ok := GetRoomStats(roomID)
+	if !ok {
+		return []PresenceEntry{}
+	}
+	presence := make([]PresenceEntry, 0, len(stats.Users))
+	for _, member := range stats.Users {
+		presence = append(presence, PresenceEntry{SocketID: member.SocketID, User: member.User})
+	}
+	return presence
+}
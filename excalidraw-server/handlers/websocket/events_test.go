@@ -0,0 +1,43 @@
+package websocket
+
+import "testing"
+
+func TestSubscribeRoom(t *testing.T) {
+	events, unsubscribe := SubscribeRoom("events-room")
+	publishRoomEvent("events-room", "client-chat-message", "hello")
+	publishRoomEvent("other-room", "client-chat-message", "elsewhere")
+
+	if event := <-events; event.Name != "client-chat-message" || event.Data != "hello" {
+		t.Errorf("event = %+v, want the room's chat message", event)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("got %+v from another room", event)
+	default:
+	}
+
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Error("channel still open after unsubscribing")
+	}
+	unsubscribe()
+	if len(roomSubscribers["events-room"]) != 0 {
+		t.Error("subscriber kept after unsubscribing")
+	}
+}
+
+func TestSubscribeRoomOverflow(t *testing.T) {
+	events, unsubscribe := SubscribeRoom("busy-room")
+	defer unsubscribe()
+
+	for i := 0; i <= roomEventBuffer; i++ {
+		publishRoomEvent("busy-room", "room-presence", i)
+	}
+	received := 0
+	for range events {
+		received++
+	}
+	if received != roomEventBuffer {
+		t.Errorf("received %d events before the channel closed, want %d", received, roomEventBuffer)
+	}
+}
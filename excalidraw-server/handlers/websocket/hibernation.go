@@ -0,0 +1,303 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// roomStateTimeout bounds saving or loading the state of one room.
+const roomStateTimeout = 5 * time.Second
+
+// HibernationStats counts rooms whose state was saved to the store and freed,
+// and rooms brought back from it, since startup.
+type HibernationStats struct {
+	Hibernated int64 `json:"hibernated"`
+	Woken      int64 `json:"woken"`
+	// Sleeping is how many rooms with sockets are hibernated right now.
+	Sleeping int `json:"sleeping"`
+}
+
+// roomState is what a hibernated room saves. It is MessagePack-encoded so
+// binary broadcast metadata and number types survive.
+type roomState struct {
+	Chat          []ChatMessage    `msgpack:"chat,omitempty"`
+	Scene         any              `msgpack:"scene,omitempty"`
+	SceneMetadata any              `msgpack:"scene_metadata,omitempty"`
+	Elements      []map[string]any `msgpack:"elements,omitempty"`
+	Seq           int64            `msgpack:"seq,omitempty"`
+}
+
+var (
+	roomStateStore      core.RoomStateStore
+	roomStateStoreMutex sync.RWMutex
+
+	// hibernatedRooms are rooms with sockets whose state was freed; it is
+	// loaded again on their next activity
+	hibernatedRooms      = make(map[string]struct{})
+	hibernatedRoomsMutex sync.Mutex
+
+	hibernatedCount atomic.Int64
+	wokenCount      atomic.Int64
+)
+
+// HibernateAfterFromEnv reads ROOM_HIBERNATE_AFTER, how long a room may go
+// without a broadcast before its state is moved to the store. Zero, the
+// default, disables hibernation.
+func HibernateAfterFromEnv() (time.Duration, error) {
+	value := os.Getenv("ROOM_HIBERNATE_AFTER")
+	if value == "" {
+		return 0, nil
+	}
+	idleAfter, err := time.ParseDuration(value)
+	if err != nil || idleAfter < 0 {
+		return 0, fmt.Errorf("invalid ROOM_HIBERNATE_AFTER %q: must be a non-negative duration", value)
+	}
+	return idleAfter, nil
+}
+
+// SetRoomStateStore sets where hibernated rooms keep their state. With a
+// store, rooms that empty out are hibernated rather than discarded, so their
+// chat and scene are there when someone joins again; nil disables
+// hibernation.
+func SetRoomStateStore(store core.RoomStateStore) {
+	roomStateStoreMutex.Lock()
+	defer roomStateStoreMutex.Unlock()
+	roomStateStore = store
+}
+
+func getRoomStateStore() core.RoomStateStore {
+	roomStateStoreMutex.RLock()
+	defer roomStateStoreMutex.RUnlock()
+	return roomStateStore
+}
+
+// GetHibernationStats reports hibernation activity since startup.
+func GetHibernationStats() HibernationStats {
+	hibernatedRoomsMutex.Lock()
+	sleeping := len(hibernatedRooms)
+	hibernatedRoomsMutex.Unlock()
+
+	return HibernationStats{
+		Hibernated: hibernatedCount.Load(),
+		Woken:      wokenCount.Load(),
+		Sleeping:   sleeping,
+	}
+}
+
+// StartHibernation hibernates rooms without a broadcast for idleAfter until
+// stop is closed.
+func StartHibernation(idleAfter time.Duration, stop <-chan struct{}) {
+	interval := idleAfter / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if hibernated := hibernateIdleRooms(now.Add(-idleAfter)); hibernated > 0 {
+				logrus.WithField("rooms", hibernated).Info("Hibernated idle rooms")
+			}
+		}
+	}
+}
+
+// hibernateIdleRooms saves and frees the state of live rooms idle since
+// before and returns how many it hibernated.
+func hibernateIdleRooms(before time.Time) int {
+	store := getRoomStateStore()
+	if store == nil {
+		return 0
+	}
+
+	hibernated := 0
+	for _, roomID := range idleRooms(before) {
+		hibernatedRoomsMutex.Lock()
+		_, sleeping := hibernatedRooms[roomID]
+		hibernatedRoomsMutex.Unlock()
+		if sleeping {
+			continue
+		}
+
+		saved, err := saveRoomState(store, roomID)
+		if err != nil {
+			logrus.WithField("room_id", roomID).WithError(err).Error("Failed to hibernate room")
+			continue
+		}
+		if !saved {
+			continue
+		}
+		freeRoomState(roomID)
+		hibernatedRoomsMutex.Lock()
+		hibernatedRooms[roomID] = struct{}{}
+		hibernatedRoomsMutex.Unlock()
+		hibernated++
+	}
+	return hibernated
+}
+
+// hibernateEmptyRoom takes the state of a room whose last socket left, so a
+// later join can wake it, and returns the function that saves it to the
+// store. The caller frees the state, and saves it once it no longer holds
+// roomsMutex.
+func hibernateEmptyRoom(roomID string) (save func()) {
+	hibernatedRoomsMutex.Lock()
+	delete(hibernatedRooms, roomID)
+	hibernatedRoomsMutex.Unlock()
+
+	store := getRoomStateStore()
+	if store == nil {
+		return func() {}
+	}
+	state, ok := takeRoomState(roomID)
+	if !ok {
+		return func() {}
+	}
+	return func() {
+		if err := putRoomState(store, roomID, state); err != nil {
+			logrus.WithField("room_id", roomID).WithError(err).Error("Failed to hibernate room")
+		}
+	}
+}
+
+// saveRoomState writes a room's chat and scene to the store. It reports
+// false for rooms without state, which save nothing.
+func saveRoomState(store core.RoomStateStore, roomID string) (bool, error) {
+	state, ok := takeRoomState(roomID)
+	if !ok {
+		return false, nil
+	}
+	if err := putRoomState(store, roomID, state); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// takeRoomState copies a room's chat and scene. Encrypted rooms keep their
+// promise not to store scenes, so they only take chat. It reports false for
+// rooms without state.
+func takeRoomState(roomID string) (roomState, bool) {
+	state := roomState{Chat: getChatHistory(roomID)}
+	if !IsEncryptedRoom(roomID) {
+		sceneInitsMutex.RLock()
+		if scene, exists := sceneInits[roomID]; exists {
+			state.Scene, state.SceneMetadata = scene.payload, scene.metadata
+		}
+		sceneInitsMutex.RUnlock()
+
+		roomScenesMutex.Lock()
+		if scene, exists := roomScenes[roomID]; exists {
+			state.Elements, state.Seq = scene.checkpoint()
+		}
+		roomScenesMutex.Unlock()
+	}
+	return state, len(state.Chat) > 0 || state.Scene != nil || len(state.Elements) > 0
+}
+
+func putRoomState(store core.RoomStateStore, roomID string, state roomState) error {
+	data, err := msgpack.Marshal(&state)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), roomStateTimeout)
+	defer cancel()
+	if err := store.PutRoomState(ctx, roomID, data); err != nil {
+		return err
+	}
+	hibernatedCount.Add(1)
+	return nil
+}
+
+// freeRoomState drops the state a hibernated room saved.
+func freeRoomState(roomID string) {
+	clearChatHistory(roomID)
+	clearSceneInit(roomID)
+	clearRoomScene(roomID)
+}
+
+// wakeRoom loads a room's saved state back when it was hibernated, or when
+// it was empty and first is set. Restoring leaves state that was created in
+// the meantime alone.
+func wakeRoom(roomID string, first bool) {
+	store := getRoomStateStore()
+	if store == nil {
+		return
+	}
+	hibernatedRoomsMutex.Lock()
+	_, sleeping := hibernatedRooms[roomID]
+	delete(hibernatedRooms, roomID)
+	hibernatedRoomsMutex.Unlock()
+	if !sleeping && !first {
+		return
+	}
+
+	log := logrus.WithField("room_id", roomID)
+	ctx, cancel := context.WithTimeout(context.Background(), roomStateTimeout)
+	defer cancel()
+	data, err := store.GetRoomState(ctx, roomID)
+	if errors.Is(err, core.ErrRoomStateNotFound) {
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to wake room")
+		return
+	}
+
+	var state roomState
+	if err := msgpack.Unmarshal(data, &state); err != nil {
+		log.WithError(err).Error("Failed to decode hibernated room state")
+	} else {
+		restoreRoomState(roomID, state)
+	}
+	if err := store.DeleteRoomState(ctx, roomID); err != nil {
+		log.WithError(err).Warn("Failed to delete hibernated room state")
+	}
+	wokenCount.Add(1)
+}
+
+func restoreRoomState(roomID string, state roomState) {
+	if len(state.Chat) > 0 {
+		chatHistoryMutex.Lock()
+		if len(chatHistory[roomID]) == 0 {
+			setChatHistory(roomID, state.Chat)
+			evictChat(roomID)
+		}
+		chatHistoryMutex.Unlock()
+	}
+
+	if state.Scene != nil {
+		sceneInitsMutex.Lock()
+		if _, exists := sceneInits[roomID]; !exists {
+			sceneInits[roomID] = sceneInit{payload: state.Scene, metadata: state.SceneMetadata}
+		}
+		sceneInitsMutex.Unlock()
+	}
+
+	if len(state.Elements) > 0 {
+		roomScenesMutex.Lock()
+		if _, exists := roomScenes[roomID]; !exists {
+			scene := newRoomScene()
+			for _, element := range state.Elements {
+				id, _ := element["id"].(string)
+				scene.elements[id] = element
+				scene.order = append(scene.order, id)
+			}
+			scene.seq = state.Seq
+			roomScenes[roomID] = scene
+		}
+		roomScenesMutex.Unlock()
+	}
+}
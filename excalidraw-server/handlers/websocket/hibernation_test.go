@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"testing"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func useRoomStateStore(t *testing.T) core.RoomStateStore {
+	t.Helper()
+	store := memory.NewDocumentStore().(core.RoomStateStore)
+	SetRoomStateStore(store)
+	t.Cleanup(func() {
+		SetRoomStateStore(nil)
+		hibernatedRoomsMutex.Lock()
+		hibernatedRooms = make(map[string]struct{})
+		hibernatedRoomsMutex.Unlock()
+	})
+	return store
+}
+
+func seedRoom(roomID string) {
+	addChatMessage(roomID, ChatMessage{ID: "m1", RoomID: roomID, Sender: "alice", Content: "hello", Timestamp: 1})
+	keepSceneInit(roomID, map[string]any{"type": "SCENE_INIT"}, nil, map[string]any{"full": true, "iv": []byte{1, 2, 3}})
+	roomScenesMutex.Lock()
+	scene := newRoomScene()
+	_, _ = scene.apply([]map[string]any{element("rect", 3, 7)})
+	scene.flush()
+	roomScenes[roomID] = scene
+	roomScenesMutex.Unlock()
+}
+
+func TestHibernateIdleRooms(t *testing.T) {
+	store := useRoomStateStore(t)
+	defer releaseRoom("sleepy")
+	defer releaseRoom("busy")
+
+	start := time.Now()
+	trackJoin("sleepy", socketio.SocketId("a"), nil, start)
+	trackJoin("busy", socketio.SocketId("b"), nil, start)
+	trackBroadcast("busy", 10, start.Add(time.Minute))
+	seedRoom("sleepy")
+	seedRoom("busy")
+
+	before := GetHibernationStats()
+	if hibernated := hibernateIdleRooms(start.Add(30 * time.Second)); hibernated != 1 {
+		t.Fatalf("hibernateIdleRooms() = %d, want 1", hibernated)
+	}
+	if len(getChatHistory("sleepy")) != 0 || len(getChatHistory("busy")) != 1 {
+		t.Error("hibernation freed the wrong room's chat")
+	}
+	if _, err := store.GetRoomState(context.Background(), "sleepy"); err != nil {
+		t.Errorf("GetRoomState() of the hibernated room failed: %v", err)
+	}
+	// A sleeping room isn't hibernated twice
+	if hibernated := hibernateIdleRooms(start.Add(30 * time.Second)); hibernated != 0 {
+		t.Errorf("second hibernateIdleRooms() = %d, want 0", hibernated)
+	}
+
+	wakeRoom("sleepy", false)
+	chat := getChatHistory("sleepy")
+	if len(chat) != 1 || chat[0].Content != "hello" {
+		t.Errorf("woken chat = %+v", chat)
+	}
+	sceneInitsMutex.RLock()
+	scene := sceneInits["sleepy"]
+	sceneInitsMutex.RUnlock()
+	metadata, _ := scene.metadata.(map[string]any)
+	if iv, _ := metadata["iv"].([]byte); !bytes.Equal(iv, []byte{1, 2, 3}) {
+		t.Errorf("woken scene metadata = %#v, want the binary iv back", scene.metadata)
+	}
+	roomScenesMutex.Lock()
+	elements, seq := roomScenes["sleepy"].checkpoint()
+	roomScenesMutex.Unlock()
+	if version, _ := number(elements[0]["version"]); len(elements) != 1 || version != 3 || seq != 1 {
+		t.Errorf("woken delta state = %v at seq %d", elements, seq)
+	}
+	if _, err := store.GetRoomState(context.Background(), "sleepy"); !errors.Is(err, core.ErrRoomStateNotFound) {
+		t.Errorf("GetRoomState() after waking error = %v, want core.ErrRoomStateNotFound", err)
+	}
+
+	stats := GetHibernationStats()
+	if stats.Hibernated-before.Hibernated != 1 || stats.Woken-before.Woken != 1 || stats.Sleeping != 0 {
+		t.Errorf("stats = %+v, started from %+v", stats, before)
+	}
+}
+
+func TestHibernateEmptyRoom(t *testing.T) {
+	store := useRoomStateStore(t)
+	defer releaseRoom("left")
+
+	seedRoom("left")
+	persist := releaseRoom("left")
+	if len(getChatHistory("left")) != 0 {
+		t.Fatal("releaseRoom() kept the chat")
+	}
+	// The store is written after roomsMutex is released
+	if _, err := store.GetRoomState(context.Background(), "left"); !errors.Is(err, core.ErrRoomStateNotFound) {
+		t.Fatalf("releaseRoom() wrote the store: %v", err)
+	}
+	persist()
+
+	// Only the first joiner of an empty room looks in the store
+	wakeRoom("left", false)
+	if len(getChatHistory("left")) != 0 {
+		t.Error("wakeRoom() of a room that wasn't hibernated loaded state")
+	}
+	wakeRoom("left", true)
+	if len(getChatHistory("left")) != 1 {
+		t.Error("first join didn't wake the room")
+	}
+}
+
+func TestHibernateEncryptedRoom(t *testing.T) {
+	store := useRoomStateStore(t)
+	initRoomMode("secret", RoomModeEncrypted, true)
+	defer releaseRoom("secret")
+
+	addChatMessage("secret", ChatMessage{ID: "m1", RoomID: "secret", Content: "hi"})
+	keepSceneInit("secret", []byte("ciphertext"), nil, map[string]any{"full": true})
+	if _, err := saveRoomState(store, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	freeRoomState("secret")
+	wakeRoom("secret", true)
+
+	if len(getChatHistory("secret")) != 1 {
+		t.Error("encrypted room lost its chat")
+	}
+	sceneInitsMutex.RLock()
+	_, kept := sceneInits["secret"]
+	sceneInitsMutex.RUnlock()
+	if kept {
+		t.Error("encrypted room's scene went through the store")
+	}
+}
+
+func TestHibernateAfterFromEnv(t *testing.T) {
+	t.Setenv("ROOM_HIBERNATE_AFTER", "")
+	if idleAfter, err := HibernateAfterFromEnv(); err != nil || idleAfter != 0 {
+		t.Errorf("HibernateAfterFromEnv() unset = %v, %v", idleAfter, err)
+	}
+	t.Setenv("ROOM_HIBERNATE_AFTER", "15m")
+	if idleAfter, err := HibernateAfterFromEnv(); err != nil || idleAfter != 15*time.Minute {
+		t.Errorf("HibernateAfterFromEnv() = %v, %v", idleAfter, err)
+	}
+	for _, value := range []string{"soon", "-1m"} {
+		t.Setenv("ROOM_HIBERNATE_AFTER", value)
+		if _, err := HibernateAfterFromEnv(); err == nil {
+			t.Errorf("HibernateAfterFromEnv() accepted %q", value)
+		}
+	}
+}
+
+func TestPurgeRoomChat(t *testing.T) {
+	store := useRoomStateStore(t)
+	seedRoom("purged")
+	if _, err := saveRoomState(store, "purged"); err != nil {
+		t.Fatal(err)
+	}
+	freeRoomState("purged")
+
+	replaced := 0
+	replace := func(ctx context.Context, roomID string, state []byte) error {
+		replaced++
+		return store.PutRoomState(ctx, roomID, state)
+	}
+	purged, err := PurgeRoomChat(context.Background(), "purged", replace)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeRoomChat() = %d, %v, want 1", purged, err)
+	}
+	state, err := loadRoomState(context.Background(), "purged")
+	if err != nil || state == nil {
+		t.Fatalf("loadRoomState() = %v, %v", state, err)
+	}
+	if len(state.Chat) != 0 || state.Scene == nil {
+		t.Errorf("state after purging = %+v, want the scene without chat", state)
+	}
+
+	if purged, err := PurgeRoomChat(context.Background(), "purged", replace); err != nil || purged != 0 || replaced != 1 {
+		t.Errorf("second PurgeRoomChat() = %d, %v with %d saves", purged, err, replaced)
+	}
+}
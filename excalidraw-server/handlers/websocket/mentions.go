@@ -0,0 +1,190 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/auth"
+	"excalidraw-server/core"
+	"excalidraw-server/notify"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const (
+	// maxMentionsPerMessage bounds the users one chat message notifies
+	maxMentionsPerMessage = 10
+	// mentionTimeout bounds looking up, storing and delivering the
+	// notifications of one message
+	mentionTimeout = 30 * time.Second
+)
+
+// mentionPattern matches @login where the @ starts a word, so e-mail
+// addresses in a message don't mention anyone.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9][A-Za-z0-9_.-]{0,38})`)
+
+// mentionDelivery is where mentions are looked up and delivered to.
+type mentionDelivery struct {
+	users         core.UserStore
+	notifications core.NotificationStore
+	notifier      notify.Notifier
+}
+
+// notificationPusher sends a notification live to the user's sockets.
+type notificationPusher func(userID string, notification *core.Notification)
+
+var (
+	mentions      *mentionDelivery
+	mentionsMutex sync.RWMutex
+)
+
+// SetMentionDelivery records the users of authenticated sockets in users and
+// turns @login in chat messages into notifications stored in notifications,
+// pushed to the mentioned user's sockets and, when notifier isn't nil,
+// delivered through it. Either store being nil turns mentions off.
+func SetMentionDelivery(users core.UserStore, notifications core.NotificationStore, notifier notify.Notifier) {
+	mentionsMutex.Lock()
+	defer mentionsMutex.Unlock()
+	if users == nil || notifications == nil {
+		mentions = nil
+		return
+	}
+	mentions = &mentionDelivery{users: users, notifications: notifications, notifier: notifier}
+}
+
+func getMentionDelivery() *mentionDelivery {
+	mentionsMutex.RLock()
+	defer mentionsMutex.RUnlock()
+	return mentions
+}
+
+// recordUser saves who an authenticated socket belongs to, so they can be
+// mentioned by login.
+func recordUser(claims *auth.Claims) {
+	delivery := getMentionDelivery()
+	if delivery == nil || claims.Subject == "" {
+		return
+	}
+	err := delivery.users.PutUser(context.Background(), core.User{
+		ID:        claims.Subject,
+		Login:     claims.Login,
+		Name:      claims.Name,
+		Email:     claims.Email,
+		AvatarURL: claims.AvatarURL,
+		SeenAt:    time.Now().UnixMilli(),
+	})
+	if err != nil {
+		logrus.WithField("user_id", claims.Subject).WithError(err).Warn("Failed to record user")
+	}
+}
+
+// extractMentions returns the distinct logins @mentioned in content, in
+// order and lower-cased, up to maxMentionsPerMessage.
+func extractMentions(content string) []string {
+	var logins []string
+	seen := make(map[string]struct{})
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// a sentence ending right after a mention doesn't end the login
+		login := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if _, dup := seen[login]; dup || login == "" {
+			continue
+		}
+		seen[login] = struct{}{}
+		logins = append(logins, login)
+		if len(logins) == maxMentionsPerMessage {
+			break
+		}
+	}
+	return logins
+}
+
+// notifyMentions notifies the users a chat message mentions, skipping its
+// sender and users who may not join the room.
+func notifyMentions(ctx context.Context, delivery *mentionDelivery, message ChatMessage, push notificationPusher) {
+	logins := extractMentions(message.Content)
+	if len(logins) == 0 {
+		return
+	}
+
+	from := message.Sender
+	if message.User != nil && message.User.Name != "" {
+		from = message.User.Name
+	}
+	log := logrus.WithFields(logrus.Fields{"room_id": message.RoomID, "message_id": message.ID})
+
+	for _, login := range logins {
+		user, err := delivery.users.FindUserByLogin(ctx, login)
+		if errors.Is(err, core.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			log.WithError(err).Warn("Failed to look up mentioned user")
+			continue
+		}
+		if message.User != nil && message.User.ID == user.ID {
+			continue
+		}
+		allowed, err := canJoin(ctx, message.RoomID, &UserInfo{ID: user.ID})
+		if err != nil {
+			log.WithError(err).Warn("Failed to check mentioned user's access")
+			continue
+		}
+		if !allowed {
+			continue
+		}
+
+		notification, err := delivery.notifications.AddNotification(ctx, core.Notification{
+			UserID:    user.ID,
+			Kind:      core.NotificationMention,
+			RoomID:    message.RoomID,
+			MessageID: message.ID,
+			From:      from,
+			Text:      message.Content,
+		})
+		if err != nil {
+			log.WithError(err).Warn("Failed to store mention notification")
+			continue
+		}
+		push(user.ID, notification)
+
+		if delivery.notifier != nil {
+			if err := delivery.notifier.Notify(ctx, *user, *notification); err != nil {
+				log.WithField("user_id", user.ID).WithError(err).Warn("Failed to deliver mention notification")
+			}
+		}
+	}
+}
+
+// deliverMentions notifies the users a chat message mentions in the
+// background, when mentions are on.
+func deliverMentions(srv *socketio.Server, message ChatMessage) {
+	delivery := getMentionDelivery()
+	if delivery == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mentionTimeout)
+		defer cancel()
+		notifyMentions(ctx, delivery, message, socketNotificationPusher(srv))
+	}()
+}
+
+// socketNotificationPusher emits notification to every socket of the user.
+func socketNotificationPusher(srv *socketio.Server) notificationPusher {
+	return func(userID string, notification *core.Notification) {
+		srv.FetchSockets()(func(sockets []*socketio.RemoteSocket, err error) {
+			if err != nil {
+				return
+			}
+			for _, socket := range sockets {
+				if user := socketUser(socket); user != nil && user.ID == userID {
+					_ = socket.Emit("notification", notification)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"no mentions here", nil},
+		{"@alice look", []string{"alice"}},
+		{"thanks @Bob.", []string{"bob"}},
+		{"@carol and @alice, @CAROL again", []string{"carol", "alice"}},
+		{"mail dave@example.com", nil},
+		{"(@erin) @@frank", []string{"erin"}},
+		{"@first.last-name", []string{"first.last-name"}},
+	}
+	for _, tt := range tests {
+		if got := extractMentions(tt.content); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("extractMentions(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+
+	many := strings.Repeat("@a @b @c @d @e @f @g @h @i @j @k @l ", 2)
+	if got := extractMentions(many); len(got) != maxMentionsPerMessage {
+		t.Errorf("extractMentions() returned %d logins, want %d", len(got), maxMentionsPerMessage)
+	}
+}
+
+type recordingNotifier struct {
+	delivered []core.Notification
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, _ core.User, notification core.Notification) error {
+	n.delivered = append(n.delivered, notification)
+	return nil
+}
+
+func TestNotifyMentions(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewDocumentStore()
+	users := store.(core.UserStore)
+	notifications := store.(core.NotificationStore)
+	for _, user := range []core.User{
+		{ID: "u-alice", Login: "alice", Name: "Alice"},
+		{ID: "u-bob", Login: "bob"},
+		{ID: "u-carol", Login: "carol"},
+	} {
+		if err := users.PutUser(ctx, user); err != nil {
+			t.Fatalf("PutUser() error = %v", err)
+		}
+	}
+	SetRoomPermissionStore(permissionMap{
+		"mention-room": {Owner: "u-alice", Visibility: core.RoomPrivate, AllowedUsers: []string{"u-bob"}},
+	})
+	defer SetRoomPermissionStore(nil)
+
+	notifier := &recordingNotifier{}
+	pushed := make(map[string]string)
+	delivery := &mentionDelivery{users: users, notifications: notifications, notifier: notifier}
+	message := ChatMessage{
+		ID:      "m1",
+		RoomID:  "mention-room",
+		Sender:  "socket-alice",
+		Content: "@alice @bob @carol @nobody have a look",
+		User:    &UserInfo{ID: "u-alice", Name: "Alice"},
+	}
+	notifyMentions(ctx, delivery, message, func(userID string, notification *core.Notification) {
+		pushed[userID] = notification.ID
+	})
+
+	// alice is the sender and carol may not join the room
+	if len(pushed) != 1 || pushed["u-bob"] == "" {
+		t.Fatalf("pushed notifications to %v, want only u-bob", pushed)
+	}
+	if len(notifier.delivered) != 1 || notifier.delivered[0].UserID != "u-bob" {
+		t.Fatalf("notifier delivered %+v, want one notification for u-bob", notifier.delivered)
+	}
+
+	stored, err := notifications.ListNotifications(ctx, "u-bob", true, 10)
+	if err != nil {
+		t.Fatalf("ListNotifications() error = %v", err)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("u-bob has %d unread notifications, want 1", len(stored))
+	}
+	got := stored[0]
+	if got.Kind != core.NotificationMention || got.RoomID != "mention-room" || got.MessageID != "m1" || got.From != "Alice" {
+		t.Errorf("stored notification = %+v", got)
+	}
+}
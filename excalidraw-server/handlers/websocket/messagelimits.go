@@ -0,0 +1,229 @@
+package websocket
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Default per-event limits, each well below MaxMessageSize so one event
+// can't fill the whole transport buffer.
+const (
+	defaultBroadcastLimit = 4 << 20
+	defaultChatLimit      = 16 << 10
+	defaultPresenceLimit  = 64 << 10
+
+	// roomLimitsTTL is how long a room's limits are used before they are
+	// read from the store again, so changes made on other instances apply
+	roomLimitsTTL = 30 * time.Second
+)
+
+// MessageLimits are the largest payloads, in bytes, clients may send with
+// each kind of event. Zero leaves only MaxMessageSize.
+type MessageLimits struct {
+	// Broadcast limits server-broadcast payloads and their metadata.
+	Broadcast int `json:"broadcast"`
+	// Chat limits the content of chat messages.
+	Chat int `json:"chat"`
+	// Presence limits server-volatile-broadcast payloads, which carry
+	// pointers and idle status.
+	Presence int `json:"presence"`
+}
+
+// messageKind names an event family in payload_too_large acks.
+type messageKind string
+
+const (
+	broadcastPayload messageKind = "broadcast"
+	chatPayload      messageKind = "chat"
+	presencePayload  messageKind = "presence"
+)
+
+var (
+	messageLimits atomic.Pointer[MessageLimits]
+
+	// roomLimitsStore keeps the limits of rooms with their settings; without
+	// one they are only kept in roomMessageLimits
+	roomLimitsStore core.RoomLimitsStore
+	// roomMessageLimits override the deployment's limits for some rooms;
+	// their zero fields keep the deployment's. With a store they cache it.
+	roomMessageLimits      = make(map[string]cachedRoomLimits)
+	roomMessageLimitsMutex sync.RWMutex
+)
+
+type cachedRoomLimits struct {
+	limits MessageLimits
+	loaded time.Time
+}
+
+func init() {
+	SetMessageLimits(MessageLimits{Broadcast: defaultBroadcastLimit, Chat: defaultChatLimit, Presence: defaultPresenceLimit})
+}
+
+// MessageLimitsFromEnv reads BROADCAST_MAX_BYTES, CHAT_MESSAGE_MAX_BYTES and
+// PRESENCE_MAX_BYTES, the deployment's limits for each kind of event. They
+// default to 4 MiB, 16 KiB and 64 KiB; zero leaves only MaxMessageSize.
+func MessageLimitsFromEnv() (MessageLimits, error) {
+	limits := MessageLimits{Broadcast: defaultBroadcastLimit, Chat: defaultChatLimit, Presence: defaultPresenceLimit}
+	for _, setting := range []struct {
+		name  string
+		value *int
+	}{{"BROADCAST_MAX_BYTES", &limits.Broadcast}, {"CHAT_MESSAGE_MAX_BYTES", &limits.Chat}, {"PRESENCE_MAX_BYTES", &limits.Presence}} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 || limit > MaxMessageSize {
+			return MessageLimits{}, fmt.Errorf("invalid %s %q: must be a number of bytes up to %d", setting.name, value, MaxMessageSize)
+		}
+		*setting.value = limit
+	}
+	return limits, nil
+}
+
+// Validate checks that every limit is between zero and MaxMessageSize.
+func (l MessageLimits) Validate() error {
+	for _, limit := range []int{l.Broadcast, l.Chat, l.Presence} {
+		if limit < 0 || limit > MaxMessageSize {
+			return fmt.Errorf("limits must be between 0 and %d bytes", MaxMessageSize)
+		}
+	}
+	return nil
+}
+
+// SetMessageLimits sets the deployment's limits, which rooms without their
+// own use.
+func SetMessageLimits(limits MessageLimits) {
+	messageLimits.Store(&limits)
+}
+
+// GetMessageLimits returns the deployment's limits.
+func GetMessageLimits() MessageLimits {
+	return *messageLimits.Load()
+}
+
+// SetRoomLimitsStore sets where rooms keep their limits, so they survive
+// restarts and apply on every instance. nil keeps them in memory.
+func SetRoomLimitsStore(store core.RoomLimitsStore) {
+	roomMessageLimitsMutex.Lock()
+	defer roomMessageLimitsMutex.Unlock()
+	roomLimitsStore = store
+	roomMessageLimits = make(map[string]cachedRoomLimits)
+}
+
+// SetRoomMessageLimits gives a room its own limits; fields left zero keep
+// the deployment's, so the zero MessageLimits removes the room's.
+func SetRoomMessageLimits(roomID string, limits MessageLimits) error {
+	roomMessageLimitsMutex.Lock()
+	defer roomMessageLimitsMutex.Unlock()
+	if roomLimitsStore != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), roomStateTimeout)
+		defer cancel()
+		if err := roomLimitsStore.PutRoomMessageLimits(ctx, roomID, core.RoomMessageLimits(limits)); err != nil {
+			return err
+		}
+	}
+	if roomLimitsStore == nil && limits == (MessageLimits{}) {
+		delete(roomMessageLimits, roomID)
+		return nil
+	}
+	roomMessageLimits[roomID] = cachedRoomLimits{limits: limits, loaded: time.Now()}
+	return nil
+}
+
+// GetRoomMessageLimits returns the limits that apply in a room.
+func GetRoomMessageLimits(roomID string) MessageLimits {
+	limits := GetMessageLimits()
+	room := roomLimits(roomID)
+	if room.Broadcast > 0 {
+		limits.Broadcast = room.Broadcast
+	}
+	if room.Chat > 0 {
+		limits.Chat = room.Chat
+	}
+	if room.Presence > 0 {
+		limits.Presence = room.Presence
+	}
+	return limits
+}
+
+// roomLimits returns a room's own limits, read through the store when its
+// cached ones are missing or older than roomLimitsTTL. Rooms whose limits
+// can't be read keep the deployment's until the next try.
+func roomLimits(roomID string) MessageLimits {
+	roomMessageLimitsMutex.RLock()
+	store := roomLimitsStore
+	cached, ok := roomMessageLimits[roomID]
+	roomMessageLimitsMutex.RUnlock()
+	if store == nil || ok && time.Since(cached.loaded) < roomLimitsTTL {
+		return cached.limits
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), roomStateTimeout)
+	defer cancel()
+	stored, err := store.GetRoomMessageLimits(ctx, roomID)
+	if err != nil {
+		logrus.WithField("room_id", roomID).WithError(err).Warn("Failed to load room message limits")
+		return cached.limits
+	}
+	roomMessageLimitsMutex.Lock()
+	roomMessageLimits[roomID] = cachedRoomLimits{limits: MessageLimits(stored), loaded: time.Now()}
+	roomMessageLimitsMutex.Unlock()
+	return MessageLimits(stored)
+}
+
+// forgetRoomMessageLimits drops the cached limits of a room that has become
+// empty; they are read from the store again when it is next used. Without a
+// store they are kept, since they exist nowhere else.
+func forgetRoomMessageLimits(roomID string) {
+	roomMessageLimitsMutex.Lock()
+	defer roomMessageLimitsMutex.Unlock()
+	if roomLimitsStore != nil {
+		delete(roomMessageLimits, roomID)
+	}
+}
+
+// tooLargeError is an event payload over its room's limit. Its error acks
+// carry the code payload_too_large, the kind of event and the limit.
+type tooLargeError struct {
+	kind  messageKind
+	size  int
+	limit int
+}
+
+func (e *tooLargeError) Error() string {
+	return fmt.Sprintf("%s payload of %d bytes is over the limit of %d", e.kind, e.size, e.limit)
+}
+
+// checkMessageSize returns a *tooLargeError when size is over the room's
+// limit for kind.
+func checkMessageSize(roomID string, kind messageKind, size int) error {
+	limits := GetRoomMessageLimits(roomID)
+	limit := limits.Broadcast
+	switch kind {
+	case chatPayload:
+		limit = limits.Chat
+	case presencePayload:
+		limit = limits.Presence
+	}
+	if limit == 0 || size <= limit {
+		return nil
+	}
+	return &tooLargeError{kind: kind, size: size, limit: limit}
+}
+
+// broadcastKind is the kind of event a broadcast's size is checked as.
+func broadcastKind(volatile bool) messageKind {
+	if volatile {
+		return presencePayload
+	}
+	return broadcastPayload
+}
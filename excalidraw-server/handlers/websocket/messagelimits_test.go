@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"context"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	ws "github.com/gorilla/websocket"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func TestMessageLimitsFromEnv(t *testing.T) {
+	t.Setenv("BROADCAST_MAX_BYTES", "")
+	t.Setenv("CHAT_MESSAGE_MAX_BYTES", "2048")
+	t.Setenv("PRESENCE_MAX_BYTES", "0")
+	limits, err := MessageLimitsFromEnv()
+	want := MessageLimits{Broadcast: defaultBroadcastLimit, Chat: 2048}
+	if err != nil || limits != want {
+		t.Errorf("got %+v, %v, want %+v", limits, err, want)
+	}
+	for _, value := range []string{"-1", "lots", "5000001"} {
+		t.Setenv("PRESENCE_MAX_BYTES", value)
+		if _, err := MessageLimitsFromEnv(); err == nil {
+			t.Errorf("PRESENCE_MAX_BYTES=%q was accepted", value)
+		}
+	}
+}
+
+func TestRoomMessageLimits(t *testing.T) {
+	defaults := GetMessageLimits()
+	SetRoomMessageLimits("limited", MessageLimits{Chat: 10})
+	defer SetRoomMessageLimits("limited", MessageLimits{})
+
+	if limits := GetRoomMessageLimits("limited"); limits.Chat != 10 || limits.Broadcast != defaults.Broadcast {
+		t.Errorf("room limits = %+v", limits)
+	}
+	if err := checkMessageSize("limited", chatPayload, 10); err != nil {
+		t.Errorf("a message at the limit was refused: %v", err)
+	}
+	err := checkMessageSize("limited", chatPayload, 11)
+	ack := errorAckPayload(err)
+	if ack["code"] != apierror.PayloadTooLarge || ack["kind"] != chatPayload || ack["limit"] != 10 {
+		t.Errorf("ack = %v", ack)
+	}
+	if err := checkMessageSize("unlimited", chatPayload, 11); err != nil {
+		t.Errorf("another room got the override: %v", err)
+	}
+
+	SetRoomMessageLimits("limited", MessageLimits{})
+	if limits := GetRoomMessageLimits("limited"); limits != defaults {
+		t.Errorf("removed override left %+v", limits)
+	}
+}
+
+// limitsMap is a RoomLimitsStore for tests.
+type limitsMap map[string]core.RoomMessageLimits
+
+func (m limitsMap) GetRoomMessageLimits(_ context.Context, roomID string) (core.RoomMessageLimits, error) {
+	return m[roomID], nil
+}
+
+func (m limitsMap) PutRoomMessageLimits(_ context.Context, roomID string, limits core.RoomMessageLimits) error {
+	m[roomID] = limits
+	return nil
+}
+
+func TestRoomMessageLimitsStore(t *testing.T) {
+	store := limitsMap{"stored": {Chat: 20}}
+	SetRoomLimitsStore(store)
+	defer SetRoomLimitsStore(nil)
+
+	// Limits saved before a restart or on another instance are read
+	if limits := GetRoomMessageLimits("stored"); limits.Chat != 20 {
+		t.Errorf("stored room limits = %+v", limits)
+	}
+	if err := SetRoomMessageLimits("stored", MessageLimits{Presence: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if store["stored"] != (core.RoomMessageLimits{Presence: 30}) {
+		t.Errorf("saved limits = %+v", store["stored"])
+	}
+
+	// Once the room empties they are read from the store again
+	store["stored"] = core.RoomMessageLimits{Chat: 40}
+	if limits := GetRoomMessageLimits("stored"); limits.Presence != 30 {
+		t.Errorf("cached room limits = %+v", limits)
+	}
+	forgetRoomMessageLimits("stored")
+	if limits := GetRoomMessageLimits("stored"); limits.Chat != 40 || limits.Presence != GetMessageLimits().Presence {
+		t.Errorf("reloaded room limits = %+v", limits)
+	}
+}
+
+func TestRawBroadcastTooLarge(t *testing.T) {
+	SetRoomMessageLimits("raw-limited", MessageLimits{Broadcast: 64})
+	defer SetRoomMessageLimits("raw-limited", MessageLimits{})
+
+	r := chi.NewRouter()
+	r.Get("/ws/rooms/{roomId}", HandleRawSocket(socketio.NewServer(nil, nil), AuthOptions{Mode: AuthOff}))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	conn := dialRaw(t, server, "raw-limited")
+	expectRaw(t, conn, "joined")
+	scene := `{"type":"SCENE_INIT","payload":{"elements":[],"padding":"` + strings.Repeat("x", 64) + `"}}`
+	if err := conn.WriteMessage(ws.TextMessage, []byte(`{"type":"server-broadcast","id":1,"data":{"payload":`+scene+`}}`)); err != nil {
+		t.Fatalf("WriteMessage() failed: %v", err)
+	}
+	ack := expectRaw(t, conn, "broadcast-ack")
+	if !strings.Contains(string(ack.Data), `"code":"payload_too_large"`) || !strings.Contains(string(ack.Data), `"limit":64`) {
+		t.Errorf("ack = %s, want payload_too_large with the limit", ack.Data)
+	}
+}
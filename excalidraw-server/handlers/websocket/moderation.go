@@ -0,0 +1,305 @@
+package websocket
+
+import (
+	"excalidraw-server/apierror"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zishang520/engine.io/v2/utils"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const defaultBanDuration = time.Hour
+
+// roomModeration tracks the owner, bans and mutes of a single room.
+type roomModeration struct {
+	ownerSocket socketio.SocketId
+	ownerUser   string
+	// bans maps a ban key (user or address) to its expiry
+	bans  map[string]time.Time
+	muted map[socketio.SocketId]struct{}
+}
+
+var (
+	moderation      = make(map[string]*roomModeration)
+	moderationMutex sync.Mutex
+	banDuration     atomic.Int64
+)
+
+func init() {
+	banDuration.Store(int64(defaultBanDuration))
+}
+
+// SetBanDuration sets how long moderate-ban blocks rejoins when no duration is given.
+func SetBanDuration(d time.Duration) {
+	if d <= 0 {
+		d = defaultBanDuration
+	}
+	banDuration.Store(int64(d))
+}
+
+func getRoomModeration(roomID string) *roomModeration {
+	state, exists := moderation[roomID]
+	if !exists {
+		state = &roomModeration{
+			bans:  make(map[string]time.Time),
+			muted: make(map[socketio.SocketId]struct{}),
+		}
+		moderation[roomID] = state
+	}
+	return state
+}
+
+// banKeys returns the keys a socket is banned under: its user id when
+// authenticated, and its remote address otherwise.
+func banKeys(user *UserInfo, address string) []string {
+	keys := make([]string, 0, 2)
+	if user != nil {
+		keys = append(keys, "user:"+user.ID)
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	if address != "" {
+		keys = append(keys, "addr:"+address)
+	}
+	return keys
+}
+
+// banExpiry reports when the ban on any of keys expires, pruning expired bans.
+func banExpiry(roomID string, keys []string, now time.Time) (time.Time, bool) {
+	moderationMutex.Lock()
+	defer moderationMutex.Unlock()
+
+	state, exists := moderation[roomID]
+	if !exists {
+		return time.Time{}, false
+	}
+
+	for key, expiry := range state.bans {
+		if !now.Before(expiry) {
+			delete(state.bans, key)
+		}
+	}
+
+	for _, key := range keys {
+		if expiry, banned := state.bans[key]; banned {
+			return expiry, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func addBan(roomID string, keys []string, until time.Time) {
+	moderationMutex.Lock()
+	defer moderationMutex.Unlock()
+
+	state := getRoomModeration(roomID)
+	for _, key := range keys {
+		state.bans[key] = until
+	}
+}
+
+// claimOwnership records the joining socket as owner when it is first in the
+// room or is the authenticated user who owned the room before.
+func claimOwnership(roomID string, socketID socketio.SocketId, user *UserInfo, firstInRoom bool) bool {
+	moderationMutex.Lock()
+	defer moderationMutex.Unlock()
+
+	state := getRoomModeration(roomID)
+	switch {
+	case user != nil && state.ownerUser == user.ID:
+		state.ownerSocket = socketID
+	case firstInRoom || state.ownerSocket == "" && state.ownerUser == "":
+		state.ownerSocket = socketID
+		state.ownerUser = ""
+		if user != nil {
+			state.ownerUser = user.ID
+		}
+	}
+	return state.ownerSocket == socketID
+}
+
+func isRoomOwner(roomID string, socketID socketio.SocketId) bool {
+	moderationMutex.Lock()
+	defer moderationMutex.Unlock()
+
+	state, exists := moderation[roomID]
+	return exists && state.ownerSocket == socketID
+}
+
+// roomOwnerSocket returns the socket that owns a room, or "" when none does.
+func roomOwnerSocket(roomID string) socketio.SocketId {
+	moderationMutex.Lock()
+	defer moderationMutex.Unlock()
+
+	state, exists := moderation[roomID]
+	if !exists {
+		return ""
+	}
+	return state.ownerSocket
+}
+
+func setMuted(roomID string, socketID socketio.SocketId, muted bool) {
+	moderationMutex.Lock()
+	defer moderationMutex.Unlock()
+
+	state := getRoomModeration(roomID)
+	if muted {
+		state.muted[socketID] = struct{}{}
+	} else {
+		delete(state.muted, socketID)
+	}
+}
+
+func isMuted(roomID string, socketID socketio.SocketId) bool {
+	moderationMutex.Lock()
+	defer moderationMutex.Unlock()
+
+	state, exists := moderation[roomID]
+	if !exists {
+		return false
+	}
+	_, muted := state.muted[socketID]
+	return muted
+}
+
+// leaveModeration drops per-socket state when a socket leaves a room. Owners
+// who are not authenticated hand the room over to the next remaining socket.
+func leaveModeration(roomID string, socketID socketio.SocketId, remaining []socketio.SocketId) {
+	moderationMutex.Lock()
+	defer moderationMutex.Unlock()
+
+	state, exists := moderation[roomID]
+	if !exists {
+		return
+	}
+
+	delete(state.muted, socketID)
+	if len(remaining) == 0 {
+		state.ownerSocket = ""
+		state.ownerUser = ""
+		if len(state.bans) == 0 {
+			delete(moderation, roomID)
+		}
+		return
+	}
+
+	if state.ownerSocket == socketID {
+		state.ownerSocket = ""
+		if state.ownerUser == "" {
+			state.ownerSocket = remaining[0]
+		}
+	}
+}
+
+func handleModeration(socket *socketio.Socket, srv *socketio.Server, event string, datas []any) {
+	ack, args := extractAck(datas)
+	respondError := func(err error) {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+	}
+
+	request, err := decodeModeration(event, args)
+	if err != nil {
+		respondError(err)
+		return
+	}
+	roomID, target := request.roomID, request.target
+	if !isRoomOwner(roomID, socket.Id()) {
+		respondError(apierror.New(apierror.NotRoomOwner, "only the room owner can moderate"))
+		return
+	}
+
+	targetID := socketio.SocketId(target)
+	room := socketio.Room(roomID)
+	srv.In(socketio.Room(targetID)).FetchSockets()(func(sockets []*socketio.RemoteSocket, fetchErr error) {
+		if fetchErr != nil {
+			respondError(fetchErr)
+			return
+		}
+		if len(sockets) == 0 || !sockets[0].Rooms().Has(room) {
+			respondError(apierror.Errorf(apierror.NotInRoom, "socket %s is not in room %s", target, roomID))
+			return
+		}
+		targetSocket := sockets[0]
+
+		payload := map[string]any{"status": "ok", "target": target}
+		switch event {
+		case "moderate-kick":
+			removeFromRoom(srv, roomID, targetSocket, "moderation-kicked", map[string]any{"roomId": roomID})
+		case "moderate-ban":
+			duration := time.Duration(banDuration.Load())
+			if request.banSeconds > 0 {
+				duration = time.Duration(request.banSeconds * float64(time.Second))
+			}
+			until := time.Now().Add(duration)
+			addBan(roomID, banKeys(socketUser(targetSocket), clientAddress(targetSocket.Handshake())), until)
+			removeFromRoom(srv, roomID, targetSocket, "moderation-banned", map[string]any{
+				"roomId": roomID,
+				"until":  until.UnixMilli(),
+			})
+			payload["until"] = until.UnixMilli()
+		case "moderate-mute":
+			muted := request.muted
+			setMuted(roomID, targetID, muted)
+			notice := map[string]any{"roomId": roomID, "muted": muted}
+			if !deliverReliably(targetID, "moderation-muted", notice) {
+				_ = targetSocket.Emit("moderation-muted", notice)
+			}
+			payload["muted"] = muted
+		}
+
+		utils.Log().Printf("%v applied %v to %v in room %v\n", socket.Id(), event, target, roomID)
+		respondWithAck(socket, ack, "", payload, nil)
+	})
+}
+
+// removeFromRoom notifies the target, makes it leave the room and updates
+// the remaining members, on the room's worker.
+func removeFromRoom(srv *socketio.Server, roomID string, target *socketio.RemoteSocket, event string, payload map[string]any) {
+	runInRoom(roomID, func() {
+		room := socketio.Room(roomID)
+		if !deliverReliably(target.Id(), event, payload) {
+			_ = target.Emit(event, payload)
+		}
+		target.Leave(room)
+		stopTyping(roomID, target.Id(), roomTypingEmitter(srv))
+		presenterLeft(roomID, target.Id(), false, roomPresentationEmitter(srv))
+
+		srv.In(room).FetchSockets()(func(users []*socketio.RemoteSocket, err error) {
+			if err != nil {
+				return
+			}
+
+			remaining := make([]socketio.SocketId, 0, len(users))
+			remainingSockets := make([]*socketio.RemoteSocket, 0, len(users))
+			for _, user := range users {
+				if user.Id() != target.Id() {
+					remaining = append(remaining, user.Id())
+					remainingSockets = append(remainingSockets, user)
+				}
+			}
+			leaveModeration(roomID, target.Id(), remaining)
+			trackLeave(roomID, target.Id())
+			clearSocketEncoding(roomID, target.Id())
+
+			persist := func() {}
+			roomsMutex.Lock()
+			if members := roomMembers(roomID, len(remaining)); members == 0 {
+				delete(activeRooms, roomID)
+				persist = releaseRoom(roomID)
+			} else {
+				activeRooms[roomID] = members
+			}
+			roomsMutex.Unlock()
+			persist()
+
+			if len(remaining) > 0 {
+				srv.In(room).Emit("room-user-change", remaining)
+			}
+			emitPresence(srv, roomID, remainingSockets)
+		})
+	})
+}
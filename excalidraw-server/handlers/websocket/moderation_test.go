@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func resetModeration() {
+	moderationMutex.Lock()
+	moderation = make(map[string]*roomModeration)
+	moderationMutex.Unlock()
+}
+
+func TestBanKeys(t *testing.T) {
+	keys := banKeys(&UserInfo{ID: "42"}, "10.0.0.1:5000")
+	if len(keys) != 2 || keys[0] != "user:42" || keys[1] != "addr:10.0.0.1" {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+
+	keys = banKeys(nil, "10.0.0.1")
+	if len(keys) != 1 || keys[0] != "addr:10.0.0.1" {
+		t.Errorf("unexpected keys for anonymous socket: %v", keys)
+	}
+}
+
+func TestBanKeysBehindProxy(t *testing.T) {
+	SetTrustProxy(true)
+	defer SetTrustProxy(false)
+
+	keys := make(chan []string, 2)
+	srv := socketio.NewServer(nil, nil)
+	srv.Use(func(socket *socketio.Socket, next func(*socketio.ExtendedError)) {
+		keys <- banKeys(socketUser(socket), clientAddress(socket.Handshake()))
+		next(nil)
+	})
+	server := httptest.NewServer(srv.ServeHandler(nil))
+	defer server.Close()
+
+	// Both sockets reach the server from the proxy's address
+	connectSocketIO(t, server, http.Header{"X-Real-Ip": {"203.0.113.1"}})
+	connectSocketIO(t, server, http.Header{"X-Real-Ip": {"203.0.113.2"}})
+	first, second := <-keys, <-keys
+	if len(first) != 1 || len(second) != 1 || first[0] == second[0] || first[0] != "addr:203.0.113.1" {
+		t.Errorf("ban keys = %v and %v, want each socket's forwarded address", first, second)
+	}
+}
+
+func TestBanExpiry(t *testing.T) {
+	resetModeration()
+
+	now := time.Now()
+	addBan("room-1", []string{"addr:10.0.0.1"}, now.Add(time.Minute))
+
+	if _, banned := banExpiry("room-1", []string{"addr:10.0.0.1"}, now); !banned {
+		t.Error("expected address to be banned")
+	}
+	if _, banned := banExpiry("room-1", []string{"addr:10.0.0.2"}, now); banned {
+		t.Error("expected other address not to be banned")
+	}
+	if _, banned := banExpiry("room-2", []string{"addr:10.0.0.1"}, now); banned {
+		t.Error("expected ban to be scoped to its room")
+	}
+	if _, banned := banExpiry("room-1", []string{"addr:10.0.0.1"}, now.Add(2*time.Minute)); banned {
+		t.Error("expected ban to expire")
+	}
+}
+
+func TestClaimOwnership_FirstInRoom(t *testing.T) {
+	resetModeration()
+
+	if !claimOwnership("room", "a", nil, true) {
+		t.Error("expected first socket to own the room")
+	}
+	if claimOwnership("room", "b", nil, false) {
+		t.Error("expected second socket not to own the room")
+	}
+	if !isRoomOwner("room", "a") || isRoomOwner("room", "b") {
+		t.Error("ownership not recorded correctly")
+	}
+}
+
+func TestClaimOwnership_AuthenticatedOwnerReclaims(t *testing.T) {
+	resetModeration()
+
+	owner := &UserInfo{ID: "owner"}
+	claimOwnership("room", "a", owner, true)
+	claimOwnership("room", "b", nil, false)
+
+	leaveModeration("room", "a", []socketio.SocketId{"b"})
+	if isRoomOwner("room", "b") {
+		t.Error("authenticated ownership should not pass to another socket")
+	}
+
+	if !claimOwnership("room", "c", owner, false) {
+		t.Error("expected the authenticated owner to reclaim the room on rejoin")
+	}
+}
+
+func TestLeaveModeration_HandsOverAnonymousOwnership(t *testing.T) {
+	resetModeration()
+
+	claimOwnership("room", "a", nil, true)
+	claimOwnership("room", "b", nil, false)
+	setMuted("room", "b", true)
+
+	leaveModeration("room", "a", []socketio.SocketId{"b"})
+	if !isRoomOwner("room", "b") {
+		t.Error("expected ownership to pass to the remaining socket")
+	}
+
+	leaveModeration("room", "b", nil)
+	moderationMutex.Lock()
+	_, exists := moderation["room"]
+	moderationMutex.Unlock()
+	if exists {
+		t.Error("expected moderation state to be dropped for an empty room without bans")
+	}
+}
+
+func TestLeaveModeration_KeepsBans(t *testing.T) {
+	resetModeration()
+
+	claimOwnership("room", "a", nil, true)
+	addBan("room", []string{"addr:10.0.0.1"}, time.Now().Add(time.Hour))
+	leaveModeration("room", "a", nil)
+
+	if _, banned := banExpiry("room", []string{"addr:10.0.0.1"}, time.Now()); !banned {
+		t.Error("expected bans to outlive an empty room")
+	}
+}
+
+func TestMute(t *testing.T) {
+	resetModeration()
+
+	setMuted("room", "a", true)
+	if !isMuted("room", "a") {
+		t.Error("expected socket to be muted")
+	}
+	if isMuted("other-room", "a") {
+		t.Error("expected mute to be scoped to its room")
+	}
+
+	setMuted("room", "a", false)
+	if isMuted("room", "a") {
+		t.Error("expected socket to be unmuted")
+	}
+}
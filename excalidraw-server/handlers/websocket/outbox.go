@@ -0,0 +1,173 @@
+package websocket
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zishang520/engine.io/v2/types"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const defaultOutboxSize = 100
+
+// outboxEntry is an event sent to a socket and not acknowledged yet.
+type outboxEntry struct {
+	ID    uint64 `json:"id"`
+	Event string `json:"event"`
+	Args  []any  `json:"args"`
+}
+
+// outboxSocket is the part of a socket an outbox uses.
+type outboxSocket interface {
+	Id() socketio.SocketId
+	Rooms() *types.Set[socketio.Room]
+	Emit(event string, args ...any) error
+}
+
+// outbox keeps what was sent to a socket that acknowledges events until it
+// does, so it can be sent again when the client resumes on a new socket.
+type outbox struct {
+	socket  outboxSocket
+	entries []outboxEntry
+	nextID  uint64
+}
+
+var (
+	outboxes      = make(map[socketio.SocketId]*outbox)
+	outboxesMutex sync.Mutex
+	outboxSize    atomic.Int64
+)
+
+func init() {
+	outboxSize.Store(defaultOutboxSize)
+}
+
+// SetOutboxSize sets how many unacknowledged events are kept per socket;
+// beyond it the oldest are dropped. Zero turns acknowledged delivery off for
+// sockets that connect afterwards.
+func SetOutboxSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+	outboxSize.Store(int64(size))
+}
+
+// openOutbox starts acknowledged delivery for a socket that asked for it
+// with acks: true in its handshake auth.
+func openOutbox(socket *socketio.Socket) {
+	if outboxSize.Load() == 0 || !acksFromHandshake(socket.Handshake().Auth) {
+		return
+	}
+	outboxesMutex.Lock()
+	defer outboxesMutex.Unlock()
+	outboxes[socket.Id()] = &outbox{socket: socket}
+}
+
+// closeOutbox stops delivery to a socket that went away; what it didn't
+// acknowledge is dropped, or carried over by its resume session.
+func closeOutbox(socketID socketio.SocketId) {
+	outboxesMutex.Lock()
+	defer outboxesMutex.Unlock()
+	delete(outboxes, socketID)
+}
+
+// deliverReliably sends an event to a socket that acknowledges events and
+// keeps it until the client does. It reports false for other sockets, which
+// the caller sends to as usual.
+func deliverReliably(socketID socketio.SocketId, event string, args ...any) bool {
+	outboxesMutex.Lock()
+	box, exists := outboxes[socketID]
+	if !exists {
+		outboxesMutex.Unlock()
+		return false
+	}
+	box.nextID++
+	entry := outboxEntry{ID: box.nextID, Event: event, Args: args}
+	box.entries = append(box.entries, entry)
+	if overflow := len(box.entries) - int(outboxSize.Load()); overflow > 0 {
+		logrus.WithFields(logrus.Fields{
+			"socket_id": socketID,
+			"dropped":   overflow,
+		}).Warn("Outbox full, dropped unacknowledged events")
+		box.entries = append(box.entries[:0:0], box.entries[overflow:]...)
+	}
+	socket := box.socket
+	outboxesMutex.Unlock()
+
+	emitEntry(socket, entry)
+	return true
+}
+
+// redeliver sends the events an earlier socket of the client didn't
+// acknowledge, acknowledged again if the new socket acknowledges events.
+func redeliver(socket *socketio.Socket, entries []outboxEntry) {
+	for _, entry := range entries {
+		if !deliverReliably(socket.Id(), entry.Event, entry.Args...) {
+			_ = socket.Emit(entry.Event, entry.Args...)
+		}
+	}
+}
+
+// pendingDeliveries returns the events a socket hasn't acknowledged yet.
+func pendingDeliveries(socketID socketio.SocketId) []outboxEntry {
+	outboxesMutex.Lock()
+	defer outboxesMutex.Unlock()
+
+	box, exists := outboxes[socketID]
+	if !exists || len(box.entries) == 0 {
+		return nil
+	}
+	return append([]outboxEntry(nil), box.entries...)
+}
+
+// reliableMembers returns the sockets in a room that acknowledge events.
+func reliableMembers(roomID string) []socketio.SocketId {
+	outboxesMutex.Lock()
+	defer outboxesMutex.Unlock()
+
+	room := socketio.Room(roomID)
+	var members []socketio.SocketId
+	for socketID, box := range outboxes {
+		if box.socket.Rooms().Has(room) {
+			members = append(members, socketID)
+		}
+	}
+	return members
+}
+
+func emitEntry(socket outboxSocket, entry outboxEntry) {
+	socketID := socket.Id()
+	args := append(append([]any(nil), entry.Args...), func(_ []any, err error) {
+		if err == nil {
+			acknowledge(socketID, entry.ID)
+		}
+	})
+	_ = socket.Emit(entry.Event, args...)
+}
+
+func acknowledge(socketID socketio.SocketId, id uint64) {
+	outboxesMutex.Lock()
+	defer outboxesMutex.Unlock()
+
+	box, exists := outboxes[socketID]
+	if !exists {
+		return
+	}
+	for i, entry := range box.entries {
+		if entry.ID == id {
+			box.entries = append(box.entries[:i], box.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func acksFromHandshake(handshakeAuth any) bool {
+	payload, ok := handshakeAuth.(map[string]any)
+	if !ok {
+		return false
+	}
+
+	acks, _ := payload["acks"].(bool)
+	return acks
+}
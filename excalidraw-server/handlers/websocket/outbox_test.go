@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/zishang520/engine.io/v2/types"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+type sentEvent struct {
+	event string
+	args  []any
+	ack   func([]any, error)
+}
+
+// fakeOutboxSocket records what is emitted to it and hands out the ack
+// callbacks.
+type fakeOutboxSocket struct {
+	id    socketio.SocketId
+	rooms *types.Set[socketio.Room]
+	sent  []sentEvent
+}
+
+func (s *fakeOutboxSocket) Id() socketio.SocketId            { return s.id }
+func (s *fakeOutboxSocket) Rooms() *types.Set[socketio.Room] { return s.rooms }
+
+func (s *fakeOutboxSocket) Emit(event string, args ...any) error {
+	sent := sentEvent{event: event, args: args}
+	if ack, ok := args[len(args)-1].(func([]any, error)); ok {
+		sent.args, sent.ack = args[:len(args)-1], ack
+	}
+	s.sent = append(s.sent, sent)
+	return nil
+}
+
+func openFakeOutbox(t *testing.T, id socketio.SocketId, rooms ...socketio.Room) *fakeOutboxSocket {
+	t.Helper()
+	socket := &fakeOutboxSocket{id: id, rooms: types.NewSet(rooms...)}
+	outboxesMutex.Lock()
+	outboxes[id] = &outbox{socket: socket}
+	outboxesMutex.Unlock()
+	t.Cleanup(func() { closeOutbox(id) })
+	return socket
+}
+
+func TestDeliverReliably(t *testing.T) {
+	socket := openFakeOutbox(t, "reliable-socket", "room")
+
+	if deliverReliably("other-socket", "client-chat-message", "hi") {
+		t.Error("deliverReliably() to a socket without an outbox reported true")
+	}
+	for _, content := range []string{"one", "two", "three"} {
+		if !deliverReliably("reliable-socket", "client-chat-message", content) {
+			t.Fatal("deliverReliably() reported false")
+		}
+	}
+	if len(socket.sent) != 3 || socket.sent[0].ack == nil {
+		t.Fatalf("sent %+v, want three events with acks", socket.sent)
+	}
+
+	// Acknowledged events are dropped, the rest wait for a resume
+	socket.sent[0].ack(nil, nil)
+	socket.sent[2].ack(nil, nil)
+	pending := pendingDeliveries("reliable-socket")
+	if len(pending) != 1 || pending[0].Args[0] != "two" {
+		t.Fatalf("pendingDeliveries() = %+v, want the second event", pending)
+	}
+
+	if members := reliableMembers("room"); len(members) != 1 || members[0] != "reliable-socket" {
+		t.Errorf("reliableMembers() = %v, want the socket", members)
+	}
+	if members := reliableMembers("other-room"); len(members) != 0 {
+		t.Errorf("reliableMembers() of another room = %v, want none", members)
+	}
+
+	closeOutbox("reliable-socket")
+	if pending := pendingDeliveries("reliable-socket"); pending != nil {
+		t.Errorf("pendingDeliveries() after closing = %+v, want none", pending)
+	}
+}
+
+func TestOutboxLimit(t *testing.T) {
+	SetOutboxSize(2)
+	defer SetOutboxSize(defaultOutboxSize)
+	openFakeOutbox(t, "busy-socket")
+
+	for _, content := range []string{"one", "two", "three"} {
+		deliverReliably("busy-socket", "client-chat-message", content)
+	}
+	pending := pendingDeliveries("busy-socket")
+	if len(pending) != 2 || pending[0].Args[0] != "two" || pending[1].Args[0] != "three" {
+		t.Errorf("pendingDeliveries() = %+v, want the two newest", pending)
+	}
+}
+
+func TestAcksFromHandshake(t *testing.T) {
+	if !acksFromHandshake(map[string]any{"acks": true}) {
+		t.Error("acksFromHandshake() with acks: true = false")
+	}
+	if acksFromHandshake(map[string]any{"acks": "yes"}) || acksFromHandshake(nil) {
+		t.Error("acksFromHandshake() without acks: true = true")
+	}
+}
@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	permissionStore      core.RoomPermissionStore
+	permissionStoreMutex sync.RWMutex
+)
+
+// SetRoomPermissionStore sets where room permissions are read from; nil
+// treats every room as public. Permissions are only enforced on join when
+// socket auth is enabled, since anonymous sockets can't be told apart.
+func SetRoomPermissionStore(store core.RoomPermissionStore) {
+	permissionStoreMutex.Lock()
+	defer permissionStoreMutex.Unlock()
+	permissionStore = store
+}
+
+func getPermissionStore() core.RoomPermissionStore {
+	permissionStoreMutex.RLock()
+	defer permissionStoreMutex.RUnlock()
+	return permissionStore
+}
+
+// canJoin reports whether user may join roomID under its stored permissions.
+func canJoin(ctx context.Context, roomID string, user *UserInfo) (bool, error) {
+	store := getPermissionStore()
+	if store == nil {
+		return true, nil
+	}
+	permissions, err := store.GetRoomPermissions(ctx, roomID)
+	if err != nil {
+		return false, err
+	}
+	userID := ""
+	if user != nil {
+		userID = user.ID
+	}
+	return permissions.Allows(userID), nil
+}
+
+// CanJoinRoom reports whether the user with userID, or an anonymous one
+// for "", may join roomID under its stored permissions.
+func CanJoinRoom(ctx context.Context, roomID, userID string) (bool, error) {
+	var user *UserInfo
+	if userID != "" {
+		user = &UserInfo{ID: userID}
+	}
+	return canJoin(ctx, roomID, user)
+}
+
+// GetListedRooms is GetActiveRooms without link-only and private rooms.
+func GetListedRooms() map[string]int {
+	rooms := GetActiveRooms()
+	store := getPermissionStore()
+	if store == nil {
+		return rooms
+	}
+	for roomID := range rooms {
+		permissions, err := store.GetRoomPermissions(context.Background(), roomID)
+		if err != nil {
+			logrus.WithField("room_id", roomID).WithError(err).Warn("Failed to get room permissions")
+			delete(rooms, roomID)
+			continue
+		}
+		if permissions.Visibility != core.RoomPublic {
+			delete(rooms, roomID)
+		}
+	}
+	return rooms
+}
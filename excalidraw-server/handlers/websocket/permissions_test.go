@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"testing"
+)
+
+// permissionMap is a RoomPermissionStore for tests; missing rooms are public.
+type permissionMap map[string]core.RoomPermissions
+
+func (m permissionMap) GetRoomPermissions(_ context.Context, roomID string) (*core.RoomPermissions, error) {
+	permissions, ok := m[roomID]
+	if !ok {
+		permissions = core.RoomPermissions{RoomID: roomID, Visibility: core.RoomPublic}
+	}
+	return &permissions, nil
+}
+
+func (m permissionMap) PutRoomPermissions(_ context.Context, permissions *core.RoomPermissions) error {
+	m[permissions.RoomID] = *permissions
+	return nil
+}
+
+func TestCanJoin(t *testing.T) {
+	SetRoomPermissionStore(permissionMap{
+		"private":   {Owner: "alice", Visibility: core.RoomPrivate, AllowedUsers: []string{"bob"}},
+		"link-only": {Owner: "alice", Visibility: core.RoomLinkOnly},
+	})
+	defer SetRoomPermissionStore(nil)
+
+	tests := []struct {
+		room string
+		user *UserInfo
+		want bool
+	}{
+		{"open", nil, true},
+		{"link-only", nil, true},
+		{"private", &UserInfo{ID: "alice"}, true},
+		{"private", &UserInfo{ID: "bob"}, true},
+		{"private", &UserInfo{ID: "carol"}, false},
+		{"private", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := canJoin(context.Background(), tt.room, tt.user)
+		if err != nil {
+			t.Fatalf("canJoin(%s) failed: %v", tt.room, err)
+		}
+		if got != tt.want {
+			t.Errorf("canJoin(%s, %+v) = %v, want %v", tt.room, tt.user, got, tt.want)
+		}
+	}
+
+	SetRoomPermissionStore(nil)
+	if ok, _ := canJoin(context.Background(), "private", nil); !ok {
+		t.Error("rooms should be open without a permission store")
+	}
+}
+
+func TestGetListedRooms(t *testing.T) {
+	roomsMutex.Lock()
+	activeRooms = map[string]int{"open": 2, "link-only": 1, "private": 3}
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		activeRooms = make(map[string]int)
+		roomsMutex.Unlock()
+	}()
+
+	SetRoomPermissionStore(permissionMap{
+		"private":   {Visibility: core.RoomPrivate},
+		"link-only": {Visibility: core.RoomLinkOnly},
+	})
+	defer SetRoomPermissionStore(nil)
+
+	rooms := GetListedRooms()
+	if len(rooms) != 1 || rooms["open"] != 2 {
+		t.Errorf("GetListedRooms() = %v, want only the public room", rooms)
+	}
+}
@@ -0,0 +1,269 @@
+package websocket
+
+import (
+	"excalidraw-server/apierror"
+	"sync"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// presenterGrace is how long a presentation waits for a presenter who lost
+// its socket to come back before it ends
+const presenterGrace = time.Minute
+
+var (
+	errPresentationTaken = apierror.New(apierror.PresentationTaken, "someone else is presenting")
+	errNotPresenter      = apierror.New(apierror.NotPresenter, "not the presenter")
+)
+
+// presentation is who presents a room and where they are.
+type presentation struct {
+	presenter socketio.SocketId
+	user      *UserInfo
+	frameID   string
+	// slide is -1 until the presenter reports one
+	slide int
+	// connected is false while waiting for the presenter to come back
+	connected bool
+	timer     *time.Timer
+}
+
+// presentationEmitter sends presentation-state to a whole room.
+type presentationEmitter func(roomID string, payload map[string]any)
+
+var (
+	presentations      = make(map[string]*presentation)
+	presentationsMutex sync.Mutex
+)
+
+// handleLaserPointer relays laser-pointer(roomId, { x, y, active? }) to the
+// rest of the room as a volatile laser-pointer { roomId, socketId, user, x,
+// y, active }.
+func handleLaserPointer(socket *socketio.Socket, datas []any) {
+	ack, args := extractAck(datas)
+	request, err := decodeLaserPointer(args)
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	roomID := request.roomID
+
+	switch {
+	case !socket.Rooms().Has(socketio.Room(roomID)):
+		err = apierror.Errorf(apierror.NotInRoom, "not in room %s", roomID)
+	case isMuted(roomID, socket.Id()):
+		err = apierror.New(apierror.Muted, "muted in room")
+	}
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+
+	_ = socket.Volatile().To(socketio.Room(roomID)).Emit("laser-pointer", map[string]any{
+		"roomId":   roomID,
+		"socketId": string(socket.Id()),
+		"user":     socketUser(socket),
+		"x":        request.x,
+		"y":        request.y,
+		"active":   request.active,
+	})
+	respondWithAck(socket, ack, "", map[string]any{"status": "ok"}, nil)
+}
+
+// handlePresentation handles presentation-state(roomId, { presenting?,
+// frameId?, slide? }) from the presenter, or from a socket that wants to
+// present, and tells the whole room the new state with presentation-state.
+// Only the presenter and the room's owner may change a presentation.
+func handlePresentation(socket *socketio.Socket, srv *socketio.Server, datas []any) {
+	ack, args := extractAck(datas)
+	request, err := decodePresentation(args)
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	roomID := request.roomID
+
+	if !socket.Rooms().Has(socketio.Room(roomID)) {
+		err := apierror.Errorf(apierror.NotInRoom, "not in room %s", roomID)
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+
+	owner := isRoomOwner(roomID, socket.Id())
+	if err := updatePresentation(request, socket.Id(), socketUser(socket), owner, roomPresentationEmitter(srv)); err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	respondWithAck(socket, ack, "", map[string]any{"status": "ok"}, nil)
+}
+
+// updatePresentation applies a presentation-state request from socketID and
+// tells the room. A frame or slide the request leaves out stays as it was.
+func updatePresentation(request presentationRequest, socketID socketio.SocketId, user *UserInfo, owner bool, emit presentationEmitter) error {
+	roomID := request.roomID
+
+	presentationsMutex.Lock()
+	current := presentations[roomID]
+	if !request.presenting {
+		if current == nil {
+			presentationsMutex.Unlock()
+			return nil
+		}
+		if current.presenter != socketID && !owner {
+			presentationsMutex.Unlock()
+			return errNotPresenter
+		}
+		stopPresenterTimer(current)
+		delete(presentations, roomID)
+		presentationsMutex.Unlock()
+
+		emit(roomID, presentationPayload(roomID, nil))
+		return nil
+	}
+
+	if current == nil {
+		current = &presentation{slide: -1}
+		presentations[roomID] = current
+	} else if current.presenter != socketID && !owner {
+		presentationsMutex.Unlock()
+		return errPresentationTaken
+	}
+	stopPresenterTimer(current)
+	current.presenter, current.user, current.connected = socketID, user, true
+	if request.frameID != "" {
+		current.frameID = request.frameID
+	}
+	if request.slide >= 0 {
+		current.slide = request.slide
+	}
+	payload := presentationPayload(roomID, current)
+	presentationsMutex.Unlock()
+
+	emit(roomID, payload)
+	return nil
+}
+
+// presenterLeft handles the presenter leaving a room. The presentation waits
+// presenterGrace for it to come back when grace is set, and ends right away
+// otherwise.
+func presenterLeft(roomID string, socketID socketio.SocketId, grace bool, emit presentationEmitter) {
+	presentationsMutex.Lock()
+	current := presentations[roomID]
+	if current == nil || current.presenter != socketID || !current.connected {
+		presentationsMutex.Unlock()
+		return
+	}
+	if !grace {
+		delete(presentations, roomID)
+		presentationsMutex.Unlock()
+
+		emit(roomID, presentationPayload(roomID, nil))
+		return
+	}
+	current.connected = false
+	current.timer = time.AfterFunc(presenterGrace, func() {
+		expirePresentation(roomID, current, emit)
+	})
+	payload := presentationPayload(roomID, current)
+	presentationsMutex.Unlock()
+
+	emit(roomID, payload)
+}
+
+// reclaimPresentation gives a presentation waiting for its presenter to the
+// socket that came back for it: the one that resumed the presenter's socket
+// previous, or one of the same authenticated user.
+func reclaimPresentation(roomID string, socketID socketio.SocketId, user *UserInfo, previous socketio.SocketId, emit presentationEmitter) bool {
+	presentationsMutex.Lock()
+	current := presentations[roomID]
+	if current == nil || current.connected {
+		presentationsMutex.Unlock()
+		return false
+	}
+	sameSocket := previous != "" && current.presenter == previous
+	sameUser := user != nil && current.user != nil && current.user.ID == user.ID
+	if !sameSocket && !sameUser {
+		presentationsMutex.Unlock()
+		return false
+	}
+	stopPresenterTimer(current)
+	current.presenter, current.user, current.connected = socketID, user, true
+	payload := presentationPayload(roomID, current)
+	presentationsMutex.Unlock()
+
+	emit(roomID, payload)
+	return true
+}
+
+// sendPresentation tells a joiner about the room's presentation, if any, so
+// it can follow the presenter right away.
+func sendPresentation(socket *socketio.Socket, roomID string) {
+	presentationsMutex.Lock()
+	current := presentations[roomID]
+	if current == nil {
+		presentationsMutex.Unlock()
+		return
+	}
+	payload := presentationPayload(roomID, current)
+	presentationsMutex.Unlock()
+
+	_ = socket.Emit("presentation-state", payload)
+}
+
+// expirePresentation ends a presentation whose presenter didn't come back,
+// unless it did or someone else took over since.
+func expirePresentation(roomID string, expired *presentation, emit presentationEmitter) {
+	presentationsMutex.Lock()
+	if presentations[roomID] != expired || expired.connected {
+		presentationsMutex.Unlock()
+		return
+	}
+	delete(presentations, roomID)
+	presentationsMutex.Unlock()
+
+	emit(roomID, presentationPayload(roomID, nil))
+}
+
+func clearPresentation(roomID string) {
+	presentationsMutex.Lock()
+	defer presentationsMutex.Unlock()
+	if current := presentations[roomID]; current != nil {
+		stopPresenterTimer(current)
+		delete(presentations, roomID)
+	}
+}
+
+// stopPresenterTimer stops waiting for a presenter to come back. Callers
+// hold presentationsMutex.
+func stopPresenterTimer(current *presentation) {
+	if current.timer != nil {
+		current.timer.Stop()
+		current.timer = nil
+	}
+}
+
+func roomPresentationEmitter(srv *socketio.Server) presentationEmitter {
+	return func(roomID string, payload map[string]any) {
+		_ = srv.To(socketio.Room(roomID)).Emit("presentation-state", payload)
+	}
+}
+
+// presentationPayload is presentation-state { roomId, presenter, connected,
+// frameId?, slide? }, with a nil presenter once the presentation ended.
+// Callers hold presentationsMutex.
+func presentationPayload(roomID string, current *presentation) map[string]any {
+	payload := map[string]any{"roomId": roomID, "presenter": nil}
+	if current == nil {
+		return payload
+	}
+	payload["presenter"] = PresenceEntry{SocketID: string(current.presenter), User: current.user}
+	payload["connected"] = current.connected
+	if current.frameID != "" {
+		payload["frameId"] = current.frameID
+	}
+	if current.slide >= 0 {
+		payload["slide"] = current.slide
+	}
+	return payload
+}
@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"testing"
+)
+
+func recordPresentation(events *[]map[string]any) presentationEmitter {
+	return func(_ string, payload map[string]any) {
+		*events = append(*events, payload)
+	}
+}
+
+func presenterOf(payload map[string]any) string {
+	entry, ok := payload["presenter"].(PresenceEntry)
+	if !ok {
+		return ""
+	}
+	return entry.SocketID
+}
+
+func TestUpdatePresentation(t *testing.T) {
+	defer clearPresentation("present-room")
+	var events []map[string]any
+	emit := recordPresentation(&events)
+
+	start := presentationRequest{roomID: "present-room", presenting: true, frameID: "frame-1", slide: -1}
+	if err := updatePresentation(start, "alice", nil, false, emit); err != nil {
+		t.Fatalf("updatePresentation() error = %v", err)
+	}
+	next := presentationRequest{roomID: "present-room", presenting: true, slide: 2}
+	if err := updatePresentation(next, "alice", nil, false, emit); err != nil {
+		t.Fatalf("updatePresentation() of the slide error = %v", err)
+	}
+	if last := events[len(events)-1]; presenterOf(last) != "alice" || last["frameId"] != "frame-1" || last["slide"] != 2 {
+		t.Errorf("presentation-state = %v, want alice on frame-1, slide 2", last)
+	}
+
+	if err := updatePresentation(start, "bob", nil, false, emit); err != errPresentationTaken {
+		t.Errorf("updatePresentation() by another socket = %v, want errPresentationTaken", err)
+	}
+	stop := presentationRequest{roomID: "present-room", slide: -1}
+	if err := updatePresentation(stop, "bob", nil, false, emit); err != errNotPresenter {
+		t.Errorf("stopping by another socket = %v, want errNotPresenter", err)
+	}
+	if err := updatePresentation(start, "owner", nil, true, emit); err != nil {
+		t.Errorf("updatePresentation() by the owner = %v, want a take-over", err)
+	}
+	if last := events[len(events)-1]; presenterOf(last) != "owner" || last["slide"] != 2 {
+		t.Errorf("presentation-state after take-over = %v, want owner keeping slide 2", last)
+	}
+
+	if err := updatePresentation(stop, "owner", nil, false, emit); err != nil {
+		t.Fatalf("stopping error = %v", err)
+	}
+	if last := events[len(events)-1]; last["presenter"] != nil {
+		t.Errorf("presentation-state after stopping = %v, want no presenter", last)
+	}
+}
+
+func TestPresenterReconnects(t *testing.T) {
+	defer clearPresentation("present-room")
+	var events []map[string]any
+	emit := recordPresentation(&events)
+	alice := &UserInfo{ID: "alice"}
+
+	_ = updatePresentation(presentationRequest{roomID: "present-room", presenting: true, slide: 1}, "s1", alice, false, emit)
+	presenterLeft("present-room", "s1", true, emit)
+	if last := events[len(events)-1]; presenterOf(last) != "s1" || last["connected"] != false {
+		t.Errorf("presentation-state after leaving = %v, want s1 disconnected", last)
+	}
+
+	if reclaimPresentation("present-room", "s2", &UserInfo{ID: "bob"}, "", emit) {
+		t.Error("another user reclaimed the presentation")
+	}
+	if !reclaimPresentation("present-room", "s2", alice, "", emit) {
+		t.Fatal("the same user didn't reclaim the presentation")
+	}
+	if last := events[len(events)-1]; presenterOf(last) != "s2" || last["connected"] != true || last["slide"] != 1 {
+		t.Errorf("presentation-state after reclaiming = %v, want s2 on slide 1", last)
+	}
+
+	presenterLeft("present-room", "s2", true, emit)
+	if !reclaimPresentation("present-room", "s3", nil, "s2", emit) {
+		t.Error("the socket resuming s2 didn't reclaim the presentation")
+	}
+	if reclaimPresentation("present-room", "s4", nil, "s2", emit) {
+		t.Error("a connected presentation was reclaimed")
+	}
+}
+
+func TestPresentationExpires(t *testing.T) {
+	defer clearPresentation("present-room")
+	var events []map[string]any
+	emit := recordPresentation(&events)
+
+	_ = updatePresentation(presentationRequest{roomID: "present-room", presenting: true, slide: -1}, "s1", nil, false, emit)
+	presenterLeft("present-room", "s1", true, emit)
+	presentationsMutex.Lock()
+	waiting := presentations["present-room"]
+	presentationsMutex.Unlock()
+
+	expirePresentation("present-room", waiting, emit)
+	if last := events[len(events)-1]; last["presenter"] != nil {
+		t.Errorf("presentation-state after the grace period = %v, want no presenter", last)
+	}
+
+	_ = updatePresentation(presentationRequest{roomID: "present-room", presenting: true, slide: -1}, "s1", nil, false, emit)
+	presenterLeft("present-room", "s1", false, emit)
+	if last := events[len(events)-1]; last["presenter"] != nil {
+		t.Errorf("presentation-state after removal = %v, want no presenter", last)
+	}
+}
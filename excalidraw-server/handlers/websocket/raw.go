@@ -0,0 +1,504 @@
+package websocket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/abuse"
+	"excalidraw-server/apierror"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	ws "github.com/gorilla/websocket"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const (
+	// rawSendBuffer is how many frames a native WebSocket peer may fall
+	// behind by before volatile frames are dropped and others close the
+	// connection.
+	rawSendBuffer = 256
+	// rawWriteTimeout bounds how long a frame may take to reach a peer.
+	rawWriteTimeout = 10 * time.Second
+)
+
+// rawFrame is a JSON text frame of the native room protocol. Frames a peer
+// sends may carry an id, which the server's answer to it repeats.
+type rawFrame struct {
+	Type string          `json:"type"`
+	ID   json.RawMessage `json:"id,omitempty"`
+	Data any             `json:"data,omitempty"`
+}
+
+type rawClientFrame struct {
+	Type string          `json:"type"`
+	ID   json.RawMessage `json:"id,omitempty"`
+	Data json.RawMessage `json:"data"`
+}
+
+// rawBroadcast is the data of server-broadcast and client-broadcast frames.
+type rawBroadcast struct {
+	Payload  any   `json:"payload"`
+	Metadata any   `json:"metadata,omitempty"`
+	Seq      int64 `json:"seq,omitempty"`
+}
+
+// rawPeer is a member of a room connected to the native WebSocket endpoint
+// rather than Socket.IO. Its id stands in for a socket id in presence,
+// stats and mutes.
+type rawPeer struct {
+	id      socketio.SocketId
+	user    *UserInfo
+	address string
+	conn    *ws.Conn
+
+	out       chan rawFrame
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// held are the volatile frames coalesced while the peer was behind,
+	// latest per sender; wake tells the write loop there are some.
+	held      map[socketio.SocketId]rawFrame
+	heldMutex sync.Mutex
+	wake      chan struct{}
+}
+
+var (
+	// rawRooms are the native WebSocket peers of each room on this
+	// instance. Lock roomsMutex first when both are needed.
+	rawRooms      = make(map[string]map[*rawPeer]struct{})
+	rawRoomsMutex sync.RWMutex
+)
+
+// HandleRawSocket serves rooms over plain WebSocket on /{roomId}, for bots
+// and custom clients that don't want a Socket.IO stack. Peers join the room
+// on connect and take part like collab sockets: they get presence, chat and
+// the broadcasts of every other member, and their broadcasts reach Socket.IO
+// members in turn. Frames are JSON text of the form {type, id?, data};
+// binary payloads, such as the ciphertext of encrypted rooms, are base64.
+// Clients authenticate like CRDT sync, and ?encrypted=true makes a new room
+// encrypted.
+func HandleRawSocket(srv *socketio.Server, authOpts AuthOptions) http.HandlerFunc {
+	upgrader := ws.Upgrader{CheckOrigin: allowedWebSocketOrigin}
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := chi.URLParam(r, "roomId")
+		user, ok := authorizeRoomRequest(w, r, roomID, authOpts)
+		if !ok {
+			return
+		}
+		requested := RoomModePlain
+		if r.URL.Query().Get("encrypted") == "true" {
+			requested = RoomModeEncrypted
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already replied
+			return
+		}
+		conn.SetReadLimit(MaxMessageSize)
+
+		peer := &rawPeer{
+			id:      socketio.SocketId("ws-" + ulid.Make().String()),
+			user:    user,
+			address: r.RemoteAddr,
+			conn:    conn,
+			out:     make(chan rawFrame, rawSendBuffer),
+			done:    make(chan struct{}),
+			wake:    make(chan struct{}, 1),
+		}
+		go peer.writeLoop()
+
+		// Subscribe first, so the peer sees its own join in the presence
+		events, unsubscribe := SubscribeRoom(roomID)
+		go func() {
+			for event := range events {
+				peer.send(rawFrame{Type: event.Name, Data: event.Data}, false)
+			}
+			// The peer fell behind the room's events
+			peer.close()
+		}()
+		// Joins, leaves and broadcasts run on the room's worker, in order
+		runInRoom(roomID, func() {
+			joinRawRoom(srv, roomID, peer, requested)
+		})
+		defer func() {
+			unsubscribe()
+			peer.close()
+			runInRoom(roomID, func() {
+				leaveRawRoom(srv, roomID, peer)
+			})
+		}()
+
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				if errors.Is(err, ws.ErrReadLimit) {
+					reportViolation(peer.user, peer.address, abuse.OversizedPayload)
+				}
+				return
+			}
+			if messageType != ws.TextMessage {
+				continue
+			}
+			var frame rawClientFrame
+			if err := json.Unmarshal(message, &frame); err != nil {
+				peer.fail(rawFrame{Type: "error"}, &payloadError{"frame", "must be a JSON object"})
+				continue
+			}
+			switch frame.Type {
+			case "server-broadcast", "server-volatile-broadcast":
+				volatile := frame.Type == "server-volatile-broadcast"
+				runInRoom(roomID, func() {
+					handleRawBroadcast(srv, roomID, peer, frame, volatile)
+				})
+			default:
+				peer.fail(rawFrame{Type: "error", ID: frame.ID}, &payloadError{"type", "is not a known frame type"})
+			}
+		}
+	}
+}
+
+// joinRawRoom adds a peer to a room and sends it joined, followed by what
+// the room has so far, like joinRoom does for sockets.
+func joinRawRoom(srv *socketio.Server, roomID string, peer *rawPeer, requested RoomMode) {
+	rawRoomsMutex.Lock()
+	if rawRooms[roomID] == nil {
+		rawRooms[roomID] = make(map[*rawPeer]struct{})
+	}
+	rawRooms[roomID][peer] = struct{}{}
+	rawRoomsMutex.Unlock()
+	trackJoin(roomID, peer.id, peer.user, time.Now())
+
+	srv.In(socketio.Room(roomID)).FetchSockets()(func(users []*socketio.RemoteSocket, _ error) {
+		roomsMutex.Lock()
+		members := roomMembers(roomID, len(users))
+		activeRooms[roomID] = members
+		roomsMutex.Unlock()
+		publishPresence(clusterJoin, roomID, peer.id, peer.user, members)
+
+		mode := initRoomMode(roomID, requested, members <= 1)
+		wakeRoom(roomID, members <= 1)
+		peer.send(rawFrame{Type: "joined", Data: map[string]any{
+			"socketId":  peer.id,
+			"mode":      mode,
+			"userCount": members,
+			"seq":       currentBroadcastSeq(roomID),
+		}}, false)
+		if history := getChatHistory(roomID); len(history) > 0 {
+			peer.send(rawFrame{Type: "chat-history", Data: history}, false)
+		}
+		if scene, ok := lastSceneInit(roomID); ok {
+			peer.send(rawFrame{Type: "scene-init", Data: rawBroadcast{
+				Payload:  rawValue(scene.payload),
+				Metadata: rawValue(scene.metadata),
+			}}, false)
+		}
+		emitPresence(srv, roomID, users)
+	})
+}
+
+// leaveRawRoom removes a peer from a room, releasing the room once nobody is
+// left in it.
+func leaveRawRoom(srv *socketio.Server, roomID string, peer *rawPeer) {
+	rawRoomsMutex.Lock()
+	delete(rawRooms[roomID], peer)
+	if len(rawRooms[roomID]) == 0 {
+		delete(rawRooms, roomID)
+	}
+	rawRoomsMutex.Unlock()
+	trackLeave(roomID, peer.id)
+
+	srv.In(socketio.Room(roomID)).FetchSockets()(func(users []*socketio.RemoteSocket, _ error) {
+		persist := func() {}
+		roomsMutex.Lock()
+		members := roomMembers(roomID, len(users))
+		if members == 0 {
+			delete(activeRooms, roomID)
+			persist = releaseRoom(roomID)
+		} else {
+			activeRooms[roomID] = members
+		}
+		roomsMutex.Unlock()
+		persist()
+		publishPresence(clusterLeave, roomID, peer.id, peer.user, members)
+		emitPresence(srv, roomID, users)
+	})
+}
+
+// roomMembers counts a room's Socket.IO sockets and native WebSocket peers.
+// Callers hold roomsMutex.
+func roomMembers(roomID string, sockets int) int {
+	rawRoomsMutex.RLock()
+	defer rawRoomsMutex.RUnlock()
+	return sockets + len(rawRooms[roomID])
+}
+
+// rawPresence lists the native WebSocket peers of a room in the order they
+// joined, which their ULIDs sort in.
+func rawPresence(roomID string) []PresenceEntry {
+	rawRoomsMutex.RLock()
+	presence := make([]PresenceEntry, 0, len(rawRooms[roomID]))
+	for peer := range rawRooms[roomID] {
+		presence = append(presence, PresenceEntry{SocketID: string(peer.id), User: peer.user})
+	}
+	rawRoomsMutex.RUnlock()
+
+	sort.Slice(presence, func(i, j int) bool { return presence[i].SocketID < presence[j].SocketID })
+	return presence
+}
+
+// handleRawBroadcast relays a peer's server-broadcast to the rest of the
+// room, like handleBroadcast, and answers with a broadcast-ack frame.
+func handleRawBroadcast(srv *socketio.Server, roomID string, peer *rawPeer, frame rawClientFrame, volatile bool) {
+	received := time.Now()
+	ack := func(data map[string]any) {
+		// Volatile broadcasts are only answered when they fail
+		if !volatile || data["status"] != "ok" {
+			peer.send(rawFrame{Type: "broadcast-ack", ID: frame.ID, Data: data}, false)
+		}
+	}
+	if err := checkMessageSize(roomID, broadcastKind(volatile), len(frame.Data)); err != nil {
+		peer.fail(rawFrame{Type: "broadcast-ack", ID: frame.ID}, err)
+		return
+	}
+	payload, metadata, err := decodeRawBroadcast(roomID, frame.Data)
+	if err != nil {
+		peer.fail(rawFrame{Type: "broadcast-ack", ID: frame.ID}, err)
+		return
+	}
+	wakeRoom(roomID, false)
+
+	if !volatile && isMuted(roomID, peer.id) {
+		ack(errorAckPayload(apierror.New(apierror.Muted, "muted in room")))
+		return
+	}
+
+	relay := func(payload any, received time.Time) (int64, error) {
+		seq, err := sequenceBroadcast(roomID, func(seq int64) error {
+			sendRawBroadcast(roomID, peer.id, payload, metadata, seq, volatile)
+			var lagging []socketio.Room
+			if volatile {
+				lagging = holdVolatile(roomID, peer.id, heldBroadcast{scene: payload, metadata: metadata, seq: seq})
+			}
+			return relayBroadcast(func() *socketio.BroadcastOperator {
+				if volatile {
+					return srv.Local().Volatile().Except(lagging...)
+				}
+				return srv.Local()
+			}, roomID, payload, nil, metadata, seq)
+		})
+		if err != nil {
+			return seq, err
+		}
+		trackRelayLatency(roomID, time.Since(received))
+
+		trackBroadcast(roomID, payloadSize(payload), time.Now())
+		if !volatile {
+			recordBroadcast(roomID, string(peer.id), payload)
+			keepSceneInit(roomID, payload, nil, metadata)
+		}
+		publishClusterEvent(clusterEvent{
+			Type:     clusterBroadcast,
+			RoomID:   roomID,
+			SocketID: string(peer.id),
+			Payload:  payload,
+			Metadata: metadata,
+			Volatile: volatile,
+		})
+		return seq, nil
+	}
+	respond := func(seq int64, err error) {
+		if err != nil {
+			ack(errorAckPayload(err))
+			return
+		}
+		response := makeBroadcastAckPayload(payload, nil)
+		response["seq"] = seq
+		ack(response)
+	}
+
+	if !volatile && coalesceBroadcast(roomID, peer.id, payload, metadata, func(update any) (int64, error) {
+		return relay(update, time.Now())
+	}, respond) {
+		return
+	}
+	respond(relay(payload, received))
+}
+
+// decodeRawBroadcast reads the payload and metadata of a server-broadcast
+// frame. Encrypted rooms relay binary, which the frame carries as base64.
+func decodeRawBroadcast(roomID string, data json.RawMessage) (payload, metadata any, err error) {
+	var request rawBroadcast
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, nil, &payloadError{"data", "must be an object"}
+	}
+	if request.Payload == nil {
+		return nil, nil, &payloadError{"payload", "is required"}
+	}
+	payload, metadata = request.Payload, request.Metadata
+
+	if IsEncryptedRoom(roomID) {
+		if payload, err = decodeRawBinary(payload, "payload"); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := metadata.(string); ok {
+			if metadata, err = decodeRawBinary(metadata, "metadata"); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	switch metadata.(type) {
+	case nil, []byte, map[string]any, []any:
+	default:
+		return nil, nil, &payloadError{"metadata", "must be binary, an object or an array"}
+	}
+	return payload, metadata, nil
+}
+
+func decodeRawBinary(value any, field string) ([]byte, error) {
+	encoded, ok := value.(string)
+	if !ok {
+		return nil, &payloadError{field, "must be base64 in an encrypted room"}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, &payloadError{field, "must be base64 in an encrypted room"}
+	}
+	return decoded, nil
+}
+
+// sendRawBroadcast sends a broadcast to the native WebSocket peers of a
+// room but its sender.
+func sendRawBroadcast(roomID string, sender socketio.SocketId, payload, metadata any, seq int64, volatile bool) {
+	rawRoomsMutex.RLock()
+	defer rawRoomsMutex.RUnlock()
+	if len(rawRooms[roomID]) == 0 {
+		return
+	}
+	frame := rawFrame{Type: "client-broadcast", Data: rawBroadcast{
+		Payload:  rawValue(payload),
+		Metadata: rawValue(metadata),
+		Seq:      seq,
+	}}
+	for peer := range rawRooms[roomID] {
+		switch {
+		case peer.id == sender:
+		case volatile:
+			peer.sendVolatile(sender, frame)
+		default:
+			peer.send(frame, false)
+		}
+	}
+}
+
+// rawValue turns Socket.IO binary attachments into bytes, which encode as
+// base64.
+func rawValue(value any) any {
+	if binary, ok := value.(binaryArg); ok {
+		return binary.Bytes()
+	}
+	return value
+}
+
+// send queues a frame for the peer. A peer too far behind drops volatile
+// frames and is disconnected by any other.
+func (peer *rawPeer) send(frame rawFrame, volatile bool) {
+	select {
+	case peer.out <- frame:
+	case <-peer.done:
+	default:
+		if !volatile {
+			logrus.WithField("socket_id", peer.id).Debug("Disconnecting a native WebSocket peer that fell behind")
+			peer.close()
+		}
+	}
+}
+
+// sendVolatile queues a volatile frame from sender. A peer that fell behind
+// gets only the latest of each sender's volatile frames, once it caught up.
+func (peer *rawPeer) sendVolatile(sender socketio.SocketId, frame rawFrame) {
+	peer.heldMutex.Lock()
+	// Once one is held, later ones are too, so they can't overtake it
+	if len(peer.held) == 0 && len(peer.out) < rawVolatileBacklog {
+		peer.heldMutex.Unlock()
+		peer.send(frame, true)
+		return
+	}
+	if peer.held == nil {
+		peer.held = make(map[socketio.SocketId]rawFrame)
+	}
+	peer.held[sender] = frame
+	peer.heldMutex.Unlock()
+
+	select {
+	case peer.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (peer *rawPeer) writeLoop() {
+	for {
+		select {
+		case <-peer.done:
+			return
+		case frame := <-peer.out:
+			if !peer.write(frame) {
+				return
+			}
+		case <-peer.wake:
+		}
+		if len(peer.out) > 0 {
+			continue
+		}
+		for _, frame := range peer.takeHeld() {
+			if !peer.write(frame) {
+				return
+			}
+		}
+	}
+}
+
+// takeHeld returns the frames held for the peer, if it caught up.
+func (peer *rawPeer) takeHeld() map[socketio.SocketId]rawFrame {
+	peer.heldMutex.Lock()
+	defer peer.heldMutex.Unlock()
+	held := peer.held
+	peer.held = nil
+	return held
+}
+
+func (peer *rawPeer) write(frame rawFrame) bool {
+	_ = peer.conn.SetWriteDeadline(time.Now().Add(rawWriteTimeout))
+	if err := peer.conn.WriteJSON(frame); err != nil {
+		peer.close()
+		return false
+	}
+	return true
+}
+
+// fail answers a frame with an error, counting the error against the peer's
+// client when it is a violation. Once that is banned, the peer is closed
+// with a policy violation.
+func (peer *rawPeer) fail(frame rawFrame, err error) {
+	frame.Data = errorAckPayload(err)
+	peer.send(frame, false)
+	if violation, ok := violationOf(err); ok && reportViolation(peer.user, peer.address, violation) {
+		message := ws.FormatCloseMessage(ws.ClosePolicyViolation, "temporarily banned")
+		_ = peer.conn.WriteControl(ws.CloseMessage, message, time.Now().Add(rawWriteTimeout))
+		peer.close()
+	}
+}
+
+// close ends the connection, which ends the peer's read loop.
+func (peer *rawPeer) close() {
+	peer.closeOnce.Do(func() {
+		close(peer.done)
+		_ = peer.conn.Close()
+	})
+}
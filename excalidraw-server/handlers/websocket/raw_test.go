@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	ws "github.com/gorilla/websocket"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+type testRawFrame struct {
+	Type string          `json:"type"`
+	ID   json.RawMessage `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+func dialRaw(t *testing.T, server *httptest.Server, roomID string) *ws.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/rooms/" + roomID
+	conn, _, err := ws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// expectRaw reads frames until one of the given type arrives.
+func expectRaw(t *testing.T, conn *ws.Conn, frameType string) testRawFrame {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var frame testRawFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("waiting for %s: %v", frameType, err)
+		}
+		if frame.Type == frameType {
+			return frame
+		}
+	}
+}
+
+func TestHandleRawSocket(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/ws/rooms/{roomId}", HandleRawSocket(socketio.NewServer(nil, nil), AuthOptions{Mode: AuthOff}))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	alice := dialRaw(t, server, "raw-room")
+	joined := expectRaw(t, alice, "joined")
+	var info struct {
+		SocketID  string   `json:"socketId"`
+		Mode      RoomMode `json:"mode"`
+		UserCount int      `json:"userCount"`
+	}
+	if err := json.Unmarshal(joined.Data, &info); err != nil || !strings.HasPrefix(info.SocketID, "ws-") || info.Mode != RoomModePlain || info.UserCount != 1 {
+		t.Fatalf("joined = %s, want a plain room with one member", joined.Data)
+	}
+
+	bob := dialRaw(t, server, "raw-room")
+	expectRaw(t, bob, "joined")
+	for {
+		var presence []PresenceEntry
+		if err := json.Unmarshal(expectRaw(t, alice, "room-presence").Data, &presence); err != nil {
+			t.Fatalf("invalid presence: %v", err)
+		}
+		if len(presence) == 2 {
+			if presence[0].SocketID != info.SocketID {
+				t.Errorf("presence = %+v, want alice first", presence)
+			}
+			break
+		}
+	}
+
+	scene := `{"payload":{"elements":[]},"type":"SCENE_INIT"}`
+	if err := bob.WriteMessage(ws.TextMessage, []byte(`{"type":"server-broadcast","id":7,"data":{"payload":`+scene+`}}`)); err != nil {
+		t.Fatalf("WriteMessage() failed: %v", err)
+	}
+	ack := expectRaw(t, bob, "broadcast-ack")
+	if string(ack.ID) != "7" || string(ack.Data) != `{"seq":1,"status":"ok"}` {
+		t.Errorf("ack = %s %s, want id 7 with seq 1", ack.ID, ack.Data)
+	}
+	if got := expectRaw(t, alice, "client-broadcast"); string(got.Data) != `{"payload":`+scene+`,"seq":1}` {
+		t.Errorf("client-broadcast = %s", got.Data)
+	}
+
+	// A late joiner starts from the last full scene
+	carol := dialRaw(t, server, "raw-room")
+	if got := expectRaw(t, carol, "scene-init"); string(got.Data) != `{"payload":`+scene+`}` {
+		t.Errorf("scene-init = %s", got.Data)
+	}
+
+	for _, message := range []string{
+		`{"type":"server-broadcast","id":"a","data":{}}`,
+		`{"type":"server-broadcast","id":"b","data":{"payload":{},"metadata":"iv"}}`,
+		`{"type":"nope","id":"c"}`,
+	} {
+		if err := bob.WriteMessage(ws.TextMessage, []byte(message)); err != nil {
+			t.Fatalf("WriteMessage() failed: %v", err)
+		}
+	}
+	for _, frameType := range []string{"broadcast-ack", "broadcast-ack", "error"} {
+		frame := expectRaw(t, bob, frameType)
+		if !strings.Contains(string(frame.Data), `"code":"invalid_payload"`) {
+			t.Errorf("%s %s = %s, want invalid_payload", frameType, frame.ID, frame.Data)
+		}
+	}
+
+	_ = alice.Close()
+	_ = bob.Close()
+	_ = carol.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for isLiveRoom("raw-room") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if isLiveRoom("raw-room") {
+		t.Error("room still live after its peers left")
+	}
+}
@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"excalidraw-server/apierror"
+	"sync"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// canvasReactionInterval is how soon after a canvas reaction a socket may
+// send the next one
+const canvasReactionInterval = 200 * time.Millisecond
+
+var (
+	// lastCanvasReaction is when each socket last sent a canvas reaction
+	lastCanvasReaction      = make(map[socketio.SocketId]time.Time)
+	lastCanvasReactionMutex sync.Mutex
+)
+
+// handleCanvasReaction relays server-reaction(roomId, { x, y, emoji }) to the
+// rest of the room as a volatile client-reaction { roomId, socketId, user, x,
+// y, emoji }: a ping at a point of the canvas that doesn't touch the scene.
+func handleCanvasReaction(socket *socketio.Socket, datas []any) {
+	ack, args := extractAck(datas)
+	request, err := decodeCanvasReaction(args)
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	roomID := request.roomID
+
+	switch {
+	case !socket.Rooms().Has(socketio.Room(roomID)):
+		err = apierror.Errorf(apierror.NotInRoom, "not in room %s", roomID)
+	case isMuted(roomID, socket.Id()):
+		err = apierror.New(apierror.Muted, "muted in room")
+	case !allowCanvasReaction(socket.Id(), time.Now()):
+		err = apierror.New(apierror.TooManyReactions, "too many reactions")
+	}
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+
+	emitErr := socket.Volatile().To(socketio.Room(roomID)).Emit("client-reaction", map[string]any{
+		"roomId":   roomID,
+		"socketId": string(socket.Id()),
+		"user":     socketUser(socket),
+		"x":        request.x,
+		"y":        request.y,
+		"emoji":    request.emoji,
+	})
+	if emitErr != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(emitErr), emitErr)
+		return
+	}
+	respondWithAck(socket, ack, "", map[string]any{"status": "ok"}, nil)
+}
+
+// allowCanvasReaction reports whether a socket may send a canvas reaction at
+// now, at most one per canvasReactionInterval.
+func allowCanvasReaction(socketID socketio.SocketId, now time.Time) bool {
+	lastCanvasReactionMutex.Lock()
+	defer lastCanvasReactionMutex.Unlock()
+
+	if last, exists := lastCanvasReaction[socketID]; exists && now.Sub(last) < canvasReactionInterval {
+		return false
+	}
+	lastCanvasReaction[socketID] = now
+	return true
+}
+
+func forgetCanvasReactions(socketID socketio.SocketId) {
+	lastCanvasReactionMutex.Lock()
+	defer lastCanvasReactionMutex.Unlock()
+	delete(lastCanvasReaction, socketID)
+}
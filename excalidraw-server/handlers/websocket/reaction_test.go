@@ -0,0 +1,26 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowCanvasReaction(t *testing.T) {
+	defer forgetCanvasReactions("pinger")
+	now := time.Now()
+
+	if !allowCanvasReaction("pinger", now) {
+		t.Fatal("first reaction was refused")
+	}
+	if allowCanvasReaction("pinger", now.Add(canvasReactionInterval/2)) {
+		t.Error("reaction within canvasReactionInterval was allowed")
+	}
+	if !allowCanvasReaction("pinger", now.Add(canvasReactionInterval)) {
+		t.Error("reaction after canvasReactionInterval was refused")
+	}
+
+	forgetCanvasReactions("pinger")
+	if !allowCanvasReaction("pinger", now.Add(canvasReactionInterval)) {
+		t.Error("reaction after forgetting the socket was refused")
+	}
+}
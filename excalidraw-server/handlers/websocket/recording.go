@@ -0,0 +1,127 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/apierror"
+	"excalidraw-server/core"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// activeRecording is a recording in progress in a room.
+type activeRecording struct {
+	id        string
+	startedAt time.Time
+}
+
+var (
+	recordingStore   core.RecordingStore
+	activeRecordings = make(map[string]*activeRecording)
+	recordingsMutex  sync.Mutex
+)
+
+// SetRecordingStore sets where room recordings are persisted; nil disables
+// recording.
+func SetRecordingStore(store core.RecordingStore) {
+	recordingsMutex.Lock()
+	defer recordingsMutex.Unlock()
+	recordingStore = store
+}
+
+// StartRecording starts capturing the server-broadcast events of a room.
+// Encrypted rooms can't be recorded since the server never persists their
+// frames.
+func StartRecording(ctx context.Context, roomID string) (string, error) {
+	if IsEncryptedRoom(roomID) {
+		return "", apierror.Errorf(apierror.RoomEncrypted, "room %s is end-to-end encrypted", roomID)
+	}
+
+	recordingsMutex.Lock()
+	defer recordingsMutex.Unlock()
+
+	if recordingStore == nil {
+		return "", apierror.New(apierror.NotImplemented, "recording is not enabled")
+	}
+	if _, exists := activeRecordings[roomID]; exists {
+		return "", core.ErrRecordingActive
+	}
+
+	startedAt := time.Now()
+	id, err := recordingStore.CreateRecording(ctx, roomID, startedAt)
+	if err != nil {
+		return "", err
+	}
+	activeRecordings[roomID] = &activeRecording{id: id, startedAt: startedAt}
+	return id, nil
+}
+
+// StopRecording ends the recording in progress in a room and returns its id.
+func StopRecording(ctx context.Context, roomID string) (string, error) {
+	recordingsMutex.Lock()
+	defer recordingsMutex.Unlock()
+
+	return stopRecordingLocked(ctx, roomID)
+}
+
+func stopRecordingLocked(ctx context.Context, roomID string) (string, error) {
+	recording, exists := activeRecordings[roomID]
+	if !exists {
+		return "", core.ErrNotRecording
+	}
+	delete(activeRecordings, roomID)
+	return recording.id, recordingStore.FinishRecording(ctx, recording.id, time.Now())
+}
+
+// ActiveRecording returns the id of the recording in progress in a room.
+func ActiveRecording(roomID string) (string, bool) {
+	recordingsMutex.Lock()
+	defer recordingsMutex.Unlock()
+
+	recording, exists := activeRecordings[roomID]
+	if !exists {
+		return "", false
+	}
+	return recording.id, true
+}
+
+// recordBroadcast appends a relayed server-broadcast to the room's recording,
+// if one is in progress.
+func recordBroadcast(roomID, socketID string, payload any) {
+	recordingsMutex.Lock()
+	defer recordingsMutex.Unlock()
+
+	recording, exists := activeRecordings[roomID]
+	if !exists {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logrus.WithField("recording_id", recording.id).WithError(err).Warn("Failed to encode broadcast for recording")
+		return
+	}
+	event := core.RecordingEvent{
+		Offset:   time.Since(recording.startedAt).Milliseconds(),
+		SocketID: socketID,
+		Payload:  data,
+	}
+	if err := recordingStore.AppendRecordingEvent(context.Background(), recording.id, event); err != nil {
+		logrus.WithField("recording_id", recording.id).WithError(err).Error("Failed to record broadcast")
+	}
+}
+
+// finishRoomRecording ends the recording of a room that has become empty.
+func finishRoomRecording(roomID string) {
+	recordingsMutex.Lock()
+	defer recordingsMutex.Unlock()
+
+	if _, exists := activeRecordings[roomID]; !exists {
+		return
+	}
+	if _, err := stopRecordingLocked(context.Background(), roomID); err != nil {
+		logrus.WithField("room_id", roomID).WithError(err).Error("Failed to finish recording")
+	}
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"testing"
+)
+
+func resetRecordings(store core.RecordingStore) {
+	recordingsMutex.Lock()
+	activeRecordings = make(map[string]*activeRecording)
+	recordingsMutex.Unlock()
+	SetRecordingStore(store)
+}
+
+func TestRecording_CapturesBroadcasts(t *testing.T) {
+	store := memory.NewDocumentStore().(core.RecordingStore)
+	resetRecordings(store)
+	defer resetRecordings(nil)
+	ctx := context.Background()
+
+	recordBroadcast("room", "socket-a", map[string]any{"ignored": true})
+
+	id, err := StartRecording(ctx, "room")
+	if err != nil {
+		t.Fatalf("StartRecording() failed: %v", err)
+	}
+	if _, err := StartRecording(ctx, "room"); !errors.Is(err, core.ErrRecordingActive) {
+		t.Errorf("second StartRecording() error = %v, want ErrRecordingActive", err)
+	}
+	recordBroadcast("room", "socket-a", map[string]any{"type": "SCENE_UPDATE"})
+	recordBroadcast("other-room", "socket-b", map[string]any{"type": "SCENE_UPDATE"})
+
+	if stopped, err := StopRecording(ctx, "room"); err != nil || stopped != id {
+		t.Fatalf("StopRecording() = %q, %v", stopped, err)
+	}
+	recordBroadcast("room", "socket-a", map[string]any{"type": "SCENE_UPDATE"})
+
+	events, err := store.GetRecordingEvents(ctx, id)
+	if err != nil {
+		t.Fatalf("GetRecordingEvents() failed: %v", err)
+	}
+	if len(events) != 1 || events[0].SocketID != "socket-a" || string(events[0].Payload) != `{"type":"SCENE_UPDATE"}` {
+		t.Errorf("events = %+v", events)
+	}
+	recording, _ := store.GetRecording(ctx, id)
+	if recording.EndedAt == 0 {
+		t.Error("expected recording to be finished")
+	}
+}
+
+func TestRecording_FinishedWhenRoomEmpties(t *testing.T) {
+	store := memory.NewDocumentStore().(core.RecordingStore)
+	resetRecordings(store)
+	defer resetRecordings(nil)
+
+	id, err := StartRecording(context.Background(), "room")
+	if err != nil {
+		t.Fatalf("StartRecording() failed: %v", err)
+	}
+	releaseRoom("room")()
+
+	if _, active := ActiveRecording("room"); active {
+		t.Error("expected recording to stop when the room empties")
+	}
+	if recording, _ := store.GetRecording(context.Background(), id); recording.EndedAt == 0 {
+		t.Error("expected recording to be finished")
+	}
+}
+
+func TestRecording_RefusesEncryptedRooms(t *testing.T) {
+	resetRoomModes()
+	resetRecordings(memory.NewDocumentStore().(core.RecordingStore))
+	defer resetRecordings(nil)
+	initRoomMode("room", RoomModeEncrypted, true)
+	defer clearRoomMode("room")
+
+	if _, err := StartRecording(context.Background(), "room"); err == nil {
+		t.Error("expected encrypted rooms to be refused")
+	}
+}
+
+func TestRecording_Disabled(t *testing.T) {
+	resetRecordings(nil)
+
+	if _, err := StartRecording(context.Background(), "room"); err == nil {
+		t.Error("expected an error without a recording store")
+	}
+}
@@ -0,0 +1,134 @@
+package websocket
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RoomMode describes how the server treats broadcast payloads in a room.
+type RoomMode string
+
+const (
+	// RoomModePlain rooms exchange plaintext scene JSON.
+	RoomModePlain RoomMode = "plain"
+	// RoomModeEncrypted rooms exchange end-to-end encrypted frames that the
+	// server relays as opaque ciphertext and never persists.
+	RoomModeEncrypted RoomMode = "encrypted"
+)
+
+var (
+	roomModes      = make(map[string]RoomMode)
+	roomModesMutex sync.RWMutex
+	encryptedOnly  atomic.Bool
+
+	// roomModeStore remembers encrypted rooms after they empty; without one
+	// a room's mode is forgotten with its other state
+	roomModeStore      core.RoomModeStore
+	roomModeStoreMutex sync.RWMutex
+)
+
+// SetRoomModeStore sets where rooms that relayed encrypted frames are
+// remembered. Such rooms stay encrypted: their later sessions are too, and
+// IsEncryptedRoomStored reports them while empty, so nothing stores their
+// scenes.
+func SetRoomModeStore(store core.RoomModeStore) {
+	roomModeStoreMutex.Lock()
+	defer roomModeStoreMutex.Unlock()
+	roomModeStore = store
+}
+
+func getRoomModeStore() core.RoomModeStore {
+	roomModeStoreMutex.RLock()
+	defer roomModeStoreMutex.RUnlock()
+	return roomModeStore
+}
+
+// SetEncryptedOnly forces every room into encrypted relay mode.
+func SetEncryptedOnly(enabled bool) {
+	encryptedOnly.Store(enabled)
+}
+
+// IsEncryptedRoom reports whether a room runs in encrypted relay mode.
+func IsEncryptedRoom(roomID string) bool {
+	return getRoomMode(roomID) == RoomModeEncrypted
+}
+
+func getRoomMode(roomID string) RoomMode {
+	if encryptedOnly.Load() {
+		return RoomModeEncrypted
+	}
+
+	roomModesMutex.RLock()
+	defer roomModesMutex.RUnlock()
+
+	if mode, exists := roomModes[roomID]; exists {
+		return mode
+	}
+	return RoomModePlain
+}
+
+// IsEncryptedRoomStored is IsEncryptedRoom for rooms that may be empty: it
+// also reports rooms the store remembers as encrypted. Handlers that would
+// persist a room's scene use it.
+func IsEncryptedRoomStored(roomID string) bool {
+	roomModesMutex.RLock()
+	mode, live := roomModes[roomID]
+	roomModesMutex.RUnlock()
+	if encryptedOnly.Load() || mode == RoomModeEncrypted {
+		return true
+	}
+	if live {
+		return false
+	}
+	encrypted, err := storedEncrypted(roomID)
+	// rooms the store can't be asked about count as encrypted, so their
+	// scenes aren't stored by mistake
+	return encrypted || err != nil
+}
+
+// initRoomMode fixes the mode of a room when its first socket joins; later
+// joiners inherit whatever mode the room already has. Rooms the store
+// remembers as encrypted stay encrypted, and new encrypted rooms are
+// remembered.
+func initRoomMode(roomID string, requested RoomMode, firstInRoom bool) RoomMode {
+	if firstInRoom {
+		if encrypted, _ := storedEncrypted(roomID); encrypted {
+			requested = RoomModeEncrypted
+		} else if store := getRoomModeStore(); store != nil && requested == RoomModeEncrypted {
+			ctx, cancel := context.WithTimeout(context.Background(), roomStateTimeout)
+			if err := store.MarkRoomEncrypted(ctx, roomID); err != nil {
+				logrus.WithField("room_id", roomID).WithError(err).Error("Failed to remember encrypted room")
+			}
+			cancel()
+		}
+		roomModesMutex.Lock()
+		roomModes[roomID] = requested
+		roomModesMutex.Unlock()
+	}
+	return getRoomMode(roomID)
+}
+
+// storedEncrypted reports whether the store remembers a room as encrypted.
+func storedEncrypted(roomID string) (bool, error) {
+	store := getRoomModeStore()
+	if store == nil {
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), roomStateTimeout)
+	defer cancel()
+	encrypted, err := store.IsRoomEncrypted(ctx, roomID)
+	if err != nil {
+		logrus.WithField("room_id", roomID).WithError(err).Warn("Failed to look up room mode")
+	}
+	return encrypted, err
+}
+
+func clearRoomMode(roomID string) {
+	roomModesMutex.Lock()
+	defer roomModesMutex.Unlock()
+	delete(roomModes, roomID)
+}
@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+)
+
+func resetRoomModes() {
+	roomModesMutex.Lock()
+	roomModes = make(map[string]RoomMode)
+	roomModesMutex.Unlock()
+	SetEncryptedOnly(false)
+}
+
+func TestInitRoomMode_FirstJoinerDecides(t *testing.T) {
+	resetRoomModes()
+
+	if mode := initRoomMode("room", RoomModeEncrypted, true); mode != RoomModeEncrypted {
+		t.Fatalf("expected encrypted room, got %q", mode)
+	}
+	if mode := initRoomMode("room", RoomModePlain, false); mode != RoomModeEncrypted {
+		t.Errorf("later joiners must inherit the room mode, got %q", mode)
+	}
+	if !IsEncryptedRoom("room") {
+		t.Error("expected IsEncryptedRoom to report true")
+	}
+
+	clearRoomMode("room")
+	if IsEncryptedRoom("room") {
+		t.Error("expected mode to be cleared")
+	}
+}
+
+func TestSetEncryptedOnly(t *testing.T) {
+	resetRoomModes()
+	defer SetEncryptedOnly(false)
+
+	SetEncryptedOnly(true)
+	if mode := initRoomMode("room", RoomModePlain, true); mode != RoomModeEncrypted {
+		t.Errorf("expected encrypted-only server to force encrypted mode, got %q", mode)
+	}
+}
+
+// encryptedRooms is a RoomModeStore for tests.
+type encryptedRooms map[string]bool
+
+func (m encryptedRooms) IsRoomEncrypted(_ context.Context, roomID string) (bool, error) {
+	return m[roomID], nil
+}
+
+func (m encryptedRooms) MarkRoomEncrypted(_ context.Context, roomID string) error {
+	m[roomID] = true
+	return nil
+}
+
+func TestRoomModeStore(t *testing.T) {
+	resetRoomModes()
+	store := encryptedRooms{}
+	SetRoomModeStore(store)
+	defer SetRoomModeStore(nil)
+
+	initRoomMode("secret", RoomModeEncrypted, true)
+	initRoomMode("open", RoomModePlain, true)
+	if !store["secret"] || store["open"] {
+		t.Errorf("stored encrypted rooms = %v", store)
+	}
+
+	// Encrypted rooms are still reported once they empty
+	clearRoomMode("secret")
+	clearRoomMode("open")
+	if !IsEncryptedRoomStored("secret") || IsEncryptedRoomStored("open") {
+		t.Error("IsEncryptedRoomStored() forgot which room was encrypted")
+	}
+	if IsEncryptedRoom("secret") {
+		t.Error("IsEncryptedRoom() reported an empty room")
+	}
+
+	// and their later sessions are encrypted too
+	if mode := initRoomMode("secret", RoomModePlain, true); mode != RoomModeEncrypted {
+		t.Errorf("a remembered encrypted room reopened %q", mode)
+	}
+	clearRoomMode("secret")
+}
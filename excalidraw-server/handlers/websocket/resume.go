@@ -0,0 +1,282 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/apierror"
+	"excalidraw-server/sessions"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// sessionTimeout bounds one call to the session store.
+const sessionTimeout = 2 * time.Second
+
+// resumeSession is what a resume token stands for: the rooms the socket had
+// joined, with the options it joined them with, who it was and what it had
+// yet to acknowledge.
+type resumeSession struct {
+	Rooms []resumeRoom `json:"rooms"`
+	User  *UserInfo    `json:"user,omitempty"`
+	// Outbox is what the socket hadn't acknowledged
+	Outbox []outboxEntry `json:"outbox,omitempty"`
+	// SocketID is the socket's id, to take over what it was doing
+	SocketID string `json:"socketId,omitempty"`
+}
+
+type resumeRoom struct {
+	RoomID    string   `json:"roomId"`
+	Encrypted bool     `json:"encrypted,omitempty"`
+	Protocol  int      `json:"protocol"`
+	Encoding  Encoding `json:"encoding"`
+}
+
+// liveSession is the session of a connected socket.
+type liveSession struct {
+	socketID socketio.SocketId
+	token    string
+	user     *UserInfo
+	rooms    []joinRoomRequest
+}
+
+var (
+	sessionStore      sessions.Store
+	sessionTTL        time.Duration
+	sessionStoreMutex sync.RWMutex
+
+	liveSessions      = make(map[socketio.SocketId]*liveSession)
+	liveSessionsMutex sync.Mutex
+)
+
+// SetSessionStore enables resuming: every socket is given a resume token, and
+// a socket that connects with it within ttl of the old one going away, on
+// any instance sharing the store, rejoins its rooms.
+func SetSessionStore(store sessions.Store, ttl time.Duration) {
+	sessionStoreMutex.Lock()
+	defer sessionStoreMutex.Unlock()
+	sessionStore, sessionTTL = store, ttl
+}
+
+func getSessionStore() (sessions.Store, time.Duration) {
+	sessionStoreMutex.RLock()
+	defer sessionStoreMutex.RUnlock()
+	return sessionStore, sessionTTL
+}
+
+// StartSessionRefresh keeps the sessions of connected sockets from expiring
+// until stop is closed, so they can be resumed if this instance goes away
+// without saying goodbye.
+func StartSessionRefresh(stop <-chan struct{}) {
+	_, ttl := getSessionStore()
+	interval := ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			liveSessionsMutex.Lock()
+			live := make([]*liveSession, 0, len(liveSessions))
+			for _, session := range liveSessions {
+				live = append(live, session)
+			}
+			liveSessionsMutex.Unlock()
+
+			for _, session := range live {
+				saveSession(session)
+			}
+		}
+	}
+}
+
+// startSession gives a new socket its resume token with session-token(token)
+// and, when it connected with the token of an earlier socket, rejoins that
+// socket's rooms.
+func startSession(srv *socketio.Server, socket *socketio.Socket, authOpts AuthOptions) {
+	if store, _ := getSessionStore(); store == nil {
+		return
+	}
+
+	token, err := newResumeToken()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to create resume token")
+		return
+	}
+	liveSessionsMutex.Lock()
+	liveSessions[socket.Id()] = &liveSession{socketID: socket.Id(), token: token, user: socketUser(socket)}
+	liveSessionsMutex.Unlock()
+	_ = socket.Emit("session-token", token)
+
+	if previous := resumeTokenFromHandshake(socket.Handshake().Auth); previous != "" {
+		resumeSocketSession(srv, socket, authOpts, previous)
+	}
+}
+
+// resumeSocketSession rejoins the rooms of the session behind token, reports
+// the outcome with session-resumed and sends again what the old socket didn't
+// acknowledge. Each room is joined as by join-room, with its own
+// join-room-ack.
+func resumeSocketSession(srv *socketio.Server, socket *socketio.Socket, authOpts AuthOptions, token string) {
+	session, err := takeSession(token)
+	if err == nil && !resumableBy(session.User, socketUser(socket)) {
+		err = apierror.New(apierror.InvalidToken, "resume token belongs to another user")
+	}
+	if err != nil {
+		if errors.Is(err, sessions.ErrNotFound) {
+			err = apierror.New(apierror.InvalidToken, "unknown or expired resume token")
+		}
+		_ = socket.Emit("session-resumed", errorAckPayload(err))
+		return
+	}
+
+	rooms := make([]string, 0, len(session.Rooms))
+	for _, room := range session.Rooms {
+		rooms = append(rooms, room.RoomID)
+	}
+	_ = socket.Emit("session-resumed", map[string]any{
+		"status": "ok",
+		"rooms":  rooms,
+	})
+	for _, room := range session.Rooms {
+		reclaimPresentation(room.RoomID, socket.Id(), socketUser(socket), socketio.SocketId(session.SocketID), roomPresentationEmitter(srv))
+		joinRoom(srv, socket, authOpts, room.request(), nil)
+	}
+	redeliver(socket, session.Outbox)
+}
+
+// rememberSessionRoom adds a room the socket joined to its session.
+func rememberSessionRoom(socketID socketio.SocketId, request joinRoomRequest) {
+	liveSessionsMutex.Lock()
+	session, exists := liveSessions[socketID]
+	if !exists {
+		liveSessionsMutex.Unlock()
+		return
+	}
+	rooms := make([]joinRoomRequest, 0, len(session.rooms)+1)
+	for _, room := range session.rooms {
+		if room.roomID != request.roomID {
+			rooms = append(rooms, room)
+		}
+	}
+	session.rooms = append(rooms, request)
+	liveSessionsMutex.Unlock()
+
+	saveSession(session)
+}
+
+// endSession saves the session of a leaving socket once more, so it can be
+// resumed for the whole TTL.
+func endSession(socketID socketio.SocketId) {
+	liveSessionsMutex.Lock()
+	session, exists := liveSessions[socketID]
+	delete(liveSessions, socketID)
+	liveSessionsMutex.Unlock()
+
+	if exists {
+		saveSession(session)
+	}
+}
+
+func saveSession(session *liveSession) {
+	store, ttl := getSessionStore()
+	if store == nil {
+		return
+	}
+
+	liveSessionsMutex.Lock()
+	token, descriptor := session.token, session.descriptor()
+	liveSessionsMutex.Unlock()
+	descriptor.Outbox = pendingDeliveries(session.socketID)
+	if len(descriptor.Rooms) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(descriptor)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encode session")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionTimeout)
+	defer cancel()
+	if err := store.Put(ctx, token, data, ttl); err != nil {
+		logrus.WithError(err).Warn("Failed to save session")
+	}
+}
+
+func takeSession(token string) (resumeSession, error) {
+	store, _ := getSessionStore()
+	ctx, cancel := context.WithTimeout(context.Background(), sessionTimeout)
+	defer cancel()
+
+	data, err := store.Take(ctx, token)
+	if err != nil {
+		if !errors.Is(err, sessions.ErrNotFound) {
+			logrus.WithError(err).Error("Failed to load session")
+		}
+		return resumeSession{}, err
+	}
+	var session resumeSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		logrus.WithError(err).Error("Failed to decode session")
+		return resumeSession{}, sessions.ErrNotFound
+	}
+	return session, nil
+}
+
+// descriptor returns what the session's token stands for. Callers hold
+// liveSessionsMutex.
+func (s *liveSession) descriptor() resumeSession {
+	descriptor := resumeSession{User: s.user, SocketID: string(s.socketID), Rooms: make([]resumeRoom, 0, len(s.rooms))}
+	for _, room := range s.rooms {
+		descriptor.Rooms = append(descriptor.Rooms, resumeRoom{
+			RoomID:    room.roomID,
+			Encrypted: room.mode == RoomModeEncrypted,
+			Protocol:  room.protocol,
+			Encoding:  room.encoding,
+		})
+	}
+	return descriptor
+}
+
+func (r resumeRoom) request() joinRoomRequest {
+	request := joinRoomRequest{roomID: r.RoomID, mode: RoomModePlain, protocol: r.Protocol, encoding: r.Encoding}
+	if r.Encrypted {
+		request.mode = RoomModeEncrypted
+	}
+	return request
+}
+
+// resumableBy reports whether a socket of user may resume a session of owner:
+// sessions of authenticated users only resume for the same user.
+func resumableBy(owner, user *UserInfo) bool {
+	return owner == nil || (user != nil && user.ID == owner.ID)
+}
+
+func newResumeToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func resumeTokenFromHandshake(handshakeAuth any) string {
+	payload, ok := handshakeAuth.(map[string]any)
+	if !ok {
+		return ""
+	}
+
+	token, _ := payload["resumeToken"].(string)
+	return token
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"errors"
+	"excalidraw-server/sessions"
+	"testing"
+	"time"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	SetSessionStore(sessions.NewMemoryStore(), time.Minute)
+	defer SetSessionStore(nil, 0)
+
+	liveSessionsMutex.Lock()
+	liveSessions["socket-1"] = &liveSession{socketID: "socket-1", token: "token-1", user: &UserInfo{ID: "alice"}}
+	liveSessionsMutex.Unlock()
+
+	rememberSessionRoom("socket-1", joinRoomRequest{roomID: "room-a", mode: RoomModePlain, protocol: 1, encoding: EncodingJSON})
+	rememberSessionRoom("socket-1", joinRoomRequest{roomID: "room-b", mode: RoomModeEncrypted, protocol: 1, encoding: EncodingMsgpack})
+	// Joining again replaces the room's options
+	rememberSessionRoom("socket-1", joinRoomRequest{roomID: "room-a", mode: RoomModePlain, protocol: 1, encoding: EncodingMsgpack})
+	// Unacknowledged events go with the session
+	openFakeOutbox(t, "socket-1", "room-a")
+	deliverReliably("socket-1", "moderation-muted", map[string]any{"roomId": "room-a", "muted": true})
+	endSession("socket-1")
+
+	liveSessionsMutex.Lock()
+	_, live := liveSessions["socket-1"]
+	liveSessionsMutex.Unlock()
+	if live {
+		t.Error("endSession() left the session live")
+	}
+
+	session, err := takeSession("token-1")
+	if err != nil {
+		t.Fatalf("takeSession() failed: %v", err)
+	}
+	if session.User == nil || session.User.ID != "alice" {
+		t.Errorf("session user = %+v, want alice", session.User)
+	}
+	if len(session.Outbox) != 1 || session.Outbox[0].Event != "moderation-muted" {
+		t.Errorf("session outbox = %+v, want the mute", session.Outbox)
+	}
+	if len(session.Rooms) != 2 {
+		t.Fatalf("session rooms = %+v, want two", session.Rooms)
+	}
+	if got := session.Rooms[0].request(); got.roomID != "room-b" || got.mode != RoomModeEncrypted || got.encoding != EncodingMsgpack {
+		t.Errorf("first room = %+v", got)
+	}
+	if got := session.Rooms[1].request(); got.roomID != "room-a" || got.mode != RoomModePlain || got.encoding != EncodingMsgpack {
+		t.Errorf("second room = %+v", got)
+	}
+
+	if _, err := takeSession("token-1"); !errors.Is(err, sessions.ErrNotFound) {
+		t.Errorf("takeSession() of a used token error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestResumableBy(t *testing.T) {
+	alice, bob := &UserInfo{ID: "alice"}, &UserInfo{ID: "bob"}
+	tests := []struct {
+		name        string
+		owner, user *UserInfo
+		want        bool
+	}{
+		{"anonymous session", nil, nil, true},
+		{"anonymous session by a user", nil, alice, true},
+		{"same user", alice, &UserInfo{ID: "alice"}, true},
+		{"other user", alice, bob, false},
+		{"anonymous socket", alice, nil, false},
+	}
+	for _, tt := range tests {
+		if got := resumableBy(tt.owner, tt.user); got != tt.want {
+			t.Errorf("%s: resumableBy() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResumeTokenFromHandshake(t *testing.T) {
+	if token := resumeTokenFromHandshake(map[string]any{"token": "jwt", "resumeToken": "abc"}); token != "abc" {
+		t.Errorf("resumeTokenFromHandshake() = %q, want abc", token)
+	}
+	if token := resumeTokenFromHandshake(nil); token != "" {
+		t.Errorf("resumeTokenFromHandshake(nil) = %q, want empty", token)
+	}
+
+	first, err := newResumeToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := newResumeToken()
+	if len(first) < 40 || first == second {
+		t.Errorf("newResumeToken() = %q, %q; want long, distinct tokens", first, second)
+	}
+}
@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const (
+	defaultRoomStatsInterval = 5 * time.Second
+	// relaySamples is how many of a room's latest broadcasts the relay
+	// latency percentile is taken over
+	relaySamples = 200
+)
+
+// RoomQuality is what the room-stats event tells a room's members about
+// their session, so clients can show connection quality.
+type RoomQuality struct {
+	RoomID string `json:"roomId"`
+	// UserCount counts the room's members on this instance.
+	UserCount int `json:"userCount"`
+	// RelayLatencyP95 is the 95th percentile of how long the server took to
+	// relay the room's recent broadcasts, in milliseconds.
+	RelayLatencyP95     float64 `json:"relayLatencyP95Ms"`
+	BroadcastsPerMinute int64   `json:"broadcastsPerMinute"`
+	Region              string  `json:"region,omitempty"`
+}
+
+// relayLatencies keeps a room's latest relay latencies in a ring.
+type relayLatencies struct {
+	samples [relaySamples]time.Duration
+	next    int
+	count   int
+}
+
+// RoomStatsIntervalFromEnv reads ROOM_STATS_INTERVAL, how often members get
+// the room-stats event. Zero disables it.
+func RoomStatsIntervalFromEnv() (time.Duration, error) {
+	value := os.Getenv("ROOM_STATS_INTERVAL")
+	if value == "" {
+		return defaultRoomStatsInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 || interval > 0 && interval < time.Second {
+		return 0, fmt.Errorf("invalid ROOM_STATS_INTERVAL %q: must be 0 or a duration of at least 1s", value)
+	}
+	return interval, nil
+}
+
+// trackRelayLatency records how long relaying a broadcast in a room took.
+func trackRelayLatency(roomID string, latency time.Duration) {
+	roomTrafficsMutex.Lock()
+	defer roomTrafficsMutex.Unlock()
+
+	latencies := &getRoomTraffic(roomID).latencies
+	latencies.samples[latencies.next] = latency
+	latencies.next = (latencies.next + 1) % relaySamples
+	latencies.count = min(latencies.count+1, relaySamples)
+}
+
+// p95 returns the 95th percentile of the samples, or zero without any.
+func (l *relayLatencies) p95() time.Duration {
+	if l.count == 0 {
+		return 0
+	}
+	samples := make([]time.Duration, l.count)
+	copy(samples, l.samples[:l.count])
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[int(math.Ceil(float64(l.count)*0.95))-1]
+}
+
+// roomQualityAt describes a live room for its members.
+func roomQualityAt(roomID string, userCount int, region string, now time.Time) RoomQuality {
+	quality := RoomQuality{RoomID: roomID, UserCount: userCount, Region: region}
+	if stats, ok := roomStatsAt(roomID, now); ok {
+		quality.BroadcastsPerMinute = stats.MessagesPerMinute
+	}
+
+	roomTrafficsMutex.Lock()
+	defer roomTrafficsMutex.Unlock()
+	if traffic, exists := roomTraffics[roomID]; exists {
+		quality.RelayLatencyP95 = float64(traffic.latencies.p95().Microseconds()) / 1000
+	}
+	return quality
+}
+
+// emitRoomStats sends every live room's room-stats to its sockets and
+// subscribers, which include native WebSocket peers.
+func emitRoomStats(srv *socketio.Server, region string, now time.Time) {
+	for roomID, userCount := range GetActiveRooms() {
+		quality := roomQualityAt(roomID, userCount, region, now)
+		srv.In(socketio.Room(roomID)).Emit("room-stats", quality)
+		publishRoomEvent(roomID, "room-stats", quality)
+	}
+}
+
+// StartRoomStats sends room-stats to the members of every live room each
+// interval until stop is closed. region, e.g. SERVER_REGION, tells clients
+// where the server runs.
+func StartRoomStats(srv *socketio.Server, interval time.Duration, region string, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			emitRoomStats(srv, region, now)
+		}
+	}
+}
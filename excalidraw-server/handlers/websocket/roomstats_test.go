@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func TestRoomStatsIntervalFromEnv(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": defaultRoomStatsInterval, "0": 0, "30s": 30 * time.Second} {
+		t.Setenv("ROOM_STATS_INTERVAL", value)
+		if interval, err := RoomStatsIntervalFromEnv(); err != nil || interval != want {
+			t.Errorf("ROOM_STATS_INTERVAL=%q gave %v, %v, want %v", value, interval, err, want)
+		}
+	}
+	for _, value := range []string{"soon", "-1s", "100ms"} {
+		t.Setenv("ROOM_STATS_INTERVAL", value)
+		if _, err := RoomStatsIntervalFromEnv(); err == nil {
+			t.Errorf("ROOM_STATS_INTERVAL=%q was accepted", value)
+		}
+	}
+}
+
+func TestEmitRoomStats(t *testing.T) {
+	const roomID = "quality"
+	roomsMutex.Lock()
+	activeRooms[roomID] = 2
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(activeRooms, roomID)
+		roomsMutex.Unlock()
+		releaseRoom(roomID)
+	}()
+
+	now := time.Now()
+	// the oldest samples fall out of the ring
+	for i := 0; i < relaySamples; i++ {
+		trackRelayLatency(roomID, time.Hour)
+	}
+	for i := 1; i <= relaySamples; i++ {
+		trackRelayLatency(roomID, time.Duration(i)*time.Millisecond)
+		trackBroadcast(roomID, 10, now)
+	}
+
+	events, unsubscribe := SubscribeRoom(roomID)
+	defer unsubscribe()
+	emitRoomStats(socketio.NewServer(nil, nil), "eu-west", now)
+
+	select {
+	case event := <-events:
+		quality, ok := event.Data.(RoomQuality)
+		if event.Name != "room-stats" || !ok {
+			t.Fatalf("got %s %#v", event.Name, event.Data)
+		}
+		want := RoomQuality{RoomID: roomID, UserCount: 2, RelayLatencyP95: 190, BroadcastsPerMinute: relaySamples, Region: "eu-west"}
+		if quality != want {
+			t.Errorf("room-stats = %+v, want %+v", quality, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no room-stats event")
+	}
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// roomQueueSize is how many tasks a room worker holds before the sockets
+// handing it more wait, which slows down readers of a busy room rather than
+// queueing without bound.
+const roomQueueSize = 1024
+
+// roomWorkers are the queues of the worker goroutines, or nil until
+// StartRoomWorkers runs.
+var roomWorkers atomic.Pointer[[]chan func()]
+
+// RoomWorkersFromEnv reads ROOM_WORKERS, how many goroutines process room
+// events. It defaults to four per CPU.
+func RoomWorkersFromEnv() (int, error) {
+	value := os.Getenv("ROOM_WORKERS")
+	if value == "" {
+		return 4 * runtime.GOMAXPROCS(0), nil
+	}
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 1 {
+		return 0, fmt.Errorf("invalid ROOM_WORKERS %q: must be a positive integer", value)
+	}
+	return workers, nil
+}
+
+// StartRoomWorkers starts the goroutines joins, leaves and broadcasts run
+// on. Rooms are spread over them by id, so a room's events are processed
+// one at a time in the order they came, and its member count and
+// room-user-change updates can't overtake each other, while different rooms
+// go on in parallel. Until it runs, events are processed where they arrive.
+func StartRoomWorkers(workers int) {
+	queues := make([]chan func(), workers)
+	for i := range queues {
+		queues[i] = make(chan func(), roomQueueSize)
+		go func(queue chan func()) {
+			for task := range queue {
+				task()
+			}
+		}(queues[i])
+	}
+	roomWorkers.Store(&queues)
+}
+
+// runInRoom queues a task on its room's worker. Tasks must not wait for
+// other room tasks, which may be queued behind them.
+func runInRoom(roomID string, task func()) {
+	queues := roomWorkers.Load()
+	if queues == nil {
+		task()
+		return
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(roomID))
+	(*queues)[hash.Sum32()%uint32(len(*queues))] <- task
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRoomWorkersFromEnv(t *testing.T) {
+	t.Setenv("ROOM_WORKERS", "8")
+	if workers, err := RoomWorkersFromEnv(); err != nil || workers != 8 {
+		t.Errorf("ROOM_WORKERS=8 gave %d, %v", workers, err)
+	}
+	t.Setenv("ROOM_WORKERS", "")
+	if workers, err := RoomWorkersFromEnv(); err != nil || workers < 4 {
+		t.Errorf("the default gave %d, %v", workers, err)
+	}
+	for _, value := range []string{"0", "-1", "many"} {
+		t.Setenv("ROOM_WORKERS", value)
+		if _, err := RoomWorkersFromEnv(); err == nil {
+			t.Errorf("ROOM_WORKERS=%q was accepted", value)
+		}
+	}
+}
+
+func TestRunInRoom(t *testing.T) {
+	// without workers, tasks run right away
+	ran := false
+	runInRoom("inline", func() { ran = true })
+	if !ran {
+		t.Fatal("the task didn't run inline")
+	}
+
+	StartRoomWorkers(3)
+	defer roomWorkers.Store(nil)
+
+	const rooms, tasks = 10, 200
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	order := make(map[string][]int)
+	for i := 0; i < tasks; i++ {
+		for r := 0; r < rooms; r++ {
+			roomID, i := fmt.Sprintf("room-%d", r), i
+			wg.Add(1)
+			runInRoom(roomID, func() {
+				defer wg.Done()
+				mu.Lock()
+				defer mu.Unlock()
+				order[roomID] = append(order[roomID], i)
+			})
+		}
+	}
+	wg.Wait()
+
+	for roomID, seen := range order {
+		for i, task := range seen {
+			if task != i {
+				t.Fatalf("%s ran task %d as number %d", roomID, task, i)
+			}
+		}
+	}
+	if len(order) != rooms {
+		t.Errorf("ran tasks of %d rooms", len(order))
+	}
+}
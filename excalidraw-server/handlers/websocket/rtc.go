@@ -0,0 +1,153 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"excalidraw-server/apierror"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zishang520/engine.io/v2/utils"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const defaultTURNTTL = time.Hour
+
+// rtcSignalEvents are relayed verbatim from one room member to another so
+// clients can negotiate WebRTC peer connections for voice chat.
+var rtcSignalEvents = []string{"rtc-offer", "rtc-answer", "rtc-ice"}
+
+// RTCConfig describes the ICE servers handed to clients. With TURNSecret set,
+// short-lived TURN credentials are minted using the TURN REST API scheme
+// understood by coturn's static-auth-secret.
+type RTCConfig struct {
+	STUNURLs   []string
+	TURNURLs   []string
+	TURNSecret string
+	TURNTTL    time.Duration
+}
+
+var rtcConfig atomic.Pointer[RTCConfig]
+
+// SetRTCConfig replaces the ICE server configuration.
+func SetRTCConfig(cfg RTCConfig) {
+	if cfg.TURNTTL <= 0 {
+		cfg.TURNTTL = defaultTURNTTL
+	}
+	rtcConfig.Store(&cfg)
+}
+
+// RTCConfigFromEnv reads STUN_URLS, TURN_URLS, TURN_SECRET and TURN_TTL.
+func RTCConfigFromEnv() (RTCConfig, error) {
+	cfg := RTCConfig{
+		STUNURLs:   splitList(os.Getenv("STUN_URLS")),
+		TURNURLs:   splitList(os.Getenv("TURN_URLS")),
+		TURNSecret: os.Getenv("TURN_SECRET"),
+		TURNTTL:    defaultTURNTTL,
+	}
+	if value := os.Getenv("TURN_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return RTCConfig{}, fmt.Errorf("invalid TURN_TTL: %w", err)
+		}
+		cfg.TURNTTL = ttl
+	}
+	return cfg, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// turnCredentials mints a TURN REST API credential valid until expiry.
+func turnCredentials(secret, identity string, expiry time.Time) (username, credential string) {
+	username = strconv.FormatInt(expiry.Unix(), 10) + ":" + identity
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// iceServers builds the RTCIceServer list for a client.
+func iceServers(cfg *RTCConfig, identity string, now time.Time) []map[string]any {
+	servers := []map[string]any{}
+	if cfg == nil {
+		return servers
+	}
+	if len(cfg.STUNURLs) > 0 {
+		servers = append(servers, map[string]any{"urls": cfg.STUNURLs})
+	}
+	if len(cfg.TURNURLs) > 0 && cfg.TURNSecret != "" {
+		username, credential := turnCredentials(cfg.TURNSecret, identity, now.Add(cfg.TURNTTL))
+		servers = append(servers, map[string]any{
+			"urls":       cfg.TURNURLs,
+			"username":   username,
+			"credential": credential,
+		})
+	}
+	return servers
+}
+
+// handleRTCConfig answers rtc-config with the ICE servers for the socket.
+func handleRTCConfig(socket *socketio.Socket, datas []any) {
+	ack, _ := extractAck(datas)
+
+	identity := string(socket.Id())
+	if user := socketUser(socket); user != nil {
+		identity = user.ID
+	}
+	respondWithAck(socket, ack, "rtc-config", map[string]any{
+		"status":     "ok",
+		"iceServers": iceServers(rtcConfig.Load(), identity, time.Now()),
+	}, nil)
+}
+
+// handleRTCSignal relays an rtc-offer, rtc-answer or rtc-ice message
+// (roomId, targetSocketId, payload) to a socket in the same room.
+func handleRTCSignal(socket *socketio.Socket, srv *socketio.Server, event string, datas []any) {
+	ack, args := extractAck(datas)
+	respondError := func(err error) {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+	}
+
+	request, err := decodeRTCSignal(args)
+	if err != nil {
+		respondError(err)
+		return
+	}
+	roomID, target := request.roomID, request.target
+	room := socketio.Room(roomID)
+	if !socket.Rooms().Has(room) {
+		respondError(apierror.Errorf(apierror.NotInRoom, "not in room %s", roomID))
+		return
+	}
+
+	srv.In(socketio.Room(target)).FetchSockets()(func(sockets []*socketio.RemoteSocket, fetchErr error) {
+		if fetchErr != nil {
+			respondError(fetchErr)
+			return
+		}
+		if len(sockets) == 0 || !sockets[0].Rooms().Has(room) {
+			respondError(apierror.Errorf(apierror.NotInRoom, "socket %s is not in room %s", target, roomID))
+			return
+		}
+
+		_ = sockets[0].Emit(event, map[string]any{
+			"roomId":  roomID,
+			"from":    string(socket.Id()),
+			"payload": request.payload,
+		})
+		utils.Log().Printf("relayed %v from %v to %v in room %v\n", event, socket.Id(), target, roomID)
+		respondWithAck(socket, ack, "", map[string]any{"status": "ok"}, nil)
+	})
+}
@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTurnCredentials(t *testing.T) {
+	username, credential := turnCredentials("secret", "alice", time.Unix(1_700_003_600, 0))
+	if username != "1700003600:alice" {
+		t.Errorf("username = %q", username)
+	}
+	// Matches coturn's base64(HMAC-SHA1(static-auth-secret, username)).
+	if credential != "LLPLO4qjdVL2qZhwr3eImhn7J20=" {
+		t.Errorf("credential = %q", credential)
+	}
+}
+
+func TestIceServers(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	if servers := iceServers(nil, "alice", now); len(servers) != 0 {
+		t.Errorf("expected no servers without config, got %v", servers)
+	}
+
+	stunOnly := &RTCConfig{STUNURLs: []string{"stun:stun.example.com"}}
+	if servers := iceServers(stunOnly, "alice", now); len(servers) != 1 || servers[0]["username"] != nil {
+		t.Errorf("unexpected STUN-only servers %v", servers)
+	}
+
+	withoutSecret := &RTCConfig{TURNURLs: []string{"turn:turn.example.com"}}
+	if servers := iceServers(withoutSecret, "alice", now); len(servers) != 0 {
+		t.Errorf("TURN without a secret should be skipped, got %v", servers)
+	}
+
+	full := &RTCConfig{
+		STUNURLs:   []string{"stun:stun.example.com"},
+		TURNURLs:   []string{"turn:turn.example.com"},
+		TURNSecret: "secret",
+		TURNTTL:    time.Hour,
+	}
+	servers := iceServers(full, "alice", now)
+	if len(servers) != 2 {
+		t.Fatalf("expected STUN and TURN servers, got %v", servers)
+	}
+	if servers[1]["username"] != "1700003600:alice" {
+		t.Errorf("TURN username = %v", servers[1]["username"])
+	}
+}
+
+func TestRTCConfigFromEnv(t *testing.T) {
+	t.Setenv("STUN_URLS", "stun:a.example.com, stun:b.example.com")
+	t.Setenv("TURN_URLS", "turn:turn.example.com")
+	t.Setenv("TURN_SECRET", "secret")
+	t.Setenv("TURN_TTL", "30m")
+
+	cfg, err := RTCConfigFromEnv()
+	if err != nil {
+		t.Fatalf("RTCConfigFromEnv() failed: %v", err)
+	}
+	if len(cfg.STUNURLs) != 2 || cfg.STUNURLs[1] != "stun:b.example.com" {
+		t.Errorf("STUNURLs = %v", cfg.STUNURLs)
+	}
+	if cfg.TURNTTL != 30*time.Minute {
+		t.Errorf("TURNTTL = %v", cfg.TURNTTL)
+	}
+
+	t.Setenv("TURN_TTL", "forever")
+	if _, err := RTCConfigFromEnv(); err == nil {
+		t.Error("expected error for invalid TURN_TTL")
+	}
+}
@@ -0,0 +1,522 @@
+package websocket
+
+import (
+	"excalidraw-server/apierror"
+	"fmt"
+	"math"
+)
+
+const (
+	// ProtocolVersion is the newest version of the socket event schema the
+	// server speaks. Clients ask for a version in the join-room options and
+	// the join-room-ack reports the one the server will use.
+	ProtocolVersion = 1
+	// minProtocolVersion is the oldest version still accepted. Clients that
+	// don't ask for a version get this one.
+	minProtocolVersion = 1
+)
+
+// binaryArg matches the buffers socket.io decodes binary attachments into.
+type binaryArg interface {
+	Bytes() []byte
+}
+
+// payloadError describes an event argument that doesn't match the event's
+// schema. Its error acks carry the code invalid_payload and the field.
+type payloadError struct {
+	field  string
+	reason string
+}
+
+func (e *payloadError) Error() string {
+	return fmt.Sprintf("%s: %s", e.field, e.reason)
+}
+
+// errorAckPayload is the ack payload for a failed event, with the error's
+// apierror code.
+func errorAckPayload(err error) map[string]any {
+	payload := map[string]any{
+		"status": "error",
+		"error":  err.Error(),
+		"code":   apierror.CodeOf(err),
+	}
+	if invalid, ok := err.(*payloadError); ok {
+		payload["code"] = apierror.InvalidPayload
+		payload["field"] = invalid.field
+	}
+	if filtered, ok := err.(*filteredError); ok {
+		payload["code"] = apierror.Filtered
+		payload["reason"] = filtered.reason
+	}
+	if tooLarge, ok := err.(*tooLargeError); ok {
+		payload["code"] = apierror.PayloadTooLarge
+		payload["kind"] = tooLarge.kind
+		payload["limit"] = tooLarge.limit
+	}
+	return payload
+}
+
+// joinRoomRequest is join-room(roomId, { encrypted?, protocol?, encoding? }).
+type joinRoomRequest struct {
+	roomID   string
+	mode     RoomMode
+	protocol int
+	encoding Encoding
+}
+
+// broadcastRequest is server-broadcast(roomId, payload, metadata?) and its
+// volatile variant. The payload is relayed as is: it may be ciphertext.
+type broadcastRequest struct {
+	roomID   string
+	payload  any
+	metadata any
+}
+
+// chatMessageRequest is server-chat-message(roomId, { id, content, replyTo? }).
+type chatMessageRequest struct {
+	roomID  string
+	id      string
+	content string
+	replyTo string
+}
+
+// chatReactionRequest is server-chat-reaction(roomId, { messageId, emoji,
+// remove? }).
+type chatReactionRequest struct {
+	roomID    string
+	messageID string
+	emoji     string
+	remove    bool
+}
+
+// moderationRequest is moderate-kick(roomId, socketId),
+// moderate-ban(roomId, socketId, seconds?) and
+// moderate-mute(roomId, socketId, muted?). banSeconds is zero and muted true
+// when the optional argument is missing.
+type moderationRequest struct {
+	roomID     string
+	target     string
+	banSeconds float64
+	muted      bool
+}
+
+// canvasReactionRequest is server-reaction(roomId, { x, y, emoji }), with x
+// and y in scene coordinates.
+type canvasReactionRequest struct {
+	roomID string
+	x, y   float64
+	emoji  string
+}
+
+// laserPointerRequest is laser-pointer(roomId, { x, y, active? }). active is
+// true when missing; false hides the pointer.
+type laserPointerRequest struct {
+	roomID string
+	x, y   float64
+	active bool
+}
+
+// presentationRequest is presentation-state(roomId, { presenting?, frameId?,
+// slide? }). presenting is true when missing; slide is -1 when missing.
+type presentationRequest struct {
+	roomID     string
+	presenting bool
+	frameID    string
+	slide      int
+}
+
+// typingRequest is server-typing(roomId, typing?). typing is true when the
+// argument is missing.
+type typingRequest struct {
+	roomID string
+	typing bool
+}
+
+// rtcSignalRequest is rtc-offer/rtc-answer/rtc-ice(roomId, targetSocketId,
+// payload).
+type rtcSignalRequest struct {
+	roomID  string
+	target  string
+	payload any
+}
+
+func decodeJoinRoom(args []any) (joinRoomRequest, error) {
+	request := joinRoomRequest{mode: RoomModePlain, protocol: minProtocolVersion, encoding: EncodingJSON}
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return joinRoomRequest{}, err
+	}
+	options, err := objectArg(args, 1, "options")
+	if err != nil {
+		return joinRoomRequest{}, err
+	}
+	if value, ok := options["encrypted"]; ok && value != nil {
+		encrypted, ok := value.(bool)
+		if !ok {
+			return joinRoomRequest{}, &payloadError{"options.encrypted", "must be a boolean"}
+		}
+		if encrypted {
+			request.mode = RoomModeEncrypted
+		}
+	}
+	if value, ok := options["protocol"]; ok && value != nil {
+		requested, ok := integer(value)
+		if !ok {
+			return joinRoomRequest{}, &payloadError{"options.protocol", "must be an integer"}
+		}
+		if request.protocol, err = negotiateProtocol(requested); err != nil {
+			return joinRoomRequest{}, err
+		}
+	}
+	if value, ok := options["encoding"]; ok && value != nil {
+		encoding, _ := value.(string)
+		if Encoding(encoding) != EncodingJSON && Encoding(encoding) != EncodingMsgpack {
+			return joinRoomRequest{}, &payloadError{"options.encoding", fmt.Sprintf("must be %q or %q", EncodingJSON, EncodingMsgpack)}
+		}
+		request.encoding = Encoding(encoding)
+	}
+	return request, nil
+}
+
+// negotiateProtocol picks the version to speak with a client that asked for
+// requested: the same one, or the server's newest when the client is ahead.
+func negotiateProtocol(requested int64) (int, error) {
+	if requested < minProtocolVersion {
+		return 0, &payloadError{"options.protocol", fmt.Sprintf("version %d is not supported, the oldest supported is %d", requested, minProtocolVersion)}
+	}
+	if requested > ProtocolVersion {
+		return ProtocolVersion, nil
+	}
+	return int(requested), nil
+}
+
+func decodeBroadcast(args []any) (broadcastRequest, error) {
+	var request broadcastRequest
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return broadcastRequest{}, err
+	}
+	if len(args) < 2 || args[1] == nil {
+		return broadcastRequest{}, &payloadError{"payload", "is required"}
+	}
+	request.payload = args[1]
+	if len(args) > 2 {
+		switch args[2].(type) {
+		case nil, []byte, binaryArg, map[string]any, []any:
+			request.metadata = args[2]
+		default:
+			return broadcastRequest{}, &payloadError{"metadata", "must be binary, an object or an array"}
+		}
+	}
+	return request, nil
+}
+
+func decodeChatMessage(args []any) (chatMessageRequest, error) {
+	var request chatMessageRequest
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return chatMessageRequest{}, err
+	}
+	message, err := objectArg(args, 1, "message")
+	if err != nil {
+		return chatMessageRequest{}, err
+	}
+	if message == nil {
+		return chatMessageRequest{}, &payloadError{"message", "is required"}
+	}
+	if request.id, err = stringField(message, "id", "message.id"); err != nil {
+		return chatMessageRequest{}, err
+	}
+	if request.content, err = stringField(message, "content", "message.content"); err != nil {
+		return chatMessageRequest{}, err
+	}
+	if value, ok := message["replyTo"]; ok && value != nil {
+		if request.replyTo, err = stringField(message, "replyTo", "message.replyTo"); err != nil {
+			return chatMessageRequest{}, err
+		}
+	}
+	return request, nil
+}
+
+func decodeChatReaction(args []any) (chatReactionRequest, error) {
+	var request chatReactionRequest
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return chatReactionRequest{}, err
+	}
+	reaction, err := objectArg(args, 1, "reaction")
+	if err != nil {
+		return chatReactionRequest{}, err
+	}
+	if reaction == nil {
+		return chatReactionRequest{}, &payloadError{"reaction", "is required"}
+	}
+	if request.messageID, err = stringField(reaction, "messageId", "reaction.messageId"); err != nil {
+		return chatReactionRequest{}, err
+	}
+	if request.emoji, err = stringField(reaction, "emoji", "reaction.emoji"); err != nil {
+		return chatReactionRequest{}, err
+	}
+	if len(request.emoji) > maxReactionLength {
+		return chatReactionRequest{}, &payloadError{"reaction.emoji", fmt.Sprintf("must be at most %d bytes", maxReactionLength)}
+	}
+	if value, ok := reaction["remove"]; ok && value != nil {
+		if request.remove, ok = value.(bool); !ok {
+			return chatReactionRequest{}, &payloadError{"reaction.remove", "must be a boolean"}
+		}
+	}
+	return request, nil
+}
+
+func decodeModeration(event string, args []any) (moderationRequest, error) {
+	request := moderationRequest{muted: true}
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return moderationRequest{}, err
+	}
+	if request.target, err = stringArg(args, 1, "socketId"); err != nil {
+		return moderationRequest{}, err
+	}
+	if len(args) < 3 || args[2] == nil {
+		return request, nil
+	}
+	switch event {
+	case "moderate-ban":
+		seconds, ok := number(args[2])
+		if !ok || seconds <= 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+			return moderationRequest{}, &payloadError{"seconds", "must be a positive number"}
+		}
+		request.banSeconds = seconds
+	case "moderate-mute":
+		muted, ok := args[2].(bool)
+		if !ok {
+			return moderationRequest{}, &payloadError{"muted", "must be a boolean"}
+		}
+		request.muted = muted
+	}
+	return request, nil
+}
+
+func decodeCanvasReaction(args []any) (canvasReactionRequest, error) {
+	var request canvasReactionRequest
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return canvasReactionRequest{}, err
+	}
+	reaction, err := objectArg(args, 1, "reaction")
+	if err != nil {
+		return canvasReactionRequest{}, err
+	}
+	if reaction == nil {
+		return canvasReactionRequest{}, &payloadError{"reaction", "is required"}
+	}
+	if request.x, err = finiteField(reaction, "x", "reaction.x"); err != nil {
+		return canvasReactionRequest{}, err
+	}
+	if request.y, err = finiteField(reaction, "y", "reaction.y"); err != nil {
+		return canvasReactionRequest{}, err
+	}
+	if request.emoji, err = stringField(reaction, "emoji", "reaction.emoji"); err != nil {
+		return canvasReactionRequest{}, err
+	}
+	if len(request.emoji) > maxReactionLength {
+		return canvasReactionRequest{}, &payloadError{"reaction.emoji", fmt.Sprintf("must be at most %d bytes", maxReactionLength)}
+	}
+	return request, nil
+}
+
+func decodeLaserPointer(args []any) (laserPointerRequest, error) {
+	request := laserPointerRequest{active: true}
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return laserPointerRequest{}, err
+	}
+	pointer, err := objectArg(args, 1, "pointer")
+	if err != nil {
+		return laserPointerRequest{}, err
+	}
+	if pointer == nil {
+		return laserPointerRequest{}, &payloadError{"pointer", "is required"}
+	}
+	if request.x, err = finiteField(pointer, "x", "pointer.x"); err != nil {
+		return laserPointerRequest{}, err
+	}
+	if request.y, err = finiteField(pointer, "y", "pointer.y"); err != nil {
+		return laserPointerRequest{}, err
+	}
+	if value, ok := pointer["active"]; ok && value != nil {
+		if request.active, ok = value.(bool); !ok {
+			return laserPointerRequest{}, &payloadError{"pointer.active", "must be a boolean"}
+		}
+	}
+	return request, nil
+}
+
+func decodePresentation(args []any) (presentationRequest, error) {
+	request := presentationRequest{presenting: true, slide: -1}
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return presentationRequest{}, err
+	}
+	state, err := objectArg(args, 1, "state")
+	if err != nil || state == nil {
+		return request, err
+	}
+	if value, ok := state["presenting"]; ok && value != nil {
+		if request.presenting, ok = value.(bool); !ok {
+			return presentationRequest{}, &payloadError{"state.presenting", "must be a boolean"}
+		}
+	}
+	if value, ok := state["frameId"]; ok && value != nil {
+		if request.frameID, err = stringField(state, "frameId", "state.frameId"); err != nil {
+			return presentationRequest{}, err
+		}
+	}
+	if value, ok := state["slide"]; ok && value != nil {
+		slide, ok := number(value)
+		if !ok || slide < 0 || slide != math.Trunc(slide) || slide > math.MaxInt32 {
+			return presentationRequest{}, &payloadError{"state.slide", "must be a non-negative integer"}
+		}
+		request.slide = int(slide)
+	}
+	return request, nil
+}
+
+func decodeTyping(args []any) (typingRequest, error) {
+	request := typingRequest{typing: true}
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return typingRequest{}, err
+	}
+	if len(args) > 1 && args[1] != nil {
+		typing, ok := args[1].(bool)
+		if !ok {
+			return typingRequest{}, &payloadError{"typing", "must be a boolean"}
+		}
+		request.typing = typing
+	}
+	return request, nil
+}
+
+func decodeRTCSignal(args []any) (rtcSignalRequest, error) {
+	var request rtcSignalRequest
+	var err error
+	if request.roomID, err = stringArg(args, 0, "roomId"); err != nil {
+		return rtcSignalRequest{}, err
+	}
+	if request.target, err = stringArg(args, 1, "targetSocketId"); err != nil {
+		return rtcSignalRequest{}, err
+	}
+	if len(args) < 3 || args[2] == nil {
+		return rtcSignalRequest{}, &payloadError{"payload", "is required"}
+	}
+	request.payload = args[2]
+	return request, nil
+}
+
+// stringArg returns the non-empty string argument at index.
+func stringArg(args []any, index int, field string) (string, error) {
+	if index >= len(args) || args[index] == nil {
+		return "", &payloadError{field, "is required"}
+	}
+	value, ok := args[index].(string)
+	if !ok {
+		return "", &payloadError{field, fmt.Sprintf("must be a string, got %s", typeName(args[index]))}
+	}
+	if value == "" {
+		return "", &payloadError{field, "must not be empty"}
+	}
+	return value, nil
+}
+
+// objectArg returns the object argument at index, or nil when it's missing.
+func objectArg(args []any, index int, field string) (map[string]any, error) {
+	if index >= len(args) || args[index] == nil {
+		return nil, nil
+	}
+	value, ok := args[index].(map[string]any)
+	if !ok {
+		return nil, &payloadError{field, fmt.Sprintf("must be an object, got %s", typeName(args[index]))}
+	}
+	return value, nil
+}
+
+// stringField returns the non-empty string under key in object.
+func stringField(object map[string]any, key, field string) (string, error) {
+	value, ok := object[key]
+	if !ok || value == nil {
+		return "", &payloadError{field, "is required"}
+	}
+	text, ok := value.(string)
+	if !ok {
+		return "", &payloadError{field, fmt.Sprintf("must be a string, got %s", typeName(value))}
+	}
+	if text == "" {
+		return "", &payloadError{field, "must not be empty"}
+	}
+	return text, nil
+}
+
+// finiteField returns the finite number under key in object.
+func finiteField(object map[string]any, key, field string) (float64, error) {
+	value, ok := object[key]
+	if !ok || value == nil {
+		return 0, &payloadError{field, "is required"}
+	}
+	parsed, ok := number(value)
+	if !ok || math.IsInf(parsed, 0) || math.IsNaN(parsed) {
+		return 0, &payloadError{field, fmt.Sprintf("must be a finite number, got %s", typeName(value))}
+	}
+	return parsed, nil
+}
+
+// number converts a decoded JSON number.
+func number(value any) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	}
+	return 0, false
+}
+
+// integer converts a decoded JSON number without a fractional part.
+func integer(value any) (int64, bool) {
+	switch number := value.(type) {
+	case float64:
+		if number != math.Trunc(number) || math.Abs(number) > 1<<53 {
+			return 0, false
+		}
+		return int64(number), true
+	case int:
+		return int64(number), true
+	case int64:
+		return number, true
+	}
+	return 0, false
+}
+
+// typeName names a decoded argument's type the way a JavaScript client
+// would think of it.
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, int, int64:
+		return "number"
+	case []byte, binaryArg:
+		return "binary"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
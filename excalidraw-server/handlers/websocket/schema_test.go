@@ -0,0 +1,253 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"excalidraw-server/apierror"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJoinRoom(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []any
+		wantMode     RoomMode
+		wantProtocol int
+		wantField    string
+	}{
+		{"no options", []any{"room"}, RoomModePlain, 1, ""},
+		{"null options", []any{"room", nil}, RoomModePlain, 1, ""},
+		{"encrypted false", []any{"room", map[string]any{"encrypted": false}}, RoomModePlain, 1, ""},
+		{"encrypted true", []any{"room", map[string]any{"encrypted": true}}, RoomModeEncrypted, 1, ""},
+		{"current protocol", []any{"room", map[string]any{"protocol": float64(ProtocolVersion)}}, RoomModePlain, ProtocolVersion, ""},
+		{"newer protocol", []any{"room", map[string]any{"protocol": float64(ProtocolVersion + 1)}}, RoomModePlain, ProtocolVersion, ""},
+		{"no room id", nil, "", 0, "roomId"},
+		{"empty room id", []any{""}, "", 0, "roomId"},
+		{"numeric room id", []any{float64(42)}, "", 0, "roomId"},
+		{"non-map options", []any{"room", "encrypted"}, "", 0, "options"},
+		{"non-bool encrypted", []any{"room", map[string]any{"encrypted": "yes"}}, "", 0, "options.encrypted"},
+		{"non-string encoding", []any{"room", map[string]any{"encoding": true}}, "", 0, "options.encoding"},
+		{"unknown encoding", []any{"room", map[string]any{"encoding": "cbor"}}, "", 0, "options.encoding"},
+		{"fractional protocol", []any{"room", map[string]any{"protocol": 1.5}}, "", 0, "options.protocol"},
+		{"unsupported protocol", []any{"room", map[string]any{"protocol": float64(0)}}, "", 0, "options.protocol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := decodeJoinRoom(tt.args)
+			if tt.wantField != "" {
+				assertPayloadError(t, err, tt.wantField)
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeJoinRoom() failed: %v", err)
+			}
+			if request.roomID != "room" || request.mode != tt.wantMode || request.protocol != tt.wantProtocol || request.encoding != EncodingJSON {
+				t.Errorf("decodeJoinRoom() = %+v", request)
+			}
+		})
+	}
+
+	request, err := decodeJoinRoom([]any{"room", map[string]any{"encoding": "msgpack"}})
+	if err != nil || request.encoding != EncodingMsgpack {
+		t.Errorf("decodeJoinRoom() with msgpack encoding = %+v, %v", request, err)
+	}
+}
+
+func TestDecodeBroadcast(t *testing.T) {
+	scene := map[string]any{"type": "SCENE_UPDATE"}
+	for _, args := range [][]any{
+		{"room", scene},
+		{"room", []byte("ciphertext"), []byte("iv")},
+		{"room", []byte("ciphertext"), bytes.NewBufferString("iv")},
+		{"room", scene, map[string]any{"source": "test"}},
+		{"room", scene, nil},
+	} {
+		request, err := decodeBroadcast(args)
+		if err != nil || request.roomID != "room" || request.payload == nil {
+			t.Errorf("decodeBroadcast(%v) = %+v, %v", args, request, err)
+		}
+	}
+
+	for field, args := range map[string][]any{
+		"roomId":   {nil, scene},
+		"payload":  {"room"},
+		"metadata": {"room", scene, float64(1)},
+	} {
+		_, err := decodeBroadcast(args)
+		assertPayloadError(t, err, field)
+	}
+}
+
+func TestDecodeChatMessage(t *testing.T) {
+	request, err := decodeChatMessage([]any{"room", map[string]any{"id": "m1", "content": "hi"}})
+	if err != nil || request.roomID != "room" || request.id != "m1" || request.content != "hi" {
+		t.Errorf("decodeChatMessage() = %+v, %v", request, err)
+	}
+
+	for field, args := range map[string][]any{
+		"roomId":          {true, map[string]any{"id": "m1", "content": "hi"}},
+		"message":         {"room"},
+		"message.id":      {"room", map[string]any{"content": "hi"}},
+		"message.content": {"room", map[string]any{"id": "m1", "content": float64(3)}},
+		"message.replyTo": {"room", map[string]any{"id": "m1", "content": "hi", "replyTo": float64(1)}},
+	} {
+		_, err := decodeChatMessage(args)
+		assertPayloadError(t, err, field)
+	}
+
+	request, err = decodeChatMessage([]any{"room", map[string]any{"id": "m2", "content": "re", "replyTo": "m1"}})
+	if err != nil || request.replyTo != "m1" {
+		t.Errorf("decodeChatMessage() with replyTo = %+v, %v", request, err)
+	}
+}
+
+func TestDecodeChatReaction(t *testing.T) {
+	request, err := decodeChatReaction([]any{"room", map[string]any{"messageId": "m1", "emoji": "👍"}})
+	if err != nil || request.messageID != "m1" || request.emoji != "👍" || request.remove {
+		t.Errorf("decodeChatReaction() = %+v, %v", request, err)
+	}
+	request, err = decodeChatReaction([]any{"room", map[string]any{"messageId": "m1", "emoji": "👍", "remove": true}})
+	if err != nil || !request.remove {
+		t.Errorf("decodeChatReaction() with remove = %+v, %v", request, err)
+	}
+
+	for field, args := range map[string][]any{
+		"reaction":           {"room"},
+		"reaction.messageId": {"room", map[string]any{"emoji": "👍"}},
+		"reaction.emoji":     {"room", map[string]any{"messageId": "m1", "emoji": strings.Repeat("x", maxReactionLength+1)}},
+		"reaction.remove":    {"room", map[string]any{"messageId": "m1", "emoji": "👍", "remove": "yes"}},
+	} {
+		_, err := decodeChatReaction(args)
+		assertPayloadError(t, err, field)
+	}
+}
+
+func TestDecodeModeration(t *testing.T) {
+	request, err := decodeModeration("moderate-ban", []any{"room", "socket", float64(30)})
+	if err != nil || request.banSeconds != 30 {
+		t.Errorf("decodeModeration(ban) = %+v, %v", request, err)
+	}
+	request, err = decodeModeration("moderate-mute", []any{"room", "socket"})
+	if err != nil || !request.muted {
+		t.Errorf("decodeModeration(mute) without muted = %+v, %v, want muted", request, err)
+	}
+
+	_, err = decodeModeration("moderate-kick", []any{"room"})
+	assertPayloadError(t, err, "socketId")
+	_, err = decodeModeration("moderate-ban", []any{"room", "socket", "forever"})
+	assertPayloadError(t, err, "seconds")
+	_, err = decodeModeration("moderate-mute", []any{"room", "socket", "no"})
+	assertPayloadError(t, err, "muted")
+}
+
+func TestDecodeCanvasReaction(t *testing.T) {
+	request, err := decodeCanvasReaction([]any{"room", map[string]any{"x": float64(10.5), "y": float64(-3), "emoji": "👀"}})
+	if err != nil || request.x != 10.5 || request.y != -3 || request.emoji != "👀" {
+		t.Errorf("decodeCanvasReaction() = %+v, %v", request, err)
+	}
+
+	for field, args := range map[string][]any{
+		"reaction":       {"room"},
+		"reaction.x":     {"room", map[string]any{"x": "left", "y": float64(0), "emoji": "👀"}},
+		"reaction.y":     {"room", map[string]any{"x": float64(0), "y": math.NaN(), "emoji": "👀"}},
+		"reaction.emoji": {"room", map[string]any{"x": float64(0), "y": float64(0)}},
+	} {
+		_, err := decodeCanvasReaction(args)
+		assertPayloadError(t, err, field)
+	}
+}
+
+func TestDecodeLaserPointer(t *testing.T) {
+	request, err := decodeLaserPointer([]any{"room", map[string]any{"x": float64(1), "y": float64(2)}})
+	if err != nil || !request.active || request.x != 1 || request.y != 2 {
+		t.Errorf("decodeLaserPointer() = %+v, %v", request, err)
+	}
+	_, err = decodeLaserPointer([]any{"room", map[string]any{"x": float64(1), "y": float64(2), "active": "no"}})
+	assertPayloadError(t, err, "pointer.active")
+	_, err = decodeLaserPointer([]any{"room"})
+	assertPayloadError(t, err, "pointer")
+}
+
+func TestDecodePresentation(t *testing.T) {
+	request, err := decodePresentation([]any{"room"})
+	if err != nil || !request.presenting || request.slide != -1 {
+		t.Errorf("decodePresentation() without state = %+v, %v", request, err)
+	}
+	request, err = decodePresentation([]any{"room", map[string]any{"presenting": false, "frameId": "f1", "slide": float64(3)}})
+	if err != nil || request.presenting || request.frameID != "f1" || request.slide != 3 {
+		t.Errorf("decodePresentation() = %+v, %v", request, err)
+	}
+
+	for field, state := range map[string]map[string]any{
+		"state.presenting": {"presenting": "yes"},
+		"state.frameId":    {"frameId": float64(1)},
+		"state.slide":      {"slide": float64(1.5)},
+	} {
+		_, err := decodePresentation([]any{"room", state})
+		assertPayloadError(t, err, field)
+	}
+}
+
+func TestDecodeTyping(t *testing.T) {
+	request, err := decodeTyping([]any{"room"})
+	if err != nil || !request.typing {
+		t.Errorf("decodeTyping() without typing = %+v, %v, want typing", request, err)
+	}
+	request, err = decodeTyping([]any{"room", false})
+	if err != nil || request.typing {
+		t.Errorf("decodeTyping(false) = %+v, %v", request, err)
+	}
+
+	_, err = decodeTyping([]any{})
+	assertPayloadError(t, err, "roomId")
+	_, err = decodeTyping([]any{"room", "yes"})
+	assertPayloadError(t, err, "typing")
+}
+
+func TestDecodeRTCSignal(t *testing.T) {
+	request, err := decodeRTCSignal([]any{"room", "peer", map[string]any{"sdp": "..."}})
+	if err != nil || request.target != "peer" || request.payload == nil {
+		t.Errorf("decodeRTCSignal() = %+v, %v", request, err)
+	}
+	_, err = decodeRTCSignal([]any{"room", "peer"})
+	assertPayloadError(t, err, "payload")
+}
+
+func TestErrorAckPayload(t *testing.T) {
+	_, err := decodeJoinRoom([]any{float64(42)})
+	payload := errorAckPayload(err)
+	if payload["status"] != "error" || payload["code"] != apierror.InvalidPayload || payload["field"] != "roomId" ||
+		payload["error"] != "roomId: must be a string, got number" {
+		t.Errorf("errorAckPayload() = %v", payload)
+	}
+
+	payload = errorAckPayload(apierror.New(apierror.Muted, "muted in room"))
+	if payload["code"] != apierror.Muted || payload["error"] != "muted in room" {
+		t.Errorf("errorAckPayload() of a coded error = %v", payload)
+	}
+
+	payload = errorAckPayload(errors.New("failed to check room permissions"))
+	if payload["code"] != apierror.Internal {
+		t.Errorf("errorAckPayload() of a plain error = %v, want the internal code", payload)
+	}
+
+	payload = errorAckPayload(&filteredError{reason: "blocked words"})
+	if payload["code"] != apierror.Filtered || payload["reason"] != "blocked words" {
+		t.Errorf("errorAckPayload() of a filtered message = %v", payload)
+	}
+}
+
+func assertPayloadError(t *testing.T, err error, field string) {
+	t.Helper()
+	var invalid *payloadError
+	if !errors.As(err, &invalid) {
+		t.Errorf("error = %v, want a payload error for %s", err, field)
+		return
+	}
+	if invalid.field != field {
+		t.Errorf("error field = %q, want %q (%v)", invalid.field, field, err)
+	}
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"excalidraw-server/apierror"
+	"sync"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// roomSequence numbers the broadcasts relayed in a room. mu is held while a
+// broadcast is numbered and relayed, so members get them in order and a
+// missing number means a dropped broadcast.
+type roomSequence struct {
+	mu  sync.Mutex
+	seq int64
+}
+
+var (
+	roomSequences      = make(map[string]*roomSequence)
+	roomSequencesMutex sync.Mutex
+)
+
+func getRoomSequence(roomID string) *roomSequence {
+	roomSequencesMutex.Lock()
+	defer roomSequencesMutex.Unlock()
+
+	sequence, exists := roomSequences[roomID]
+	if !exists {
+		sequence = &roomSequence{}
+		roomSequences[roomID] = sequence
+	}
+	return sequence
+}
+
+// sequenceBroadcast gives a broadcast the room's next seq and relays it with
+// it. The seq is used up even if relaying fails, since some members may have
+// got it.
+func sequenceBroadcast(roomID string, relay func(seq int64) error) (int64, error) {
+	sequence := getRoomSequence(roomID)
+	sequence.mu.Lock()
+	defer sequence.mu.Unlock()
+
+	sequence.seq++
+	return sequence.seq, relay(sequence.seq)
+}
+
+// currentBroadcastSeq returns the seq of the room's last broadcast, or zero.
+func currentBroadcastSeq(roomID string) int64 {
+	roomSequencesMutex.Lock()
+	sequence, exists := roomSequences[roomID]
+	roomSequencesMutex.Unlock()
+	if !exists {
+		return 0
+	}
+
+	sequence.mu.Lock()
+	defer sequence.mu.Unlock()
+	return sequence.seq
+}
+
+func clearBroadcastSeq(roomID string) {
+	roomSequencesMutex.Lock()
+	defer roomSequencesMutex.Unlock()
+	delete(roomSequences, roomID)
+}
+
+// handleResyncRequest answers resync-request(roomId) from a member that saw
+// a gap in the broadcast seq: it gets the room's last full scene and delta
+// checkpoint, whichever the server has, and the seq to continue from.
+func handleResyncRequest(socket *socketio.Socket, datas []any) {
+	ack, args := extractAck(datas)
+	roomID, err := stringArg(args, 0, "roomId")
+	if err == nil && !socket.Rooms().Has(socketio.Room(roomID)) {
+		err = apierror.Errorf(apierror.NotInRoom, "not in room %s", roomID)
+	}
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+
+	wakeRoom(roomID, false)
+	seq := currentBroadcastSeq(roomID)
+	sceneInitSent := sendSceneInit(socket, roomID)
+	checkpointSent := sendCheckpoint(socket, roomID)
+	respondWithAck(socket, ack, "", map[string]any{
+		"status":     "ok",
+		"seq":        seq,
+		"scene_init": sceneInitSent,
+		"checkpoint": checkpointSent,
+	}, nil)
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestSequenceBroadcast(t *testing.T) {
+	defer clearBroadcastSeq("room")
+
+	if seq := currentBroadcastSeq("room"); seq != 0 {
+		t.Errorf("currentBroadcastSeq() of a new room = %d, want 0", seq)
+	}
+
+	var relayed []int64
+	relay := func(seq int64) error {
+		relayed = append(relayed, seq)
+		return nil
+	}
+	for want := int64(1); want <= 3; want++ {
+		if seq, err := sequenceBroadcast("room", relay); err != nil || seq != want {
+			t.Errorf("sequenceBroadcast() = %d, %v, want %d", seq, err, want)
+		}
+	}
+
+	// A failed relay still uses up its seq
+	failure := errors.New("emit failed")
+	if seq, err := sequenceBroadcast("room", func(int64) error { return failure }); seq != 4 || !errors.Is(err, failure) {
+		t.Errorf("sequenceBroadcast() with a failing relay = %d, %v", seq, err)
+	}
+	if seq := currentBroadcastSeq("room"); seq != 4 {
+		t.Errorf("currentBroadcastSeq() = %d, want 4", seq)
+	}
+	if seq := currentBroadcastSeq("other"); seq != 0 {
+		t.Errorf("currentBroadcastSeq() of another room = %d, want 0", seq)
+	}
+
+	clearBroadcastSeq("room")
+	if seq, _ := sequenceBroadcast("room", relay); seq != 1 {
+		t.Errorf("sequenceBroadcast() after clearing = %d, want 1", seq)
+	}
+}
+
+func TestSequenceBroadcastOrder(t *testing.T) {
+	defer clearBroadcastSeq("room")
+
+	var relayed []int64
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = sequenceBroadcast("room", func(seq int64) error {
+				// Relays run one at a time, so this needs no lock
+				relayed = append(relayed, seq)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	for i, seq := range relayed {
+		if seq != int64(i+1) {
+			t.Fatalf("relay %d got seq %d; broadcasts went out of order", i, seq)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+// rateWindow is the period message rates are averaged over, in seconds.
+const rateWindow = 60
+
+// RoomMember is a socket in a room as reported by the room stats.
+type RoomMember struct {
+	SocketID string    `json:"socket_id"`
+	User     *UserInfo `json:"user,omitempty"`
+	JoinedAt int64     `json:"joined_at"`
+}
+
+// RoomStats describes the members and traffic of a live room.
+type RoomStats struct {
+	RoomID         string       `json:"room_id"`
+	Mode           RoomMode     `json:"mode"`
+	Users          []RoomMember `json:"users"`
+	Messages       int64        `json:"messages"`
+	BytesBroadcast int64        `json:"bytes_broadcast"`
+	// MessagesPerMinute counts broadcasts over the last minute.
+	MessagesPerMinute int64 `json:"messages_per_minute"`
+}
+
+// roomTraffic tracks the members and broadcast counters of one room.
+type roomTraffic struct {
+	members  map[socketio.SocketId]RoomMember
+	messages int64
+	bytes    int64
+	// active is the last join or broadcast
+	active time.Time
+	// buckets counts messages per second over the rate window, indexed by
+	// unix second modulo rateWindow; stamps records which second each holds.
+	buckets [rateWindow]int64
+	stamps  [rateWindow]int64
+	// latencies of the latest relayed broadcasts, for room-stats
+	latencies relayLatencies
+}
+
+var (
+	roomTraffics      = make(map[string]*roomTraffic)
+	roomTrafficsMutex sync.Mutex
+
+	// broadcastsTotal and broadcastBytesTotal count every broadcast since
+	// the server started, across rooms
+	broadcastsTotal     atomic.Int64
+	broadcastBytesTotal atomic.Int64
+)
+
+func getRoomTraffic(roomID string) *roomTraffic {
+	traffic, exists := roomTraffics[roomID]
+	if !exists {
+		traffic = &roomTraffic{members: make(map[socketio.SocketId]RoomMember)}
+		roomTraffics[roomID] = traffic
+	}
+	return traffic
+}
+
+// trackJoin records when a socket joined a room.
+func trackJoin(roomID string, socketID socketio.SocketId, user *UserInfo, now time.Time) {
+	roomTrafficsMutex.Lock()
+	defer roomTrafficsMutex.Unlock()
+
+	traffic := getRoomTraffic(roomID)
+	if _, exists := traffic.members[socketID]; exists {
+		return
+	}
+	traffic.active = now
+	traffic.members[socketID] = RoomMember{
+		SocketID: string(socketID),
+		User:     user,
+		JoinedAt: now.UnixMilli(),
+	}
+}
+
+// trackLeave forgets a socket that left a room.
+func trackLeave(roomID string, socketID socketio.SocketId) {
+	roomTrafficsMutex.Lock()
+	defer roomTrafficsMutex.Unlock()
+
+	if traffic, exists := roomTraffics[roomID]; exists {
+		delete(traffic.members, socketID)
+	}
+}
+
+// trackBroadcast counts a broadcast of size bytes relayed in a room.
+func trackBroadcast(roomID string, size int, now time.Time) {
+	broadcastsTotal.Add(1)
+	broadcastBytesTotal.Add(int64(size))
+
+	roomTrafficsMutex.Lock()
+	defer roomTrafficsMutex.Unlock()
+
+	traffic := getRoomTraffic(roomID)
+	traffic.active = now
+	traffic.messages++
+	traffic.bytes += int64(size)
+
+	second := now.Unix()
+	slot := second % rateWindow
+	if traffic.stamps[slot] != second {
+		traffic.stamps[slot] = second
+		traffic.buckets[slot] = 0
+	}
+	traffic.buckets[slot]++
+}
+
+// idleRooms returns the live rooms without a join or broadcast since before.
+func idleRooms(before time.Time) []string {
+	roomTrafficsMutex.Lock()
+	defer roomTrafficsMutex.Unlock()
+
+	var rooms []string
+	for roomID, traffic := range roomTraffics {
+		if len(traffic.members) > 0 && traffic.active.Before(before) {
+			rooms = append(rooms, roomID)
+		}
+	}
+	return rooms
+}
+
+func clearRoomTraffic(roomID string) {
+	roomTrafficsMutex.Lock()
+	defer roomTrafficsMutex.Unlock()
+	delete(roomTraffics, roomID)
+}
+
+// payloadSize estimates the wire size of a broadcast payload.
+func payloadSize(payload any) int {
+	switch value := payload.(type) {
+	case []byte:
+		return len(value)
+	case binaryArg:
+		return len(value.Bytes())
+	case string:
+		return len(value)
+	case nil:
+		return 0
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// IsUserInRoom reports whether an authenticated user has a socket in a room
+// on this instance.
+func IsUserInRoom(roomID, userID string) bool {
+	roomTrafficsMutex.Lock()
+	defer roomTrafficsMutex.Unlock()
+
+	if traffic, exists := roomTraffics[roomID]; exists && userID != "" {
+		for _, member := range traffic.members {
+			if member.User != nil && member.User.ID == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetRoomStats reports the members and traffic of a live room.
+func GetRoomStats(roomID string) (RoomStats, bool) {
+	return roomStatsAt(roomID, time.Now())
+}
+
+func roomStatsAt(roomID string, now time.Time) (RoomStats, bool) {
+	roomsMutex.RLock()
+	_, active := activeRooms[roomID]
+	roomsMutex.RUnlock()
+	if !active {
+		return RoomStats{}, false
+	}
+
+	stats := RoomStats{
+		RoomID: roomID,
+		Mode:   getRoomMode(roomID),
+		Users:  []RoomMember{},
+	}
+
+	roomTrafficsMutex.Lock()
+	defer roomTrafficsMutex.Unlock()
+
+	traffic, exists := roomTraffics[roomID]
+	if !exists {
+		return stats, true
+	}
+	for _, member := range traffic.members {
+		stats.Users = append(stats.Users, member)
+	}
+	sort.Slice(stats.Users, func(i, j int) bool {
+		if stats.Users[i].JoinedAt != stats.Users[j].JoinedAt {
+			return stats.Users[i].JoinedAt < stats.Users[j].JoinedAt
+		}
+		return stats.Users[i].SocketID < stats.Users[j].SocketID
+	})
+
+	stats.Messages = traffic.messages
+	stats.BytesBroadcast = traffic.bytes
+	second := now.Unix()
+	for slot, stamp := range traffic.stamps {
+		if second-stamp < rateWindow {
+			stats.MessagesPerMinute += traffic.buckets[slot]
+		}
+	}
+	return stats, true
+}
+
+// DisconnectRoom force-disconnects every socket and native WebSocket peer
+// in a room and returns how many were connected.
+func DisconnectRoom(srv *socketio.Server, roomID string) int {
+	rawRoomsMutex.RLock()
+	disconnected := len(rawRooms[roomID])
+	for peer := range rawRooms[roomID] {
+		peer.close()
+	}
+	rawRoomsMutex.RUnlock()
+
+	operator := srv.In(socketio.Room(roomID))
+	sockets, err := operator.AllSockets()
+	if err != nil || sockets.Len() == 0 {
+		return disconnected
+	}
+	operator.DisconnectSockets(true)
+	return disconnected + sockets.Len()
+}
@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoomStats(t *testing.T) {
+	roomsMutex.Lock()
+	activeRooms["room"] = 2
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(activeRooms, "room")
+		roomsMutex.Unlock()
+		clearRoomTraffic("room")
+	}()
+
+	now := time.Unix(1000, 0)
+	trackJoin("room", "socket-b", nil, now.Add(time.Second))
+	trackJoin("room", "socket-a", &UserInfo{ID: "42"}, now)
+	trackJoin("room", "socket-a", nil, now.Add(time.Hour))
+	trackBroadcast("room", 100, now.Add(-2*time.Minute))
+	trackBroadcast("room", 10, now)
+	trackBroadcast("room", 5, now.Add(30*time.Second))
+
+	stats, ok := roomStatsAt("room", now.Add(30*time.Second))
+	if !ok {
+		t.Fatal("expected stats for an active room")
+	}
+	if len(stats.Users) != 2 || stats.Users[0].SocketID != "socket-a" || stats.Users[0].JoinedAt != now.UnixMilli() || stats.Users[0].User == nil {
+		t.Errorf("users = %+v", stats.Users)
+	}
+	if stats.Messages != 3 || stats.BytesBroadcast != 115 {
+		t.Errorf("messages = %d, bytes = %d", stats.Messages, stats.BytesBroadcast)
+	}
+	if stats.MessagesPerMinute != 2 {
+		t.Errorf("messages per minute = %d, want 2", stats.MessagesPerMinute)
+	}
+
+	trackLeave("room", "socket-a")
+	if stats, _ := roomStatsAt("room", now); len(stats.Users) != 1 {
+		t.Errorf("expected socket-a to be removed, got %+v", stats.Users)
+	}
+
+	if _, ok := GetRoomStats("missing"); ok {
+		t.Error("expected no stats for an unknown room")
+	}
+}
+
+func TestPayloadSize(t *testing.T) {
+	tests := []struct {
+		payload any
+		want    int
+	}{
+		{[]byte("abcd"), 4},
+		{"abc", 3},
+		{nil, 0},
+		{map[string]any{"a": 1}, len(`{"a":1}`)},
+	}
+	for _, tt := range tests {
+		if got := payloadSize(tt.payload); got != tt.want {
+			t.Errorf("payloadSize(%v) = %d, want %d", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestIsUserInRoom(t *testing.T) {
+	defer clearRoomTraffic("members")
+
+	trackJoin("members", "socket-a", &UserInfo{ID: "alice"}, time.Now())
+	trackJoin("members", "socket-b", nil, time.Now())
+	if !IsUserInRoom("members", "alice") {
+		t.Error("alice isn't in the room")
+	}
+	if IsUserInRoom("members", "bob") || IsUserInRoom("members", "") || IsUserInRoom("other", "alice") {
+		t.Error("found a user who isn't in the room")
+	}
+	trackLeave("members", "socket-a")
+	if IsUserInRoom("members", "alice") {
+		t.Error("alice is still in the room after leaving")
+	}
+}
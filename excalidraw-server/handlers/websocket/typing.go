@@ -0,0 +1,144 @@
+package websocket
+
+import (
+	"excalidraw-server/apierror"
+	"sync"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+const (
+	// typingRelayInterval is how often a socket that keeps sending
+	// server-typing is relayed to the room again
+	typingRelayInterval = 2 * time.Second
+	// typingTimeout ends a typing indicator that wasn't refreshed for this
+	// long, as when the client went away without saying it stopped
+	typingTimeout = 6 * time.Second
+)
+
+// typingEmitter sends client-typing to everyone in a room but the typist.
+type typingEmitter func(roomID string, socketID socketio.SocketId, payload map[string]any)
+
+type typingKey struct {
+	roomID   string
+	socketID socketio.SocketId
+}
+
+// typingState is a socket typing in a room.
+type typingState struct {
+	user      *UserInfo
+	relayedAt time.Time
+	timer     *time.Timer
+}
+
+var (
+	typists      = make(map[typingKey]*typingState)
+	typistsMutex sync.Mutex
+)
+
+// handleTyping relays server-typing(roomId, typing?) to the rest of the room
+// as a volatile client-typing { roomId, socketId, user, typing }. Clients can
+// send it on every keystroke: the server relays a typist once, again every
+// typingRelayInterval while it keeps typing, and tells the room it stopped
+// when it says so, sends its message, leaves or goes quiet for
+// typingTimeout.
+func handleTyping(socket *socketio.Socket, srv *socketio.Server, datas []any) {
+	ack, args := extractAck(datas)
+	request, err := decodeTyping(args)
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+	roomID := request.roomID
+
+	switch {
+	case !socket.Rooms().Has(socketio.Room(roomID)):
+		err = apierror.Errorf(apierror.NotInRoom, "not in room %s", roomID)
+	case request.typing && isMuted(roomID, socket.Id()):
+		err = apierror.New(apierror.Muted, "muted in room")
+	}
+	if err != nil {
+		respondWithAck(socket, ack, "", errorAckPayload(err), err)
+		return
+	}
+
+	emit := roomTypingEmitter(srv)
+	if request.typing {
+		startTyping(roomID, socket.Id(), socketUser(socket), time.Now(), emit)
+	} else {
+		stopTyping(roomID, socket.Id(), emit)
+	}
+	respondWithAck(socket, ack, "", map[string]any{"status": "ok"}, nil)
+}
+
+// startTyping marks a socket as typing in a room, telling the room unless it
+// was told less than typingRelayInterval ago.
+func startTyping(roomID string, socketID socketio.SocketId, user *UserInfo, now time.Time, emit typingEmitter) {
+	key := typingKey{roomID: roomID, socketID: socketID}
+
+	typistsMutex.Lock()
+	state, exists := typists[key]
+	if exists && now.Sub(state.relayedAt) < typingRelayInterval {
+		state.timer.Reset(typingTimeout)
+		typistsMutex.Unlock()
+		return
+	}
+	if exists {
+		state.timer.Stop()
+	}
+	state = &typingState{user: user, relayedAt: now}
+	state.timer = time.AfterFunc(typingTimeout, func() {
+		expireTyping(key, state, emit)
+	})
+	typists[key] = state
+	typistsMutex.Unlock()
+
+	emit(roomID, socketID, typingPayload(key, user, true))
+}
+
+// stopTyping tells the room a socket stopped typing, if it was.
+func stopTyping(roomID string, socketID socketio.SocketId, emit typingEmitter) {
+	key := typingKey{roomID: roomID, socketID: socketID}
+
+	typistsMutex.Lock()
+	state, exists := typists[key]
+	if exists {
+		state.timer.Stop()
+		delete(typists, key)
+	}
+	typistsMutex.Unlock()
+
+	if exists {
+		emit(roomID, socketID, typingPayload(key, state.user, false))
+	}
+}
+
+// expireTyping ends a typing indicator that timed out, unless the socket
+// started typing again since.
+func expireTyping(key typingKey, state *typingState, emit typingEmitter) {
+	typistsMutex.Lock()
+	if typists[key] != state {
+		typistsMutex.Unlock()
+		return
+	}
+	delete(typists, key)
+	typistsMutex.Unlock()
+
+	emit(key.roomID, key.socketID, typingPayload(key, state.user, false))
+}
+
+func roomTypingEmitter(srv *socketio.Server) typingEmitter {
+	return func(roomID string, socketID socketio.SocketId, payload map[string]any) {
+		_ = srv.To(socketio.Room(roomID)).Except(socketio.Room(socketID)).Volatile().Emit("client-typing", payload)
+	}
+}
+
+func typingPayload(key typingKey, user *UserInfo, typing bool) map[string]any {
+	return map[string]any{
+		"roomId":   key.roomID,
+		"socketId": string(key.socketID),
+		"user":     user,
+		"typing":   typing,
+	}
+}
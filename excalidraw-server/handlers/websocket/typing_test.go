@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	socketio "github.com/zishang520/socket.io/v2/socket"
+)
+
+func recordTyping(events *[]map[string]any) typingEmitter {
+	return func(_ string, _ socketio.SocketId, payload map[string]any) {
+		*events = append(*events, payload)
+	}
+}
+
+func TestTypingIsDebounced(t *testing.T) {
+	var events []map[string]any
+	emit := recordTyping(&events)
+	user := &UserInfo{ID: "alice"}
+	now := time.Now()
+	defer stopTyping("typing-room", "s1", emit)
+
+	startTyping("typing-room", "s1", user, now, emit)
+	startTyping("typing-room", "s1", user, now.Add(time.Second), emit)
+	if len(events) != 1 || events[0]["typing"] != true || events[0]["user"] != user {
+		t.Fatalf("events after two quick keystrokes = %v, want one typing event", events)
+	}
+
+	startTyping("typing-room", "s1", user, now.Add(typingRelayInterval), emit)
+	if len(events) != 2 {
+		t.Errorf("events after typingRelayInterval = %d, want 2", len(events))
+	}
+
+	stopTyping("typing-room", "s1", emit)
+	stopTyping("typing-room", "s1", emit)
+	if len(events) != 3 || events[2]["typing"] != false || events[2]["socketId"] != "s1" {
+		t.Errorf("events after stopping twice = %v, want one stop event", events)
+	}
+}
+
+func TestTypingExpires(t *testing.T) {
+	var events []map[string]any
+	emit := recordTyping(&events)
+	key := typingKey{roomID: "typing-room", socketID: "s2"}
+
+	startTyping(key.roomID, key.socketID, nil, time.Now(), emit)
+	typistsMutex.Lock()
+	stale := typists[key]
+	typistsMutex.Unlock()
+	stale.timer.Stop()
+
+	startTyping(key.roomID, key.socketID, nil, time.Now().Add(typingRelayInterval), emit)
+	expireTyping(key, stale, emit)
+	if len(events) != 2 {
+		t.Errorf("events after a stale timeout = %d, want 2", len(events))
+	}
+
+	typistsMutex.Lock()
+	current := typists[key]
+	typistsMutex.Unlock()
+	current.timer.Stop()
+	expireTyping(key, current, emit)
+	if len(events) != 3 || events[2]["typing"] != false {
+		t.Errorf("events after timing out = %v, want a stop event", events)
+	}
+	typistsMutex.Lock()
+	defer typistsMutex.Unlock()
+	if _, exists := typists[key]; exists {
+		t.Error("typist kept after timing out")
+	}
+}
@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"context"
+	"sort"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// chatRooms returns the rooms with chat in memory or saved state, sorted.
+func chatRooms(ctx context.Context) ([]string, error) {
+	rooms := make(map[string]struct{})
+	chatHistoryMutex.RLock()
+	for roomID := range chatHistory {
+		rooms[roomID] = struct{}{}
+	}
+	chatHistoryMutex.RUnlock()
+
+	if store := getRoomStateStore(); store != nil {
+		saved, err := store.ListRoomStates(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, roomID := range saved {
+			rooms[roomID] = struct{}{}
+		}
+	}
+
+	roomIDs := make([]string, 0, len(rooms))
+	for roomID := range rooms {
+		roomIDs = append(roomIDs, roomID)
+	}
+	sort.Strings(roomIDs)
+	return roomIDs, nil
+}
+
+// sentBy reports whether an authenticated user sent a message.
+func sentBy(message ChatMessage, userID string) bool {
+	return message.User != nil && message.User.ID == userID
+}
+
+// UserChat returns the chat messages userID sent, in live rooms and the ones
+// hibernated rooms saved, by room and oldest first.
+func UserChat(ctx context.Context, userID string) ([]ChatMessage, error) {
+	roomIDs, err := chatRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []ChatMessage{}
+	for _, roomID := range roomIDs {
+		history, err := GetRoomChat(ctx, roomID)
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range history {
+			if sentBy(message, userID) {
+				messages = append(messages, message)
+			}
+		}
+	}
+	return messages, nil
+}
+
+// anonymizeChat drops userID from the messages they sent and the reactions
+// they left, in place, and returns how many messages they sent.
+func anonymizeChat(messages []ChatMessage, userID string) (sent int, changed bool) {
+	for i := range messages {
+		if sentBy(messages[i], userID) {
+			messages[i].User = nil
+			sent++
+			changed = true
+		}
+		for emoji, reactors := range messages[i].Reactions {
+			kept := reactors[:0]
+			for _, reactor := range reactors {
+				if reactor != userID {
+					kept = append(kept, reactor)
+				}
+			}
+			if len(kept) == len(reactors) {
+				continue
+			}
+			changed = true
+			if len(kept) == 0 {
+				delete(messages[i].Reactions, emoji)
+			} else {
+				messages[i].Reactions[emoji] = kept
+			}
+		}
+		if len(messages[i].Reactions) == 0 {
+			messages[i].Reactions = nil
+		}
+	}
+	return sent, changed
+}
+
+// AnonymizeUserChat removes userID from the chat messages they sent and the
+// reactions they left, in live rooms and the state hibernated rooms saved,
+// and returns how many messages they sent. The messages themselves stay, so
+// conversations keep making sense.
+func AnonymizeUserChat(ctx context.Context, userID string) (int, error) {
+	roomIDs, err := chatRooms(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, roomID := range roomIDs {
+		if isLiveRoom(roomID) {
+			wakeRoom(roomID, false)
+			chatHistoryMutex.Lock()
+			sent, _ := anonymizeChat(chatHistory[roomID], userID)
+			setChatHistory(roomID, chatHistory[roomID])
+			chatHistoryMutex.Unlock()
+			total += sent
+			continue
+		}
+
+		state, err := loadRoomState(ctx, roomID)
+		if err != nil {
+			return total, err
+		}
+		if state == nil {
+			continue
+		}
+		sent, changed := anonymizeChat(state.Chat, userID)
+		if !changed {
+			continue
+		}
+		data, err := msgpack.Marshal(state)
+		if err != nil {
+			return total, err
+		}
+		if err := getRoomStateStore().PutRoomState(ctx, roomID, data); err != nil {
+			return total, err
+		}
+		total += sent
+	}
+	return total, nil
+}
@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestUserChat(t *testing.T) {
+	ctx := context.Background()
+	store := useRoomStateStore(t)
+
+	alice := &UserInfo{ID: "u-alice", Name: "Alice"}
+	bob := &UserInfo{ID: "u-bob", Name: "Bob"}
+	defer clearChatHistory("live-chat")
+	addChatMessage("live-chat", ChatMessage{ID: "m1", RoomID: "live-chat", Content: "hi", User: alice})
+	addChatMessage("live-chat", ChatMessage{ID: "m2", RoomID: "live-chat", Content: "hey", User: bob,
+		Reactions: map[string][]string{"👍": {"u-alice", "u-carol"}, "🎉": {"u-alice"}}})
+	roomsMutex.Lock()
+	activeRooms["live-chat"] = 1
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(activeRooms, "live-chat")
+		roomsMutex.Unlock()
+	}()
+
+	data, err := msgpack.Marshal(roomState{Chat: []ChatMessage{
+		{ID: "m3", RoomID: "sleeping-chat", Content: "anyone?", User: alice},
+		{ID: "m4", RoomID: "sleeping-chat", Content: "anonymous", Sender: "socket"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutRoomState(ctx, "sleeping-chat", data); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := UserChat(ctx, "u-alice")
+	if err != nil {
+		t.Fatalf("UserChat() error = %v", err)
+	}
+	var ids []string
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"m1", "m3"}) {
+		t.Errorf("UserChat() = %v, want m1 and m3", ids)
+	}
+
+	anonymized, err := AnonymizeUserChat(ctx, "u-alice")
+	if err != nil || anonymized != 2 {
+		t.Fatalf("AnonymizeUserChat() = %d, %v, want 2", anonymized, err)
+	}
+	if messages, _ := UserChat(ctx, "u-alice"); len(messages) != 0 {
+		t.Errorf("UserChat() after anonymizing = %+v, want none", messages)
+	}
+
+	live := getChatHistory("live-chat")
+	if live[0].User != nil || live[0].Content != "hi" {
+		t.Errorf("anonymized message = %+v, want its content without the user", live[0])
+	}
+	if live[1].User != bob || !reflect.DeepEqual(live[1].Reactions, map[string][]string{"👍": {"u-carol"}}) {
+		t.Errorf("other user's message = %+v, want only their reactions dropped", live[1])
+	}
+	saved, err := loadRoomState(ctx, "sleeping-chat")
+	if err != nil || len(saved.Chat) != 2 || saved.Chat[0].User != nil || saved.Chat[1].Sender != "socket" {
+		t.Errorf("saved chat after anonymizing = %+v, %v", saved, err)
+	}
+}
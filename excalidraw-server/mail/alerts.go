@@ -0,0 +1,118 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// alertRepeatInterval is how long an alert with the same message is held
+	// back after it was mailed
+	alertRepeatInterval = time.Hour
+	// maxAlertsPerHour bounds the alerts mailed in an hour, so a failing
+	// store doesn't flood the admins' inboxes
+	maxAlertsPerHour = 10
+)
+
+// AlertHook is a logrus hook that mails error, fatal and panic log entries
+// to the admins. Repeats of a message are mailed at most once an hour, and
+// at most maxAlertsPerHour alerts are mailed in an hour.
+type AlertHook struct {
+	mailer   Mailer
+	to       []string
+	instance string
+
+	mu     sync.Mutex
+	sent   map[string]time.Time
+	window time.Time
+	count  int
+	// now is time.Now, replaced in tests
+	now func() time.Time
+}
+
+// NewAlertHook mails alerts through mailer to the admins at to, naming the
+// server instance.
+func NewAlertHook(mailer Mailer, to []string, instance string) (*AlertHook, error) {
+	if _, err := addresses(to); err != nil {
+		return nil, err
+	}
+	return &AlertHook{
+		mailer:   mailer,
+		to:       to,
+		instance: instance,
+		sent:     make(map[string]time.Time),
+		now:      time.Now,
+	}, nil
+}
+
+func (h *AlertHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+// Fire mails entry in the background unless it is held back. Send failures
+// are logged as warnings, which don't fire the hook again.
+func (h *AlertHook) Fire(entry *logrus.Entry) error {
+	if !h.allow(entry.Message) {
+		return nil
+	}
+
+	fields := make(map[string]string, len(entry.Data))
+	for key, value := range entry.Data {
+		fields[key] = fmt.Sprint(value)
+	}
+	message, err := Render("alert", h.to, Alert{
+		Instance: h.instance,
+		Level:    entry.Level.String(),
+		Message:  entry.Message,
+		Fields:   fields,
+		Time:     entry.Time.UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		return err
+	}
+
+	send := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := h.mailer.Send(ctx, message); err != nil {
+			logrus.WithError(err).Warn("Failed to mail alert")
+		}
+	}
+	if entry.Level <= logrus.FatalLevel {
+		// the process is about to exit
+		send()
+	} else {
+		go send()
+	}
+	return nil
+}
+
+// allow reports whether an alert with message may be mailed now, counting
+// it when it may.
+func (h *AlertHook) allow(message string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if now.Sub(h.window) >= time.Hour {
+		h.window, h.count = now, 0
+		for key, sentAt := range h.sent {
+			if now.Sub(sentAt) >= alertRepeatInterval {
+				delete(h.sent, key)
+			}
+		}
+	}
+	if sentAt, ok := h.sent[message]; ok && now.Sub(sentAt) < alertRepeatInterval {
+		return false
+	}
+	if h.count >= maxAlertsPerHour {
+		return false
+	}
+	h.sent[message] = now
+	h.count++
+	return true
+}
@@ -0,0 +1,78 @@
+package mail
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+func TestAlertHookThrottles(t *testing.T) {
+	hook, err := NewAlertHook(&recordingMailer{}, []string{"ops@example.com"}, "Draw")
+	if err != nil {
+		t.Fatalf("NewAlertHook() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	hook.now = func() time.Time { return now }
+
+	if !hook.allow("Backup failed") {
+		t.Fatal("first alert was held back")
+	}
+	if hook.allow("Backup failed") {
+		t.Error("repeated alert was mailed again right away")
+	}
+	for i := 1; i < maxAlertsPerHour; i++ {
+		if !hook.allow(string(rune('a' + i))) {
+			t.Fatalf("alert %d was held back", i)
+		}
+	}
+	if hook.allow("one too many") {
+		t.Error("alert over the hourly limit was mailed")
+	}
+
+	now = now.Add(time.Hour)
+	if !hook.allow("Backup failed") {
+		t.Error("repeated alert was held back after an hour")
+	}
+}
+
+func TestAlertHookFire(t *testing.T) {
+	mailer := &recordingMailer{}
+	hook, err := NewAlertHook(mailer, []string{"ops@example.com"}, "Draw")
+	if err != nil {
+		t.Fatalf("NewAlertHook() error = %v", err)
+	}
+	if _, err := NewAlertHook(mailer, []string{"nobody"}, "Draw"); err == nil {
+		t.Error("NewAlertHook() accepted an invalid address")
+	}
+
+	entry := logrus.NewEntry(logrus.New()).WithField("room_id", "r1")
+	entry.Level, entry.Message, entry.Time = logrus.FatalLevel, "Store unavailable", time.Now()
+	if err := hook.Fire(entry); err != nil {
+		t.Fatalf("Fire() error = %v", err)
+	}
+
+	// fatal alerts are sent before Fire returns
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+	if len(mailer.sent) != 1 {
+		t.Fatalf("sent %d alerts, want 1", len(mailer.sent))
+	}
+	if sent := mailer.sent[0]; sent.Subject != "[Draw] fatal: Store unavailable" || sent.To[0] != "ops@example.com" {
+		t.Errorf("alert = %+v", sent)
+	}
+}
@@ -0,0 +1,260 @@
+// Package mail sends the server's emails: share invitations, mention
+// notifications and admin alerts. Messages are rendered from the templates
+// in templates/ and sent over SMTP, or only logged in dry-run mode.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sendTimeout bounds sending one email when the caller set no deadline.
+const sendTimeout = 30 * time.Second
+
+// Message is an email with a plain text and an HTML body.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// FromEnv builds the mailer configured by SMTP_HOST, SMTP_PORT (default
+// 587), SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM. With MAIL_DRY_RUN=true,
+// emails are logged instead of sent. It returns nil when neither is set.
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from != "" {
+		if _, err := mail.ParseAddress(from); err != nil {
+			return nil, fmt.Errorf("invalid MAIL_FROM: %w", err)
+		}
+	}
+	if os.Getenv("MAIL_DRY_RUN") == "true" {
+		return Log{}, nil
+	}
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil, nil
+	}
+	if from == "" {
+		return nil, fmt.Errorf("SMTP_HOST requires MAIL_FROM to be set")
+	}
+	port := 587
+	if value := os.Getenv("SMTP_PORT"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 65535 {
+			return nil, fmt.Errorf("invalid SMTP_PORT %q", value)
+		}
+		port = parsed
+	}
+	return &SMTP{
+		Addr:     host + ":" + strconv.Itoa(port),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}, nil
+}
+
+// SMTP sends emails through an SMTP server, upgrading the connection with
+// STARTTLS when the server offers it.
+type SMTP struct {
+	// Addr is the server's host:port
+	Addr string
+	// Username and Password log in with PLAIN auth when Username is set;
+	// net/smtp only sends them over TLS or to localhost
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTP) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sender, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	recipients, err := addresses(message.To)
+	if err != nil {
+		return err
+	}
+	body, err := compose(sender, recipients, message)
+	if err != nil {
+		return err
+	}
+	to := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		to[i] = recipient.Address
+	}
+
+	if err := s.send(ctx, sender.Address, to, body); err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+	return nil
+}
+
+// send is smtp.SendMail bounded by ctx, or by sendTimeout without a
+// deadline.
+func (s *SMTP) send(ctx context.Context, from string, to []string, body []byte) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	host, _, _ := net.SplitHostPort(s.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Log is the dry-run mailer: it logs emails instead of sending them.
+type Log struct{}
+
+func (Log) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := addresses(message.To); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{
+		"to":      strings.Join(message.To, ", "),
+		"subject": message.Subject,
+	}).Info("Dry run: not sending email\n" + message.Text)
+	return nil
+}
+
+// addresses parses the recipients of an email.
+func addresses(recipients []string) ([]*mail.Address, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("email has no recipients")
+	}
+	parsed := make([]*mail.Address, 0, len(recipients))
+	for _, recipient := range recipients {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+		}
+		parsed = append(parsed, address)
+	}
+	return parsed, nil
+}
+
+// compose renders message as a multipart/alternative email from from to
+// recipients.
+func compose(from *mail.Address, recipients []*mail.Address, message Message) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", message.Text},
+		{"text/html; charset=utf-8", message.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	to := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		to[i] = recipient.String()
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", from.String())
+	fmt.Fprintf(&out, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Message-ID: %s\r\n", messageID(from.Address))
+	out.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// messageID makes a unique Message-ID in the sender's domain.
+func messageID(sender string) string {
+	_, domain, ok := strings.Cut(sender, "@")
+	if !ok {
+		domain = "localhost"
+	}
+	random := make([]byte, 12)
+	_, _ = rand.Read(random)
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">"
+}
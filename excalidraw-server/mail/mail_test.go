@@ -0,0 +1,186 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one email and sends what it got as DATA on the channel.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "DATA"):
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case strings.HasPrefix(command, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSMTPSend(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	mailer := &SMTP{Addr: addr, From: "Excalidraw <draw@example.com>"}
+	message := Message{
+		To:      []string{"Bob <bob@example.com>"},
+		Subject: "Grüße",
+		Text:    "hello bob\n",
+		HTML:    "<p>hello bob</p>",
+	}
+	if err := mailer.Send(context.Background(), message); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var data string
+	select {
+	case data = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server received no email")
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != "Grüße" {
+		t.Errorf("Subject = %q, want Grüße", subject)
+	}
+	if to := parsed.Header.Get("To"); !strings.Contains(to, "bob@example.com") {
+		t.Errorf("To = %q", to)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v)", parsed.Header.Get("Content-Type"), err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(part)
+		bodies = append(bodies, string(body))
+	}
+	if len(bodies) != 2 || strings.ReplaceAll(bodies[0], "\r\n", "\n") != message.Text || bodies[1] != message.HTML {
+		t.Errorf("bodies = %q", bodies)
+	}
+}
+
+func TestSendRejectsInvalidRecipients(t *testing.T) {
+	for _, to := range [][]string{nil, {"not an address"}, {"bob@example.com\r\nBcc: eve@example.com"}} {
+		if err := (Log{}).Send(context.Background(), Message{To: to}); err == nil {
+			t.Errorf("Send() to %q succeeded", to)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	message, err := Render("mention", []string{"bob@example.com"}, Mention{
+		Instance: "Draw",
+		From:     "Alice",
+		RoomID:   "room-1",
+		Text:     "look at <this> @bob\nsubject: injected",
+		URL:      "https://draw.example.com",
+	})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if message.Subject != "Alice mentioned you in room room-1" {
+		t.Errorf("Subject = %q", message.Subject)
+	}
+	if !strings.Contains(message.Text, "look at <this> @bob") || !strings.Contains(message.Text, "https://draw.example.com") {
+		t.Errorf("Text = %q", message.Text)
+	}
+	if !strings.Contains(message.HTML, "look at &lt;this&gt;") || strings.Contains(message.HTML, "<this>") {
+		t.Errorf("HTML doesn't escape the message: %q", message.HTML)
+	}
+
+	for _, name := range []string{"invitation", "alert"} {
+		if _, err := Render(name, []string{"bob@example.com"}, map[string]any{"Instance": "Draw", "URL": "https://draw.example.com"}); err != nil {
+			t.Errorf("Render(%q) error = %v", name, err)
+		}
+	}
+	if _, err := Render("missing", nil, nil); err == nil {
+		t.Error("Render() of an unknown email succeeded")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, key := range []string{"MAIL_FROM", "MAIL_DRY_RUN", "SMTP_HOST", "SMTP_PORT"} {
+		t.Setenv(key, "")
+	}
+	if mailer, err := FromEnv(); err != nil || mailer != nil {
+		t.Errorf("FromEnv() without settings = %v, %v, want nil", mailer, err)
+	}
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	if _, err := FromEnv(); err == nil {
+		t.Error("FromEnv() accepted SMTP_HOST without MAIL_FROM")
+	}
+	t.Setenv("MAIL_FROM", "draw@example.com")
+	t.Setenv("SMTP_PORT", "465")
+	mailer, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv() error = %v", err)
+	}
+	if smtpMailer, ok := mailer.(*SMTP); !ok || smtpMailer.Addr != "smtp.example.com:465" {
+		t.Errorf("FromEnv() = %#v", mailer)
+	}
+	t.Setenv("SMTP_PORT", "0")
+	if _, err := FromEnv(); err == nil {
+		t.Error("FromEnv() accepted SMTP_PORT=0")
+	}
+
+	t.Setenv("MAIL_DRY_RUN", "true")
+	if mailer, err := FromEnv(); err != nil || mailer != (Log{}) {
+		t.Errorf("FromEnv() in dry-run mode = %v, %v", mailer, err)
+	}
+}
@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// templateFS holds the emails: each has name.txt, the plain text body with
+// its subject in a "name.subject" template, and name.html, the HTML body.
+// layout.html defines the "header" and "footer" every HTML body uses.
+//
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+)
+
+type (
+	// Mention is the data of the mention email.
+	Mention struct {
+		Instance string
+		// From is the name of whoever wrote the message
+		From   string
+		RoomID string
+		Text   string
+		// URL opens the server, when it knows its public URL
+		URL string
+	}
+
+	// Invitation is the data of the invitation email.
+	Invitation struct {
+		Instance string
+		From     string
+		RoomID   string
+		// Message is a note from whoever sent the invitation
+		Message string
+		URL     string
+	}
+
+	// Alert is the data of the alert email sent to admins.
+	Alert struct {
+		Instance string
+		Level    string
+		Message  string
+		// Fields are the log entry's fields, as text
+		Fields map[string]string
+		Time   string
+	}
+)
+
+// Render builds the email named name for recipients to from data.
+func Render(name string, to []string, data any) (Message, error) {
+	var subject, text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		To: to,
+		// a subject is one line, whatever the data holds
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -1,19 +1,67 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"excalidraw-server/abuse"
+	"excalidraw-server/apierror"
+	"excalidraw-server/archive"
+	"excalidraw-server/auth"
+	"excalidraw-server/backup"
+	"excalidraw-server/challenge"
+	"excalidraw-server/chatfilter"
+	"excalidraw-server/cluster"
+	"excalidraw-server/config"
 	"excalidraw-server/core"
+	"excalidraw-server/filegc"
+	"excalidraw-server/grpcapi"
+	"excalidraw-server/handlers/api/account"
+	"excalidraw-server/handlers/api/adminpanel"
+	"excalidraw-server/handlers/api/apiversion"
+	"excalidraw-server/handlers/api/backups"
+	"excalidraw-server/handlers/api/bans"
+	"excalidraw-server/handlers/api/cachestats"
+	"excalidraw-server/handlers/api/canvases"
+	"excalidraw-server/handlers/api/cleanup"
+	"excalidraw-server/handlers/api/compression"
+	"excalidraw-server/handlers/api/deadline"
+	"excalidraw-server/handlers/api/diagnostics"
+	"excalidraw-server/handlers/api/diagrams"
 	"excalidraw-server/handlers/api/documents"
+	"excalidraw-server/handlers/api/files"
+	"excalidraw-server/handlers/api/graphql"
+	"excalidraw-server/handlers/api/imports"
+	"excalidraw-server/handlers/api/instance"
+	"excalidraw-server/handlers/api/libraries"
+	"excalidraw-server/handlers/api/notifications"
+	"excalidraw-server/handlers/api/openapi"
+	"excalidraw-server/handlers/api/orgs"
+	"excalidraw-server/handlers/api/recordings"
+	"excalidraw-server/handlers/api/rooms"
 	"excalidraw-server/handlers/api/snapshots"
+	"excalidraw-server/handlers/api/templates"
+	"excalidraw-server/handlers/frontend"
+	"excalidraw-server/handlers/share"
 	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/mail"
+	"excalidraw-server/notify"
+	"excalidraw-server/ratelimit"
+	"excalidraw-server/retention"
+	"excalidraw-server/sessions"
 	"excalidraw-server/stores"
+	"excalidraw-server/stores/cache"
+	"excalidraw-server/thumbnails"
+	"expvar"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -21,10 +69,143 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	socketio "github.com/zishang520/socket.io/v2/socket"
+	"golang.org/x/crypto/acme/autocert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-func setupRouter(documentStore core.DocumentStore) *chi.Mux {
+// routerOptions carries the optional HTTP middleware configured at startup.
+type routerOptions struct {
+	// basePath is the URL prefix the router is mounted under, if any.
+	basePath string
+	// trustProxy takes client addresses from X-Forwarded-For / X-Real-IP.
+	trustProxy bool
+	// rateLimit guards endpoints that create data; nil disables it.
+	rateLimit func(http.Handler) http.Handler
+	// abuse bans clients that keep misbehaving; nil leaves its admin
+	// endpoints out.
+	abuse *abuse.Tracker
+	// postChallenge asks anonymous document uploads to solve a challenge;
+	// nil disables it.
+	postChallenge func(http.Handler) http.Handler
+	// verifier authenticates per-user endpoints; nil leaves them disabled.
+	verifier *auth.Verifier
+	// recording exposes the room recorder and playback endpoints.
+	recording bool
+	// crdtSync serves the y-websocket CRDT provider on /yjs/{roomId}.
+	crdtSync bool
+	// socketAuth authenticates CRDT sync and native room sockets like collab
+	// sockets.
+	socketAuth websocket.AuthOptions
+	// adminToken guards the room admin endpoints; empty disables them.
+	adminToken string
+	// debug exposes pprof, expvar and a goroutine summary under /debug to
+	// holders of adminToken.
+	debug bool
+	// disconnectRoom force-disconnects the sockets of a room.
+	disconnectRoom func(roomID string) int
+	// roomSocket serves rooms over plain WebSocket on /ws/rooms/{roomId};
+	// nil leaves it off.
+	roomSocket http.HandlerFunc
+	// deleteChat deletes from a room's chat history and tells its members.
+	deleteChat func(ctx context.Context, roomID, messageID string) (int, error)
+	// requestTimeout bounds API requests; zero disables it.
+	requestTimeout time.Duration
+	// apiV2Sunset is when /api/v2 goes away; zero when not yet decided.
+	apiV2Sunset time.Time
+	// compressionLevel encodes API responses with brotli or gzip; zero
+	// disables it.
+	compressionLevel int
+	// instance names the server and describes it to the frontend; setupRouter
+	// fills in the features.
+	instance instance.Config
+	// frontendDir serves a web app build from disk; empty disables it.
+	frontendDir string
+	// publicURL is the server's external URL for link previews; empty
+	// derives it from each request.
+	publicURL string
+	// backupStatus reports scheduled backups; nil when they are disabled.
+	backupStatus func() backup.Status
+	// storeCache serves document and canvas reads from memory; nil disables
+	// it.
+	storeCache *cache.Cache
+	// hibernation exposes the room hibernation stats.
+	hibernation bool
+	// notifier delivers mention notifications outside the app as well; nil
+	// only keeps them in the store.
+	notifier notify.Notifier
+	// mailer sends room invitations; nil disables them.
+	mailer mail.Mailer
+	// retentionReport lists what the room cleanup policies would delete;
+	// nil when they are disabled.
+	retentionReport func(ctx context.Context) (retention.Report, error)
+	// grpcGateway serves the gRPC services as JSON under /api/grpc; nil
+	// disables it.
+	grpcGateway http.Handler
+}
+
+// describeInstance reports the optional APIs setupRouter registers for
+// documentStore and opts.
+func describeInstance(documentStore core.DocumentStore, opts routerOptions) instance.Config {
+	cfg := opts.instance
+	_, hasFiles := documentStore.(core.FileStore)
+	_, hasLibraries := documentStore.(core.LibraryStore)
+	_, hasCanvases := documentStore.(core.CanvasStore)
+	_, hasSnapshots := documentStore.(snapshots.SnapshotStore)
+	_, hasRecordings := documentStore.(core.RecordingStore)
+	_, hasOrgs := documentStore.(core.OrgStore)
+	_, hasRoomPermissions := documentStore.(core.RoomPermissionStore)
+	_, hasCRDT := documentStore.(core.CRDTStore)
+	_, hasUsers := documentStore.(core.UserStore)
+	_, hasNotifications := documentStore.(core.NotificationStore)
+	_, hasThumbnails := documentStore.(core.ThumbnailStore)
+	_, hasTemplates := documentStore.(core.TemplateStore)
+
+	cfg.Features.Collaboration = true
+	cfg.Features.Accounts = opts.verifier != nil
+	cfg.Features.Files = hasFiles
+	cfg.Features.Libraries = hasLibraries && opts.verifier != nil
+	cfg.Features.Canvases = hasCanvases && opts.verifier != nil
+	cfg.Features.Orgs = hasOrgs && opts.verifier != nil
+	cfg.Features.RoomPermissions = hasRoomPermissions && opts.verifier != nil
+	cfg.Features.Notifications = hasUsers && hasNotifications && opts.verifier != nil
+	cfg.Features.Invitations = opts.mailer != nil && opts.verifier != nil && opts.publicURL != ""
+	cfg.Features.Snapshots = hasSnapshots
+	cfg.Features.Recording = hasRecordings && opts.recording
+	cfg.Features.CRDTSync = hasCRDT && opts.crdtSync
+	cfg.Features.Thumbnails = hasThumbnails && (hasSnapshots || cfg.Features.Canvases)
+	cfg.Features.Templates = hasTemplates
+
+	if hasFiles {
+		cfg.Limits.FileMaxSize = files.MaxSizeFromEnv()
+	}
+	cfg.Limits.LibraryMaxSize = libraries.MaxLibrarySize
+	cfg.Limits.CanvasMaxSize = canvases.MaxCanvasSize
+	cfg.Limits.TemplateMaxSize = templates.MaxTemplateSize
+	cfg.Limits.ImportMaxSize = imports.MaxImportSize
+	cfg.Limits.MessageMaxSize = websocket.MaxMessageSize
+	return cfg
+}
+
+func setupRouter(documentStore core.DocumentStore, opts routerOptions) *chi.Mux {
+	instanceConfig := describeInstance(documentStore, opts)
+	// readStore serves the document read endpoints
+	readStore := documentStore
+	if opts.storeCache != nil {
+		readStore = cache.Documents(documentStore, opts.storeCache)
+	}
+	// thumbnailService renders canvas and snapshot previews; nil when the
+	// store can't cache them
+	var thumbnailService *thumbnails.Service
+	if thumbnailStore, ok := documentStore.(core.ThumbnailStore); ok {
+		thumbnailService = thumbnails.NewService(thumbnailStore)
+	}
+	// spec documents the routes as they are registered
+	spec := openapi.NewSpec(instanceConfig.Name+" API", "2", opts.basePath)
 	r := chi.NewRouter()
+	if opts.trustProxy {
+		r.Use(middleware.RealIP)
+	}
 	r.Use(middleware.Logger)
 
 	corsOptions := cors.Options{
@@ -51,57 +232,609 @@ func setupRouter(documentStore core.DocumentStore) *chi.Mux {
 
 			return false
 		},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Content-Length"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Content-Length", files.ChecksumHeader, challenge.ProofHeader, challenge.CaptchaHeader},
+		ExposedHeaders:   []string{challenge.DifficultyHeader, apierror.CodeHeader, "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}
 
 	r.Use(cors.Handler(corsOptions))
 
-	r.Route("/api/v2", func(r chi.Router) {
-		r.Post("/post/", documents.HandleCreate(documentStore))
-		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", documents.HandleGet(documentStore))
+	r.Group(func(r chi.Router) {
+		if opts.requestTimeout > 0 {
+			r.Use(deadline.Middleware(opts.requestTimeout))
+		}
+		if opts.compressionLevel > 0 {
+			r.Use(compression.Middleware(opts.compressionLevel))
+		}
+
+		// /api/v3 serves the same routes with JSON errors and paged lists;
+		// v2 keeps its responses and announces its deprecation
+		v2 := r.With(
+			apiversion.Middleware(apiversion.Legacy),
+			apiversion.Deprecate("/api/v2", "/api/v3", apiversion.V2Deprecated, opts.apiV2Sunset),
+		)
+		api := v2.Route("/api/v2", func(r chi.Router) {
+			r.Get("/config", instance.Handle(instanceConfig))
+			spec.Add("/api/v2", instance.Operation)
+			r.Group(func(r chi.Router) {
+				if opts.rateLimit != nil {
+					r.Use(opts.rateLimit)
+				}
+				if opts.postChallenge != nil {
+					r.Use(opts.postChallenge)
+				}
+				r.Post("/post/", documents.HandleCreate(documentStore))
+				r.Route("/import", func(r chi.Router) {
+					importOpts := imports.Options{Documents: documentStore}
+					if canvasStore, ok := documentStore.(core.CanvasStore); ok && opts.verifier != nil {
+						if opts.storeCache != nil {
+							canvasStore = cache.Canvases(canvasStore, opts.storeCache)
+						}
+						importOpts.Canvases = canvasStore
+					}
+					r.Use(auth.Middleware(opts.verifier, false))
+					r.Post("/drawio", imports.HandleDrawio(importOpts))
+					r.Post("/svg", imports.HandleSVG(importOpts))
+				})
+			})
+			r.Route("/convert", func(r chi.Router) {
+				if opts.rateLimit != nil {
+					r.Use(opts.rateLimit)
+				}
+				r.Post("/dot", diagrams.HandleDOT())
+				r.Post("/data", diagrams.HandleData())
+			})
+			if fileStore, ok := documentStore.(core.FileStore); ok {
+				r.Route("/files/{fileId}", func(r chi.Router) {
+					r.Get("/", files.HandleGet(fileStore))
+					r.Group(func(r chi.Router) {
+						if opts.rateLimit != nil {
+							r.Use(opts.rateLimit)
+						}
+						r.Put("/", files.HandlePut(fileStore, instanceConfig.Limits.FileMaxSize))
+					})
+				})
+			}
+			if libraryStore, ok := documentStore.(core.LibraryStore); ok && opts.verifier != nil {
+				requireUser := auth.Middleware(opts.verifier, true)
+				r.Route("/libraries", func(r chi.Router) {
+					r.Use(auth.Middleware(opts.verifier, false))
+					r.Get("/public", libraries.HandleListPublic(libraryStore))
+					r.With(requireUser).Get("/", libraries.HandleListOwn(libraryStore))
+					r.With(requireUser).Post("/", libraries.HandleCreate(libraryStore))
+					r.Route("/{libraryId}", func(r chi.Router) {
+						r.Get("/", libraries.HandleGet(libraryStore))
+						r.Get("/excalidrawlib", libraries.HandleDownload(libraryStore))
+						r.With(requireUser).Put("/", libraries.HandleUpdate(libraryStore))
+						r.With(requireUser).Delete("/", libraries.HandleDelete(libraryStore))
+					})
+				})
+			} else {
+				logrus.Info("Library API not available - requires JWT_SECRET")
+			}
+			if canvasStore, ok := documentStore.(core.CanvasStore); ok && opts.verifier != nil {
+				if opts.storeCache != nil {
+					canvasStore = cache.Canvases(canvasStore, opts.storeCache)
+				}
+				r.Route("/kv", func(r chi.Router) {
+					r.Use(auth.Middleware(opts.verifier, true))
+					r.Get("/", canvases.HandleList(canvasStore))
+					r.Get("/{key}", canvases.HandleGet(canvasStore))
+					r.Put("/{key}", canvases.HandlePut(canvasStore))
+					r.Patch("/{key}", canvases.HandlePatch(canvasStore))
+					r.Delete("/{key}", canvases.HandleDelete(canvasStore))
+					if thumbnailService != nil {
+						r.Get("/{key}/thumbnail", canvases.HandleThumbnail(canvasStore, thumbnailService))
+					}
+				})
+				spec.Add("/api/v2/kv", canvases.Operations...)
+				if thumbnailService != nil {
+					spec.Add("/api/v2/kv", canvases.ThumbnailOperation)
+				}
+			}
+			if orgStore, ok := documentStore.(core.OrgStore); ok && opts.verifier != nil {
+				canvasStore, _ := documentStore.(core.CanvasStore)
+				if canvasStore != nil && opts.storeCache != nil {
+					canvasStore = cache.Canvases(canvasStore, opts.storeCache)
+				}
+				r.Route("/orgs", func(r chi.Router) {
+					r.Use(auth.Middleware(opts.verifier, true))
+					r.Get("/", orgs.HandleList(orgStore))
+					r.Post("/", orgs.HandleCreate(orgStore))
+					r.Route("/{orgId}", func(r chi.Router) {
+						r.Use(orgs.RequireMember(orgStore))
+						r.Get("/", orgs.HandleGet(orgStore))
+						r.Delete("/", orgs.HandleDelete(orgStore, canvasStore))
+						r.Get("/members", orgs.HandleListMembers(orgStore))
+						r.Put("/members/{userId}", orgs.HandlePutMember(orgStore))
+						r.Delete("/members/{userId}", orgs.HandleRemoveMember(orgStore))
+						if canvasStore != nil {
+							r.Route("/kv", func(r chi.Router) {
+								r.Get("/", canvases.HandleList(canvasStore))
+								r.Get("/{key}", canvases.HandleGet(canvasStore))
+								r.Put("/{key}", canvases.HandlePut(canvasStore))
+								r.Patch("/{key}", canvases.HandlePatch(canvasStore))
+								r.Delete("/{key}", canvases.HandleDelete(canvasStore))
+								if thumbnailService != nil {
+									r.Get("/{key}/thumbnail", canvases.HandleThumbnail(canvasStore, thumbnailService))
+								}
+							})
+							spec.Add("/api/v2/orgs/{orgId}/kv", canvases.Operations...)
+							if thumbnailService != nil {
+								spec.Add("/api/v2/orgs/{orgId}/kv", canvases.ThumbnailOperation)
+							}
+						}
+					})
+				})
+			}
+			if templateStore, ok := documentStore.(core.TemplateStore); ok {
+				r.Route("/templates", func(r chi.Router) {
+					r.Use(auth.Middleware(opts.verifier, false))
+					requireAdmin := auth.RequireToken(opts.adminToken)
+					r.Get("/", templates.HandleList(templateStore))
+					if opts.adminToken != "" {
+						r.With(requireAdmin).Post("/", templates.HandleCreate(templateStore))
+					}
+					r.Route("/{templateId}", func(r chi.Router) {
+						r.Get("/", templates.HandleGet(templateStore))
+						if thumbnailService != nil {
+							r.Get("/preview", templates.HandlePreview(templateStore, thumbnailService))
+						}
+						if opts.adminToken != "" {
+							r.With(requireAdmin).Put("/", templates.HandleUpdate(templateStore))
+							r.With(requireAdmin).Delete("/", templates.HandleDelete(templateStore))
+						}
+						if canvasStore, ok := documentStore.(core.CanvasStore); ok && opts.verifier != nil {
+							if opts.storeCache != nil {
+								canvasStore = cache.Canvases(canvasStore, opts.storeCache)
+							}
+							r.With(auth.Middleware(opts.verifier, true)).Post("/canvas", templates.HandleCreateCanvas(templateStore, canvasStore))
+						}
+						r.Group(func(r chi.Router) {
+							if opts.rateLimit != nil {
+								r.Use(opts.rateLimit)
+							}
+							r.Post("/room", templates.HandleCreateRoom(templateStore, templates.RoomOptions{
+								Seed:    websocket.SeedRoom,
+								CanJoin: websocket.CanJoinRoom,
+							}))
+						})
+					})
+				})
+				if opts.adminToken == "" {
+					logrus.Info("Template admin API not available - requires ADMIN_TOKEN")
+				}
+			}
+			userStore, _ := documentStore.(core.UserStore)
+			if notificationStore, ok := documentStore.(core.NotificationStore); ok && userStore != nil && opts.verifier != nil {
+				websocket.SetMentionDelivery(userStore, notificationStore, opts.notifier)
+				r.Route("/notifications", func(r chi.Router) {
+					r.Use(auth.Middleware(opts.verifier, true))
+					r.Get("/", notifications.HandleList(notificationStore))
+					r.Post("/read", notifications.HandleMarkRead(notificationStore))
+				})
+			}
+			if opts.verifier != nil {
+				stores := account.Stores{
+					Chat:          websocket.UserChat,
+					AnonymizeChat: websocket.AnonymizeUserChat,
+				}
+				stores.Canvases, _ = documentStore.(core.CanvasStore)
+				if stores.Canvases != nil && opts.storeCache != nil {
+					stores.Canvases = cache.Canvases(stores.Canvases, opts.storeCache)
+				}
+				stores.Libraries, _ = documentStore.(core.LibraryStore)
+				stores.Orgs, _ = documentStore.(core.OrgStore)
+				stores.Rooms, _ = documentStore.(account.RoomStore)
+				stores.Users = userStore
+				stores.Notifications, _ = documentStore.(core.NotificationStore)
+				r.Route("/me", func(r chi.Router) {
+					r.Use(auth.Middleware(opts.verifier, true))
+					r.Get("/export", account.HandleExport(stores))
+					r.Delete("/", account.HandleDelete(stores))
+				})
+			}
+			r.Route("/{id}", func(r chi.Router) {
+				r.Get("/", documents.HandleGet(readStore))
+				r.Get("/export.{format}", documents.HandleExport(readStore))
+				r.Get("/og.png", documents.HandleOGImage(readStore))
+				r.Get("/analyze", documents.HandleAnalyze(readStore))
+				if statsStore, ok := documentStore.(core.DocumentStatsStore); ok {
+					r.Get("/stats", documents.HandleStats(statsStore))
+					spec.Add("/api/v2", documents.StatsOperation)
+				}
+			})
+			spec.Add("/api/v2", documents.Operations...)
 		})
-	})
+		r.With(apiversion.Middleware(apiversion.Latest)).Mount("/api/v3", api)
 
-	r.Get("/api/rooms", func(w http.ResponseWriter, r *http.Request) {
-		rooms := websocket.GetActiveRooms()
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(rooms); err != nil {
-			http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		shareOpts := share.Options{
+			PublicURL:    opts.publicURL,
+			TrustProxy:   opts.trustProxy,
+			ProviderName: instanceConfig.Name,
 		}
-	})
+		r.Get("/api/openapi.json", openapi.Handle(spec))
+		r.Get("/api/docs", openapi.HandleUI("openapi.json"))
 
-	// Snapshot API routes - only available with SQLite store
-	if snapshotStore, ok := documentStore.(snapshots.SnapshotStore); ok {
-		r.Route("/api/rooms/{roomId}/snapshots", func(r chi.Router) {
-			r.Post("/", snapshots.HandleCreateSnapshot(snapshotStore))
-			r.Get("/", snapshots.HandleListSnapshots(snapshotStore))
-			r.Get("/count", snapshots.HandleGetSnapshotCount(snapshotStore))
-		})
+		r.Get("/share/{id}", share.HandlePage(readStore, shareOpts))
+		r.Get("/oembed", share.HandleOEmbed(readStore, shareOpts))
 
-		r.Route("/api/snapshots/{snapshotId}", func(r chi.Router) {
-			r.Get("/", snapshots.HandleGetSnapshot(snapshotStore))
-			r.Delete("/", snapshots.HandleDeleteSnapshot(snapshotStore))
-			r.Put("/", snapshots.HandleUpdateSnapshot(snapshotStore))
+		r.Get("/api/rooms", rooms.HandleList(websocket.GetListedRooms))
+		spec.Add("/api/rooms", rooms.ListOperation)
+		if limitsStore, ok := documentStore.(core.RoomLimitsStore); ok {
+			websocket.SetRoomLimitsStore(limitsStore)
+		}
+		if modeStore, ok := documentStore.(core.RoomModeStore); ok {
+			websocket.SetRoomModeStore(modeStore)
+		}
+		if permissionStore, ok := documentStore.(core.RoomPermissionStore); ok {
+			websocket.SetRoomPermissionStore(permissionStore)
+			if opts.verifier != nil {
+				r.Route("/api/rooms/{roomId}/permissions", func(r chi.Router) {
+					r.Use(auth.Middleware(opts.verifier, true))
+					r.Get("/", rooms.HandleGetPermissions(permissionStore))
+					r.Put("/", rooms.HandlePutPermissions(permissionStore, websocket.IsUserInRoom, opts.socketAuth.Mode != websocket.AuthOff))
+				})
+				spec.Add("/api/rooms", rooms.PermissionOperations...)
+			}
+		}
+		if instanceConfig.Features.Invitations {
+			r.Route("/api/rooms/{roomId}/invitations", func(r chi.Router) {
+				r.Use(auth.Middleware(opts.verifier, true))
+				if opts.rateLimit != nil {
+					r.Use(opts.rateLimit)
+				}
+				r.Post("/", rooms.HandleInvite(rooms.InvitationOptions{
+					Mailer:    opts.mailer,
+					Instance:  instanceConfig.Name,
+					PublicURL: opts.publicURL,
+					CanInvite: websocket.CanJoinRoom,
+				}))
+			})
+			spec.Add("/api/rooms", rooms.InvitationOperation)
+		} else if opts.mailer != nil {
+			logrus.Info("Room invitations not available - requires JWT_SECRET and PUBLIC_URL")
+		}
+		if opts.deleteChat != nil {
+			chatAccess := rooms.NewChatAccess(opts.adminToken, websocket.CanReadRoomChat, websocket.IsRoomModerator)
+			r.Route("/api/rooms/{roomId}/chat", func(r chi.Router) {
+				r.Use(auth.Middleware(opts.verifier, false))
+				r.Get("/", rooms.HandleGetChat(websocket.GetRoomChat, chatAccess))
+				r.Delete("/", rooms.HandleDeleteChat(opts.deleteChat, chatAccess))
+				r.Delete("/{messageId}", rooms.HandleDeleteChat(opts.deleteChat, chatAccess))
+			})
+			spec.Add("/api/rooms", rooms.ChatOperations...)
+		}
+		if opts.adminToken != "" && opts.disconnectRoom != nil {
+			requireAdmin := auth.RequireToken(opts.adminToken)
+			r.With(requireAdmin).Get("/api/rooms/{roomId}", rooms.HandleGet(websocket.GetRoomStats))
+			r.With(requireAdmin).Delete("/api/rooms/{roomId}/connections", rooms.HandleDisconnect(opts.disconnectRoom))
+			r.With(requireAdmin).Get("/api/rooms/{roomId}/limits", rooms.HandleGetLimits(websocket.GetRoomMessageLimits))
+			r.With(requireAdmin).Put("/api/rooms/{roomId}/limits", rooms.HandlePutLimits(websocket.SetRoomMessageLimits, websocket.GetRoomMessageLimits))
+			spec.Add("/api/rooms", rooms.AdminOperations...)
+		} else {
+			logrus.Info("Room admin API not available - requires ADMIN_TOKEN")
+		}
+		if opts.adminToken != "" {
+			sources := adminpanel.Sources{
+				Rooms:       websocket.GetActiveRooms,
+				Stats:       websocket.GetRoomStats,
+				StorageType: os.Getenv("STORAGE_TYPE"),
+				StartedAt:   time.Now(),
+				// like the admin namespace, time a lookup of a missing
+				// document; only failing to get an answer counts
+				ProbeStore: func(ctx context.Context) error {
+					_, _ = documentStore.FindID(ctx, "admin-latency-probe")
+					return ctx.Err()
+				},
+			}
+			sources.Usage, _ = documentStore.(core.StorageUsageStore)
+			sources.Users, _ = documentStore.(core.UserLister)
+			r.Get("/admin", adminpanel.HandlePage())
+			r.With(auth.RequireToken(opts.adminToken)).Get("/api/admin/overview", adminpanel.HandleOverview(sources))
+		} else {
+			logrus.Info("Admin panel not available - requires ADMIN_TOKEN")
+		}
+		if opts.adminToken != "" && opts.backupStatus != nil {
+			r.With(auth.RequireToken(opts.adminToken)).Get("/api/backups/status", backups.HandleStatus(opts.backupStatus))
+		} else if opts.backupStatus != nil {
+			logrus.Info("Backup status API not available - requires ADMIN_TOKEN")
+		}
+		if opts.adminToken != "" && opts.hibernation {
+			r.With(auth.RequireToken(opts.adminToken)).Get("/api/hibernation/stats", rooms.HandleHibernationStats(websocket.GetHibernationStats))
+		} else if opts.hibernation {
+			logrus.Info("Hibernation stats API not available - requires ADMIN_TOKEN")
+		}
+		if opts.adminToken != "" {
+			r.With(auth.RequireToken(opts.adminToken)).Get("/api/chat/stats", rooms.HandleChatStats(websocket.GetChatStats))
+		}
+		if opts.adminToken != "" && opts.storeCache != nil {
+			r.With(auth.RequireToken(opts.adminToken)).Get("/api/cache/stats", cachestats.HandleStats(opts.storeCache.Stats))
+		} else if opts.storeCache != nil {
+			logrus.Info("Cache stats API not available - requires ADMIN_TOKEN")
+		}
+		if opts.adminToken != "" && opts.abuse != nil {
+			requireAdmin := auth.RequireToken(opts.adminToken)
+			r.With(requireAdmin).Get("/api/abuse/bans", bans.HandleList(opts.abuse))
+			r.With(requireAdmin).Delete("/api/abuse/bans/{key}", bans.HandleLift(opts.abuse))
+			spec.Add("/api/abuse", bans.Operations...)
+		} else if opts.abuse != nil {
+			logrus.Info("Abuse ban API not available - requires ADMIN_TOKEN")
+		}
+		if opts.adminToken != "" && opts.retentionReport != nil {
+			r.With(auth.RequireToken(opts.adminToken)).Get("/api/retention/report", cleanup.HandleReport(opts.retentionReport))
+		} else if opts.retentionReport != nil {
+			logrus.Info("Room cleanup report API not available - requires ADMIN_TOKEN")
+		}
+
+		// GraphQL reads the same stores; fields whose store is missing fail
+		// with not_implemented
+		graphqlOpts := graphql.Options{
+			Rooms: graphql.Rooms{List: websocket.GetListedRooms, Stats: websocket.GetRoomStats, CanJoin: websocket.CanJoinRoom},
+		}
+		graphqlOpts.Users, _ = documentStore.(core.UserStore)
+		graphqlOpts.Snapshots, _ = documentStore.(snapshots.SnapshotStore)
+		if canvasStore, ok := documentStore.(core.CanvasStore); ok && opts.verifier != nil {
+			if opts.storeCache != nil {
+				canvasStore = cache.Canvases(canvasStore, opts.storeCache)
+			}
+			graphqlOpts.Canvases = canvasStore
+		}
+		handleGraphQL := graphql.Handle(graphqlOpts)
+		r.Route("/api/graphql", func(r chi.Router) {
+			r.Use(auth.Middleware(opts.verifier, false))
+			r.Get("/", handleGraphQL)
+			r.Post("/", handleGraphQL)
 		})
+		spec.Add("/api/graphql", graphql.Operations...)
+
+		// Snapshot API routes - only available with SQLite store
+		if snapshotStore, ok := documentStore.(snapshots.SnapshotStore); ok {
+			r.Route("/api/rooms/{roomId}/snapshots", func(r chi.Router) {
+				r.With(snapshots.RejectEncryptedRooms(websocket.IsEncryptedRoomStored)).Post("/", snapshots.HandleCreateSnapshot(snapshotStore))
+				r.Get("/", snapshots.HandleListSnapshots(snapshotStore))
+				r.Get("/count", snapshots.HandleGetSnapshotCount(snapshotStore))
+			})
+
+			r.Route("/api/snapshots/{snapshotId}", func(r chi.Router) {
+				r.Get("/", snapshots.HandleGetSnapshot(snapshotStore))
+				r.Delete("/", snapshots.HandleDeleteSnapshot(snapshotStore))
+				r.Put("/", snapshots.HandleUpdateSnapshot(snapshotStore))
+				if thumbnailService != nil {
+					r.Get("/thumbnail", snapshots.HandleGetThumbnail(snapshotStore, thumbnailService))
+				}
+			})
+
+			r.Route("/api/rooms/{roomId}/settings", func(r chi.Router) {
+				r.Get("/", snapshots.HandleGetRoomSettings(snapshotStore))
+				r.Put("/", snapshots.HandleUpdateRoomSettings(snapshotStore))
+			})
+			spec.Add("/api", snapshots.Operations...)
+			if thumbnailService != nil {
+				spec.Add("/api", snapshots.ThumbnailOperation)
+			}
+
+			logrus.Info("Snapshot API routes registered")
+		} else {
+			logrus.Warn("Snapshot API not available - requires SQLite storage")
+		}
 
-		r.Route("/api/rooms/{roomId}/settings", func(r chi.Router) {
-			r.Get("/", snapshots.HandleGetRoomSettings(snapshotStore))
-			r.Put("/", snapshots.HandleUpdateRoomSettings(snapshotStore))
+		if recordingStore, ok := documentStore.(core.RecordingStore); ok && opts.recording {
+			websocket.SetRecordingStore(recordingStore)
+			r.Route("/api/rooms/{roomId}/recordings", func(r chi.Router) {
+				r.Get("/", recordings.HandleList(recordingStore))
+				r.With(snapshots.RejectEncryptedRooms(websocket.IsEncryptedRoomStored)).Post("/", recordings.HandleStart(websocket.StartRecording))
+				r.Post("/stop", recordings.HandleStop(websocket.StopRecording))
+			})
+			r.Get("/api/recordings/{recordingId}", recordings.HandleGet(recordingStore))
+		} else if opts.recording {
+			logrus.Warn("Recording API not available - requires memory or SQLite storage")
+		}
+	})
+
+	// Playback streams for as long as the recording lasts, so it is exempt
+	// from the request timeout
+	if recordingStore, ok := documentStore.(core.RecordingStore); ok && opts.recording {
+		r.Get("/api/recordings/{recordingId}/playback", recordings.HandlePlayback(recordingStore))
+	}
+
+	// Room event streams stay open too
+	eventsAccess := rooms.NewChatAccess(opts.adminToken, websocket.CanReadRoomChat, websocket.IsRoomModerator)
+	r.With(auth.Middleware(opts.verifier, false)).Get("/api/rooms/{roomId}/events", rooms.HandleEvents(rooms.RoomEvents{
+		Presence:  websocket.GetRoomPresence,
+		History:   websocket.GetRoomChat,
+		Subscribe: websocket.SubscribeRoom,
+	}, eventsAccess))
+	spec.Add("/api/rooms", rooms.EventsOperation)
+
+	// The gateway streams WatchRoom, and gRPC clients set their own
+	// deadlines
+	if opts.grpcGateway != nil {
+		r.Mount("/api/grpc", http.StripPrefix(opts.basePath+"/api/grpc", opts.grpcGateway))
+	}
+
+	// Native room sockets stay open too
+	if opts.roomSocket != nil {
+		r.Get("/ws/rooms/{roomId}", opts.roomSocket)
+	}
+
+	// CRDT sync connections stay open too
+	if crdtStore, ok := documentStore.(core.CRDTStore); ok && opts.crdtSync {
+		r.Get("/yjs/{roomId}", websocket.HandleCRDTSync(crdtStore, opts.socketAuth))
+	} else if opts.crdtSync {
+		logrus.Warn("CRDT sync not available - requires memory or SQLite storage")
+	}
+
+	// CPU profiles and traces run for as long as asked, so the debug
+	// endpoints are exempt as well
+	if opts.debug && opts.adminToken != "" {
+		r.Route("/debug", func(r chi.Router) {
+			r.Use(auth.RequireToken(opts.adminToken))
+			r.Get("/goroutines", diagnostics.HandleGoroutines())
+			r.Mount("/", middleware.Profiler())
 		})
+		logrus.Warn("Debug endpoints enabled under /debug")
+	} else if opts.debug {
+		logrus.Warn("Debug endpoints not available - requires ADMIN_TOKEN")
+	}
 
-		logrus.Info("Snapshot API routes registered")
-	} else {
-		logrus.Warn("Snapshot API not available - requires SQLite storage")
+	if opts.frontendDir != "" {
+		r.Group(func(r chi.Router) {
+			if opts.compressionLevel > 0 {
+				r.Use(compression.Middleware(opts.compressionLevel))
+			}
+			r.Get("/*", frontend.Handler(opts.frontendDir, instance.Script(instanceConfig)))
+		})
 	}
 
 	return r
 }
 
-func waitForShutdown(ioo *socketio.Server) {
+// runtimeSettings returns one applier per setting that is safe to change
+// while sockets stay connected, so an invalid value holds back only its own
+// setting. They run at startup and on every config reload. A non-empty
+// logLevel is the --loglevel flag, which wins over LOG_LEVEL.
+func runtimeSettings(logLevel string) []func() error {
+	return []func() error{
+		func() error {
+			if logLevel != "" {
+				level, err := logrus.ParseLevel(logLevel)
+				if err != nil {
+					return fmt.Errorf("invalid --loglevel: %w", err)
+				}
+				logrus.SetLevel(level)
+				return nil
+			}
+			if value := os.Getenv("LOG_LEVEL"); value != "" {
+				level, err := logrus.ParseLevel(value)
+				if err != nil {
+					return fmt.Errorf("invalid LOG_LEVEL: %w", err)
+				}
+				logrus.SetLevel(level)
+			}
+			return nil
+		},
+		func() error {
+			if value := os.Getenv("ROOM_BAN_DURATION"); value != "" {
+				duration, err := time.ParseDuration(value)
+				if err != nil {
+					return fmt.Errorf("invalid ROOM_BAN_DURATION: %w", err)
+				}
+				websocket.SetBanDuration(duration)
+			}
+			return nil
+		},
+		func() error {
+			if value := os.Getenv("OUTBOX_SIZE"); value != "" {
+				size, err := strconv.Atoi(value)
+				if err != nil || size < 0 {
+					return fmt.Errorf("invalid OUTBOX_SIZE %q: must be a non-negative integer", value)
+				}
+				websocket.SetOutboxSize(size)
+			}
+			return nil
+		},
+		func() error {
+			if value := os.Getenv("BROADCAST_COALESCE_WINDOW"); value != "" {
+				window, err := time.ParseDuration(value)
+				if err != nil || window < 0 || window > websocket.MaxCoalesceWindow {
+					return fmt.Errorf("invalid BROADCAST_COALESCE_WINDOW %q: must be a duration from 0 to %v", value, websocket.MaxCoalesceWindow)
+				}
+				websocket.SetCoalesceWindow(window)
+			}
+			return nil
+		},
+		func() error {
+			chatRoomBudget, chatTotalBudget, err := websocket.ChatBudgetsFromEnv()
+			if err != nil {
+				return err
+			}
+			websocket.SetChatBudgets(chatRoomBudget, chatTotalBudget)
+			return nil
+		},
+		func() error {
+			messageLimits, err := websocket.MessageLimitsFromEnv()
+			if err != nil {
+				return err
+			}
+			websocket.SetMessageLimits(messageLimits)
+			return nil
+		},
+		func() error {
+			rtcConfig, err := websocket.RTCConfigFromEnv()
+			if err != nil {
+				return err
+			}
+			websocket.SetRTCConfig(rtcConfig)
+			return nil
+		},
+		func() error {
+			chatFilter, err := chatfilter.FromEnv()
+			if err != nil {
+				return err
+			}
+			websocket.SetChatFilter(chatFilter)
+			return nil
+		},
+	}
+}
+
+type tlsOptions struct {
+	certFile     string
+	keyFile      string
+	acmeHosts    []string
+	acmeCache    string
+	acmeEmail    string
+	acmeHTTPAddr string
+}
+
+// listenAndServe serves plain HTTP, TLS from a certificate/key pair, or TLS
+// with certificates provisioned from Let's Encrypt. TLS listeners negotiate
+// HTTP/2; websocket upgrades fall back to HTTP/1.1.
+func listenAndServe(addr string, handler http.Handler, opts tlsOptions) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	switch {
+	case len(opts.acmeHosts) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(opts.acmeHosts...),
+			Cache:      autocert.DirCache(opts.acmeCache),
+			Email:      opts.acmeEmail,
+		}
+		server.TLSConfig = manager.TLSConfig()
+
+		if opts.acmeHTTPAddr != "" {
+			// Answers HTTP-01 challenges and redirects everything else to HTTPS
+			go func() {
+				challengeServer := &http.Server{
+					Addr:              opts.acmeHTTPAddr,
+					Handler:           manager.HTTPHandler(nil),
+					ReadHeaderTimeout: 10 * time.Second,
+				}
+				if err := challengeServer.ListenAndServe(); err != nil {
+					logrus.WithField("event", "start acme challenge server").Error(err)
+				}
+			}()
+		}
+		return server.ListenAndServeTLS("", "")
+	case opts.certFile != "" || opts.keyFile != "":
+		if opts.certFile == "" || opts.keyFile == "" {
+			return fmt.Errorf("both --tls-cert and --tls-key are required")
+		}
+		return server.ListenAndServeTLS(opts.certFile, opts.keyFile)
+	default:
+		return server.ListenAndServe()
+	}
+}
+
+func waitForShutdown(ioo *socketio.Server, reloader *config.Reloader) {
 	exit := make(chan struct{})
 	SignalC := make(chan os.Signal, 1)
 
@@ -109,7 +842,13 @@ func waitForShutdown(ioo *socketio.Server) {
 	go func() {
 		for s := range SignalC {
 			switch s {
-			case os.Interrupt, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
+			case syscall.SIGHUP:
+				if err := reloader.Reload(); err != nil {
+					logrus.WithError(err).Error("Failed to reload config")
+					continue
+				}
+				logrus.Info("Config reloaded")
+			case os.Interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
 				close(exit)
 				return
 			}
@@ -125,32 +864,573 @@ func waitForShutdown(ioo *socketio.Server) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rooms" {
+		os.Exit(runRooms(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Define a log level flag
 	logLevel := flag.String("loglevel", "info", "Set the logging level: debug, info, warn, error, fatal, panic")
 	listenAddr := flag.String("listen", ":3002", "Set the server listen address")
+	configPath := flag.String("config", "", "Path to a KEY=VALUE config file, reloaded on SIGHUP or when it changes")
+	tlsCert := flag.String("tls-cert", "", "Path to a TLS certificate (PEM) to serve HTTPS")
+	tlsKey := flag.String("tls-key", "", "Path to the TLS private key (PEM) matching --tls-cert")
+	acmeHost := flag.String("acme-host", "", "Comma-separated hostnames to provision Let's Encrypt certificates for")
+	acmeCache := flag.String("acme-cache", "./acme-cache", "Directory where ACME certificates are cached")
+	acmeEmail := flag.String("acme-email", "", "Contact email for the ACME account")
+	acmeHTTP := flag.String("acme-http-listen", ":80", "Listen address for ACME HTTP-01 challenges and HTTPS redirects (empty to disable)")
+	migrateOnly := flag.Bool("migrate-only", false, "Apply pending database migrations and exit")
 	flag.Parse()
 
-	// Set the log level
-	level, err := logrus.ParseLevel(*logLevel)
+	if *configPath != "" {
+		if err := config.Load(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// An explicit --loglevel wins over LOG_LEVEL, on reloads too
+	var explicitLogLevel string
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "loglevel" {
+			explicitLogLevel = *logLevel
+		}
+	})
+
+	reloader := config.NewReloader(*configPath)
+	for _, apply := range runtimeSettings(explicitLogLevel) {
+		reloader.OnReload(apply)
+		if err := apply(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if *migrateOnly {
+		// Opening the store applies any pending migrations
+		stores.GetStore()
+		logrus.Info("Database is up to date")
+		os.Exit(0)
+	}
+
+	authMode, err := websocket.ParseAuthMode(os.Getenv("SOCKET_AUTH"))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Invalid log level: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Invalid SOCKET_AUTH: %v\n", err)
+		os.Exit(1)
+	}
+	verifier := auth.GetVerifier()
+	if authMode != websocket.AuthOff && verifier == nil {
+		fmt.Fprintln(os.Stderr, "SOCKET_AUTH requires JWT_SECRET to be set")
 		os.Exit(1)
 	}
-	logrus.SetLevel(level)
+
+	encryptedOnly := os.Getenv("RELAY_MODE") == string(websocket.RoomModeEncrypted)
+	websocket.SetEncryptedOnly(encryptedOnly)
+
+	limiter, err := ratelimit.GetLimiter()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid rate limit configuration: %v\n", err)
+		os.Exit(1)
+	}
+	reloader.OnReload(func() error {
+		perMinute, burst, rateErr := ratelimit.RateFromEnv()
+		if rateErr != nil {
+			return rateErr
+		}
+		limiter.SetRate(perMinute, burst)
+		return nil
+	})
+
+	// Rate limit hits, malformed events and oversized payloads count
+	// against their client, which is banned from connecting for a while
+	// once it has too many
+	abuseConfig, err := abuse.ConfigFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid abuse ban configuration: %v\n", err)
+		os.Exit(1)
+	}
+	abuseTracker := abuse.NewTracker(abuseConfig)
+	websocket.SetAbuseTracker(abuseTracker)
+	reloader.OnReload(func() error {
+		cfg, err := abuse.ConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		abuseTracker.SetConfig(cfg)
+		return nil
+	})
+
+	opts := routerOptions{
+		trustProxy: os.Getenv("TRUST_PROXY_HEADERS") == "true",
+		rateLimit: ratelimit.Middleware(limiter, ratelimit.KeyByUserOrIP(verifier), func(key string) {
+			abuseTracker.Report(key, abuse.RateLimited)
+		}),
+		abuse:      abuseTracker,
+		verifier:   verifier,
+		recording:  os.Getenv("ROOM_RECORDING") == "true",
+		crdtSync:   os.Getenv("CRDT_SYNC") == "true",
+		socketAuth: websocket.AuthOptions{Mode: authMode, Verifier: verifier},
+		adminToken: os.Getenv("ADMIN_TOKEN"),
+		debug:      os.Getenv("DEBUG_ENDPOINTS") == "true",
+		instance:   instance.BrandingFromEnv(),
+	}
+	if opts.crdtSync && encryptedOnly {
+		fmt.Fprintln(os.Stderr, "CRDT_SYNC can't be used with RELAY_MODE=encrypted, since the server stores CRDT updates in plain text")
+		os.Exit(1)
+	}
+	opts.instance.Features.SocketAuth = string(authMode)
+	opts.instance.Features.EncryptedOnly = encryptedOnly
+	opts.instance.Features.PostChallenge = "off"
+	if kind := os.Getenv("POST_CHALLENGE"); kind != "" {
+		opts.instance.Features.PostChallenge = kind
+	}
+	postChallenge, err := challenge.GetChallenge()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid challenge configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if postChallenge != nil {
+		// the challenge guards uploads and imports, and reads no more of a
+		// body than an import may have
+		opts.postChallenge = challenge.Middleware(postChallenge, verifier, imports.MaxImportSize)
+	}
+
+	opts.requestTimeout, err = deadline.TimeoutFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid REQUEST_TIMEOUT: %v\n", err)
+		os.Exit(1)
+	}
+	opts.apiV2Sunset, err = apiversion.SunsetFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	opts.compressionLevel, err = compression.LevelFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	opts.frontendDir, err = frontend.DirFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	opts.publicURL, err = share.PublicURLFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	opts.mailer, err = mail.FromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid mail configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if alertEmail := os.Getenv("ALERT_EMAIL"); alertEmail != "" {
+		if opts.mailer == nil {
+			fmt.Fprintln(os.Stderr, "ALERT_EMAIL requires SMTP_HOST or MAIL_DRY_RUN=true")
+			os.Exit(1)
+		}
+		alerts, err := mail.NewAlertHook(opts.mailer, strings.Split(alertEmail, ","), opts.instance.Name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid ALERT_EMAIL: %v\n", err)
+			os.Exit(1)
+		}
+		logrus.AddHook(alerts)
+	}
+
+	opts.notifier, err = notify.FromEnv(opts.mailer, opts.instance.Name, opts.publicURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	cacheBytes, err := cache.MaxBytesFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if cacheBytes > 0 {
+		opts.storeCache = cache.New(cacheBytes)
+	}
+
+	gcInterval, gcGrace, err := filegc.SettingsFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid file GC configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	thumbnailInterval, err := thumbnails.BackfillIntervalFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid thumbnail configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	backupSettings, err := backup.SettingsFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid backup configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	archiveSettings, err := archive.SettingsFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid archive configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	retentionSettings, err := retention.SettingsFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid room cleanup configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	hibernateAfter, err := websocket.HibernateAfterFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	roomStatsInterval, err := websocket.RoomStatsIntervalFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	roomWorkers, err := websocket.RoomWorkersFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	websocket.StartRoomWorkers(roomWorkers)
+
+	clusterSettings, err := cluster.SettingsFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid cluster configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	sessionTTL, err := sessions.TTLFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if sessionTTL > 0 {
+		sessionStore, err := sessions.GetStore()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid session store configuration: %v\n", err)
+			os.Exit(1)
+		}
+		websocket.SetSessionStore(sessionStore, sessionTTL)
+	}
+
+	basePath, err := config.BasePathFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts.basePath = basePath
+
+	websocket.SetTrustProxy(opts.trustProxy)
+	ioo := websocket.SetupSocketIO(opts.socketAuth)
+	if clusterSettings.Transport != "" {
+		transport, err := cluster.Dial(clusterSettings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to join the cluster: %v\n", err)
+			os.Exit(1)
+		}
+		if err := websocket.JoinCluster(ioo, transport, clusterSettings.Prefix); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to join the cluster: %v\n", err)
+			os.Exit(1)
+		}
+		logrus.WithFields(logrus.Fields{
+			"transport": clusterSettings.Transport,
+			"prefix":    clusterSettings.Prefix,
+		}).Info("Cluster mode enabled")
+	}
+	opts.disconnectRoom = func(roomID string) int {
+		return websocket.DisconnectRoom(ioo, roomID)
+	}
+	opts.roomSocket = websocket.HandleRawSocket(ioo, opts.socketAuth)
+	opts.deleteChat = func(ctx context.Context, roomID, messageID string) (int, error) {
+		return websocket.DeleteRoomChat(ctx, ioo, roomID, messageID)
+	}
 
 	documentStore := stores.GetStore()
-	r := setupRouter(documentStore)
-	ioo := websocket.SetupSocketIO()
+
+	var backupScheduler *backup.Scheduler
+	if backupSettings != nil {
+		backupWriter, ok := documentStore.(core.BackupWriter)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "BACKUP_SCHEDULE requires SQLite or filesystem storage")
+			os.Exit(1)
+		}
+		uploader, err := backup.NewS3Client(backupSettings.S3)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid backup configuration: %v\n", err)
+			os.Exit(1)
+		}
+		backupScheduler = backup.NewScheduler(backupWriter, uploader, *backupSettings)
+		opts.backupStatus = backupScheduler.Status
+	}
+
+	if hibernateAfter > 0 {
+		roomStateStore, ok := documentStore.(core.RoomStateStore)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "ROOM_HIBERNATE_AFTER requires memory or SQLite storage")
+			os.Exit(1)
+		}
+		websocket.SetRoomStateStore(roomStateStore)
+		opts.hibernation = true
+	}
+
+	var archiveMover *archive.Mover
+	if archiveSettings != nil {
+		archivingStore, ok := documentStore.(core.ArchivingStore)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "ARCHIVE_AFTER_DAYS requires SQLite storage")
+			os.Exit(1)
+		}
+		coldArchive, err := archive.New(*archiveSettings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid archive configuration: %v\n", err)
+			os.Exit(1)
+		}
+		archivingStore.SetArchive(coldArchive)
+		archiveMover = archive.NewMover(archivingStore, archiveSettings.IdleAfter)
+	}
+
+	var cleaner *retention.Cleaner
+	if retentionSettings != nil {
+		retentionStore, ok := documentStore.(core.RoomRetentionStore)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "RETENTION_* policies require SQLite storage")
+			os.Exit(1)
+		}
+		purgeChat := func(ctx context.Context, roomID string) (int, error) {
+			return websocket.PurgeRoomChat(ctx, roomID, retentionStore.ReplaceRoomState)
+		}
+		isLive := func(roomID string) bool {
+			_, live := websocket.GetActiveRooms()[roomID]
+			return live
+		}
+		cleaner, err = retention.NewCleaner(retentionStore, *retentionSettings, purgeChat, isLive)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid room cleanup configuration: %v\n", err)
+			os.Exit(1)
+		}
+		opts.retentionReport = cleaner.Report
+	}
+
+	var admin *websocket.Admin
+	if opts.adminToken != "" {
+		adminInterval, err := websocket.AdminIntervalFromEnv()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		admin = websocket.SetupAdmin(ioo, websocket.AdminOptions{
+			Token:    opts.adminToken,
+			Interval: adminInterval,
+			Config: func() any {
+				perMinute, burst, _ := ratelimit.RateFromEnv()
+				return map[string]any{
+					"instance":       describeInstance(documentStore, opts),
+					"log_level":      logrus.GetLevel().String(),
+					"rate_limit":     map[string]int{"per_minute": perMinute, "burst": burst},
+					"message_limits": websocket.GetMessageLimits(),
+				}
+			},
+			// looking up a missing document times a round trip to the store;
+			// only failing to get an answer counts as an error
+			ProbeStore: func(ctx context.Context) error {
+				_, _ = documentStore.FindID(ctx, "admin-latency-probe")
+				return ctx.Err()
+			},
+		})
+		reloader.OnReload(func() error {
+			admin.PublishConfig()
+			return nil
+		})
+	} else {
+		logrus.Info("Admin namespace not available - requires ADMIN_TOKEN")
+	}
+
+	if opts.debug {
+		expvar.Publish("collab", expvar.Func(func() any {
+			return map[string]any{
+				"rooms":       len(websocket.GetActiveRooms()),
+				"sockets":     ioo.Sockets().Sockets().Len(),
+				"hibernation": websocket.GetHibernationStats(),
+				"chat":        websocket.GetChatStats(),
+			}
+		}))
+	}
+
+	var grpcServer *grpc.Server
+	grpcAddr := os.Getenv("GRPC_ADDR")
+	if grpcAddr != "" || os.Getenv("GRPC_GATEWAY") == "true" {
+		grpcOpts := grpcapi.Options{
+			Documents:  documentStore,
+			Verifier:   verifier,
+			AdminToken: opts.adminToken,
+			Rooms: grpcapi.Rooms{
+				List:        websocket.GetActiveRooms,
+				Stats:       websocket.GetRoomStats,
+				Disconnect:  opts.disconnectRoom,
+				IsEncrypted: websocket.IsEncryptedRoomStored,
+			},
+			Limiter: limiter,
+		}
+		if canvasStore, ok := documentStore.(core.CanvasStore); ok {
+			if opts.storeCache != nil {
+				canvasStore = cache.Canvases(canvasStore, opts.storeCache)
+			}
+			grpcOpts.Canvases = canvasStore
+		}
+		grpcOpts.Snapshots, _ = documentStore.(snapshots.SnapshotStore)
+
+		var serverOpts []grpc.ServerOption
+		if *tlsCert != "" && *tlsKey != "" {
+			creds, err := credentials.NewServerTLSFromFile(*tlsCert, *tlsKey)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid TLS configuration for gRPC: %v\n", err)
+				os.Exit(1)
+			}
+			serverOpts = append(serverOpts, grpc.Creds(creds))
+		}
+		grpcServer = grpcapi.NewServer(grpcOpts, serverOpts...)
+
+		if os.Getenv("GRPC_GATEWAY") == "true" {
+			opts.grpcGateway, err = grpcapi.Gateway(grpcServer)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to start the gRPC gateway: %v\n", err)
+				os.Exit(1)
+			}
+			logrus.Info("gRPC gateway enabled under /api/grpc")
+		}
+	}
+
+	r := setupRouter(documentStore, opts)
 	r.Handle("/socket.io/", ioo.ServeHandler(nil))
 
-	logrus.WithField("addr", *listenAddr).Info("starting server")
+	var handler http.Handler = r
+	if basePath != "" {
+		root := chi.NewRouter()
+		root.Mount(basePath, r)
+		handler = root
+	}
+
+	tlsOpts := tlsOptions{
+		certFile:     *tlsCert,
+		keyFile:      *tlsKey,
+		acmeCache:    *acmeCache,
+		acmeEmail:    *acmeEmail,
+		acmeHTTPAddr: *acmeHTTP,
+	}
+	for _, host := range strings.Split(*acmeHost, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			tlsOpts.acmeHosts = append(tlsOpts.acmeHosts, host)
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"addr": *listenAddr,
+		"base": basePath,
+		"tls":  len(tlsOpts.acmeHosts) > 0 || tlsOpts.certFile != "",
+	}).Info("starting server")
 	go func() {
-		if err := http.ListenAndServe(*listenAddr, r); err != nil {
+		if err := listenAndServe(*listenAddr, handler, tlsOpts); err != nil {
 			logrus.WithField("event", "start server").Fatal(err)
 		}
 	}()
 
+	if grpcAddr != "" {
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			logrus.WithField("event", "start grpc server").Fatal(err)
+		}
+		logrus.WithFields(logrus.Fields{
+			"addr":     grpcAddr,
+			"services": len(grpcServer.GetServiceInfo()),
+		}).Info("starting gRPC server")
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				logrus.WithField("event", "start grpc server").Fatal(err)
+			}
+		}()
+	}
+
+	stopBackground := make(chan struct{})
+	defer close(stopBackground)
+	go reloader.Watch(5*time.Second, stopBackground)
+
+	fileStore, hasFiles := documentStore.(core.FileStore)
+	sceneScanner, hasScenes := documentStore.(core.SceneScanner)
+	if gcInterval > 0 && hasFiles && hasScenes {
+		logrus.WithFields(logrus.Fields{
+			"interval": gcInterval,
+			"grace":    gcGrace,
+		}).Info("File garbage collection enabled")
+		go filegc.NewCollector(fileStore, sceneScanner, gcGrace).Start(gcInterval, stopBackground)
+	}
+
+	if thumbnailStore, ok := documentStore.(core.ThumbnailStore); ok && thumbnailInterval > 0 && hasScenes {
+		logrus.WithField("interval", thumbnailInterval).Info("Thumbnail backfill enabled")
+		go thumbnails.NewService(thumbnailStore).StartBackfill(sceneScanner, thumbnailInterval, stopBackground)
+	}
+
+	if backupScheduler != nil {
+		logrus.WithFields(logrus.Fields{
+			"schedule":  backupSettings.Schedule.String(),
+			"bucket":    backupSettings.S3.Bucket,
+			"prefix":    backupSettings.Prefix,
+			"retention": backupSettings.Retention,
+		}).Info("Scheduled backups enabled")
+		go backupScheduler.Start(stopBackground)
+	}
+
+	if archiveMover != nil {
+		logrus.WithFields(logrus.Fields{
+			"idle_after": archiveSettings.IdleAfter,
+			"interval":   archiveSettings.Interval,
+			"path":       archiveSettings.Path,
+			"bucket":     archiveSettings.S3.Bucket,
+		}).Info("Archiving idle data enabled")
+		go archiveMover.Start(archiveSettings.Interval, stopBackground)
+	}
+
+	if admin != nil {
+		go admin.Start(stopBackground)
+	}
+
+	if cleaner != nil {
+		logrus.WithFields(logrus.Fields{
+			"policies": retentionSettings.After,
+			"interval": retentionSettings.Interval,
+			"webhook":  retentionSettings.WebhookURL != "",
+			"notice":   retentionSettings.Notice,
+			"dry_run":  retentionSettings.DryRun,
+		}).Info("Room cleanup enabled")
+		go cleaner.Start(retentionSettings.Interval, stopBackground)
+	}
+
+	if hibernateAfter > 0 {
+		logrus.WithField("idle_after", hibernateAfter).Info("Room hibernation enabled")
+		go websocket.StartHibernation(hibernateAfter, stopBackground)
+	}
+
+	if roomStatsInterval > 0 {
+		go websocket.StartRoomStats(ioo, roomStatsInterval, os.Getenv("SERVER_REGION"), stopBackground)
+	}
+
+	if sessionTTL > 0 {
+		logrus.WithField("ttl", sessionTTL).Info("Session resuming enabled")
+		go websocket.StartSessionRefresh(stopBackground)
+	}
+
 	logrus.Debug("Server is running in the background")
-	waitForShutdown(ioo)
+	waitForShutdown(ioo, reloader)
 
 }
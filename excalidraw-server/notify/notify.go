@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/core"
+	"excalidraw-server/mail"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed with
+// NOTIFY_WEBHOOK_SECRET.
+const SignatureHeader = "X-Excalidraw-Signature"
+
+// Notifier delivers a notification to a user outside the app.
+type Notifier interface {
+	Notify(ctx context.Context, user core.User, notification core.Notification) error
+}
+
+// Multi delivers through every notifier, returning their errors joined.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, user core.User, notification core.Notification) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, user, notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FromEnv builds the notifiers configured by NOTIFY_WEBHOOK_URL and
+// NOTIFY_WEBHOOK_SECRET, plus mention emails through mailer unless
+// NOTIFY_EMAIL=false. It returns nil when there are none.
+func FromEnv(mailer mail.Mailer, instance, publicURL string) (Notifier, error) {
+	var notifiers Multi
+	if endpoint := os.Getenv("NOTIFY_WEBHOOK_URL"); endpoint != "" {
+		webhook, err := NewWebhook(endpoint, os.Getenv("NOTIFY_WEBHOOK_SECRET"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid NOTIFY_WEBHOOK_URL: %w", err)
+		}
+		notifiers = append(notifiers, webhook)
+	}
+	if mailer != nil && os.Getenv("NOTIFY_EMAIL") != "false" {
+		notifiers = append(notifiers, &Email{Mailer: mailer, Instance: instance, URL: publicURL})
+	}
+
+	switch len(notifiers) {
+	case 0:
+		return nil, nil
+	case 1:
+		return notifiers[0], nil
+	}
+	return notifiers, nil
+}
+
+// Email mails mention notifications to users who have an email address.
+type Email struct {
+	Mailer   mail.Mailer
+	Instance string
+	// URL is linked from the emails when set
+	URL string
+}
+
+func (e *Email) Notify(ctx context.Context, user core.User, notification core.Notification) error {
+	if user.Email == "" || notification.Kind != core.NotificationMention {
+		return nil
+	}
+	message, err := mail.Render("mention", []string{user.Email}, mail.Mention{
+		Instance: e.Instance,
+		From:     notification.From,
+		RoomID:   notification.RoomID,
+		Text:     notification.Text,
+		URL:      e.URL,
+	})
+	if err != nil {
+		return err
+	}
+	return e.Mailer.Send(ctx, message)
+}
+
+// Webhook POSTs JSON to an endpoint, signed when it has a secret. As a
+// Notifier it sends { user, notification }.
+type Webhook struct {
+	endpoint string
+	secret   []byte
+	client   *http.Client
+}
+
+func NewWebhook(endpoint, secret string) (*Webhook, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("%q is not an http or https URL", endpoint)
+	}
+	return &Webhook{
+		endpoint: endpoint,
+		secret:   []byte(secret),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, user core.User, notification core.Notification) error {
+	return w.Post(ctx, map[string]any{
+		"user":         user,
+		"notification": notification,
+	})
+}
+
+// Post sends payload to the webhook as JSON.
+func (w *Webhook) Post(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"excalidraw-server/core"
+	"excalidraw-server/mail"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	var received struct {
+		User         core.User         `json:"user"`
+		Notification core.Notification `json:"notification"`
+	}
+	var signature string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(server.URL, "secret")
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	user := core.User{ID: "alice", Login: "alice"}
+	notification := core.Notification{ID: "n1", UserID: "alice", Kind: core.NotificationMention, Text: "hi @alice"}
+	if err := webhook.Notify(context.Background(), user, notification); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if received.User.ID != "alice" || received.Notification.ID != "n1" {
+		t.Errorf("webhook received %+v", received)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	if want := hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	webhook, _ := NewWebhook(server.URL, "")
+	if err := webhook.Notify(context.Background(), core.User{}, core.Notification{}); err == nil {
+		t.Error("Notify() succeeded against a failing endpoint")
+	}
+	if _, err := NewWebhook("mailto:alice@example.com", ""); err == nil {
+		t.Error("NewWebhook() accepted a non-http URL")
+	}
+}
+
+type recordingMailer struct {
+	sent []mail.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, message mail.Message) error {
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+func TestEmail(t *testing.T) {
+	mailer := &recordingMailer{}
+	email := &Email{Mailer: mailer, Instance: "Draw"}
+	notification := core.Notification{Kind: core.NotificationMention, RoomID: "r1", From: "Alice", Text: "@bob hi"}
+
+	if err := email.Notify(context.Background(), core.User{ID: "bob"}, notification); err != nil || len(mailer.sent) != 0 {
+		t.Fatalf("Notify() without an address = %v, sent %d", err, len(mailer.sent))
+	}
+	if err := email.Notify(context.Background(), core.User{ID: "bob", Email: "bob@example.com"}, notification); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].To[0] != "bob@example.com" || mailer.sent[0].Subject != "Alice mentioned you in room r1" {
+		t.Errorf("sent %+v", mailer.sent)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("NOTIFY_WEBHOOK_URL", "")
+	t.Setenv("NOTIFY_EMAIL", "")
+	if notifier, err := FromEnv(nil, "Draw", ""); err != nil || notifier != nil {
+		t.Errorf("FromEnv() without settings = %v, %v, want nil", notifier, err)
+	}
+	if notifier, err := FromEnv(mail.Log{}, "Draw", ""); err != nil {
+		t.Errorf("FromEnv() error = %v", err)
+	} else if _, ok := notifier.(*Email); !ok {
+		t.Errorf("FromEnv() with a mailer = %T, want *Email", notifier)
+	}
+	t.Setenv("NOTIFY_EMAIL", "false")
+	if notifier, _ := FromEnv(mail.Log{}, "Draw", ""); notifier != nil {
+		t.Errorf("FromEnv() with NOTIFY_EMAIL=false = %v, want nil", notifier)
+	}
+
+	t.Setenv("NOTIFY_EMAIL", "")
+	t.Setenv("NOTIFY_WEBHOOK_URL", "https://hooks.example.com")
+	if notifier, _ := FromEnv(mail.Log{}, "Draw", ""); len(notifier.(Multi)) != 2 {
+		t.Errorf("FromEnv() with a webhook and a mailer = %v, want both", notifier)
+	}
+	t.Setenv("NOTIFY_WEBHOOK_URL", "ftp://example.com")
+	if _, err := FromEnv(nil, "Draw", ""); err == nil {
+		t.Error("FromEnv() accepted an ftp URL")
+	}
+}
@@ -0,0 +1,223 @@
+package ratelimit
+
+import (
+	"context"
+	"excalidraw-server/apierror"
+	"excalidraw-server/auth"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Limiter decides whether a request identified by key may proceed. When it
+// may not, retryAfter tells the client how long to wait.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration, err error)
+	// SetRate changes the limit at runtime; perMinute <= 0 disables it.
+	SetRate(perMinute, burst int)
+}
+
+const (
+	defaultPerMinute = 30
+	defaultBurst     = 10
+)
+
+// GetLimiter builds the limiter configured by RATE_LIMIT_REDIS_URL, falling
+// back to an in-memory limiter, with the rate from RateFromEnv.
+func GetLimiter() (Limiter, error) {
+	perMinute, burst, err := RateFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := logrus.Fields{
+		"perMinute": perMinute,
+		"burst":     burst,
+	}
+
+	var limiter Limiter
+	if url := os.Getenv("RATE_LIMIT_REDIS_URL"); url != "" {
+		limiter, err = NewRedisLimiter(url, perMinute, burst)
+		if err != nil {
+			return nil, err
+		}
+		fields["backend"] = "redis"
+	} else {
+		limiter = NewMemoryLimiter(perMinute, burst)
+		fields["backend"] = "memory"
+	}
+
+	logrus.WithFields(fields).Info("Use rate limiter")
+	return limiter, nil
+}
+
+// RateFromEnv reads RATE_LIMIT_PER_MINUTE and RATE_LIMIT_BURST.
+func RateFromEnv() (perMinute, burst int, err error) {
+	perMinute, burst = defaultPerMinute, defaultBurst
+	if value := os.Getenv("RATE_LIMIT_PER_MINUTE"); value != "" {
+		if perMinute, err = strconv.Atoi(value); err != nil {
+			return 0, 0, fmt.Errorf("invalid RATE_LIMIT_PER_MINUTE: %w", err)
+		}
+	}
+	if value := os.Getenv("RATE_LIMIT_BURST"); value != "" {
+		if burst, err = strconv.Atoi(value); err != nil {
+			return 0, 0, fmt.Errorf("invalid RATE_LIMIT_BURST: %w", err)
+		}
+	}
+	return perMinute, burst, nil
+}
+
+// KeyFunc derives the rate limit key for a request.
+type KeyFunc func(r *http.Request) string
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryLimiter is an in-process token bucket limiter. Each key refills at
+// perMinute tokens per minute up to burst tokens.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	perMinute float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter(perMinute, burst int) *MemoryLimiter {
+	l := &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	l.SetRate(perMinute, burst)
+	return l
+}
+
+// SetRate changes the refill rate and burst size; existing buckets keep
+// their tokens, capped at the new burst.
+func (l *MemoryLimiter) SetRate(perMinute, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.perMinute = float64(perMinute)
+	l.burst = float64(burst)
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	if l.perMinute <= 0 {
+		return true, 0, nil
+	}
+	perSecond := l.perMinute / 60
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	return false, wait, nil
+}
+
+// sweep drops buckets that have refilled completely, at most once a minute.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	perSecond := l.perMinute / 60
+	for key, b := range l.buckets {
+		if perSecond <= 0 || b.tokens+now.Sub(b.last).Seconds()*perSecond >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware rejects requests over the limit with 429 Too Many Requests and
+// a Retry-After header, and tells onLimited, if set, whose they were.
+// Limiter failures let the request through.
+func Middleware(limiter Limiter, keyFn KeyFunc, onLimited func(key string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFn(r)
+			allowed, retryAfter, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				logrus.WithError(err).Error("Rate limiter unavailable, allowing request")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				logrus.WithFields(logrus.Fields{
+					"key":  key,
+					"path": r.URL.Path,
+				}).Warn("Rate limit exceeded")
+				if onLimited != nil {
+					onLimited(key)
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				apierror.Write(w, http.StatusTooManyRequests, apierror.RateLimited, "Too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// KeyByIP keys requests by client address.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// KeyByUserOrIP keys requests carrying a valid bearer token by user, and
+// all other requests by client address.
+func KeyByUserOrIP(verifier *auth.Verifier) KeyFunc {
+	return func(r *http.Request) string {
+		if verifier != nil {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token != "" {
+				if claims, err := verifier.Verify(token); err == nil {
+					return "user:" + claims.Subject
+				}
+			}
+		}
+		return KeyByIP(r)
+	}
+}
@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestLimiter(perMinute, burst int) (*MemoryLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	limiter := NewMemoryLimiter(perMinute, burst)
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func TestMemoryLimiter_Burst(t *testing.T) {
+	limiter, _ := newTestLimiter(60, 3)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if allowed, _, _ := limiter.Allow(ctx, "a"); !allowed {
+			t.Fatalf("request %d should be allowed within burst", i)
+		}
+	}
+
+	allowed, retryAfter, err := limiter.Allow(ctx, "a")
+	if err != nil {
+		t.Fatalf("Allow() failed: %v", err)
+	}
+	if allowed {
+		t.Fatal("request over burst should be rejected")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("unexpected retryAfter %v for 1 token/second", retryAfter)
+	}
+}
+
+func TestMemoryLimiter_Refill(t *testing.T) {
+	limiter, clock := newTestLimiter(60, 1)
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "a")
+	if allowed, _, _ := limiter.Allow(ctx, "a"); allowed {
+		t.Fatal("expected bucket to be empty")
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if allowed, _, _ := limiter.Allow(ctx, "a"); !allowed {
+		t.Error("expected a token after one second")
+	}
+}
+
+func TestMemoryLimiter_KeysAreIndependent(t *testing.T) {
+	limiter, _ := newTestLimiter(60, 1)
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "a")
+	if allowed, _, _ := limiter.Allow(ctx, "b"); !allowed {
+		t.Error("a different key should have its own bucket")
+	}
+}
+
+func TestMemoryLimiter_Disabled(t *testing.T) {
+	limiter, _ := newTestLimiter(0, 1)
+	for i := 0; i < 100; i++ {
+		if allowed, _, _ := limiter.Allow(context.Background(), "a"); !allowed {
+			t.Fatal("a zero rate should disable limiting")
+		}
+	}
+}
+
+func TestMemoryLimiter_SetRate(t *testing.T) {
+	limiter, _ := newTestLimiter(60, 1)
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "a")
+	limiter.SetRate(0, 1)
+	if allowed, _, _ := limiter.Allow(ctx, "a"); !allowed {
+		t.Error("expected limiting to be disabled after SetRate(0)")
+	}
+}
+
+func TestMemoryLimiter_SweepsFullBuckets(t *testing.T) {
+	limiter, clock := newTestLimiter(60, 2)
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "a")
+	clock.now = clock.now.Add(2 * time.Minute)
+	limiter.Allow(ctx, "b")
+
+	limiter.mu.Lock()
+	_, exists := limiter.buckets["a"]
+	limiter.mu.Unlock()
+	if exists {
+		t.Error("expected refilled bucket to be swept")
+	}
+}
+
+type stubLimiter struct {
+	allowed    bool
+	retryAfter time.Duration
+	err        error
+}
+
+func (s stubLimiter) Allow(context.Context, string) (bool, time.Duration, error) {
+	return s.allowed, s.retryAfter, s.err
+}
+
+func (s stubLimiter) SetRate(int, int) {}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		limiter        stubLimiter
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"allowed", stubLimiter{allowed: true}, http.StatusOK, ""},
+		{"rejected", stubLimiter{retryAfter: 2500 * time.Millisecond}, http.StatusTooManyRequests, "3"},
+		{"rejected sub-second", stubLimiter{retryAfter: time.Millisecond}, http.StatusTooManyRequests, "1"},
+		{"limiter error", stubLimiter{err: errors.New("redis down")}, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limited := ""
+			handler := Middleware(tt.limiter, KeyByIP, func(key string) { limited = key })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v2/post/", http.NoBody))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			if wantLimited := tt.wantStatus == http.StatusTooManyRequests; (limited != "") != wantLimited {
+				t.Errorf("onLimited got %q", limited)
+			}
+		})
+	}
+}
+
+func TestKeyByIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if got := KeyByIP(req); got != "ip:192.0.2.1" {
+		t.Errorf("KeyByIP() = %q", got)
+	}
+}
+
+func TestKeyByUserOrIP(t *testing.T) {
+	verifier := auth.NewVerifier([]byte("secret"))
+	token, err := verifier.Sign(&auth.Claims{Subject: "42"})
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	keyFn := KeyByUserOrIP(verifier)
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("Authorization", "Bearer "+token)
+	if got := keyFn(req); got != "user:42" {
+		t.Errorf("expected user key, got %q", got)
+	}
+
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	if got := keyFn(req); got != "ip:192.0.2.1" {
+		t.Errorf("expected invalid tokens to fall back to IP, got %q", got)
+	}
+}
+
+func TestRateFromEnv(t *testing.T) {
+	t.Setenv("RATE_LIMIT_PER_MINUTE", "")
+	t.Setenv("RATE_LIMIT_BURST", "")
+	perMinute, burst, err := RateFromEnv()
+	if err != nil || perMinute != defaultPerMinute || burst != defaultBurst {
+		t.Errorf("defaults = (%d, %d, %v)", perMinute, burst, err)
+	}
+
+	t.Setenv("RATE_LIMIT_PER_MINUTE", "120")
+	t.Setenv("RATE_LIMIT_BURST", "5")
+	perMinute, burst, err = RateFromEnv()
+	if err != nil || perMinute != 120 || burst != 5 {
+		t.Errorf("configured = (%d, %d, %v)", perMinute, burst, err)
+	}
+
+	t.Setenv("RATE_LIMIT_BURST", "lots")
+	if _, _, err := RateFromEnv(); err == nil {
+		t.Error("expected error for invalid burst")
+	}
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills and takes a token atomically. It returns
+// {allowed, retryAfterMillis}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate) + 1000)
+return {allowed, wait}
+`)
+
+// RedisLimiter is a token bucket limiter shared by every server instance
+// using the same Redis database.
+type RedisLimiter struct {
+	client    *redis.Client
+	prefix    string
+	mu        sync.RWMutex
+	perMinute int
+	burst     int
+}
+
+// NewRedisLimiter connects to the Redis server at url (redis://host:port/db).
+func NewRedisLimiter(url string, perMinute, burst int) (*RedisLimiter, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &RedisLimiter{
+		client: redis.NewClient(opts),
+		prefix: "excalidraw:ratelimit:",
+	}
+	l.SetRate(perMinute, burst)
+	return l, nil
+}
+
+func (l *RedisLimiter) SetRate(perMinute, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.perMinute = perMinute
+	l.burst = burst
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	l.mu.RLock()
+	perMinute, burst := l.perMinute, l.burst
+	l.mu.RUnlock()
+
+	if perMinute <= 0 {
+		return true, 0, nil
+	}
+
+	perMilli := float64(perMinute) / float64(time.Minute/time.Millisecond)
+	result, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key},
+		perMilli, burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,406 @@
+// Package retention deletes the snapshots, autosaves and chat history of
+// rooms nobody used for longer than configured, so instances can follow
+// data-retention rules. Deletions can be announced to a webhook a while
+// before they happen, and reported without deleting anything.
+package retention
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"excalidraw-server/notify"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultInterval = 24 * time.Hour
+	// defaultNotice is how long deletions wait after they were announced to
+	// the webhook, unless RETENTION_NOTICE says otherwise
+	defaultNotice = 24 * time.Hour
+)
+
+// Kind is a kind of room data a policy deletes.
+type Kind string
+
+const (
+	// Snapshots are the snapshots users saved.
+	Snapshots Kind = "snapshots"
+	// Autosaves are the snapshots the app saved on its own.
+	Autosaves Kind = "autosaves"
+	// Chat is the chat history of hibernated rooms.
+	Chat Kind = "chat"
+)
+
+// kinds are the kinds of data in the order they are deleted.
+var kinds = []Kind{Autosaves, Snapshots, Chat}
+
+// Settings configures the cleanup policies.
+type Settings struct {
+	// After is how long a room must be inactive before each kind of its
+	// data is deleted; kinds without a policy are kept.
+	After map[Kind]time.Duration
+	// Interval is how often to look for inactive rooms.
+	Interval time.Duration
+	// WebhookURL is told about deletions Notice before they happen.
+	WebhookURL    string
+	WebhookSecret string
+	Notice        time.Duration
+	// DryRun only logs what would be deleted.
+	DryRun bool
+}
+
+// SettingsFromEnv reads RETENTION_SNAPSHOT_DAYS, RETENTION_AUTOSAVE_DAYS,
+// RETENTION_CHAT_DAYS, RETENTION_INTERVAL, RETENTION_WEBHOOK_URL,
+// RETENTION_WEBHOOK_SECRET, RETENTION_NOTICE and RETENTION_DRY_RUN. It
+// returns nil when no policy is set, which disables cleanup.
+func SettingsFromEnv() (*Settings, error) {
+	settings := &Settings{
+		After:         make(map[Kind]time.Duration),
+		Interval:      defaultInterval,
+		WebhookURL:    os.Getenv("RETENTION_WEBHOOK_URL"),
+		WebhookSecret: os.Getenv("RETENTION_WEBHOOK_SECRET"),
+		Notice:        defaultNotice,
+		DryRun:        os.Getenv("RETENTION_DRY_RUN") == "true",
+	}
+	for kind, key := range map[Kind]string{
+		Snapshots: "RETENTION_SNAPSHOT_DAYS",
+		Autosaves: "RETENTION_AUTOSAVE_DAYS",
+		Chat:      "RETENTION_CHAT_DAYS",
+	} {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+		settings.After[kind] = time.Duration(days) * 24 * time.Hour
+	}
+	if len(settings.After) == 0 {
+		return nil, nil
+	}
+
+	for key, target := range map[string]*time.Duration{
+		"RETENTION_INTERVAL": &settings.Interval,
+		"RETENTION_NOTICE":   &settings.Notice,
+	} {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < 0 || duration == 0 && key == "RETENTION_INTERVAL" {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+		*target = duration
+	}
+	return settings, nil
+}
+
+type (
+	// RoomPlan is what cleanup deletes of an inactive room.
+	RoomPlan struct {
+		core.RoomActivity
+		Delete []Kind `json:"delete"`
+		// NotifiedAt is when the webhook was told, in Unix milliseconds
+		NotifiedAt int64 `json:"notified_at,omitempty"`
+		// DeleteAfter is the earliest the data is deleted when deletions
+		// are announced, in Unix milliseconds
+		DeleteAfter int64 `json:"delete_after,omitempty"`
+	}
+
+	// Report is what cleanup would delete if it ran now.
+	Report struct {
+		GeneratedAt int64 `json:"generated_at"`
+		DryRun      bool  `json:"dry_run"`
+		// Policies are the days of inactivity after which each kind of
+		// data is deleted
+		Policies map[Kind]int `json:"policies"`
+		Rooms    []RoomPlan   `json:"rooms"`
+	}
+
+	// Result counts what a run did.
+	Result struct {
+		Notified     int `json:"notified"`
+		Rooms        int `json:"rooms"`
+		Snapshots    int `json:"snapshots"`
+		Autosaves    int `json:"autosaves"`
+		ChatMessages int `json:"chat_messages"`
+	}
+)
+
+// announcement is a deletion the webhook was told about.
+type announcement struct {
+	at    time.Time
+	kinds string
+}
+
+// Cleaner runs the cleanup policies.
+type Cleaner struct {
+	store    core.RoomRetentionStore
+	settings Settings
+	// purgeChat deletes a hibernated room's chat history
+	purgeChat func(ctx context.Context, roomID string) (int, error)
+	// isLive reports whether a room has sockets; live rooms are skipped
+	isLive  func(roomID string) bool
+	webhook *notify.Webhook
+
+	mu        sync.Mutex
+	announced map[string]announcement
+	now       func() time.Time
+}
+
+func NewCleaner(store core.RoomRetentionStore, settings Settings, purgeChat func(ctx context.Context, roomID string) (int, error), isLive func(roomID string) bool) (*Cleaner, error) {
+	c := &Cleaner{
+		store:     store,
+		settings:  settings,
+		purgeChat: purgeChat,
+		isLive:    isLive,
+		announced: make(map[string]announcement),
+		now:       time.Now,
+	}
+	if settings.WebhookURL != "" {
+		webhook, err := notify.NewWebhook(settings.WebhookURL, settings.WebhookSecret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RETENTION_WEBHOOK_URL: %w", err)
+		}
+		c.webhook = webhook
+	}
+	return c, nil
+}
+
+// plan returns the inactive rooms with data the policies delete.
+func (c *Cleaner) plan(ctx context.Context, now time.Time) ([]RoomPlan, error) {
+	shortest := time.Duration(0)
+	for _, after := range c.settings.After {
+		if shortest == 0 || after < shortest {
+			shortest = after
+		}
+	}
+	rooms, err := c.store.ListInactiveRooms(ctx, now.Add(-shortest))
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []RoomPlan
+	for _, room := range rooms {
+		if c.isLive(room.RoomID) {
+			continue
+		}
+		inactive := now.Sub(time.UnixMilli(room.LastActive))
+		plan := RoomPlan{RoomActivity: room}
+		for _, kind := range kinds {
+			after, ok := c.settings.After[kind]
+			if !ok || inactive < after || !hasData(room, kind) {
+				continue
+			}
+			plan.Delete = append(plan.Delete, kind)
+		}
+		if len(plan.Delete) > 0 {
+			plans = append(plans, plan)
+		}
+	}
+	return plans, nil
+}
+
+func hasData(room core.RoomActivity, kind Kind) bool {
+	switch kind {
+	case Snapshots:
+		return room.Snapshots > 0
+	case Autosaves:
+		return room.Autosaves > 0
+	default:
+		return room.HasState
+	}
+}
+
+func kindsKey(plan RoomPlan) string {
+	names := make([]string, len(plan.Delete))
+	for i, kind := range plan.Delete {
+		names[i] = string(kind)
+	}
+	return strings.Join(names, ",")
+}
+
+// Report returns what a run would delete now, without deleting or
+// announcing anything.
+func (c *Cleaner) Report(ctx context.Context) (Report, error) {
+	now := c.now()
+	plans, err := c.plan(ctx, now)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{
+		GeneratedAt: now.UnixMilli(),
+		DryRun:      c.settings.DryRun,
+		Policies:    make(map[Kind]int, len(c.settings.After)),
+		Rooms:       plans,
+	}
+	for kind, after := range c.settings.After {
+		report.Policies[kind] = int(after / (24 * time.Hour))
+	}
+	if report.Rooms == nil {
+		report.Rooms = []RoomPlan{}
+	}
+	if c.webhook == nil {
+		return report, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, plan := range report.Rooms {
+		deleteAfter := now.Add(c.settings.Notice)
+		if announced, ok := c.announced[plan.RoomID]; ok && announced.kinds == kindsKey(plan) {
+			report.Rooms[i].NotifiedAt = announced.at.UnixMilli()
+			deleteAfter = announced.at.Add(c.settings.Notice)
+		}
+		report.Rooms[i].DeleteAfter = max(deleteAfter.UnixMilli(), now.UnixMilli())
+	}
+	return report, nil
+}
+
+// Run applies the policies once. With a webhook, rooms are announced first
+// and their data is deleted on a later run once Notice has passed, if they
+// stayed inactive.
+func (c *Cleaner) Run(ctx context.Context) (Result, error) {
+	now := c.now()
+	plans, err := c.plan(ctx, now)
+	if err != nil {
+		return Result{}, err
+	}
+	if c.settings.DryRun {
+		for _, plan := range plans {
+			logrus.WithFields(logrus.Fields{"room_id": plan.RoomID, "delete": plan.Delete}).Info("Dry run: not deleting inactive room data")
+		}
+		return Result{}, nil
+	}
+
+	var result Result
+	due := plans
+	if c.webhook != nil {
+		if due, result.Notified, err = c.announce(ctx, plans, now); err != nil {
+			return result, err
+		}
+	}
+
+	var errs []error
+	for _, plan := range due {
+		if err := c.cleanRoom(ctx, plan, &result); err != nil {
+			logrus.WithField("room_id", plan.RoomID).WithError(err).Warn("Failed to delete inactive room data")
+			errs = append(errs, err)
+			continue
+		}
+		result.Rooms++
+		c.mu.Lock()
+		delete(c.announced, plan.RoomID)
+		c.mu.Unlock()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"rooms":         result.Rooms,
+		"snapshots":     result.Snapshots,
+		"autosaves":     result.Autosaves,
+		"chat_messages": result.ChatMessages,
+		"notified":      result.Notified,
+	}).Info("Room cleanup finished")
+	return result, errors.Join(errs...)
+}
+
+// announce tells the webhook about rooms it wasn't told about yet, or whose
+// planned deletions changed, and returns the rooms announced at least Notice
+// ago.
+func (c *Cleaner) announce(ctx context.Context, plans []RoomPlan, now time.Time) ([]RoomPlan, int, error) {
+	c.mu.Lock()
+	var due, fresh []RoomPlan
+	planned := make(map[string]bool, len(plans))
+	for _, plan := range plans {
+		planned[plan.RoomID] = true
+		announced, ok := c.announced[plan.RoomID]
+		switch {
+		case !ok || announced.kinds != kindsKey(plan):
+			fresh = append(fresh, plan)
+		case now.Sub(announced.at) >= c.settings.Notice:
+			due = append(due, plan)
+		}
+	}
+	// rooms used again since they were announced start over
+	for roomID := range c.announced {
+		if !planned[roomID] {
+			delete(c.announced, roomID)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(fresh) == 0 {
+		return due, 0, nil
+	}
+	err := c.webhook.Post(ctx, map[string]any{
+		"event":        "room_cleanup.scheduled",
+		"delete_after": now.Add(c.settings.Notice).UnixMilli(),
+		"rooms":        fresh,
+	})
+	if err != nil {
+		return due, 0, fmt.Errorf("announce room cleanup: %w", err)
+	}
+
+	c.mu.Lock()
+	for _, plan := range fresh {
+		c.announced[plan.RoomID] = announcement{at: now, kinds: kindsKey(plan)}
+	}
+	c.mu.Unlock()
+	if c.settings.Notice == 0 {
+		due = append(due, fresh...)
+	}
+	return due, len(fresh), nil
+}
+
+// cleanRoom deletes the data plan names.
+func (c *Cleaner) cleanRoom(ctx context.Context, plan RoomPlan, result *Result) error {
+	for _, kind := range plan.Delete {
+		switch kind {
+		case Snapshots, Autosaves:
+			deleted, err := c.store.DeleteRoomSnapshots(ctx, plan.RoomID, kind == Autosaves)
+			if err != nil {
+				return err
+			}
+			if kind == Autosaves {
+				result.Autosaves += deleted
+			} else {
+				result.Snapshots += deleted
+			}
+		case Chat:
+			purged, err := c.purgeChat(ctx, plan.RoomID)
+			if err != nil {
+				return err
+			}
+			result.ChatMessages += purged
+		}
+	}
+	return nil
+}
+
+// Start runs the cleaner every interval until stop is closed.
+func (c *Cleaner) Start(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := c.Run(context.Background()); err != nil {
+				logrus.WithError(err).Error("Room cleanup failed")
+			}
+		}
+	}
+}
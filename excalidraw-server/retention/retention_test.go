@@ -0,0 +1,234 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/core"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// fakeStore keeps room activity in memory and records deletions.
+type fakeStore struct {
+	rooms   []core.RoomActivity
+	deleted map[string][]Kind
+}
+
+func (s *fakeStore) ListInactiveRooms(_ context.Context, before time.Time) ([]core.RoomActivity, error) {
+	var rooms []core.RoomActivity
+	for _, room := range s.rooms {
+		if room.LastActive < before.UnixMilli() {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+func (s *fakeStore) DeleteRoomSnapshots(_ context.Context, roomID string, autosaves bool) (int, error) {
+	for i, room := range s.rooms {
+		if room.RoomID != roomID {
+			continue
+		}
+		if autosaves {
+			s.deleted[roomID] = append(s.deleted[roomID], Autosaves)
+			deleted := room.Autosaves
+			s.rooms[i].Autosaves = 0
+			return deleted, nil
+		}
+		s.deleted[roomID] = append(s.deleted[roomID], Snapshots)
+		deleted := room.Snapshots
+		s.rooms[i].Snapshots = 0
+		return deleted, nil
+	}
+	return 0, nil
+}
+
+func (s *fakeStore) ReplaceRoomState(context.Context, string, []byte) error {
+	return nil
+}
+
+func newTestCleaner(t *testing.T, settings Settings, now time.Time, live ...string) (*Cleaner, *fakeStore) {
+	t.Helper()
+	ago := func(d time.Duration) int64 { return now.Add(-d).UnixMilli() }
+	store := &fakeStore{
+		rooms: []core.RoomActivity{
+			{RoomID: "ancient", LastActive: ago(100 * day), Snapshots: 2, Autosaves: 3, HasState: true},
+			{RoomID: "month", LastActive: ago(40 * day), Autosaves: 1, HasState: true},
+			{RoomID: "week", LastActive: ago(8 * day), Snapshots: 1},
+			{RoomID: "busy", LastActive: ago(200 * day), Snapshots: 1},
+		},
+		deleted: make(map[string][]Kind),
+	}
+	liveRooms := make(map[string]bool)
+	for _, roomID := range live {
+		liveRooms[roomID] = true
+	}
+	purgeChat := func(_ context.Context, roomID string) (int, error) {
+		store.deleted[roomID] = append(store.deleted[roomID], Chat)
+		return 5, nil
+	}
+	cleaner, err := NewCleaner(store, settings, purgeChat, func(roomID string) bool { return liveRooms[roomID] })
+	if err != nil {
+		t.Fatalf("NewCleaner() error = %v", err)
+	}
+	cleaner.now = func() time.Time { return now }
+	return cleaner, store
+}
+
+func TestRun(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cleaner, store := newTestCleaner(t, Settings{After: map[Kind]time.Duration{
+		Autosaves: 30 * day,
+		Snapshots: 90 * day,
+		Chat:      7 * day,
+	}}, now, "busy")
+
+	result, err := cleaner.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := map[string][]Kind{
+		"ancient": {Autosaves, Snapshots, Chat},
+		"month":   {Autosaves, Chat},
+	}
+	if !reflect.DeepEqual(store.deleted, want) {
+		t.Errorf("deleted %v, want %v", store.deleted, want)
+	}
+	if want := (Result{Rooms: 2, Snapshots: 2, Autosaves: 4, ChatMessages: 10}); result != want {
+		t.Errorf("Run() = %+v, want %+v", result, want)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	cleaner, store := newTestCleaner(t, Settings{
+		After:  map[Kind]time.Duration{Snapshots: 7 * day},
+		DryRun: true,
+	}, time.Unix(1700000000, 0))
+
+	if _, err := cleaner.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(store.deleted) != 0 {
+		t.Errorf("dry run deleted %v", store.deleted)
+	}
+
+	report, err := cleaner.Report(context.Background())
+	if err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	var rooms []string
+	for _, plan := range report.Rooms {
+		rooms = append(rooms, plan.RoomID)
+	}
+	if !reflect.DeepEqual(rooms, []string{"ancient", "week", "busy"}) || !report.DryRun || report.Policies[Snapshots] != 7 {
+		t.Errorf("Report() = %+v", report)
+	}
+}
+
+func TestRunAnnouncesFirst(t *testing.T) {
+	var mu sync.Mutex
+	var announced []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		announced = append(announced, payload)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	now := time.Unix(1700000000, 0)
+	cleaner, store := newTestCleaner(t, Settings{
+		After:      map[Kind]time.Duration{Snapshots: 90 * day},
+		WebhookURL: server.URL,
+		Notice:     day,
+	}, now)
+	cleaner.now = func() time.Time { return now }
+
+	result, err := cleaner.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Notified != 2 || len(store.deleted) != 0 {
+		t.Fatalf("first run = %+v and deleted %v, want only announcements", result, store.deleted)
+	}
+	if len(announced) != 1 || announced[0]["event"] != "room_cleanup.scheduled" || len(announced[0]["rooms"].([]any)) != 2 {
+		t.Fatalf("webhook got %v", announced)
+	}
+
+	report, _ := cleaner.Report(context.Background())
+	if got := report.Rooms[0].DeleteAfter; got != now.Add(day).UnixMilli() {
+		t.Errorf("DeleteAfter = %d, want a day from now", got)
+	}
+
+	// before the notice passed nothing happens, not even a second webhook
+	now = now.Add(time.Hour)
+	if result, _ := cleaner.Run(context.Background()); result.Notified != 0 || len(store.deleted) != 0 {
+		t.Errorf("run during the notice = %+v, deleted %v", result, store.deleted)
+	}
+
+	now = now.Add(day)
+	if result, err := cleaner.Run(context.Background()); err != nil || result.Rooms != 2 {
+		t.Errorf("run after the notice = %+v, %v, want 2 rooms cleaned", result, err)
+	}
+	if len(announced) != 1 {
+		t.Errorf("webhook was called %d times, want once", len(announced))
+	}
+}
+
+func TestRunKeepsDataWhenAnnouncingFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cleaner, store := newTestCleaner(t, Settings{
+		After:      map[Kind]time.Duration{Snapshots: 7 * day},
+		WebhookURL: server.URL,
+	}, time.Unix(1700000000, 0))
+	if _, err := cleaner.Run(context.Background()); err == nil {
+		t.Error("Run() succeeded while the webhook failed")
+	}
+	if len(store.deleted) != 0 {
+		t.Errorf("deleted %v without announcing it", store.deleted)
+	}
+}
+
+func TestSettingsFromEnv(t *testing.T) {
+	for _, key := range []string{"RETENTION_SNAPSHOT_DAYS", "RETENTION_AUTOSAVE_DAYS", "RETENTION_CHAT_DAYS", "RETENTION_INTERVAL", "RETENTION_NOTICE", "RETENTION_DRY_RUN"} {
+		t.Setenv(key, "")
+	}
+	if settings, err := SettingsFromEnv(); err != nil || settings != nil {
+		t.Errorf("SettingsFromEnv() without policies = %v, %v, want nil", settings, err)
+	}
+
+	t.Setenv("RETENTION_CHAT_DAYS", "30")
+	t.Setenv("RETENTION_NOTICE", "48h")
+	settings, err := SettingsFromEnv()
+	if err != nil {
+		t.Fatalf("SettingsFromEnv() error = %v", err)
+	}
+	if settings.After[Chat] != 30*day || settings.Notice != 48*time.Hour || settings.Interval != defaultInterval {
+		t.Errorf("SettingsFromEnv() = %+v", settings)
+	}
+
+	for key, value := range map[string]string{
+		"RETENTION_SNAPSHOT_DAYS": "0",
+		"RETENTION_INTERVAL":      "0s",
+		"RETENTION_NOTICE":        "soon",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := SettingsFromEnv(); err == nil {
+				t.Errorf("SettingsFromEnv() accepted %s=%q", key, value)
+			}
+		})
+	}
+}
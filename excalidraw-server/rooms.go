@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/config"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/snapshots"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/retention"
+	"excalidraw-server/stores"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const roomsUsage = `usage: excalidraw-server rooms <command> [flags]
+
+commands:
+  prune -inactive 30d [-dry-run]   delete the snapshots, autosaves and chat
+                                   history of rooms inactive that long
+  export [-dir DIR] [-inactive 30d] [ROOM...]
+                                   write rooms' snapshots and chat history
+                                   to DIR/<room>/
+
+flags of both:
+  -config FILE     KEY=VALUE config file to read the storage settings from
+  -loglevel LEVEL  logging level, warn by default
+`
+
+// roomsCommand is what the rooms subcommands work with.
+type roomsCommand struct {
+	store  core.DocumentStore
+	stdout io.Writer
+	now    func() time.Time
+}
+
+// runRooms runs the rooms maintenance subcommands directly against the
+// configured store, without starting the server, and returns the exit
+// status. They are meant for cron jobs.
+func runRooms(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "prune" && args[0] != "export" {
+		fmt.Fprint(stderr, roomsUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet("rooms "+args[0], flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configPath := flags.String("config", "", "")
+	logLevel := flags.String("loglevel", "warn", "")
+	inactive := flags.String("inactive", "", "")
+	dryRun := flags.Bool("dry-run", false, "")
+	dir := flags.String("dir", ".", "")
+	if err := flags.Parse(args[1:]); err != nil || args[0] == "prune" && (*inactive == "" || flags.NArg() > 0) {
+		fmt.Fprint(stderr, roomsUsage)
+		return 2
+	}
+
+	var age time.Duration
+	if *inactive != "" {
+		var err error
+		if age, err = parseAge(*inactive); err != nil {
+			fmt.Fprintf(stderr, "Invalid -inactive: %v\n", err)
+			return 2
+		}
+	}
+	level, err := logrus.ParseLevel(*logLevel)
+	if err != nil {
+		fmt.Fprintf(stderr, "Invalid log level: %v\n", err)
+		return 2
+	}
+	logrus.SetLevel(level)
+	if *configPath != "" {
+		if err := config.Load(*configPath); err != nil {
+			fmt.Fprintf(stderr, "Failed to load config: %v\n", err)
+			return 1
+		}
+	}
+
+	c := &roomsCommand{store: stores.GetStore(), stdout: stdout, now: time.Now}
+	ctx := context.Background()
+	if args[0] == "prune" {
+		err = c.prune(ctx, age, *dryRun)
+	} else {
+		err = c.export(ctx, *dir, age, flags.Args())
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "rooms %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// parseAge parses a duration that may also be given in days, like 30d.
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%q is not a number of days", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("%q is not a positive duration", value)
+	}
+	return age, nil
+}
+
+// prune applies one policy for every kind of room data, like the RETENTION_*
+// policies of the server but without a webhook. Rooms can't be checked for
+// sockets from here, so live rooms count as inactive too.
+func (c *roomsCommand) prune(ctx context.Context, inactive time.Duration, dryRun bool) error {
+	retentionStore, ok := c.store.(core.RoomRetentionStore)
+	if !ok {
+		return errors.New("pruning rooms requires SQLite storage")
+	}
+	if roomStateStore, ok := c.store.(core.RoomStateStore); ok {
+		websocket.SetRoomStateStore(roomStateStore)
+	}
+	purgeChat := func(ctx context.Context, roomID string) (int, error) {
+		return websocket.PurgeRoomChat(ctx, roomID, retentionStore.ReplaceRoomState)
+	}
+	settings := retention.Settings{After: map[retention.Kind]time.Duration{
+		retention.Snapshots: inactive,
+		retention.Autosaves: inactive,
+		retention.Chat:      inactive,
+	}}
+	cleaner, err := retention.NewCleaner(retentionStore, settings, purgeChat, func(string) bool { return false })
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		report, err := cleaner.Report(ctx)
+		if err != nil {
+			return err
+		}
+		table := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "ROOM\tLAST ACTIVE\tSNAPSHOTS\tAUTOSAVES\tDELETE")
+		for _, room := range report.Rooms {
+			kinds := make([]string, len(room.Delete))
+			for i, kind := range room.Delete {
+				kinds[i] = string(kind)
+			}
+			lastActive := time.UnixMilli(room.LastActive).UTC().Format(time.RFC3339)
+			fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%s\n", room.RoomID, lastActive, room.Snapshots, room.Autosaves, strings.Join(kinds, ","))
+		}
+		return table.Flush()
+	}
+
+	result, err := cleaner.Run(ctx)
+	fmt.Fprintf(c.stdout, "Pruned %d rooms: %d snapshots, %d autosaves, %d chat messages\n",
+		result.Rooms, result.Snapshots, result.Autosaves, result.ChatMessages)
+	return err
+}
+
+// export writes each room's snapshots to DIR/<room>/<snapshot>.excalidraw,
+// their metadata to snapshots.json and the chat history of hibernated rooms
+// to chat.json. Without room ids it exports every room with stored data, or
+// the ones inactive for longer than inactive.
+func (c *roomsCommand) export(ctx context.Context, dir string, inactive time.Duration, roomIDs []string) error {
+	snapshotStore, ok := c.store.(snapshots.SnapshotStore)
+	if !ok {
+		return errors.New("exporting rooms requires SQLite storage")
+	}
+	if roomStateStore, ok := c.store.(core.RoomStateStore); ok {
+		websocket.SetRoomStateStore(roomStateStore)
+	}
+	if len(roomIDs) == 0 {
+		retentionStore, ok := c.store.(core.RoomRetentionStore)
+		if !ok {
+			return errors.New("listing rooms requires SQLite storage")
+		}
+		// a millisecond ahead, so rooms active right now are included
+		rooms, err := retentionStore.ListInactiveRooms(ctx, c.now().Add(time.Millisecond-inactive))
+		if err != nil {
+			return err
+		}
+		for _, room := range rooms {
+			roomIDs = append(roomIDs, room.RoomID)
+		}
+	}
+
+	for _, roomID := range roomIDs {
+		if roomID == "" || roomID != filepath.Base(roomID) || roomID == "." || roomID == ".." {
+			return fmt.Errorf("invalid room id %q", roomID)
+		}
+		roomDir := filepath.Join(dir, roomID)
+		if err := os.MkdirAll(roomDir, 0o755); err != nil {
+			return err
+		}
+		list, err := snapshotStore.ListSnapshots(ctx, roomID)
+		if err != nil {
+			return fmt.Errorf("failed to list the snapshots of %s: %w", roomID, err)
+		}
+		if len(list) > 0 {
+			if err := writeJSON(filepath.Join(roomDir, "snapshots.json"), list); err != nil {
+				return err
+			}
+		}
+		for _, entry := range list {
+			snapshot, err := snapshotStore.GetSnapshot(ctx, entry.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get snapshot %s: %w", entry.ID, err)
+			}
+			if err := os.WriteFile(filepath.Join(roomDir, entry.ID+".excalidraw"), snapshot.Data, 0o644); err != nil {
+				return err
+			}
+		}
+
+		chat, err := websocket.GetRoomChat(ctx, roomID)
+		if err != nil {
+			return fmt.Errorf("failed to get the chat history of %s: %w", roomID, err)
+		}
+		if len(chat) > 0 {
+			if err := writeJSON(filepath.Join(roomDir, "chat.json"), chat); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintf(c.stdout, "Exported %d rooms to %s\n", len(roomIDs), dir)
+	return nil
+}
+
+func writeJSON(name string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644)
+}
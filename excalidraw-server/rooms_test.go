@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+	"excalidraw-server/handlers/api/snapshots"
+	"excalidraw-server/handlers/websocket"
+	"excalidraw-server/stores/sqlite"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestParseAge(t *testing.T) {
+	for value, want := range map[string]time.Duration{"30d": 30 * 24 * time.Hour, "12h": 12 * time.Hour} {
+		if age, err := parseAge(value); err != nil || age != want {
+			t.Errorf("parseAge(%q) = %v, %v, want %v", value, age, err, want)
+		}
+	}
+	for _, value := range []string{"", "0d", "-1h", "d", "month"} {
+		if _, err := parseAge(value); err == nil {
+			t.Errorf("parseAge(%q) succeeded", value)
+		}
+	}
+}
+
+func TestRoomsCommand(t *testing.T) {
+	ctx := context.Background()
+	store := sqlite.NewDocumentStore(filepath.Join(t.TempDir(), "rooms.db"))
+	t.Cleanup(func() { websocket.SetRoomStateStore(nil) })
+	snapshotStore := store.(snapshots.SnapshotStore)
+	id, err := snapshotStore.CreateSnapshot(ctx, "design", "v1", "", "", "alice", []byte(`{"elements":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snapshotStore.CreateSnapshot(ctx, "design", "", core.AutosaveDescription, "", "", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := msgpack.Marshal(map[string]any{"chat": []websocket.ChatMessage{{ID: "m1", RoomID: "design", Content: "hi"}}})
+	if err := store.(core.RoomStateStore).PutRoomState(ctx, "design", state); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	c := &roomsCommand{store: store, stdout: &out, now: time.Now}
+	dir := t.TempDir()
+	if err := c.export(ctx, dir, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "design", id+".excalidraw")); err != nil || string(data) != `{"elements":[]}` {
+		t.Errorf("exported snapshot = %q, %v", data, err)
+	}
+	for _, name := range []string{"snapshots.json", "chat.json"} {
+		if _, err := os.Stat(filepath.Join(dir, "design", name)); err != nil {
+			t.Errorf("%s wasn't exported: %v", name, err)
+		}
+	}
+	if err := c.export(ctx, dir, 0, []string{"../design"}); err == nil {
+		t.Error("export accepted a room id outside the directory")
+	}
+
+	// nothing is inactive for an hour yet
+	out.Reset()
+	if err := c.prune(ctx, time.Hour, false); err != nil || !strings.HasPrefix(out.String(), "Pruned 0 rooms") {
+		t.Fatalf("prune = %q, %v", out.String(), err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	out.Reset()
+	if err := c.prune(ctx, time.Millisecond, true); err != nil || !strings.Contains(out.String(), "autosaves,snapshots,chat") {
+		t.Fatalf("prune -dry-run = %q, %v", out.String(), err)
+	}
+	out.Reset()
+	if err := c.prune(ctx, time.Millisecond, false); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Pruned 1 rooms: 1 snapshots, 1 autosaves, 1 chat messages\n"; out.String() != want {
+		t.Errorf("prune = %q, want %q", out.String(), want)
+	}
+	if list, _ := snapshotStore.ListSnapshots(ctx, "design"); len(list) != 0 {
+		t.Errorf("%d snapshots left after pruning", len(list))
+	}
+}
@@ -0,0 +1,41 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore is a Store shared by every server instance using the same Redis
+// database; Redis expires the sessions.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore connects to the Redis server at url (redis://host:port/db).
+func NewRedisStore(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{
+		client: redis.NewClient(opts),
+		prefix: "excalidraw:session:",
+	}, nil
+}
+
+func (s *RedisStore) Put(ctx context.Context, token string, data []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+token, data, ttl).Err()
+}
+
+func (s *RedisStore) Take(ctx context.Context, token string) ([]byte, error) {
+	data, err := s.client.GetDel(ctx, s.prefix+token).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
@@ -0,0 +1,111 @@
+// Package sessions keeps short-lived descriptors of socket sessions, so a
+// client that reconnects to any instance can resume where it was.
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNotFound is returned for tokens that are unknown or expired.
+var ErrNotFound = errors.New("session not found")
+
+// Store keeps session descriptors by resume token.
+type Store interface {
+	// Put stores data under token for ttl, replacing what was there.
+	Put(ctx context.Context, token string, data []byte, ttl time.Duration) error
+	// Take returns the data under token and removes it, so a token resumes
+	// at most one connection.
+	Take(ctx context.Context, token string) ([]byte, error)
+}
+
+// TTLFromEnv reads SESSION_RESUME_TTL, how long a disconnected session can be
+// resumed. Zero, the default, disables resuming.
+func TTLFromEnv() (time.Duration, error) {
+	value := os.Getenv("SESSION_RESUME_TTL")
+	if value == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid SESSION_RESUME_TTL %q: must be a non-negative duration", value)
+	}
+	return ttl, nil
+}
+
+// GetStore builds the store configured by SESSION_REDIS_URL, falling back to
+// an in-memory store that only lets clients resume on the same instance.
+func GetStore() (Store, error) {
+	if url := os.Getenv("SESSION_REDIS_URL"); url != "" {
+		store, err := NewRedisStore(url)
+		if err != nil {
+			return nil, err
+		}
+		logrus.WithField("backend", "redis").Info("Use session store")
+		return store, nil
+	}
+	logrus.WithField("backend", "memory").Info("Use session store")
+	return NewMemoryStore(), nil
+}
+
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+// MemoryStore is an in-process Store.
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]memorySession),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) Put(_ context.Context, token string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	s.sessions[token] = memorySession{data: data, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Take(_ context.Context, token string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[token]
+	delete(s.sessions, token)
+	if !exists || !s.now().Before(session.expires) {
+		return nil, ErrNotFound
+	}
+	return session.data, nil
+}
+
+// sweep drops expired sessions, at most once a minute.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for token, session := range s.sessions {
+		if !now.Before(session.expires) {
+			delete(s.sessions, token)
+		}
+	}
+}
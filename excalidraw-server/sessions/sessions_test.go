@@ -0,0 +1,61 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	if err := store.Put(ctx, "token", []byte("session"), time.Minute); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	data, err := store.Take(ctx, "token")
+	if err != nil || string(data) != "session" {
+		t.Fatalf("Take() = %q, %v, want the session", data, err)
+	}
+	// A token resumes once
+	if _, err := store.Take(ctx, "token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Take() error = %v, want ErrNotFound", err)
+	}
+
+	_ = store.Put(ctx, "expiring", []byte("session"), time.Minute)
+	now = now.Add(time.Minute)
+	if _, err := store.Take(ctx, "expiring"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Take() of an expired session error = %v, want ErrNotFound", err)
+	}
+
+	// Expired sessions are swept on later writes
+	_ = store.Put(ctx, "stale", []byte("session"), time.Minute)
+	now = now.Add(2 * time.Minute)
+	_ = store.Put(ctx, "fresh", []byte("session"), time.Minute)
+	if _, exists := store.sessions["stale"]; exists {
+		t.Error("expired session was not swept")
+	}
+}
+
+func TestTTLFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "2m", want: 2 * time.Minute},
+		{value: "-1s", wantErr: true},
+		{value: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("SESSION_RESUME_TTL", tt.value)
+		got, err := TTLFromEnv()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("TTLFromEnv() with %q = %v, %v", tt.value, got, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package azureblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiVersion is the Blob service REST API version requests are made with.
+const apiVersion = "2021-08-06"
+
+// errNotFound is returned for blobs that don't exist.
+var errNotFound = errors.New("blob not found")
+
+// client talks to the Blob service REST API with Shared Key authorization.
+// It implements just the calls the store needs: put, get, list and delete.
+type client struct {
+	containerURL *url.URL
+	account      string
+	key          []byte
+	http         *http.Client
+	now          func() time.Time
+}
+
+// blob is a listed blob without its data.
+type blob struct {
+	Name         string
+	LastModified time.Time
+}
+
+func newClient(opts Options) (*client, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	key, _ := base64.StdEncoding.DecodeString(opts.Key)
+	containerURL, err := url.Parse(opts.serviceURL() + "/" + opts.Container)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		containerURL: containerURL,
+		account:      opts.Account,
+		key:          key,
+		http:         &http.Client{Timeout: 30 * time.Second},
+		now:          time.Now,
+	}, nil
+}
+
+func (c *client) blobURL(name string) *url.URL {
+	u := *c.containerURL
+	u.Path += "/" + name
+	u.RawPath = ""
+	return &u
+}
+
+// put uploads data as a block blob, replacing any blob with the same name.
+func (c *client) put(ctx context.Context, name string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.blobURL(name).String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	_, err = c.do(req, nil)
+	return err
+}
+
+// get downloads a blob and its last modification time.
+func (c *client) get(ctx context.Context, name string) ([]byte, time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.blobURL(name).String(), nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var data bytes.Buffer
+	header, err := c.do(req, &data)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	modified, _ := http.ParseTime(header.Get("Last-Modified"))
+	return data.Bytes(), modified, nil
+}
+
+// list returns the blobs whose names start with prefix.
+func (c *client) list(ctx context.Context, prefix string) ([]blob, error) {
+	var blobs []blob
+	marker := ""
+	for {
+		u := *c.containerURL
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		u.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var body bytes.Buffer
+		if _, err := c.do(req, &body); err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Blobs []struct {
+				Name         string `xml:"Name"`
+				LastModified string `xml:"Properties>Last-Modified"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		if err := xml.Unmarshal(body.Bytes(), &result); err != nil {
+			return nil, err
+		}
+		for _, b := range result.Blobs {
+			modified, _ := http.ParseTime(b.LastModified)
+			blobs = append(blobs, blob{Name: b.Name, LastModified: modified})
+		}
+		if result.NextMarker == "" {
+			return blobs, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// delete removes a blob.
+func (c *client) delete(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.blobURL(name).String(), nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(req, nil)
+	return err
+}
+
+// do signs and sends req, copying a successful response body into body.
+func (c *client) do(req *http.Request, body io.Writer) (http.Header, error) {
+	c.sign(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		var azureErr struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		_ = xml.Unmarshal(data, &azureErr)
+		// A missing container is a configuration error, not a missing blob
+		if resp.StatusCode == http.StatusNotFound && azureErr.Code != "ContainerNotFound" {
+			return nil, fmt.Errorf("azure blob %s: %w", req.URL.Path, errNotFound)
+		}
+		if azureErr.Code != "" {
+			return nil, fmt.Errorf("azure blob %s %s: %s: %s", req.Method, req.URL.Path, azureErr.Code, strings.TrimSpace(azureErr.Message))
+		}
+		return nil, fmt.Errorf("azure blob %s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+	if body != nil {
+		if _, err := io.Copy(body, resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	return resp.Header, nil
+}
+
+// sign adds the x-ms-date and x-ms-version headers and a Shared Key
+// Authorization header covering them.
+func (c *client) sign(req *http.Request) {
+	req.Header.Set("X-Ms-Date", c.now().UTC().Format(http.TimeFormat))
+	req.Header.Set("X-Ms-Version", apiVersion)
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(c.stringToSign(req)))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	req.Header.Set("Authorization", "SharedKey "+c.account+":"+signature)
+}
+
+// stringToSign builds the Shared Key string to sign for req: the method,
+// the standard headers, the x-ms-* headers and the canonicalized resource.
+func (c *client) stringToSign(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+	lines := []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, superseded by x-ms-date
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}
+
+	var msHeaders []string
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name+":"+strings.TrimSpace(strings.Join(values, ",")))
+		}
+	}
+	sort.Strings(msHeaders)
+	lines = append(lines, msHeaders...)
+
+	resource := "/" + c.account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		resource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+	return strings.Join(append(lines, resource), "\n")
+}
@@ -0,0 +1,162 @@
+package azureblob
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBlobService serves just enough of the Blob service API for the client,
+// addressed like Azurite: /{account}/{container}/{blob}.
+type fakeBlobService struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (f *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey devstoreaccount1:") || r.Header.Get("X-Ms-Version") != apiVersion {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<Error><Code>AuthenticationFailed</Code><Message>Server failed to authenticate the request.</Message></Error>")
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/devstoreaccount1/drawings") {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<Error><Code>ContainerNotFound</Code><Message>The specified container does not exist.</Message></Error>")
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/devstoreaccount1/drawings"), "/")
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("comp") == "list":
+		var names []string
+		for n := range f.blobs {
+			if strings.HasPrefix(n, r.URL.Query().Get("prefix")) {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		fmt.Fprint(w, "<EnumerationResults><Blobs>")
+		for _, n := range names {
+			fmt.Fprintf(w, "<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified></Properties></Blob>", n, modified)
+		}
+		fmt.Fprint(w, "</Blobs><NextMarker/></EnumerationResults>")
+	case r.Method == http.MethodPut:
+		if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.blobs[name] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet, r.Method == http.MethodDelete:
+		data, ok := f.blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>BlobNotFound</Code><Message>The specified blob does not exist.</Message></Error>")
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.blobs, name)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Last-Modified", modified)
+		_, _ = w.Write(data)
+	}
+}
+
+// newTestStore returns a store backed by a fake blob service.
+func newTestStore(t *testing.T) (*documentStore, *fakeBlobService) {
+	t.Helper()
+	fake := &fakeBlobService{blobs: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewDocumentStore(Options{
+		Account:   "devstoreaccount1",
+		Key:       "a2V5",
+		Container: "drawings",
+		Endpoint:  server.URL + "/devstoreaccount1/",
+	})
+	if err != nil {
+		t.Fatalf("NewDocumentStore() failed: %v", err)
+	}
+	return store.(*documentStore), fake
+}
+
+func TestStringToSign(t *testing.T) {
+	c, err := newClient(Options{Account: "myaccount", Key: "a2V5", Container: "drawings"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://myaccount.blob.core.windows.net/drawings?restype=container&comp=list&prefix=files%2F", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Ms-Date", "Fri, 26 Jun 2015 23:39:12 GMT")
+	req.Header.Set("X-Ms-Version", apiVersion)
+
+	want := "GET\n\n\n\n\n\n\n\n\n\n\n\n" +
+		"x-ms-date:Fri, 26 Jun 2015 23:39:12 GMT\n" +
+		"x-ms-version:" + apiVersion + "\n" +
+		"/myaccount/drawings\ncomp:list\nprefix:files/\nrestype:container"
+	if got := c.stringToSign(req); got != want {
+		t.Errorf("stringToSign() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("AZURE_STORAGE_ACCOUNT", "myaccount")
+	t.Setenv("AZURE_STORAGE_KEY", "a2V5")
+	t.Setenv("AZURE_STORAGE_CONTAINER", "drawings")
+	opts, err := OptionsFromEnv()
+	if err != nil {
+		t.Fatalf("OptionsFromEnv() failed: %v", err)
+	}
+	if opts.serviceURL() != "https://myaccount.blob.core.windows.net" {
+		t.Errorf("serviceURL() = %s", opts.serviceURL())
+	}
+
+	t.Setenv("AZURE_STORAGE_KEY", "not base64!")
+	if _, err := OptionsFromEnv(); err == nil {
+		t.Error("OptionsFromEnv() accepted a key that isn't base64")
+	}
+	t.Setenv("AZURE_STORAGE_KEY", "a2V5")
+	t.Setenv("AZURE_STORAGE_CONTAINER", "")
+	if _, err := OptionsFromEnv(); err == nil {
+		t.Error("OptionsFromEnv() accepted a missing container")
+	}
+}
+
+func TestMissingContainerIsNotNotFound(t *testing.T) {
+	store, _ := newTestStore(t)
+	store.client.containerURL.Path = "/devstoreaccount1/other"
+
+	_, err := store.FindID(context.Background(), "anything")
+	if err == nil || !strings.Contains(err.Error(), "ContainerNotFound") {
+		t.Errorf("FindID() error = %v, want ContainerNotFound", err)
+	}
+}
+
+func TestDocumentKeys(t *testing.T) {
+	store, fake := newTestStore(t)
+	id, err := store.Create(context.Background(), &core.Document{Data: *bytes.NewBufferString(`{"elements":[]}`)})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if _, ok := fake.blobs[documentsPrefix+id]; !ok {
+		t.Errorf("document wasn't stored as %s%s", documentsPrefix, id)
+	}
+}
@@ -0,0 +1,14 @@
+package azureblob
+
+import (
+	"excalidraw-server/core"
+	"excalidraw-server/stores/storetest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) core.DocumentStore {
+		store, _ := newTestStore(t)
+		return store
+	})
+}
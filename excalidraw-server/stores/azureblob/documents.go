@@ -0,0 +1,59 @@
+// Package azureblob stores documents and files as blobs in an Azure Storage
+// container.
+package azureblob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// documentsPrefix holds one blob per document, named by its id.
+const documentsPrefix = "documents/"
+
+type documentStore struct {
+	client *client
+}
+
+func NewDocumentStore(opts Options) (core.DocumentStore, error) {
+	c, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &documentStore{client: c}, nil
+}
+
+func (s *documentStore) FindID(ctx context.Context, id string) (*core.Document, error) {
+	log := logrus.WithField("document_id", id)
+
+	data, _, err := s.client.get(ctx, documentsPrefix+id)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			log.WithField("error", "document not found").Warn("Document with specified ID not found")
+			return nil, fmt.Errorf("document with id %s not found", id)
+		}
+		log.WithField("error", err).Error("Failed to retrieve document")
+		return nil, err
+	}
+
+	log.Info("Document retrieved successfully")
+	return &core.Document{Data: *bytes.NewBuffer(data)}, nil
+}
+
+func (s *documentStore) Create(ctx context.Context, document *core.Document) (string, error) {
+	id := ulid.Make().String()
+	log := logrus.WithField("document_id", id)
+
+	if err := s.client.put(ctx, documentsPrefix+id, document.Data.Bytes()); err != nil {
+		log.WithField("error", err).Error("Failed to create document")
+		return "", err
+	}
+
+	log.Info("Document created successfully")
+	return id, nil
+}
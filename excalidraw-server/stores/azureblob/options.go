@@ -0,0 +1,56 @@
+package azureblob
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Options locates a blob container and the shared key to access it.
+type Options struct {
+	Account string
+	// Key is the storage account's base64 access key.
+	Key       string
+	Container string
+	// Endpoint overrides the account's blob service URL, e.g.
+	// http://127.0.0.1:10000/devstoreaccount1 for Azurite.
+	Endpoint string
+}
+
+// OptionsFromEnv reads AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_KEY,
+// AZURE_STORAGE_CONTAINER and AZURE_STORAGE_ENDPOINT.
+func OptionsFromEnv() (Options, error) {
+	opts := Options{
+		Account:   os.Getenv("AZURE_STORAGE_ACCOUNT"),
+		Key:       os.Getenv("AZURE_STORAGE_KEY"),
+		Container: os.Getenv("AZURE_STORAGE_CONTAINER"),
+		Endpoint:  os.Getenv("AZURE_STORAGE_ENDPOINT"),
+	}
+	return opts, opts.validate()
+}
+
+func (o Options) validate() error {
+	if o.Account == "" || o.Key == "" || o.Container == "" {
+		return fmt.Errorf("AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_KEY and AZURE_STORAGE_CONTAINER are required")
+	}
+	if _, err := base64.StdEncoding.DecodeString(o.Key); err != nil {
+		return fmt.Errorf("AZURE_STORAGE_KEY is not base64: %w", err)
+	}
+	if o.Endpoint != "" {
+		parsed, err := url.Parse(o.Endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("AZURE_STORAGE_ENDPOINT %q must be an absolute http or https URL", o.Endpoint)
+		}
+	}
+	return nil
+}
+
+// serviceURL is the blob service's base URL without a trailing slash.
+func (o Options) serviceURL() string {
+	if o.Endpoint != "" {
+		return strings.TrimSuffix(o.Endpoint, "/")
+	}
+	return "https://" + o.Account + ".blob.core.windows.net"
+}
@@ -0,0 +1,148 @@
+// Package cache keeps recently read documents and canvases in memory in
+// front of a slower store, within a fixed byte budget.
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// entryOverhead approximates the memory an entry uses besides its key and
+// data: the list element, map slot and struct.
+const entryOverhead = 128
+
+// Cache is a least-recently-used cache bounded by the total size of its
+// entries. It is safe for concurrent use.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	entries  map[string]*list.Element
+	// order has the most recently used entry at the front
+	order *list.List
+
+	hits, misses, evictions int64
+}
+
+type entry struct {
+	key   string
+	value any
+	size  int64
+}
+
+// Stats reports how well the cache is doing.
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	// HitRate is hits over lookups, 0 before the first lookup.
+	HitRate  float64 `json:"hit_rate"`
+	Entries  int     `json:"entries"`
+	Bytes    int64   `json:"bytes"`
+	MaxBytes int64   `json:"max_bytes"`
+}
+
+func New(maxBytes int64) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// MaxBytesFromEnv reads STORE_CACHE_BYTES, the cache's memory budget. It
+// returns 0, which disables the cache, when unset.
+func MaxBytesFromEnv() (int64, error) {
+	value := os.Getenv("STORE_CACHE_BYTES")
+	if value == "" {
+		return 0, nil
+	}
+	maxBytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || maxBytes < 0 {
+		return 0, fmt.Errorf("invalid STORE_CACHE_BYTES %q", value)
+	}
+	return maxBytes, nil
+}
+
+// get calls read with the value cached under key, under the lock so values
+// that are updated in place can be copied safely, and marks it recently used.
+// It reports whether the key was cached.
+func (c *Cache) get(key string, read func(value any)) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	read(element.Value.(*entry).value)
+	return true
+}
+
+// add caches value under key, evicting the least recently used entries to
+// stay within budget. Values larger than an eighth of the budget aren't
+// cached, so one huge scene can't flush everything else.
+func (c *Cache) add(key string, value any, dataSize int) {
+	size := int64(dataSize+len(key)) + entryOverhead
+	if size > c.maxBytes/8 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value, size: size})
+	c.bytes += size
+	for c.bytes > c.maxBytes {
+		c.removeElement(c.order.Back())
+		c.evictions++
+	}
+}
+
+// update calls fn with the value cached under key, if any, under the lock.
+func (c *Cache) update(key string, fn func(value any)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		fn(element.Value.(*entry).value)
+	}
+}
+
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *Cache) removeElement(element *list.Element) {
+	e := c.order.Remove(element).(*entry)
+	delete(c.entries, e.key)
+	c.bytes -= e.size
+}
+
+// Stats returns the cache's counters.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
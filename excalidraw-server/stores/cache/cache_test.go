@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func ignore(any) {}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	value := strings.Repeat("x", 100)
+	// Room for eight entries with two character keys
+	c := New(8 * (2 + 100 + entryOverhead))
+	for i := 0; i < 8; i++ {
+		c.add(fmt.Sprintf("k%d", i), value, len(value))
+	}
+	c.get("k0", ignore)
+	c.add("k8", value, len(value))
+
+	if c.get("k1", ignore) {
+		t.Error("least recently used entry wasn't evicted")
+	}
+	for _, key := range []string{"k0", "k2", "k8"} {
+		if !c.get(key, ignore) {
+			t.Errorf("entry %q was evicted", key)
+		}
+	}
+	stats := c.Stats()
+	if stats.Entries != 8 || stats.Evictions != 1 || stats.Bytes != stats.MaxBytes {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCacheSkipsLargeValues(t *testing.T) {
+	c := New(1024)
+	c.add("big", nil, 512)
+	if c.get("big", ignore) {
+		t.Error("value over an eighth of the budget was cached")
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	c := New(1 << 20)
+	if stats := c.Stats(); stats.HitRate != 0 {
+		t.Errorf("HitRate before any lookup = %v", stats.HitRate)
+	}
+	c.add("a", []byte("scene"), 5)
+	c.get("a", ignore)
+	c.get("a", ignore)
+	c.get("a", ignore)
+	c.get("b", ignore)
+	c.remove("a")
+
+	stats := c.Stats()
+	if stats.Hits != 3 || stats.Misses != 1 || stats.HitRate != 0.75 || stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
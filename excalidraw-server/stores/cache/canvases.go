@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"excalidraw-server/core"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// canvases caches GetCanvas, dropping entries when a canvas is saved or
+// deleted through it.
+type canvases struct {
+	core.CanvasStore
+	cache *Cache
+}
+
+// Canvases wraps store so GetCanvas is served from c when possible. Writes
+// from other server instances sharing the store aren't seen until the
+// entry is evicted.
+func Canvases(store core.CanvasStore, c *Cache) core.CanvasStore {
+	return &canvases{CanvasStore: store, cache: c}
+}
+
+func canvasKey(ownerID, key string) string {
+	return "canvas:" + ownerID + "\x00" + key
+}
+
+func (c *canvases) GetCanvas(ctx context.Context, ownerID, key string) (*core.Canvas, error) {
+	var cached core.Canvas
+	if c.cache.get(canvasKey(ownerID, key), func(value any) { cached = *value.(*core.Canvas) }) {
+		return &cached, nil
+	}
+	canvas, err := c.CanvasStore.GetCanvas(ctx, ownerID, key)
+	if err != nil {
+		return nil, err
+	}
+	stored := *canvas
+	c.cache.add(canvasKey(ownerID, key), &stored, len(canvas.Data))
+	return canvas, nil
+}
+
+func (c *canvases) PutCanvas(ctx context.Context, canvas *core.Canvas) error {
+	defer c.cache.remove(canvasKey(canvas.OwnerID, canvas.Key))
+	return c.CanvasStore.PutCanvas(ctx, canvas)
+}
+
+func (c *canvases) DeleteCanvas(ctx context.Context, ownerID, key string) error {
+	defer c.cache.remove(canvasKey(ownerID, key))
+	return c.CanvasStore.DeleteCanvas(ctx, ownerID, key)
+}
+
+func (c *canvases) UpdateCanvasMetadata(ctx context.Context, ownerID, key string, update core.CanvasMetadataUpdate) (*core.Canvas, error) {
+	defer c.cache.remove(canvasKey(ownerID, key))
+	return c.CanvasStore.UpdateCanvasMetadata(ctx, ownerID, key, update)
+}
+
+// RecordCanvasView counts the view in the store and in the cached copy, so
+// cached canvases report the same stats the store does.
+func (c *canvases) RecordCanvasView(ctx context.Context, ownerID, key string) error {
+	if err := c.CanvasStore.RecordCanvasView(ctx, ownerID, key); err != nil {
+		return err
+	}
+	c.cache.update(canvasKey(ownerID, key), func(value any) {
+		canvas := value.(*core.Canvas)
+		canvas.Views++
+		canvas.LastAccessed = int64(ulid.Now())
+	})
+	return nil
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"testing"
+)
+
+func TestCanvases(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewDocumentStore().(core.CanvasStore)
+	c := New(1 << 20)
+	cached := Canvases(store, c)
+
+	if err := cached.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "plan", Data: []byte("v1")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.GetCanvas(ctx, "alice", "plan"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cached.RecordCanvasView(ctx, "alice", "plan"); err != nil {
+		t.Fatal(err)
+	}
+	canvas, err := cached.GetCanvas(ctx, "alice", "plan")
+	if err != nil || string(canvas.Data) != "v1" || canvas.Views != 1 {
+		t.Fatalf("cached GetCanvas() = %+v, %v", canvas, err)
+	}
+	if stats := c.Stats(); stats.Hits != 1 {
+		t.Errorf("Stats() = %+v, want a hit", stats)
+	}
+
+	// Saving drops the cached copy
+	if err := cached.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "plan", Data: []byte("v2")}); err != nil {
+		t.Fatal(err)
+	}
+	if canvas, err := cached.GetCanvas(ctx, "alice", "plan"); err != nil || string(canvas.Data) != "v2" {
+		t.Errorf("GetCanvas() after put = %+v, %v", canvas, err)
+	}
+
+	// So does renaming it
+	name := "Plan"
+	if _, err := cached.UpdateCanvasMetadata(ctx, "alice", "plan", core.CanvasMetadataUpdate{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+	if canvas, err := cached.GetCanvas(ctx, "alice", "plan"); err != nil || canvas.Name != name {
+		t.Errorf("GetCanvas() after rename = %+v, %v", canvas, err)
+	}
+
+	if err := cached.DeleteCanvas(ctx, "alice", "plan"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.GetCanvas(ctx, "alice", "plan"); err == nil {
+		t.Error("GetCanvas() after delete succeeded")
+	}
+}
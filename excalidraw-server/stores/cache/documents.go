@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+)
+
+// documents caches FindID. Shared documents never change, so entries only
+// leave the cache when evicted.
+type documents struct {
+	core.DocumentStore
+	cache *Cache
+}
+
+// documentsWithStats keeps the store's view counting visible to handlers
+// that look for it.
+type documentsWithStats struct {
+	*documents
+	core.DocumentStatsStore
+}
+
+// Documents wraps store so FindID is served from c when possible. The
+// result also implements core.DocumentStatsStore when store does.
+func Documents(store core.DocumentStore, c *Cache) core.DocumentStore {
+	d := &documents{DocumentStore: store, cache: c}
+	if stats, ok := store.(core.DocumentStatsStore); ok {
+		return &documentsWithStats{documents: d, DocumentStatsStore: stats}
+	}
+	return d
+}
+
+func documentKey(id string) string {
+	return "document:" + id
+}
+
+func (d *documents) FindID(ctx context.Context, id string) (*core.Document, error) {
+	var data []byte
+	if d.cache.get(documentKey(id), func(value any) { data = value.([]byte) }) {
+		// Callers own the returned buffer, so give them a copy
+		return &core.Document{Data: *bytes.NewBuffer(bytes.Clone(data))}, nil
+	}
+	document, err := d.DocumentStore.FindID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.Clone(document.Data.Bytes())
+	d.cache.add(documentKey(id), data, len(data))
+	return document, nil
+}
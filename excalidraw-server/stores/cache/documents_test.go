@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+	"excalidraw-server/stores/memory"
+	"testing"
+)
+
+func TestDocumentsFindID(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewDocumentStore()
+	id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("scene")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(1 << 20)
+	cached := Documents(store, c)
+
+	for i := 0; i < 2; i++ {
+		document, err := cached.FindID(ctx, id)
+		if err != nil || document.Data.String() != "scene" {
+			t.Fatalf("FindID() = %v, %v", document, err)
+		}
+		// Changing the returned buffer mustn't change the cached copy
+		document.Data.Reset()
+		document.Data.WriteString("edited")
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	if _, err := cached.FindID(ctx, "missing"); err == nil {
+		t.Error("FindID() of a missing document succeeded")
+	}
+	if _, ok := cached.(core.DocumentStatsStore); !ok {
+		t.Error("Documents() hid the store's view stats")
+	}
+}
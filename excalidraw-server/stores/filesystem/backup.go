@@ -0,0 +1,63 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+func (s *documentStore) BackupFormat() string {
+	return "tar"
+}
+
+// WriteBackup writes every regular file under the base directory to w as a
+// tar archive. Documents are written once and never modified, so the archive
+// is consistent apart from files created while it is written.
+func (s *documentStore) WriteBackup(ctx context.Context, w io.Writer) error {
+	archive := tar.NewWriter(w)
+	err := filepath.WalkDir(s.basePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(s.basePath, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = io.CopyN(archive, file, header.Size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
@@ -0,0 +1,52 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+	"io"
+	"testing"
+)
+
+func TestWriteBackup(t *testing.T) {
+	store := NewDocumentStore(t.TempDir()).(*documentStore)
+	ctx := context.Background()
+
+	id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("scene")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutFile(ctx, &core.File{ID: "image", Data: []byte("png")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var backup bytes.Buffer
+	if err := store.WriteBackup(ctx, &backup); err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(map[string]string)
+	archive := tar.NewReader(&backup)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found[header.Name] = string(data)
+	}
+
+	if found[id] != "scene" {
+		t.Errorf("expected document %s in backup, got %v", id, found)
+	}
+	if len(found) != 2 {
+		t.Errorf("expected the document and the file, got %v", found)
+	}
+}
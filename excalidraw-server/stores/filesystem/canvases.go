@@ -0,0 +1,276 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// canvasesDir holds a directory per owner with one JSON file per canvas.
+const canvasesDir = "canvases"
+
+// canvasesMutex serializes read-modify-write cycles on canvas files.
+var canvasesMutex sync.Mutex
+
+// canvasFile is the on-disk form of a canvas; core.Canvas hides the owner
+// and data from JSON.
+type canvasFile struct {
+	Key       string `json:"key"`
+	OwnerID   string `json:"owner_id"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+	Data      []byte `json:"data"`
+	core.CanvasMetadata
+}
+
+// ownerDir hex-encodes the owner id so any subject is a safe directory name.
+func (s *documentStore) ownerDir(ownerID string) string {
+	return filepath.Join(s.basePath, canvasesDir, hex.EncodeToString([]byte(ownerID)))
+}
+
+func (s *documentStore) canvasPath(ownerID, key string) string {
+	return filepath.Join(s.ownerDir(ownerID), filepath.Base(key)+".json")
+}
+
+// canvasStatsPath is the stats file next to the canvas at path.
+func canvasStatsPath(path string) string {
+	return strings.TrimSuffix(path, ".json") + ".stats"
+}
+
+func (s *documentStore) readCanvas(path string) (*core.Canvas, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file canvasFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	stats, err := readStats(canvasStatsPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return &core.Canvas{
+		Key:            file.Key,
+		OwnerID:        file.OwnerID,
+		Size:           len(file.Data),
+		CreatedAt:      file.CreatedAt,
+		UpdatedAt:      file.UpdatedAt,
+		Data:           file.Data,
+		CanvasMetadata: file.CanvasMetadata,
+		AccessStats:    stats,
+	}, nil
+}
+
+// writeCanvas saves file at path, creating the owner's directory.
+func writeCanvas(path string, file canvasFile) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *documentStore) PutCanvas(ctx context.Context, canvas *core.Canvas) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	canvasesMutex.Lock()
+	defer canvasesMutex.Unlock()
+
+	path := s.canvasPath(canvas.OwnerID, canvas.Key)
+	now := int64(ulid.Now())
+	file := canvasFile{
+		Key:       canvas.Key,
+		OwnerID:   canvas.OwnerID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Data:      canvas.Data,
+	}
+	if existing, err := s.readCanvas(path); err == nil {
+		file.CreatedAt = existing.CreatedAt
+		file.CanvasMetadata = existing.CanvasMetadata
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"canvas_key": canvas.Key,
+		"owner_id":   canvas.OwnerID,
+	})
+	if err := writeCanvas(path, file); err != nil {
+		log.WithField("error", err).Error("Failed to save canvas")
+		return err
+	}
+
+	log.Info("Canvas saved successfully")
+	return nil
+}
+
+func (s *documentStore) GetCanvas(ctx context.Context, ownerID, key string) (*core.Canvas, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	canvas, err := s.readCanvas(s.canvasPath(ownerID, key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+		}
+		return nil, err
+	}
+	return canvas, nil
+}
+
+func (s *documentStore) DeleteCanvas(ctx context.Context, ownerID, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.canvasPath(ownerID, key)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+		}
+		return err
+	}
+	if err := os.Remove(canvasStatsPath(s.canvasPath(ownerID, key))); err != nil && !os.IsNotExist(err) {
+		logrus.WithField("canvas_key", key).WithField("error", err).Warn("Failed to delete canvas stats")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"canvas_key": key,
+		"owner_id":   ownerID,
+	}).Info("Canvas deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ListCanvases(ctx context.Context, ownerID string) ([]core.Canvas, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	dir := s.ownerDir(ownerID)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var canvases []core.Canvas
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		canvas, err := s.readCanvas(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			logrus.WithField("file", entry.Name()).WithField("error", err).Warn("Failed to read canvas")
+			continue
+		}
+		canvas.Data = nil
+		canvases = append(canvases, *canvas)
+	}
+
+	sort.Slice(canvases, func(i, j int) bool {
+		if canvases[i].UpdatedAt != canvases[j].UpdatedAt {
+			return canvases[i].UpdatedAt > canvases[j].UpdatedAt
+		}
+		return canvases[i].Key < canvases[j].Key
+	})
+	return canvases, nil
+}
+
+func (s *documentStore) ListCanvasPage(ctx context.Context, ownerID string, opts core.CanvasListOptions) (core.CanvasPage, error) {
+	canvases, err := s.ListCanvases(ctx, ownerID)
+	if err != nil {
+		return core.CanvasPage{}, err
+	}
+	return core.PageCanvases(canvases, opts)
+}
+
+func (s *documentStore) UpdateCanvasMetadata(ctx context.Context, ownerID, key string, update core.CanvasMetadataUpdate) (*core.Canvas, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	canvasesMutex.Lock()
+	defer canvasesMutex.Unlock()
+
+	path := s.canvasPath(ownerID, key)
+	canvas, err := s.readCanvas(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+		}
+		return nil, err
+	}
+	canvas.CanvasMetadata.Apply(update)
+	err = writeCanvas(path, canvasFile{
+		Key:            canvas.Key,
+		OwnerID:        canvas.OwnerID,
+		CreatedAt:      canvas.CreatedAt,
+		UpdatedAt:      canvas.UpdatedAt,
+		Data:           canvas.Data,
+		CanvasMetadata: canvas.CanvasMetadata,
+	})
+	if err != nil {
+		logrus.WithField("canvas_key", key).WithField("error", err).Error("Failed to update canvas metadata")
+		return nil, err
+	}
+
+	canvas.Data = nil
+	return canvas, nil
+}
+
+func (s *documentStore) RecordCanvasView(ctx context.Context, ownerID, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path := s.canvasPath(ownerID, key)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+		}
+		return err
+	}
+	return recordView(canvasStatsPath(path))
+}
+
+// scanCanvases calls fn with the data of every user's canvases.
+func (s *documentStore) scanCanvases(ctx context.Context, fn func(data []byte) error) error {
+	paths, err := filepath.Glob(filepath.Join(s.basePath, canvasesDir, "*", "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		canvas, err := s.readCanvas(path)
+		if err != nil {
+			logrus.WithField("file", path).WithField("error", err).Warn("Failed to read canvas while scanning")
+			continue
+		}
+		if err := fn(canvas.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
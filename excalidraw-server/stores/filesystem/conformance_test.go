@@ -0,0 +1,19 @@
+package filesystem
+
+import (
+	"excalidraw-server/core"
+	"excalidraw-server/stores/storetest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) core.DocumentStore {
+		return NewDocumentStore(t.TempDir())
+	})
+}
+
+func BenchmarkStore(b *testing.B) {
+	storetest.Benchmark(b, func(b *testing.B) core.DocumentStore {
+		return NewDocumentStore(b.TempDir())
+	})
+}
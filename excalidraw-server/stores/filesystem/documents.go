@@ -26,6 +26,10 @@ func NewDocumentStore(basePath string) core.DocumentStore {
 }
 
 func (s *documentStore) FindID(ctx context.Context, id string) (*core.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	filePath := filepath.Join(s.basePath, id)
 	log := logrus.WithField("document_id", id)
 
@@ -49,6 +53,10 @@ func (s *documentStore) FindID(ctx context.Context, id string) (*core.Document,
 }
 
 func (s *documentStore) Create(ctx context.Context, document *core.Document) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	id := ulid.Make().String()
 	filePath := filepath.Join(s.basePath, id)
 	log := logrus.WithFields(logrus.Fields{
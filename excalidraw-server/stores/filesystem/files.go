@@ -0,0 +1,158 @@
+package filesystem
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// filesDir holds uploaded assets next to the documents. Document ids are
+// ULIDs, so the name can't collide with one.
+const filesDir = "files"
+
+func (s *documentStore) filePath(id string) string {
+	return filepath.Join(s.basePath, filesDir, filepath.Base(id))
+}
+
+func (s *documentStore) PutFile(ctx context.Context, file *core.File) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	filePath := s.filePath(file.ID)
+	log := logrus.WithFields(logrus.Fields{
+		"file_id":   file.ID,
+		"file_path": filePath,
+	})
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		log.WithField("error", err).Error("Failed to create files directory")
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial asset.
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		log.WithField("error", err).Error("Failed to store file")
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(file.Data); err != nil {
+		_ = tmp.Close()
+		log.WithField("error", err).Error("Failed to store file")
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		log.WithField("error", err).Error("Failed to store file")
+		return err
+	}
+
+	log.Info("File stored successfully")
+	return nil
+}
+
+func (s *documentStore) GetFile(ctx context.Context, id string) (*core.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	filePath := s.filePath(id)
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file with id %s: %w", id, core.ErrFileNotFound)
+		}
+		return nil, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &core.File{ID: id, Data: data, CreatedAt: info.ModTime()}, nil
+}
+
+func (s *documentStore) ListFiles(ctx context.Context) ([]core.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.basePath, filesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	files := make([]core.File, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, core.File{ID: entry.Name(), CreatedAt: info.ModTime()})
+	}
+	return files, nil
+}
+
+func (s *documentStore) DeleteFile(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.filePath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file with id %s: %w", id, core.ErrFileNotFound)
+		}
+		logrus.WithField("file_id", id).WithField("error", err).Error("Failed to delete file")
+		return err
+	}
+	logrus.WithField("file_id", id).Info("File deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ScanScenes(ctx context.Context, fn func(data []byte) error) error {
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.basePath, entry.Name()))
+		if err != nil {
+			logrus.WithField("document_id", entry.Name()).WithField("error", err).Warn("Failed to read document while scanning")
+			continue
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	if err := s.scanCanvases(ctx, fn); err != nil {
+		return err
+	}
+	return s.scanTemplates(ctx, fn)
+}
@@ -0,0 +1,173 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// librariesDir holds one JSON file per library next to the documents.
+const librariesDir = "libraries"
+
+// librariesMutex serializes read-modify-write cycles on library files.
+var librariesMutex sync.Mutex
+
+func (s *documentStore) libraryPath(id string) string {
+	return filepath.Join(s.basePath, librariesDir, filepath.Base(id)+".json")
+}
+
+func (s *documentStore) writeLibrary(library *core.Library) error {
+	path := s.libraryPath(library.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(library)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *documentStore) readLibrary(id string) (*core.Library, error) {
+	data, err := os.ReadFile(s.libraryPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("library with id %s: %w", id, core.ErrLibraryNotFound)
+		}
+		return nil, err
+	}
+	var library core.Library
+	if err := json.Unmarshal(data, &library); err != nil {
+		return nil, err
+	}
+	return &library, nil
+}
+
+func (s *documentStore) CreateLibrary(ctx context.Context, library *core.Library) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	stored := *library
+	stored.ID = ulid.Make().String()
+	stored.CreatedAt = int64(ulid.Now())
+	stored.UpdatedAt = stored.CreatedAt
+
+	log := logrus.WithFields(logrus.Fields{
+		"library_id": stored.ID,
+		"owner_id":   stored.OwnerID,
+	})
+	if err := s.writeLibrary(&stored); err != nil {
+		log.WithField("error", err).Error("Failed to create library")
+		return "", err
+	}
+
+	log.Info("Library created successfully")
+	return stored.ID, nil
+}
+
+func (s *documentStore) GetLibrary(ctx context.Context, id string) (*core.Library, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.readLibrary(id)
+}
+
+func (s *documentStore) UpdateLibrary(ctx context.Context, library *core.Library) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	librariesMutex.Lock()
+	defer librariesMutex.Unlock()
+
+	stored, err := s.readLibrary(library.ID)
+	if err != nil {
+		return err
+	}
+	stored.Name = library.Name
+	stored.Description = library.Description
+	stored.Public = library.Public
+	stored.Data = library.Data
+	stored.UpdatedAt = int64(ulid.Now())
+
+	if err := s.writeLibrary(stored); err != nil {
+		logrus.WithField("library_id", library.ID).WithField("error", err).Error("Failed to update library")
+		return err
+	}
+
+	logrus.WithField("library_id", library.ID).Info("Library updated successfully")
+	return nil
+}
+
+func (s *documentStore) DeleteLibrary(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.libraryPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("library with id %s: %w", id, core.ErrLibraryNotFound)
+		}
+		return err
+	}
+
+	logrus.WithField("library_id", id).Info("Library deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ListLibraries(ctx context.Context, filter core.LibraryFilter) ([]core.Library, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.basePath, librariesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var libraries []core.Library
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		library, err := s.readLibrary(id)
+		if err != nil {
+			logrus.WithField("library_id", id).WithField("error", err).Warn("Failed to read library")
+			continue
+		}
+		if filter.OwnerID != "" && library.OwnerID != filter.OwnerID {
+			continue
+		}
+		if filter.PublicOnly && !library.Public {
+			continue
+		}
+		library.Data = nil
+		libraries = append(libraries, *library)
+	}
+
+	sort.Slice(libraries, func(i, j int) bool {
+		if libraries[i].UpdatedAt != libraries[j].UpdatedAt {
+			return libraries[i].UpdatedAt > libraries[j].UpdatedAt
+		}
+		return libraries[i].ID > libraries[j].ID
+	})
+	return libraries, nil
+}
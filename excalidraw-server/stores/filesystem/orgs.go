@@ -0,0 +1,277 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// orgsDir holds one JSON file per organization, members included.
+const orgsDir = "orgs"
+
+// orgsMutex serializes read-modify-write cycles on organization files.
+var orgsMutex sync.Mutex
+
+// orgFile is the on-disk form of an organization.
+type orgFile struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	CreatedAt int64            `json:"created_at"`
+	Members   []core.OrgMember `json:"members"`
+}
+
+func (s *documentStore) orgPath(id string) string {
+	return filepath.Join(s.basePath, orgsDir, filepath.Base(id)+".json")
+}
+
+func (s *documentStore) readOrg(id string) (*orgFile, error) {
+	data, err := os.ReadFile(s.orgPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("organization with id %s: %w", id, core.ErrOrgNotFound)
+		}
+		return nil, err
+	}
+	var file orgFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (s *documentStore) writeOrg(file *orgFile) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	path := s.orgPath(file.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (file *orgFile) member(userID string) int {
+	for i, member := range file.Members {
+		if member.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *documentStore) CreateOrg(ctx context.Context, name, ownerID string) (*core.Org, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := int64(ulid.Now())
+	file := orgFile{
+		ID:        ulid.Make().String(),
+		Name:      name,
+		CreatedAt: now,
+		Members:   []core.OrgMember{{UserID: ownerID, Role: core.OrgRoleAdmin, JoinedAt: now}},
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"org_id":   file.ID,
+		"owner_id": ownerID,
+	})
+
+	orgsMutex.Lock()
+	defer orgsMutex.Unlock()
+
+	if err := s.writeOrg(&file); err != nil {
+		log.WithField("error", err).Error("Failed to create organization")
+		return nil, err
+	}
+
+	log.Info("Organization created successfully")
+	return &core.Org{ID: file.ID, Name: file.Name, CreatedAt: file.CreatedAt}, nil
+}
+
+func (s *documentStore) GetOrg(ctx context.Context, id string) (*core.Org, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := s.readOrg(id)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Org{ID: file.ID, Name: file.Name, CreatedAt: file.CreatedAt}, nil
+}
+
+func (s *documentStore) DeleteOrg(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	orgsMutex.Lock()
+	defer orgsMutex.Unlock()
+
+	if err := os.Remove(s.orgPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("organization with id %s: %w", id, core.ErrOrgNotFound)
+		}
+		return err
+	}
+
+	logrus.WithField("org_id", id).Info("Organization deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ListOrgs(ctx context.Context, userID string) ([]core.Org, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.basePath, orgsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var orgs []core.Org
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logrus.WithField("file", path).WithField("error", err).Warn("Failed to read organization")
+			continue
+		}
+		var file orgFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			logrus.WithField("file", path).WithField("error", err).Warn("Failed to read organization")
+			continue
+		}
+		if i := file.member(userID); i >= 0 {
+			orgs = append(orgs, core.Org{
+				ID:        file.ID,
+				Name:      file.Name,
+				CreatedAt: file.CreatedAt,
+				Role:      file.Members[i].Role,
+			})
+		}
+	}
+
+	sort.Slice(orgs, func(i, j int) bool {
+		if orgs[i].Name != orgs[j].Name {
+			return orgs[i].Name < orgs[j].Name
+		}
+		return orgs[i].ID < orgs[j].ID
+	})
+	return orgs, nil
+}
+
+func (s *documentStore) ListOrgMembers(ctx context.Context, orgID string) ([]core.OrgMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := s.readOrg(orgID)
+	if err != nil {
+		return nil, err
+	}
+	members := append([]core.OrgMember{}, file.Members...)
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].JoinedAt != members[j].JoinedAt {
+			return members[i].JoinedAt < members[j].JoinedAt
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (s *documentStore) GetOrgMember(ctx context.Context, orgID, userID string) (*core.OrgMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := s.readOrg(orgID)
+	if err != nil && !errors.Is(err, core.ErrOrgNotFound) {
+		return nil, err
+	}
+	i := -1
+	if file != nil {
+		i = file.member(userID)
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("user %s in organization %s: %w", userID, orgID, core.ErrNotMember)
+	}
+	member := file.Members[i]
+	return &member, nil
+}
+
+func (s *documentStore) PutOrgMember(ctx context.Context, orgID string, member core.OrgMember) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	orgsMutex.Lock()
+	defer orgsMutex.Unlock()
+
+	file, err := s.readOrg(orgID)
+	if err != nil {
+		return err
+	}
+	if i := file.member(member.UserID); i >= 0 {
+		file.Members[i].Role = member.Role
+	} else {
+		member.JoinedAt = int64(ulid.Now())
+		file.Members = append(file.Members, member)
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"org_id":  orgID,
+		"user_id": member.UserID,
+		"role":    member.Role,
+	})
+	if err := s.writeOrg(file); err != nil {
+		log.WithField("error", err).Error("Failed to save organization member")
+		return err
+	}
+
+	log.Info("Organization member saved successfully")
+	return nil
+}
+
+func (s *documentStore) RemoveOrgMember(ctx context.Context, orgID, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	orgsMutex.Lock()
+	defer orgsMutex.Unlock()
+
+	file, err := s.readOrg(orgID)
+	if err != nil && !errors.Is(err, core.ErrOrgNotFound) {
+		return err
+	}
+	i := -1
+	if file != nil {
+		i = file.member(userID)
+	}
+	if i < 0 {
+		return fmt.Errorf("user %s in organization %s: %w", userID, orgID, core.ErrNotMember)
+	}
+	file.Members = append(file.Members[:i], file.Members[i+1:]...)
+	if err := s.writeOrg(file); err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"org_id":  orgID,
+		"user_id": userID,
+	}).Info("Organization member removed successfully")
+	return nil
+}
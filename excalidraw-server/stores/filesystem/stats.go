@@ -0,0 +1,111 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// statsDir holds the view counts of shared documents. Canvases keep theirs
+// in a .stats file next to the canvas, so a view doesn't rewrite the canvas.
+const statsDir = "stats"
+
+// statsMutex serializes read-modify-write cycles on stats files.
+var statsMutex sync.Mutex
+
+// readStats returns the stats at path; a missing file means no views yet.
+func readStats(path string) (core.AccessStats, error) {
+	var stats core.AccessStats
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stats, nil
+		}
+		return stats, err
+	}
+	err = json.Unmarshal(data, &stats)
+	return stats, err
+}
+
+// recordView counts a view in the stats file at path.
+func recordView(path string) error {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	stats, err := readStats(path)
+	if err != nil {
+		return err
+	}
+	stats.Views++
+	stats.LastAccessed = int64(ulid.Now())
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *documentStore) documentStatsPath(id string) string {
+	return filepath.Join(s.basePath, statsDir, filepath.Base(id)+".json")
+}
+
+// documentExists reports whether id names a stored document.
+func (s *documentStore) documentExists(id string) (bool, error) {
+	info, err := os.Stat(filepath.Join(s.basePath, filepath.Base(id)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.Mode().IsRegular(), nil
+}
+
+func (s *documentStore) RecordDocumentView(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	exists, err := s.documentExists(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("document with id %s: %w", id, core.ErrDocumentNotFound)
+	}
+	if err := recordView(s.documentStatsPath(id)); err != nil {
+		logrus.WithField("document_id", id).WithField("error", err).Error("Failed to record document view")
+		return err
+	}
+	return nil
+}
+
+func (s *documentStore) GetDocumentStats(ctx context.Context, id string) (*core.AccessStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.documentExists(id)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("document with id %s: %w", id, core.ErrDocumentNotFound)
+	}
+	stats, err := readStats(s.documentStatsPath(id))
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
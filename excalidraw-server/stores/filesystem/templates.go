@@ -0,0 +1,191 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// templatesDir holds one JSON file per template next to the documents.
+const templatesDir = "templates"
+
+// templatesMutex serializes read-modify-write cycles on template files.
+var templatesMutex sync.Mutex
+
+func (s *documentStore) templatePath(id string) string {
+	return filepath.Join(s.basePath, templatesDir, filepath.Base(id)+".json")
+}
+
+func (s *documentStore) writeTemplate(template *core.Template) error {
+	path := s.templatePath(template.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *documentStore) readTemplate(id string) (*core.Template, error) {
+	data, err := os.ReadFile(s.templatePath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("template with id %s: %w", id, core.ErrTemplateNotFound)
+		}
+		return nil, err
+	}
+	var template core.Template
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (s *documentStore) CreateTemplate(ctx context.Context, template *core.Template) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	stored := *template
+	stored.ID = ulid.Make().String()
+	stored.CreatedAt = int64(ulid.Now())
+	stored.UpdatedAt = stored.CreatedAt
+
+	log := logrus.WithField("template_id", stored.ID)
+	if err := s.writeTemplate(&stored); err != nil {
+		log.WithField("error", err).Error("Failed to create template")
+		return "", err
+	}
+
+	log.Info("Template created successfully")
+	return stored.ID, nil
+}
+
+func (s *documentStore) GetTemplate(ctx context.Context, id string) (*core.Template, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.readTemplate(id)
+}
+
+func (s *documentStore) UpdateTemplate(ctx context.Context, template *core.Template) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	templatesMutex.Lock()
+	defer templatesMutex.Unlock()
+
+	stored, err := s.readTemplate(template.ID)
+	if err != nil {
+		return err
+	}
+	stored.Name = template.Name
+	stored.Description = template.Description
+	stored.Category = template.Category
+	stored.Data = template.Data
+	stored.UpdatedAt = int64(ulid.Now())
+
+	if err := s.writeTemplate(stored); err != nil {
+		logrus.WithField("template_id", template.ID).WithField("error", err).Error("Failed to update template")
+		return err
+	}
+
+	logrus.WithField("template_id", template.ID).Info("Template updated successfully")
+	return nil
+}
+
+func (s *documentStore) DeleteTemplate(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.templatePath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("template with id %s: %w", id, core.ErrTemplateNotFound)
+		}
+		return err
+	}
+
+	logrus.WithField("template_id", id).Info("Template deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ListTemplates(ctx context.Context, category string) ([]core.Template, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.basePath, templatesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var templates []core.Template
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		template, err := s.readTemplate(id)
+		if err != nil {
+			logrus.WithField("template_id", id).WithField("error", err).Warn("Failed to read template")
+			continue
+		}
+		if category != "" && template.Category != category {
+			continue
+		}
+		template.Data = nil
+		templates = append(templates, *template)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Category != templates[j].Category {
+			return templates[i].Category < templates[j].Category
+		}
+		if templates[i].Name != templates[j].Name {
+			return templates[i].Name < templates[j].Name
+		}
+		return templates[i].ID < templates[j].ID
+	})
+	return templates, nil
+}
+
+func (s *documentStore) scanTemplates(ctx context.Context, fn func(data []byte) error) error {
+	paths, err := filepath.Glob(filepath.Join(s.basePath, templatesDir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		template, err := s.readTemplate(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			logrus.WithField("file", path).WithField("error", err).Warn("Failed to read template while scanning")
+			continue
+		}
+		if err := fn(template.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
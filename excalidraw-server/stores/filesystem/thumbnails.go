@@ -0,0 +1,97 @@
+package filesystem
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// thumbnailsDir holds one PNG per scene hash.
+const thumbnailsDir = "thumbnails"
+
+func (s *documentStore) thumbnailPath(hash string) string {
+	return filepath.Join(s.basePath, thumbnailsDir, filepath.Base(hash)+".png")
+}
+
+func (s *documentStore) PutThumbnail(ctx context.Context, hash string, png []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path := s.thumbnailPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// The same scene may be rendered twice at once, so write to a temporary
+	// file first and never let a reader see half a thumbnail.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".thumbnail-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(png); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *documentStore) GetThumbnail(ctx context.Context, hash string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	png, err := os.ReadFile(s.thumbnailPath(hash))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("scene %s: %w", hash, core.ErrThumbnailNotFound)
+	}
+	return png, err
+}
+
+func (s *documentStore) DeleteThumbnail(ctx context.Context, hash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.thumbnailPath(hash)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *documentStore) ListThumbnails(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.basePath, thumbnailsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		hash, ok := strings.CutSuffix(entry.Name(), ".png")
+		if entry.IsDir() || !ok || strings.HasPrefix(hash, ".") {
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
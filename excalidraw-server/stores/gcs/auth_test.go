@@ -0,0 +1,122 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"excalidraw-server/core"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestServiceAccountCredentials(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token endpoint checks the assertion's signature and claims
+	var exchanges atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var claims map[string]any
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		_ = json.Unmarshal(payload, &claims)
+		if claims["iss"] != "server@project.iam.gserviceaccount.com" || claims["scope"] != scope {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		exchanges.Add(1)
+		fmt.Fprint(w, `{"access_token":"secret-token","expires_in":3600,"token_type":"Bearer"}`)
+	}))
+	t.Cleanup(tokenServer.Close)
+
+	credentials, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "server@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenServer.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(path, credentials, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeGCS{objects: map[string][]byte{}, token: "secret-token"}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	store, err := NewDocumentStore(Options{Bucket: "drawings", Endpoint: server.URL, CredentialsFile: path})
+	if err != nil {
+		t.Fatalf("NewDocumentStore() failed: %v", err)
+	}
+
+	ctx := context.Background()
+	id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("scene")})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if _, err := store.FindID(ctx, id); err != nil {
+		t.Fatalf("FindID() failed: %v", err)
+	}
+	// The token is cached between requests
+	if n := exchanges.Load(); n != 1 {
+		t.Errorf("token exchanged %d times, want 1", n)
+	}
+}
+
+func TestMetadataCredentials(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"instance-token","expires_in":3599}`)
+	}))
+	t.Cleanup(metadata.Close)
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(metadata.URL, "http://"))
+
+	token, err := metadataTokens(http.DefaultClient).Token(context.Background())
+	if err != nil || token != "instance-token" {
+		t.Errorf("Token() = %q, %v", token, err)
+	}
+}
+
+func TestInvalidCredentialsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(path, []byte(`{"type":"authorized_user"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDocumentStore(Options{Bucket: "drawings", CredentialsFile: path}); err == nil {
+		t.Error("NewDocumentStore() accepted credentials without a service account key")
+	}
+}
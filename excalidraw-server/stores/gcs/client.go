@@ -0,0 +1,171 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// errNotFound is returned for objects that don't exist.
+var errNotFound = errors.New("object not found")
+
+// client talks to the Cloud Storage JSON API. It implements just the calls
+// the store needs: upload, download, list and delete.
+type client struct {
+	endpoint string
+	bucket   string
+	// tokens authorizes requests; nil sends them unauthenticated.
+	tokens *tokenSource
+	http   *http.Client
+}
+
+// object is a listed object without its data.
+type object struct {
+	Name    string    `json:"name"`
+	Updated time.Time `json:"updated"`
+}
+
+func newClient(opts Options) (*client, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	c := &client{
+		endpoint: opts.endpoint(),
+		bucket:   opts.Bucket,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+	switch {
+	case opts.CredentialsFile != "":
+		tokens, err := serviceAccountTokens(opts.CredentialsFile, c.http)
+		if err != nil {
+			return nil, fmt.Errorf("GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+		}
+		c.tokens = tokens
+	case opts.Endpoint == "":
+		c.tokens = metadataTokens(c.http)
+	}
+	return c, nil
+}
+
+func (c *client) objectURL(name string) string {
+	return c.endpoint + "/storage/v1/b/" + url.PathEscape(c.bucket) + "/o/" + url.PathEscape(name)
+}
+
+// upload stores data as name, replacing any object with the same name.
+func (c *client) upload(ctx context.Context, name string, data []byte) error {
+	query := url.Values{"uploadType": {"media"}, "name": {name}}
+	u := c.endpoint + "/upload/storage/v1/b/" + url.PathEscape(c.bucket) + "/o?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	_, err = c.do(req, nil)
+	return err
+}
+
+// download returns an object's data and its last modification time.
+func (c *client) download(ctx context.Context, name string) ([]byte, time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.objectURL(name)+"?alt=media", nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var data bytes.Buffer
+	header, err := c.do(req, &data)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	modified, _ := http.ParseTime(header.Get("Last-Modified"))
+	return data.Bytes(), modified, nil
+}
+
+// list returns the objects whose names start with prefix.
+func (c *client) list(ctx context.Context, prefix string) ([]object, error) {
+	var objects []object
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name,updated),nextPageToken"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		u := c.endpoint + "/storage/v1/b/" + url.PathEscape(c.bucket) + "/o?" + query.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		var body bytes.Buffer
+		if _, err := c.do(req, &body); err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Items         []object `json:"items"`
+			NextPageToken string   `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(body.Bytes(), &result); err != nil {
+			return nil, err
+		}
+		objects = append(objects, result.Items...)
+		if result.NextPageToken == "" {
+			return objects, nil
+		}
+		pageToken = result.NextPageToken
+	}
+}
+
+// delete removes an object.
+func (c *client) delete(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.objectURL(name), nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(req, nil)
+	return err
+}
+
+// do authorizes and sends req, copying a successful response body into body.
+func (c *client) do(req *http.Request, body io.Writer) (http.Header, error) {
+	if c.tokens != nil {
+		token, err := c.tokens.Token(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		var gcsErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		message := resp.Status
+		if json.Unmarshal(data, &gcsErr) == nil && gcsErr.Error.Message != "" {
+			message = gcsErr.Error.Message
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("gcs %s: %s: %w", req.URL.Path, message, errNotFound)
+		}
+		return nil, fmt.Errorf("gcs %s %s: %s", req.Method, req.URL.Path, message)
+	}
+	if body != nil {
+		if _, err := io.Copy(body, resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,142 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"excalidraw-server/core"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGCS serves just enough of the Cloud Storage JSON API for the client,
+// for the bucket "drawings".
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	// token is the bearer token required on every request, if set.
+	token string
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"code":401,"message":"Invalid Credentials"}}`)
+		return
+	}
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	objectName, isObject := strings.CutPrefix(r.URL.Path, "/storage/v1/b/drawings/o/")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/drawings/o":
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Query().Get("name")] = data
+		fmt.Fprint(w, `{}`)
+	case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/drawings/o":
+		var names []string
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		var result struct {
+			Items []object `json:"items,omitempty"`
+		}
+		for _, name := range names {
+			result.Items = append(result.Items, object{Name: name, Updated: modified})
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	case isObject && (r.Method == http.MethodGet || r.Method == http.MethodDelete):
+		data, ok := f.objects[objectName]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":{"code":404,"message":"No such object: drawings/%s"}}`, objectName)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.objects, objectName)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.URL.Query().Get("alt") != "media" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		_, _ = w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"code":404,"message":"Not Found"}}`)
+	}
+}
+
+// newTestStore returns a store backed by a fake Cloud Storage endpoint.
+func newTestStore(t *testing.T) (*documentStore, *fakeGCS) {
+	t.Helper()
+	fake := &fakeGCS{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewDocumentStore(Options{Bucket: "drawings", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewDocumentStore() failed: %v", err)
+	}
+	return store.(*documentStore), fake
+}
+
+func TestObjectNamesAreEscaped(t *testing.T) {
+	store, fake := newTestStore(t)
+	if err := store.client.upload(context.Background(), "files/a b?c", []byte("data")); err != nil {
+		t.Fatalf("upload() failed: %v", err)
+	}
+	data, _, err := store.client.download(context.Background(), "files/a b?c")
+	if err != nil || string(data) != "data" {
+		t.Errorf("download() = %q, %v", data, err)
+	}
+	if _, ok := fake.objects["files/a b?c"]; !ok {
+		t.Errorf("objects = %v", fake.objects)
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("GCS_BUCKET", "drawings")
+	t.Setenv("GCS_ENDPOINT", "http://localhost:4443/")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	opts, err := OptionsFromEnv()
+	if err != nil {
+		t.Fatalf("OptionsFromEnv() failed: %v", err)
+	}
+	if opts.endpoint() != "http://localhost:4443" {
+		t.Errorf("endpoint() = %s", opts.endpoint())
+	}
+
+	t.Setenv("GCS_ENDPOINT", "localhost:4443")
+	if _, err := OptionsFromEnv(); err == nil {
+		t.Error("OptionsFromEnv() accepted an endpoint without a scheme")
+	}
+	t.Setenv("GCS_BUCKET", "")
+	if _, err := OptionsFromEnv(); err == nil {
+		t.Error("OptionsFromEnv() accepted a missing bucket")
+	}
+}
+
+func TestDocumentKeys(t *testing.T) {
+	store, fake := newTestStore(t)
+	id, err := store.Create(context.Background(), &core.Document{Data: *bytes.NewBufferString(`{"elements":[]}`)})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if _, ok := fake.objects[documentsPrefix+id]; !ok {
+		t.Errorf("document wasn't stored as %s%s", documentsPrefix, id)
+	}
+}
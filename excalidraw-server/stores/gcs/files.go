@@ -0,0 +1,80 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// filesPrefix holds uploaded assets, one object per file id.
+const filesPrefix = "files/"
+
+func (s *documentStore) PutFile(ctx context.Context, file *core.File) error {
+	log := logrus.WithField("file_id", file.ID)
+	if err := s.client.upload(ctx, filesPrefix+file.ID, file.Data); err != nil {
+		log.WithField("error", err).Error("Failed to store file")
+		return err
+	}
+	log.Info("File stored successfully")
+	return nil
+}
+
+func (s *documentStore) GetFile(ctx context.Context, id string) (*core.File, error) {
+	data, modified, err := s.client.download(ctx, filesPrefix+id)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("file with id %s: %w", id, core.ErrFileNotFound)
+		}
+		return nil, err
+	}
+	return &core.File{ID: id, Data: data, CreatedAt: modified}, nil
+}
+
+func (s *documentStore) ListFiles(ctx context.Context) ([]core.File, error) {
+	objects, err := s.client.list(ctx, filesPrefix)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]core.File, 0, len(objects))
+	for _, o := range objects {
+		files = append(files, core.File{ID: strings.TrimPrefix(o.Name, filesPrefix), CreatedAt: o.Updated})
+	}
+	return files, nil
+}
+
+func (s *documentStore) DeleteFile(ctx context.Context, id string) error {
+	if err := s.client.delete(ctx, filesPrefix+id); err != nil {
+		if errors.Is(err, errNotFound) {
+			return fmt.Errorf("file with id %s: %w", id, core.ErrFileNotFound)
+		}
+		logrus.WithField("file_id", id).WithField("error", err).Error("Failed to delete file")
+		return err
+	}
+	logrus.WithField("file_id", id).Info("File deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ScanScenes(ctx context.Context, fn func(data []byte) error) error {
+	objects, err := s.client.list(ctx, documentsPrefix)
+	if err != nil {
+		return err
+	}
+	for _, o := range objects {
+		data, _, err := s.client.download(ctx, o.Name)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			logrus.WithField("document_id", strings.TrimPrefix(o.Name, documentsPrefix)).WithField("error", err).Warn("Failed to read document while scanning")
+			continue
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package gcs
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// defaultEndpoint is the Cloud Storage JSON API's base URL.
+const defaultEndpoint = "https://storage.googleapis.com"
+
+// Options locates a bucket and the credentials to access it.
+type Options struct {
+	Bucket string
+	// Endpoint overrides the Cloud Storage endpoint, e.g.
+	// http://localhost:4443 for fake-gcs-server.
+	Endpoint string
+	// CredentialsFile is a service account key file. Without one, tokens
+	// come from the metadata server, or requests to a custom Endpoint are
+	// sent unauthenticated.
+	CredentialsFile string
+}
+
+// OptionsFromEnv reads GCS_BUCKET, GCS_ENDPOINT and
+// GOOGLE_APPLICATION_CREDENTIALS.
+func OptionsFromEnv() (Options, error) {
+	opts := Options{
+		Bucket:          os.Getenv("GCS_BUCKET"),
+		Endpoint:        os.Getenv("GCS_ENDPOINT"),
+		CredentialsFile: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+	}
+	return opts, opts.validate()
+}
+
+func (o Options) validate() error {
+	if o.Bucket == "" {
+		return fmt.Errorf("GCS_BUCKET is required")
+	}
+	if o.Endpoint != "" {
+		parsed, err := url.Parse(o.Endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("GCS_ENDPOINT %q must be an absolute http or https URL", o.Endpoint)
+		}
+	}
+	return nil
+}
+
+// endpoint is the API's base URL without a trailing slash.
+func (o Options) endpoint() string {
+	if o.Endpoint != "" {
+		return strings.TrimSuffix(o.Endpoint, "/")
+	}
+	return defaultEndpoint
+}
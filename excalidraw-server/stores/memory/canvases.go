@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"sort"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func (s *documentStore) PutCanvas(ctx context.Context, canvas *core.Canvas) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := int64(ulid.Now())
+	stored := *canvas
+	stored.Size = len(canvas.Data)
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+
+	s.mu.Lock()
+	owned, ok := s.canvases[canvas.OwnerID]
+	if !ok {
+		owned = make(map[string]core.Canvas)
+		s.canvases[canvas.OwnerID] = owned
+	}
+	stored.CanvasMetadata = core.CanvasMetadata{}
+	stored.AccessStats = core.AccessStats{}
+	if existing, ok := owned[canvas.Key]; ok {
+		stored.CreatedAt = existing.CreatedAt
+		stored.CanvasMetadata = existing.CanvasMetadata
+		stored.AccessStats = existing.AccessStats
+	}
+	owned[canvas.Key] = stored
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"canvas_key": canvas.Key,
+		"owner_id":   canvas.OwnerID,
+	}).Info("Canvas saved successfully")
+	return nil
+}
+
+func (s *documentStore) GetCanvas(ctx context.Context, ownerID, key string) (*core.Canvas, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	canvas, ok := s.canvases[ownerID][key]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+	}
+	return &canvas, nil
+}
+
+func (s *documentStore) DeleteCanvas(ctx context.Context, ownerID, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.canvases[ownerID][key]; !ok {
+		return fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+	}
+	delete(s.canvases[ownerID], key)
+
+	logrus.WithFields(logrus.Fields{
+		"canvas_key": key,
+		"owner_id":   ownerID,
+	}).Info("Canvas deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ListCanvases(ctx context.Context, ownerID string) ([]core.Canvas, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var canvases []core.Canvas
+	for _, canvas := range s.canvases[ownerID] {
+		canvas.Data = nil
+		canvases = append(canvases, canvas)
+	}
+
+	sort.Slice(canvases, func(i, j int) bool {
+		if canvases[i].UpdatedAt != canvases[j].UpdatedAt {
+			return canvases[i].UpdatedAt > canvases[j].UpdatedAt
+		}
+		return canvases[i].Key < canvases[j].Key
+	})
+	return canvases, nil
+}
+
+func (s *documentStore) ListCanvasPage(ctx context.Context, ownerID string, opts core.CanvasListOptions) (core.CanvasPage, error) {
+	canvases, err := s.ListCanvases(ctx, ownerID)
+	if err != nil {
+		return core.CanvasPage{}, err
+	}
+	return core.PageCanvases(canvases, opts)
+}
+
+func (s *documentStore) UpdateCanvasMetadata(ctx context.Context, ownerID, key string, update core.CanvasMetadataUpdate) (*core.Canvas, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	canvas, ok := s.canvases[ownerID][key]
+	if !ok {
+		return nil, fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+	}
+	canvas.CanvasMetadata.Apply(update)
+	s.canvases[ownerID][key] = canvas
+
+	canvas.Data = nil
+	return &canvas, nil
+}
+
+func (s *documentStore) RecordCanvasView(ctx context.Context, ownerID, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	canvas, ok := s.canvases[ownerID][key]
+	if !ok {
+		return fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+	}
+	canvas.Views++
+	canvas.LastAccessed = int64(ulid.Now())
+	s.canvases[ownerID][key] = canvas
+	return nil
+}
@@ -0,0 +1,26 @@
+package memory
+
+import (
+	"excalidraw-server/core"
+	"excalidraw-server/stores/storetest"
+	"testing"
+	"time"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) core.DocumentStore {
+		return NewDocumentStore()
+	})
+}
+
+func TestConformanceWithEviction(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) core.DocumentStore {
+		return NewDocumentStoreWithOptions(Options{MaxEntries: 1000, MaxBytes: 64 << 20, TTL: time.Hour})
+	})
+}
+
+func BenchmarkStore(b *testing.B) {
+	storetest.Benchmark(b, func(b *testing.B) core.DocumentStore {
+		return NewDocumentStore()
+	})
+}
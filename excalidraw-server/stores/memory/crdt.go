@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+func (s *documentStore) AppendCRDTUpdate(ctx context.Context, roomID string, update []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crdtUpdates[roomID] = append(s.crdtUpdates[roomID], bytes.Clone(update))
+	return nil
+}
+
+func (s *documentStore) GetCRDTUpdates(ctx context.Context, roomID string) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	updates := make([][]byte, len(s.crdtUpdates[roomID]))
+	for i, update := range s.crdtUpdates[roomID] {
+		updates[i] = bytes.Clone(update)
+	}
+	return updates, nil
+}
+
+func (s *documentStore) CompactCRDTUpdates(ctx context.Context, roomID string, n int, merged []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updates := s.crdtUpdates[roomID]
+	if n < 0 || n > len(updates) {
+		return fmt.Errorf("room %s has %d CRDT updates, can't compact %d", roomID, len(updates), n)
+	}
+	compacted := make([][]byte, 0, len(updates)-n+1)
+	compacted = append(compacted, bytes.Clone(merged))
+	s.crdtUpdates[roomID] = append(compacted, updates[n:]...)
+	return nil
+}
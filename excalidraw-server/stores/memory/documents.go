@@ -1,10 +1,12 @@
 package memory
 
 import (
+	"container/list"
 	"context"
 	"excalidraw-server/core"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/sirupsen/logrus"
@@ -13,19 +15,88 @@ import (
 type documentStore struct {
 	mu        sync.RWMutex
 	documents map[string]core.Document
+	// documentStats by document id
+	documentStats map[string]core.AccessStats
+	files         map[string]core.File
+	libraries     map[string]core.Library
+	templates     map[string]core.Template
+	// canvases by owner id, then key
+	canvases map[string]map[string]core.Canvas
+	// orgs and their members by org id, then user id
+	orgs       map[string]core.Org
+	orgMembers map[string]map[string]core.OrgMember
+	// recordings and their events, keyed by recording id
+	recordings      map[string]core.Recording
+	recordingEvents map[string][]core.RecordingEvent
+	// crdtUpdates by room id, oldest first
+	crdtUpdates map[string][][]byte
+	// roomStates of hibernated rooms by room id
+	roomStates map[string][]byte
+	// thumbnails by scene hash
+	thumbnails map[string][]byte
+	// users by id, and notifications by user id, oldest first
+	users         map[string]core.User
+	notifications map[string][]core.Notification
+
+	opts Options
+	now  func() time.Time
+	// lruMu guards the eviction bookkeeping, which reads update under s.mu's
+	// read lock. lru has the most recently used document or file at the
+	// front; it's nil when opts sets no limits.
+	lruMu      sync.Mutex
+	lru        *list.List
+	lruEntries map[string]*list.Element
+	lruBytes   int64
 }
 
 func NewDocumentStore() core.DocumentStore {
-	return &documentStore{
-		documents: make(map[string]core.Document),
+	return NewDocumentStoreWithOptions(Options{})
+}
+
+// NewDocumentStoreWithOptions returns an empty store that evicts documents
+// and files beyond the limits in opts.
+func NewDocumentStoreWithOptions(opts Options) core.DocumentStore {
+	store := &documentStore{
+		documents:     make(map[string]core.Document),
+		documentStats: make(map[string]core.AccessStats),
+		files:         make(map[string]core.File),
+		libraries:     make(map[string]core.Library),
+		templates:     make(map[string]core.Template),
+		canvases:      make(map[string]map[string]core.Canvas),
+
+		orgs:       make(map[string]core.Org),
+		orgMembers: make(map[string]map[string]core.OrgMember),
+
+		recordings:      make(map[string]core.Recording),
+		recordingEvents: make(map[string][]core.RecordingEvent),
+
+		crdtUpdates: make(map[string][][]byte),
+		roomStates:  make(map[string][]byte),
+		thumbnails:  make(map[string][]byte),
+
+		users:         make(map[string]core.User),
+		notifications: make(map[string][]core.Notification),
+
+		opts: opts,
+		now:  time.Now,
 	}
+	if opts.evicts() {
+		store.lru = list.New()
+		store.lruEntries = make(map[string]*list.Element)
+	}
+	return store
 }
 
 func (s *documentStore) FindID(ctx context.Context, id string) (*core.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	log := logrus.WithField("document_id", id)
 
 	s.mu.RLock()
 	doc, ok := s.documents[id]
+	ok = ok && s.touch(documentEntry, id)
 	s.mu.RUnlock()
 
 	if ok {
@@ -38,10 +109,15 @@ func (s *documentStore) FindID(ctx context.Context, id string) (*core.Document,
 }
 
 func (s *documentStore) Create(ctx context.Context, document *core.Document) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	id := ulid.Make().String()
 
 	s.mu.Lock()
 	s.documents[id] = *document
+	s.track(documentEntry, id, int64(document.Data.Len()))
 	s.mu.Unlock()
 
 	log := logrus.WithFields(logrus.Fields{
@@ -52,3 +128,66 @@ func (s *documentStore) Create(ctx context.Context, document *core.Document) (st
 
 	return id, nil
 }
+
+func (s *documentStore) RecordDocumentView(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.documents[id]; !ok {
+		return fmt.Errorf("document with id %s: %w", id, core.ErrDocumentNotFound)
+	}
+	stats := s.documentStats[id]
+	stats.Views++
+	stats.LastAccessed = int64(ulid.Now())
+	s.documentStats[id] = stats
+	return nil
+}
+
+func (s *documentStore) GetDocumentStats(ctx context.Context, id string) (*core.AccessStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.documents[id]; !ok {
+		return nil, fmt.Errorf("document with id %s: %w", id, core.ErrDocumentNotFound)
+	}
+	stats := s.documentStats[id]
+	return &stats, nil
+}
+
+// StorageUsage counts what the store holds; Bytes adds up the documents,
+// canvases and files.
+func (s *documentStore) StorageUsage(ctx context.Context) (*core.StorageUsage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	usage := core.StorageUsage{
+		Documents: len(s.documents),
+		Files:     len(s.files),
+		Users:     len(s.users),
+	}
+	for _, document := range s.documents {
+		usage.Bytes += int64(document.Data.Len())
+	}
+	for _, file := range s.files {
+		usage.Bytes += int64(len(file.Data))
+	}
+	for _, canvases := range s.canvases {
+		usage.Canvases += len(canvases)
+		for _, canvas := range canvases {
+			usage.Bytes += int64(len(canvas.Data))
+		}
+	}
+	return &usage, nil
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	documentEntry = "document"
+	fileEntry     = "file"
+)
+
+// lruEntry tracks a document or file for eviction.
+type lruEntry struct {
+	kind, id string
+	size     int64
+	// touched is when it was last read or written
+	touched time.Time
+}
+
+// track records a new or replaced document or file as just used, then evicts
+// whatever no longer fits. Callers hold s.mu for writing.
+func (s *documentStore) track(kind, id string, size int64) {
+	if s.lru == nil {
+		return
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+
+	key := kind + ":" + id
+	if element, ok := s.lruEntries[key]; ok {
+		s.lruBytes -= element.Value.(*lruEntry).size
+		s.lru.Remove(element)
+	}
+	s.lruEntries[key] = s.lru.PushFront(&lruEntry{kind: kind, id: id, size: size, touched: s.now()})
+	s.lruBytes += size
+	s.evict()
+}
+
+// touch marks a document or file as just read. It reports false when the
+// entry has expired and should be treated as gone. Callers hold s.mu for
+// reading at least.
+func (s *documentStore) touch(kind, id string) bool {
+	if s.lru == nil {
+		return true
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+
+	element, ok := s.lruEntries[kind+":"+id]
+	if !ok {
+		return true
+	}
+	entry := element.Value.(*lruEntry)
+	now := s.now()
+	if s.expired(entry, now) {
+		return false
+	}
+	entry.touched = now
+	s.lru.MoveToFront(element)
+	return true
+}
+
+// untrack forgets a deleted document or file. Callers hold s.mu for writing.
+func (s *documentStore) untrack(kind, id string) {
+	if s.lru == nil {
+		return
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+
+	if element, ok := s.lruEntries[kind+":"+id]; ok {
+		s.removeEntry(element)
+	}
+}
+
+func (s *documentStore) expired(entry *lruEntry, now time.Time) bool {
+	return s.opts.TTL > 0 && now.Sub(entry.touched) >= s.opts.TTL
+}
+
+// evict removes the least recently used documents and files until the store
+// is within its limits and nothing left has expired. Callers hold s.mu for
+// writing and s.lruMu.
+func (s *documentStore) evict() {
+	now := s.now()
+	evicted := 0
+	for element := s.lru.Back(); element != nil; element = s.lru.Back() {
+		entry := element.Value.(*lruEntry)
+		overEntries := s.opts.MaxEntries > 0 && s.lru.Len() > s.opts.MaxEntries
+		overBytes := s.opts.MaxBytes > 0 && s.lruBytes > s.opts.MaxBytes
+		if !overEntries && !overBytes && !s.expired(entry, now) {
+			break
+		}
+		switch entry.kind {
+		case documentEntry:
+			delete(s.documents, entry.id)
+			delete(s.documentStats, entry.id)
+		case fileEntry:
+			delete(s.files, entry.id)
+		}
+		s.removeEntry(element)
+		evicted++
+	}
+	if evicted > 0 {
+		logrus.WithField("evicted", evicted).Debug("Evicted documents and files from memory")
+	}
+}
+
+func (s *documentStore) removeEntry(element *list.Element) {
+	entry := s.lru.Remove(element).(*lruEntry)
+	delete(s.lruEntries, entry.kind+":"+entry.id)
+	s.lruBytes -= entry.size
+}
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"testing"
+	"time"
+)
+
+func newEvictingStore(t *testing.T, opts Options) (*documentStore, *time.Time) {
+	t.Helper()
+	store := NewDocumentStoreWithOptions(opts).(*documentStore)
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func createDocument(t *testing.T, store core.DocumentStore, data string) string {
+	t.Helper()
+	id, err := store.Create(context.Background(), &core.Document{Data: *bytes.NewBufferString(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestEvictMaxEntries(t *testing.T) {
+	store, _ := newEvictingStore(t, Options{MaxEntries: 2})
+	ctx := context.Background()
+
+	first := createDocument(t, store, "first")
+	second := createDocument(t, store, "second")
+	// Reading first makes second the least recently used
+	if _, err := store.FindID(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutFile(ctx, &core.File{ID: "image", Data: []byte("png")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.FindID(ctx, second); err == nil {
+		t.Error("least recently used document wasn't evicted")
+	}
+	if _, err := store.FindID(ctx, first); err != nil {
+		t.Errorf("recently read document was evicted: %v", err)
+	}
+	if _, err := store.GetFile(ctx, "image"); err != nil {
+		t.Errorf("new file was evicted: %v", err)
+	}
+}
+
+func TestEvictMaxBytes(t *testing.T) {
+	store, _ := newEvictingStore(t, Options{MaxBytes: 10})
+	ctx := context.Background()
+
+	old := createDocument(t, store, "123456")
+	if err := store.RecordDocumentView(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+	recent := createDocument(t, store, "789012")
+
+	if _, err := store.FindID(ctx, old); err == nil {
+		t.Error("document over the byte limit wasn't evicted")
+	}
+	if _, err := store.GetDocumentStats(ctx, old); !errors.Is(err, core.ErrDocumentNotFound) {
+		t.Errorf("evicted document's stats error = %v", err)
+	}
+	if _, err := store.FindID(ctx, recent); err != nil {
+		t.Errorf("FindID() of the newest document failed: %v", err)
+	}
+	if store.lruBytes != 6 {
+		t.Errorf("lruBytes = %d, want 6", store.lruBytes)
+	}
+}
+
+func TestEvictTTL(t *testing.T) {
+	store, now := newEvictingStore(t, Options{TTL: time.Hour})
+	ctx := context.Background()
+
+	idle := createDocument(t, store, "idle")
+	read := createDocument(t, store, "read")
+	*now = now.Add(40 * time.Minute)
+	if _, err := store.FindID(ctx, read); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(30 * time.Minute)
+
+	// Expired entries are gone for readers before they're swept
+	if _, err := store.FindID(ctx, idle); err == nil {
+		t.Error("FindID() returned an expired document")
+	}
+	if _, err := store.FindID(ctx, read); err != nil {
+		t.Errorf("FindID() of a recently read document failed: %v", err)
+	}
+
+	createDocument(t, store, "new")
+	if _, ok := store.documents[idle]; ok {
+		t.Error("expired document wasn't swept on the next write")
+	}
+	if len(store.documents) != 2 || store.lru.Len() != 2 {
+		t.Errorf("%d documents and %d tracked entries, want 2", len(store.documents), store.lru.Len())
+	}
+}
+
+func TestDeleteFileUntracks(t *testing.T) {
+	store, _ := newEvictingStore(t, Options{MaxEntries: 10})
+	ctx := context.Background()
+	if err := store.PutFile(ctx, &core.File{ID: "image", Data: []byte("png")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteFile(ctx, "image"); err != nil {
+		t.Fatal(err)
+	}
+	if store.lru.Len() != 0 || store.lruBytes != 0 {
+		t.Errorf("deleted file is still tracked: %d entries, %d bytes", store.lru.Len(), store.lruBytes)
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("MEMORY_MAX_ENTRIES", "1000")
+	t.Setenv("MEMORY_MAX_BYTES", "67108864")
+	t.Setenv("MEMORY_TTL", "24h")
+	opts, err := OptionsFromEnv()
+	if err != nil {
+		t.Fatalf("OptionsFromEnv() failed: %v", err)
+	}
+	if opts != (Options{MaxEntries: 1000, MaxBytes: 64 << 20, TTL: 24 * time.Hour}) {
+		t.Errorf("OptionsFromEnv() = %+v", opts)
+	}
+
+	for key, value := range map[string]string{
+		"MEMORY_MAX_ENTRIES": "-1",
+		"MEMORY_MAX_BYTES":   "lots",
+		"MEMORY_TTL":         "1 day",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := OptionsFromEnv(); err == nil {
+				t.Errorf("OptionsFromEnv() accepted %s=%q", key, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+func (s *documentStore) PutFile(ctx context.Context, file *core.File) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.files[file.ID] = *file
+	s.track(fileEntry, file.ID, int64(len(file.Data)))
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"file_id":     file.ID,
+		"data_length": len(file.Data),
+	}).Info("File stored successfully")
+	return nil
+}
+
+func (s *documentStore) GetFile(ctx context.Context, id string) (*core.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	file, ok := s.files[id]
+	ok = ok && s.touch(fileEntry, id)
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("file with id %s: %w", id, core.ErrFileNotFound)
+	}
+	return &file, nil
+}
+
+func (s *documentStore) ListFiles(ctx context.Context) ([]core.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files := make([]core.File, 0, len(s.files))
+	for id, file := range s.files {
+		files = append(files, core.File{ID: id, CreatedAt: file.CreatedAt})
+	}
+	return files, nil
+}
+
+func (s *documentStore) DeleteFile(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[id]; !ok {
+		return fmt.Errorf("file with id %s: %w", id, core.ErrFileNotFound)
+	}
+	delete(s.files, id)
+	s.untrack(fileEntry, id)
+	logrus.WithField("file_id", id).Info("File deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ScanScenes(ctx context.Context, fn func(data []byte) error) error {
+	s.mu.RLock()
+	scenes := make([][]byte, 0, len(s.documents))
+	for _, doc := range s.documents {
+		scenes = append(scenes, doc.Data.Bytes())
+	}
+	for _, canvases := range s.canvases {
+		for _, canvas := range canvases {
+			scenes = append(scenes, canvas.Data)
+		}
+	}
+	for _, template := range s.templates {
+		scenes = append(scenes, template.Data)
+	}
+	s.mu.RUnlock()
+
+	for _, data := range scenes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
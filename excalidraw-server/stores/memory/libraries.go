@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"sort"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func (s *documentStore) CreateLibrary(ctx context.Context, library *core.Library) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	id := ulid.Make().String()
+	now := int64(ulid.Now())
+
+	stored := *library
+	stored.ID = id
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+
+	s.mu.Lock()
+	s.libraries[id] = stored
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"library_id": id,
+		"owner_id":   library.OwnerID,
+	}).Info("Library created successfully")
+	return id, nil
+}
+
+func (s *documentStore) GetLibrary(ctx context.Context, id string) (*core.Library, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	library, ok := s.libraries[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("library with id %s: %w", id, core.ErrLibraryNotFound)
+	}
+	return &library, nil
+}
+
+func (s *documentStore) UpdateLibrary(ctx context.Context, library *core.Library) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.libraries[library.ID]
+	if !ok {
+		return fmt.Errorf("library with id %s: %w", library.ID, core.ErrLibraryNotFound)
+	}
+	stored.Name = library.Name
+	stored.Description = library.Description
+	stored.Public = library.Public
+	stored.Data = library.Data
+	stored.UpdatedAt = int64(ulid.Now())
+	s.libraries[library.ID] = stored
+
+	logrus.WithField("library_id", library.ID).Info("Library updated successfully")
+	return nil
+}
+
+func (s *documentStore) DeleteLibrary(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.libraries[id]; !ok {
+		return fmt.Errorf("library with id %s: %w", id, core.ErrLibraryNotFound)
+	}
+	delete(s.libraries, id)
+
+	logrus.WithField("library_id", id).Info("Library deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ListLibraries(ctx context.Context, filter core.LibraryFilter) ([]core.Library, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var libraries []core.Library
+	for _, library := range s.libraries {
+		if filter.OwnerID != "" && library.OwnerID != filter.OwnerID {
+			continue
+		}
+		if filter.PublicOnly && !library.Public {
+			continue
+		}
+		library.Data = nil
+		libraries = append(libraries, library)
+	}
+
+	sort.Slice(libraries, func(i, j int) bool {
+		if libraries[i].UpdatedAt != libraries[j].UpdatedAt {
+			return libraries[i].UpdatedAt > libraries[j].UpdatedAt
+		}
+		return libraries[i].ID > libraries[j].ID
+	})
+	return libraries, nil
+}
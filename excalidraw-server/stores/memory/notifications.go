@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+)
+
+func (s *documentStore) PutUser(ctx context.Context, user core.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *documentStore) GetUser(ctx context.Context, id string) (*core.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	user, ok := s.users[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("user %s: %w", id, core.ErrUserNotFound)
+	}
+	return &user, nil
+}
+
+func (s *documentStore) GetUsers(ctx context.Context, ids []string) (map[string]core.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make(map[string]core.User, len(ids))
+	for _, id := range ids {
+		if user, ok := s.users[id]; ok {
+			users[id] = user
+		}
+	}
+	return users, nil
+}
+
+func (s *documentStore) FindUserByLogin(ctx context.Context, login string) (*core.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *core.User
+	for _, user := range s.users {
+		if user.Login == "" || !strings.EqualFold(user.Login, login) {
+			continue
+		}
+		if found == nil || user.SeenAt > found.SeenAt {
+			user := user
+			found = &user
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("user with login %s: %w", login, core.ErrUserNotFound)
+	}
+	return found, nil
+}
+
+func (s *documentStore) DeleteUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, id)
+	return nil
+}
+
+func (s *documentStore) ListUsers(ctx context.Context, limit int) ([]core.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	users := make([]core.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].SeenAt != users[j].SeenAt {
+			return users[i].SeenAt > users[j].SeenAt
+		}
+		return users[i].ID < users[j].ID
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (s *documentStore) AddNotification(ctx context.Context, notification core.Notification) (*core.Notification, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	notification.ID = ulid.Make().String()
+	notification.CreatedAt = s.now().UnixMilli()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications[notification.UserID] = append(s.notifications[notification.UserID], notification)
+	return &notification, nil
+}
+
+func (s *documentStore) ListNotifications(ctx context.Context, userID string, unreadOnly bool, limit int) ([]core.Notification, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored := s.notifications[userID]
+	notifications := make([]core.Notification, 0, min(limit, len(stored)))
+	for i := len(stored) - 1; i >= 0 && len(notifications) < limit; i-- {
+		if unreadOnly && stored[i].ReadAt != 0 {
+			continue
+		}
+		notifications = append(notifications, stored[i])
+	}
+	return notifications, nil
+}
+
+func (s *documentStore) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	unread := 0
+	for _, notification := range s.notifications[userID] {
+		if notification.ReadAt == 0 {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (s *documentStore) MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	readAt := s.now().UnixMilli()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	marked := 0
+	notifications := s.notifications[userID]
+	for i := range notifications {
+		if notifications[i].ReadAt != 0 || len(ids) > 0 && !wanted[notifications[i].ID] {
+			continue
+		}
+		notifications[i].ReadAt = readAt
+		marked++
+	}
+	return marked, nil
+}
+
+func (s *documentStore) DeleteNotifications(ctx context.Context, userID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := len(s.notifications[userID])
+	delete(s.notifications, userID)
+	return deleted, nil
+}
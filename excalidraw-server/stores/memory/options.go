@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Options bounds how much the memory store keeps. Limits apply to shared
+// documents and uploaded files, which anyone can create; libraries, canvases,
+// organizations and recordings belong to signed-in users and are never
+// evicted. Zero values disable each limit.
+type Options struct {
+	// MaxEntries is the most documents and files kept; the least recently
+	// used are evicted first.
+	MaxEntries int
+	// MaxBytes bounds the total size of documents and files.
+	MaxBytes int64
+	// TTL drops documents and files that haven't been read or written for
+	// this long.
+	TTL time.Duration
+}
+
+func (o Options) evicts() bool {
+	return o.MaxEntries > 0 || o.MaxBytes > 0 || o.TTL > 0
+}
+
+// OptionsFromEnv reads MEMORY_MAX_ENTRIES, MEMORY_MAX_BYTES and MEMORY_TTL.
+// Unset values leave the store unbounded.
+func OptionsFromEnv() (Options, error) {
+	var opts Options
+	if value := os.Getenv("MEMORY_MAX_ENTRIES"); value != "" {
+		entries, err := strconv.Atoi(value)
+		if err != nil || entries < 0 {
+			return Options{}, fmt.Errorf("invalid MEMORY_MAX_ENTRIES %q", value)
+		}
+		opts.MaxEntries = entries
+	}
+	if value := os.Getenv("MEMORY_MAX_BYTES"); value != "" {
+		maxBytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || maxBytes < 0 {
+			return Options{}, fmt.Errorf("invalid MEMORY_MAX_BYTES %q", value)
+		}
+		opts.MaxBytes = maxBytes
+	}
+	if value := os.Getenv("MEMORY_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return Options{}, fmt.Errorf("invalid MEMORY_TTL %q", value)
+		}
+		opts.TTL = ttl
+	}
+	return opts, nil
+}
@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"sort"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func (s *documentStore) CreateOrg(ctx context.Context, name, ownerID string) (*core.Org, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	org := core.Org{ID: ulid.Make().String(), Name: name, CreatedAt: int64(ulid.Now())}
+
+	s.mu.Lock()
+	s.orgs[org.ID] = org
+	s.orgMembers[org.ID] = map[string]core.OrgMember{
+		ownerID: {UserID: ownerID, Role: core.OrgRoleAdmin, JoinedAt: org.CreatedAt},
+	}
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"org_id":   org.ID,
+		"owner_id": ownerID,
+	}).Info("Organization created successfully")
+	return &org, nil
+}
+
+func (s *documentStore) GetOrg(ctx context.Context, id string) (*core.Org, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	org, ok := s.orgs[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("organization with id %s: %w", id, core.ErrOrgNotFound)
+	}
+	return &org, nil
+}
+
+func (s *documentStore) DeleteOrg(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgs[id]; !ok {
+		return fmt.Errorf("organization with id %s: %w", id, core.ErrOrgNotFound)
+	}
+	delete(s.orgs, id)
+	delete(s.orgMembers, id)
+
+	logrus.WithField("org_id", id).Info("Organization deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ListOrgs(ctx context.Context, userID string) ([]core.Org, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orgs []core.Org
+	for id, members := range s.orgMembers {
+		member, ok := members[userID]
+		if !ok {
+			continue
+		}
+		org := s.orgs[id]
+		org.Role = member.Role
+		orgs = append(orgs, org)
+	}
+
+	sort.Slice(orgs, func(i, j int) bool {
+		if orgs[i].Name != orgs[j].Name {
+			return orgs[i].Name < orgs[j].Name
+		}
+		return orgs[i].ID < orgs[j].ID
+	})
+	return orgs, nil
+}
+
+func (s *documentStore) ListOrgMembers(ctx context.Context, orgID string) ([]core.OrgMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.orgs[orgID]; !ok {
+		return nil, fmt.Errorf("organization with id %s: %w", orgID, core.ErrOrgNotFound)
+	}
+	members := make([]core.OrgMember, 0, len(s.orgMembers[orgID]))
+	for _, member := range s.orgMembers[orgID] {
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].JoinedAt != members[j].JoinedAt {
+			return members[i].JoinedAt < members[j].JoinedAt
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (s *documentStore) GetOrgMember(ctx context.Context, orgID, userID string) (*core.OrgMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	member, ok := s.orgMembers[orgID][userID]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("user %s in organization %s: %w", userID, orgID, core.ErrNotMember)
+	}
+	return &member, nil
+}
+
+func (s *documentStore) PutOrgMember(ctx context.Context, orgID string, member core.OrgMember) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members, ok := s.orgMembers[orgID]
+	if !ok {
+		return fmt.Errorf("organization with id %s: %w", orgID, core.ErrOrgNotFound)
+	}
+	if existing, ok := members[member.UserID]; ok {
+		member.JoinedAt = existing.JoinedAt
+	} else {
+		member.JoinedAt = int64(ulid.Now())
+	}
+	members[member.UserID] = member
+
+	logrus.WithFields(logrus.Fields{
+		"org_id":  orgID,
+		"user_id": member.UserID,
+		"role":    member.Role,
+	}).Info("Organization member saved successfully")
+	return nil
+}
+
+func (s *documentStore) RemoveOrgMember(ctx context.Context, orgID, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgMembers[orgID][userID]; !ok {
+		return fmt.Errorf("user %s in organization %s: %w", userID, orgID, core.ErrNotMember)
+	}
+	delete(s.orgMembers[orgID], userID)
+
+	logrus.WithFields(logrus.Fields{
+		"org_id":  orgID,
+		"user_id": userID,
+	}).Info("Organization member removed successfully")
+	return nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func (s *documentStore) CreateRecording(ctx context.Context, roomID string, startedAt time.Time) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	id := ulid.Make().String()
+
+	s.mu.Lock()
+	s.recordings[id] = core.Recording{ID: id, RoomID: roomID, StartedAt: startedAt.UnixMilli()}
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"recording_id": id,
+		"room_id":      roomID,
+	}).Info("Recording created successfully")
+	return id, nil
+}
+
+func (s *documentStore) AppendRecordingEvent(ctx context.Context, id string, event core.RecordingEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recording, ok := s.recordings[id]
+	if !ok {
+		return fmt.Errorf("recording with id %s: %w", id, core.ErrRecordingNotFound)
+	}
+	recording.EventCount++
+	s.recordings[id] = recording
+	s.recordingEvents[id] = append(s.recordingEvents[id], event)
+	return nil
+}
+
+func (s *documentStore) FinishRecording(ctx context.Context, id string, endedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recording, ok := s.recordings[id]
+	if !ok {
+		return fmt.Errorf("recording with id %s: %w", id, core.ErrRecordingNotFound)
+	}
+	recording.EndedAt = endedAt.UnixMilli()
+	s.recordings[id] = recording
+
+	logrus.WithField("recording_id", id).Info("Recording finished")
+	return nil
+}
+
+func (s *documentStore) GetRecording(ctx context.Context, id string) (*core.Recording, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	recording, ok := s.recordings[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("recording with id %s: %w", id, core.ErrRecordingNotFound)
+	}
+	return &recording, nil
+}
+
+func (s *documentStore) ListRecordings(ctx context.Context, roomID string) ([]core.Recording, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var recordings []core.Recording
+	for _, recording := range s.recordings {
+		if recording.RoomID == roomID {
+			recordings = append(recordings, recording)
+		}
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		if recordings[i].StartedAt != recordings[j].StartedAt {
+			return recordings[i].StartedAt > recordings[j].StartedAt
+		}
+		return recordings[i].ID > recordings[j].ID
+	})
+	return recordings, nil
+}
+
+func (s *documentStore) GetRecordingEvents(ctx context.Context, id string) ([]core.RecordingEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.recordings[id]; !ok {
+		return nil, fmt.Errorf("recording with id %s: %w", id, core.ErrRecordingNotFound)
+	}
+	events := make([]core.RecordingEvent, len(s.recordingEvents[id]))
+	copy(events, s.recordingEvents[id])
+	return events, nil
+}
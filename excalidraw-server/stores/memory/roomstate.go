@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"sort"
+)
+
+func (s *documentStore) PutRoomState(ctx context.Context, roomID string, state []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roomStates[roomID] = bytes.Clone(state)
+	return nil
+}
+
+func (s *documentStore) GetRoomState(ctx context.Context, roomID string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.roomStates[roomID]
+	if !ok {
+		return nil, fmt.Errorf("room %s: %w", roomID, core.ErrRoomStateNotFound)
+	}
+	return bytes.Clone(state), nil
+}
+
+func (s *documentStore) DeleteRoomState(ctx context.Context, roomID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.roomStates, roomID)
+	return nil
+}
+
+func (s *documentStore) ListRoomStates(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roomIDs := make([]string, 0, len(s.roomStates))
+	for roomID := range s.roomStates {
+		roomIDs = append(roomIDs, roomID)
+	}
+	sort.Strings(roomIDs)
+	return roomIDs, nil
+}
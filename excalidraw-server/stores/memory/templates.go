@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"sort"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func (s *documentStore) CreateTemplate(ctx context.Context, template *core.Template) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	id := ulid.Make().String()
+	now := int64(ulid.Now())
+
+	stored := *template
+	stored.ID = id
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+
+	s.mu.Lock()
+	s.templates[id] = stored
+	s.mu.Unlock()
+
+	logrus.WithField("template_id", id).Info("Template created successfully")
+	return id, nil
+}
+
+func (s *documentStore) GetTemplate(ctx context.Context, id string) (*core.Template, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	template, ok := s.templates[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("template with id %s: %w", id, core.ErrTemplateNotFound)
+	}
+	return &template, nil
+}
+
+func (s *documentStore) UpdateTemplate(ctx context.Context, template *core.Template) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.templates[template.ID]
+	if !ok {
+		return fmt.Errorf("template with id %s: %w", template.ID, core.ErrTemplateNotFound)
+	}
+	stored.Name = template.Name
+	stored.Description = template.Description
+	stored.Category = template.Category
+	stored.Data = template.Data
+	stored.UpdatedAt = int64(ulid.Now())
+	s.templates[template.ID] = stored
+
+	logrus.WithField("template_id", template.ID).Info("Template updated successfully")
+	return nil
+}
+
+func (s *documentStore) DeleteTemplate(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.templates[id]; !ok {
+		return fmt.Errorf("template with id %s: %w", id, core.ErrTemplateNotFound)
+	}
+	delete(s.templates, id)
+
+	logrus.WithField("template_id", id).Info("Template deleted successfully")
+	return nil
+}
+
+func (s *documentStore) ListTemplates(ctx context.Context, category string) ([]core.Template, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var templates []core.Template
+	for _, template := range s.templates {
+		if category != "" && template.Category != category {
+			continue
+		}
+		template.Data = nil
+		templates = append(templates, template)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Category != templates[j].Category {
+			return templates[i].Category < templates[j].Category
+		}
+		if templates[i].Name != templates[j].Name {
+			return templates[i].Name < templates[j].Name
+		}
+		return templates[i].ID < templates[j].ID
+	})
+	return templates, nil
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"sort"
+)
+
+func (s *documentStore) PutThumbnail(ctx context.Context, hash string, png []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.thumbnails[hash] = bytes.Clone(png)
+	return nil
+}
+
+func (s *documentStore) GetThumbnail(ctx context.Context, hash string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	png, ok := s.thumbnails[hash]
+	if !ok {
+		return nil, fmt.Errorf("scene %s: %w", hash, core.ErrThumbnailNotFound)
+	}
+	return bytes.Clone(png), nil
+}
+
+func (s *documentStore) DeleteThumbnail(ctx context.Context, hash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.thumbnails, hash)
+	return nil
+}
+
+func (s *documentStore) ListThumbnails(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hashes := make([]string, 0, len(s.thumbnails))
+	for hash := range s.thumbnails {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
@@ -0,0 +1,250 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"excalidraw-server/core"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// accessGranularity limits how often reading a document updates its last
+// access time, so reads don't turn into writes.
+const accessGranularity = time.Hour
+
+func documentArchiveKey(id string) string {
+	return "documents/" + id
+}
+
+// canvasArchiveKey hex-encodes the owner and key so any value is a safe
+// object name or path.
+func canvasArchiveKey(ownerID, key string) string {
+	return "canvases/" + hex.EncodeToString([]byte(ownerID)) + "/" + hex.EncodeToString([]byte(key))
+}
+
+// SetArchive enables archiving idle data into archive. It must be called
+// before the store is used.
+func (s *documentStore) SetArchive(archive core.Archive) {
+	s.archive = archive
+}
+
+// ArchiveIdle moves documents and canvases that haven't been read or saved
+// since idleSince into the archive. Their rows stay behind without data, so
+// reads restore them transparently.
+func (s *documentStore) ArchiveIdle(ctx context.Context, idleSince time.Time) (int, error) {
+	if s.archive == nil {
+		return 0, fmt.Errorf("no archive configured")
+	}
+	documents, err := s.archiveDocuments(ctx, idleSince)
+	if err != nil {
+		return documents, err
+	}
+	canvases, err := s.archiveCanvases(ctx, idleSince)
+	return documents + canvases, err
+}
+
+// selectStrings runs a query returning one string column.
+func (s *documentStore) selectStrings(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close rows")
+		}
+	}()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func (s *documentStore) archiveDocuments(ctx context.Context, idleSince time.Time) (int, error) {
+	cutoff := idleSince.UnixMilli()
+	// Document ids are ULIDs, so ids below a zero-entropy ULID at the cutoff
+	// were created before it; that covers documents never read since.
+	createdBefore := ulid.MustNew(uint64(cutoff), nil).String()
+	ids, err := s.selectStrings(ctx,
+		"SELECT id FROM documents WHERE archived = 0 AND last_accessed < ? AND id < ?",
+		cutoff, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	archived := 0
+	for _, id := range ids {
+		var data []byte
+		if err := s.db.QueryRowContext(ctx, "SELECT data FROM documents WHERE id = ?", id).Scan(&data); err != nil {
+			return archived, err
+		}
+		if err := s.archive.Put(ctx, documentArchiveKey(id), data); err != nil {
+			return archived, fmt.Errorf("archive document %s: %w", id, err)
+		}
+		// A read since the query keeps the document; its archived copy is
+		// overwritten if it goes idle again
+		result, err := s.db.ExecContext(ctx,
+			"UPDATE documents SET data = NULL, archived = 1 WHERE id = ? AND archived = 0 AND last_accessed < ?",
+			id, cutoff)
+		if err != nil {
+			return archived, err
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+func (s *documentStore) archiveCanvases(ctx context.Context, idleSince time.Time) (int, error) {
+	cutoff := idleSince.UnixMilli()
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT owner_id, key, updated_at FROM canvases WHERE archived_size IS NULL AND updated_at < ? AND last_accessed < ?",
+		cutoff, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	type idleCanvas struct {
+		ownerID, key string
+		updatedAt    int64
+	}
+	var idle []idleCanvas
+	for rows.Next() {
+		var c idleCanvas
+		if err := rows.Scan(&c.ownerID, &c.key, &c.updatedAt); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		idle = append(idle, c)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	archived := 0
+	for _, c := range idle {
+		var data []byte
+		err := s.db.QueryRowContext(ctx,
+			"SELECT data FROM canvases WHERE owner_id = ? AND key = ? AND updated_at = ?",
+			c.ownerID, c.key, c.updatedAt).Scan(&data)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return archived, err
+		}
+		if err := s.archive.Put(ctx, canvasArchiveKey(c.ownerID, c.key), data); err != nil {
+			return archived, fmt.Errorf("archive canvas %s: %w", c.key, err)
+		}
+		// Skip canvases saved or viewed since the query
+		result, err := s.db.ExecContext(ctx,
+			`UPDATE canvases SET data = X'', archived_size = ?
+			WHERE owner_id = ? AND key = ? AND updated_at = ? AND archived_size IS NULL AND last_accessed < ?`,
+			len(data), c.ownerID, c.key, c.updatedAt, cutoff)
+		if err != nil {
+			return archived, err
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+// restoreDocument moves an archived document back into the database. The
+// archived copy is kept, so concurrent restores can't miss it.
+func (s *documentStore) restoreDocument(ctx context.Context, id string) ([]byte, error) {
+	if s.archive == nil {
+		return nil, fmt.Errorf("document %s is archived but no archive is configured", id)
+	}
+	data, err := s.archive.Get(ctx, documentArchiveKey(id))
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.ExecContext(ctx,
+		"UPDATE documents SET data = ?, archived = 0, last_accessed = ? WHERE id = ? AND archived = 1",
+		data, int64(ulid.Now()), id)
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithField("document_id", id).Info("Restored archived document")
+	return data, nil
+}
+
+// restoreCanvas moves an archived canvas back into the database. It returns
+// nil data when the canvas was saved again meanwhile.
+func (s *documentStore) restoreCanvas(ctx context.Context, ownerID, key string) ([]byte, error) {
+	if s.archive == nil {
+		return nil, fmt.Errorf("canvas %s is archived but no archive is configured", key)
+	}
+	data, err := s.archive.Get(ctx, canvasArchiveKey(ownerID, key))
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE canvases SET data = ?, archived_size = NULL WHERE owner_id = ? AND key = ? AND archived_size IS NOT NULL",
+		data, ownerID, key)
+	if err != nil {
+		return nil, err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, nil
+	}
+	logrus.WithField("canvas_key", key).WithField("owner_id", ownerID).Info("Restored archived canvas")
+	return data, nil
+}
+
+// touchDocument records a read of a document that was last read at
+// lastAccessed, at most once per accessGranularity.
+func (s *documentStore) touchDocument(ctx context.Context, id string, lastAccessed int64) {
+	now := time.Now()
+	if now.Sub(time.UnixMilli(lastAccessed)) < accessGranularity {
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, "UPDATE documents SET last_accessed = ? WHERE id = ?", now.UnixMilli(), id); err != nil {
+		logrus.WithField("document_id", id).WithField("error", err).Warn("Failed to record document access")
+	}
+}
+
+// scanArchived passes the data of every archived document and canvas to fn.
+func (s *documentStore) scanArchived(ctx context.Context, fn func(data []byte) error) error {
+	ids, err := s.selectStrings(ctx, "SELECT id FROM documents WHERE archived = 1")
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, documentArchiveKey(id))
+	}
+	// The same keys as canvasArchiveKey
+	canvases, err := s.selectStrings(ctx,
+		"SELECT 'canvases/' || lower(hex(owner_id)) || '/' || lower(hex(key)) FROM canvases WHERE archived_size IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	keys = append(keys, canvases...)
+	if len(keys) > 0 && s.archive == nil {
+		return fmt.Errorf("%d items are archived but no archive is configured", len(keys))
+	}
+
+	for _, key := range keys {
+		data, err := s.archive.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("read archived %s: %w", key, err)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
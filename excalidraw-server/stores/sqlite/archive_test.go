@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryArchive is a core.Archive backed by a map.
+type memoryArchive struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (a *memoryArchive) Put(_ context.Context, key string, data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (a *memoryArchive) Get(_ context.Context, key string) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	data, ok := a.objects[key]
+	if !ok {
+		return nil, errors.New("not archived")
+	}
+	return data, nil
+}
+
+func (a *memoryArchive) Delete(_ context.Context, key string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.objects, key)
+	return nil
+}
+
+func setupArchivingDB(t *testing.T) (*documentStore, *memoryArchive) {
+	t.Helper()
+	store := setupTestDB(t)
+	archive := &memoryArchive{objects: map[string][]byte{}}
+	store.SetArchive(archive)
+	return store, archive
+}
+
+func TestArchiveDocuments(t *testing.T) {
+	store, archive := setupArchivingDB(t)
+	ctx := context.Background()
+
+	idle, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("idle scene")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("recent scene")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Read after the cutoff, so it isn't idle
+	if _, err := store.db.Exec("UPDATE documents SET last_accessed = ? WHERE id = ?", time.Now().Add(time.Hour).UnixMilli(), recent); err != nil {
+		t.Fatal(err)
+	}
+
+	archived, err := store.ArchiveIdle(ctx, time.Now().Add(time.Minute))
+	if err != nil || archived != 1 {
+		t.Fatalf("ArchiveIdle() = %d, %v, want 1", archived, err)
+	}
+	if string(archive.objects[documentArchiveKey(idle)]) != "idle scene" {
+		t.Errorf("archive = %v", archive.objects)
+	}
+	var data []byte
+	if err := store.db.QueryRow("SELECT data FROM documents WHERE id = ?", idle).Scan(&data); err != nil || data != nil {
+		t.Errorf("archived row data = %q, %v, want NULL", data, err)
+	}
+
+	// Reading restores it
+	document, err := store.FindID(ctx, idle)
+	if err != nil || document.Data.String() != "idle scene" {
+		t.Fatalf("FindID() = %v, %v", document, err)
+	}
+	var isArchived bool
+	if err := store.db.QueryRow("SELECT archived FROM documents WHERE id = ?", idle).Scan(&isArchived); err != nil || isArchived {
+		t.Errorf("archived = %v, %v after restore", isArchived, err)
+	}
+
+	// Nothing is idle right after the restore
+	if archived, err := store.ArchiveIdle(ctx, time.Now().Add(-time.Minute)); err != nil || archived != 0 {
+		t.Errorf("second ArchiveIdle() = %d, %v, want 0", archived, err)
+	}
+}
+
+func TestArchiveCanvases(t *testing.T) {
+	store, archive := setupArchivingDB(t)
+	ctx := context.Background()
+
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "plan", Data: []byte(`{"elements":[]}`)}); err != nil {
+		t.Fatal(err)
+	}
+	archived, err := store.ArchiveIdle(ctx, time.Now().Add(time.Minute))
+	if err != nil || archived != 1 {
+		t.Fatalf("ArchiveIdle() = %d, %v, want 1", archived, err)
+	}
+
+	// Listings keep the original size
+	canvases, err := store.ListCanvases(ctx, "alice")
+	if err != nil || len(canvases) != 1 || canvases[0].Size != 15 {
+		t.Errorf("ListCanvases() = %+v, %v", canvases, err)
+	}
+
+	// File garbage collection still sees archived scenes
+	var scanned []string
+	if err := store.ScanScenes(ctx, func(data []byte) error {
+		scanned = append(scanned, string(data))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(scanned) != 1 || scanned[0] != `{"elements":[]}` {
+		t.Errorf("ScanScenes() = %q", scanned)
+	}
+
+	canvas, err := store.GetCanvas(ctx, "alice", "plan")
+	if err != nil || string(canvas.Data) != `{"elements":[]}` || canvas.Size != 15 {
+		t.Fatalf("GetCanvas() = %+v, %v", canvas, err)
+	}
+
+	if err := store.DeleteCanvas(ctx, "alice", "plan"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := archive.objects[canvasArchiveKey("alice", "plan")]; ok {
+		t.Error("DeleteCanvas() left the archived copy")
+	}
+}
+
+func TestArchiveIdleWithoutArchive(t *testing.T) {
+	store := setupTestDB(t)
+	if _, err := store.ArchiveIdle(context.Background(), time.Now()); err == nil {
+		t.Error("ArchiveIdle() without an archive succeeded")
+	}
+}
@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func (s *documentStore) BackupFormat() string {
+	return "db"
+}
+
+// WriteBackup copies a consistent snapshot of the database to w. VACUUM INTO
+// reads inside a single transaction, so writers keep going while it runs.
+func (s *documentStore) WriteBackup(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "excalidraw-backup-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "backup.db")
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	_, err = io.Copy(w, file)
+	return err
+}
@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteBackup(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("scene")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var backup bytes.Buffer
+	if err := store.WriteBackup(ctx, &backup); err != nil {
+		t.Fatal(err)
+	}
+
+	// The backup is a complete database the store can open again
+	path := filepath.Join(t.TempDir(), "restored.db")
+	if err := os.WriteFile(path, backup.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewDocumentStore(path)
+	document, err := restored.FindID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.Data.String() != "scene" {
+		t.Errorf("expected restored scene, got %q", document.Data.String())
+	}
+}
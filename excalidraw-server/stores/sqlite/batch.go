@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writeFunc performs one logical write inside a transaction.
+type writeFunc func(ctx context.Context, tx *sql.Tx) error
+
+type writeOp struct {
+	ctx  context.Context
+	fn   writeFunc
+	done chan error
+}
+
+// writeBatcher funnels inserts through a single goroutine that commits up to
+// size of them per transaction, so concurrent saves share one fsync instead
+// of contending for the write lock.
+type writeBatcher struct {
+	db   *sql.DB
+	ops  chan writeOp
+	size int
+	wait time.Duration
+}
+
+func newWriteBatcher(db *sql.DB, size int, wait time.Duration) *writeBatcher {
+	b := &writeBatcher{
+		db:   db,
+		ops:  make(chan writeOp, size),
+		size: size,
+		wait: wait,
+	}
+	go b.run()
+	return b
+}
+
+// do queues fn and waits until its batch is committed.
+func (b *writeBatcher) do(ctx context.Context, fn writeFunc) error {
+	op := writeOp{ctx: ctx, fn: fn, done: make(chan error, 1)}
+	select {
+	case b.ops <- op:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-op.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *writeBatcher) run() {
+	for first := range b.ops {
+		batch := []writeOp{first}
+		timer := time.NewTimer(b.wait)
+	collect:
+		for len(batch) < b.size {
+			select {
+			case op := <-b.ops:
+				batch = append(batch, op)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		b.commit(batch)
+	}
+}
+
+// commit runs a batch in one transaction. Each op runs in its own savepoint
+// so a failing op is rolled back without affecting the others. Statements
+// run without the op's context: interrupting a statement would roll back the
+// whole transaction.
+func (b *writeBatcher) commit(batch []writeOp) {
+	results := make([]error, len(batch))
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		for _, op := range batch {
+			op.done <- err
+		}
+		return
+	}
+
+	for i, op := range batch {
+		if err := op.ctx.Err(); err != nil {
+			results[i] = err
+			continue
+		}
+		if _, err := tx.Exec("SAVEPOINT batch_op"); err != nil {
+			results[i] = err
+			continue
+		}
+		if err := op.fn(context.Background(), tx); err != nil {
+			results[i] = err
+			if _, rerr := tx.Exec("ROLLBACK TO batch_op"); rerr != nil {
+				logrus.WithError(rerr).Error("Failed to roll back batched write")
+			}
+		}
+		if _, err := tx.Exec("RELEASE batch_op"); err != nil && results[i] == nil {
+			results[i] = err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logrus.WithField("batch_size", len(batch)).WithError(err).Error("Failed to commit write batch")
+		for i := range results {
+			if results[i] == nil {
+				results[i] = err
+			}
+		}
+	}
+	for i, op := range batch {
+		op.done <- results[i]
+	}
+}
+
+// write runs fn through the write batcher, or in a transaction of its own
+// when batching is disabled.
+func (s *documentStore) write(ctx context.Context, fn writeFunc) error {
+	if s.writes != nil {
+		return s.writes.do(ctx, fn)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"excalidraw-server/core"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setupBatchedDB(t *testing.T) *documentStore {
+	t.Helper()
+	opts := DefaultOptions()
+	opts.BatchSize = 16
+	opts.BatchWait = 5 * time.Millisecond
+	return NewDocumentStoreWithOptions(filepath.Join(t.TempDir(), "test.db"), opts).(*documentStore)
+}
+
+func TestJournalModeWAL(t *testing.T) {
+	store := setupTestDB(t)
+
+	var mode string
+	if err := store.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		t.Fatalf("PRAGMA journal_mode failed: %v", err)
+	}
+	if mode != "wal" {
+		t.Errorf("journal_mode = %q, want wal", mode)
+	}
+}
+
+func TestBatchedConcurrentWrites(t *testing.T) {
+	store := setupBatchedDB(t)
+	ctx := context.Background()
+
+	const writers = 50
+	ids := make(chan string, writers*2)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("scene")})
+			if err != nil {
+				t.Errorf("Create() failed: %v", err)
+				return
+			}
+			ids <- id
+			id, err = store.CreateSnapshot(ctx, "room-1", "auto", "", "", "", []byte("scene"))
+			if err != nil {
+				t.Errorf("CreateSnapshot() failed: %v", err)
+				return
+			}
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	count := 0
+	for range ids {
+		count++
+	}
+	if count != writers*2 {
+		t.Errorf("got %d ids, want %d", count, writers*2)
+	}
+
+	snapshots, err := store.ListSnapshots(ctx, "room-1")
+	if err != nil {
+		t.Fatalf("ListSnapshots() failed: %v", err)
+	}
+	if len(snapshots) != 10 {
+		t.Errorf("got %d snapshots, want the default limit of 10", len(snapshots))
+	}
+}
+
+func TestBatchedWriteFailureIsIsolated(t *testing.T) {
+	store := setupBatchedDB(t)
+	ctx := context.Background()
+	failure := errors.New("boom")
+
+	var wg sync.WaitGroup
+	results := make([]error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		results[0] = store.write(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO documents (id, data) VALUES ('failed', 'x')"); err != nil {
+				return err
+			}
+			return failure
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		results[1] = store.write(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO documents (id, data) VALUES ('kept', 'x')")
+			return err
+		})
+	}()
+	wg.Wait()
+
+	if !errors.Is(results[0], failure) || results[1] != nil {
+		t.Fatalf("results = %v", results)
+	}
+	if _, err := store.FindID(ctx, "failed"); err == nil {
+		t.Error("expected the failed write to be rolled back")
+	}
+	if _, err := store.FindID(ctx, "kept"); err != nil {
+		t.Errorf("expected the other write to be committed: %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"excalidraw-server/core"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// PutCanvas creates or replaces a user's canvas, keeping its creation time
+func (s *documentStore) PutCanvas(ctx context.Context, canvas *core.Canvas) error {
+	now := int64(ulid.Now())
+	log := logrus.WithFields(logrus.Fields{
+		"canvas_key": canvas.Key,
+		"owner_id":   canvas.OwnerID,
+	})
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO canvases (owner_id, key, created_at, updated_at, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(owner_id, key) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at, archived_size = NULL`,
+		canvas.OwnerID, canvas.Key, now, now, canvas.Data)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to save canvas")
+		return err
+	}
+
+	log.Info("Canvas saved successfully")
+	return nil
+}
+
+// GetCanvas retrieves a user's canvas including its data
+func (s *documentStore) GetCanvas(ctx context.Context, ownerID, key string) (*core.Canvas, error) {
+	canvas := core.Canvas{OwnerID: ownerID, Key: key}
+	var archivedSize sql.NullInt64
+	var tags string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT created_at, updated_at, views, last_accessed, data, archived_size, name, tags FROM canvases WHERE owner_id = ? AND key = ?",
+		ownerID, key).Scan(&canvas.CreatedAt, &canvas.UpdatedAt, &canvas.Views, &canvas.LastAccessed, &canvas.Data, &archivedSize, &canvas.Name, &tags)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+		}
+		logrus.WithField("canvas_key", key).WithField("error", err).Error("Failed to retrieve canvas")
+		return nil, err
+	}
+	if archivedSize.Valid {
+		restored, err := s.restoreCanvas(ctx, ownerID, key)
+		if err != nil {
+			logrus.WithField("canvas_key", key).WithField("error", err).Error("Failed to restore archived canvas")
+			return nil, err
+		}
+		if restored == nil {
+			// Saved again while it was being restored
+			return s.GetCanvas(ctx, ownerID, key)
+		}
+		canvas.Data = restored
+	}
+	canvas.Size = len(canvas.Data)
+	if canvas.Tags, err = decodeTags(tags); err != nil {
+		return nil, err
+	}
+
+	return &canvas, nil
+}
+
+// DeleteCanvas deletes a user's canvas
+func (s *documentStore) DeleteCanvas(ctx context.Context, ownerID, key string) error {
+	log := logrus.WithFields(logrus.Fields{
+		"canvas_key": key,
+		"owner_id":   ownerID,
+	})
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM canvases WHERE owner_id = ? AND key = ?", ownerID, key)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to delete canvas")
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+	}
+
+	if s.archive != nil {
+		if err := s.archive.Delete(ctx, canvasArchiveKey(ownerID, key)); err != nil {
+			log.WithField("error", err).Warn("Failed to delete archived canvas")
+		}
+	}
+
+	log.Info("Canvas deleted successfully")
+	return nil
+}
+
+const (
+	// canvasListColumns are the columns scanListedCanvas reads
+	canvasListColumns = "key, created_at, updated_at, COALESCE(archived_size, length(data)), views, last_accessed, name, tags"
+	// canvasSortName matches the canvases_owner_name index
+	canvasSortName = "(CASE WHEN name = '' THEN key ELSE name END)"
+)
+
+func scanListedCanvas(rows *sql.Rows, ownerID string) (*core.Canvas, error) {
+	canvas := core.Canvas{OwnerID: ownerID}
+	var tags string
+	if err := rows.Scan(&canvas.Key, &canvas.CreatedAt, &canvas.UpdatedAt, &canvas.Size, &canvas.Views, &canvas.LastAccessed, &canvas.Name, &tags); err != nil {
+		return nil, err
+	}
+	var err error
+	if canvas.Tags, err = decodeTags(tags); err != nil {
+		return nil, err
+	}
+	return &canvas, nil
+}
+
+func decodeTags(tags string) ([]string, error) {
+	var decoded []string
+	if err := json.Unmarshal([]byte(tags), &decoded); err != nil {
+		return nil, fmt.Errorf("decode canvas tags: %w", err)
+	}
+	if len(decoded) == 0 {
+		return nil, nil
+	}
+	return decoded, nil
+}
+
+// ListCanvases lists a user's canvases without their data
+func (s *documentStore) ListCanvases(ctx context.Context, ownerID string) ([]core.Canvas, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+canvasListColumns+" FROM canvases WHERE owner_id = ? ORDER BY updated_at DESC, key ASC",
+		ownerID)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list canvases")
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close canvas rows")
+		}
+	}()
+
+	var canvases []core.Canvas
+	for rows.Next() {
+		canvas, err := scanListedCanvas(rows, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		canvases = append(canvases, *canvas)
+	}
+	return canvases, rows.Err()
+}
+
+// ListCanvasPage lists a page of a user's canvases without their data,
+// seeking past the cursor with the canvases_owner_updated or
+// canvases_owner_name index
+func (s *documentStore) ListCanvasPage(ctx context.Context, ownerID string, opts core.CanvasListOptions) (core.CanvasPage, error) {
+	last, hasCursor, err := opts.After()
+	if err != nil {
+		return core.CanvasPage{}, err
+	}
+
+	direction, compare := "ASC", ">"
+	if opts.Descending() {
+		direction, compare = "DESC", "<"
+	}
+	query := "SELECT " + canvasListColumns + " FROM canvases WHERE owner_id = ?"
+	args := []any{ownerID}
+	if opts.Sort == core.CanvasSortName {
+		if hasCursor {
+			query += " AND (" + canvasSortName + ", key) " + compare + " (?, ?)"
+			args = append(args, last.SortName(), last.Key)
+		}
+		query += " ORDER BY " + canvasSortName + " " + direction + ", key " + direction
+	} else {
+		if hasCursor {
+			query += " AND (updated_at, key) " + compare + " (?, ?)"
+			args = append(args, last.UpdatedAt, last.Key)
+		}
+		query += " ORDER BY updated_at " + direction + ", key " + direction
+	}
+	if opts.Limit > 0 {
+		// One more row tells whether there is a next page
+		query += " LIMIT ?"
+		args = append(args, opts.Limit+1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list canvases")
+		return core.CanvasPage{}, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close canvas rows")
+		}
+	}()
+
+	var page core.CanvasPage
+	for rows.Next() {
+		canvas, err := scanListedCanvas(rows, ownerID)
+		if err != nil {
+			return core.CanvasPage{}, err
+		}
+		page.Canvases = append(page.Canvases, *canvas)
+	}
+	if err := rows.Err(); err != nil {
+		return core.CanvasPage{}, err
+	}
+	if opts.Limit > 0 && len(page.Canvases) > opts.Limit {
+		page.Canvases = page.Canvases[:opts.Limit]
+		page.NextCursor = opts.CursorAfter(page.Canvases[opts.Limit-1])
+	}
+	return page, nil
+}
+
+// UpdateCanvasMetadata renames or re-tags a user's canvas without loading
+// its data
+func (s *documentStore) UpdateCanvasMetadata(ctx context.Context, ownerID, key string, update core.CanvasMetadataUpdate) (*core.Canvas, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, "SELECT "+canvasListColumns+" FROM canvases WHERE owner_id = ? AND key = ?", ownerID, key)
+	if err != nil {
+		return nil, err
+	}
+	var canvas *core.Canvas
+	if rows.Next() {
+		canvas, err = scanListedCanvas(rows, ownerID)
+	}
+	if cerr := rows.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if canvas == nil {
+		return nil, fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+	}
+
+	canvas.CanvasMetadata.Apply(update)
+	tags, err := json.Marshal(append([]string{}, canvas.Tags...))
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE canvases SET name = ?, tags = ? WHERE owner_id = ? AND key = ?",
+		canvas.Name, string(tags), ownerID, key)
+	if err != nil {
+		logrus.WithField("canvas_key", key).WithField("error", err).Error("Failed to update canvas metadata")
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return canvas, nil
+}
+
+// RecordCanvasView counts a view of a user's canvas
+func (s *documentStore) RecordCanvasView(ctx context.Context, ownerID, key string) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE canvases SET views = views + 1, last_accessed = ? WHERE owner_id = ? AND key = ?",
+		int64(ulid.Now()), ownerID, key)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("canvas %s: %w", key, core.ErrCanvasNotFound)
+	}
+	return nil
+}
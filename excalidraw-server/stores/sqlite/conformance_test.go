@@ -0,0 +1,20 @@
+package sqlite
+
+import (
+	"excalidraw-server/core"
+	"excalidraw-server/stores/storetest"
+	"path/filepath"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) core.DocumentStore {
+		return setupTestDB(t)
+	})
+}
+
+func BenchmarkStore(b *testing.B) {
+	storetest.Benchmark(b, func(b *testing.B) core.DocumentStore {
+		return NewDocumentStore(filepath.Join(b.TempDir(), "bench.db"))
+	})
+}
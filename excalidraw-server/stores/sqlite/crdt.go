@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// AppendCRDTUpdate adds an update to the end of a room's CRDT log
+func (s *documentStore) AppendCRDTUpdate(ctx context.Context, roomID string, update []byte) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO crdt_updates (room_id, seq, data) SELECT ?, COALESCE(MAX(seq), 0) + 1, ? FROM crdt_updates WHERE room_id = ?",
+		roomID, update, roomID)
+	return err
+}
+
+// GetCRDTUpdates returns a room's CRDT log, oldest first
+func (s *documentStore) GetCRDTUpdates(ctx context.Context, roomID string) ([][]byte, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT data FROM crdt_updates WHERE room_id = ? ORDER BY seq", roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	updates := [][]byte{}
+	for rows.Next() {
+		var update []byte
+		if err := rows.Scan(&update); err != nil {
+			return nil, err
+		}
+		updates = append(updates, update)
+	}
+	return updates, rows.Err()
+}
+
+// CompactCRDTUpdates replaces the first n updates of a room's CRDT log with
+// merged, which takes the place of the last one replaced
+func (s *documentStore) CompactCRDTUpdates(ctx context.Context, roomID string, n int, merged []byte) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if n < 0 {
+		return fmt.Errorf("can't compact %d CRDT updates", n)
+	}
+	var count, last int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*), COALESCE(MAX(seq), 0) FROM (SELECT seq FROM crdt_updates WHERE room_id = ? ORDER BY seq LIMIT ?)",
+		roomID, n).Scan(&count, &last)
+	if err != nil {
+		return err
+	}
+	if count != int64(n) {
+		return fmt.Errorf("room %s has %d CRDT updates, can't compact %d", roomID, count, n)
+	}
+
+	if n > 0 {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM crdt_updates WHERE room_id = ? AND seq <= ?", roomID, last); err != nil {
+			return err
+		}
+	} else {
+		// Nothing to replace; merged goes before everything else
+		err = tx.QueryRowContext(ctx, "SELECT COALESCE(MIN(seq), 1) - 1 FROM crdt_updates WHERE room_id = ?", roomID).Scan(&last)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO crdt_updates (room_id, seq, data) VALUES (?, ?, ?)", roomID, last, merged); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
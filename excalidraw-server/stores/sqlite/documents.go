@@ -16,57 +16,43 @@ import (
 
 type documentStore struct {
 	db *sql.DB
+	// writes batches document and snapshot inserts; nil writes each alone.
+	writes *writeBatcher
+	// archive holds idle documents and canvases; nil disables archiving.
+	archive core.Archive
 }
 
 func NewDocumentStore(dataSourceName string) core.DocumentStore {
-	db, err := sql.Open("sqlite3", dataSourceName)
+	return NewDocumentStoreWithOptions(dataSourceName, DefaultOptions())
+}
 
-	if err != nil {
-		stdlog.Fatal(err)
-	}
+// NewDocumentStoreWithOptions opens the database with the given journal mode,
+// busy timeout and write batching.
+func NewDocumentStoreWithOptions(dataSourceName string, opts Options) core.DocumentStore {
+	db, err := sql.Open("sqlite3", dataSourceWithOptions(dataSourceName, opts))
 
-	// Create documents table
-	sts := `CREATE TABLE IF NOT EXISTS documents (id TEXT PRIMARY KEY, data BLOB);`
-	_, err = db.Exec(sts)
 	if err != nil {
 		stdlog.Fatal(err)
 	}
 
-	// Create snapshots table
-	snapshotsTable := `CREATE TABLE IF NOT EXISTS snapshots (
-		id TEXT PRIMARY KEY,
-		room_id TEXT NOT NULL,
-		name TEXT,
-		description TEXT,
-		thumbnail TEXT,
-		created_by TEXT,
-		created_at INTEGER NOT NULL,
-		data BLOB NOT NULL
-	);`
-	_, err = db.Exec(snapshotsTable)
-	if err != nil {
+	if _, err := Migrate(context.Background(), db); err != nil {
 		stdlog.Fatal(err)
 	}
 
-	// Create room_settings table
-	settingsTable := `CREATE TABLE IF NOT EXISTS room_settings (
-		room_id TEXT PRIMARY KEY,
-		max_snapshots INTEGER DEFAULT 10,
-		auto_save_interval INTEGER DEFAULT 300
-	);`
-	_, err = db.Exec(settingsTable)
-	if err != nil {
-		stdlog.Fatal(err)
+	store := &documentStore{db: db}
+	if opts.BatchSize > 1 {
+		store.writes = newWriteBatcher(db, opts.BatchSize, opts.BatchWait)
 	}
-
-	return &documentStore{db}
+	return store
 }
 
 func (s *documentStore) FindID(ctx context.Context, id string) (*core.Document, error) {
 	log := logrus.WithField("document_id", id)
 	log.Debug("Retrieving document by ID")
 	var data []byte
-	err := s.db.QueryRowContext(ctx, "SELECT data FROM documents WHERE id = ?", id).Scan(&data)
+	var archived bool
+	var lastAccessed int64
+	err := s.db.QueryRowContext(ctx, "SELECT data, archived, last_accessed FROM documents WHERE id = ?", id).Scan(&data, &archived, &lastAccessed)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.WithField("error", "document not found").Warn("Document with specified ID not found")
@@ -75,6 +61,14 @@ func (s *documentStore) FindID(ctx context.Context, id string) (*core.Document,
 		log.WithField("error", err).Error("Failed to retrieve document")
 		return nil, err
 	}
+	if archived {
+		if data, err = s.restoreDocument(ctx, id); err != nil {
+			log.WithField("error", err).Error("Failed to restore archived document")
+			return nil, err
+		}
+	} else {
+		s.touchDocument(ctx, id, lastAccessed)
+	}
 	document := core.Document{
 		Data: *bytes.NewBuffer(data),
 	}
@@ -90,7 +84,10 @@ func (s *documentStore) Create(ctx context.Context, document *core.Document) (st
 		"data_length": len(data),
 	})
 
-	_, err := s.db.ExecContext(ctx, "INSERT INTO documents (id, data) VALUES (?, ?)", id, data)
+	err := s.write(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO documents (id, data) VALUES (?, ?)", id, data)
+		return err
+	})
 	if err != nil {
 		log.WithField("error", err).Error("Failed to create document")
 		return "", err
@@ -140,28 +137,31 @@ func (s *documentStore) CreateSnapshot(ctx context.Context, roomID, name, descri
 		}
 	}
 
-	// Count existing snapshots
-	var count int
-	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM snapshots WHERE room_id = ?", roomID).Scan(&count)
-	if err != nil {
-		log.WithField("error", err).Error("Failed to count snapshots")
-		return "", err
-	}
-
-	// If at limit, delete oldest snapshot
-	if count >= settings.MaxSnapshots {
-		_, err = s.db.ExecContext(ctx,
-			"DELETE FROM snapshots WHERE id = (SELECT id FROM snapshots WHERE room_id = ? ORDER BY created_at ASC LIMIT 1)",
-			roomID)
+	err = s.write(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		// Count existing snapshots
+		var count int
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM snapshots WHERE room_id = ?", roomID).Scan(&count)
 		if err != nil {
-			log.WithField("error", err).Error("Failed to delete oldest snapshot")
+			log.WithField("error", err).Error("Failed to count snapshots")
+			return err
+		}
+
+		// If at limit, delete oldest snapshot
+		if count >= settings.MaxSnapshots {
+			_, err = tx.ExecContext(ctx,
+				"DELETE FROM snapshots WHERE id = (SELECT id FROM snapshots WHERE room_id = ? ORDER BY created_at ASC LIMIT 1)",
+				roomID)
+			if err != nil {
+				log.WithField("error", err).Error("Failed to delete oldest snapshot")
+			}
 		}
-	}
 
-	// Insert new snapshot
-	_, err = s.db.ExecContext(ctx,
-		"INSERT INTO snapshots (id, room_id, name, description, thumbnail, created_by, created_at, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		id, roomID, name, description, thumbnail, createdBy, createdAt, data)
+		// Insert new snapshot
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO snapshots (id, room_id, name, description, thumbnail, created_by, created_at, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			id, roomID, name, description, thumbnail, createdBy, createdAt, data)
+		return err
+	})
 	if err != nil {
 		log.WithField("error", err).Error("Failed to create snapshot")
 		return "", err
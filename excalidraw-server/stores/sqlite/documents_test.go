@@ -748,3 +748,36 @@ func TestNullValues(t *testing.T) {
 		t.Errorf("Expected empty name, got %q", snapshot.Name)
 	}
 }
+
+func TestInlineThumbnails(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	withThumbnail, err := store.CreateSnapshot(ctx, "room-1", "a", "", "data:image/png;base64,AAAA", "", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateSnapshot(ctx, "room-1", "b", "", "", "", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := store.ListInlineThumbnails(ctx)
+	if err != nil || len(ids) != 1 || ids[0] != withThumbnail {
+		t.Fatalf("ListInlineThumbnails() = %v, %v, want [%s]", ids, err, withThumbnail)
+	}
+	if err := store.SetInlineThumbnail(ctx, withThumbnail, "data:image/png;base64,BBBB"); err != nil {
+		t.Fatalf("SetInlineThumbnail() failed: %v", err)
+	}
+	if thumbnail, err := store.GetInlineThumbnail(ctx, withThumbnail); err != nil || thumbnail != "data:image/png;base64,BBBB" {
+		t.Errorf("GetInlineThumbnail() = %q, %v", thumbnail, err)
+	}
+	if err := store.SetInlineThumbnail(ctx, withThumbnail, ""); err != nil {
+		t.Fatalf("SetInlineThumbnail() removing failed: %v", err)
+	}
+	if ids, err := store.ListInlineThumbnails(ctx); err != nil || len(ids) != 0 {
+		t.Errorf("ListInlineThumbnails() after removing = %v, %v", ids, err)
+	}
+	if _, err := store.GetInlineThumbnail(ctx, "missing"); err == nil {
+		t.Error("GetInlineThumbnail() of a missing snapshot succeeded")
+	}
+}
@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func (s *documentStore) PutFile(ctx context.Context, file *core.File) error {
+	log := logrus.WithFields(logrus.Fields{
+		"file_id":     file.ID,
+		"data_length": len(file.Data),
+	})
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO files (id, data, created_at) VALUES (?, ?, ?) ON CONFLICT(id) DO UPDATE SET data = excluded.data",
+		file.ID, file.Data, file.CreatedAt.UnixMilli())
+	if err != nil {
+		log.WithField("error", err).Error("Failed to store file")
+		return err
+	}
+
+	log.Info("File stored successfully")
+	return nil
+}
+
+func (s *documentStore) GetFile(ctx context.Context, id string) (*core.File, error) {
+	var data []byte
+	var createdAt int64
+	err := s.db.QueryRowContext(ctx, "SELECT data, created_at FROM files WHERE id = ?", id).Scan(&data, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("file with id %s: %w", id, core.ErrFileNotFound)
+		}
+		logrus.WithField("file_id", id).WithField("error", err).Error("Failed to retrieve file")
+		return nil, err
+	}
+
+	return &core.File{ID: id, Data: data, CreatedAt: time.UnixMilli(createdAt)}, nil
+}
+
+func (s *documentStore) ListFiles(ctx context.Context) ([]core.File, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, created_at FROM files")
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list files")
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close file rows")
+		}
+	}()
+
+	var files []core.File
+	for rows.Next() {
+		var file core.File
+		var createdAt int64
+		if err := rows.Scan(&file.ID, &createdAt); err != nil {
+			return nil, err
+		}
+		file.CreatedAt = time.UnixMilli(createdAt)
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+func (s *documentStore) DeleteFile(ctx context.Context, id string) error {
+	log := logrus.WithField("file_id", id)
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM files WHERE id = ?", id)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to delete file")
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("file with id %s: %w", id, core.ErrFileNotFound)
+	}
+
+	log.Info("File deleted successfully")
+	return nil
+}
+
+// ScanScenes passes the data of every document, snapshot and canvas to fn,
+// archived ones included.
+func (s *documentStore) ScanScenes(ctx context.Context, fn func(data []byte) error) error {
+	for _, query := range []string{
+		"SELECT data FROM documents WHERE archived = 0",
+		"SELECT data FROM snapshots",
+		"SELECT data FROM canvases WHERE archived_size IS NULL",
+		"SELECT data FROM templates",
+	} {
+		if err := s.scanData(ctx, query, fn); err != nil {
+			return err
+		}
+	}
+	return s.scanArchived(ctx, fn)
+}
+
+func (s *documentStore) scanData(ctx context.Context, query string, fn func(data []byte) error) error {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close scene rows")
+		}
+	}()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateLibrary stores a new library and returns its id
+func (s *documentStore) CreateLibrary(ctx context.Context, library *core.Library) (string, error) {
+	id := ulid.Make().String()
+	now := int64(ulid.Now())
+	log := logrus.WithFields(logrus.Fields{
+		"library_id": id,
+		"owner_id":   library.OwnerID,
+	})
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO libraries (id, owner_id, owner_name, name, description, public, created_at, updated_at, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, library.OwnerID, library.OwnerName, library.Name, library.Description, library.Public, now, now, []byte(library.Data))
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create library")
+		return "", err
+	}
+
+	log.Info("Library created successfully")
+	return id, nil
+}
+
+// GetLibrary retrieves a library including its data
+func (s *documentStore) GetLibrary(ctx context.Context, id string) (*core.Library, error) {
+	var library core.Library
+	var data []byte
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, owner_id, owner_name, name, description, public, created_at, updated_at, data FROM libraries WHERE id = ?",
+		id).Scan(&library.ID, &library.OwnerID, &library.OwnerName, &library.Name, &library.Description, &library.Public, &library.CreatedAt, &library.UpdatedAt, &data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("library with id %s: %w", id, core.ErrLibraryNotFound)
+		}
+		logrus.WithField("library_id", id).WithField("error", err).Error("Failed to retrieve library")
+		return nil, err
+	}
+	library.Data = data
+
+	return &library, nil
+}
+
+// UpdateLibrary replaces a library's metadata and data
+func (s *documentStore) UpdateLibrary(ctx context.Context, library *core.Library) error {
+	log := logrus.WithField("library_id", library.ID)
+
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE libraries SET name = ?, description = ?, public = ?, data = ?, updated_at = ? WHERE id = ?",
+		library.Name, library.Description, library.Public, []byte(library.Data), int64(ulid.Now()), library.ID)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to update library")
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("library with id %s: %w", library.ID, core.ErrLibraryNotFound)
+	}
+
+	log.Info("Library updated successfully")
+	return nil
+}
+
+// DeleteLibrary deletes a library by id
+func (s *documentStore) DeleteLibrary(ctx context.Context, id string) error {
+	log := logrus.WithField("library_id", id)
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM libraries WHERE id = ?", id)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to delete library")
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("library with id %s: %w", id, core.ErrLibraryNotFound)
+	}
+
+	log.Info("Library deleted successfully")
+	return nil
+}
+
+// ListLibraries lists libraries matching filter without their data
+func (s *documentStore) ListLibraries(ctx context.Context, filter core.LibraryFilter) ([]core.Library, error) {
+	var conditions []string
+	var args []any
+	if filter.OwnerID != "" {
+		conditions = append(conditions, "owner_id = ?")
+		args = append(args, filter.OwnerID)
+	}
+	if filter.PublicOnly {
+		conditions = append(conditions, "public = 1")
+	}
+
+	query := "SELECT id, owner_id, owner_name, name, description, public, created_at, updated_at FROM libraries"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY updated_at DESC, id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list libraries")
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close library rows")
+		}
+	}()
+
+	var libraries []core.Library
+	for rows.Next() {
+		var library core.Library
+		if err := rows.Scan(&library.ID, &library.OwnerID, &library.OwnerName, &library.Name, &library.Description, &library.Public, &library.CreatedAt, &library.UpdatedAt); err != nil {
+			return nil, err
+		}
+		libraries = append(libraries, library)
+	}
+	return libraries, rows.Err()
+}
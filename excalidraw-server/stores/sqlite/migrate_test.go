@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations() failed: %v", err)
+	}
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %d has version %d; versions must be contiguous", i, m.version)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	migrations, _ := loadMigrations()
+	applied, err := Migrate(ctx, db)
+	if err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("Migrate() applied %d, want %d", applied, len(migrations))
+	}
+
+	applied, err = Migrate(ctx, db)
+	if err != nil || applied != 0 {
+		t.Errorf("second Migrate() = %d, %v, want nothing to apply", applied, err)
+	}
+
+	var version int
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		t.Fatalf("reading schema_migrations failed: %v", err)
+	}
+	if version != migrations[len(migrations)-1].version {
+		t.Errorf("schema version = %d, want %d", version, migrations[len(migrations)-1].version)
+	}
+}
+
+func TestMigrate_AdoptsExistingSchema(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	// Databases created before migrations existed
+	if _, err := db.Exec(`CREATE TABLE documents (id TEXT PRIMARY KEY, data BLOB);
+		INSERT INTO documents (id, data) VALUES ('old', 'scene');`); err != nil {
+		t.Fatalf("creating legacy schema failed: %v", err)
+	}
+
+	if _, err := Migrate(ctx, db); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	var data string
+	if err := db.QueryRow("SELECT data FROM documents WHERE id = 'old'").Scan(&data); err != nil || data != "scene" {
+		t.Errorf("existing document = %q, %v", data, err)
+	}
+}
+
+func TestMigrate_RejectsNewerSchema(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	if _, err := Migrate(ctx, db); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'future', 0)"); err != nil {
+		t.Fatalf("inserting future version failed: %v", err)
+	}
+	if _, err := Migrate(ctx, db); err == nil {
+		t.Error("expected an error for a schema newer than the server")
+	}
+}
@@ -0,0 +1,20 @@
+-- Baseline schema. Databases created before migrations existed already have
+-- these tables, hence IF NOT EXISTS.
+CREATE TABLE IF NOT EXISTS documents (id TEXT PRIMARY KEY, data BLOB);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+	id TEXT PRIMARY KEY,
+	room_id TEXT NOT NULL,
+	name TEXT,
+	description TEXT,
+	thumbnail TEXT,
+	created_by TEXT,
+	created_at INTEGER NOT NULL,
+	data BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS room_settings (
+	room_id TEXT PRIMARY KEY,
+	max_snapshots INTEGER DEFAULT 10,
+	auto_save_interval INTEGER DEFAULT 300
+);
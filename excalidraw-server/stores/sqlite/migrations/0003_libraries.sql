@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS libraries (
+	id TEXT PRIMARY KEY,
+	owner_id TEXT NOT NULL,
+	owner_name TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	public INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	data BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS libraries_owner ON libraries (owner_id);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS recordings (
+	id TEXT PRIMARY KEY,
+	room_id TEXT NOT NULL,
+	started_at INTEGER NOT NULL,
+	ended_at INTEGER NOT NULL DEFAULT 0,
+	event_count INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS recordings_room ON recordings (room_id);
+
+CREATE TABLE IF NOT EXISTS recording_events (
+	recording_id TEXT NOT NULL,
+	seq INTEGER NOT NULL,
+	offset_ms INTEGER NOT NULL,
+	socket_id TEXT NOT NULL,
+	payload BLOB NOT NULL,
+	PRIMARY KEY (recording_id, seq)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS canvases (
+	owner_id TEXT NOT NULL,
+	key TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	data BLOB NOT NULL,
+	PRIMARY KEY (owner_id, key)
+);
@@ -0,0 +1,5 @@
+-- Room permissions live alongside the snapshot settings. allowed_users is a
+-- JSON array of user subjects.
+ALTER TABLE room_settings ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE room_settings ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE room_settings ADD COLUMN allowed_users TEXT NOT NULL DEFAULT '[]';
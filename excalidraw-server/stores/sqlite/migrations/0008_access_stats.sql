@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS document_stats (
+	id TEXT PRIMARY KEY,
+	views INTEGER NOT NULL DEFAULT 0,
+	last_accessed INTEGER NOT NULL DEFAULT 0
+);
+
+ALTER TABLE canvases ADD COLUMN views INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE canvases ADD COLUMN last_accessed INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+-- Idle documents and canvases can be moved to a cold archive. Archived
+-- documents keep their row with NULL data; archived canvases keep an empty
+-- blob and remember their size for listings.
+ALTER TABLE documents ADD COLUMN last_accessed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE documents ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE canvases ADD COLUMN archived_size INTEGER;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS crdt_updates (
+	room_id TEXT NOT NULL,
+	seq INTEGER NOT NULL,
+	data BLOB NOT NULL,
+	PRIMARY KEY (room_id, seq)
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS room_states (
+	room_id TEXT PRIMARY KEY,
+	state BLOB NOT NULL,
+	updated_at INTEGER NOT NULL
+);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	login TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL DEFAULT '',
+	email TEXT NOT NULL DEFAULT '',
+	avatar_url TEXT NOT NULL DEFAULT '',
+	seen_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_login ON users(login COLLATE NOCASE);
+
+CREATE TABLE IF NOT EXISTS notifications (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	room_id TEXT NOT NULL DEFAULT '',
+	message_id TEXT NOT NULL DEFAULT '',
+	from_name TEXT NOT NULL DEFAULT '',
+	text TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	read_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);
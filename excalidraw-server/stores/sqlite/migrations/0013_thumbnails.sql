@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS thumbnails (
+	hash TEXT PRIMARY KEY,
+	data BLOB NOT NULL,
+	created_at INTEGER NOT NULL
+);
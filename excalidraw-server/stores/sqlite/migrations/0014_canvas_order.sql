@@ -0,0 +1,2 @@
+-- Canvas listings are paged by update time with the key breaking ties.
+CREATE INDEX IF NOT EXISTS canvases_owner_updated ON canvases (owner_id, updated_at, key);
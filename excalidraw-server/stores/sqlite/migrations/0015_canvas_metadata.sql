@@ -0,0 +1,5 @@
+-- Canvases can be named and tagged without re-uploading them. Tags are a
+-- JSON array; unnamed canvases sort by key.
+ALTER TABLE canvases ADD COLUMN name TEXT NOT NULL DEFAULT '';
+ALTER TABLE canvases ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+CREATE INDEX IF NOT EXISTS canvases_owner_name ON canvases (owner_id, (CASE WHEN name = '' THEN key ELSE name END), key);
@@ -0,0 +1,5 @@
+-- Per-room message size limits live alongside the other room settings; zero
+-- keeps the deployment's limit.
+ALTER TABLE room_settings ADD COLUMN broadcast_max_bytes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE room_settings ADD COLUMN chat_max_bytes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE room_settings ADD COLUMN presence_max_bytes INTEGER NOT NULL DEFAULT 0;
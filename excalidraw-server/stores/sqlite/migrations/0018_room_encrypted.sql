@@ -0,0 +1,3 @@
+-- Rooms that ever relayed end-to-end encrypted frames stay encrypted, so
+-- their scenes are never stored in plaintext once everyone has left.
+ALTER TABLE room_settings ADD COLUMN encrypted INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,195 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// PutUser saves what is known about a user, replacing earlier details
+func (s *documentStore) PutUser(ctx context.Context, user core.User) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO users (id, login, name, email, avatar_url, seen_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET login = excluded.login, name = excluded.name, email = excluded.email,
+			avatar_url = excluded.avatar_url, seen_at = excluded.seen_at`,
+		user.ID, user.Login, user.Name, user.Email, user.AvatarURL, user.SeenAt)
+	if err != nil {
+		logrus.WithField("user_id", user.ID).WithField("error", err).Error("Failed to save user")
+	}
+	return err
+}
+
+// GetUser retrieves a user by id
+func (s *documentStore) GetUser(ctx context.Context, id string) (*core.User, error) {
+	return s.scanUser(s.db.QueryRowContext(ctx,
+		"SELECT id, login, name, email, avatar_url, seen_at FROM users WHERE id = ?", id), "user "+id)
+}
+
+// FindUserByLogin retrieves the user seen last with a login
+func (s *documentStore) FindUserByLogin(ctx context.Context, login string) (*core.User, error) {
+	return s.scanUser(s.db.QueryRowContext(ctx,
+		`SELECT id, login, name, email, avatar_url, seen_at FROM users
+		WHERE login = ? COLLATE NOCASE AND login != '' ORDER BY seen_at DESC LIMIT 1`, login), "user with login "+login)
+}
+
+// GetUsers retrieves the known users among ids
+func (s *documentStore) GetUsers(ctx context.Context, ids []string) (map[string]core.User, error) {
+	users := make(map[string]core.User, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, login, name, email, avatar_url, seen_at FROM users WHERE id IN (?"+strings.Repeat(", ?", len(ids)-1)+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user core.User
+		if err := rows.Scan(&user.ID, &user.Login, &user.Name, &user.Email, &user.AvatarURL, &user.SeenAt); err != nil {
+			return nil, err
+		}
+		users[user.ID] = user
+	}
+	return users, rows.Err()
+}
+
+// ListUsers lists up to limit users, most recently seen first
+func (s *documentStore) ListUsers(ctx context.Context, limit int) ([]core.User, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, login, name, email, avatar_url, seen_at FROM users ORDER BY seen_at DESC, id LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []core.User{}
+	for rows.Next() {
+		var user core.User
+		if err := rows.Scan(&user.ID, &user.Login, &user.Name, &user.Email, &user.AvatarURL, &user.SeenAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// DeleteUser forgets a user
+func (s *documentStore) DeleteUser(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		logrus.WithField("user_id", id).WithField("error", err).Error("Failed to delete user")
+	}
+	return err
+}
+
+func (s *documentStore) scanUser(row *sql.Row, describe string) (*core.User, error) {
+	var user core.User
+	err := row.Scan(&user.ID, &user.Login, &user.Name, &user.Email, &user.AvatarURL, &user.SeenAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s: %w", describe, core.ErrUserNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// AddNotification stores a notification for its user
+func (s *documentStore) AddNotification(ctx context.Context, notification core.Notification) (*core.Notification, error) {
+	notification.ID = ulid.Make().String()
+	notification.CreatedAt = int64(ulid.Now())
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO notifications (id, user_id, kind, room_id, message_id, from_name, text, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		notification.ID, notification.UserID, notification.Kind, notification.RoomID,
+		notification.MessageID, notification.From, notification.Text, notification.CreatedAt)
+	if err != nil {
+		logrus.WithField("user_id", notification.UserID).WithField("error", err).Error("Failed to save notification")
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// ListNotifications lists a user's notifications, newest first
+func (s *documentStore) ListNotifications(ctx context.Context, userID string, unreadOnly bool, limit int) ([]core.Notification, error) {
+	query := `SELECT id, user_id, kind, room_id, message_id, from_name, text, created_at, read_at
+		FROM notifications WHERE user_id = ?`
+	if unreadOnly {
+		query += " AND read_at = 0"
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list notifications")
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close notification rows")
+		}
+	}()
+
+	notifications := []core.Notification{}
+	for rows.Next() {
+		var notification core.Notification
+		if err := rows.Scan(&notification.ID, &notification.UserID, &notification.Kind, &notification.RoomID,
+			&notification.MessageID, &notification.From, &notification.Text, &notification.CreatedAt,
+			&notification.ReadAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+// CountUnreadNotifications counts a user's unread notifications
+func (s *documentStore) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	var unread int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at = 0", userID).Scan(&unread)
+	return unread, err
+}
+
+// MarkNotificationsRead marks some or all of a user's notifications read
+func (s *documentStore) MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error) {
+	query := "UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at = 0"
+	args := []any{int64(ulid.Now()), userID}
+	if len(ids) > 0 {
+		query += " AND id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithField("user_id", userID).WithField("error", err).Error("Failed to mark notifications read")
+		return 0, err
+	}
+	marked, err := result.RowsAffected()
+	return int(marked), err
+}
+
+// DeleteNotifications deletes all of a user's notifications
+func (s *documentStore) DeleteNotifications(ctx context.Context, userID string) (int, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM notifications WHERE user_id = ?", userID)
+	if err != nil {
+		logrus.WithField("user_id", userID).WithField("error", err).Error("Failed to delete notifications")
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options tunes how the sqlite store handles concurrent writers.
+type Options struct {
+	// JournalMode is applied to every connection; WAL lets readers proceed
+	// while a write is in progress. Empty keeps the database's mode.
+	JournalMode string
+	// BusyTimeout is how long a connection waits for a lock before failing
+	// with "database is locked".
+	BusyTimeout time.Duration
+	// BatchSize is the most inserts committed in one transaction; 0 or 1
+	// writes every insert in its own transaction.
+	BatchSize int
+	// BatchWait is how long the first insert of a batch waits for others.
+	BatchWait time.Duration
+}
+
+// DefaultOptions enables WAL with a 5s busy timeout and no write batching.
+func DefaultOptions() Options {
+	return Options{
+		JournalMode: "WAL",
+		BusyTimeout: 5 * time.Second,
+		BatchWait:   5 * time.Millisecond,
+	}
+}
+
+// OptionsFromEnv reads SQLITE_JOURNAL_MODE, SQLITE_BUSY_TIMEOUT,
+// SQLITE_WRITE_BATCH and SQLITE_WRITE_BATCH_WAIT on top of DefaultOptions.
+func OptionsFromEnv() (Options, error) {
+	opts := DefaultOptions()
+	if value, ok := os.LookupEnv("SQLITE_JOURNAL_MODE"); ok {
+		opts.JournalMode = value
+	}
+	if value := os.Getenv("SQLITE_BUSY_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return Options{}, fmt.Errorf("invalid SQLITE_BUSY_TIMEOUT %q", value)
+		}
+		opts.BusyTimeout = timeout
+	}
+	if value := os.Getenv("SQLITE_WRITE_BATCH"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return Options{}, fmt.Errorf("invalid SQLITE_WRITE_BATCH %q", value)
+		}
+		opts.BatchSize = size
+	}
+	if value := os.Getenv("SQLITE_WRITE_BATCH_WAIT"); value != "" {
+		wait, err := time.ParseDuration(value)
+		if err != nil || wait < 0 {
+			return Options{}, fmt.Errorf("invalid SQLITE_WRITE_BATCH_WAIT %q", value)
+		}
+		opts.BatchWait = wait
+	}
+	return opts, nil
+}
+
+// dataSourceWithOptions adds the journal mode, busy timeout and transaction
+// locking to a data source name unless it sets them itself.
+func dataSourceWithOptions(dataSourceName string, opts Options) string {
+	var params []string
+	if opts.JournalMode != "" && !strings.Contains(dataSourceName, "_journal") {
+		params = append(params, "_journal_mode="+opts.JournalMode)
+	}
+	if opts.BusyTimeout > 0 && !strings.Contains(dataSourceName, "_busy_timeout") && !strings.Contains(dataSourceName, "_timeout") {
+		params = append(params, "_busy_timeout="+strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	}
+	// Take the write lock when a transaction begins so that read-then-write
+	// transactions wait out the busy timeout instead of failing on upgrade
+	if !strings.Contains(dataSourceName, "_txlock") {
+		params = append(params, "_txlock=immediate")
+	}
+	if len(params) == 0 {
+		return dataSourceName
+	}
+
+	separator := "?"
+	if strings.Contains(dataSourceName, "?") {
+		separator = "&"
+	}
+	return dataSourceName + separator + strings.Join(params, "&")
+}
@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDataSourceWithOptions(t *testing.T) {
+	opts := Options{JournalMode: "WAL", BusyTimeout: 2 * time.Second}
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"./excalidraw.db", "./excalidraw.db?_journal_mode=WAL&_busy_timeout=2000&_txlock=immediate"},
+		{"file:test.db?cache=shared", "file:test.db?cache=shared&_journal_mode=WAL&_busy_timeout=2000&_txlock=immediate"},
+		{"test.db?_journal_mode=DELETE&_busy_timeout=1&_txlock=deferred", "test.db?_journal_mode=DELETE&_busy_timeout=1&_txlock=deferred"},
+	}
+	for _, tt := range tests {
+		if got := dataSourceWithOptions(tt.dsn, opts); got != tt.want {
+			t.Errorf("dataSourceWithOptions(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("SQLITE_JOURNAL_MODE", "")
+	t.Setenv("SQLITE_BUSY_TIMEOUT", "10s")
+	t.Setenv("SQLITE_WRITE_BATCH", "64")
+	t.Setenv("SQLITE_WRITE_BATCH_WAIT", "2ms")
+
+	opts, err := OptionsFromEnv()
+	if err != nil {
+		t.Fatalf("OptionsFromEnv() failed: %v", err)
+	}
+	want := Options{BusyTimeout: 10 * time.Second, BatchSize: 64, BatchWait: 2 * time.Millisecond}
+	if opts != want {
+		t.Errorf("OptionsFromEnv() = %+v, want %+v", opts, want)
+	}
+
+	t.Setenv("SQLITE_WRITE_BATCH", "-1")
+	if _, err := OptionsFromEnv(); err == nil {
+		t.Error("expected an error for a negative batch size")
+	}
+}
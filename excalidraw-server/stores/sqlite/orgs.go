@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateOrg creates an organization with ownerID as its first admin
+func (s *documentStore) CreateOrg(ctx context.Context, name, ownerID string) (*core.Org, error) {
+	org := core.Org{ID: ulid.Make().String(), Name: name, CreatedAt: int64(ulid.Now())}
+	log := logrus.WithFields(logrus.Fields{
+		"org_id":   org.ID,
+		"owner_id": ownerID,
+	})
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO orgs (id, name, created_at) VALUES (?, ?, ?)",
+		org.ID, org.Name, org.CreatedAt)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create organization")
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO org_members (org_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)",
+		org.ID, ownerID, core.OrgRoleAdmin, org.CreatedAt)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create organization")
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	log.Info("Organization created successfully")
+	return &org, nil
+}
+
+// GetOrg retrieves an organization
+func (s *documentStore) GetOrg(ctx context.Context, id string) (*core.Org, error) {
+	org := core.Org{ID: id}
+	err := s.db.QueryRowContext(ctx, "SELECT name, created_at FROM orgs WHERE id = ?", id).
+		Scan(&org.Name, &org.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("organization with id %s: %w", id, core.ErrOrgNotFound)
+		}
+		logrus.WithField("org_id", id).WithField("error", err).Error("Failed to retrieve organization")
+		return nil, err
+	}
+	return &org, nil
+}
+
+// DeleteOrg deletes an organization and its memberships
+func (s *documentStore) DeleteOrg(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM orgs WHERE id = ?", id)
+	if err != nil {
+		logrus.WithField("org_id", id).WithField("error", err).Error("Failed to delete organization")
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("organization with id %s: %w", id, core.ErrOrgNotFound)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM org_members WHERE org_id = ?", id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logrus.WithField("org_id", id).Info("Organization deleted successfully")
+	return nil
+}
+
+// ListOrgs lists the organizations a user belongs to, with their role
+func (s *documentStore) ListOrgs(ctx context.Context, userID string) ([]core.Org, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT o.id, o.name, o.created_at, m.role FROM orgs o
+		JOIN org_members m ON m.org_id = o.id
+		WHERE m.user_id = ? ORDER BY o.name ASC, o.id ASC`,
+		userID)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list organizations")
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close organization rows")
+		}
+	}()
+
+	var orgs []core.Org
+	for rows.Next() {
+		var org core.Org
+		if err := rows.Scan(&org.ID, &org.Name, &org.CreatedAt, &org.Role); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+// ListOrgMembers lists an organization's members, oldest first
+func (s *documentStore) ListOrgMembers(ctx context.Context, orgID string) ([]core.OrgMember, error) {
+	if _, err := s.GetOrg(ctx, orgID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT user_id, role, joined_at FROM org_members WHERE org_id = ? ORDER BY joined_at ASC, user_id ASC",
+		orgID)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list organization members")
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close organization member rows")
+		}
+	}()
+
+	members := []core.OrgMember{}
+	for rows.Next() {
+		var member core.OrgMember
+		if err := rows.Scan(&member.UserID, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// GetOrgMember retrieves a user's membership of an organization
+func (s *documentStore) GetOrgMember(ctx context.Context, orgID, userID string) (*core.OrgMember, error) {
+	member := core.OrgMember{UserID: userID}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT role, joined_at FROM org_members WHERE org_id = ? AND user_id = ?",
+		orgID, userID).Scan(&member.Role, &member.JoinedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %s in organization %s: %w", userID, orgID, core.ErrNotMember)
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+// PutOrgMember adds a member or changes their role, keeping the join time
+func (s *documentStore) PutOrgMember(ctx context.Context, orgID string, member core.OrgMember) error {
+	if _, err := s.GetOrg(ctx, orgID); err != nil {
+		return err
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"org_id":  orgID,
+		"user_id": member.UserID,
+		"role":    member.Role,
+	})
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO org_members (org_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(org_id, user_id) DO UPDATE SET role = excluded.role`,
+		orgID, member.UserID, member.Role, int64(ulid.Now()))
+	if err != nil {
+		log.WithField("error", err).Error("Failed to save organization member")
+		return err
+	}
+
+	log.Info("Organization member saved successfully")
+	return nil
+}
+
+// RemoveOrgMember removes a user from an organization
+func (s *documentStore) RemoveOrgMember(ctx context.Context, orgID, userID string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM org_members WHERE org_id = ? AND user_id = ?", orgID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("user %s in organization %s: %w", userID, orgID, core.ErrNotMember)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"org_id":  orgID,
+		"user_id": userID,
+	}).Info("Organization member removed successfully")
+	return nil
+}
@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"excalidraw-server/core"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GetRoomPermissions retrieves a room's permissions from its settings
+func (s *documentStore) GetRoomPermissions(ctx context.Context, roomID string) (*core.RoomPermissions, error) {
+	permissions := core.RoomPermissions{RoomID: roomID}
+	var allowed string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT owner, visibility, allowed_users FROM room_settings WHERE room_id = ?",
+		roomID).Scan(&permissions.Owner, &permissions.Visibility, &allowed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &core.RoomPermissions{RoomID: roomID, Visibility: core.RoomPublic, AllowedUsers: []string{}}, nil
+		}
+		logrus.WithField("room_id", roomID).WithField("error", err).Error("Failed to retrieve room permissions")
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(allowed), &permissions.AllowedUsers); err != nil {
+		return nil, err
+	}
+	if permissions.AllowedUsers == nil {
+		permissions.AllowedUsers = []string{}
+	}
+
+	return &permissions, nil
+}
+
+// PutRoomPermissions updates a room's permissions, keeping its snapshot
+// settings
+func (s *documentStore) PutRoomPermissions(ctx context.Context, permissions *core.RoomPermissions) error {
+	log := logrus.WithFields(logrus.Fields{
+		"room_id":    permissions.RoomID,
+		"owner":      permissions.Owner,
+		"visibility": permissions.Visibility,
+	})
+
+	allowed := permissions.AllowedUsers
+	if allowed == nil {
+		allowed = []string{}
+	}
+	data, err := json.Marshal(allowed)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO room_settings (room_id, owner, visibility, allowed_users) VALUES (?, ?, ?, ?)
+		ON CONFLICT(room_id) DO UPDATE SET owner = excluded.owner, visibility = excluded.visibility, allowed_users = excluded.allowed_users`,
+		permissions.RoomID, permissions.Owner, permissions.Visibility, string(data))
+	if err != nil {
+		log.WithField("error", err).Error("Failed to update room permissions")
+		return err
+	}
+
+	log.Info("Room permissions updated successfully")
+	return nil
+}
+
+// ListUserRoomPermissions lists the permissions of the rooms a user owns or
+// is allowed in
+func (s *documentStore) ListUserRoomPermissions(ctx context.Context, userID string) ([]core.RoomPermissions, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT room_id, owner, visibility, allowed_users FROM room_settings
+		WHERE owner = ? OR EXISTS (SELECT 1 FROM json_each(room_settings.allowed_users) WHERE value = ?)
+		ORDER BY room_id`, userID, userID)
+	if err != nil {
+		logrus.WithField("user_id", userID).WithField("error", err).Error("Failed to list user's room permissions")
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close room permission rows")
+		}
+	}()
+
+	list := []core.RoomPermissions{}
+	for rows.Next() {
+		var permissions core.RoomPermissions
+		var allowed string
+		if err := rows.Scan(&permissions.RoomID, &permissions.Owner, &permissions.Visibility, &allowed); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(allowed), &permissions.AllowedUsers); err != nil {
+			return nil, err
+		}
+		if permissions.AllowedUsers == nil {
+			permissions.AllowedUsers = []string{}
+		}
+		list = append(list, permissions)
+	}
+	return list, rows.Err()
+}
+
+// GetRoomMessageLimits retrieves a room's message limits from its settings
+func (s *documentStore) GetRoomMessageLimits(ctx context.Context, roomID string) (core.RoomMessageLimits, error) {
+	var limits core.RoomMessageLimits
+	err := s.db.QueryRowContext(ctx,
+		"SELECT broadcast_max_bytes, chat_max_bytes, presence_max_bytes FROM room_settings WHERE room_id = ?",
+		roomID).Scan(&limits.Broadcast, &limits.Chat, &limits.Presence)
+	if err == sql.ErrNoRows {
+		return core.RoomMessageLimits{}, nil
+	}
+	if err != nil {
+		logrus.WithField("room_id", roomID).WithField("error", err).Error("Failed to retrieve room message limits")
+		return core.RoomMessageLimits{}, err
+	}
+	return limits, nil
+}
+
+// PutRoomMessageLimits updates a room's message limits, keeping its other
+// settings
+func (s *documentStore) PutRoomMessageLimits(ctx context.Context, roomID string, limits core.RoomMessageLimits) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO room_settings (room_id, broadcast_max_bytes, chat_max_bytes, presence_max_bytes) VALUES (?, ?, ?, ?)
+		ON CONFLICT(room_id) DO UPDATE SET broadcast_max_bytes = excluded.broadcast_max_bytes,
+			chat_max_bytes = excluded.chat_max_bytes, presence_max_bytes = excluded.presence_max_bytes`,
+		roomID, limits.Broadcast, limits.Chat, limits.Presence)
+	if err != nil {
+		logrus.WithField("room_id", roomID).WithField("error", err).Error("Failed to update room message limits")
+		return err
+	}
+	return nil
+}
+
+// IsRoomEncrypted reports whether a room's settings mark it encrypted
+func (s *documentStore) IsRoomEncrypted(ctx context.Context, roomID string) (bool, error) {
+	var encrypted bool
+	err := s.db.QueryRowContext(ctx, "SELECT encrypted FROM room_settings WHERE room_id = ?", roomID).Scan(&encrypted)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		logrus.WithField("room_id", roomID).WithField("error", err).Error("Failed to retrieve room mode")
+		return false, err
+	}
+	return encrypted, nil
+}
+
+// MarkRoomEncrypted marks a room encrypted in its settings, keeping the rest
+func (s *documentStore) MarkRoomEncrypted(ctx context.Context, roomID string) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO room_settings (room_id, encrypted) VALUES (?, 1)
+		ON CONFLICT(room_id) DO UPDATE SET encrypted = 1`, roomID)
+	if err != nil {
+		logrus.WithField("room_id", roomID).WithField("error", err).Error("Failed to mark room encrypted")
+		return err
+	}
+	return nil
+}
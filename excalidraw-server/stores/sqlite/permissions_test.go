@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"context"
+	"excalidraw-server/core"
+	"reflect"
+	"testing"
+)
+
+func TestRoomPermissions(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	defaults, err := store.GetRoomPermissions(ctx, "room-1")
+	if err != nil {
+		t.Fatalf("GetRoomPermissions() failed: %v", err)
+	}
+	if defaults.Visibility != core.RoomPublic || defaults.Owner != "" || len(defaults.AllowedUsers) != 0 {
+		t.Errorf("default permissions = %+v, want public without owner", defaults)
+	}
+
+	if err := store.UpdateRoomSettings(ctx, "room-1", 5, 60); err != nil {
+		t.Fatalf("UpdateRoomSettings() failed: %v", err)
+	}
+	err = store.PutRoomPermissions(ctx, &core.RoomPermissions{
+		RoomID:       "room-1",
+		Owner:        "alice",
+		Visibility:   core.RoomPrivate,
+		AllowedUsers: []string{"bob"},
+	})
+	if err != nil {
+		t.Fatalf("PutRoomPermissions() failed: %v", err)
+	}
+
+	permissions, err := store.GetRoomPermissions(ctx, "room-1")
+	if err != nil {
+		t.Fatalf("GetRoomPermissions() failed: %v", err)
+	}
+	if permissions.Owner != "alice" || permissions.Visibility != core.RoomPrivate ||
+		len(permissions.AllowedUsers) != 1 || permissions.AllowedUsers[0] != "bob" {
+		t.Errorf("GetRoomPermissions() = %+v", permissions)
+	}
+
+	// Permissions and snapshot settings share a row without clobbering
+	// each other
+	settings, err := store.GetRoomSettings(ctx, "room-1")
+	if err != nil {
+		t.Fatalf("GetRoomSettings() failed: %v", err)
+	}
+	if settings.MaxSnapshots != 5 || settings.AutoSaveInterval != 60 {
+		t.Errorf("settings after PutRoomPermissions() = %+v", settings)
+	}
+	if err := store.UpdateRoomSettings(ctx, "room-1", 20, 60); err != nil {
+		t.Fatalf("UpdateRoomSettings() failed: %v", err)
+	}
+	if permissions, _ := store.GetRoomPermissions(ctx, "room-1"); permissions.Owner != "alice" {
+		t.Errorf("UpdateRoomSettings() cleared the owner: %+v", permissions)
+	}
+
+	// A fresh room gets default snapshot settings
+	if err := store.PutRoomPermissions(ctx, &core.RoomPermissions{RoomID: "room-2", Visibility: core.RoomLinkOnly}); err != nil {
+		t.Fatalf("PutRoomPermissions() failed: %v", err)
+	}
+	if settings, _ := store.GetRoomSettings(ctx, "room-2"); settings.MaxSnapshots != 10 {
+		t.Errorf("settings for a new room = %+v, want defaults", settings)
+	}
+}
+
+func TestListUserRoomPermissions(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	for _, permissions := range []core.RoomPermissions{
+		{RoomID: "owned", Owner: "alice", Visibility: core.RoomPrivate, AllowedUsers: []string{"bob"}},
+		{RoomID: "allowed", Owner: "bob", Visibility: core.RoomPrivate, AllowedUsers: []string{"carol", "alice"}},
+		{RoomID: "other", Owner: "bob", Visibility: core.RoomPublic, AllowedUsers: []string{"alicia"}},
+	} {
+		if err := store.PutRoomPermissions(ctx, &permissions); err != nil {
+			t.Fatalf("PutRoomPermissions() failed: %v", err)
+		}
+	}
+	if err := store.UpdateRoomSettings(ctx, "settings-only", 5, 60); err != nil {
+		t.Fatalf("UpdateRoomSettings() failed: %v", err)
+	}
+
+	list, err := store.ListUserRoomPermissions(ctx, "alice")
+	if err != nil {
+		t.Fatalf("ListUserRoomPermissions() failed: %v", err)
+	}
+	var rooms []string
+	for _, permissions := range list {
+		rooms = append(rooms, permissions.RoomID)
+	}
+	if !reflect.DeepEqual(rooms, []string{"allowed", "owned"}) {
+		t.Errorf("ListUserRoomPermissions() = %v, want allowed and owned", rooms)
+	}
+	if len(list[0].AllowedUsers) != 2 {
+		t.Errorf("ListUserRoomPermissions() allowed users = %v", list[0].AllowedUsers)
+	}
+
+	if list, err := store.ListUserRoomPermissions(ctx, "nobody"); err != nil || len(list) != 0 {
+		t.Errorf("ListUserRoomPermissions() of a stranger = %v, %v, want none", list, err)
+	}
+}
+
+func TestRoomMessageLimits(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	if limits, err := store.GetRoomMessageLimits(ctx, "room-1"); err != nil || limits != (core.RoomMessageLimits{}) {
+		t.Fatalf("GetRoomMessageLimits() of a new room = %+v, %v", limits, err)
+	}
+	if err := store.PutRoomPermissions(ctx, &core.RoomPermissions{RoomID: "room-1", Owner: "alice", Visibility: core.RoomPrivate}); err != nil {
+		t.Fatalf("PutRoomPermissions() failed: %v", err)
+	}
+	want := core.RoomMessageLimits{Broadcast: 1024, Chat: 64}
+	if err := store.PutRoomMessageLimits(ctx, "room-1", want); err != nil {
+		t.Fatalf("PutRoomMessageLimits() failed: %v", err)
+	}
+	if limits, err := store.GetRoomMessageLimits(ctx, "room-1"); err != nil || limits != want {
+		t.Errorf("GetRoomMessageLimits() = %+v, %v, want %+v", limits, err, want)
+	}
+	// Limits and permissions share a row without clobbering each other
+	if permissions, _ := store.GetRoomPermissions(ctx, "room-1"); permissions.Owner != "alice" {
+		t.Errorf("PutRoomMessageLimits() cleared the owner: %+v", permissions)
+	}
+}
+
+func TestRoomEncrypted(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	if encrypted, err := store.IsRoomEncrypted(ctx, "room-1"); err != nil || encrypted {
+		t.Fatalf("IsRoomEncrypted() of a new room = %v, %v", encrypted, err)
+	}
+	if err := store.PutRoomPermissions(ctx, &core.RoomPermissions{RoomID: "room-1", Owner: "alice", Visibility: core.RoomPrivate}); err != nil {
+		t.Fatalf("PutRoomPermissions() failed: %v", err)
+	}
+	if err := store.MarkRoomEncrypted(ctx, "room-1"); err != nil {
+		t.Fatalf("MarkRoomEncrypted() failed: %v", err)
+	}
+	if encrypted, err := store.IsRoomEncrypted(ctx, "room-1"); err != nil || !encrypted {
+		t.Errorf("IsRoomEncrypted() = %v, %v, want true", encrypted, err)
+	}
+	if permissions, _ := store.GetRoomPermissions(ctx, "room-1"); permissions.Owner != "alice" {
+		t.Errorf("MarkRoomEncrypted() cleared the owner: %+v", permissions)
+	}
+}
@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateRecording starts a new recording for a room
+func (s *documentStore) CreateRecording(ctx context.Context, roomID string, startedAt time.Time) (string, error) {
+	id := ulid.Make().String()
+	log := logrus.WithFields(logrus.Fields{
+		"recording_id": id,
+		"room_id":      roomID,
+	})
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO recordings (id, room_id, started_at, ended_at, event_count) VALUES (?, ?, ?, 0, 0)",
+		id, roomID, startedAt.UnixMilli())
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create recording")
+		return "", err
+	}
+
+	log.Info("Recording created successfully")
+	return id, nil
+}
+
+// AppendRecordingEvent adds an event to the end of a recording
+func (s *documentStore) AppendRecordingEvent(ctx context.Context, id string, event core.RecordingEvent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var seq int
+	err = tx.QueryRowContext(ctx, "SELECT event_count FROM recordings WHERE id = ?", id).Scan(&seq)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("recording with id %s: %w", id, core.ErrRecordingNotFound)
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO recording_events (recording_id, seq, offset_ms, socket_id, payload) VALUES (?, ?, ?, ?, ?)",
+		id, seq, event.Offset, event.SocketID, []byte(event.Payload))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE recordings SET event_count = event_count + 1 WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FinishRecording marks a recording as ended
+func (s *documentStore) FinishRecording(ctx context.Context, id string, endedAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE recordings SET ended_at = ? WHERE id = ?", endedAt.UnixMilli(), id)
+	if err != nil {
+		logrus.WithField("recording_id", id).WithField("error", err).Error("Failed to finish recording")
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("recording with id %s: %w", id, core.ErrRecordingNotFound)
+	}
+
+	logrus.WithField("recording_id", id).Info("Recording finished")
+	return nil
+}
+
+// GetRecording retrieves a recording's metadata
+func (s *documentStore) GetRecording(ctx context.Context, id string) (*core.Recording, error) {
+	var recording core.Recording
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, room_id, started_at, ended_at, event_count FROM recordings WHERE id = ?",
+		id).Scan(&recording.ID, &recording.RoomID, &recording.StartedAt, &recording.EndedAt, &recording.EventCount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("recording with id %s: %w", id, core.ErrRecordingNotFound)
+		}
+		return nil, err
+	}
+	return &recording, nil
+}
+
+// ListRecordings lists the recordings of a room, newest first
+func (s *documentStore) ListRecordings(ctx context.Context, roomID string) ([]core.Recording, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, room_id, started_at, ended_at, event_count FROM recordings WHERE room_id = ? ORDER BY started_at DESC, id DESC",
+		roomID)
+	if err != nil {
+		logrus.WithField("room_id", roomID).WithField("error", err).Error("Failed to list recordings")
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close recording rows")
+		}
+	}()
+
+	var recordings []core.Recording
+	for rows.Next() {
+		var recording core.Recording
+		if err := rows.Scan(&recording.ID, &recording.RoomID, &recording.StartedAt, &recording.EndedAt, &recording.EventCount); err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, recording)
+	}
+	return recordings, rows.Err()
+}
+
+// GetRecordingEvents returns every event of a recording in order
+func (s *documentStore) GetRecordingEvents(ctx context.Context, id string) ([]core.RecordingEvent, error) {
+	if _, err := s.GetRecording(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT offset_ms, socket_id, payload FROM recording_events WHERE recording_id = ? ORDER BY seq",
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close recording event rows")
+		}
+	}()
+
+	events := []core.RecordingEvent{}
+	for rows.Next() {
+		var event core.RecordingEvent
+		var payload []byte
+		if err := rows.Scan(&event.Offset, &event.SocketID, &payload); err != nil {
+			return nil, err
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ListInactiveRooms returns the rooms with snapshots or a hibernated state
+// whose newest snapshot and state are older than before
+func (s *documentStore) ListInactiveRooms(ctx context.Context, before time.Time) ([]core.RoomActivity, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT room_id, MAX(last_active), SUM(snapshots), SUM(autosaves), MAX(has_state) FROM (
+			SELECT room_id, MAX(created_at) AS last_active,
+				SUM(CASE WHEN description = ? THEN 0 ELSE 1 END) AS snapshots,
+				SUM(CASE WHEN description = ? THEN 1 ELSE 0 END) AS autosaves,
+				0 AS has_state
+			FROM snapshots GROUP BY room_id
+			UNION ALL
+			SELECT room_id, updated_at, 0, 0, 1 FROM room_states
+		)
+		GROUP BY room_id HAVING MAX(last_active) < ?
+		ORDER BY MAX(last_active), room_id`,
+		core.AutosaveDescription, core.AutosaveDescription, before.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close rows")
+		}
+	}()
+
+	var rooms []core.RoomActivity
+	for rows.Next() {
+		var room core.RoomActivity
+		if err := rows.Scan(&room.RoomID, &room.LastActive, &room.Snapshots, &room.Autosaves, &room.HasState); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+// DeleteRoomSnapshots deletes a room's autosaves, or its other snapshots
+func (s *documentStore) DeleteRoomSnapshots(ctx context.Context, roomID string, autosaves bool) (int, error) {
+	comparison := "IS NOT"
+	if autosaves {
+		comparison = "IS"
+	}
+	var deleted int64
+	err := s.write(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			"DELETE FROM snapshots WHERE room_id = ? AND description "+comparison+" ?",
+			roomID, core.AutosaveDescription)
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		return err
+	})
+	return int(deleted), err
+}
+
+// ReplaceRoomState replaces the saved state of a hibernated room, keeping
+// when it was saved
+func (s *documentStore) ReplaceRoomState(ctx context.Context, roomID string, state []byte) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE room_states SET state = ? WHERE room_id = ?", state, roomID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("room %s: %w", roomID, core.ErrRoomStateNotFound)
+	}
+	return nil
+}
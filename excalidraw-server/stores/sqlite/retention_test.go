@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"testing"
+	"time"
+)
+
+func TestRoomRetention(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+
+	for _, snapshot := range []struct{ room, description string }{
+		{"old", "Saved by hand"},
+		{"old", core.AutosaveDescription},
+		{"old", core.AutosaveDescription},
+		{"fresh", core.AutosaveDescription},
+	} {
+		if _, err := store.CreateSnapshot(ctx, snapshot.room, "name", snapshot.description, "", "", []byte("{}")); err != nil {
+			t.Fatalf("CreateSnapshot() error = %v", err)
+		}
+	}
+	if err := store.PutRoomState(ctx, "sleeping", []byte("state")); err != nil {
+		t.Fatalf("PutRoomState() error = %v", err)
+	}
+	// make everything but the fresh room a day old
+	dayAgo := time.Now().Add(-24 * time.Hour).UnixMilli()
+	if _, err := store.db.Exec("UPDATE snapshots SET created_at = ? WHERE room_id = 'old'", dayAgo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.db.Exec("UPDATE room_states SET updated_at = ?", dayAgo+1); err != nil {
+		t.Fatal(err)
+	}
+
+	rooms, err := store.ListInactiveRooms(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("ListInactiveRooms() error = %v", err)
+	}
+	want := []core.RoomActivity{
+		{RoomID: "old", LastActive: dayAgo, Snapshots: 1, Autosaves: 2},
+		{RoomID: "sleeping", LastActive: dayAgo + 1, HasState: true},
+	}
+	if len(rooms) != len(want) {
+		t.Fatalf("ListInactiveRooms() = %+v, want %+v", rooms, want)
+	}
+	for i := range want {
+		if rooms[i] != want[i] {
+			t.Errorf("room %d = %+v, want %+v", i, rooms[i], want[i])
+		}
+	}
+
+	if deleted, err := store.DeleteRoomSnapshots(ctx, "old", true); err != nil || deleted != 2 {
+		t.Errorf("DeleteRoomSnapshots(autosaves) = %d, %v, want 2", deleted, err)
+	}
+	if deleted, err := store.DeleteRoomSnapshots(ctx, "old", false); err != nil || deleted != 1 {
+		t.Errorf("DeleteRoomSnapshots(snapshots) = %d, %v, want 1", deleted, err)
+	}
+
+	if err := store.ReplaceRoomState(ctx, "sleeping", []byte("purged")); err != nil {
+		t.Fatalf("ReplaceRoomState() error = %v", err)
+	}
+	if state, _ := store.GetRoomState(ctx, "sleeping"); string(state) != "purged" {
+		t.Errorf("state = %q, want purged", state)
+	}
+	rooms, _ = store.ListInactiveRooms(ctx, time.Now().Add(-time.Hour))
+	if len(rooms) != 1 || rooms[0].LastActive != dayAgo+1 {
+		t.Errorf("replacing the state counted as activity: %+v", rooms)
+	}
+	if err := store.ReplaceRoomState(ctx, "missing", []byte("x")); !errors.Is(err, core.ErrRoomStateNotFound) {
+		t.Errorf("ReplaceRoomState() of a missing room = %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PutRoomState saves the state of a hibernated room, replacing any earlier one
+func (s *documentStore) PutRoomState(ctx context.Context, roomID string, state []byte) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO room_states (room_id, state, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(room_id) DO UPDATE SET state = excluded.state, updated_at = excluded.updated_at`,
+		roomID, state, time.Now().UnixMilli())
+	return err
+}
+
+// GetRoomState returns the saved state of a hibernated room
+func (s *documentStore) GetRoomState(ctx context.Context, roomID string) ([]byte, error) {
+	var state []byte
+	err := s.db.QueryRowContext(ctx, "SELECT state FROM room_states WHERE room_id = ?", roomID).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("room %s: %w", roomID, core.ErrRoomStateNotFound)
+	}
+	return state, err
+}
+
+// DeleteRoomState forgets the saved state of a room that woke up
+func (s *documentStore) DeleteRoomState(ctx context.Context, roomID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM room_states WHERE room_id = ?", roomID)
+	return err
+}
+
+// ListRoomStates lists the rooms with saved state
+func (s *documentStore) ListRoomStates(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT room_id FROM room_states ORDER BY room_id")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close room state rows")
+		}
+	}()
+
+	roomIDs := []string{}
+	for rows.Next() {
+		var roomID string
+		if err := rows.Scan(&roomID); err != nil {
+			return nil, err
+		}
+		roomIDs = append(roomIDs, roomID)
+	}
+	return roomIDs, rows.Err()
+}
@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// RecordDocumentView counts a view of a shared document
+func (s *documentStore) RecordDocumentView(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO document_stats (id, views, last_accessed) SELECT id, 1, ? FROM documents WHERE id = ?
+		ON CONFLICT(id) DO UPDATE SET views = views + 1, last_accessed = excluded.last_accessed`,
+		int64(ulid.Now()), id)
+	if err != nil {
+		logrus.WithField("document_id", id).WithField("error", err).Error("Failed to record document view")
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("document with id %s: %w", id, core.ErrDocumentNotFound)
+	}
+	return nil
+}
+
+// GetDocumentStats retrieves the view count of a shared document
+func (s *documentStore) GetDocumentStats(ctx context.Context, id string) (*core.AccessStats, error) {
+	var stats core.AccessStats
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(s.views, 0), COALESCE(s.last_accessed, 0) FROM documents d
+		LEFT JOIN document_stats s ON s.id = d.id WHERE d.id = ?`,
+		id).Scan(&stats.Views, &stats.LastAccessed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("document with id %s: %w", id, core.ErrDocumentNotFound)
+		}
+		logrus.WithField("document_id", id).WithField("error", err).Error("Failed to retrieve document stats")
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// StorageUsage counts the stored documents, canvases, snapshots, files and
+// users, and measures the database file
+func (s *documentStore) StorageUsage(ctx context.Context) (*core.StorageUsage, error) {
+	var usage core.StorageUsage
+	err := s.db.QueryRowContext(ctx,
+		`SELECT (SELECT COUNT(*) FROM documents), (SELECT COUNT(*) FROM canvases),
+			(SELECT COUNT(*) FROM snapshots), (SELECT COUNT(*) FROM files), (SELECT COUNT(*) FROM users),
+			(SELECT page_count FROM pragma_page_count()) * (SELECT page_size FROM pragma_page_size())`).
+		Scan(&usage.Documents, &usage.Canvases, &usage.Snapshots, &usage.Files, &usage.Users, &usage.Bytes)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to measure storage usage")
+		return nil, err
+	}
+	return &usage, nil
+}
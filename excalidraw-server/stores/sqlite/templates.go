@@ -0,0 +1,125 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateTemplate stores a new template and returns its id
+func (s *documentStore) CreateTemplate(ctx context.Context, template *core.Template) (string, error) {
+	id := ulid.Make().String()
+	now := int64(ulid.Now())
+	log := logrus.WithField("template_id", id)
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO templates (id, name, description, category, created_at, updated_at, data) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id, template.Name, template.Description, template.Category, now, now, []byte(template.Data))
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create template")
+		return "", err
+	}
+
+	log.Info("Template created successfully")
+	return id, nil
+}
+
+// GetTemplate retrieves a template including its scene
+func (s *documentStore) GetTemplate(ctx context.Context, id string) (*core.Template, error) {
+	var template core.Template
+	var data []byte
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, name, description, category, created_at, updated_at, data FROM templates WHERE id = ?",
+		id).Scan(&template.ID, &template.Name, &template.Description, &template.Category, &template.CreatedAt, &template.UpdatedAt, &data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("template with id %s: %w", id, core.ErrTemplateNotFound)
+		}
+		logrus.WithField("template_id", id).WithField("error", err).Error("Failed to retrieve template")
+		return nil, err
+	}
+	template.Data = data
+
+	return &template, nil
+}
+
+// UpdateTemplate replaces a template's metadata and scene
+func (s *documentStore) UpdateTemplate(ctx context.Context, template *core.Template) error {
+	log := logrus.WithField("template_id", template.ID)
+
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE templates SET name = ?, description = ?, category = ?, data = ?, updated_at = ? WHERE id = ?",
+		template.Name, template.Description, template.Category, []byte(template.Data), int64(ulid.Now()), template.ID)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to update template")
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("template with id %s: %w", template.ID, core.ErrTemplateNotFound)
+	}
+
+	log.Info("Template updated successfully")
+	return nil
+}
+
+// DeleteTemplate deletes a template by id
+func (s *documentStore) DeleteTemplate(ctx context.Context, id string) error {
+	log := logrus.WithField("template_id", id)
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM templates WHERE id = ?", id)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to delete template")
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("template with id %s: %w", id, core.ErrTemplateNotFound)
+	}
+
+	log.Info("Template deleted successfully")
+	return nil
+}
+
+// ListTemplates lists the templates in category, or all of them, without
+// their scenes
+func (s *documentStore) ListTemplates(ctx context.Context, category string) ([]core.Template, error) {
+	query := "SELECT id, name, description, category, created_at, updated_at FROM templates"
+	var args []any
+	if category != "" {
+		query += " WHERE category = ?"
+		args = append(args, category)
+	}
+	query += " ORDER BY category, name, id"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to list templates")
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close template rows")
+		}
+	}()
+
+	var templates []core.Template
+	for rows.Next() {
+		var template core.Template
+		if err := rows.Scan(&template.ID, &template.Name, &template.Description, &template.Category, &template.CreatedAt, &template.UpdatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"excalidraw-server/core"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PutThumbnail caches the thumbnail of a scene, replacing any earlier one
+func (s *documentStore) PutThumbnail(ctx context.Context, hash string, png []byte) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO thumbnails (hash, data, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(hash) DO UPDATE SET data = excluded.data`,
+		hash, png, time.Now().UnixMilli())
+	return err
+}
+
+// GetThumbnail returns the cached thumbnail of a scene
+func (s *documentStore) GetThumbnail(ctx context.Context, hash string) ([]byte, error) {
+	var png []byte
+	err := s.db.QueryRowContext(ctx, "SELECT data FROM thumbnails WHERE hash = ?", hash).Scan(&png)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("scene %s: %w", hash, core.ErrThumbnailNotFound)
+	}
+	return png, err
+}
+
+// DeleteThumbnail forgets the thumbnail of a scene that no longer exists
+func (s *documentStore) DeleteThumbnail(ctx context.Context, hash string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM thumbnails WHERE hash = ?", hash)
+	return err
+}
+
+// ListThumbnails lists the scenes with a cached thumbnail
+func (s *documentStore) ListThumbnails(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT hash FROM thumbnails ORDER BY hash")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close thumbnail rows")
+		}
+	}()
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// ListInlineThumbnails lists the snapshots saved with a client-side
+// thumbnail
+func (s *documentStore) ListInlineThumbnails(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM snapshots WHERE thumbnail IS NOT NULL AND thumbnail != '' ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("Failed to close snapshot rows")
+		}
+	}()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetInlineThumbnail returns the client-side thumbnail of a snapshot
+func (s *documentStore) GetInlineThumbnail(ctx context.Context, snapshotID string) (string, error) {
+	var thumbnail sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT thumbnail FROM snapshots WHERE id = ?", snapshotID).Scan(&thumbnail)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("snapshot with id %s not found", snapshotID)
+	}
+	return thumbnail.String, err
+}
+
+// SetInlineThumbnail replaces the client-side thumbnail of a snapshot;
+// empty removes it
+func (s *documentStore) SetInlineThumbnail(ctx context.Context, snapshotID, thumbnail string) error {
+	value := sql.NullString{String: thumbnail, Valid: thumbnail != ""}
+	_, err := s.db.ExecContext(ctx, "UPDATE snapshots SET thumbnail = ? WHERE id = ?", value, snapshotID)
+	return err
+}
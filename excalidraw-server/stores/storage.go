@@ -2,7 +2,9 @@ package stores
 
 import (
 	"excalidraw-server/core"
+	"excalidraw-server/stores/azureblob"
 	"excalidraw-server/stores/filesystem"
+	"excalidraw-server/stores/gcs"
 	"excalidraw-server/stores/memory"
 	"excalidraw-server/stores/sqlite"
 	"os"
@@ -26,10 +28,42 @@ func GetStore() core.DocumentStore {
 	case "sqlite":
 		dataSourceName := os.Getenv("DATA_SOURCE_NAME")
 		storageField["dataSourceName"] = dataSourceName
-		store = sqlite.NewDocumentStore(dataSourceName)
+		opts, err := sqlite.OptionsFromEnv()
+		if err != nil {
+			logrus.WithFields(storageField).WithError(err).Fatal("Invalid SQLite configuration")
+		}
+		storageField["journalMode"] = opts.JournalMode
+		storageField["writeBatch"] = opts.BatchSize
+		store = sqlite.NewDocumentStoreWithOptions(dataSourceName, opts)
+	case "azureblob":
+		opts, err := azureblob.OptionsFromEnv()
+		if err != nil {
+			logrus.WithFields(storageField).WithError(err).Fatal("Invalid Azure Blob Storage configuration")
+		}
+		storageField["account"] = opts.Account
+		storageField["container"] = opts.Container
+		if store, err = azureblob.NewDocumentStore(opts); err != nil {
+			logrus.WithFields(storageField).WithError(err).Fatal("Failed to set up Azure Blob Storage")
+		}
+	case "gcs":
+		opts, err := gcs.OptionsFromEnv()
+		if err != nil {
+			logrus.WithFields(storageField).WithError(err).Fatal("Invalid Google Cloud Storage configuration")
+		}
+		storageField["bucket"] = opts.Bucket
+		if store, err = gcs.NewDocumentStore(opts); err != nil {
+			logrus.WithFields(storageField).WithError(err).Fatal("Failed to set up Google Cloud Storage")
+		}
 	default:
-		store = memory.NewDocumentStore()
 		storageField["storageType"] = "in-memory"
+		opts, err := memory.OptionsFromEnv()
+		if err != nil {
+			logrus.WithFields(storageField).WithError(err).Fatal("Invalid memory store configuration")
+		}
+		storageField["maxEntries"] = opts.MaxEntries
+		storageField["maxBytes"] = opts.MaxBytes
+		storageField["ttl"] = opts.TTL
+		store = memory.NewDocumentStoreWithOptions(opts)
 	}
 	logrus.WithFields(storageField).Info("Use storage")
 	return store
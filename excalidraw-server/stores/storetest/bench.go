@@ -0,0 +1,220 @@
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"excalidraw-server/core"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BenchFactory returns an empty store for one benchmark. It should register
+// any cleanup with b.
+type BenchFactory func(b *testing.B) core.DocumentStore
+
+const (
+	// benchSceneSize is about what a drawing with a few hundred elements
+	// saves.
+	benchSceneSize = 64 << 10
+	// benchFileSize is a small pasted image.
+	benchFileSize = 256 << 10
+	// benchEntries is how many entries the read benchmarks spread over.
+	benchEntries = 100
+)
+
+// Benchmark measures the hot paths of the stores newStore creates: saving
+// and loading scenes, plus files, canvases and hibernated room state when
+// the store supports them. A backend's tests call it like Run:
+//
+//	func BenchmarkStore(b *testing.B) {
+//		storetest.Benchmark(b, func(b *testing.B) core.DocumentStore {
+//			return NewDocumentStore(b.TempDir())
+//		})
+//	}
+func Benchmark(b *testing.B, newStore BenchFactory) {
+	// the stores log every save at info level, which would otherwise be
+	// most of what is measured
+	level := logrus.GetLevel()
+	logrus.SetLevel(logrus.WarnLevel)
+	b.Cleanup(func() { logrus.SetLevel(level) })
+
+	b.Run("CreateDocument", func(b *testing.B) { benchCreateDocument(b, newStore(b)) })
+	b.Run("FindDocument", func(b *testing.B) { benchFindDocument(b, newStore(b)) })
+	b.Run("FindDocumentParallel", func(b *testing.B) { benchFindDocumentParallel(b, newStore(b)) })
+
+	b.Run("PutFile", func(b *testing.B) {
+		fileStore, ok := newStore(b).(core.FileStore)
+		if !ok {
+			b.Skip("store does not implement core.FileStore")
+		}
+		benchPutFile(b, fileStore)
+	})
+	b.Run("GetFile", func(b *testing.B) {
+		fileStore, ok := newStore(b).(core.FileStore)
+		if !ok {
+			b.Skip("store does not implement core.FileStore")
+		}
+		benchGetFile(b, fileStore)
+	})
+	b.Run("PutCanvas", func(b *testing.B) {
+		canvasStore, ok := newStore(b).(core.CanvasStore)
+		if !ok {
+			b.Skip("store does not implement core.CanvasStore")
+		}
+		benchPutCanvas(b, canvasStore)
+	})
+	b.Run("GetCanvas", func(b *testing.B) {
+		canvasStore, ok := newStore(b).(core.CanvasStore)
+		if !ok {
+			b.Skip("store does not implement core.CanvasStore")
+		}
+		benchGetCanvas(b, canvasStore)
+	})
+	b.Run("RoomState", func(b *testing.B) {
+		roomStates, ok := newStore(b).(core.RoomStateStore)
+		if !ok {
+			b.Skip("store does not implement core.RoomStateStore")
+		}
+		benchRoomState(b, roomStates)
+	})
+}
+
+// createDocuments saves benchEntries scenes and returns their ids.
+func createDocuments(b *testing.B, store core.DocumentStore) []string {
+	b.Helper()
+	ctx := context.Background()
+	scene := payload(benchSceneSize)
+	ids := make([]string, benchEntries)
+	for i := range ids {
+		id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBuffer(scene)})
+		if err != nil {
+			b.Fatalf("Create() failed: %v", err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+func benchCreateDocument(b *testing.B, store core.DocumentStore) {
+	ctx := context.Background()
+	scene := payload(benchSceneSize)
+	b.SetBytes(benchSceneSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.Create(ctx, &core.Document{Data: *bytes.NewBuffer(scene)}); err != nil {
+			b.Fatalf("Create() failed: %v", err)
+		}
+	}
+}
+
+func benchFindDocument(b *testing.B, store core.DocumentStore) {
+	ctx := context.Background()
+	ids := createDocuments(b, store)
+	b.SetBytes(benchSceneSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.FindID(ctx, ids[i%len(ids)]); err != nil {
+			b.Fatalf("FindID() failed: %v", err)
+		}
+	}
+}
+
+func benchFindDocumentParallel(b *testing.B, store core.DocumentStore) {
+	ctx := context.Background()
+	ids := createDocuments(b, store)
+	var next atomic.Int64
+	b.SetBytes(benchSceneSize)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id := ids[int(next.Add(1))%len(ids)]
+			if _, err := store.FindID(ctx, id); err != nil {
+				b.Errorf("FindID() failed: %v", err)
+				return
+			}
+		}
+	})
+}
+
+func benchPutFile(b *testing.B, store core.FileStore) {
+	ctx := context.Background()
+	data := payload(benchFileSize)
+	b.SetBytes(benchFileSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.PutFile(ctx, &core.File{ID: fmt.Sprintf("bench-file-%d", i), Data: data}); err != nil {
+			b.Fatalf("PutFile() failed: %v", err)
+		}
+	}
+}
+
+func benchGetFile(b *testing.B, store core.FileStore) {
+	ctx := context.Background()
+	data := payload(benchFileSize)
+	for i := 0; i < benchEntries; i++ {
+		if err := store.PutFile(ctx, &core.File{ID: fmt.Sprintf("bench-file-%d", i), Data: data}); err != nil {
+			b.Fatalf("PutFile() failed: %v", err)
+		}
+	}
+	b.SetBytes(benchFileSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetFile(ctx, fmt.Sprintf("bench-file-%d", i%benchEntries)); err != nil {
+			b.Fatalf("GetFile() failed: %v", err)
+		}
+	}
+}
+
+// benchPutCanvas replaces the same few canvases over and over, like
+// autosave does.
+func benchPutCanvas(b *testing.B, store core.CanvasStore) {
+	ctx := context.Background()
+	data := payload(benchSceneSize)
+	b.SetBytes(benchSceneSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		canvas := &core.Canvas{OwnerID: "bench-user", Key: fmt.Sprintf("canvas-%d", i%benchEntries), Data: data}
+		if err := store.PutCanvas(ctx, canvas); err != nil {
+			b.Fatalf("PutCanvas() failed: %v", err)
+		}
+	}
+}
+
+func benchGetCanvas(b *testing.B, store core.CanvasStore) {
+	ctx := context.Background()
+	data := payload(benchSceneSize)
+	for i := 0; i < benchEntries; i++ {
+		canvas := &core.Canvas{OwnerID: "bench-user", Key: fmt.Sprintf("canvas-%d", i), Data: data}
+		if err := store.PutCanvas(ctx, canvas); err != nil {
+			b.Fatalf("PutCanvas() failed: %v", err)
+		}
+	}
+	b.SetBytes(benchSceneSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetCanvas(ctx, "bench-user", fmt.Sprintf("canvas-%d", i%benchEntries)); err != nil {
+			b.Fatalf("GetCanvas() failed: %v", err)
+		}
+	}
+}
+
+// benchRoomState hibernates and wakes a room, the round trip an idle room
+// makes.
+func benchRoomState(b *testing.B, store core.RoomStateStore) {
+	ctx := context.Background()
+	state := payload(benchSceneSize)
+	b.SetBytes(benchSceneSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		roomID := fmt.Sprintf("bench-room-%d", i%benchEntries)
+		if err := store.PutRoomState(ctx, roomID, state); err != nil {
+			b.Fatalf("PutRoomState() failed: %v", err)
+		}
+		if _, err := store.GetRoomState(ctx, roomID); err != nil {
+			b.Fatalf("GetRoomState() failed: %v", err)
+		}
+	}
+}
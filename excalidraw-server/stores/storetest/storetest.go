@@ -0,0 +1,1547 @@
+// Package storetest checks that a core.DocumentStore implementation behaves
+// the way the handlers rely on. A backend's tests call Run with a function
+// that creates an empty store:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) core.DocumentStore {
+//			return NewDocumentStore(t.TempDir())
+//		})
+//	}
+//
+// Tests of an optional interface, such as core.FileStore, core.CanvasStore
+// or core.LibraryStore, run only when the store implements it and are
+// skipped otherwise, so a backend gets the cases for whatever it supports.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/core"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns an empty store for one test. It should register any
+// cleanup with t.
+type Factory func(t *testing.T) core.DocumentStore
+
+const (
+	// largeDocumentSize is well above what the frontend usually saves.
+	largeDocumentSize = 8 << 20
+	// largeFileSize is the default FILE_MAX_SIZE.
+	largeFileSize = 4 << 20
+	// concurrency is the number of goroutines in the concurrent tests.
+	concurrency = 16
+)
+
+// traversalIDs are ids that would escape a store's namespace if used as
+// paths or object names unchecked.
+var traversalIDs = []string{"..", "../files", "../../etc/passwd", "/etc/passwd", `..\..\windows`, "a/../../b", ""}
+
+// Run runs the conformance tests for the stores newStore creates.
+func Run(t *testing.T, newStore Factory) {
+	t.Run("Documents", func(t *testing.T) { testDocuments(t, newStore(t)) })
+	t.Run("DocumentsConcurrent", func(t *testing.T) { testDocumentsConcurrent(t, newStore(t)) })
+	t.Run("DocumentsLarge", func(t *testing.T) { testDocumentsLarge(t, newStore(t)) })
+	t.Run("DocumentsPathTraversal", func(t *testing.T) { testDocumentsPathTraversal(t, newStore(t)) })
+	t.Run("DocumentsCanceled", func(t *testing.T) { testDocumentsCanceled(t, newStore(t)) })
+
+	t.Run("Files", func(t *testing.T) {
+		fileStore := requireFiles(t, newStore(t))
+		testFiles(t, fileStore)
+	})
+	t.Run("FilesConcurrent", func(t *testing.T) {
+		fileStore := requireFiles(t, newStore(t))
+		testFilesConcurrent(t, fileStore)
+	})
+	t.Run("FilesLarge", func(t *testing.T) {
+		fileStore := requireFiles(t, newStore(t))
+		testFilesLarge(t, fileStore)
+	})
+	t.Run("FilesPathTraversal", func(t *testing.T) {
+		fileStore := requireFiles(t, newStore(t))
+		testFilesPathTraversal(t, fileStore)
+	})
+	t.Run("FilesCanceled", func(t *testing.T) {
+		fileStore := requireFiles(t, newStore(t))
+		testFilesCanceled(t, fileStore)
+	})
+
+	t.Run("Canvases", func(t *testing.T) {
+		canvasStore := requireCanvases(t, newStore(t))
+		testCanvases(t, canvasStore)
+	})
+	t.Run("CanvasPages", func(t *testing.T) {
+		canvasStore := requireCanvases(t, newStore(t))
+		testCanvasPages(t, canvasStore)
+	})
+	t.Run("CanvasMetadata", func(t *testing.T) {
+		canvasStore := requireCanvases(t, newStore(t))
+		testCanvasMetadata(t, canvasStore)
+	})
+	t.Run("CanvasesConcurrent", func(t *testing.T) {
+		canvasStore := requireCanvases(t, newStore(t))
+		testCanvasesConcurrent(t, canvasStore)
+	})
+	t.Run("CanvasesCanceled", func(t *testing.T) {
+		canvasStore := requireCanvases(t, newStore(t))
+		testCanvasesCanceled(t, canvasStore)
+	})
+
+	t.Run("CRDTUpdates", func(t *testing.T) {
+		crdtStore := requireCRDT(t, newStore(t))
+		testCRDTUpdates(t, crdtStore)
+	})
+
+	t.Run("RoomStates", func(t *testing.T) {
+		roomStateStore := requireRoomStates(t, newStore(t))
+		testRoomStates(t, roomStateStore)
+	})
+
+	t.Run("Thumbnails", func(t *testing.T) {
+		thumbnailStore := requireThumbnails(t, newStore(t))
+		testThumbnails(t, thumbnailStore)
+	})
+
+	t.Run("Templates", func(t *testing.T) {
+		templateStore := requireTemplates(t, newStore(t))
+		testTemplates(t, templateStore)
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		userStore := requireUsers(t, newStore(t))
+		testUsers(t, userStore)
+	})
+
+	t.Run("Notifications", func(t *testing.T) {
+		notificationStore := requireNotifications(t, newStore(t))
+		testNotifications(t, notificationStore)
+	})
+
+	t.Run("Orgs", func(t *testing.T) {
+		orgStore := requireOrgs(t, newStore(t))
+		testOrgLifecycle(t, orgStore)
+	})
+	t.Run("OrgList", func(t *testing.T) {
+		orgStore := requireOrgs(t, newStore(t))
+		testListOrgs(t, orgStore)
+	})
+
+	t.Run("DocumentStats", func(t *testing.T) { testDocumentStats(t, newStore(t)) })
+	t.Run("CanvasViews", func(t *testing.T) {
+		canvasStore := requireCanvases(t, newStore(t))
+		testCanvasViews(t, canvasStore)
+	})
+
+	t.Run("ScanScenes", func(t *testing.T) { testScanScenes(t, newStore(t)) })
+
+	t.Run("Libraries", func(t *testing.T) {
+		libraryStore := requireLibraries(t, newStore(t))
+		testLibraryLifecycle(t, libraryStore)
+	})
+	t.Run("LibraryList", func(t *testing.T) {
+		libraryStore := requireLibraries(t, newStore(t))
+		testListLibraries(t, libraryStore)
+	})
+
+	t.Run("Recordings", func(t *testing.T) {
+		recordingStore := requireRecordings(t, newStore(t))
+		testRecordingLifecycle(t, recordingStore)
+	})
+	t.Run("RecordingList", func(t *testing.T) {
+		recordingStore := requireRecordings(t, newStore(t))
+		testListRecordings(t, recordingStore)
+	})
+	t.Run("RecordingsNotFound", func(t *testing.T) {
+		recordingStore := requireRecordings(t, newStore(t))
+		testRecordingNotFound(t, recordingStore)
+	})
+}
+
+func requireFiles(t *testing.T, store core.DocumentStore) core.FileStore {
+	t.Helper()
+	fileStore, ok := store.(core.FileStore)
+	if !ok {
+		t.Skip("store doesn't implement core.FileStore")
+	}
+	return fileStore
+}
+
+func requireCanvases(t *testing.T, store core.DocumentStore) core.CanvasStore {
+	t.Helper()
+	canvasStore, ok := store.(core.CanvasStore)
+	if !ok {
+		t.Skip("store doesn't implement core.CanvasStore")
+	}
+	return canvasStore
+}
+
+func requireCRDT(t *testing.T, store core.DocumentStore) core.CRDTStore {
+	t.Helper()
+	crdtStore, ok := store.(core.CRDTStore)
+	if !ok {
+		t.Skip("store doesn't implement core.CRDTStore")
+	}
+	return crdtStore
+}
+
+func requireRoomStates(t *testing.T, store core.DocumentStore) core.RoomStateStore {
+	t.Helper()
+	roomStateStore, ok := store.(core.RoomStateStore)
+	if !ok {
+		t.Skip("store doesn't implement core.RoomStateStore")
+	}
+	return roomStateStore
+}
+
+func requireThumbnails(t *testing.T, store core.DocumentStore) core.ThumbnailStore {
+	t.Helper()
+	thumbnailStore, ok := store.(core.ThumbnailStore)
+	if !ok {
+		t.Skip("store does not implement core.ThumbnailStore")
+	}
+	return thumbnailStore
+}
+
+func requireTemplates(t *testing.T, store core.DocumentStore) core.TemplateStore {
+	t.Helper()
+	templateStore, ok := store.(core.TemplateStore)
+	if !ok {
+		t.Skip("store doesn't implement core.TemplateStore")
+	}
+	return templateStore
+}
+
+func requireUsers(t *testing.T, store core.DocumentStore) core.UserStore {
+	t.Helper()
+	userStore, ok := store.(core.UserStore)
+	if !ok {
+		t.Skip("store doesn't implement core.UserStore")
+	}
+	return userStore
+}
+
+func requireNotifications(t *testing.T, store core.DocumentStore) core.NotificationStore {
+	t.Helper()
+	notificationStore, ok := store.(core.NotificationStore)
+	if !ok {
+		t.Skip("store doesn't implement core.NotificationStore")
+	}
+	return notificationStore
+}
+
+func requireOrgs(t *testing.T, store core.DocumentStore) core.OrgStore {
+	t.Helper()
+	orgStore, ok := store.(core.OrgStore)
+	if !ok {
+		t.Skip("store doesn't implement core.OrgStore")
+	}
+	return orgStore
+}
+
+func requireDocumentStats(t *testing.T, store core.DocumentStore) core.DocumentStatsStore {
+	t.Helper()
+	documentStatsStore, ok := store.(core.DocumentStatsStore)
+	if !ok {
+		t.Skip("store doesn't implement core.DocumentStatsStore")
+	}
+	return documentStatsStore
+}
+
+func requireSceneScanner(t *testing.T, store core.DocumentStore) core.SceneScanner {
+	t.Helper()
+	sceneScanner, ok := store.(core.SceneScanner)
+	if !ok {
+		t.Skip("store doesn't implement core.SceneScanner")
+	}
+	return sceneScanner
+}
+
+func requireLibraries(t *testing.T, store core.DocumentStore) core.LibraryStore {
+	t.Helper()
+	libraryStore, ok := store.(core.LibraryStore)
+	if !ok {
+		t.Skip("store doesn't implement core.LibraryStore")
+	}
+	return libraryStore
+}
+
+func requireRecordings(t *testing.T, store core.DocumentStore) core.RecordingStore {
+	t.Helper()
+	recordingStore, ok := store.(core.RecordingStore)
+	if !ok {
+		t.Skip("store doesn't implement core.RecordingStore")
+	}
+	return recordingStore
+}
+
+// payload returns size bytes that aren't all the same, so truncation or
+// reordering shows up.
+func payload(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7 + i/251)
+	}
+	return data
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func testDocuments(t *testing.T, store core.DocumentStore) {
+	ctx := context.Background()
+
+	scenes := []string{`{"type":"excalidraw","elements":[]}`, "", "\x00binary\xff"}
+	ids := make(map[string]string)
+	for _, scene := range scenes {
+		id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString(scene)})
+		if err != nil {
+			t.Fatalf("Create(%q) failed: %v", scene, err)
+		}
+		if id == "" {
+			t.Fatalf("Create(%q) returned an empty id", scene)
+		}
+		if _, ok := ids[id]; ok {
+			t.Fatalf("Create() returned id %s twice", id)
+		}
+		ids[id] = scene
+	}
+
+	for id, scene := range ids {
+		document, err := store.FindID(ctx, id)
+		if err != nil {
+			t.Fatalf("FindID(%s) failed: %v", id, err)
+		}
+		if got := document.Data.String(); got != scene {
+			t.Errorf("FindID(%s) = %q, want %q", id, got, scene)
+		}
+	}
+
+	if usage, ok := store.(core.StorageUsageStore); ok {
+		if got, err := usage.StorageUsage(ctx); err != nil || got.Documents != len(scenes) || got.Bytes <= 0 {
+			t.Errorf("StorageUsage() = %+v, %v, want %d documents", got, err, len(scenes))
+		}
+	}
+
+	if _, err := store.FindID(ctx, "01ARZ3NDEKTSV4RRFFQ69G5FAV"); err == nil {
+		t.Error("FindID() of an unknown id succeeded")
+	}
+
+	if statsStore, ok := store.(core.DocumentStatsStore); ok {
+		if _, err := statsStore.GetDocumentStats(ctx, "01ARZ3NDEKTSV4RRFFQ69G5FAV"); !errors.Is(err, core.ErrDocumentNotFound) {
+			t.Errorf("GetDocumentStats() of an unknown id error = %v, want core.ErrDocumentNotFound", err)
+		}
+	}
+}
+
+func testDocumentsConcurrent(t *testing.T, store core.DocumentStore) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			scene := fmt.Sprintf("scene %d", i)
+			id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString(scene)})
+			if err != nil {
+				errs <- fmt.Errorf("Create() failed: %w", err)
+				return
+			}
+			document, err := store.FindID(ctx, id)
+			if err != nil {
+				errs <- fmt.Errorf("FindID(%s) failed: %w", id, err)
+				return
+			}
+			if got := document.Data.String(); got != scene {
+				errs <- fmt.Errorf("FindID(%s) = %q, want %q", id, got, scene)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func testDocumentsLarge(t *testing.T, store core.DocumentStore) {
+	ctx := context.Background()
+	data := payload(largeDocumentSize)
+	id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBuffer(bytes.Clone(data))})
+	if err != nil {
+		t.Fatalf("Create() of %d bytes failed: %v", len(data), err)
+	}
+	document, err := store.FindID(ctx, id)
+	if err != nil {
+		t.Fatalf("FindID() failed: %v", err)
+	}
+	if !bytes.Equal(document.Data.Bytes(), data) {
+		t.Errorf("FindID() returned %d bytes that differ from the %d stored", document.Data.Len(), len(data))
+	}
+}
+
+func testDocumentsPathTraversal(t *testing.T, store core.DocumentStore) {
+	ctx := context.Background()
+	// Something for a traversal to find, in case the store nests documents
+	if _, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("scene")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range traversalIDs {
+		if document, err := store.FindID(ctx, id); err == nil {
+			t.Errorf("FindID(%q) = %q, want an error", id, document.Data.String())
+		}
+	}
+}
+
+func testDocumentsCanceled(t *testing.T, store core.DocumentStore) {
+	ctx := canceledContext()
+	if _, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("scene")}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Create() with a canceled context error = %v, want context.Canceled", err)
+	}
+
+	id, err := store.Create(context.Background(), &core.Document{Data: *bytes.NewBufferString("scene")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.FindID(ctx, id); !errors.Is(err, context.Canceled) {
+		t.Errorf("FindID() with a canceled context error = %v, want context.Canceled", err)
+	}
+}
+
+func testFiles(t *testing.T, store core.FileStore) {
+	ctx := context.Background()
+
+	files, err := store.ListFiles(ctx)
+	if err != nil || len(files) != 0 {
+		t.Fatalf("ListFiles() of an empty store = %v, %v", files, err)
+	}
+
+	if err := store.PutFile(ctx, &core.File{ID: "image-1", Data: []byte("png"), CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("PutFile() failed: %v", err)
+	}
+	if err := store.PutFile(ctx, &core.File{ID: "image-2", Data: []byte("jpeg"), CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("PutFile() failed: %v", err)
+	}
+	// Replacing keeps a single copy
+	if err := store.PutFile(ctx, &core.File{ID: "image-1", Data: []byte("webp"), CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("PutFile() of an existing id failed: %v", err)
+	}
+
+	file, err := store.GetFile(ctx, "image-1")
+	if err != nil {
+		t.Fatalf("GetFile() failed: %v", err)
+	}
+	if file.ID != "image-1" || string(file.Data) != "webp" {
+		t.Errorf("GetFile() = %s %q, want image-1 \"webp\"", file.ID, file.Data)
+	}
+	if file.CreatedAt.IsZero() {
+		t.Error("GetFile() returned a zero CreatedAt")
+	}
+
+	files, err = store.ListFiles(ctx)
+	if err != nil {
+		t.Fatalf("ListFiles() failed: %v", err)
+	}
+	listed := make(map[string]bool)
+	for _, file := range files {
+		listed[file.ID] = true
+		if file.CreatedAt.IsZero() {
+			t.Errorf("ListFiles() returned %s with a zero CreatedAt", file.ID)
+		}
+	}
+	if len(files) != 2 || !listed["image-1"] || !listed["image-2"] {
+		t.Errorf("ListFiles() = %v, want image-1 and image-2", files)
+	}
+
+	if err := store.DeleteFile(ctx, "image-1"); err != nil {
+		t.Fatalf("DeleteFile() failed: %v", err)
+	}
+	if _, err := store.GetFile(ctx, "image-1"); !errors.Is(err, core.ErrFileNotFound) {
+		t.Errorf("GetFile() after delete error = %v, want core.ErrFileNotFound", err)
+	}
+	if err := store.DeleteFile(ctx, "image-1"); !errors.Is(err, core.ErrFileNotFound) {
+		t.Errorf("second DeleteFile() error = %v, want core.ErrFileNotFound", err)
+	}
+	if _, err := store.GetFile(ctx, "image-2"); err != nil {
+		t.Errorf("DeleteFile() removed another file: %v", err)
+	}
+}
+
+func testFilesConcurrent(t *testing.T, store core.FileStore) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Half the goroutines write the same id, the rest their own
+			id := fmt.Sprintf("file-%d", i%(concurrency/2))
+			data := []byte(fmt.Sprintf("data %d", i))
+			if err := store.PutFile(ctx, &core.File{ID: id, Data: data}); err != nil {
+				errs <- fmt.Errorf("PutFile(%s) failed: %w", id, err)
+				return
+			}
+			file, err := store.GetFile(ctx, id)
+			if err != nil {
+				errs <- fmt.Errorf("GetFile(%s) failed: %w", id, err)
+				return
+			}
+			// Another goroutine may have replaced it, but never partially
+			if !bytes.HasPrefix(file.Data, []byte("data ")) {
+				errs <- fmt.Errorf("GetFile(%s) = %q, a partial write", id, file.Data)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	files, err := store.ListFiles(ctx)
+	if err != nil || len(files) != concurrency/2 {
+		t.Errorf("ListFiles() = %d files, %v, want %d", len(files), err, concurrency/2)
+	}
+}
+
+func testFilesLarge(t *testing.T, store core.FileStore) {
+	ctx := context.Background()
+	data := payload(largeFileSize)
+	if err := store.PutFile(ctx, &core.File{ID: "large", Data: bytes.Clone(data)}); err != nil {
+		t.Fatalf("PutFile() of %d bytes failed: %v", len(data), err)
+	}
+	file, err := store.GetFile(ctx, "large")
+	if err != nil {
+		t.Fatalf("GetFile() failed: %v", err)
+	}
+	if !bytes.Equal(file.Data, data) {
+		t.Errorf("GetFile() returned %d bytes that differ from the %d stored", len(file.Data), len(data))
+	}
+}
+
+func testFilesPathTraversal(t *testing.T, store core.FileStore) {
+	ctx := context.Background()
+	if err := store.PutFile(ctx, &core.File{ID: "image", Data: []byte("png")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range traversalIDs {
+		if file, err := store.GetFile(ctx, id); err == nil {
+			t.Errorf("GetFile(%q) = %q, want an error", id, file.Data)
+		}
+		if err := store.DeleteFile(ctx, id); err == nil {
+			t.Errorf("DeleteFile(%q) succeeded", id)
+		}
+	}
+	if _, err := store.GetFile(ctx, "image"); err != nil {
+		t.Errorf("traversal ids removed a file: %v", err)
+	}
+}
+
+func testFilesCanceled(t *testing.T, store core.FileStore) {
+	ctx := canceledContext()
+	if err := store.PutFile(ctx, &core.File{ID: "image", Data: []byte("png")}); !errors.Is(err, context.Canceled) {
+		t.Errorf("PutFile() with a canceled context error = %v, want context.Canceled", err)
+	}
+	if _, err := store.GetFile(ctx, "image"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetFile() with a canceled context error = %v, want context.Canceled", err)
+	}
+	if _, err := store.ListFiles(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListFiles() with a canceled context error = %v, want context.Canceled", err)
+	}
+	if err := store.DeleteFile(ctx, "image"); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteFile() with a canceled context error = %v, want context.Canceled", err)
+	}
+}
+
+func testCanvases(t *testing.T, store core.CanvasStore) {
+	ctx := context.Background()
+
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "plan", Data: []byte("v1")}); err != nil {
+		t.Fatalf("PutCanvas() failed: %v", err)
+	}
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "bob", Key: "plan", Data: []byte("bob's")}); err != nil {
+		t.Fatalf("PutCanvas() failed: %v", err)
+	}
+	first, err := store.GetCanvas(ctx, "alice", "plan")
+	if err != nil {
+		t.Fatalf("GetCanvas() failed: %v", err)
+	}
+	if first.Size != len("v1") || first.CreatedAt == 0 {
+		t.Errorf("GetCanvas() = size %d created %d", first.Size, first.CreatedAt)
+	}
+	if err := store.RecordCanvasView(ctx, "alice", "plan"); err != nil {
+		t.Fatalf("RecordCanvasView() failed: %v", err)
+	}
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "plan", Data: []byte("version 2")}); err != nil {
+		t.Fatalf("PutCanvas() replacing a canvas failed: %v", err)
+	}
+
+	canvas, err := store.GetCanvas(ctx, "alice", "plan")
+	if err != nil {
+		t.Fatalf("GetCanvas() failed: %v", err)
+	}
+	if string(canvas.Data) != "version 2" || canvas.Size != len("version 2") || canvas.Views != 1 {
+		t.Errorf("GetCanvas() = %q size %d views %d, want \"version 2\" size 9 views 1", canvas.Data, canvas.Size, canvas.Views)
+	}
+	if canvas.CreatedAt != first.CreatedAt {
+		t.Errorf("replacing the canvas changed CreatedAt from %d to %d", first.CreatedAt, canvas.CreatedAt)
+	}
+
+	// Owners don't see each other's canvases
+	canvases, err := store.ListCanvases(ctx, "alice")
+	if err != nil || len(canvases) != 1 || canvases[0].Key != "plan" {
+		t.Errorf("ListCanvases() = %+v, %v", canvases, err)
+	} else if canvases[0].Data != nil || canvases[0].Size != len("version 2") {
+		t.Errorf("ListCanvases() = %q size %d, want no data and size 9", canvases[0].Data, canvases[0].Size)
+	}
+	if canvases, err := store.ListCanvases(ctx, "carol"); err != nil || len(canvases) != 0 {
+		t.Errorf("ListCanvases() of an owner without canvases = %+v, %v", canvases, err)
+	}
+
+	if err := store.DeleteCanvas(ctx, "alice", "plan"); err != nil {
+		t.Fatalf("DeleteCanvas() failed: %v", err)
+	}
+	if _, err := store.GetCanvas(ctx, "alice", "plan"); !errors.Is(err, core.ErrCanvasNotFound) {
+		t.Errorf("GetCanvas() after delete error = %v, want core.ErrCanvasNotFound", err)
+	}
+	if err := store.DeleteCanvas(ctx, "alice", "plan"); !errors.Is(err, core.ErrCanvasNotFound) {
+		t.Errorf("second DeleteCanvas() error = %v, want core.ErrCanvasNotFound", err)
+	}
+	if err := store.RecordCanvasView(ctx, "alice", "plan"); !errors.Is(err, core.ErrCanvasNotFound) {
+		t.Errorf("RecordCanvasView() after delete error = %v, want core.ErrCanvasNotFound", err)
+	}
+	if canvas, err := store.GetCanvas(ctx, "bob", "plan"); err != nil || string(canvas.Data) != "bob's" {
+		t.Errorf("DeleteCanvas() touched another owner's canvas: %v, %v", canvas, err)
+	}
+}
+
+func testCanvasPages(t *testing.T, store core.CanvasStore) {
+	ctx := context.Background()
+	for _, key := range []string{"b", "d", "a", "c"} {
+		if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: key, Data: []byte(key)}); err != nil {
+			t.Fatalf("PutCanvas() failed: %v", err)
+		}
+		// Update times are in milliseconds
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "bob", Key: "e", Data: []byte("e")}); err != nil {
+		t.Fatalf("PutCanvas() failed: %v", err)
+	}
+
+	ascending, descending := true, false
+	for _, tc := range []struct {
+		name string
+		opts core.CanvasListOptions
+		want [][]string
+	}{
+		{"newest first", core.CanvasListOptions{}, [][]string{{"c", "a", "d", "b"}}},
+		{"newest first paged", core.CanvasListOptions{Limit: 3}, [][]string{{"c", "a", "d"}, {"b"}}},
+		{"oldest first paged", core.CanvasListOptions{Limit: 2, Ascending: &ascending}, [][]string{{"b", "d"}, {"a", "c"}}},
+		{"by name paged", core.CanvasListOptions{Sort: core.CanvasSortName, Limit: 3}, [][]string{{"a", "b", "c"}, {"d"}}},
+		{"by name descending", core.CanvasListOptions{Sort: core.CanvasSortName, Limit: 3, Ascending: &descending}, [][]string{{"d", "c", "b"}, {"a"}}},
+	} {
+		opts := tc.opts
+		var pages [][]string
+		for {
+			page, err := store.ListCanvasPage(ctx, "alice", opts)
+			if err != nil {
+				t.Fatalf("ListCanvasPage(%s) failed: %v", tc.name, err)
+			}
+			var keys []string
+			for _, canvas := range page.Canvases {
+				if canvas.Data != nil {
+					t.Errorf("ListCanvasPage(%s) returned canvas data", tc.name)
+				}
+				keys = append(keys, canvas.Key)
+			}
+			pages = append(pages, keys)
+			if page.NextCursor == "" || len(pages) > len(tc.want) {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		if !reflect.DeepEqual(pages, tc.want) {
+			t.Errorf("ListCanvasPage(%s) pages = %v, want %v", tc.name, pages, tc.want)
+		}
+	}
+
+	nameCursor := core.CanvasListOptions{Sort: core.CanvasSortName}.CursorAfter(core.Canvas{Key: "a"})
+	for _, cursor := range []string{"not a cursor!", nameCursor} {
+		if _, err := store.ListCanvasPage(ctx, "alice", core.CanvasListOptions{Cursor: cursor}); !errors.Is(err, core.ErrInvalidCursor) {
+			t.Errorf("ListCanvasPage() with cursor %q error = %v, want core.ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func testCanvasMetadata(t *testing.T, store core.CanvasStore) {
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c"} {
+		if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: key, Data: []byte("data " + key)}); err != nil {
+			t.Fatalf("PutCanvas() failed: %v", err)
+		}
+	}
+	before, err := store.GetCanvas(ctx, "alice", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, tags := "Zebra", []string{"q3", "planning"}
+	updated, err := store.UpdateCanvasMetadata(ctx, "alice", "a", core.CanvasMetadataUpdate{Name: &name, Tags: &tags})
+	if err != nil {
+		t.Fatalf("UpdateCanvasMetadata() failed: %v", err)
+	}
+	if updated.Name != name || !reflect.DeepEqual(updated.Tags, tags) || updated.Data != nil || updated.Size != len("data a") {
+		t.Errorf("UpdateCanvasMetadata() = %+v", updated)
+	}
+	// Only the fields that are set change
+	renamed := "Aardvark"
+	if _, err := store.UpdateCanvasMetadata(ctx, "alice", "a", core.CanvasMetadataUpdate{Name: &renamed}); err != nil {
+		t.Fatalf("UpdateCanvasMetadata() of the name failed: %v", err)
+	}
+
+	canvas, err := store.GetCanvas(ctx, "alice", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canvas.Name != renamed || !reflect.DeepEqual(canvas.Tags, tags) {
+		t.Errorf("GetCanvas() metadata = %+v, want %s %v", canvas.CanvasMetadata, renamed, tags)
+	}
+	if string(canvas.Data) != "data a" || canvas.UpdatedAt != before.UpdatedAt {
+		t.Errorf("UpdateCanvasMetadata() changed the data or update time: %q %d", canvas.Data, canvas.UpdatedAt)
+	}
+
+	// Metadata survives replacing the canvas
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "a", Data: []byte("v2")}); err != nil {
+		t.Fatal(err)
+	}
+	listed, err := store.ListCanvases(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, canvas := range listed {
+		if canvas.Key == "a" {
+			found = canvas.Name == renamed && reflect.DeepEqual(canvas.Tags, tags)
+		}
+	}
+	if !found {
+		t.Errorf("ListCanvases() after PutCanvas() lost the metadata: %+v", listed)
+	}
+
+	// Named canvases sort by name, the others by key
+	zebra := "Zebra"
+	if _, err := store.UpdateCanvasMetadata(ctx, "alice", "b", core.CanvasMetadataUpdate{Name: &zebra}); err != nil {
+		t.Fatal(err)
+	}
+	page, err := store.ListCanvasPage(ctx, "alice", core.CanvasListOptions{Sort: core.CanvasSortName, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{page.Canvases[0].Key}
+	for page.NextCursor != "" && len(keys) < 4 {
+		page, err = store.ListCanvasPage(ctx, "alice", core.CanvasListOptions{Sort: core.CanvasSortName, Limit: 1, Cursor: page.NextCursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, canvas := range page.Canvases {
+			keys = append(keys, canvas.Key)
+		}
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c"}) {
+		t.Errorf("ListCanvasPage() by name = %v, want [a b c]", keys)
+	}
+
+	if _, err := store.UpdateCanvasMetadata(ctx, "bob", "a", core.CanvasMetadataUpdate{Name: &name}); !errors.Is(err, core.ErrCanvasNotFound) {
+		t.Errorf("UpdateCanvasMetadata() of another owner's canvas error = %v, want core.ErrCanvasNotFound", err)
+	}
+}
+
+func testCanvasesConcurrent(t *testing.T, store core.CanvasStore) {
+	ctx := context.Background()
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "plan", Data: []byte("v0")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "plan", Data: []byte(fmt.Sprintf("v%d", i))}); err != nil {
+				errs <- fmt.Errorf("PutCanvas() failed: %w", err)
+			}
+			if err := store.RecordCanvasView(ctx, "alice", "plan"); err != nil {
+				errs <- fmt.Errorf("RecordCanvasView() failed: %w", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	canvas, err := store.GetCanvas(ctx, "alice", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canvas.Views != concurrency {
+		t.Errorf("Views = %d after %d concurrent views", canvas.Views, concurrency)
+	}
+}
+
+func testCanvasesCanceled(t *testing.T, store core.CanvasStore) {
+	ctx := canceledContext()
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "plan", Data: []byte("v1")}); !errors.Is(err, context.Canceled) {
+		t.Errorf("PutCanvas() with a canceled context error = %v, want context.Canceled", err)
+	}
+	if _, err := store.GetCanvas(ctx, "alice", "plan"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetCanvas() with a canceled context error = %v, want context.Canceled", err)
+	}
+	if _, err := store.ListCanvases(ctx, "alice"); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListCanvases() with a canceled context error = %v, want context.Canceled", err)
+	}
+	if err := store.DeleteCanvas(ctx, "alice", "plan"); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteCanvas() with a canceled context error = %v, want context.Canceled", err)
+	}
+}
+
+func testCRDTUpdates(t *testing.T, store core.CRDTStore) {
+	ctx := context.Background()
+
+	updates, err := store.GetCRDTUpdates(ctx, "room")
+	if err != nil || len(updates) != 0 {
+		t.Errorf("GetCRDTUpdates() of a new room = %q, %v", updates, err)
+	}
+
+	for _, update := range []string{"a", "b", "c"} {
+		if err := store.AppendCRDTUpdate(ctx, "room", []byte(update)); err != nil {
+			t.Fatalf("AppendCRDTUpdate() failed: %v", err)
+		}
+	}
+	if err := store.AppendCRDTUpdate(ctx, "other", []byte("x")); err != nil {
+		t.Fatalf("AppendCRDTUpdate() failed: %v", err)
+	}
+
+	check := func(want ...string) {
+		t.Helper()
+		updates, err := store.GetCRDTUpdates(ctx, "room")
+		if err != nil {
+			t.Fatalf("GetCRDTUpdates() failed: %v", err)
+		}
+		got := make([]string, len(updates))
+		for i, update := range updates {
+			got[i] = string(update)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("GetCRDTUpdates() = %q, want %q", got, want)
+		}
+	}
+	check("a", "b", "c")
+
+	if err := store.CompactCRDTUpdates(ctx, "room", 2, []byte("ab")); err != nil {
+		t.Fatalf("CompactCRDTUpdates() failed: %v", err)
+	}
+	if err := store.AppendCRDTUpdate(ctx, "room", []byte("d")); err != nil {
+		t.Fatalf("AppendCRDTUpdate() failed: %v", err)
+	}
+	check("ab", "c", "d")
+
+	if err := store.CompactCRDTUpdates(ctx, "room", 0, []byte("z")); err != nil {
+		t.Fatalf("CompactCRDTUpdates() of no updates failed: %v", err)
+	}
+	check("z", "ab", "c", "d")
+
+	if err := store.CompactCRDTUpdates(ctx, "room", 5, []byte("all")); err == nil {
+		t.Error("CompactCRDTUpdates() of more updates than the log has succeeded")
+	}
+	check("z", "ab", "c", "d")
+
+	if err := store.CompactCRDTUpdates(ctx, "room", 4, []byte("all")); err != nil {
+		t.Fatalf("CompactCRDTUpdates() of the whole log failed: %v", err)
+	}
+	check("all")
+
+	if updates, err := store.GetCRDTUpdates(ctx, "other"); err != nil || len(updates) != 1 || string(updates[0]) != "x" {
+		t.Errorf("compacting touched another room's updates: %q, %v", updates, err)
+	}
+}
+
+func testRoomStates(t *testing.T, store core.RoomStateStore) {
+	ctx := context.Background()
+
+	if _, err := store.GetRoomState(ctx, "room"); !errors.Is(err, core.ErrRoomStateNotFound) {
+		t.Errorf("GetRoomState() of a room without state error = %v, want core.ErrRoomStateNotFound", err)
+	}
+
+	if err := store.PutRoomState(ctx, "room", []byte("v1")); err != nil {
+		t.Fatalf("PutRoomState() failed: %v", err)
+	}
+	if err := store.PutRoomState(ctx, "room", payload(1024)); err != nil {
+		t.Fatalf("PutRoomState() replacing a state failed: %v", err)
+	}
+	if err := store.PutRoomState(ctx, "other", []byte("other")); err != nil {
+		t.Fatalf("PutRoomState() failed: %v", err)
+	}
+	state, err := store.GetRoomState(ctx, "room")
+	if err != nil || !bytes.Equal(state, payload(1024)) {
+		t.Errorf("GetRoomState() = %d bytes, %v, want the replaced state", len(state), err)
+	}
+	if roomIDs, err := store.ListRoomStates(ctx); err != nil || !reflect.DeepEqual(roomIDs, []string{"other", "room"}) {
+		t.Errorf("ListRoomStates() = %v, %v, want other and room", roomIDs, err)
+	}
+
+	if err := store.DeleteRoomState(ctx, "room"); err != nil {
+		t.Fatalf("DeleteRoomState() failed: %v", err)
+	}
+	if _, err := store.GetRoomState(ctx, "room"); !errors.Is(err, core.ErrRoomStateNotFound) {
+		t.Errorf("GetRoomState() after delete error = %v, want core.ErrRoomStateNotFound", err)
+	}
+	if err := store.DeleteRoomState(ctx, "room"); err != nil {
+		t.Errorf("second DeleteRoomState() failed: %v", err)
+	}
+	if state, err := store.GetRoomState(ctx, "other"); err != nil || string(state) != "other" {
+		t.Errorf("DeleteRoomState() touched another room: %q, %v", state, err)
+	}
+}
+
+func testThumbnails(t *testing.T, store core.ThumbnailStore) {
+	ctx := context.Background()
+	first := "3f79bb7b435b05321651daefd374cdc681dc06faa65e374e38337b88ca046dea"
+	second := "a3a5e715f0cc574a73c3f9bebb6bc24f32ffd5b67b387244c2c909da779a1478"
+
+	if _, err := store.GetThumbnail(ctx, first); !errors.Is(err, core.ErrThumbnailNotFound) {
+		t.Errorf("GetThumbnail() of a scene without one error = %v, want core.ErrThumbnailNotFound", err)
+	}
+	if hashes, err := store.ListThumbnails(ctx); err != nil || len(hashes) != 0 {
+		t.Errorf("ListThumbnails() of an empty store = %v, %v", hashes, err)
+	}
+
+	if err := store.PutThumbnail(ctx, first, []byte("v1")); err != nil {
+		t.Fatalf("PutThumbnail() failed: %v", err)
+	}
+	if err := store.PutThumbnail(ctx, first, payload(4096)); err != nil {
+		t.Fatalf("PutThumbnail() replacing a thumbnail failed: %v", err)
+	}
+	if err := store.PutThumbnail(ctx, second, []byte("second")); err != nil {
+		t.Fatalf("PutThumbnail() failed: %v", err)
+	}
+	png, err := store.GetThumbnail(ctx, first)
+	if err != nil || !bytes.Equal(png, payload(4096)) {
+		t.Errorf("GetThumbnail() = %d bytes, %v, want the replaced thumbnail", len(png), err)
+	}
+	if hashes, err := store.ListThumbnails(ctx); err != nil || !reflect.DeepEqual(hashes, []string{first, second}) {
+		t.Errorf("ListThumbnails() = %v, %v, want both hashes", hashes, err)
+	}
+
+	if err := store.DeleteThumbnail(ctx, first); err != nil {
+		t.Fatalf("DeleteThumbnail() failed: %v", err)
+	}
+	if _, err := store.GetThumbnail(ctx, first); !errors.Is(err, core.ErrThumbnailNotFound) {
+		t.Errorf("GetThumbnail() after delete error = %v, want core.ErrThumbnailNotFound", err)
+	}
+	if err := store.DeleteThumbnail(ctx, first); err != nil {
+		t.Errorf("second DeleteThumbnail() failed: %v", err)
+	}
+	if png, err := store.GetThumbnail(ctx, second); err != nil || string(png) != "second" {
+		t.Errorf("DeleteThumbnail() touched another thumbnail: %q, %v", png, err)
+	}
+}
+
+func testTemplates(t *testing.T, store core.TemplateStore) {
+	ctx := context.Background()
+	scene := json.RawMessage(`{"type":"excalidraw","elements":[]}`)
+
+	if _, err := store.GetTemplate(ctx, "missing"); !errors.Is(err, core.ErrTemplateNotFound) {
+		t.Errorf("GetTemplate() of an unknown id error = %v, want core.ErrTemplateNotFound", err)
+	}
+	if templates, err := store.ListTemplates(ctx, ""); err != nil || len(templates) != 0 {
+		t.Errorf("ListTemplates() of an empty store = %v, %v", templates, err)
+	}
+
+	retro, err := store.CreateTemplate(ctx, &core.Template{Name: "Retro", Category: "meetings", Data: scene})
+	if err != nil {
+		t.Fatalf("CreateTemplate() failed: %v", err)
+	}
+	kanban, err := store.CreateTemplate(ctx, &core.Template{Name: "Kanban", Category: "planning", Data: scene})
+	if err != nil {
+		t.Fatalf("CreateTemplate() failed: %v", err)
+	}
+	agenda, err := store.CreateTemplate(ctx, &core.Template{Name: "Agenda", Category: "meetings", Data: scene})
+	if err != nil {
+		t.Fatalf("CreateTemplate() failed: %v", err)
+	}
+
+	template, err := store.GetTemplate(ctx, retro)
+	if err != nil {
+		t.Fatalf("GetTemplate() failed: %v", err)
+	}
+	if template.Name != "Retro" || template.Category != "meetings" || string(template.Data) != string(scene) || template.CreatedAt == 0 {
+		t.Errorf("GetTemplate() = %+v", template)
+	}
+
+	all, err := store.ListTemplates(ctx, "")
+	if err != nil {
+		t.Fatalf("ListTemplates() failed: %v", err)
+	}
+	var ids []string
+	for _, listed := range all {
+		ids = append(ids, listed.ID)
+		if listed.Data != nil {
+			t.Errorf("ListTemplates() returned the scene of %s", listed.ID)
+		}
+	}
+	if want := []string{agenda, retro, kanban}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ListTemplates() = %v, want %v ordered by category and name", ids, want)
+	}
+	if planning, err := store.ListTemplates(ctx, "planning"); err != nil || len(planning) != 1 || planning[0].ID != kanban {
+		t.Errorf("ListTemplates(planning) = %+v, %v", planning, err)
+	}
+
+	template.Name = "Sprint retro"
+	template.Category = "planning"
+	if err := store.UpdateTemplate(ctx, template); err != nil {
+		t.Fatalf("UpdateTemplate() failed: %v", err)
+	}
+	if updated, err := store.GetTemplate(ctx, retro); err != nil || updated.Name != "Sprint retro" || updated.Category != "planning" || updated.CreatedAt != template.CreatedAt {
+		t.Errorf("GetTemplate() after update = %+v, %v", updated, err)
+	}
+	if err := store.UpdateTemplate(ctx, &core.Template{ID: "missing", Name: "x"}); !errors.Is(err, core.ErrTemplateNotFound) {
+		t.Errorf("UpdateTemplate() of an unknown id error = %v, want core.ErrTemplateNotFound", err)
+	}
+
+	if err := store.DeleteTemplate(ctx, kanban); err != nil {
+		t.Fatalf("DeleteTemplate() failed: %v", err)
+	}
+	if _, err := store.GetTemplate(ctx, kanban); !errors.Is(err, core.ErrTemplateNotFound) {
+		t.Errorf("GetTemplate() after delete error = %v, want core.ErrTemplateNotFound", err)
+	}
+	if err := store.DeleteTemplate(ctx, kanban); !errors.Is(err, core.ErrTemplateNotFound) {
+		t.Errorf("second DeleteTemplate() error = %v, want core.ErrTemplateNotFound", err)
+	}
+}
+
+func testUsers(t *testing.T, store core.UserStore) {
+	ctx := context.Background()
+
+	if _, err := store.GetUser(ctx, "alice"); !errors.Is(err, core.ErrUserNotFound) {
+		t.Errorf("GetUser() of an unknown user error = %v, want core.ErrUserNotFound", err)
+	}
+
+	users := []core.User{
+		{ID: "alice", Login: "Alice", Name: "Alice A.", Email: "alice@example.com", SeenAt: 1},
+		{ID: "bob", Name: "Bob", SeenAt: 2},
+		{ID: "alice-old", Login: "alice", SeenAt: 0},
+	}
+	for _, user := range users {
+		if err := store.PutUser(ctx, user); err != nil {
+			t.Fatalf("PutUser(%s) failed: %v", user.ID, err)
+		}
+	}
+	if user, err := store.GetUser(ctx, "alice"); err != nil || *user != users[0] {
+		t.Errorf("GetUser() = %+v, %v, want %+v", user, err, users[0])
+	}
+	if user, err := store.FindUserByLogin(ctx, "ALICE"); err != nil || user.ID != "alice" {
+		t.Errorf("FindUserByLogin() = %+v, %v, want the alice seen last", user, err)
+	}
+	if _, err := store.FindUserByLogin(ctx, ""); !errors.Is(err, core.ErrUserNotFound) {
+		t.Errorf("FindUserByLogin() of an empty login error = %v, want core.ErrUserNotFound", err)
+	}
+	if lister, ok := store.(core.UserLister); ok {
+		listed, err := lister.ListUsers(ctx, 2)
+		if err != nil || len(listed) != 2 || listed[0] != users[1] || listed[1] != users[0] {
+			t.Errorf("ListUsers(2) = %+v, %v, want bob and alice", listed, err)
+		}
+	}
+	if usage, ok := store.(core.StorageUsageStore); ok {
+		if got, err := usage.StorageUsage(ctx); err != nil || got.Users != len(users) {
+			t.Errorf("StorageUsage() = %+v, %v, want %d users", got, err, len(users))
+		}
+	}
+	if batch, ok := store.(core.UserBatchStore); ok {
+		found, err := batch.GetUsers(ctx, []string{"alice", "bob", "carol"})
+		if err != nil || len(found) != 2 || found["alice"] != users[0] || found["bob"] != users[1] {
+			t.Errorf("GetUsers() = %+v, %v, want alice and bob", found, err)
+		}
+		if found, err := batch.GetUsers(ctx, nil); err != nil || len(found) != 0 {
+			t.Errorf("GetUsers() of no ids = %+v, %v", found, err)
+		}
+	}
+
+	users[0].Login = "alice2"
+	if err := store.PutUser(ctx, users[0]); err != nil {
+		t.Fatalf("PutUser() replacing a user failed: %v", err)
+	}
+	if user, err := store.FindUserByLogin(ctx, "alice"); err != nil || user.ID != "alice-old" {
+		t.Errorf("FindUserByLogin() after a login change = %+v, %v, want alice-old", user, err)
+	}
+
+	if err := store.DeleteUser(ctx, "alice"); err != nil {
+		t.Fatalf("DeleteUser() failed: %v", err)
+	}
+	if _, err := store.GetUser(ctx, "alice"); !errors.Is(err, core.ErrUserNotFound) {
+		t.Errorf("GetUser() after delete error = %v, want core.ErrUserNotFound", err)
+	}
+	if _, err := store.FindUserByLogin(ctx, "alice2"); !errors.Is(err, core.ErrUserNotFound) {
+		t.Errorf("FindUserByLogin() after delete error = %v, want core.ErrUserNotFound", err)
+	}
+	if err := store.DeleteUser(ctx, "alice"); err != nil {
+		t.Errorf("second DeleteUser() failed: %v", err)
+	}
+	if _, err := store.GetUser(ctx, "bob"); err != nil {
+		t.Errorf("DeleteUser() touched another user: %v", err)
+	}
+}
+
+func testNotifications(t *testing.T, store core.NotificationStore) {
+	ctx := context.Background()
+
+	var ids []string
+	for _, text := range []string{"one", "two", "three"} {
+		notification, err := store.AddNotification(ctx, core.Notification{
+			UserID: "alice",
+			Kind:   core.NotificationMention,
+			RoomID: "room",
+			From:   "Bob",
+			Text:   text,
+		})
+		if err != nil {
+			t.Fatalf("AddNotification() failed: %v", err)
+		}
+		if notification.ID == "" || notification.CreatedAt == 0 {
+			t.Fatalf("AddNotification() = %+v, want an id and creation time", notification)
+		}
+		ids = append(ids, notification.ID)
+	}
+	if _, err := store.AddNotification(ctx, core.Notification{UserID: "bob", Kind: core.NotificationMention, Text: "other"}); err != nil {
+		t.Fatalf("AddNotification() failed: %v", err)
+	}
+
+	notifications, err := store.ListNotifications(ctx, "alice", false, 2)
+	if err != nil || len(notifications) != 2 || notifications[0].Text != "three" || notifications[1].Text != "two" {
+		t.Errorf("ListNotifications() = %+v, %v, want the newest two", notifications, err)
+	}
+	if unread, err := store.CountUnreadNotifications(ctx, "alice"); err != nil || unread != 3 {
+		t.Errorf("CountUnreadNotifications() = %d, %v, want 3", unread, err)
+	}
+
+	if marked, err := store.MarkNotificationsRead(ctx, "alice", []string{ids[2], "missing"}); err != nil || marked != 1 {
+		t.Errorf("MarkNotificationsRead() of one = %d, %v, want 1", marked, err)
+	}
+	if marked, _ := store.MarkNotificationsRead(ctx, "bob", []string{ids[1]}); marked != 0 {
+		t.Errorf("MarkNotificationsRead() of another user's notification = %d, want 0", marked)
+	}
+	notifications, err = store.ListNotifications(ctx, "alice", true, 10)
+	if err != nil || len(notifications) != 2 || notifications[0].Text != "two" || notifications[0].ReadAt != 0 {
+		t.Errorf("ListNotifications() of unread = %+v, %v, want one and two", notifications, err)
+	}
+
+	if marked, err := store.MarkNotificationsRead(ctx, "alice", nil); err != nil || marked != 2 {
+		t.Errorf("MarkNotificationsRead() of all = %d, %v, want 2", marked, err)
+	}
+	if unread, _ := store.CountUnreadNotifications(ctx, "alice"); unread != 0 {
+		t.Errorf("CountUnreadNotifications() after reading all = %d, want 0", unread)
+	}
+	if unread, _ := store.CountUnreadNotifications(ctx, "bob"); unread != 1 {
+		t.Errorf("reading all touched another user: %d unread, want 1", unread)
+	}
+
+	if deleted, err := store.DeleteNotifications(ctx, "alice"); err != nil || deleted != 3 {
+		t.Errorf("DeleteNotifications() = %d, %v, want 3", deleted, err)
+	}
+	if notifications, _ := store.ListNotifications(ctx, "alice", false, 10); len(notifications) != 0 {
+		t.Errorf("ListNotifications() after delete = %+v, want none", notifications)
+	}
+	if unread, _ := store.CountUnreadNotifications(ctx, "bob"); unread != 1 {
+		t.Errorf("DeleteNotifications() touched another user: %d unread, want 1", unread)
+	}
+}
+
+func testOrgLifecycle(t *testing.T, store core.OrgStore) {
+	ctx := context.Background()
+
+	org, err := store.CreateOrg(ctx, "Design", "alice")
+	if err != nil {
+		t.Fatalf("CreateOrg() failed: %v", err)
+	}
+	if org.ID == "" || org.Name != "Design" || org.CreatedAt == 0 {
+		t.Errorf("CreateOrg() = %+v", org)
+	}
+	got, err := store.GetOrg(ctx, org.ID)
+	if err != nil || got.Name != "Design" {
+		t.Fatalf("GetOrg() = %+v, %v", got, err)
+	}
+
+	owner, err := store.GetOrgMember(ctx, org.ID, "alice")
+	if err != nil || owner.Role != core.OrgRoleAdmin {
+		t.Fatalf("GetOrgMember() for the creator = %+v, %v; want admin", owner, err)
+	}
+	if _, err := store.GetOrgMember(ctx, org.ID, "bob"); !errors.Is(err, core.ErrNotMember) {
+		t.Errorf("GetOrgMember() for a stranger error = %v, want ErrNotMember", err)
+	}
+
+	if err := store.PutOrgMember(ctx, org.ID, core.OrgMember{UserID: "bob", Role: core.OrgRoleMember}); err != nil {
+		t.Fatalf("PutOrgMember() failed: %v", err)
+	}
+	bob, err := store.GetOrgMember(ctx, org.ID, "bob")
+	if err != nil || bob.Role != core.OrgRoleMember || bob.JoinedAt == 0 {
+		t.Fatalf("GetOrgMember() = %+v, %v", bob, err)
+	}
+	if err := store.PutOrgMember(ctx, org.ID, core.OrgMember{UserID: "bob", Role: core.OrgRoleAdmin}); err != nil {
+		t.Fatalf("PutOrgMember() promote failed: %v", err)
+	}
+	promoted, err := store.GetOrgMember(ctx, org.ID, "bob")
+	if err != nil || promoted.Role != core.OrgRoleAdmin || promoted.JoinedAt != bob.JoinedAt {
+		t.Errorf("promotion should keep the join time: %+v, %v", promoted, err)
+	}
+
+	members, err := store.ListOrgMembers(ctx, org.ID)
+	if err != nil {
+		t.Fatalf("ListOrgMembers() failed: %v", err)
+	}
+	if len(members) != 2 || members[0].UserID != "alice" || members[1].UserID != "bob" {
+		t.Errorf("ListOrgMembers() = %+v, want alice then bob", members)
+	}
+
+	if err := store.RemoveOrgMember(ctx, org.ID, "bob"); err != nil {
+		t.Fatalf("RemoveOrgMember() failed: %v", err)
+	}
+	if err := store.RemoveOrgMember(ctx, org.ID, "bob"); !errors.Is(err, core.ErrNotMember) {
+		t.Errorf("second RemoveOrgMember() error = %v, want ErrNotMember", err)
+	}
+
+	if err := store.DeleteOrg(ctx, org.ID); err != nil {
+		t.Fatalf("DeleteOrg() failed: %v", err)
+	}
+	if _, err := store.GetOrg(ctx, org.ID); !errors.Is(err, core.ErrOrgNotFound) {
+		t.Errorf("GetOrg() after delete error = %v, want ErrOrgNotFound", err)
+	}
+	if _, err := store.GetOrgMember(ctx, org.ID, "alice"); !errors.Is(err, core.ErrNotMember) {
+		t.Errorf("GetOrgMember() after delete error = %v, want ErrNotMember", err)
+	}
+	if err := store.PutOrgMember(ctx, org.ID, core.OrgMember{UserID: "bob", Role: core.OrgRoleMember}); !errors.Is(err, core.ErrOrgNotFound) {
+		t.Errorf("PutOrgMember() after delete error = %v, want ErrOrgNotFound", err)
+	}
+}
+
+func testListOrgs(t *testing.T, store core.OrgStore) {
+	ctx := context.Background()
+
+	zeta, err := store.CreateOrg(ctx, "Zeta", "alice")
+	if err != nil {
+		t.Fatalf("CreateOrg() failed: %v", err)
+	}
+	if _, err := store.CreateOrg(ctx, "Alpha", "bob"); err != nil {
+		t.Fatalf("CreateOrg() failed: %v", err)
+	}
+	alpha, err := store.CreateOrg(ctx, "Alpha", "carol")
+	if err != nil {
+		t.Fatalf("CreateOrg() failed: %v", err)
+	}
+	if err := store.PutOrgMember(ctx, alpha.ID, core.OrgMember{UserID: "alice", Role: core.OrgRoleMember}); err != nil {
+		t.Fatalf("PutOrgMember() failed: %v", err)
+	}
+
+	orgs, err := store.ListOrgs(ctx, "alice")
+	if err != nil {
+		t.Fatalf("ListOrgs() failed: %v", err)
+	}
+	if len(orgs) != 2 {
+		t.Fatalf("ListOrgs() returned %d orgs, want 2: %+v", len(orgs), orgs)
+	}
+	if orgs[0].ID != alpha.ID || orgs[0].Role != core.OrgRoleMember {
+		t.Errorf("orgs[0] = %+v, want Alpha as member", orgs[0])
+	}
+	if orgs[1].ID != zeta.ID || orgs[1].Role != core.OrgRoleAdmin {
+		t.Errorf("orgs[1] = %+v, want Zeta as admin", orgs[1])
+	}
+
+	if orgs, err := store.ListOrgs(ctx, "dave"); err != nil || len(orgs) != 0 {
+		t.Errorf("ListOrgs() for a stranger = %+v, %v", orgs, err)
+	}
+}
+
+func testDocumentStats(t *testing.T, store core.DocumentStore) {
+	statsStore := requireDocumentStats(t, store)
+	ctx := context.Background()
+
+	id, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString("scene")})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	stats, err := statsStore.GetDocumentStats(ctx, id)
+	if err != nil {
+		t.Fatalf("GetDocumentStats() failed: %v", err)
+	}
+	if stats.Views != 0 || stats.LastAccessed != 0 {
+		t.Errorf("stats before any view = %+v, want zero", stats)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := statsStore.RecordDocumentView(ctx, id); err != nil {
+			t.Fatalf("RecordDocumentView() failed: %v", err)
+		}
+	}
+	stats, err = statsStore.GetDocumentStats(ctx, id)
+	if err != nil {
+		t.Fatalf("GetDocumentStats() failed: %v", err)
+	}
+	if stats.Views != 3 || stats.LastAccessed == 0 {
+		t.Errorf("stats after 3 views = %+v", stats)
+	}
+
+	if _, err := statsStore.GetDocumentStats(ctx, "missing"); !errors.Is(err, core.ErrDocumentNotFound) {
+		t.Errorf("GetDocumentStats() for an unknown id error = %v, want ErrDocumentNotFound", err)
+	}
+	if err := statsStore.RecordDocumentView(ctx, "missing"); !errors.Is(err, core.ErrDocumentNotFound) {
+		t.Errorf("RecordDocumentView() for an unknown id error = %v, want ErrDocumentNotFound", err)
+	}
+}
+
+func testCanvasViews(t *testing.T, store core.CanvasStore) {
+	ctx := context.Background()
+
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "drawing", Data: []byte("v1")}); err != nil {
+		t.Fatalf("PutCanvas() failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.RecordCanvasView(ctx, "alice", "drawing"); err != nil {
+			t.Fatalf("RecordCanvasView() failed: %v", err)
+		}
+	}
+
+	// Saving a new version keeps the stats
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "drawing", Data: []byte("v2")}); err != nil {
+		t.Fatalf("PutCanvas() failed: %v", err)
+	}
+	canvases, err := store.ListCanvases(ctx, "alice")
+	if err != nil {
+		t.Fatalf("ListCanvases() failed: %v", err)
+	}
+	if len(canvases) != 1 || canvases[0].Views != 2 || canvases[0].LastAccessed == 0 {
+		t.Errorf("ListCanvases() = %+v, want 2 views", canvases)
+	}
+	canvas, err := store.GetCanvas(ctx, "alice", "drawing")
+	if err != nil || canvas.Views != 2 {
+		t.Errorf("GetCanvas() = %+v, %v; want 2 views", canvas, err)
+	}
+
+	if err := store.RecordCanvasView(ctx, "bob", "drawing"); !errors.Is(err, core.ErrCanvasNotFound) {
+		t.Errorf("RecordCanvasView() for another owner error = %v, want ErrCanvasNotFound", err)
+	}
+
+	// Stats go with the canvas
+	if err := store.DeleteCanvas(ctx, "alice", "drawing"); err != nil {
+		t.Fatalf("DeleteCanvas() failed: %v", err)
+	}
+	if err := store.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "drawing", Data: []byte("v3")}); err != nil {
+		t.Fatalf("PutCanvas() failed: %v", err)
+	}
+	if canvas, _ := store.GetCanvas(ctx, "alice", "drawing"); canvas.Views != 0 {
+		t.Errorf("recreated canvas has %d views, want 0", canvas.Views)
+	}
+}
+
+func testScanScenes(t *testing.T, store core.DocumentStore) {
+	scanner := requireSceneScanner(t, store)
+	ctx := context.Background()
+
+	if _, err := store.Create(ctx, &core.Document{Data: *bytes.NewBufferString(`{"elements":[]}`)}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	want := []string{`{"elements":[]}`}
+	// Files aren't scenes, canvases are
+	if fileStore, ok := store.(core.FileStore); ok {
+		if err := fileStore.PutFile(ctx, &core.File{ID: "image", Data: []byte("not a scene"), CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("PutFile() failed: %v", err)
+		}
+	}
+	if canvasStore, ok := store.(core.CanvasStore); ok {
+		if err := canvasStore.PutCanvas(ctx, &core.Canvas{OwnerID: "alice", Key: "drawing", Data: []byte(`{"fileId":"image"}`)}); err != nil {
+			t.Fatalf("PutCanvas() failed: %v", err)
+		}
+		want = append(want, `{"fileId":"image"}`)
+	}
+
+	var scanned []string
+	err := scanner.ScanScenes(ctx, func(data []byte) error {
+		scanned = append(scanned, string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanScenes() failed: %v", err)
+	}
+	sort.Strings(scanned)
+	sort.Strings(want)
+	if !reflect.DeepEqual(scanned, want) {
+		t.Errorf("ScanScenes() scanned %q, want %q", scanned, want)
+	}
+}
+
+func testLibraryLifecycle(t *testing.T, store core.LibraryStore) {
+	ctx := context.Background()
+
+	id, err := store.CreateLibrary(ctx, &core.Library{
+		OwnerID: "alice",
+		Name:    "Stencils",
+		Data:    json.RawMessage(`{"type":"excalidrawlib"}`),
+	})
+	if err != nil {
+		t.Fatalf("CreateLibrary() failed: %v", err)
+	}
+
+	library, err := store.GetLibrary(ctx, id)
+	if err != nil {
+		t.Fatalf("GetLibrary() failed: %v", err)
+	}
+	if library.Name != "Stencils" || library.OwnerID != "alice" || library.CreatedAt == 0 {
+		t.Errorf("GetLibrary() = %+v", library)
+	}
+	if string(library.Data) != `{"type":"excalidrawlib"}` {
+		t.Errorf("GetLibrary() data = %s", library.Data)
+	}
+
+	library.Name = "Renamed"
+	library.Public = true
+	if err := store.UpdateLibrary(ctx, library); err != nil {
+		t.Fatalf("UpdateLibrary() failed: %v", err)
+	}
+	updated, err := store.GetLibrary(ctx, id)
+	if err != nil {
+		t.Fatalf("GetLibrary() after update failed: %v", err)
+	}
+	if updated.Name != "Renamed" || !updated.Public {
+		t.Errorf("update not applied: %+v", updated)
+	}
+
+	if err := store.DeleteLibrary(ctx, id); err != nil {
+		t.Fatalf("DeleteLibrary() failed: %v", err)
+	}
+	if _, err := store.GetLibrary(ctx, id); !errors.Is(err, core.ErrLibraryNotFound) {
+		t.Errorf("GetLibrary() after delete error = %v, want ErrLibraryNotFound", err)
+	}
+	if err := store.DeleteLibrary(ctx, id); !errors.Is(err, core.ErrLibraryNotFound) {
+		t.Errorf("second DeleteLibrary() error = %v, want ErrLibraryNotFound", err)
+	}
+}
+
+func testListLibraries(t *testing.T, store core.LibraryStore) {
+	ctx := context.Background()
+
+	for _, library := range []core.Library{
+		{OwnerID: "alice", Name: "a1", Public: true},
+		{OwnerID: "alice", Name: "a2"},
+		{OwnerID: "bob", Name: "b1", Public: true},
+	} {
+		library.Data = json.RawMessage(`{"type":"excalidrawlib"}`)
+		if _, err := store.CreateLibrary(ctx, &library); err != nil {
+			t.Fatalf("CreateLibrary() failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter core.LibraryFilter
+		want   int
+	}{
+		{"all", core.LibraryFilter{}, 3},
+		{"owner", core.LibraryFilter{OwnerID: "alice"}, 2},
+		{"public", core.LibraryFilter{PublicOnly: true}, 2},
+		{"owner public", core.LibraryFilter{OwnerID: "alice", PublicOnly: true}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			libraries, err := store.ListLibraries(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListLibraries() failed: %v", err)
+			}
+			if len(libraries) != tt.want {
+				t.Errorf("ListLibraries() returned %d, want %d", len(libraries), tt.want)
+			}
+			for _, library := range libraries {
+				if library.Data != nil {
+					t.Error("ListLibraries() should omit data")
+				}
+			}
+		})
+	}
+}
+
+func testRecordingLifecycle(t *testing.T, store core.RecordingStore) {
+	ctx := context.Background()
+	start := time.UnixMilli(1000)
+
+	id, err := store.CreateRecording(ctx, "room-1", start)
+	if err != nil {
+		t.Fatalf("CreateRecording() failed: %v", err)
+	}
+	for i, data := range []string{`{"n":1}`, `{"n":2}`} {
+		event := core.RecordingEvent{Offset: int64(i * 50), SocketID: "socket-a", Payload: json.RawMessage(data)}
+		if err := store.AppendRecordingEvent(ctx, id, event); err != nil {
+			t.Fatalf("AppendRecordingEvent() failed: %v", err)
+		}
+	}
+	if err := store.FinishRecording(ctx, id, start.Add(time.Second)); err != nil {
+		t.Fatalf("FinishRecording() failed: %v", err)
+	}
+
+	recording, err := store.GetRecording(ctx, id)
+	if err != nil {
+		t.Fatalf("GetRecording() failed: %v", err)
+	}
+	if recording.RoomID != "room-1" || recording.StartedAt != 1000 || recording.EndedAt != 2000 || recording.EventCount != 2 {
+		t.Errorf("GetRecording() = %+v", recording)
+	}
+
+	events, err := store.GetRecordingEvents(ctx, id)
+	if err != nil {
+		t.Fatalf("GetRecordingEvents() failed: %v", err)
+	}
+	if len(events) != 2 || events[1].Offset != 50 || string(events[1].Payload) != `{"n":2}` {
+		t.Errorf("GetRecordingEvents() = %+v", events)
+	}
+}
+
+func testListRecordings(t *testing.T, store core.RecordingStore) {
+	ctx := context.Background()
+
+	older, _ := store.CreateRecording(ctx, "room-1", time.UnixMilli(1000))
+	newer, _ := store.CreateRecording(ctx, "room-1", time.UnixMilli(2000))
+	_, _ = store.CreateRecording(ctx, "room-2", time.UnixMilli(3000))
+
+	recordings, err := store.ListRecordings(ctx, "room-1")
+	if err != nil {
+		t.Fatalf("ListRecordings() failed: %v", err)
+	}
+	if len(recordings) != 2 || recordings[0].ID != newer || recordings[1].ID != older {
+		t.Errorf("ListRecordings() = %+v", recordings)
+	}
+}
+
+func testRecordingNotFound(t *testing.T, store core.RecordingStore) {
+	ctx := context.Background()
+
+	if _, err := store.GetRecording(ctx, "missing"); !errors.Is(err, core.ErrRecordingNotFound) {
+		t.Errorf("GetRecording() error = %v, want ErrRecordingNotFound", err)
+	}
+	if err := store.AppendRecordingEvent(ctx, "missing", core.RecordingEvent{Payload: json.RawMessage("{}")}); !errors.Is(err, core.ErrRecordingNotFound) {
+		t.Errorf("AppendRecordingEvent() error = %v, want ErrRecordingNotFound", err)
+	}
+	if err := store.FinishRecording(ctx, "missing", time.Now()); !errors.Is(err, core.ErrRecordingNotFound) {
+		t.Errorf("FinishRecording() error = %v, want ErrRecordingNotFound", err)
+	}
+}
@@ -0,0 +1,261 @@
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaxInlineSize limits a client-sent thumbnail data URI, in bytes.
+const DefaultMaxInlineSize = 64 << 10
+
+const (
+	pngPrefix  = "data:image/png;base64,"
+	webpPrefix = "data:image/webp;base64,"
+)
+
+var (
+	// ErrInvalidInline is returned for thumbnails that aren't a PNG or WebP
+	// data URI.
+	ErrInvalidInline = errors.New("thumbnail is not a PNG or WebP data URI")
+	// ErrInlineTooLarge is returned for thumbnails still over the size
+	// limit after being shrunk.
+	ErrInlineTooLarge = errors.New("thumbnail too large")
+)
+
+// InlineStore holds the data URI thumbnails clients saved with their
+// snapshots before the server rendered its own.
+type InlineStore interface {
+	// ListInlineThumbnails lists the snapshots with an inline thumbnail.
+	ListInlineThumbnails(ctx context.Context) ([]string, error)
+	GetInlineThumbnail(ctx context.Context, snapshotID string) (string, error)
+	// SetInlineThumbnail replaces a thumbnail; empty removes it.
+	SetInlineThumbnail(ctx context.Context, snapshotID, thumbnail string) error
+}
+
+// MaxInlineSizeFromEnv reads THUMBNAIL_MAX_SIZE, the largest inline
+// thumbnail data URI kept, in bytes.
+func MaxInlineSizeFromEnv() int {
+	if value := os.Getenv("THUMBNAIL_MAX_SIZE"); value != "" {
+		if size, err := strconv.Atoi(value); err == nil && size > 0 {
+			return size
+		}
+		logrus.WithField("THUMBNAIL_MAX_SIZE", value).Warn("Invalid THUMBNAIL_MAX_SIZE, using default")
+	}
+	return DefaultMaxInlineSize
+}
+
+// InlineOK cheaply checks that a thumbnail looks like a PNG or WebP data
+// URI within maxSize, without decoding it. Empty thumbnails are fine.
+func InlineOK(thumbnail string, maxSize int) bool {
+	if thumbnail == "" {
+		return true
+	}
+	return len(thumbnail) <= maxSize &&
+		(strings.HasPrefix(thumbnail, pngPrefix) || strings.HasPrefix(thumbnail, webpPrefix))
+}
+
+// SanitizeInline validates a PNG or WebP data URI thumbnail and returns it
+// without metadata such as EXIF. PNGs are re-encoded and, when larger than
+// Width x Height, scaled down; WebPs only have their metadata chunks
+// removed, so oversized ones fail with ErrInlineTooLarge.
+func SanitizeInline(thumbnail string, maxSize int) (string, error) {
+	var prefix string
+	var clean []byte
+	switch {
+	case strings.HasPrefix(thumbnail, pngPrefix):
+		data, err := base64.StdEncoding.DecodeString(thumbnail[len(pngPrefix):])
+		if err != nil {
+			return "", ErrInvalidInline
+		}
+		prefix = pngPrefix
+		if clean, err = reencodePNG(data); err != nil {
+			return "", err
+		}
+	case strings.HasPrefix(thumbnail, webpPrefix):
+		data, err := base64.StdEncoding.DecodeString(thumbnail[len(webpPrefix):])
+		if err != nil {
+			return "", ErrInvalidInline
+		}
+		prefix = webpPrefix
+		if clean, err = stripWebP(data); err != nil {
+			return "", err
+		}
+	default:
+		return "", ErrInvalidInline
+	}
+
+	sanitized := prefix + base64.StdEncoding.EncodeToString(clean)
+	if len(sanitized) > maxSize {
+		return "", fmt.Errorf("%w: %d bytes, limit %d", ErrInlineTooLarge, len(sanitized), maxSize)
+	}
+	return sanitized, nil
+}
+
+// reencodePNG decodes a PNG and encodes its pixels again, which drops every
+// ancillary chunk, scaling it down to fit Width x Height.
+func reencodePNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+		return nil, ErrInvalidInline
+	}
+	// Check the size first, so a tiny file can't claim a huge canvas
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > 4096*4096 {
+		return nil, ErrInvalidInline
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidInline
+	}
+	if cfg.Width > Width || cfg.Height > Height {
+		img = shrink(img, Width, Height)
+	}
+
+	var out bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&out, img); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// shrink scales img down to fit width x height, keeping its aspect ratio,
+// by averaging the source pixels under each destination pixel.
+func shrink(img image.Image, width, height int) image.Image {
+	src := img.Bounds()
+	scale := min(float64(width)/float64(src.Dx()), float64(height)/float64(src.Dy()))
+	dw := max(1, int(float64(src.Dx())*scale))
+	dh := max(1, int(float64(src.Dy())*scale))
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		y0 := src.Min.Y + y*src.Dy()/dh
+		y1 := max(y0+1, src.Min.Y+(y+1)*src.Dy()/dh)
+		for x := 0; x < dw; x++ {
+			x0 := src.Min.X + x*src.Dx()/dw
+			x1 := max(x0+1, src.Min.X+(x+1)*src.Dx()/dw)
+			// Premultiplied sums, so transparent pixels don't darken edges
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			if a == 0 {
+				continue
+			}
+			dst.Pix[i+0] = uint8(r * 0xff / a)
+			dst.Pix[i+1] = uint8(g * 0xff / a)
+			dst.Pix[i+2] = uint8(b * 0xff / a)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
+
+// stripWebP removes the EXIF and XMP chunks of a WebP file and clears their
+// flags in the extended header.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrInvalidInline
+	}
+
+	out := append([]byte(nil), data[:12]...)
+	hasImage := false
+	for rest := data[12:]; len(rest) > 0; {
+		if len(rest) < 8 {
+			return nil, ErrInvalidInline
+		}
+		fourCC := string(rest[:4])
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		// Chunks are padded to an even size
+		end := 8 + size + size&1
+		if size < 0 || end > len(rest) {
+			return nil, ErrInvalidInline
+		}
+		chunk := rest[:end]
+		rest = rest[end:]
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			if size < 1 {
+				return nil, ErrInvalidInline
+			}
+			chunk = append([]byte(nil), chunk...)
+			// Bit 3 flags EXIF metadata and bit 2 XMP metadata
+			chunk[8] &^= 0x08 | 0x04
+		case "VP8 ", "VP8L", "ANIM":
+			hasImage = true
+		}
+		out = append(out, chunk...)
+	}
+	if !hasImage {
+		return nil, ErrInvalidInline
+	}
+
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
+
+// InlineResult counts what a pass over inline thumbnails did.
+type InlineResult struct {
+	Checked int `json:"checked"`
+	// Rewritten thumbnails were shrunk or had metadata removed.
+	Rewritten int `json:"rewritten"`
+	// Removed thumbnails were invalid or still too large; their snapshots
+	// fall back to the rendered thumbnail.
+	Removed int `json:"removed"`
+}
+
+// SanitizeInlineStore runs SanitizeInline over every inline thumbnail in
+// store, saving the ones it changed and removing the ones it rejects.
+func SanitizeInlineStore(ctx context.Context, store InlineStore, maxSize int) (InlineResult, error) {
+	var result InlineResult
+	ids, err := store.ListInlineThumbnails(ctx)
+	if err != nil {
+		return result, fmt.Errorf("list inline thumbnails: %w", err)
+	}
+
+	for _, id := range ids {
+		thumbnail, err := store.GetInlineThumbnail(ctx, id)
+		if err != nil {
+			// The snapshot may have been deleted since it was listed
+			logrus.WithField("snapshot_id", id).WithError(err).Warn("Failed to get inline thumbnail")
+			continue
+		}
+		result.Checked++
+
+		sanitized, err := SanitizeInline(thumbnail, maxSize)
+		if err != nil {
+			logrus.WithField("snapshot_id", id).WithError(err).Info("Removing inline thumbnail")
+			sanitized = ""
+		}
+		if sanitized == thumbnail {
+			continue
+		}
+		if err := store.SetInlineThumbnail(ctx, id, sanitized); err != nil {
+			return result, fmt.Errorf("save inline thumbnail: %w", err)
+		}
+		if sanitized == "" {
+			result.Removed++
+		} else {
+			result.Rewritten++
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,200 @@
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// pngURI encodes a width x height PNG as a data URI, with an eXIf chunk
+// when exif is set.
+func pngURI(t *testing.T, width, height int, exif bool) string {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i * 7)
+	}
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if exif {
+		// Insert the chunk right after IHDR
+		chunk := pngChunk("eXIf", []byte("MM\x00*GPS secret"))
+		data = append(append(append([]byte(nil), data[:33]...), chunk...), data[33:]...)
+	}
+	return pngPrefix + base64.StdEncoding.EncodeToString(data)
+}
+
+func pngChunk(kind string, payload []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func webpChunk(kind string, payload []byte) []byte {
+	chunk := append([]byte(kind), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func webpURI(chunks ...[]byte) string {
+	body := []byte("WEBP")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return webpPrefix + base64.StdEncoding.EncodeToString(append(data, body...))
+}
+
+func decodeURI(t *testing.T, uri string) []byte {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(uri[strings.Index(uri, ",")+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSanitizeInlinePNG(t *testing.T) {
+	sanitized, err := SanitizeInline(pngURI(t, 40, 20, true), DefaultMaxInlineSize)
+	if err != nil {
+		t.Fatalf("SanitizeInline() failed: %v", err)
+	}
+	data := decodeURI(t, sanitized)
+	if bytes.Contains(data, []byte("eXIf")) || bytes.Contains(data, []byte("secret")) {
+		t.Error("SanitizeInline() kept the EXIF chunk")
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("sanitized thumbnail isn't a PNG: %v", err)
+	}
+	if got := img.Bounds().Size(); got != (image.Point{40, 20}) {
+		t.Errorf("small thumbnail resized to %v", got)
+	}
+
+	// Sanitizing is idempotent, so the backfill job leaves it alone
+	if again, err := SanitizeInline(sanitized, DefaultMaxInlineSize); err != nil || again != sanitized {
+		t.Errorf("SanitizeInline() of a sanitized thumbnail changed it: %v", err)
+	}
+}
+
+func TestSanitizeInlineShrinksLargePNG(t *testing.T) {
+	sanitized, err := SanitizeInline(pngURI(t, 1600, 500, false), 1<<20)
+	if err != nil {
+		t.Fatalf("SanitizeInline() failed: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(decodeURI(t, sanitized)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Size(); got != (image.Point{Width, 125}) {
+		t.Errorf("shrunk thumbnail is %v, want %dx125", got, Width)
+	}
+
+	if _, err := SanitizeInline(pngURI(t, 1600, 500, false), 100); !errors.Is(err, ErrInlineTooLarge) {
+		t.Errorf("SanitizeInline() over the limit error = %v, want ErrInlineTooLarge", err)
+	}
+}
+
+func TestSanitizeInlineWebP(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x08 | 0x04 | 0x10
+	uri := webpURI(webpChunk("VP8X", vp8x), webpChunk("VP8L", []byte("pixels")), webpChunk("EXIF", []byte("GPS secret")), webpChunk("XMP ", []byte("<x/>")))
+
+	sanitized, err := SanitizeInline(uri, DefaultMaxInlineSize)
+	if err != nil {
+		t.Fatalf("SanitizeInline() failed: %v", err)
+	}
+	want := decodeURI(t, webpURI(webpChunk("VP8X", append([]byte{0x10}, vp8x[1:]...)), webpChunk("VP8L", []byte("pixels"))))
+	if got := decodeURI(t, sanitized); !bytes.Equal(got, want) {
+		t.Errorf("SanitizeInline() = %q, want %q", got, want)
+	}
+}
+
+func TestSanitizeInlineRejects(t *testing.T) {
+	for name, thumbnail := range map[string]string{
+		"svg":          "data:image/svg+xml;base64,PHN2Zy8+",
+		"url":          "https://example.com/a.png",
+		"bad base64":   pngPrefix + "!!!",
+		"not a png":    pngPrefix + base64.StdEncoding.EncodeToString([]byte("<html>")),
+		"no webp data": webpURI(webpChunk("EXIF", []byte("x"))),
+		"cut webp":     webpURI([]byte("VP8L\xff\x00\x00\x00")),
+	} {
+		if _, err := SanitizeInline(thumbnail, DefaultMaxInlineSize); !errors.Is(err, ErrInvalidInline) {
+			t.Errorf("SanitizeInline(%s) error = %v, want ErrInvalidInline", name, err)
+		}
+	}
+}
+
+func TestInlineOK(t *testing.T) {
+	if !InlineOK("", 10) || !InlineOK(pngPrefix+"AAAA", 100) {
+		t.Error("InlineOK() rejected a valid thumbnail")
+	}
+	if InlineOK(pngPrefix+"AAAA", 10) || InlineOK("data:text/html;base64,AAAA", 100) {
+		t.Error("InlineOK() accepted an oversized or non-image thumbnail")
+	}
+}
+
+type inlineStore map[string]string
+
+func (s inlineStore) ListInlineThumbnails(ctx context.Context) ([]string, error) {
+	ids := []string{}
+	for id := range s {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s inlineStore) GetInlineThumbnail(ctx context.Context, id string) (string, error) {
+	return s[id], nil
+}
+
+func (s inlineStore) SetInlineThumbnail(ctx context.Context, id, thumbnail string) error {
+	if thumbnail == "" {
+		delete(s, id)
+	} else {
+		s[id] = thumbnail
+	}
+	return nil
+}
+
+func TestSanitizeInlineStore(t *testing.T) {
+	clean, err := SanitizeInline(pngURI(t, 10, 10, false), DefaultMaxInlineSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := inlineStore{
+		"clean": clean,
+		"exif":  pngURI(t, 10, 10, true),
+		"html":  "data:text/html;base64,PGgxPg==",
+	}
+
+	result, err := SanitizeInlineStore(context.Background(), store, DefaultMaxInlineSize)
+	if err != nil {
+		t.Fatalf("SanitizeInlineStore() failed: %v", err)
+	}
+	if result != (InlineResult{Checked: 3, Rewritten: 1, Removed: 1}) {
+		t.Errorf("SanitizeInlineStore() = %+v", result)
+	}
+	if store["clean"] != clean || store["exif"] != clean {
+		t.Error("SanitizeInlineStore() didn't save the sanitized thumbnails")
+	}
+	if _, ok := store["html"]; ok {
+		t.Error("SanitizeInlineStore() kept an invalid thumbnail")
+	}
+}
@@ -0,0 +1,222 @@
+// Package thumbnails renders small PNG previews of stored scenes on the
+// server and caches them in a core.ThumbnailStore by content, so identical
+// canvases, snapshots and documents share one thumbnail and a scene is only
+// rendered again after it changes.
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"excalidraw-server/core"
+	"excalidraw-server/export"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Width and Height are the size of every thumbnail, in pixels.
+	Width  = 400
+	Height = 250
+
+	defaultBackfillInterval = time.Hour
+)
+
+// Service renders and caches scene thumbnails.
+type Service struct {
+	store core.ThumbnailStore
+}
+
+func NewService(store core.ThumbnailStore) *Service {
+	return &Service{store: store}
+}
+
+// BackfillIntervalFromEnv reads THUMBNAIL_BACKFILL_INTERVAL, how often the
+// thumbnails of all stored scenes are brought up to date; 0 disables it.
+func BackfillIntervalFromEnv() (time.Duration, error) {
+	value := os.Getenv("THUMBNAIL_BACKFILL_INTERVAL")
+	if value == "" {
+		return defaultBackfillInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("invalid THUMBNAIL_BACKFILL_INTERVAL %q: must be a non-negative duration", value)
+	}
+	return interval, nil
+}
+
+// Hash returns the key the thumbnail of a scene is cached under.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the thumbnail of a scene and its hash, rendering and caching
+// it first when it isn't cached yet. Scenes that aren't plain Excalidraw
+// JSON, such as encrypted canvases, fail with export.ErrNotScene.
+func (s *Service) Get(ctx context.Context, data []byte) (png []byte, hash string, err error) {
+	hash = Hash(data)
+	png, err = s.store.GetThumbnail(ctx, hash)
+	if err == nil {
+		return png, hash, nil
+	}
+	if !errors.Is(err, core.ErrThumbnailNotFound) {
+		return nil, "", err
+	}
+
+	if png, err = render(data); err != nil {
+		return nil, "", err
+	}
+	// A thumbnail that couldn't be cached is still worth serving
+	if err := s.store.PutThumbnail(ctx, hash, png); err != nil {
+		logrus.WithField("hash", hash).WithError(err).Warn("Failed to cache thumbnail")
+	}
+	return png, hash, nil
+}
+
+// render draws a scene as a thumbnail.
+func render(data []byte) ([]byte, error) {
+	scene, err := export.ParseScene(data)
+	if err != nil {
+		// Some clients save the scene as a JSON encoded string
+		var embedded string
+		if json.Unmarshal(data, &embedded) != nil {
+			return nil, err
+		}
+		if scene, err = export.ParseScene([]byte(embedded)); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	if err := export.Thumbnail(&out, scene, Width, Height, false); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Serve writes a thumbnail with its hash as ETag, or 304 Not Modified when
+// the client already has it. Callers set Cache-Control.
+func Serve(w http.ResponseWriter, r *http.Request, png []byte, hash string) {
+	etag := `"` + hash + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := w.Write(png); err != nil {
+		logrus.WithField("hash", hash).WithError(err).Warn("Failed to write thumbnail")
+	}
+}
+
+// BackfillResult counts what a backfill pass did.
+type BackfillResult struct {
+	Scenes   int `json:"scenes"`
+	Rendered int `json:"rendered"`
+	// Skipped scenes aren't plain Excalidraw JSON and have no thumbnail.
+	Skipped int `json:"skipped"`
+	Deleted int `json:"deleted"`
+	// Inline covers the thumbnails clients saved with snapshots, for
+	// stores that have them.
+	Inline InlineResult `json:"inline"`
+}
+
+// Backfill renders the thumbnails of the stored scenes that don't have one
+// yet and deletes the thumbnails of scenes that no longer exist. When scenes
+// is also an InlineStore, its inline thumbnails are sanitized as well.
+func (s *Service) Backfill(ctx context.Context, scenes core.SceneScanner) (BackfillResult, error) {
+	// Thumbnails are listed before scanning, so one rendered for a scene
+	// saved during the scan is never taken for an orphan
+	cached, err := s.store.ListThumbnails(ctx)
+	if err != nil {
+		return BackfillResult{}, fmt.Errorf("list thumbnails: %w", err)
+	}
+	orphans := make(map[string]bool, len(cached))
+	for _, hash := range cached {
+		orphans[hash] = true
+	}
+
+	var result BackfillResult
+	seen := make(map[string]bool)
+	err = scenes.ScanScenes(ctx, func(data []byte) error {
+		result.Scenes++
+		hash := Hash(data)
+		if seen[hash] {
+			return nil
+		}
+		seen[hash] = true
+		if orphans[hash] {
+			delete(orphans, hash)
+			return nil
+		}
+
+		png, err := render(data)
+		if errors.Is(err, export.ErrNotScene) {
+			result.Skipped++
+			return nil
+		}
+		if err != nil {
+			logrus.WithField("hash", hash).WithError(err).Warn("Failed to render thumbnail")
+			return nil
+		}
+		if err := s.store.PutThumbnail(ctx, hash, png); err != nil {
+			return fmt.Errorf("save thumbnail: %w", err)
+		}
+		result.Rendered++
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("scan scenes: %w", err)
+	}
+
+	for hash := range orphans {
+		if err := s.store.DeleteThumbnail(ctx, hash); err != nil {
+			logrus.WithField("hash", hash).WithError(err).Warn("Failed to delete orphaned thumbnail")
+			continue
+		}
+		result.Deleted++
+	}
+
+	if inline, ok := scenes.(InlineStore); ok {
+		if result.Inline, err = SanitizeInlineStore(ctx, inline, MaxInlineSizeFromEnv()); err != nil {
+			return result, err
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"scenes":           result.Scenes,
+		"rendered":         result.Rendered,
+		"skipped":          result.Skipped,
+		"deleted":          result.Deleted,
+		"inline_rewritten": result.Inline.Rewritten,
+		"inline_removed":   result.Inline.Removed,
+	}).Info("Thumbnail backfill finished")
+	return result, nil
+}
+
+// StartBackfill runs a backfill pass right away and then every interval,
+// until stop is closed.
+func (s *Service) StartBackfill(scenes core.SceneScanner, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Backfill(context.Background(), scenes); err != nil {
+			logrus.WithError(err).Error("Thumbnail backfill failed")
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
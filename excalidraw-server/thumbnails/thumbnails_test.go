@@ -0,0 +1,125 @@
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"excalidraw-server/core"
+	"excalidraw-server/export"
+	"excalidraw-server/stores/memory"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const scene = `{"elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":50,"strokeColor":"#1e1e1e"}]}`
+
+func TestGet(t *testing.T) {
+	store := memory.NewDocumentStore().(core.ThumbnailStore)
+	service := NewService(store)
+	ctx := context.Background()
+
+	data, hash, err := service.Get(ctx, []byte(scene))
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if hash != Hash([]byte(scene)) {
+		t.Errorf("hash = %s, want the scene's", hash)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("thumbnail isn't a PNG: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != Width || size.Y != Height {
+		t.Errorf("thumbnail is %v, want %dx%d", size, Width, Height)
+	}
+
+	// The second call is served from the store
+	if err := store.PutThumbnail(ctx, hash, []byte("cached")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, err := service.Get(ctx, []byte(scene)); err != nil || string(data) != "cached" {
+		t.Errorf("Get() = %q, %v, want the cached thumbnail", data, err)
+	}
+
+	embedded := `"` + `{\"elements\":[]}` + `"`
+	if _, _, err := service.Get(ctx, []byte(embedded)); err != nil {
+		t.Errorf("Get() of a scene saved as a string failed: %v", err)
+	}
+	if _, _, err := service.Get(ctx, []byte("\x00encrypted")); !errors.Is(err, export.ErrNotScene) {
+		t.Errorf("Get() of an encrypted scene error = %v, want export.ErrNotScene", err)
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	store := memory.NewDocumentStore()
+	thumbnailStore := store.(core.ThumbnailStore)
+	canvases := store.(core.CanvasStore)
+	service := NewService(thumbnailStore)
+	ctx := context.Background()
+
+	for _, canvas := range []core.Canvas{
+		{OwnerID: "alice", Key: "a", Data: []byte(scene)},
+		{OwnerID: "bob", Key: "copy", Data: []byte(scene)},
+		{OwnerID: "bob", Key: "encrypted", Data: []byte("\x00encrypted")},
+	} {
+		if err := canvases.PutCanvas(ctx, &canvas); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := thumbnailStore.PutThumbnail(ctx, Hash([]byte("deleted scene")), []byte("orphan")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := service.Backfill(ctx, store.(core.SceneScanner))
+	if err != nil {
+		t.Fatalf("Backfill() failed: %v", err)
+	}
+	if want := (BackfillResult{Scenes: 3, Rendered: 1, Skipped: 1, Deleted: 1}); result != want {
+		t.Errorf("Backfill() = %+v, want %+v", result, want)
+	}
+	hashes, _ := thumbnailStore.ListThumbnails(ctx)
+	if len(hashes) != 1 || hashes[0] != Hash([]byte(scene)) {
+		t.Errorf("thumbnails = %v, want only the shared canvas's", hashes)
+	}
+
+	result, err = service.Backfill(ctx, store.(core.SceneScanner))
+	if err != nil || result.Rendered != 0 || result.Deleted != 0 {
+		t.Errorf("second Backfill() = %+v, %v, want nothing to do", result, err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	w := httptest.NewRecorder()
+	Serve(w, httptest.NewRequest(http.MethodGet, "/thumbnail", nil), []byte("png"), "abc")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"abc"` || w.Header().Get("Content-Type") != "image/png" || w.Body.String() != "png" {
+		t.Errorf("Serve() = %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/thumbnail", nil)
+	r.Header.Set("If-None-Match", `"abc"`)
+	w = httptest.NewRecorder()
+	Serve(w, r, []byte("png"), "abc")
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Serve() with a matching ETag = %d, %q, want 304", w.Code, w.Body.String())
+	}
+}
+
+func TestBackfillIntervalFromEnv(t *testing.T) {
+	t.Setenv("THUMBNAIL_BACKFILL_INTERVAL", "")
+	if interval, err := BackfillIntervalFromEnv(); err != nil || interval != time.Hour {
+		t.Errorf("BackfillIntervalFromEnv() unset = %v, %v, want 1h", interval, err)
+	}
+	t.Setenv("THUMBNAIL_BACKFILL_INTERVAL", "0")
+	if interval, err := BackfillIntervalFromEnv(); err != nil || interval != 0 {
+		t.Errorf("BackfillIntervalFromEnv() = %v, %v, want 0", interval, err)
+	}
+	for _, value := range []string{"soon", "-1m"} {
+		t.Setenv("THUMBNAIL_BACKFILL_INTERVAL", value)
+		if _, err := BackfillIntervalFromEnv(); err == nil {
+			t.Errorf("BackfillIntervalFromEnv() accepted %q", value)
+		}
+	}
+}